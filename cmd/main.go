@@ -14,6 +14,7 @@ import (
 	"github.com/omniscale/imposm3/geom/limit"
 	"github.com/omniscale/imposm3/import_"
 	"github.com/omniscale/imposm3/logging"
+	"github.com/omniscale/imposm3/mapping"
 	"github.com/omniscale/imposm3/stats"
 )
 
@@ -25,6 +26,7 @@ func PrintCmds() {
 	fmt.Println("\timport")
 	fmt.Println("\tdiff")
 	fmt.Println("\tquery-cache")
+	fmt.Println("\tdefault-mapping")
 	fmt.Println("\tversion")
 }
 
@@ -99,6 +101,15 @@ func Main(usage func()) {
 
 	case "query-cache":
 		query.Query(os.Args[2:])
+	case "default-mapping":
+		if len(os.Args) != 3 {
+			fmt.Fprintf(os.Stderr, "Usage: %s default-mapping FILE\n", os.Args[0])
+			logging.Shutdown()
+			os.Exit(2)
+		}
+		if err := mapping.WriteDefault(os.Args[2]); err != nil {
+			log.Fatal(err)
+		}
 	case "version":
 		fmt.Println(Version)
 		os.Exit(0)
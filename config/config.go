@@ -120,9 +120,6 @@ func (o *_BaseOptions) check() []error {
 	if o.Srid != 3857 && o.Srid != 4326 {
 		errs = append(errs, errors.New("only -srid=3857 or -srid=4326 are supported"))
 	}
-	if o.MappingFile == "" {
-		errs = append(errs, errors.New("missing mapping"))
-	}
 	return errs
 }
 
@@ -146,7 +143,7 @@ func addBaseFlags(flags *flag.FlagSet) {
 	flags.StringVar(&BaseOptions.Connection, "connection", "", "connection parameters")
 	flags.StringVar(&BaseOptions.CacheDir, "cachedir", defaultCacheDir, "cache directory")
 	flags.StringVar(&BaseOptions.DiffDir, "diffdir", "", "diff directory for last.state.txt")
-	flags.StringVar(&BaseOptions.MappingFile, "mapping", "", "mapping file")
+	flags.StringVar(&BaseOptions.MappingFile, "mapping", "", "mapping file (default mapping if empty)")
 	flags.IntVar(&BaseOptions.Srid, "srid", defaultSrid, "srs id")
 	flags.StringVar(&BaseOptions.LimitTo, "limitto", "", "limit to geometries")
 	flags.Float64Var(&BaseOptions.LimitToCacheBuffer, "limittocachebuffer", 0.0, "limit to buffer for cache")
@@ -3,6 +3,7 @@ package database
 import (
 	"errors"
 	"strings"
+	"time"
 
 	"github.com/omniscale/imposm3/element"
 	"github.com/omniscale/imposm3/geom"
@@ -11,12 +12,294 @@ import (
 
 type Config struct {
 	ConnectionParams string
+	// Srid of the inserted geometries and of all tables without srid
+	// option. 0 detects the SRID from the first inserted geometry. All
+	// geometries need to have the same SRID in this case.
 	Srid             int
 	ImportSchema     string
 	ProductionSchema string
 	BackupSchema     string
+	// GeometryEncoding "ewkb" inserts geometries with ST_GeomFromEWKB.
+	// The SRID embedded in each geometry needs to match Srid (or the
+	// SRID of the table, if no transformation is required), geometries
+	// without SRID get Srid. "wkt" inserts WKT strings with
+	// ST_GeomFromText and requires Srid. Defaults to casting the hex
+	// encoded (E)WKB to geometry.
+	GeometryEncoding string
+	// TransformPipeline is a PROJ pipeline (or a URN of a coordinate
+	// operation) for the transformation of geometries into the SRID of
+	// tables with srid, instead of the default transformation of PROJ.
+	// Requires PostGIS 3.4 for ST_TransformPipeline.
+	TransformPipeline string
+	// GridSize reduces the precision of all inserted geometries to a
+	// grid of this size, in units of the SRID of the table (0 to
+	// disable). Uses ST_ReducePrecision with PostGIS 3.1 or newer, which
+	// keeps polygons valid, and ST_SnapToGrid for older versions.
+	// Geometries are inserted with INSERT instead of COPY.
+	GridSize float64
+	// DDLRetries is the number of retries for creating a table when
+	// the DDL statement fails with a lock timeout.
+	DDLRetries int
+	// InsertDefaultId includes the serial id column as DEFAULT in all
+	// INSERT statements, so that the column lists are uniform across tables.
+	InsertDefaultId bool
+	// InvalidTables creates a <table>_invalid table for each table.
+	// Rows that are rejected during the import are inserted into these
+	// tables, together with the reason for the rejection.
+	InvalidTables bool
+	// DropEmptyInvalidTables removes all invalid tables without rows
+	// in Finish.
+	DropEmptyInvalidTables bool
+	// DisableTriggersDuringLoad disables all triggers (including foreign
+	// key triggers) of the tables between Begin and End. Requires
+	// superuser privileges.
+	DisableTriggersDuringLoad bool
+	// GeneralizeWorkers is the number of generalized tables that are
+	// built in parallel, each with its own connection (0 for GOMAXPROCS).
+	GeneralizeWorkers int
+	// CrashedImportPolicy defines what Init does if a previous import did
+	// not finish: "abort" (default) or "cleanup" to drop all tables of the
+	// previous import.
+	CrashedImportPolicy string
+	// CopyBufferRows is the number of rows after which the COPY of a
+	// bulk import is finished and restarted (0 to COPY all rows of a
+	// table at once). Rows that require ST_Subdivide or that are rejected
+	// are kept in memory till the COPY is finished. Smaller values reduce
+	// the memory usage, but require more round trips.
+	CopyBufferRows int
+	// CopyBufferBytes is the approximate number of bytes after which the
+	// COPY of a bulk import is finished and restarted (0 for no limit).
+	// Both limits can be set for each table in the mapping.
+	CopyBufferBytes int
+	// LoadMethod of bulk imports for all tables without load_method:
+	// "copy", "insert", "staging" or "auto" (default). Tables that require INSERT
+	// (e.g. upsert) never use COPY. Tables with auto start with INSERT
+	// and switch to COPY after CopyThresholdRows rows (0 to always use
+	// COPY).
+	LoadMethod        string
+	CopyThresholdRows int
+	// MaxRowsPerSecond and MaxBytesPerSecond limit the write rate of all
+	// tables, to reduce the load on shared database servers. Imports wait
+	// after each batch (COPY flush or commit), not after each row. 0
+	// disables the limit. SetThrottle (Throttler) changes the limits during
+	// the import.
+	MaxRowsPerSecond  int
+	MaxBytesPerSecond int
+	// MaxTransactionAge commits the transactions of bulk imports and
+	// continues with new transactions when they are open for longer, to
+	// allow vacuum and replication to catch up. It is ignored for diff
+	// imports, which commit all changes of a diff in one transaction.
+	MaxTransactionAge time.Duration
+	// AnalyzeEveryRows runs ANALYZE on a table each time a bulk import
+	// committed this many rows into the table, so that queries on the
+	// tables during the import get statistics. Rows are only committed
+	// during the import with MaxTransactionAge or Flush. ANALYZE runs on
+	// a separate connection, at most one at a time for each table. 0
+	// disables it, analyze_every_rows of a table in the mapping overrides
+	// it. The ANALYZE of Optimize runs in any case.
+	AnalyzeEveryRows int
+	// DeferConstraintValidation creates tables without their CHECK
+	// constraints (geometry_check: immediate) and adds them with NOT
+	// VALID in Finish, after the load, and validates them, in parallel
+	// for all tables. Invalid rows are not rejected during the load, they
+	// fail Finish with a ConstraintViolationError instead.
+	DeferConstraintValidation bool
+	// WarnConstraintViolations logs the violations of
+	// DeferConstraintValidation as warnings, instead of failing Finish.
+	// The constraints stay NOT VALID, they are still checked for new
+	// rows, e.g. of diff imports.
+	WarnConstraintViolations bool
+	// IndexNameTemplate names all indexes that are created for the
+	// tables, e.g. "ix_{table}_{column}". {table} is replaced by the
+	// table name and {column} by the indexed column (or by
+	// {column}_geohash for geohash indexes, expr_<hash> for expressions).
+	// Names longer than 63 bytes are truncated with a hash. Empty uses
+	// the default names, e.g. osm_roads_geom and osm_roads_osm_id_idx.
+	IndexNameTemplate string
+	// ImportIDColumn adds a UUID column with this name to all tables. All
+	// inserted (and upserted) rows get the ImportID, so that all rows of
+	// an import run can be deleted with DeleteByImportID. Empty disables
+	// the column.
+	ImportIDColumn string
+	// ImportID is the UUID of the import run for the ImportIDColumn. A
+	// random UUID is generated if it is empty.
+	ImportID string
+	// PreserveM declares all geometry columns with the M dimension (e.g.
+	// LINESTRINGM), for geometries with measures (e.g. for routing or
+	// linear referencing). Inserted geometries need M values. Geometries
+	// are reduced to the GridSize with ST_SnapToGrid, as
+	// ST_ReducePrecision drops the M values.
+	PreserveM bool
+	// GeometryMismatchPolicy defines what Init does if the geometry
+	// column of a table already exists with another type or SRID than in
+	// the mapping (e.g. a table that was not dropped): "error" (default),
+	// "recreate" to drop and add the column again, or "ignore" to keep
+	// the existing column.
+	GeometryMismatchPolicy string
+	// TagsTable creates a tags table (osm_tags with the default prefix)
+	// with the osm_id and all tags of each imported element, for
+	// debugging the mapping. The tags are stored as "hstore" or "jsonb".
+	// Empty disables the table.
+	TagsTable string
+	// SkipGeometry creates all tables without geometry columns, for
+	// imports that only need the tags. Generalized tables are skipped.
+	SkipGeometry bool
+	// SpatialStats logs the extent and the number of NULL and empty
+	// geometries of each table after Optimize, with a warning for extents
+	// outside of the SRID bounds. SpatialStatsSample calculates them from
+	// a 1% sample, for large tables.
+	SpatialStats       bool
+	SpatialStatsSample bool
+	// PgBouncer enables the compatibility mode for pgbouncer in
+	// transaction pooling mode (see postgis/pgbouncer.go). It is also
+	// enabled with pgbouncer=true in the connection params.
+	PgBouncer bool
+	// DefaultTablespace and TempTablespaces are set for each connection
+	// (SET default_tablespace and temp_tablespaces), so that all tables
+	// and indexes, and all temporary files (e.g. of sorts for indexes),
+	// are created in these tablespaces. Empty for the defaults of the
+	// server. Not supported with PgBouncer.
+	DefaultTablespace string
+	TempTablespaces   []string
+	// SuppressNoticeCodes are the SQLSTATE codes of PostgreSQL notices
+	// that are not logged, 01P01 (deprecated feature) if nil, e.g. for
+	// AddGeometryColumn with newer PostGIS versions. Set it to an empty
+	// slice to log all notices.
+	SuppressNoticeCodes []string
+	// SuppressNoticeMessages suppresses notices that contain one of the
+	// messages (case-insensitive).
+	SuppressNoticeMessages []string
+	// DeployLockTimeout is the lock_timeout for the rotation of the tables
+	// in Deploy and RevertDeploy. The rotation fails instead of waiting
+	// for queries that lock the production tables, and can be retried.
+	// Defaults to no timeout.
+	DeployLockTimeout time.Duration
+	// Reindex lets Optimize rebuild the indexes that imposm created for
+	// all tables, instead of clustering them. This can be enabled for
+	// each table with reindex in the mapping. ReindexOnline rebuilds the
+	// indexes without blocking writes (REINDEX CONCURRENTLY, or a new
+	// index that replaces the old index before PostgreSQL 12).
+	Reindex       bool
+	ReindexOnline bool
+	// RenameReservedColumns renames fields that use a column name that is
+	// reserved by imposm (id, osm_id, geometry) with a _tag suffix,
+	// instead of failing.
+	RenameReservedColumns bool
+	// TableSchemas contains the schemas for each schema name of the
+	// mapping (see schema option of tables). Tables without schema use
+	// ImportSchema, ProductionSchema and BackupSchema.
+	TableSchemas map[string]Schemas
+	// CustomSrids are inserted into spatial_ref_sys in Init, before any
+	// table is created, e.g. for national grids. Existing SRIDs are not
+	// changed. User-defined SRIDs (900000 and above) of Srid and of the
+	// tables need to be listed here.
+	CustomSrids []SridDef
+	// NormalizeStrings normalizes the values of all string columns,
+	// unless the field has its own normalize option.
+	NormalizeStrings *mapping.Normalize
+	// NullPolicy for all string columns, unless the field has its own
+	// null_policy (empty_as_null, null_as_empty or verbatim). Defaults
+	// to verbatim.
+	NullPolicy string
+	// SkipRowsMissingRequired skips rows with a NULL value in a not_null
+	// column without default, instead of failing the insert. Skipped rows
+	// are counted in RowCounts.
+	SkipRowsMissingRequired bool
+	// SoftDelete adds a deleted column to all tables. Deletes of diff
+	// imports set deleted to true instead of removing the rows.
+	SoftDelete bool
+	// CopyProgress is called every CopyProgressRows rows during the COPY
+	// of a bulk import.
+	CopyProgress     ProgressFunc
+	CopyProgressRows int
+	// Cancel aborts the COPY of all tables of a bulk import when it is
+	// closed. The transactions are rolled back and End returns
+	// ErrCanceled.
+	Cancel <-chan struct{}
+	// OnlyTables limits the import to these tables of the mapping and
+	// SkipTables excludes tables from the import. Generalized tables are
+	// included if their source table is included. Init, Finish and Deploy
+	// only handle the selected tables.
+	OnlyTables []string
+	SkipTables []string
+	// SkippedTableInserts defines what happens with rows for tables that
+	// are not selected: "ignore" (default) or "error".
+	SkippedTableInserts string
+	// GrantDefaultPrivileges lets GrantSelect alter the default
+	// privileges of the schemas, so that the role can also read all
+	// tables of later imports.
+	GrantDefaultPrivileges bool
+	// OnRowError is called for each row that is rejected before it is
+	// inserted (e.g. max_vertices or invalid geometries). It is not
+	// called for failed statements or transactions, as PostgreSQL
+	// aborts the whole transaction in this case. It can be called
+	// concurrently from multiple goroutines. Defaults to SkipRowErrors.
+	OnRowError RowErrorFunc
+	// MappingVersion lets Init record the mapping of each table in the
+	// <prefix>import_meta table of the import schema, together with
+	// MappingName (e.g. the name of the mapping file) and the time of
+	// the import.
+	MappingName    string
+	MappingVersion string
 }
 
+// ProgressFunc is called with the name of the table and the number of
+// inserted rows.
+type ProgressFunc func(table string, rows int64)
+
+// ErrorAction defines what happens with a rejected row.
+type ErrorAction int
+
+const (
+	// SkipRow logs the row, counts it as failed and inserts it into the
+	// invalid table, if InvalidTables is enabled.
+	SkipRow ErrorAction = iota
+	// RetryRow prepares the row again. The RowErrorFunc can modify the
+	// values of the row before it returns RetryRow. Each row is only
+	// retried once, a second RetryRow skips the row.
+	RetryRow
+	// AbortImport fails the import. The transactions are rolled back.
+	AbortImport
+)
+
+// RowErrorFunc is called with the name of the table, the values of the
+// rejected row and the reason for the rejection.
+type RowErrorFunc func(table string, row []interface{}, err error) ErrorAction
+
+// SkipRowErrors is the default RowErrorFunc. It skips all rejected rows.
+func SkipRowErrors(table string, row []interface{}, err error) ErrorAction {
+	return SkipRow
+}
+
+// ErrCanceled is returned if the import was canceled.
+var ErrCanceled = errors.New("import canceled")
+
+// Schemas are the import, production and backup schema of a group of
+// tables.
+type Schemas struct {
+	Import     string
+	Production string
+	Backup     string
+}
+
+// SridDef is a custom spatial reference system. AuthSrid defaults to
+// Srid. Proj4 or Wkt is required.
+type SridDef struct {
+	Srid     int
+	AuthName string
+	AuthSrid int
+	Proj4    string
+	Wkt      string
+}
+
+// DB is the interface of all database backends.
+//
+// Init, Begin, BeginBulk, End, Abort and Close change the state of the
+// database and need to be called from a single goroutine, and never
+// concurrently with any other method. The methods of Inserter and
+// Deleter can be called concurrently from multiple goroutines between
+// Begin/BeginBulk and End/Abort. All other methods (Generalize, Finish,
+// Deploy, etc.) need to be called after End.
 type DB interface {
 	Begin() error
 	End() error
@@ -62,10 +345,64 @@ type Deleter interface {
 	DeleteElem(element.OSMElem) error
 }
 
+type TableChecker interface {
+	// CheckTables verifies that existing tables match the mapping
+	// before any data is written into them.
+	CheckTables() error
+}
+
 type Optimizer interface {
 	Optimize() error
 }
 
+type Granter interface {
+	// GrantSelect grants read access on all tables to role.
+	GrantSelect(role string) error
+}
+
+type ReferenceChecker interface {
+	// CheckReferences returns the number of rows of childTable that
+	// reference a missing row of parentTable and a sample of the
+	// missing values.
+	CheckReferences(childTable, childCol, parentTable, parentCol string) (int64, []string, error)
+}
+
+type IdInserter interface {
+	// InsertBatchReturningIds inserts the rows into table and returns
+	// the generated id of each row, 0 for skipped rows.
+	InsertBatchReturningIds(table string, rows [][]interface{}) ([]int64, error)
+}
+
+type Warmer interface {
+	// Warmup opens n connections before the import, so that the first
+	// inserts don't need to connect.
+	Warmup(n int) error
+}
+
+type Throttler interface {
+	// SetThrottle changes the max. rows and bytes per second of all
+	// inserts. 0 disables the limit.
+	SetThrottle(rowsPerSecond, bytesPerSecond int)
+}
+
+type Flusher interface {
+	// Flush commits all inserts before the call. Close implies a final
+	// Flush.
+	Flush() error
+}
+
+type MultiInserter interface {
+	// InsertMultiBatch inserts the rows of all tables (by name) in one
+	// transaction. Errors roll back the rows of all tables.
+	InsertMultiBatch(batches map[string][][]interface{}) error
+}
+
+type CentroidCreator interface {
+	// CreateCentroidTable creates a table with a point on the surface of
+	// each geometry of the table.
+	CreateCentroidTable(table string) error
+}
+
 var databases map[string]func(Config, *mapping.Mapping) (DB, error)
 
 func init() {
@@ -0,0 +1,61 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/omniscale/imposm3/element"
+	"github.com/omniscale/imposm3/geom"
+	"github.com/omniscale/imposm3/mapping"
+)
+
+func TestDefaultMappingNullDb(t *testing.T) {
+	m := mapping.Default()
+	db, err := Open(Config{ConnectionParams: "null:"}, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Begin(); err != nil {
+		t.Fatal(err)
+	}
+
+	node := element.Node{OSMElem: element.OSMElem{Id: 1, Tags: element.Tags{"amenity": "school", "name": "School"}}}
+	matches := m.PointMatcher().MatchNode(&node)
+	if len(matches) == 0 {
+		t.Error("no match for node")
+	}
+	if err := db.InsertPoint(node.OSMElem, geom.Geometry{}, matches); err != nil {
+		t.Fatal(err)
+	}
+
+	road := element.Way{OSMElem: element.OSMElem{Id: 2, Tags: element.Tags{"highway": "primary", "name": "Main Street"}}}
+	matches = m.LineStringMatcher().MatchWay(&road)
+	if len(matches) == 0 {
+		t.Error("no match for road")
+	}
+	for _, match := range matches {
+		// rows are built for all columns of the table
+		match.Row(&road.OSMElem, &geom.Geometry{})
+	}
+	if err := db.InsertLineString(road.OSMElem, geom.Geometry{}, matches); err != nil {
+		t.Fatal(err)
+	}
+
+	building := element.Way{OSMElem: element.OSMElem{Id: 3, Tags: element.Tags{"building": "yes"}}, Refs: []int64{1, 2, 3, 1}}
+	matches = m.PolygonMatcher().MatchWay(&building)
+	if len(matches) == 0 {
+		t.Error("no match for building")
+	}
+	if err := db.InsertPolygon(building.OSMElem, geom.Geometry{}, matches); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.End(); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,94 @@
+package postgis
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Bulk imports analyze tables with AnalyzeEveryRows each time that many
+// rows were committed (see bulkTableTx.flush), so that queries on the
+// tables during the import (e.g. of generalized tables or hooks of
+// overlapping import phases) do not run without statistics. ANALYZE runs
+// in the background on another connection of pg.Db, the import
+// continues meanwhile. Only one ANALYZE runs at a time for each table and
+// the next starts at least minAnalyzeInterval later, committed rows are
+// collected till then.
+
+var minAnalyzeInterval = time.Minute
+
+// analyzer tracks the committed rows and the ANALYZE runs of a table.
+type analyzer struct {
+	mu      sync.Mutex
+	pending int64
+	running bool
+	last    time.Time
+	runs    int64
+	wg      sync.WaitGroup
+}
+
+// start adds the committed rows and returns true if the table needs to
+// be analyzed. done needs to be called after the ANALYZE.
+func (a *analyzer) start(rows int64, every int) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.pending += rows
+	if a.pending < int64(every) || a.running {
+		return false
+	}
+	if !a.last.IsZero() && now().Sub(a.last) < minAnalyzeInterval {
+		return false
+	}
+	a.pending = 0
+	a.running = true
+	a.last = now()
+	a.runs += 1
+	a.wg.Add(1)
+	return true
+}
+
+func (a *analyzer) done() {
+	a.mu.Lock()
+	a.running = false
+	a.mu.Unlock()
+	a.wg.Done()
+}
+
+// analyzeCommitted analyzes the table in the background if enough rows
+// were committed.
+func (pg *PostGIS) analyzeCommitted(spec *TableSpec, rows int64) {
+	if spec.analyzer == nil || rows == 0 || !spec.analyzer.start(rows, spec.AnalyzeEveryRows) {
+		return
+	}
+	sql := fmt.Sprintf(`ANALYZE "%s"."%s"`, spec.Schema, spec.FullName)
+	go func() {
+		defer spec.analyzer.done()
+		if _, err := pg.Db.Exec(sql); err != nil {
+			log.Warnf("analyzing %s during the import: %s", spec.FullName, &SQLError{sql, err})
+		}
+	}()
+}
+
+// waitAnalyzes waits till the ANALYZE of all tables finished.
+func (pg *PostGIS) waitAnalyzes() {
+	for _, spec := range pg.Tables {
+		if spec.analyzer != nil {
+			spec.analyzer.wg.Wait()
+		}
+	}
+}
+
+// Analyzes returns the number of ANALYZE runs during imports, for all
+// tables with AnalyzeEveryRows.
+func (pg *PostGIS) Analyzes() map[string]int64 {
+	runs := make(map[string]int64)
+	for name, spec := range pg.Tables {
+		if spec.analyzer == nil {
+			continue
+		}
+		spec.analyzer.mu.Lock()
+		runs[name] = spec.analyzer.runs
+		spec.analyzer.mu.Unlock()
+	}
+	return runs
+}
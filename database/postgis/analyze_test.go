@@ -0,0 +1,92 @@
+package postgis
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAnalyzeEveryRows(t *testing.T) {
+	clock, restore := installFakeClock()
+	defer restore()
+	db, d := newFakeDb()
+	defer db.Close()
+	pg := testFlushPostGIS(t, db)
+	roads := pg.Tables["roads"]
+	roads.AnalyzeEveryRows = 5
+	roads.analyzer = &analyzer{}
+	if err := pg.BeginBulk(); err != nil {
+		t.Fatal(err)
+	}
+	line := ewkbLineString(3857, 0, 0, 1, 1).hex()
+	insert := func(n int) {
+		for i := 0; i < n; i++ {
+			pg.txRouter.Insert("roads", []interface{}{int64(i), line, "", ""})
+			pg.txRouter.Insert("buildings", []interface{}{int64(i), line, "", ""})
+		}
+		if err := pg.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		pg.waitAnalyzes()
+	}
+
+	insert(3)
+	if n := d.count("ANALYZE"); n != 0 {
+		t.Error("unexpected ANALYZE", n)
+	}
+	insert(3)
+	if n := d.count(`ANALYZE "import"."osm_roads"`); n != 1 {
+		t.Error("unexpected ANALYZE", n)
+	}
+	// not again within minAnalyzeInterval
+	insert(10)
+	if n := d.count("ANALYZE"); n != 1 {
+		t.Error("unexpected ANALYZE", n)
+	}
+	clock.sleep(time.Minute)
+	insert(1)
+	if n := d.count("ANALYZE"); n != 2 {
+		t.Error("unexpected ANALYZE", n)
+	}
+	if err := pg.txRouter.End(); err != nil {
+		t.Fatal(err)
+	}
+	if runs := pg.Analyzes(); !reflect.DeepEqual(runs, map[string]int64{"roads": 2}) {
+		t.Error("unexpected analyzes", runs)
+	}
+}
+
+func TestAnalyzerSingleFlight(t *testing.T) {
+	a := &analyzer{}
+	if !a.start(10, 10) {
+		t.Fatal("expected ANALYZE")
+	}
+	// running, rows are collected
+	if a.start(100, 10) {
+		t.Error("unexpected second ANALYZE")
+	}
+	a.done()
+	a.last = time.Time{}
+	if !a.start(0, 10) {
+		t.Error("expected ANALYZE of collected rows")
+	}
+	a.done()
+}
+
+func TestAnalyzeEveryRowsOverride(t *testing.T) {
+	pg := testPostGIS()
+	pg.Config.AnalyzeEveryRows = 1000
+	spec := testTableSpec(t, pg, testTable())
+	if spec.AnalyzeEveryRows != 1000 || spec.analyzer == nil {
+		t.Error("unexpected AnalyzeEveryRows", spec.AnalyzeEveryRows)
+	}
+	table := testTable()
+	table.AnalyzeEveryRows = -1
+	if spec := testTableSpec(t, pg, table); spec.analyzer != nil {
+		t.Error("ANALYZE not disabled")
+	}
+	table.AnalyzeEveryRows = 50
+	if spec := testTableSpec(t, testPostGIS(), table); spec.AnalyzeEveryRows != 50 || spec.analyzer == nil {
+		t.Error("unexpected AnalyzeEveryRows", spec.AnalyzeEveryRows)
+	}
+}
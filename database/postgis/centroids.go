@@ -0,0 +1,100 @@
+package postgis
+
+import (
+	"errors"
+	"fmt"
+)
+
+// CreateCentroidTable creates a <table>_centroid table with a point for
+// each row of the table, e.g. for label placement. The points are
+// calculated with ST_PointOnSurface and not with ST_Centroid, as the
+// centroid of concave polygons and of lines can be outside of the
+// geometry. The table has the id and geometry column of the source table
+// and it is deployed with the source table.
+
+const centroidTableSuffix = "_centroid"
+
+// CentroidTableName returns the name of the table with the centroids.
+func (spec *TableSpec) CentroidTableName() string {
+	return spec.FullName + centroidTableSuffix
+}
+
+// centroidColumns returns the id and geometry column of the table.
+func (spec *TableSpec) centroidColumns() (id, geometry *ColumnSpec, err error) {
+	if !spec.hasGeometry() {
+		return nil, nil, fmt.Errorf("table %s has no geometry column", spec.Name)
+	}
+	idIdx := spec.idColumnIndex()
+	if idIdx < 0 {
+		return nil, nil, fmt.Errorf("table %s has no id column", spec.Name)
+	}
+	return &spec.Columns[idIdx], &spec.Columns[spec.geometryColumnIndex()], nil
+}
+
+func (spec *TableSpec) CreateCentroidTableSQL() string {
+	id, _, _ := spec.centroidColumns()
+	return fmt.Sprintf(`CREATE TABLE "%s"."%s" ("%s" %s)`,
+		spec.Schema, spec.CentroidTableName(), id.Name, id.Type.Name())
+}
+
+func (spec *TableSpec) InsertCentroidsSQL() string {
+	id, geometry, _ := spec.centroidColumns()
+	where := fmt.Sprintf(`"%s" IS NOT NULL`, geometry.Name)
+	if spec.SoftDelete {
+		where += " AND " + notDeletedSQL
+	}
+	return fmt.Sprintf(`INSERT INTO "%s"."%s" ("%s", "%s") SELECT "%s", ST_PointOnSurface("%s") FROM "%s"."%s" WHERE %s`,
+		spec.Schema, spec.CentroidTableName(), id.Name, geometry.Name,
+		id.Name, geometry.Name, spec.Schema, spec.FullName, where)
+}
+
+// CreateCentroidTable (re)creates and fills the centroid table of the
+// table (by name, without prefix).
+func (pg *PostGIS) CreateCentroidTable(table string) error {
+	spec, ok := pg.Tables[table]
+	if !ok {
+		return errors.New("unknown table " + table)
+	}
+	_, geometry, err := spec.centroidColumns()
+	if err != nil {
+		return err
+	}
+	name := spec.CentroidTableName()
+	defer log.StopStep(log.StartStep(fmt.Sprintf("Creating centroids of %s in %s", spec.FullName, name)))
+
+	tx, err := pg.Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer rollbackIfTx(&tx)
+
+	if err := dropTableIfExists(tx, spec.Schema, name); err != nil {
+		return err
+	}
+	sql := spec.CreateCentroidTableSQL()
+	if _, err := tx.Exec(sql); err != nil {
+		return &SQLError{sql, err}
+	}
+	sql = fmt.Sprintf("SELECT AddGeometryColumn('%s', '%s', '%s', '%d', 'POINT', 2);",
+		spec.Schema, name, geometry.Name, spec.Srid)
+	var void interface{}
+	if err := tx.QueryRow(sql).Scan(&void); err != nil {
+		return &SQLError{sql, err}
+	}
+	for _, sql := range []string{
+		spec.InsertCentroidsSQL(),
+		geometryIndexSQL(spec.dialect(), spec.indexNames(), spec.Schema, name, geometry.Name),
+	} {
+		if _, err := tx.Exec(sql); err != nil {
+			return &SQLError{sql, err}
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+	tx = nil // set nil to prevent rollback
+	spec.centroids = true
+	return nil
+}
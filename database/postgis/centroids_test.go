@@ -0,0 +1,93 @@
+package postgis
+
+import (
+	"database/sql/driver"
+	"testing"
+
+	"github.com/omniscale/imposm3/mapping"
+)
+
+func TestInsertCentroidsSQL(t *testing.T) {
+	spec := testTableSpec(t, testPostGIS(), testTable())
+
+	expected := `CREATE TABLE "import"."osm_roads_centroid" ("osm_id" BIGINT)`
+	if sql := spec.CreateCentroidTableSQL(); sql != expected {
+		t.Errorf("unexpected SQL\n%s\n%s", sql, expected)
+	}
+	expected = `INSERT INTO "import"."osm_roads_centroid" ("osm_id", "geometry") SELECT "osm_id", ST_PointOnSurface("geometry") FROM "import"."osm_roads" WHERE "geometry" IS NOT NULL`
+	if sql := spec.InsertCentroidsSQL(); sql != expected {
+		t.Errorf("unexpected SQL\n%s\n%s", sql, expected)
+	}
+
+	spec.SoftDelete = true
+	expected = `INSERT INTO "import"."osm_roads_centroid" ("osm_id", "geometry") SELECT "osm_id", ST_PointOnSurface("geometry") FROM "import"."osm_roads" WHERE "geometry" IS NOT NULL AND NOT "deleted"`
+	if sql := spec.InsertCentroidsSQL(); sql != expected {
+		t.Errorf("unexpected SQL\n%s\n%s", sql, expected)
+	}
+}
+
+func TestCreateCentroidTable(t *testing.T) {
+	db, d := newFakeDb()
+	defer db.Close()
+	pg := testPostGIS()
+	pg.Db = db
+	spec := testTableSpec(t, pg, testTable())
+	pg.Tables = map[string]*TableSpec{"roads": spec}
+	d.results = map[string]fakeResult{
+		"SELECT EXISTS(SELECT * FROM information_schema.tables": {
+			columns: []string{"exists"},
+			rows:    [][]driver.Value{{true}},
+		},
+		"SELECT DropGeometryTable": {
+			columns: []string{"dropgeometrytable"},
+			rows:    [][]driver.Value{{"ok"}},
+		},
+		"SELECT AddGeometryColumn": {
+			columns: []string{"addgeometrycolumn"},
+			rows:    [][]driver.Value{{"ok"}},
+		},
+	}
+
+	if err := pg.CreateCentroidTable("roads"); err != nil {
+		t.Fatal(err)
+	}
+	for _, sql := range []string{
+		`SELECT DropGeometryTable('import', 'osm_roads_centroid');`,
+		spec.CreateCentroidTableSQL(),
+		`SELECT AddGeometryColumn('import', 'osm_roads_centroid', 'geometry', '3857', 'POINT', 2);`,
+		spec.InsertCentroidsSQL(),
+		`CREATE INDEX "osm_roads_centroid_geom" ON "import"."osm_roads_centroid" USING GIST ("geometry")`,
+	} {
+		if n := d.count(sql); n != 1 {
+			t.Errorf("expected one %s, got %d", sql, n)
+		}
+	}
+	if d.commits != 1 {
+		t.Error("expected commit", d.commits)
+	}
+
+	found := false
+	for _, table := range pg.deployTables() {
+		if table.name == "osm_roads_centroid" {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("centroid table not deployed")
+	}
+}
+
+func TestCreateCentroidTableErrors(t *testing.T) {
+	pg := testPostGIS()
+	table := testTable()
+	table.Type = mapping.NoneTable
+	table.Fields = table.Fields[2:]
+	pg.Tables = map[string]*TableSpec{"roads": testTableSpec(t, pg, table)}
+
+	if err := pg.CreateCentroidTable("unknown"); err == nil {
+		t.Error("expected error for unknown table")
+	}
+	if err := pg.CreateCentroidTable("roads"); err == nil {
+		t.Error("expected error for table without geometry")
+	}
+}
@@ -0,0 +1,146 @@
+package postgis
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// TableMismatchError is returned by CheckTables if existing tables
+// do not match the mapping.
+type TableMismatchError struct {
+	Mismatches []string
+}
+
+func (e *TableMismatchError) Error() string {
+	return fmt.Sprintf("existing tables do not match the mapping:\n\t%s",
+		strings.Join(e.Mismatches, "\n\t"))
+}
+
+// geometryColumnMismatches compares the SRID and type of an existing
+// geometry column (from geometry_columns) with the table spec.
+func geometryColumnMismatches(spec *TableSpec, srid int, geomType string) []string {
+	var mismatches []string
+	if spec.Srid != 0 && srid != spec.Srid {
+		mismatches = append(mismatches, fmt.Sprintf("%s.%s: SRID %d does not match %d of the mapping",
+			spec.Schema, spec.FullName, srid, spec.Srid))
+	}
+	if !strings.EqualFold(geomType, spec.geometryColumnType()) {
+		mismatches = append(mismatches, fmt.Sprintf("%s.%s: geometry type %s does not match %s of the mapping",
+			spec.Schema, spec.FullName, strings.ToUpper(geomType), spec.geometryColumnType()))
+	}
+	return mismatches
+}
+
+// existingGeometryColumn returns the SRID and type of the geometry column
+// from geometry_columns. found is false if the column is not registered.
+func existingGeometryColumn(db queryer, spec *TableSpec) (srid int, geomType string, found bool, err error) {
+	idx := spec.geometryColumnIndex()
+	if idx < 0 {
+		return 0, "", false, nil
+	}
+	query := fmt.Sprintf(`SELECT srid, type FROM geometry_columns WHERE f_table_schema = '%s' AND f_table_name = '%s' AND f_geometry_column = '%s'`,
+		spec.Schema, spec.FullName, spec.Columns[idx].Name)
+	err = db.QueryRow(query).Scan(&srid, &geomType)
+	if err == sql.ErrNoRows {
+		return 0, "", false, nil
+	}
+	if err != nil {
+		return 0, "", false, &SQLError{query, err}
+	}
+	return srid, geomType, true, nil
+}
+
+// existingColumns returns the names of all columns of the existing table
+// in their order, or nil if the table does not exist.
+func existingColumns(db *sql.DB, spec *TableSpec) ([]string, error) {
+	query := fmt.Sprintf(`SELECT column_name FROM information_schema.columns WHERE table_schema = '%s' AND table_name = '%s' ORDER BY ordinal_position`,
+		spec.Schema, spec.FullName)
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, &SQLError{query, err}
+	}
+	defer rows.Close()
+	var columns []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, &SQLError{query, err}
+		}
+		columns = append(columns, name)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, &SQLError{query, err}
+	}
+	return columns, nil
+}
+
+// columnMismatches compares the columns of an existing table with the
+// table spec. Columns of the mapping that are missing in the table are
+// mismatches. Columns in a different order (e.g. after fields of the
+// mapping were reordered) are no mismatch, as INSERT and COPY name all
+// columns and rows are inserted into the right columns. reordered is
+// true for these tables.
+func columnMismatches(spec *TableSpec, existing []string) (mismatches []string, reordered bool) {
+	positions := make(map[string]int, len(existing))
+	for i, name := range existing {
+		positions[name] = i
+	}
+	last := -1
+	for _, col := range spec.Columns {
+		pos, ok := positions[col.Name]
+		if !ok {
+			mismatches = append(mismatches, fmt.Sprintf("%s.%s: column %s of the mapping is missing",
+				spec.Schema, spec.FullName, col.Name))
+			continue
+		}
+		if pos < last {
+			reordered = true
+		}
+		last = pos
+	}
+	return mismatches, reordered
+}
+
+// CheckTables compares the columns, SRID and geometry type of all
+// existing tables with the mapping. It returns a *TableMismatchError with
+// all mismatches, before any data is appended to tables with missing
+// columns or incompatible geometries. Tables with columns in a different
+// order and tables that are not registered in geometry_columns are only
+// logged.
+func (pg *PostGIS) CheckTables() error {
+	var mismatches []string
+	for _, spec := range pg.Tables {
+		existing, err := existingColumns(pg.Db, spec)
+		if err != nil {
+			return err
+		}
+		if len(existing) > 0 {
+			columns, reordered := columnMismatches(spec, existing)
+			mismatches = append(mismatches, columns...)
+			if reordered {
+				log.Printf("columns of %s.%s are in a different order than in the mapping, rows are inserted by column name",
+					spec.Schema, spec.FullName)
+			}
+		}
+		if spec.geometryColumnIndex() < 0 {
+			continue
+		}
+		srid, geomType, found, err := existingGeometryColumn(pg.Db, spec)
+		if err != nil {
+			return err
+		}
+		if !found {
+			log.Warnf("unable to check geometry column of %s.%s: not found in geometry_columns",
+				spec.Schema, spec.FullName)
+			continue
+		}
+		mismatches = append(mismatches, geometryColumnMismatches(spec, srid, geomType)...)
+	}
+	if len(mismatches) > 0 {
+		sort.Strings(mismatches)
+		return &TableMismatchError{mismatches}
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package postgis
+
+import (
+	"database/sql/driver"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/omniscale/imposm3/mapping"
+)
+
+func TestGeometryColumnMismatches(t *testing.T) {
+	spec := testTableSpec(t, testPostGIS(), testTable())
+	if m := geometryColumnMismatches(spec, 3857, "LINESTRING"); len(m) != 0 {
+		t.Error("unexpected mismatches", m)
+	}
+	if m := geometryColumnMismatches(spec, 3857, "linestring"); len(m) != 0 {
+		t.Error("unexpected mismatches", m)
+	}
+
+	table := testTable()
+	table.Type = mapping.PolygonTable
+	spec = testTableSpec(t, testPostGIS(), table)
+	if m := geometryColumnMismatches(spec, 3857, "GEOMETRY"); len(m) != 0 {
+		t.Error("unexpected mismatches for polygon table", m)
+	}
+}
+
+func TestGeometryColumnMismatchesSrid(t *testing.T) {
+	spec := testTableSpec(t, testPostGIS(), testTable())
+	m := geometryColumnMismatches(spec, 4326, "LINESTRING")
+	if len(m) != 1 {
+		t.Fatal("expected one mismatch", m)
+	}
+	if m[0] != "import.osm_roads: SRID 4326 does not match 3857 of the mapping" {
+		t.Error("unexpected mismatch", m[0])
+	}
+}
+
+func TestGeometryColumnMismatchesType(t *testing.T) {
+	spec := testTableSpec(t, testPostGIS(), testTable())
+	m := geometryColumnMismatches(spec, 3857, "POINT")
+	if len(m) != 1 {
+		t.Fatal("expected one mismatch", m)
+	}
+	if m[0] != "import.osm_roads: geometry type POINT does not match LINESTRING of the mapping" {
+		t.Error("unexpected mismatch", m[0])
+	}
+
+	err := &TableMismatchError{append(m, geometryColumnMismatches(spec, 4326, "LINESTRING")...)}
+	if !strings.Contains(err.Error(), "POINT") || !strings.Contains(err.Error(), "4326") {
+		t.Error("mismatches missing in error", err)
+	}
+}
+
+func TestColumnMismatches(t *testing.T) {
+	spec := testTableSpec(t, testPostGIS(), testTable())
+	m, reordered := columnMismatches(spec, []string{"id", "osm_id", "geometry", "name", "tags"})
+	if len(m) != 0 || reordered {
+		t.Error("unexpected mismatches", m, reordered)
+	}
+	m, reordered = columnMismatches(spec, []string{"id", "osm_id", "tags", "name", "geometry", "extra"})
+	if len(m) != 0 || !reordered {
+		t.Error("unexpected mismatches", m, reordered)
+	}
+	m, _ = columnMismatches(spec, []string{"id", "osm_id", "geometry", "tags"})
+	if !reflect.DeepEqual(m, []string{"import.osm_roads: column name of the mapping is missing"}) {
+		t.Error("unexpected mismatches", m)
+	}
+}
+
+func TestCheckTablesReordered(t *testing.T) {
+	db, d := newFakeDb()
+	defer db.Close()
+	pg := testPostGIS()
+	pg.Db = db
+	pg.Tables = map[string]*TableSpec{"roads": testTableSpec(t, pg, testTable())}
+	d.results = map[string]fakeResult{
+		"SELECT srid, type FROM geometry_columns": {
+			columns: []string{"srid", "type"},
+			rows:    [][]driver.Value{{int64(3857), "LINESTRING"}},
+		},
+	}
+
+	// existing table with reordered columns
+	d.results["SELECT column_name FROM information_schema.columns"] = fakeResult{
+		columns: []string{"column_name"},
+		rows:    [][]driver.Value{{"id"}, {"tags"}, {"name"}, {"osm_id"}, {"geometry"}},
+	}
+	if err := pg.CheckTables(); err != nil {
+		t.Fatal(err)
+	}
+	// rows are inserted by name
+	if sql := pg.Tables["roads"].InsertSQL(); !strings.Contains(sql, `("osm_id", "geometry", "name", "tags")`) {
+		t.Error("unexpected sql", sql)
+	}
+
+	d.results["SELECT column_name FROM information_schema.columns"] = fakeResult{
+		columns: []string{"column_name"},
+		rows:    [][]driver.Value{{"id"}, {"tags"}, {"osm_id"}, {"geometry"}},
+	}
+	err := pg.CheckTables()
+	if e, ok := err.(*TableMismatchError); !ok || len(e.Mismatches) != 1 || !strings.Contains(e.Mismatches[0], "column name") {
+		t.Error("unexpected error", err)
+	}
+}
@@ -0,0 +1,189 @@
+package postgis
+
+import (
+	"fmt"
+	"runtime"
+	"time"
+
+	pq "github.com/lib/pq"
+)
+
+// Tables with DeferConstraintValidation are created without their CHECK
+// constraints, so that PostgreSQL does not check each row of the load.
+// Finish adds the constraints with NOT VALID (NOT VALID constraints are
+// still checked for new rows, so they can't be added before the load)
+// and validates them with VALIDATE CONSTRAINT, in parallel for all
+// tables. Rows that violate a constraint are not rejected during the
+// load, Finish reports them with a sample of their ids instead.
+
+// constraintSampleSize is the max number of ids of violating rows of a
+// ConstraintViolationError.
+const constraintSampleSize = 10
+
+// checkConstraint is a CHECK constraint of a table.
+type checkConstraint struct {
+	name string
+	expr string
+}
+
+// checkConstraints returns the CHECK constraints of the table.
+func (spec *TableSpec) checkConstraints() []checkConstraint {
+	var constraints []checkConstraint
+	if spec.GeometryCheck == geometryCheckImmediate {
+		constraints = append(constraints, checkConstraint{
+			spec.geometryCheckName(),
+			fmt.Sprintf(`ST_IsValid("%s")`, spec.Columns[spec.geometryColumnIndex()].Name),
+		})
+	}
+	return constraints
+}
+
+// addSQL returns the statement that adds the constraint to the table of
+// spec, optionally without checking the existing rows.
+func (c checkConstraint) addSQL(spec *TableSpec, notValid bool) string {
+	sql := fmt.Sprintf(`ALTER TABLE "%s"."%s" ADD CONSTRAINT "%s" CHECK (%s)`,
+		spec.Schema, spec.FullName, c.name, c.expr)
+	if notValid {
+		sql += " NOT VALID"
+	}
+	return sql
+}
+
+func (c checkConstraint) validateSQL(spec *TableSpec) string {
+	return fmt.Sprintf(`ALTER TABLE "%s"."%s" VALIDATE CONSTRAINT "%s"`,
+		spec.Schema, spec.FullName, c.name)
+}
+
+// violationsSQL returns the query for the ids of the violating rows and
+// the number of all violating rows, like checkReferencesSQL. Tables
+// without id column return the ctid of the rows.
+func (c checkConstraint) violationsSQL(spec *TableSpec, limit int) string {
+	id := "ctid"
+	if idx := spec.idColumnIndex(); idx >= 0 {
+		id = fmt.Sprintf(`"%s"`, spec.Columns[idx].Name)
+	}
+	return fmt.Sprintf(`SELECT %s::text, count(*) OVER () FROM "%s"."%s" WHERE NOT (%s) LIMIT %d`,
+		id, spec.Schema, spec.FullName, c.expr, limit)
+}
+
+// ConstraintViolationError is returned by Finish for rows that violate a
+// constraint of a table with DeferConstraintValidation.
+type ConstraintViolationError struct {
+	Table      string
+	Constraint string
+	// Violations is the number of violating rows.
+	Violations int64
+	// Sample are the ids of up to 10 violating rows.
+	Sample []string
+}
+
+func (e *ConstraintViolationError) Error() string {
+	return fmt.Sprintf("%d rows of %s violate constraint %s (e.g. %v)",
+		e.Violations, e.Table, e.Constraint, e.Sample)
+}
+
+// isCheckViolation returns whether err is a check_violation.
+func isCheckViolation(err error) bool {
+	pqErr, ok := err.(*pq.Error)
+	return ok && pqErr.Code == "23514"
+}
+
+// validateConstraints adds and validates the constraints of all tables
+// with DeferConstraintValidation.
+func (pg *PostGIS) validateConstraints() error {
+	worker := int(runtime.GOMAXPROCS(0))
+	if worker < 1 {
+		worker = 1
+	}
+	p := newWorkerPool(worker, len(pg.Tables))
+	for _, tbl := range pg.Tables {
+		table := tbl
+		if !table.DeferConstraintValidation || len(table.checkConstraints()) == 0 {
+			continue
+		}
+		p.in <- func() error {
+			for _, c := range table.checkConstraints() {
+				if err := pg.validateConstraint(table, c); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+	return p.wait()
+}
+
+// validateConstraint adds the constraint with NOT VALID and validates
+// it. Violations are logged as warnings with WarnConstraintViolations,
+// the constraint stays NOT VALID.
+func (pg *PostGIS) validateConstraint(spec *TableSpec, c checkConstraint) error {
+	sql := c.addSQL(spec, true)
+	if _, err := pg.Db.Exec(sql); err != nil {
+		return &SQLError{sql, err}
+	}
+	start := time.Now()
+	sql = c.validateSQL(spec)
+	_, err := pg.Db.Exec(sql)
+	pg.addConstraintDuration(spec.FullName+"."+c.name, time.Since(start))
+	if err == nil {
+		log.Printf("validated constraint %s of %s in %s", c.name, spec.FullName, time.Since(start))
+		return nil
+	}
+	if !isCheckViolation(err) {
+		return &SQLError{sql, err}
+	}
+	violations, err := pg.constraintViolations(spec, c)
+	if err != nil {
+		return err
+	}
+	if pg.Config.WarnConstraintViolations {
+		log.Warnf("%s, constraint stays NOT VALID", violations)
+		return nil
+	}
+	return violations
+}
+
+// constraintViolations queries the violating rows of the constraint.
+func (pg *PostGIS) constraintViolations(spec *TableSpec, c checkConstraint) (*ConstraintViolationError, error) {
+	sql := c.violationsSQL(spec, constraintSampleSize)
+	rows, err := pg.Db.Query(sql)
+	if err != nil {
+		return nil, &SQLError{sql, err}
+	}
+	defer rows.Close()
+
+	violations := &ConstraintViolationError{Table: spec.FullName, Constraint: c.name}
+	for rows.Next() {
+		var id nullString
+		if err := rows.Scan(&id, &violations.Violations); err != nil {
+			return nil, &SQLError{sql, err}
+		}
+		violations.Sample = append(violations.Sample, string(id))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, &SQLError{sql, err}
+	}
+	return violations, nil
+}
+
+func (pg *PostGIS) addConstraintDuration(constraint string, d time.Duration) {
+	pg.constraintMu.Lock()
+	defer pg.constraintMu.Unlock()
+	if pg.constraintDurations == nil {
+		pg.constraintDurations = make(map[string]time.Duration)
+	}
+	pg.constraintDurations[constraint] = d
+}
+
+// ConstraintDurations returns the duration of the validation of each
+// constraint (as table.constraint) of Finish, also of failed
+// validations.
+func (pg *PostGIS) ConstraintDurations() map[string]time.Duration {
+	pg.constraintMu.Lock()
+	defer pg.constraintMu.Unlock()
+	durations := make(map[string]time.Duration, len(pg.constraintDurations))
+	for constraint, d := range pg.constraintDurations {
+		durations[constraint] = d
+	}
+	return durations
+}
@@ -0,0 +1,93 @@
+package postgis
+
+import (
+	"database/sql/driver"
+	"reflect"
+	"testing"
+
+	pq "github.com/lib/pq"
+)
+
+func testConstraintsPostGIS(t *testing.T) *PostGIS {
+	pg := testPostGIS()
+	pg.Config.DeferConstraintValidation = true
+	table := testTable()
+	table.GeometryCheck = geometryCheckImmediate
+	pg.Tables = map[string]*TableSpec{"roads": testTableSpec(t, pg, table)}
+	return pg
+}
+
+func TestDeferredCheckConstraintSQL(t *testing.T) {
+	pg := testConstraintsPostGIS(t)
+	spec := pg.Tables["roads"]
+	if sqls := spec.GeometryCheckSQL(); len(sqls) != 0 {
+		t.Error("constraint not deferred", sqls)
+	}
+	c := spec.checkConstraints()[0]
+	expected := `ALTER TABLE "import"."osm_roads" ADD CONSTRAINT "osm_roads_geometry_valid" CHECK (ST_IsValid("geometry")) NOT VALID`
+	if sql := c.addSQL(spec, true); sql != expected {
+		t.Errorf("unexpected SQL\n%s\n%s", sql, expected)
+	}
+	expected = `ALTER TABLE "import"."osm_roads" VALIDATE CONSTRAINT "osm_roads_geometry_valid"`
+	if sql := c.validateSQL(spec); sql != expected {
+		t.Errorf("unexpected SQL\n%s\n%s", sql, expected)
+	}
+	expected = `SELECT "osm_id"::text, count(*) OVER () FROM "import"."osm_roads" WHERE NOT (ST_IsValid("geometry")) LIMIT 10`
+	if sql := c.violationsSQL(spec, 10); sql != expected {
+		t.Errorf("unexpected SQL\n%s\n%s", sql, expected)
+	}
+}
+
+func TestValidateConstraints(t *testing.T) {
+	db, d := newFakeDb()
+	defer db.Close()
+	pg := testConstraintsPostGIS(t)
+	pg.Db = db
+	if err := pg.validateConstraints(); err != nil {
+		t.Fatal(err)
+	}
+	if n := d.count(`ALTER TABLE "import"."osm_roads" ADD CONSTRAINT`); n != 1 {
+		t.Error("unexpected ADD CONSTRAINT", n)
+	}
+	if n := d.count(`ALTER TABLE "import"."osm_roads" VALIDATE CONSTRAINT`); n != 1 {
+		t.Error("unexpected VALIDATE CONSTRAINT", n)
+	}
+	if _, ok := pg.ConstraintDurations()["osm_roads.osm_roads_geometry_valid"]; !ok {
+		t.Error("missing duration", pg.ConstraintDurations())
+	}
+}
+
+func TestValidateConstraintsViolations(t *testing.T) {
+	db, d := newFakeDb()
+	defer db.Close()
+	pg := testConstraintsPostGIS(t)
+	pg.Db = db
+	d.fail = `ALTER TABLE "import"."osm_roads" VALIDATE`
+	d.failErr = &pq.Error{Code: "23514"}
+	d.results = map[string]fakeResult{
+		"SELECT": {
+			columns: []string{"osm_id", "count"},
+			rows:    [][]driver.Value{{"12", int64(42)}, {"-7", int64(42)}},
+		},
+	}
+	err := pg.validateConstraints()
+	violations, ok := err.(*ConstraintViolationError)
+	if !ok {
+		t.Fatal("expected ConstraintViolationError", err)
+	}
+	expected := &ConstraintViolationError{"osm_roads", "osm_roads_geometry_valid", 42, []string{"12", "-7"}}
+	if !reflect.DeepEqual(violations, expected) {
+		t.Errorf("unexpected error %#v", violations)
+	}
+
+	pg.Config.WarnConstraintViolations = true
+	if err := pg.validateConstraints(); err != nil {
+		t.Error("unexpected error", err)
+	}
+
+	// other errors fail regardless of WarnConstraintViolations
+	d.failErr = nil
+	if err := pg.validateConstraints(); err == nil {
+		t.Error("expected error")
+	}
+}
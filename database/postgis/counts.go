@@ -0,0 +1,236 @@
+package postgis
+
+import (
+	"sync/atomic"
+)
+
+// RowCounts are the number of rows that were inserted into a table or
+// that were skipped.
+type RowCounts struct {
+	Inserted int64
+	// SkippedFilter are rows dropped by filters (e.g. dedup).
+	SkippedFilter int64
+	// SkippedNullGeometry are rows without geometry.
+	SkippedNullGeometry int64
+	// NullGeometry are rows without geometry that were inserted with a
+	// NULL geometry (allow_null_geometry).
+	NullGeometry int64
+	// SkippedMissingRequired are rows with NULL in a not_null column
+	// (see Config.SkipRowsMissingRequired).
+	SkippedMissingRequired int64
+	// Failed are rows that were rejected (e.g. exceeding max_vertices).
+	Failed int64
+	// NormalizedValues are string values that were changed by the
+	// normalization.
+	NormalizedValues int64
+}
+
+// rowCounter counts the rows of a table across all transactions. All
+// methods can be called concurrently.
+type rowCounter struct {
+	counts RowCounts
+}
+
+func (c *rowCounter) inserted() { atomic.AddInt64(&c.counts.Inserted, 1) }
+
+func (c *rowCounter) skippedFilter() { atomic.AddInt64(&c.counts.SkippedFilter, 1) }
+
+func (c *rowCounter) skippedNullGeometry() { atomic.AddInt64(&c.counts.SkippedNullGeometry, 1) }
+
+func (c *rowCounter) nullGeometry() { atomic.AddInt64(&c.counts.NullGeometry, 1) }
+
+func (c *rowCounter) skippedMissingRequired() { atomic.AddInt64(&c.counts.SkippedMissingRequired, 1) }
+
+func (c *rowCounter) failed() { atomic.AddInt64(&c.counts.Failed, 1) }
+
+func (c *rowCounter) normalized() { atomic.AddInt64(&c.counts.NormalizedValues, 1) }
+
+func (c *rowCounter) load() RowCounts {
+	return RowCounts{
+		Inserted:               atomic.LoadInt64(&c.counts.Inserted),
+		SkippedFilter:          atomic.LoadInt64(&c.counts.SkippedFilter),
+		SkippedNullGeometry:    atomic.LoadInt64(&c.counts.SkippedNullGeometry),
+		NullGeometry:           atomic.LoadInt64(&c.counts.NullGeometry),
+		SkippedMissingRequired: atomic.LoadInt64(&c.counts.SkippedMissingRequired),
+		Failed:                 atomic.LoadInt64(&c.counts.Failed),
+		NormalizedValues:       atomic.LoadInt64(&c.counts.NormalizedValues),
+	}
+}
+
+// nullGeometry returns true if the table has a geometry column and the
+// geometry of the row is missing.
+func (spec *TableSpec) nullGeometry(row []interface{}) bool {
+	idx := spec.geometryColumnIndex()
+	if idx < 0 || idx >= len(row) {
+		return false
+	}
+	switch g := row[idx].(type) {
+	case nil:
+		return true
+	case string:
+		return g == ""
+	case []byte:
+		return len(g) == 0
+	}
+	return false
+}
+
+// withNullGeometry returns the row with nil for empty geometries, so that
+// they are inserted as NULL.
+func (spec *TableSpec) withNullGeometry(row []interface{}) []interface{} {
+	idx := spec.geometryColumnIndex()
+	if row[idx] == nil {
+		return row
+	}
+	encoded := make([]interface{}, len(row))
+	copy(encoded, row)
+	encoded[idx] = nil
+	return encoded
+}
+
+// prepareRow applies dedup (see collectRows for keep last), the null
+// geometry check, the SRID detection, the EWKB, UUID, decimal and osm_type encoding, the
+// WKT type check, the normalization of strings, the null policies and
+// max_vertices to the row. It returns nil for skipped rows and an error for rows that need to
+// be rejected. Skipped rows are counted.
+func (spec *TableSpec) prepareRow(row []interface{}) ([]interface{}, error) {
+	if spec.dedup != nil && !spec.dedup.keepLast {
+		if first, dup := spec.dedup.seenRow(row); dup {
+			if spec.dedup.keepError {
+				return nil, &DuplicateRowError{spec.Name, first, row}
+			}
+			spec.rows.skippedFilter()
+			return nil, nil
+		}
+	}
+	return spec.convertRow(row)
+}
+
+// convertRow is prepareRow without dedup, for rows that are retried
+// (see rowErrorAction).
+func (spec *TableSpec) convertRow(row []interface{}) ([]interface{}, error) {
+	if spec.nullGeometry(row) {
+		if !spec.AllowNullGeometry {
+			spec.rows.skippedNullGeometry()
+			return nil, nil
+		}
+		spec.rows.nullGeometry()
+		row = spec.withNullGeometry(row)
+	}
+	if err := spec.detectSrid(row); err != nil {
+		return nil, err
+	}
+	srid, err := spec.checkSrid(row)
+	if err != nil {
+		return nil, err
+	}
+	row, err = spec.encodeEwkb(row, srid)
+	if err != nil {
+		return nil, err
+	}
+	if err := spec.checkWkt(row); err != nil {
+		return nil, err
+	}
+	if err := spec.checkEnums(row); err != nil {
+		return nil, err
+	}
+	row, err = spec.encodeUuids(row)
+	if err != nil {
+		return nil, err
+	}
+	row, err = spec.encodeTstzRanges(row)
+	if err != nil {
+		return nil, err
+	}
+	row, err = spec.encodeDecimals(row)
+	if err != nil {
+		return nil, err
+	}
+	row, err = spec.encodeOsmTypes(row)
+	if err != nil {
+		return nil, err
+	}
+	row = spec.normalizeRow(row)
+	row = spec.applyNullPolicies(row)
+	if spec.missingRequired(row) {
+		spec.rows.skippedMissingRequired()
+		return nil, nil
+	}
+	row, err = spec.limitVertices(row)
+	if err != nil {
+		return nil, err
+	}
+	return spec.encodeGeometryHashes(row)
+}
+
+// RowCounts returns the number of inserted and skipped rows for each
+// table since the database was opened.
+func (pg *PostGIS) RowCounts() map[string]RowCounts {
+	counts := make(map[string]RowCounts)
+	for name, spec := range pg.Tables {
+		counts[name] = spec.rows.load()
+	}
+	return counts
+}
+
+func (pg *PostGIS) logRowCounts() {
+	for name, c := range pg.RowCounts() {
+		if c.SkippedFilter > 0 || c.SkippedNullGeometry > 0 || c.Failed > 0 {
+			log.Printf("inserted %d rows into %s, skipped %d by filter, %d without geometry, %d failed",
+				c.Inserted, name, c.SkippedFilter, c.SkippedNullGeometry, c.Failed)
+		}
+		if c.NullGeometry > 0 {
+			log.Printf("inserted %d rows into %s with NULL geometry", c.NullGeometry, name)
+		}
+		if c.SkippedMissingRequired > 0 {
+			log.Printf("skipped %d rows of %s with missing values in not_null columns", c.SkippedMissingRequired, name)
+		}
+		if c.NormalizedValues > 0 {
+			log.Printf("normalized %d values in %s", c.NormalizedValues, name)
+		}
+	}
+	for name, n := range pg.Analyzes() {
+		if n > 0 {
+			log.Printf("analyzed %s %d times during the import", name, n)
+		}
+	}
+	for name, columns := range pg.NulledValues() {
+		for column, n := range columns {
+			if n > 0 {
+				log.Printf("inserted %d null_values of column %s in %s as NULL", n, column, name)
+			}
+		}
+	}
+}
+
+// NulledValues returns the number of values that matched the null_values
+// of each column, for all tables with null_values.
+func (pg *PostGIS) NulledValues() map[string]map[string]int64 {
+	counts := make(map[string]map[string]int64)
+	for name, spec := range pg.Tables {
+		if len(spec.nullValueFields) == 0 {
+			continue
+		}
+		columns := make(map[string]int64)
+		for _, field := range spec.nullValueFields {
+			columns[field.Name] += field.NulledValues()
+		}
+		counts[name] = columns
+	}
+	return counts
+}
+
+// CopyBufferSettings are the effective COPY buffer limits of a table.
+type CopyBufferSettings struct {
+	Rows  int
+	Bytes int
+}
+
+// CopyBufferSettings returns the COPY buffer limits for each table.
+func (pg *PostGIS) CopyBufferSettings() map[string]CopyBufferSettings {
+	settings := make(map[string]CopyBufferSettings)
+	for name, spec := range pg.Tables {
+		settings[name] = CopyBufferSettings{spec.CopyBufferRows, spec.CopyBufferBytes}
+	}
+	return settings
+}
@@ -0,0 +1,109 @@
+package postgis
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/omniscale/imposm3/mapping"
+)
+
+func TestRowCountsMixedBatch(t *testing.T) {
+	pg := testPostGIS()
+	table := testTable()
+	table.MaxVertices = 2
+	table.Dedup = &mapping.Dedup{}
+	spec := testTableSpec(t, pg, table)
+	pg.Tables = map[string]*TableSpec{"roads": spec}
+	tt := &bulkTableTx{Spec: spec}
+
+	line := ewkbLineString(3857, 0, 0, 1, 1).hex()
+	rows := [][]interface{}{
+		{int64(1), line, "", ""},
+		{int64(1), line, "", ""}, // duplicate
+		{int64(2), line, "", ""},
+		{int64(3), nil, "", ""},
+		{int64(4), "", "", ""},
+		// polygon collapses before it reaches max_vertices
+		{int64(5), ewkbPolygon(3857, circle(10, 10)).hex(), "", ""},
+	}
+	// same steps as the insert loop, without the database
+	for _, row := range rows {
+		prepared, err := spec.prepareRow(row)
+		if err != nil {
+			tt.reject(row, err)
+			continue
+		}
+		if prepared == nil {
+			continue
+		}
+		spec.rows.inserted()
+	}
+
+	expected := RowCounts{Inserted: 2, SkippedFilter: 1, SkippedNullGeometry: 2, Failed: 1}
+	if counts := pg.RowCounts()["roads"]; counts != expected {
+		t.Errorf("unexpected counts %+v", counts)
+	}
+
+	// counts are aggregated across transactions
+	spec.dedup.reset()
+	if _, err := spec.prepareRow(rows[1]); err != nil {
+		t.Fatal(err)
+	}
+	spec.rows.inserted()
+	expected.Inserted = 3
+	if counts := pg.RowCounts()["roads"]; counts != expected {
+		t.Errorf("unexpected counts %+v", counts)
+	}
+}
+
+func TestNullGeometryWithoutGeometryColumn(t *testing.T) {
+	table := testTable()
+	table.Fields = table.Fields[2:]
+	spec := testTableSpec(t, testPostGIS(), table)
+	if spec.nullGeometry([]interface{}{nil, nil}) {
+		t.Error("table without geometry column has null geometry")
+	}
+}
+
+func TestAllowNullGeometry(t *testing.T) {
+	db, d := newFakeDb()
+	defer db.Close()
+	pg := testPostGIS()
+	pg.Db = db
+	table := testTable()
+	table.AllowNullGeometry = true
+	table.MaxVertices = 10
+	spec := testTableSpec(t, pg, table)
+	pg.Tables = map[string]*TableSpec{"roads": spec}
+	if err := pg.Begin(); err != nil {
+		t.Fatal(err)
+	}
+	for i, geom := range []interface{}{nil, "", []byte{}, ewkbLineString(3857, 0, 0, 1, 1).hex()} {
+		if err := pg.txRouter.Insert("roads", []interface{}{int64(i), geom, "", ""}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := pg.txRouter.End(); err != nil {
+		t.Fatal(err)
+	}
+	values := d.values(`INSERT INTO "import"."osm_roads"`, 1)
+	if len(values) != 4 || values[0] != nil || values[1] != nil || values[2] != nil || values[3] == nil {
+		t.Errorf("unexpected geometries %v", values)
+	}
+	expected := RowCounts{Inserted: 4, NullGeometry: 3}
+	if counts := pg.RowCounts()["roads"]; counts != expected {
+		t.Errorf("unexpected counts %+v", counts)
+	}
+
+	gen := &GeneralizedTableSpec{Name: "roads_gen0", FullName: "osm_roads_gen0", Schema: "import", Source: spec}
+	if sql := gen.InsertSQL(); !strings.HasSuffix(sql, `WHERE "osm_id" = $1 AND "geometry" IS NOT NULL)`) {
+		t.Error("unexpected SQL", sql)
+	}
+
+	table = testTable()
+	table.Fields = table.Fields[2:]
+	table.AllowNullGeometry = true
+	if _, err := NewTableSpec(pg, table); err == nil || !strings.Contains(err.Error(), "allow_null_geometry requires geometry column") {
+		t.Error("expected error", err)
+	}
+}
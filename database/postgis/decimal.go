@@ -0,0 +1,125 @@
+package postgis
+
+import (
+	"fmt"
+
+	"github.com/omniscale/imposm3/mapping"
+)
+
+// Columns of type decimal are created as NUMERIC(precision, scale) with
+// the precision and scale from the args of the column. Values are passed
+// as exact decimal strings for INSERT and COPY, without conversion to
+// float. Values with more digits than the precision would fail the
+// insert of the whole batch. They are inserted as NULL, or the row is
+// rejected with overflow: reject.
+
+const (
+	decimalOverflowNull   = "null"
+	decimalOverflowReject = "reject"
+	maxDecimalPrecision   = 1000
+)
+
+type decimalColumnType struct {
+	simpleColumnType
+	precision int
+	scale     int
+	overflow  string
+}
+
+// newDecimalColumnType returns the column type for the args of the
+// field.
+func newDecimalColumnType(field *mapping.Field) (*decimalColumnType, error) {
+	precision, ok := intArg(field.Args["precision"])
+	if !ok {
+		return nil, fmt.Errorf("decimal column %s requires precision", field.Name)
+	}
+	scale, ok := intArg(field.Args["scale"])
+	if !ok {
+		return nil, fmt.Errorf("decimal column %s requires scale", field.Name)
+	}
+	if scale < 1 || scale > precision || precision > maxDecimalPrecision {
+		return nil, fmt.Errorf("decimal column %s requires 1 <= scale <= precision <= %d, got precision %d and scale %d",
+			field.Name, maxDecimalPrecision, precision, scale)
+	}
+	overflow := decimalOverflowNull
+	if v, ok := field.Args["overflow"]; ok {
+		overflow, _ = v.(string)
+		if overflow != decimalOverflowNull && overflow != decimalOverflowReject {
+			return nil, fmt.Errorf("unknown overflow '%v' of decimal column %s", v, field.Name)
+		}
+	}
+	return &decimalColumnType{
+		simpleColumnType: simpleColumnType{fmt.Sprintf("NUMERIC(%d,%d)", precision, scale)},
+		precision:        precision,
+		scale:            scale,
+		overflow:         overflow,
+	}, nil
+}
+
+// intArg returns the integer value of an arg, from YAML (int) or
+// JSON (float64) mappings.
+func intArg(v interface{}) (int, bool) {
+	switch v := v.(type) {
+	case int:
+		return v, true
+	case float64:
+		if v == float64(int(v)) {
+			return int(v), true
+		}
+	}
+	return 0, false
+}
+
+// encode returns the value as decimal string, rounded to the scale.
+// overflow is true if the value has more digits than the precision.
+func (t *decimalColumnType) encode(val interface{}) (s string, overflow bool, err error) {
+	d, err := mapping.CanonicalDecimal(val)
+	if err != nil {
+		return "", false, err
+	}
+	d = d.Round(t.scale)
+	if d.IntDigits() > t.precision-t.scale {
+		return "", true, nil
+	}
+	return d.String(), false, nil
+}
+
+// encodeDecimals returns the row with all values of decimal columns as
+// exact decimal strings. It returns an error for invalid values and for
+// overflows with overflow: reject, before PostgreSQL aborts the
+// transaction.
+func (spec *TableSpec) encodeDecimals(row []interface{}) ([]interface{}, error) {
+	encoded := row
+	copied := false
+	for i, col := range spec.Columns {
+		if i >= len(row) || row[i] == nil {
+			continue
+		}
+		t, ok := col.Type.(*decimalColumnType)
+		if !ok {
+			continue
+		}
+		var v interface{}
+		s, overflow, err := t.encode(row[i])
+		if err != nil {
+			return nil, fmt.Errorf("column %s of %s: %s", col.Name, spec.Name, err)
+		}
+		if overflow {
+			if t.overflow == decimalOverflowReject {
+				return nil, fmt.Errorf("column %s of %s: value %v exceeds %s", col.Name, spec.Name, row[i], t.Name())
+			}
+		} else {
+			v = s
+		}
+		if str, ok := row[i].(string); ok && v != nil && str == s {
+			continue
+		}
+		if !copied {
+			encoded = make([]interface{}, len(row))
+			copy(encoded, row)
+			copied = true
+		}
+		encoded[i] = v
+	}
+	return encoded, nil
+}
@@ -0,0 +1,149 @@
+package postgis
+
+import (
+	"database/sql/driver"
+	"strings"
+	"testing"
+
+	"github.com/omniscale/imposm3/mapping"
+)
+
+func decimalTable(args map[string]interface{}) *mapping.Table {
+	table := testTable()
+	table.Fields = append(table.Fields, &mapping.Field{Name: "fee", Key: "fee", Type: "decimal", Args: args})
+	return table
+}
+
+func TestDecimalSQL(t *testing.T) {
+	spec := testTableSpec(t, testPostGIS(), decimalTable(map[string]interface{}{"precision": 20, "scale": 2}))
+	if sql := spec.CreateTableSQL(); !strings.Contains(sql, `"fee" NUMERIC(20,2)`) {
+		t.Error("unexpected sql", sql)
+	}
+
+	// args from JSON mappings
+	spec = testTableSpec(t, testPostGIS(), decimalTable(map[string]interface{}{"precision": float64(1000), "scale": float64(1000)}))
+	if sql := spec.CreateTableSQL(); !strings.Contains(sql, `"fee" NUMERIC(1000,1000)`) {
+		t.Error("unexpected sql", sql)
+	}
+}
+
+func TestDecimalArgs(t *testing.T) {
+	for _, test := range []struct {
+		args    map[string]interface{}
+		problem string
+	}{
+		{map[string]interface{}{"scale": 2}, "decimal column fee requires precision"},
+		{map[string]interface{}{"precision": 10}, "decimal column fee requires scale"},
+		{map[string]interface{}{"precision": 10.5, "scale": 2}, "decimal column fee requires precision"},
+		{map[string]interface{}{"precision": 10, "scale": 0}, "requires 1 <= scale <= precision <= 1000"},
+		{map[string]interface{}{"precision": 10, "scale": 11}, "requires 1 <= scale <= precision <= 1000"},
+		{map[string]interface{}{"precision": 1001, "scale": 2}, "requires 1 <= scale <= precision <= 1000"},
+		{map[string]interface{}{"precision": 10, "scale": 2, "overflow": "clip"}, "unknown overflow 'clip' of decimal column fee"},
+	} {
+		_, err := NewTableSpec(testPostGIS(), decimalTable(test.args))
+		if err == nil || !strings.Contains(err.Error(), test.problem) {
+			t.Errorf("unexpected error for %v: %v", test.args, err)
+		}
+	}
+}
+
+func TestEncodeDecimals(t *testing.T) {
+	spec := testTableSpec(t, testPostGIS(), decimalTable(map[string]interface{}{"precision": 20, "scale": 2}))
+
+	for _, test := range []struct {
+		value    interface{}
+		expected interface{}
+	}{
+		// not exact as float64 (12345678901234568)
+		{"12345678901234567.89", "12345678901234567.89"},
+		{"-12345678901234567.89", "-12345678901234567.89"},
+		{[]byte("0.10"), "0.1"},
+		{"0.105", "0.11"},
+		{"-0.105", "-0.11"},
+		{"9.995", "10"},
+		{"-0.001", "0"},
+		{int64(42), "42"},
+		{0.1, "0.1"},
+		// overflows after rounding
+		{"999999999999999999.995", nil},
+		{"1000000000000000000", nil},
+		{nil, nil},
+	} {
+		row, err := spec.encodeDecimals([]interface{}{int64(1), "", "", "", test.value})
+		if err != nil {
+			t.Errorf("unexpected error for %#v: %s", test.value, err)
+			continue
+		}
+		if row[4] != test.expected {
+			t.Errorf("unexpected value for %#v: %#v", test.value, row[4])
+		}
+	}
+
+	row := []interface{}{int64(1), "", "", "", "12.5"}
+	if encoded, err := spec.encodeDecimals(row); err != nil || &encoded[0] != &row[0] {
+		t.Error("exact row copied", err)
+	}
+	for _, value := range []interface{}{"1e5", "12,5", "", "-", true} {
+		if _, err := spec.encodeDecimals([]interface{}{int64(1), "", "", "", value}); err == nil {
+			t.Errorf("expected error for %#v", value)
+		}
+	}
+
+	reject := testTableSpec(t, testPostGIS(), decimalTable(map[string]interface{}{"precision": 4, "scale": 2, "overflow": "reject"}))
+	if row, err := reject.encodeDecimals([]interface{}{int64(1), "", "", "", "99.99"}); err != nil || row[4] != "99.99" {
+		t.Error("unexpected value", row, err)
+	}
+	_, err := reject.encodeDecimals([]interface{}{int64(1), "", "", "", "99.995"})
+	if err == nil || !strings.Contains(err.Error(), "value 99.995 exceeds NUMERIC(4,2)") {
+		t.Error("unexpected error", err)
+	}
+
+	args := rejectedRow{[]interface{}{int64(1), "", "", "", "99.995"}, "error", ""}.invalidArgs(reject)
+	if args[4] != nil {
+		t.Error("overflow for invalid table", args[4])
+	}
+}
+
+func TestDecimalInsert(t *testing.T) {
+	line := ewkbLineString(3857, 0, 0, 1, 1).hex()
+	for _, method := range []string{LoadMethodCopy, LoadMethodInsert} {
+		db, d := newFakeDb()
+		pg := testPostGIS()
+		pg.Db = db
+		table := decimalTable(map[string]interface{}{"precision": 30, "scale": 10, "overflow": "reject"})
+		table.LoadMethod = method
+		spec := testTableSpec(t, pg, table)
+		pg.Tables = map[string]*TableSpec{"roads": spec}
+
+		tt := NewBulkTableTx(pg, spec)
+		if err := tt.Begin(nil); err != nil {
+			t.Fatal(err)
+		}
+		tt.Insert([]interface{}{int64(1), line, "", "", "12345678901234567.0123456789"})
+		tt.Insert([]interface{}{int64(2), line, "", "", "0.00000000005"})
+		// overflow is rejected
+		tt.Insert([]interface{}{int64(3), line, "", "", "123456789012345678901"})
+		if err := tt.Commit(); err != nil {
+			t.Fatal(err)
+		}
+		db.Close()
+
+		stmt := "COPY"
+		if method == LoadMethodInsert {
+			stmt = "INSERT"
+		}
+		expected := []driver.Value{"12345678901234567.0123456789", "0.0000000001"}
+		fees := d.values(stmt, 4)
+		if len(fees) != len(expected) {
+			t.Fatal("unexpected rows", method, fees)
+		}
+		for i := range expected {
+			if fees[i] != expected[i] {
+				t.Error("unexpected value", method, fees[i])
+			}
+		}
+		if n := pg.RowCounts()["roads"].Failed; n != 1 {
+			t.Error("unexpected failed rows", method, n)
+		}
+	}
+}
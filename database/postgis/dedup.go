@@ -0,0 +1,301 @@
+package postgis
+
+import (
+	"container/list"
+	"fmt"
+	"hash/fnv"
+	"sync"
+
+	"github.com/omniscale/imposm3/mapping"
+)
+
+const defaultDedupSize = 100000
+
+type dedupKey struct {
+	id   int64
+	hash uint64
+}
+
+// deduplicator detects repeated rows of the same element. Rows are
+// identified by the OSM id, and optionally by a hash of the geometry.
+// The number of remembered rows is limited to size. With lru the
+// least recently seen rows are forgotten first, otherwise all rows are
+// forgotten with each reset (i.e. at the end of each transaction) or
+// when the limit is reached.
+//
+// With keepLast, rows are held back till the end of the batch (see
+// collect) and duplicates replace the pending row. With keepError, the
+// first row of each element is remembered and duplicates are rejected
+// with a DuplicateRowError.
+type deduplicator struct {
+	mu        sync.Mutex
+	lru       bool
+	keepLast  bool
+	keepError bool
+	size      int
+	geometry  bool
+	idIdx     int
+	geomIdx   int
+	seen      map[dedupKey]*list.Element
+	order     *list.List // only for lru
+	dropped   int64
+	// pending rows for keepLast, index maps to the position in pending
+	pending [][]interface{}
+	index   map[dedupKey]int
+	// first rows for keepError
+	first map[dedupKey][]interface{}
+}
+
+// DuplicateRowError is returned for repeated rows of tables with dedup
+// keep error.
+type DuplicateRowError struct {
+	Table string
+	First []interface{}
+	Row   []interface{}
+}
+
+func (e *DuplicateRowError) Error() string {
+	return fmt.Sprintf("duplicate rows in %s: %v and %v", e.Table, e.First, e.Row)
+}
+
+func newDeduplicator(conf *mapping.Dedup, spec *TableSpec) (*deduplicator, error) {
+	d := &deduplicator{
+		size:     conf.Size,
+		geometry: conf.Geometry,
+		idIdx:    spec.idColumnIndex(),
+		geomIdx:  spec.geometryColumnIndex(),
+		seen:     make(map[dedupKey]*list.Element),
+	}
+	switch conf.Scope {
+	case "", "batch":
+	case "lru":
+		d.lru = true
+		d.order = list.New()
+	default:
+		return nil, fmt.Errorf("unknown dedup scope '%s' for table %s", conf.Scope, spec.Name)
+	}
+	switch conf.Keep {
+	case "", "first":
+	case "last":
+		if d.lru {
+			return nil, fmt.Errorf("dedup keep last requires batch scope for table %s", spec.Name)
+		}
+		d.keepLast = true
+		d.index = make(map[dedupKey]int)
+	case "error":
+		if d.lru {
+			return nil, fmt.Errorf("dedup keep error requires batch scope for table %s", spec.Name)
+		}
+		d.keepError = true
+		d.first = make(map[dedupKey][]interface{})
+	default:
+		return nil, fmt.Errorf("unknown dedup keep '%s' for table %s", conf.Keep, spec.Name)
+	}
+	if d.idIdx < 0 {
+		return nil, fmt.Errorf("dedup requires id column for table %s", spec.Name)
+	}
+	if d.size <= 0 {
+		d.size = defaultDedupSize
+	}
+	return d, nil
+}
+
+func (d *deduplicator) key(row []interface{}) (dedupKey, bool) {
+	if d.idIdx >= len(row) {
+		return dedupKey{}, false
+	}
+	id, ok := row[d.idIdx].(int64)
+	if !ok {
+		return dedupKey{}, false
+	}
+	key := dedupKey{id: id}
+	if d.geometry && d.geomIdx >= 0 && d.geomIdx < len(row) {
+		h := fnv.New64a()
+		switch g := row[d.geomIdx].(type) {
+		case string:
+			h.Write([]byte(g))
+		case []byte:
+			h.Write(g)
+		}
+		key.hash = h.Sum64()
+	}
+	return key, true
+}
+
+// duplicate returns true if the row was already seen.
+func (d *deduplicator) duplicate(row []interface{}) bool {
+	_, dup := d.seenRow(row)
+	return dup
+}
+
+// seenRow returns true if the row was already seen, and the first row of
+// the element for keepError.
+func (d *deduplicator) seenRow(row []interface{}) (first []interface{}, dup bool) {
+	key, ok := d.key(row)
+	if !ok {
+		return nil, false
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if elem, ok := d.seen[key]; ok {
+		if d.lru {
+			d.order.MoveToFront(elem)
+		}
+		d.dropped += 1
+		return d.first[key], true
+	}
+
+	if len(d.seen) >= d.size {
+		if d.lru {
+			oldest := d.order.Back()
+			d.order.Remove(oldest)
+			delete(d.seen, oldest.Value.(dedupKey))
+		} else {
+			d.seen = make(map[dedupKey]*list.Element)
+			if d.keepError {
+				d.first = make(map[dedupKey][]interface{})
+			}
+		}
+	}
+	if d.lru {
+		d.seen[key] = d.order.PushFront(key)
+	} else {
+		d.seen[key] = nil
+	}
+	if d.keepError {
+		// copy, the row is converted before the insert
+		d.first[key] = append([]interface{}(nil), row...)
+	}
+	return nil, false
+}
+
+// collect adds the row to the pending rows of keepLast and replaces
+// the pending row of a duplicate. It returns the rows that are ready for
+// insert: rows without id and all pending rows if the limit is reached.
+// replaced is true if the row replaced a pending row.
+func (d *deduplicator) collect(row []interface{}) (ready [][]interface{}, replaced bool) {
+	key, ok := d.key(row)
+	if !ok {
+		return [][]interface{}{row}, false
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if i, ok := d.index[key]; ok {
+		d.pending[i] = row
+		d.dropped += 1
+		return nil, true
+	}
+	if len(d.pending) >= d.size {
+		ready = d.takePending()
+	}
+	d.index[key] = len(d.pending)
+	d.pending = append(d.pending, row)
+	return ready, false
+}
+
+// takePending returns and forgets all pending rows.
+func (d *deduplicator) takePending() [][]interface{} {
+	pending := d.pending
+	d.pending = nil
+	d.index = make(map[dedupKey]int)
+	return pending
+}
+
+// flush returns and forgets all pending rows of keepLast.
+func (d *deduplicator) flush() [][]interface{} {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.takePending()
+}
+
+// forget drops all pending rows of the element, e.g. if the element is
+// deleted before the rows are inserted.
+func (d *deduplicator) forget(id int64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.pending) == 0 {
+		return
+	}
+	pending := d.pending
+	d.pending = nil
+	d.index = make(map[dedupKey]int)
+	for _, row := range pending {
+		key, _ := d.key(row)
+		if key.id == id {
+			continue
+		}
+		d.index[key] = len(d.pending)
+		d.pending = append(d.pending, row)
+	}
+}
+
+// reset forgets all rows for the batch scope.
+func (d *deduplicator) reset() {
+	if d.lru {
+		return
+	}
+	d.mu.Lock()
+	d.seen = make(map[dedupKey]*list.Element)
+	if d.keepLast {
+		d.takePending()
+	}
+	if d.keepError {
+		d.first = make(map[dedupKey][]interface{})
+	}
+	d.mu.Unlock()
+}
+
+// collectRows returns the rows that are ready for insert. Tables with dedup
+// keep last hold back rows till flushRows, all other rows are returned
+// immediately. Replaced duplicates are counted.
+func (spec *TableSpec) collectRows(row []interface{}) [][]interface{} {
+	if spec.dedup == nil || !spec.dedup.keepLast {
+		return [][]interface{}{row}
+	}
+	rows, replaced := spec.dedup.collect(row)
+	if replaced {
+		spec.rows.skippedFilter()
+	}
+	return rows
+}
+
+// flushRows returns all rows that are held back by collectRows.
+func (spec *TableSpec) flushRows() [][]interface{} {
+	if spec.dedup == nil || !spec.dedup.keepLast {
+		return nil
+	}
+	return spec.dedup.flush()
+}
+
+func (d *deduplicator) droppedRows() int64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.dropped
+}
+
+// DedupCounts returns the number of dropped (or rejected for keep error)
+// duplicate rows for each table with dedup.
+func (pg *PostGIS) DedupCounts() map[string]int64 {
+	counts := make(map[string]int64)
+	for name, spec := range pg.Tables {
+		if spec.dedup != nil {
+			counts[name] = spec.dedup.droppedRows()
+		}
+	}
+	return counts
+}
+
+func (pg *PostGIS) logDedupCounts() {
+	for name, n := range pg.DedupCounts() {
+		if n == 0 {
+			continue
+		}
+		if pg.Tables[name].dedup.keepError {
+			log.Printf("rejected %d duplicate rows in %s", n, name)
+		} else {
+			log.Printf("dropped %d duplicate rows in %s", n, name)
+		}
+	}
+}
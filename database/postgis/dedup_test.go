@@ -0,0 +1,245 @@
+package postgis
+
+import (
+	"testing"
+
+	"github.com/omniscale/imposm3/database"
+	"github.com/omniscale/imposm3/mapping"
+)
+
+func TestDedupBatch(t *testing.T) {
+	spec := testTableSpec(t, testPostGIS(), testTable())
+	d, err := newDeduplicator(&mapping.Dedup{}, spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if d.duplicate([]interface{}{int64(1), "0101", "", ""}) {
+		t.Error("first row is duplicate")
+	}
+	if !d.duplicate([]interface{}{int64(1), "0102", "", ""}) {
+		t.Error("same id not detected")
+	}
+	if d.duplicate([]interface{}{int64(2), "0101", "", ""}) {
+		t.Error("other id is duplicate")
+	}
+	d.reset()
+	if d.duplicate([]interface{}{int64(1), "0101", "", ""}) {
+		t.Error("duplicate after reset")
+	}
+	if d.droppedRows() != 1 {
+		t.Error("unexpected dropped rows", d.droppedRows())
+	}
+}
+
+func TestDedupGeometry(t *testing.T) {
+	spec := testTableSpec(t, testPostGIS(), testTable())
+	d, err := newDeduplicator(&mapping.Dedup{Geometry: true}, spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if d.duplicate([]interface{}{int64(1), "0101", "", ""}) {
+		t.Error("first row is duplicate")
+	}
+	if d.duplicate([]interface{}{int64(1), "0102", "", ""}) {
+		t.Error("other geometry is duplicate")
+	}
+	if !d.duplicate([]interface{}{int64(1), "0101", "", ""}) {
+		t.Error("same geometry not detected")
+	}
+}
+
+func TestDedupLRU(t *testing.T) {
+	spec := testTableSpec(t, testPostGIS(), testTable())
+	d, err := newDeduplicator(&mapping.Dedup{Scope: "lru", Size: 2}, spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	row := func(id int64) []interface{} { return []interface{}{id, "", "", ""} }
+
+	d.duplicate(row(1))
+	d.duplicate(row(2))
+	if !d.duplicate(row(1)) { // 1 is now the most recent
+		t.Error("1 not detected")
+	}
+	d.duplicate(row(3)) // evicts 2
+	if len(d.seen) != 2 || d.order.Len() != 2 {
+		t.Error("size not limited", len(d.seen))
+	}
+	if d.duplicate(row(2)) {
+		t.Error("2 not evicted")
+	}
+	// reset is a no-op for lru
+	d.reset()
+	if !d.duplicate(row(2)) {
+		t.Error("2 not detected after reset")
+	}
+}
+
+func TestDedupBatchLimit(t *testing.T) {
+	spec := testTableSpec(t, testPostGIS(), testTable())
+	d, err := newDeduplicator(&mapping.Dedup{Size: 10}, spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := int64(0); i < 25; i++ {
+		d.duplicate([]interface{}{i, "", "", ""})
+	}
+	if len(d.seen) > 10 {
+		t.Error("size not limited", len(d.seen))
+	}
+}
+
+func TestDedupInvalidScope(t *testing.T) {
+	spec := testTableSpec(t, testPostGIS(), testTable())
+	if _, err := newDeduplicator(&mapping.Dedup{Scope: "forever"}, spec); err == nil {
+		t.Error("expected error")
+	}
+}
+
+func TestDedupKeepOptions(t *testing.T) {
+	spec := testTableSpec(t, testPostGIS(), testTable())
+	if _, err := newDeduplicator(&mapping.Dedup{Keep: "last", Scope: "lru"}, spec); err == nil {
+		t.Error("expected error for keep last with lru")
+	}
+	if _, err := newDeduplicator(&mapping.Dedup{Keep: "newest"}, spec); err == nil {
+		t.Error("expected error for unknown keep")
+	}
+}
+
+func TestDedupCollect(t *testing.T) {
+	spec := testTableSpec(t, testPostGIS(), testTable())
+	d, err := newDeduplicator(&mapping.Dedup{Keep: "last", Size: 3}, spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	row := func(id int64, name string) []interface{} { return []interface{}{id, "", name, ""} }
+
+	for _, r := range [][]interface{}{row(1, "a"), row(2, "b"), row(1, "c")} {
+		if ready, _ := d.collect(r); ready != nil {
+			t.Error("unexpected ready rows", ready)
+		}
+	}
+	if ready, _ := d.collect([]interface{}{"no id", "", "", ""}); len(ready) != 1 {
+		t.Error("row without id not ready", ready)
+	}
+	d.collect(row(3, "d"))
+	// limit reached
+	ready, _ := d.collect(row(4, "e"))
+	if len(ready) != 3 || ready[0][2] != "c" || ready[1][2] != "b" || ready[2][2] != "d" {
+		t.Error("unexpected ready rows", ready)
+	}
+
+	d.collect(row(5, "f"))
+	d.forget(4)
+	if pending := d.flush(); len(pending) != 1 || pending[0][2] != "f" {
+		t.Error("unexpected pending rows", pending)
+	}
+	if d.droppedRows() != 1 {
+		t.Error("unexpected dropped rows", d.droppedRows())
+	}
+}
+
+func TestDedupBulkInsert(t *testing.T) {
+	line := ewkbLineString(3857, 0, 0, 1, 1).hex()
+	for _, test := range []struct {
+		keep     string
+		expected []string
+	}{
+		{"first", []string{"Main", "Side"}},
+		{"last", []string{"Main Street", "Side"}},
+	} {
+		db, d := newFakeDb()
+		pg := testPostGIS()
+		pg.Db = db
+		table := testTable()
+		table.Dedup = &mapping.Dedup{Keep: test.keep}
+		spec := testTableSpec(t, pg, table)
+		pg.Tables = map[string]*TableSpec{"roads": spec}
+
+		tt := NewBulkTableTx(pg, spec)
+		if err := tt.Begin(nil); err != nil {
+			t.Fatal(err)
+		}
+		tt.Insert([]interface{}{int64(1), line, "Main", ""})
+		tt.Insert([]interface{}{int64(2), line, "Side", ""})
+		tt.Insert([]interface{}{int64(1), line, "Main Street", ""})
+		if err := tt.Commit(); err != nil {
+			t.Fatal(err)
+		}
+		db.Close()
+
+		names := d.values("COPY", 2)
+		if len(names) != len(test.expected) {
+			t.Fatal("unexpected rows", test.keep, names)
+		}
+		for i, name := range test.expected {
+			if names[i] != name {
+				t.Error("unexpected row", test.keep, names)
+			}
+		}
+		if n := pg.DedupCounts()["roads"]; n != 1 {
+			t.Error("unexpected dedup count", test.keep, n)
+		}
+		if counts := pg.RowCounts()["roads"]; counts.Inserted != 2 || counts.SkippedFilter != 1 {
+			t.Error("unexpected row counts", test.keep, counts)
+		}
+	}
+}
+
+func TestDedupKeepError(t *testing.T) {
+	spec := testTableSpec(t, testPostGIS(), testTable())
+	if _, err := newDeduplicator(&mapping.Dedup{Keep: "error", Scope: "lru"}, spec); err == nil {
+		t.Error("expected error for keep error with lru")
+	}
+
+	line := ewkbLineString(3857, 0, 0, 1, 1).hex()
+	db, d := newFakeDb()
+	pg := testPostGIS()
+	pg.Db = db
+	var rejected []error
+	pg.Config.OnRowError = func(table string, row []interface{}, err error) database.ErrorAction {
+		rejected = append(rejected, err)
+		return database.SkipRow
+	}
+	table := testTable()
+	table.Dedup = &mapping.Dedup{Keep: "error"}
+	spec = testTableSpec(t, pg, table)
+	pg.Tables = map[string]*TableSpec{"roads": spec}
+
+	tt := NewBulkTableTx(pg, spec)
+	if err := tt.Begin(nil); err != nil {
+		t.Fatal(err)
+	}
+	tt.Insert([]interface{}{int64(1), line, "Main", ""})
+	tt.Insert([]interface{}{int64(2), line, "Side", ""})
+	tt.Insert([]interface{}{int64(1), line, "Main Street", ""})
+	if err := tt.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	if names := d.values("COPY", 2); len(names) != 2 || names[0] != "Main" || names[1] != "Side" {
+		t.Error("unexpected rows", names)
+	}
+	if len(rejected) != 1 {
+		t.Fatal("unexpected rejected rows", rejected)
+	}
+	dupErr, ok := rejected[0].(*DuplicateRowError)
+	if !ok || dupErr.First[2] != "Main" || dupErr.Row[2] != "Main Street" || rejectReason(dupErr) != "duplicate" {
+		t.Error("unexpected error", rejected[0])
+	}
+	if n := pg.DedupCounts()["roads"]; n != 1 {
+		t.Error("unexpected dedup count", n)
+	}
+	if counts := pg.RowCounts()["roads"]; counts.Inserted != 2 || counts.Failed != 1 {
+		t.Error("unexpected row counts", counts)
+	}
+
+	// first rows are forgotten with each batch
+	spec.dedup.reset()
+	if _, dup := spec.dedup.seenRow([]interface{}{int64(1), line, "Main", ""}); dup {
+		t.Error("unexpected duplicate after reset")
+	}
+}
@@ -0,0 +1,112 @@
+package postgis
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Dialect is the SQL syntax of a database backend. TableSpec creates the
+// CREATE TABLE, INSERT, COPY and CREATE INDEX statements of a table with
+// its Dialect, so that other backends can reuse the column handling of
+// TableSpec by implementing a Dialect, instead of building their own SQL.
+// Specs without Dialect use PostgreSQL.
+//
+// Statements of features that only PostgreSQL supports (e.g. upsert,
+// subdivide, generalized tables) are not part of the Dialect.
+type Dialect interface {
+	// Placeholder returns the placeholder of the i-th parameter of a
+	// statement, starting at 1.
+	Placeholder(i int) string
+	// QuoteIdent returns the quoted identifier.
+	QuoteIdent(name string) string
+	// QualifiedName returns the quoted name of the table in the schema.
+	QualifiedName(schema, table string) string
+	// Cast returns the expression converted to the SQL type.
+	Cast(expr, typ string) string
+	// SerialPrimaryKey returns the column definition of a generated
+	// integer primary key.
+	SerialPrimaryKey(name string) string
+	// GeomFromWKB returns the geometry of the hex encoded (E)WKB of expr.
+	// srid is 0 for EWKB with SRID.
+	GeomFromWKB(expr string, srid int) string
+	// GeomFromText returns the geometry of the WKT of expr.
+	GeomFromText(expr string, srid int) string
+	// GeometryColumnDDL returns the statement that adds the geometry
+	// column to the table of the spec.
+	GeometryColumnDDL(spec *TableSpec, column string) string
+	// IndexSQL returns the CREATE INDEX statement for target, the
+	// quoted columns or expression of the index. method is empty for the
+	// default method of the database.
+	IndexSQL(name, schema, table, method, target string) string
+	// SupportsCopy returns whether rows can be loaded with CopySQL.
+	SupportsCopy() bool
+	// SupportsReturning returns whether INSERT statements can return
+	// values of the inserted rows.
+	SupportsReturning() bool
+}
+
+// PostgreSQL is the Dialect of PostgreSQL with PostGIS.
+var PostgreSQL Dialect = postgresDialect{}
+
+type postgresDialect struct{}
+
+func (postgresDialect) Placeholder(i int) string {
+	return fmt.Sprintf("$%d", i)
+}
+
+func (postgresDialect) QuoteIdent(name string) string {
+	return `"` + strings.Replace(name, `"`, `""`, -1) + `"`
+}
+
+func (postgresDialect) QualifiedName(schema, table string) string {
+	return qualifiedTableName(schema, table)
+}
+
+func (postgresDialect) Cast(expr, typ string) string {
+	return expr + "::" + typ
+}
+
+func (postgresDialect) SerialPrimaryKey(name string) string {
+	return name + " SERIAL PRIMARY KEY"
+}
+
+func (postgresDialect) GeomFromWKB(expr string, srid int) string {
+	if srid == 0 {
+		return fmt.Sprintf("ST_GeomFromEWKB(decode(%s, 'hex'))", expr)
+	}
+	return fmt.Sprintf("ST_GeomFromWKB(decode(%s, 'hex'), %d)", expr, srid)
+}
+
+func (postgresDialect) GeomFromText(expr string, srid int) string {
+	return fmt.Sprintf("ST_GeomFromText(%s, %d)", expr, srid)
+}
+
+func (postgresDialect) GeometryColumnDDL(spec *TableSpec, column string) string {
+	return fmt.Sprintf("SELECT AddGeometryColumn('%s', '%s', '%s', '%d', '%s', %d);",
+		spec.Schema, spec.FullName, column, spec.Srid, spec.geometryColumnType(), spec.geometryDims())
+}
+
+func (d postgresDialect) IndexSQL(name, schema, table, method, target string) string {
+	using := ""
+	if method != "" {
+		using = " USING " + method
+	}
+	return fmt.Sprintf(`CREATE INDEX %s ON %s.%s%s (%s)`,
+		d.QuoteIdent(name), d.QuoteIdent(schema), d.QuoteIdent(table), using, target)
+}
+
+func (postgresDialect) SupportsCopy() bool {
+	return true
+}
+
+func (postgresDialect) SupportsReturning() bool {
+	return true
+}
+
+// dialect returns the Dialect of the spec.
+func (spec *TableSpec) dialect() Dialect {
+	if spec.Dialect == nil {
+		return PostgreSQL
+	}
+	return spec.Dialect
+}
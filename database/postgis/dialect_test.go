@@ -0,0 +1,134 @@
+package postgis
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/omniscale/imposm3/mapping"
+)
+
+func dialectTestSpec(t *testing.T, d Dialect) *TableSpec {
+	table := testTable()
+	table.Fields = append(table.Fields, &mapping.Field{
+		Name: "ref", Key: "ref", Type: "uuid",
+		NotNull: true, Default: "'00000000-0000-0000-0000-000000000000'",
+	})
+	table.TimestampColumn = "imported_at"
+	table.Upsert = true
+	table.Indexes = []*mapping.Index{{Columns: []string{"name DESC"}}, {Column: "tags", Method: "gin"}}
+	pg := testPostGIS()
+	pg.Config.GeometryEncoding = GeometryEncodingEwkb
+	spec := testTableSpec(t, pg, table)
+	spec.Dialect = d
+	return spec
+}
+
+func managedIndexSQL(spec *TableSpec) []string {
+	var sqls []string
+	for _, idx := range managedIndexes(spec.dialect(), spec.indexNames(), spec.Schema, spec.FullName, spec.Srid, spec.Columns, idIndexBrin, spec.Indexes) {
+		sqls = append(sqls, idx.sql)
+	}
+	return sqls
+}
+
+// TestPostgreSQLDialect compares the statements with the statements
+// before the Dialect was introduced, they need to stay the same.
+func TestPostgreSQLDialect(t *testing.T) {
+	for _, d := range []Dialect{nil, PostgreSQL} {
+		spec := dialectTestSpec(t, d)
+
+		expected := `
+        CREATE TABLE IF NOT EXISTS "import"."osm_roads" (
+            id SERIAL PRIMARY KEY,
+"osm_id" BIGINT,
+"name" VARCHAR,
+"tags" HSTORE,
+"ref" UUID NOT NULL DEFAULT '00000000-0000-0000-0000-000000000000',
+"imported_at" TIMESTAMP WITH TIME ZONE DEFAULT now(),
+UNIQUE ("osm_id")
+        );`
+		if sql := spec.CreateTableSQL(); sql != expected {
+			t.Errorf("unexpected SQL\n%s\n%s", sql, expected)
+		}
+		expected = `INSERT INTO "import"."osm_roads" ("osm_id", "geometry", "name", "tags", "ref") VALUES ($1, ST_GeomFromEWKB(decode($2, 'hex')), $3, $4, COALESCE($5::uuid, '00000000-0000-0000-0000-000000000000'))`
+		if sql := spec.InsertSQL(); sql != expected {
+			t.Errorf("unexpected SQL\n%s\n%s", sql, expected)
+		}
+		expected = `COPY "import"."osm_roads" ("osm_id", "geometry", "name", "tags", "ref", "imported_at") FROM STDIN`
+		if sql := spec.CopySQL(); sql != expected {
+			t.Errorf("unexpected SQL\n%s\n%s", sql, expected)
+		}
+		expectedIndexes := []string{
+			`CREATE INDEX "osm_roads_osm_id_idx" ON "import"."osm_roads" USING BRIN ("osm_id")`,
+			`CREATE INDEX "osm_roads_geom" ON "import"."osm_roads" USING GIST ("geometry")`,
+			`CREATE INDEX "osm_roads_geom_geohash" ON "import"."osm_roads" (ST_GeoHash(ST_Transform(ST_SetSRID(Box2D(geometry), 3857), 4326)))`,
+			`CREATE INDEX "osm_roads_name_idx" ON "import"."osm_roads" USING btree ("name" DESC)`,
+			`CREATE INDEX "osm_roads_tags_idx" ON "import"."osm_roads" USING gin ("tags")`,
+		}
+		if sqls := managedIndexSQL(spec); !reflect.DeepEqual(sqls, expectedIndexes) {
+			t.Errorf("unexpected indexes\n%v\n%v", sqls, expectedIndexes)
+		}
+	}
+
+	if sql := PostgreSQL.GeomFromWKB("$1", 4326); sql != "ST_GeomFromWKB(decode($1, 'hex'), 4326)" {
+		t.Error("unexpected SQL", sql)
+	}
+	if ident := PostgreSQL.QuoteIdent(`a"b`); ident != `"a""b"` {
+		t.Error("unexpected identifier", ident)
+	}
+}
+
+// testDialect is a minimal dialect with the syntax of SQLite.
+type testDialect struct{}
+
+func (testDialect) Placeholder(i int) string      { return "?" }
+func (testDialect) QuoteIdent(name string) string { return "`" + name + "`" }
+func (d testDialect) QualifiedName(schema, table string) string {
+	return d.QuoteIdent(schema) + "." + d.QuoteIdent(table)
+}
+func (testDialect) Cast(expr, typ string) string { return expr }
+func (testDialect) SerialPrimaryKey(name string) string {
+	return name + " INTEGER PRIMARY KEY AUTOINCREMENT"
+}
+func (testDialect) GeomFromWKB(expr string, srid int) string {
+	return fmt.Sprintf("GeomFromEWKB(%s)", expr)
+}
+func (testDialect) GeomFromText(expr string, srid int) string {
+	return fmt.Sprintf("GeomFromText(%s, %d)", expr, srid)
+}
+func (testDialect) GeometryColumnDDL(spec *TableSpec, column string) string {
+	return fmt.Sprintf("SELECT AddGeometryColumn('%s', '%s', %d, '%s')",
+		spec.FullName, column, spec.Srid, spec.geometryColumnType())
+}
+func (d testDialect) IndexSQL(name, schema, table, method, target string) string {
+	return fmt.Sprintf("CREATE INDEX %s ON %s (%s)", d.QuoteIdent(name), d.QualifiedName(schema, table), target)
+}
+func (testDialect) SupportsCopy() bool      { return false }
+func (testDialect) SupportsReturning() bool { return false }
+
+func TestDialect(t *testing.T) {
+	spec := dialectTestSpec(t, testDialect{})
+
+	sql := spec.CreateTableSQL()
+	for _, part := range []string{"CREATE TABLE IF NOT EXISTS `import`.`osm_roads`", "id INTEGER PRIMARY KEY AUTOINCREMENT", "`ref` UUID NOT NULL"} {
+		if !strings.Contains(sql, part) {
+			t.Errorf("missing %q in %s", part, sql)
+		}
+	}
+	expected := "INSERT INTO `import`.`osm_roads` (`osm_id`, `geometry`, `name`, `tags`, `ref`) VALUES (?, GeomFromEWKB(?), ?, ?, COALESCE(?, '00000000-0000-0000-0000-000000000000'))"
+	if sql := spec.InsertSQL(); sql != expected {
+		t.Errorf("unexpected SQL\n%s\n%s", sql, expected)
+	}
+	if sqls := managedIndexSQL(spec); sqls[1] != "CREATE INDEX `osm_roads_geom` ON `import`.`osm_roads` (`geometry`)" {
+		t.Error("unexpected index", sqls[1])
+	}
+	spec.Upsert = false
+	if spec.canCopy() {
+		t.Error("COPY not supported by dialect")
+	}
+	if _, err := (&PostGIS{Tables: map[string]*TableSpec{"roads": spec}}).InsertBatchReturningIds("roads", nil); err == nil {
+		t.Error("RETURNING not supported by dialect")
+	}
+}
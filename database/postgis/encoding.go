@@ -0,0 +1,116 @@
+package postgis
+
+import (
+	"encoding/hex"
+	"fmt"
+)
+
+// With Config.GeometryEncoding ewkb, geometries are inserted with
+// ST_GeomFromEWKB and the SRID of each geometry is checked before the
+// insert. Geometries are passed as hex encoded EWKB with SRID for
+// INSERT and COPY. Plain WKB (without the SRID flag in the header) gets
+// the SRID of the input, so that mixed input is inserted uniformly.
+
+// GeometryEncodingEwkb inserts geometries with ST_GeomFromEWKB.
+const GeometryEncodingEwkb = "ewkb"
+
+// EwkbSridError is returned for geometries with an embedded SRID that
+// differs from the SRID of the table (or of the input, if geometries are
+// transformed).
+type EwkbSridError struct {
+	Table    string
+	Srid     int
+	Expected int
+}
+
+func (e *EwkbSridError) Error() string {
+	return fmt.Sprintf("geometry for %s has SRID %d, but SRID %d is expected", e.Table, e.Srid, e.Expected)
+}
+
+func checkGeometryEncoding(encoding string, srid int) error {
+	switch encoding {
+	case "", GeometryEncodingEwkb:
+		return nil
+	case GeometryEncodingWkt:
+		if srid == 0 {
+			// WKT has no SRID to detect
+			return fmt.Errorf("geometry encoding wkt requires Srid")
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown geometry encoding '%s'", encoding)
+}
+
+// inputSrid returns the SRID that embedded SRIDs need to match.
+func (spec *TableSpec) inputSrid() int {
+	if spec.transformGeometry() {
+		return spec.InputSrid
+	}
+	return spec.Srid
+}
+
+// encodeEwkb returns the row with the geometry as hex encoded EWKB with
+// SRID. srid is the embedded SRID of the geometry (see checkSrid), plain
+// WKB (-1) gets the SRID of the input. Rows are not changed if the SRID
+// is detected from the data (see detectSrid).
+func (spec *TableSpec) encodeEwkb(row []interface{}, srid int) ([]interface{}, error) {
+	if spec.GeometryEncoding != GeometryEncodingEwkb || spec.autoSrid != nil {
+		return row, nil
+	}
+	idx := spec.geometryColumnIndex()
+	if idx < 0 || idx >= len(row) || row[idx] == nil {
+		return row, nil
+	}
+	if _, ok := row[idx].(string); ok && srid >= 0 {
+		return row, nil
+	}
+	wkb, err := hexWkb(row[idx])
+	if err != nil {
+		return nil, err
+	}
+	ewkb, err := wkbWithSrid(wkb, spec.inputSrid())
+	if err != nil {
+		return nil, err
+	}
+	encoded := make([]interface{}, len(row))
+	copy(encoded, row)
+	encoded[idx] = hex.EncodeToString(ewkb)
+	return encoded, nil
+}
+
+// checkSrid returns an *EwkbSridError for rows with a geometry with
+// another embedded SRID than inputSrid, before PostGIS fails the insert
+// with a less descriptive error (see sridInsertError). The SRID is
+// checked with the ewkb GeometryEncoding and for tables with CheckSrid.
+// It returns the embedded SRID for encodeEwkb, or -1 for plain WKB and
+// unchecked rows.
+func (spec *TableSpec) checkSrid(row []interface{}) (int, error) {
+	if spec.GeometryEncoding != GeometryEncodingEwkb && !spec.CheckSrid {
+		return -1, nil
+	}
+	if spec.autoSrid != nil {
+		return -1, nil
+	}
+	idx := spec.geometryColumnIndex()
+	if idx < 0 || idx >= len(row) || row[idx] == nil {
+		return -1, nil
+	}
+	srid, err := geometrySrid(row[idx])
+	if err != nil {
+		return 0, err
+	}
+	if expected := spec.inputSrid(); srid >= 0 && srid != expected {
+		return 0, &EwkbSridError{spec.Name, srid, expected}
+	}
+	return srid, nil
+}
+
+// sridInsertError returns the *EwkbSridError of checkSrid as an
+// *SQLInsertError with the statement that would insert the row. Other
+// errors are returned unchanged.
+func sridInsertError(err error, sql string, row []interface{}) error {
+	if sridErr, ok := err.(*EwkbSridError); ok {
+		return &SQLInsertError{SQLError{sql, sridErr}, row}
+	}
+	return err
+}
@@ -0,0 +1,182 @@
+package postgis
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/omniscale/imposm3/database"
+)
+
+func TestGeometryEncodingSQL(t *testing.T) {
+	pg := testPostGIS()
+	pg.Config.GeometryEncoding = GeometryEncodingEwkb
+	spec := testTableSpec(t, pg, testTable())
+	if sql := spec.InsertSQL(); !strings.Contains(sql, "ST_GeomFromEWKB(decode($2, 'hex'))") {
+		t.Error("unexpected sql", sql)
+	}
+
+	table := testTable()
+	table.Srid = 25832
+	spec = testTableSpec(t, pg, table)
+	if sql := spec.InsertSQL(); !strings.Contains(sql, "ST_Transform(ST_GeomFromEWKB(decode($2, 'hex')), 25832)") {
+		t.Error("unexpected sql", sql)
+	}
+
+	pg.Config.GeometryEncoding = "gml"
+	if err := pg.prepareTables(selectionMapping()); err == nil || !strings.Contains(err.Error(), "unknown geometry encoding 'gml'") {
+		t.Error("unexpected error", err)
+	}
+}
+
+func TestEncodeEwkb(t *testing.T) {
+	pg := testPostGIS()
+	pg.Config.GeometryEncoding = GeometryEncodingEwkb
+	spec := testTableSpec(t, pg, testTable())
+
+	ewkb := ewkbLineString(3857, 0, 0, 1, 1)
+	row := []interface{}{int64(1), ewkb.hex(), "", ""}
+	if encoded, err := spec.encodeEwkb(row, 3857); err != nil || &encoded[0] != &row[0] {
+		t.Error("unexpected encoding", encoded, err)
+	}
+
+	// mixed input, plain WKB and binary EWKB
+	wkb := ewkbLineString(0, 0, 0, 1, 1)
+	for _, geom := range []interface{}{wkb.hex(), ewkb.Bytes()} {
+		srid, err := spec.checkSrid([]interface{}{int64(1), geom, "", ""})
+		if err != nil {
+			t.Fatal(err)
+		}
+		encoded, err := spec.encodeEwkb([]interface{}{int64(1), geom, "", ""}, srid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if encoded[1] != ewkb.hex() {
+			t.Errorf("unexpected geometry %v", encoded[1])
+		}
+	}
+
+	_, err := spec.checkSrid([]interface{}{int64(1), ewkbLineString(4326, 0, 0, 1, 1).hex(), "", ""})
+	if err == nil || err.Error() != "geometry for roads has SRID 4326, but SRID 3857 is expected" {
+		t.Error("unexpected error", err)
+	}
+
+	// input SRID for transformed geometries
+	table := testTable()
+	table.Srid = 25832
+	spec = testTableSpec(t, pg, table)
+	if _, err := spec.checkSrid(row); err != nil {
+		t.Error(err)
+	}
+	encoded, err := spec.encodeEwkb([]interface{}{int64(1), wkb.hex(), "", ""}, -1)
+	if err != nil || encoded[1] != ewkb.hex() {
+		t.Error("unexpected encoding", encoded, err)
+	}
+}
+
+func TestCheckSrid(t *testing.T) {
+	table := testTable()
+	table.CheckSrid = true
+	spec := testTableSpec(t, testPostGIS(), table)
+	if !spec.CheckSrid {
+		t.Fatal("CheckSrid not enabled")
+	}
+
+	for _, geom := range []interface{}{
+		ewkbLineString(3857, 0, 0, 1, 1).hex(),
+		ewkbLineString(3857, 0, 0, 1, 1).Bytes(),
+		// plain WKB
+		ewkbLineString(0, 0, 0, 1, 1).hex(),
+	} {
+		row, err := spec.convertRow([]interface{}{int64(1), geom, "", ""})
+		if err != nil || row == nil {
+			t.Error("unexpected error", geom, err)
+		}
+	}
+
+	row := []interface{}{int64(1), ewkbLineString(4326, 0, 0, 1, 1).hex(), "", ""}
+	_, err := spec.convertRow(row)
+	sridErr, ok := err.(*EwkbSridError)
+	if !ok || sridErr.Srid != 4326 || sridErr.Expected != 3857 {
+		t.Errorf("unexpected error %#v", err)
+	}
+	if err == nil || err.Error() != "geometry for roads has SRID 4326, but SRID 3857 is expected" {
+		t.Error("unexpected error", err)
+	}
+
+	// input SRID for transformed geometries
+	table.Srid = 25832
+	spec = testTableSpec(t, testPostGIS(), table)
+	if _, err := spec.convertRow(row); err == nil || !strings.Contains(err.Error(), "SRID 4326, but SRID 3857 is expected") {
+		t.Error("unexpected error", err)
+	}
+	row[1] = ewkbLineString(3857, 0, 0, 1, 1).hex()
+	if _, err := spec.convertRow(row); err != nil {
+		t.Error(err)
+	}
+
+	// without check_srid, the geometry is passed to PostGIS
+	spec = testTableSpec(t, testPostGIS(), testTable())
+	if _, err := spec.convertRow([]interface{}{int64(1), ewkbLineString(4326, 0, 0, 1, 1).hex(), "", ""}); err != nil {
+		t.Error(err)
+	}
+
+	// always checked with ewkb encoding
+	pg := testPostGIS()
+	pg.Config.GeometryEncoding = GeometryEncodingEwkb
+	spec = testTableSpec(t, pg, testTable())
+	if _, err := spec.convertRow([]interface{}{int64(1), ewkbLineString(4326, 0, 0, 1, 1).hex(), "", ""}); err == nil {
+		t.Error("expected error for mismatching SRID")
+	} else if _, ok := err.(*EwkbSridError); !ok {
+		t.Error("expected EwkbSridError", err)
+	}
+
+	pg.Config.GeometryEncoding = GeometryEncodingWkt
+	if _, err := NewTableSpec(pg, table); err == nil || !strings.Contains(err.Error(), "check_srid requires EWKB geometries") {
+		t.Error("unexpected error", err)
+	}
+}
+
+// TestCheckSridStatement checks that rejected rows report the statement
+// that would insert them.
+func TestCheckSridStatement(t *testing.T) {
+	for _, bulk := range []bool{true, false} {
+		db, _ := newFakeDb()
+		pg := testPostGIS()
+		pg.Db = db
+		var rowErr error
+		pg.Config.OnRowError = func(table string, row []interface{}, err error) database.ErrorAction {
+			rowErr = err
+			return database.SkipRow
+		}
+		table := testTable()
+		table.CheckSrid = true
+		spec := testTableSpec(t, pg, table)
+		pg.Tables = map[string]*TableSpec{"roads": spec}
+
+		var tt TableTx
+		expected := spec.InsertSQL()
+		if bulk {
+			tt = NewBulkTableTx(pg, spec)
+			expected = spec.CopySQL()
+		} else {
+			tt = NewSynchronousTableTx(pg, spec.FullName, spec)
+		}
+		if err := tt.Begin(nil); err != nil {
+			t.Fatal(err)
+		}
+		tt.Insert([]interface{}{int64(1), ewkbLineString(4326, 0, 0, 1, 1).hex(), "Main", ""})
+		tt.End()
+		if err := tt.Commit(); err != nil {
+			t.Fatal(err)
+		}
+		db.Close()
+
+		insertErr, ok := rowErr.(*SQLInsertError)
+		if !ok {
+			t.Fatal("expected SQLInsertError", bulk, rowErr)
+		}
+		if _, ok := insertErr.originalError.(*EwkbSridError); !ok || insertErr.query != expected {
+			t.Errorf("unexpected error %v\n%s", bulk, insertErr)
+		}
+	}
+}
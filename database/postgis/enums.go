@@ -0,0 +1,178 @@
+package postgis
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/omniscale/imposm3/mapping"
+)
+
+// Columns with enum use a PostgreSQL enum type of the mapping. Init
+// creates the types in the import schema, or adds missing values to
+// existing types. Types are never dropped, as the deployed tables in the
+// production and backup schemas still use them. Values are cast to the
+// enum type, PostgreSQL rejects unknown values. Enums with check reject
+// these rows before the insert, so that they don't fail the whole batch.
+// Columns of the invalid table are VARCHAR, to keep rejected values.
+
+// EnumValueError is returned for values that are not members of the enum
+// of the column.
+type EnumValueError struct {
+	Table  string
+	Column string
+	Enum   string
+	Value  string
+}
+
+func (e *EnumValueError) Error() string {
+	return fmt.Sprintf("invalid value '%s' for enum %s of column %s in %s", e.Value, e.Enum, e.Column, e.Table)
+}
+
+// enumSpec is an enum type of the mapping.
+type enumSpec struct {
+	Name   string
+	Schema string
+	Values []string
+	check  bool
+	values map[string]bool
+}
+
+func (e *enumSpec) qualifiedName() string {
+	return fmt.Sprintf(`"%s"."%s"`, e.Schema, e.Name)
+}
+
+// enumColumnType is the column type of columns with enum.
+type enumColumnType struct {
+	simpleColumnType
+}
+
+func (t *enumColumnType) PrepareInsertSql(i int, spec *TableSpec) string {
+	d := spec.dialect()
+	return d.Cast(d.Placeholder(i), t.name)
+}
+
+// prepareEnums creates the specs for all enums of the mapping.
+func (pg *PostGIS) prepareEnums(enums map[string]*mapping.Enum) error {
+	pg.enums = make(map[string]*enumSpec)
+	for name, enum := range enums {
+		if enum == nil || len(enum.Values) == 0 {
+			return fmt.Errorf("enum %s requires values", name)
+		}
+		spec := &enumSpec{
+			Name:   pg.Prefix + name,
+			Schema: pg.Config.ImportSchema,
+			Values: enum.Values,
+			check:  enum.Check,
+			values: make(map[string]bool),
+		}
+		for _, v := range enum.Values {
+			if spec.values[v] {
+				return fmt.Errorf("duplicate value '%s' in enum %s", v, name)
+			}
+			spec.values[v] = true
+		}
+		pg.enums[name] = spec
+	}
+	return nil
+}
+
+// prepareEnumColumns sets the enum type of all columns with enum and
+// returns all problems.
+func (pg *PostGIS) prepareEnumColumns(spec *TableSpec, fields []*mapping.Field) []string {
+	var problems []string
+	for i, field := range fields {
+		if field.Enum == "" {
+			continue
+		}
+		enum, ok := pg.enums[field.Enum]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("unknown enum %s for column %s", field.Enum, field.Name))
+			continue
+		}
+		if spec.Columns[i].FieldType.GoType != "string" {
+			problems = append(problems, fmt.Sprintf("enum %s requires string column, %s is %s",
+				field.Enum, field.Name, field.Type))
+			continue
+		}
+		spec.Columns[i].Type = &enumColumnType{simpleColumnType{enum.qualifiedName()}}
+		spec.Columns[i].enum = enum
+	}
+	return problems
+}
+
+// checkEnums returns an error for values that are not members of the
+// enum of columns with check.
+func (spec *TableSpec) checkEnums(row []interface{}) error {
+	for i, col := range spec.Columns {
+		if col.enum == nil || !col.enum.check || i >= len(row) || row[i] == nil {
+			continue
+		}
+		v, ok := row[i].(string)
+		if !ok {
+			v = fmt.Sprint(row[i])
+		}
+		if !col.enum.values[v] {
+			return &EnumValueError{spec.Name, col.Name, col.enum.Name, v}
+		}
+	}
+	return nil
+}
+
+// quoteLiteral returns s as SQL string literal.
+func quoteLiteral(s string) string {
+	return "'" + strings.Replace(s, "'", "''", -1) + "'"
+}
+
+func (e *enumSpec) CreateTypeSQL() string {
+	values := make([]string, len(e.Values))
+	for i, v := range e.Values {
+		values[i] = quoteLiteral(v)
+	}
+	return fmt.Sprintf(`CREATE TYPE %s AS ENUM (%s)`, e.qualifiedName(), strings.Join(values, ", "))
+}
+
+// AddValueSQL returns the statement that adds the value to an existing
+// type. It can't run in a transaction before PostgreSQL 12.
+func (e *enumSpec) AddValueSQL(value string) string {
+	return fmt.Sprintf(`ALTER TYPE %s ADD VALUE IF NOT EXISTS %s`, e.qualifiedName(), quoteLiteral(value))
+}
+
+// createEnums creates all enum types, or adds missing values to
+// existing types.
+func (pg *PostGIS) createEnums() error {
+	names := make([]string, 0, len(pg.enums))
+	for name := range pg.enums {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := createEnum(pg.Db, pg.enums[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func createEnum(db *sql.DB, enum *enumSpec) error {
+	sql := `SELECT EXISTS(SELECT 1 FROM pg_type t JOIN pg_namespace n ON n.oid = t.typnamespace WHERE n.nspname = $1 AND t.typname = $2)`
+	var exists bool
+	if err := db.QueryRow(sql, enum.Schema, enum.Name).Scan(&exists); err != nil {
+		return &SQLError{sql, err}
+	}
+	if !exists {
+		sql = enum.CreateTypeSQL()
+		if _, err := db.Exec(sql); err != nil {
+			return &SQLError{sql, err}
+		}
+		return nil
+	}
+	for _, v := range enum.Values {
+		sql = enum.AddValueSQL(v)
+		if _, err := db.Exec(sql); err != nil {
+			return &SQLError{sql, err}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package postgis
+
+import (
+	"database/sql/driver"
+	"strings"
+	"testing"
+
+	"github.com/omniscale/imposm3/mapping"
+)
+
+func testEnumPostGIS(t *testing.T, check bool) *PostGIS {
+	pg := testPostGIS()
+	err := pg.prepareEnums(map[string]*mapping.Enum{
+		"road_class": {Values: []string{"motorway", "primary", "farmer's"}, Check: check},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pg
+}
+
+func testEnumTable() *mapping.Table {
+	table := testTable()
+	table.Fields = append(table.Fields, &mapping.Field{Name: "class", Key: "highway", Type: "string", Enum: "road_class"})
+	return table
+}
+
+func TestCreateTypeSQL(t *testing.T) {
+	pg := testEnumPostGIS(t, false)
+	enum := pg.enums["road_class"]
+	expected := `CREATE TYPE "import"."osm_road_class" AS ENUM ('motorway', 'primary', 'farmer''s')`
+	if sql := enum.CreateTypeSQL(); sql != expected {
+		t.Errorf("unexpected SQL\n%s\n%s", sql, expected)
+	}
+	expected = `ALTER TYPE "import"."osm_road_class" ADD VALUE IF NOT EXISTS 'primary'`
+	if sql := enum.AddValueSQL("primary"); sql != expected {
+		t.Errorf("unexpected SQL\n%s\n%s", sql, expected)
+	}
+}
+
+func TestEnumColumnSQL(t *testing.T) {
+	spec := testTableSpec(t, testEnumPostGIS(t, false), testEnumTable())
+
+	if sql := spec.CreateTableSQL(); !strings.Contains(sql, `"class" "import"."osm_road_class"`) {
+		t.Error("missing enum column", sql)
+	}
+	if sql := spec.InsertSQL(); !strings.Contains(sql, `$5::"import"."osm_road_class")`) {
+		t.Error("missing enum cast", sql)
+	}
+	if sql := spec.CreateInvalidTableSQL(); !strings.Contains(sql, `"class" VARCHAR`) {
+		t.Error("invalid table requires VARCHAR column", sql)
+	}
+	if sql := spec.InsertInvalidSQL(); strings.Contains(sql, `osm_road_class`) {
+		t.Error("unexpected enum cast for invalid table", sql)
+	}
+}
+
+func TestEnumColumnErrors(t *testing.T) {
+	pg := testEnumPostGIS(t, false)
+
+	table := testTable()
+	table.Fields = append(table.Fields, &mapping.Field{Name: "class", Key: "highway", Type: "string", Enum: "unknown"})
+	_, err := NewTableSpec(pg, table)
+	if err == nil || !strings.Contains(err.Error(), "unknown enum unknown for column class") {
+		t.Error("unexpected error", err)
+	}
+
+	table = testTable()
+	table.Fields = append(table.Fields, &mapping.Field{Name: "lanes", Key: "lanes", Type: "integer", Enum: "road_class"})
+	_, err = NewTableSpec(pg, table)
+	if err == nil || !strings.Contains(err.Error(), "enum road_class requires string column, lanes is integer") {
+		t.Error("unexpected error", err)
+	}
+
+	if err := pg.prepareEnums(map[string]*mapping.Enum{"empty": {}}); err == nil {
+		t.Error("missing error for enum without values")
+	}
+	if err := pg.prepareEnums(map[string]*mapping.Enum{"dup": {Values: []string{"a", "a"}}}); err == nil {
+		t.Error("missing error for duplicate values")
+	}
+}
+
+func TestCheckEnums(t *testing.T) {
+	geom := ewkbLineString(3857, 0, 0, 10, 10).hex()
+
+	spec := testTableSpec(t, testEnumPostGIS(t, true), testEnumTable())
+	if _, err := spec.prepareRow([]interface{}{int64(1), geom, "A1", nil, "motorway"}); err != nil {
+		t.Error(err)
+	}
+	if _, err := spec.prepareRow([]interface{}{int64(1), geom, "A1", nil, nil}); err != nil {
+		t.Error(err)
+	}
+	_, err := spec.prepareRow([]interface{}{int64(1), geom, "A1", nil, "track"})
+	if _, ok := err.(*EnumValueError); !ok {
+		t.Fatal("unexpected error", err)
+	}
+	if reason := rejectReason(err); reason != "enum" {
+		t.Error("unexpected reason", reason)
+	}
+
+	// values are checked by PostgreSQL without check
+	spec = testTableSpec(t, testEnumPostGIS(t, false), testEnumTable())
+	if _, err := spec.prepareRow([]interface{}{int64(1), geom, "A1", nil, "track"}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCreateEnums(t *testing.T) {
+	for _, exists := range []bool{false, true} {
+		db, d := newFakeDb()
+		pg := testEnumPostGIS(t, false)
+		pg.Db = db
+		d.results = map[string]fakeResult{
+			"SELECT EXISTS(SELECT 1 FROM pg_type": {
+				columns: []string{"exists"},
+				rows:    [][]driver.Value{{exists}},
+			},
+		}
+		if err := pg.createEnums(); err != nil {
+			t.Fatal(err)
+		}
+		if exists {
+			if n := d.count("CREATE TYPE"); n != 0 {
+				t.Error("existing type created")
+			}
+			if n := d.count(`ALTER TYPE "import"."osm_road_class" ADD VALUE`); n != 3 {
+				t.Error("unexpected number of added values", n)
+			}
+		} else {
+			if n := d.count(`CREATE TYPE "import"."osm_road_class"`); n != 1 {
+				t.Error("type not created")
+			}
+			if n := d.count("ALTER TYPE"); n != 0 {
+				t.Error("unexpected added values", n)
+			}
+		}
+		db.Close()
+	}
+}
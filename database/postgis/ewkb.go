@@ -0,0 +1,440 @@
+package postgis
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Geometries are passed as hex encoded EWKB strings (see geos.AsEwkbHex)
+// to the database. The following functions read just enough of (E)WKB to
+// make decisions before the geometry is sent to PostGIS.
+
+const (
+	ewkbZFlag    = 0x80000000
+	ewkbMFlag    = 0x40000000
+	ewkbSridFlag = 0x20000000
+)
+
+const (
+	wkbPoint              = 1
+	wkbLineString         = 2
+	wkbPolygon            = 3
+	wkbMultiPoint         = 4
+	wkbMultiLineString    = 5
+	wkbMultiPolygon       = 6
+	wkbGeometryCollection = 7
+)
+
+var errWkbTruncated = errors.New("truncated WKB")
+
+type wkbReader struct {
+	buf   []byte
+	pos   int
+	order binary.ByteOrder
+}
+
+func (r *wkbReader) byteOrder() error {
+	if r.pos+1 > len(r.buf) {
+		return errWkbTruncated
+	}
+	switch r.buf[r.pos] {
+	case 0:
+		r.order = binary.BigEndian
+	case 1:
+		r.order = binary.LittleEndian
+	default:
+		return fmt.Errorf("invalid WKB byte order %d", r.buf[r.pos])
+	}
+	r.pos += 1
+	return nil
+}
+
+func (r *wkbReader) uint32() (uint32, error) {
+	if r.pos+4 > len(r.buf) {
+		return 0, errWkbTruncated
+	}
+	v := r.order.Uint32(r.buf[r.pos:])
+	r.pos += 4
+	return v, nil
+}
+
+func (r *wkbReader) skip(n int) error {
+	if n < 0 || r.pos+n > len(r.buf) {
+		return errWkbTruncated
+	}
+	r.pos += n
+	return nil
+}
+
+// header reads byte order, type and optional SRID of the next geometry.
+// It returns the base geometry type (1-7), whether the coordinates have
+// Z and M values and the SRID (-1 if not set).
+func (r *wkbReader) header() (geomType uint32, hasZ, hasM bool, srid int, err error) {
+	if err = r.byteOrder(); err != nil {
+		return
+	}
+	typ, err := r.uint32()
+	if err != nil {
+		return
+	}
+	hasZ = typ&ewkbZFlag != 0
+	hasM = typ&ewkbMFlag != 0
+	srid = -1
+	if typ&ewkbSridFlag != 0 {
+		var s uint32
+		s, err = r.uint32()
+		if err != nil {
+			return
+		}
+		srid = int(int32(s))
+	}
+	typ = typ &^ (ewkbZFlag | ewkbMFlag | ewkbSridFlag)
+	// ISO WKB uses type+1000 for Z, +2000 for M and +3000 for ZM
+	switch typ / 1000 {
+	case 1:
+		hasZ = true
+	case 2:
+		hasM = true
+	case 3:
+		hasZ, hasM = true, true
+	}
+	geomType = typ % 1000
+	if geomType < wkbPoint || geomType > wkbGeometryCollection {
+		err = fmt.Errorf("unsupported WKB geometry type %d", typ)
+	}
+	return
+}
+
+func coordDims(hasZ, hasM bool) int {
+	dims := 2
+	if hasZ {
+		dims += 1
+	}
+	if hasM {
+		dims += 1
+	}
+	return dims
+}
+
+// numPoints reads the next geometry and returns the number of its coordinates.
+func (r *wkbReader) numPoints() (int, error) {
+	geomType, hasZ, hasM, _, err := r.header()
+	if err != nil {
+		return 0, err
+	}
+	dims := coordDims(hasZ, hasM)
+	switch geomType {
+	case wkbPoint:
+		return 1, r.skip(dims * 8)
+	case wkbLineString:
+		n, err := r.uint32()
+		if err != nil {
+			return 0, err
+		}
+		return int(n), r.skip(int(n) * dims * 8)
+	case wkbPolygon:
+		rings, err := r.uint32()
+		if err != nil {
+			return 0, err
+		}
+		total := 0
+		for i := uint32(0); i < rings; i++ {
+			n, err := r.uint32()
+			if err != nil {
+				return 0, err
+			}
+			if err := r.skip(int(n) * dims * 8); err != nil {
+				return 0, err
+			}
+			total += int(n)
+		}
+		return total, nil
+	default:
+		parts, err := r.uint32()
+		if err != nil {
+			return 0, err
+		}
+		total := 0
+		for i := uint32(0); i < parts; i++ {
+			n, err := r.numPoints()
+			if err != nil {
+				return 0, err
+			}
+			total += n
+		}
+		return total, nil
+	}
+}
+
+// wkbNumPoints returns the number of coordinates of a (E)WKB geometry.
+func wkbNumPoints(wkb []byte) (int, error) {
+	r := wkbReader{buf: wkb}
+	return r.numPoints()
+}
+
+// isBinaryWkb returns whether b is binary (E)WKB and not hex encoded.
+// Binary WKB starts with the byte order 0 or 1, hex encoded WKB with
+// the characters '0'.
+func isBinaryWkb(b []byte) bool {
+	return len(b) > 0 && (b[0] == 0 || b[0] == 1)
+}
+
+// hexWkb returns the binary (E)WKB of a geometry row value.
+func hexWkb(value interface{}) ([]byte, error) {
+	switch v := value.(type) {
+	case string:
+		return hex.DecodeString(v)
+	case []byte:
+		if isBinaryWkb(v) {
+			return v, nil
+		}
+		return hex.DecodeString(string(v))
+	case nil:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unsupported geometry value %T", value)
+	}
+}
+
+// wkbGeometry is a decoded (E)WKB geometry.
+type wkbGeometry struct {
+	geomType   uint32
+	hasZ, hasM bool
+	srid       int // -1 if not set
+	// coords of point, linestring or polygon rings with
+	// coordDims values for each coordinate
+	rings [][]float64
+	// parts of multi geometries and geometry collections
+	parts []*wkbGeometry
+}
+
+func (g *wkbGeometry) dims() int {
+	return coordDims(g.hasZ, g.hasM)
+}
+
+func (r *wkbReader) coords(n int, dims int) ([]float64, error) {
+	if n < 0 || r.pos+n*dims*8 > len(r.buf) {
+		return nil, errWkbTruncated
+	}
+	coords := make([]float64, n*dims)
+	for i := range coords {
+		coords[i] = math.Float64frombits(r.order.Uint64(r.buf[r.pos:]))
+		r.pos += 8
+	}
+	return coords, nil
+}
+
+// geometry reads and decodes the next geometry.
+func (r *wkbReader) geometry() (*wkbGeometry, error) {
+	geomType, hasZ, hasM, srid, err := r.header()
+	if err != nil {
+		return nil, err
+	}
+	g := &wkbGeometry{geomType: geomType, hasZ: hasZ, hasM: hasM, srid: srid}
+	dims := g.dims()
+	switch geomType {
+	case wkbPoint:
+		coords, err := r.coords(1, dims)
+		if err != nil {
+			return nil, err
+		}
+		g.rings = [][]float64{coords}
+	case wkbLineString:
+		n, err := r.uint32()
+		if err != nil {
+			return nil, err
+		}
+		coords, err := r.coords(int(n), dims)
+		if err != nil {
+			return nil, err
+		}
+		g.rings = [][]float64{coords}
+	case wkbPolygon:
+		rings, err := r.uint32()
+		if err != nil {
+			return nil, err
+		}
+		for i := uint32(0); i < rings; i++ {
+			n, err := r.uint32()
+			if err != nil {
+				return nil, err
+			}
+			coords, err := r.coords(int(n), dims)
+			if err != nil {
+				return nil, err
+			}
+			g.rings = append(g.rings, coords)
+		}
+	default:
+		parts, err := r.uint32()
+		if err != nil {
+			return nil, err
+		}
+		for i := uint32(0); i < parts; i++ {
+			part, err := r.geometry()
+			if err != nil {
+				return nil, err
+			}
+			g.parts = append(g.parts, part)
+		}
+	}
+	return g, nil
+}
+
+// decodeWkb decodes a (E)WKB geometry.
+func decodeWkb(wkb []byte) (*wkbGeometry, error) {
+	r := wkbReader{buf: wkb}
+	return r.geometry()
+}
+
+func (g *wkbGeometry) numPoints() int {
+	n := 0
+	for _, ring := range g.rings {
+		n += len(ring) / g.dims()
+	}
+	for _, part := range g.parts {
+		n += part.numPoints()
+	}
+	return n
+}
+
+// bounds returns the 2D bounding box of the geometry.
+func (g *wkbGeometry) bounds() (minx, miny, maxx, maxy float64) {
+	minx, miny = math.Inf(1), math.Inf(1)
+	maxx, maxy = math.Inf(-1), math.Inf(-1)
+	g.walkBounds(&minx, &miny, &maxx, &maxy)
+	return
+}
+
+func (g *wkbGeometry) walkBounds(minx, miny, maxx, maxy *float64) {
+	dims := g.dims()
+	for _, ring := range g.rings {
+		for i := 0; i+1 < len(ring); i += dims {
+			*minx = math.Min(*minx, ring[i])
+			*maxx = math.Max(*maxx, ring[i])
+			*miny = math.Min(*miny, ring[i+1])
+			*maxy = math.Max(*maxy, ring[i+1])
+		}
+	}
+	for _, part := range g.parts {
+		part.walkBounds(minx, miny, maxx, maxy)
+	}
+}
+
+// appendEwkb appends the little endian EWKB of the geometry to buf.
+// The SRID is included if it is set.
+func (g *wkbGeometry) appendEwkb(buf []byte) []byte {
+	typ := g.geomType
+	if g.hasZ {
+		typ |= ewkbZFlag
+	}
+	if g.hasM {
+		typ |= ewkbMFlag
+	}
+	if g.srid >= 0 {
+		typ |= ewkbSridFlag
+	}
+	buf = append(buf, 1)
+	buf = appendUint32(buf, typ)
+	if g.srid >= 0 {
+		buf = appendUint32(buf, uint32(g.srid))
+	}
+	dims := g.dims()
+	switch g.geomType {
+	case wkbPoint:
+		buf = appendFloats(buf, g.rings[0])
+	case wkbLineString:
+		buf = appendUint32(buf, uint32(len(g.rings[0])/dims))
+		buf = appendFloats(buf, g.rings[0])
+	case wkbPolygon:
+		buf = appendUint32(buf, uint32(len(g.rings)))
+		for _, ring := range g.rings {
+			buf = appendUint32(buf, uint32(len(ring)/dims))
+			buf = appendFloats(buf, ring)
+		}
+	default:
+		buf = appendUint32(buf, uint32(len(g.parts)))
+		for _, part := range g.parts {
+			buf = part.appendEwkb(buf)
+		}
+	}
+	return buf
+}
+
+// hexEwkb returns the hex encoded EWKB of the geometry.
+func (g *wkbGeometry) hexEwkb() string {
+	return hex.EncodeToString(g.appendEwkb(nil))
+}
+
+func appendUint32(buf []byte, v uint32) []byte {
+	return append(buf, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+}
+
+func appendFloats(buf []byte, values []float64) []byte {
+	for _, v := range values {
+		b := math.Float64bits(v)
+		buf = append(buf, byte(b), byte(b>>8), byte(b>>16), byte(b>>24),
+			byte(b>>32), byte(b>>40), byte(b>>48), byte(b>>56))
+	}
+	return buf
+}
+
+// ewkbHexWithSrid returns the geometry value as hex encoded EWKB with
+// SRID. PostGIS accepts this text representation for geometry columns,
+// so that geometries can be passed with text mode COPY without
+// ST_GeomFromWKB. value can be hex encoded or binary (E)WKB. Hex encoded
+// EWKB that already contains a SRID is returned unchanged.
+func ewkbHexWithSrid(value interface{}, srid int) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+	if v, ok := value.(string); ok && len(v) >= 18 {
+		// check header and SRID without decoding the whole geometry
+		prefix, err := hex.DecodeString(v[:18])
+		if err != nil {
+			return nil, err
+		}
+		r := wkbReader{buf: prefix}
+		if _, _, _, s, err := r.header(); err == nil && s >= 0 {
+			return v, nil
+		}
+	}
+	wkb, err := hexWkb(value)
+	if err != nil {
+		return nil, err
+	}
+	ewkb, err := wkbWithSrid(wkb, srid)
+	if err != nil {
+		return nil, err
+	}
+	return hex.EncodeToString(ewkb), nil
+}
+
+// wkbWithSrid returns EWKB with the SRID in the header of the outer
+// geometry. ISO WKB types are converted to EWKB flags. The SRID of EWKB
+// that already has one is kept.
+func wkbWithSrid(wkb []byte, srid int) ([]byte, error) {
+	r := wkbReader{buf: wkb}
+	geomType, hasZ, hasM, s, err := r.header()
+	if err != nil {
+		return nil, err
+	}
+	if s >= 0 {
+		return wkb, nil
+	}
+	typ := geomType | ewkbSridFlag
+	if hasZ {
+		typ |= ewkbZFlag
+	}
+	if hasM {
+		typ |= ewkbMFlag
+	}
+	ewkb := make([]byte, 9, len(wkb)+4)
+	ewkb[0] = wkb[0]
+	r.order.PutUint32(ewkb[1:], typ)
+	r.order.PutUint32(ewkb[5:], uint32(srid))
+	return append(ewkb, wkb[r.pos:]...), nil
+}
@@ -0,0 +1,191 @@
+package postgis
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"math"
+	"testing"
+)
+
+// ewkbBuilder creates little endian EWKB for tests.
+type ewkbBuilder struct {
+	bytes.Buffer
+}
+
+func (b *ewkbBuilder) header(geomType uint32, srid int) {
+	b.WriteByte(1)
+	if srid > 0 {
+		geomType |= ewkbSridFlag
+	}
+	binary.Write(b, binary.LittleEndian, geomType)
+	if srid > 0 {
+		binary.Write(b, binary.LittleEndian, uint32(srid))
+	}
+}
+
+func (b *ewkbBuilder) coords(coords ...float64) {
+	binary.Write(b, binary.LittleEndian, uint32(len(coords)/2))
+	for _, c := range coords {
+		binary.Write(b, binary.LittleEndian, math.Float64bits(c))
+	}
+}
+
+func (b *ewkbBuilder) hex() string {
+	return hex.EncodeToString(b.Bytes())
+}
+
+func ewkbLineString(srid int, coords ...float64) *ewkbBuilder {
+	b := &ewkbBuilder{}
+	b.header(wkbLineString, srid)
+	b.coords(coords...)
+	return b
+}
+
+func ewkbPolygon(srid int, rings ...[]float64) *ewkbBuilder {
+	b := &ewkbBuilder{}
+	b.header(wkbPolygon, srid)
+	binary.Write(b, binary.LittleEndian, uint32(len(rings)))
+	for _, ring := range rings {
+		b.coords(ring...)
+	}
+	return b
+}
+
+func ewkbPoint(srid int, x, y float64) *ewkbBuilder {
+	b := &ewkbBuilder{}
+	b.header(wkbPoint, srid)
+	binary.Write(b, binary.LittleEndian, math.Float64bits(x))
+	binary.Write(b, binary.LittleEndian, math.Float64bits(y))
+	return b
+}
+
+func TestWkbNumPoints(t *testing.T) {
+	square := []float64{0, 0, 10, 0, 10, 10, 0, 10, 0, 0}
+	hole := []float64{2, 2, 4, 2, 4, 4, 2, 2}
+
+	multi := &ewkbBuilder{}
+	multi.header(wkbMultiPolygon, 3857)
+	binary.Write(multi, binary.LittleEndian, uint32(2))
+	multi.Write(ewkbPolygon(0, square).Bytes())
+	multi.Write(ewkbPolygon(0, square, hole).Bytes())
+
+	for _, test := range []struct {
+		wkb      []byte
+		expected int
+	}{
+		{ewkbPoint(0, 1, 2).Bytes(), 1},
+		{ewkbPoint(4326, 1, 2).Bytes(), 1},
+		{ewkbLineString(3857, 0, 0, 1, 1, 2, 2).Bytes(), 3},
+		{ewkbPolygon(0, square).Bytes(), 5},
+		{ewkbPolygon(3857, square, hole).Bytes(), 9},
+		{multi.Bytes(), 14},
+	} {
+		n, err := wkbNumPoints(test.wkb)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != test.expected {
+			t.Errorf("%x: %d != %d", test.wkb, n, test.expected)
+		}
+	}
+}
+
+func TestWkbNumPointsZ(t *testing.T) {
+	// LINESTRING Z (0 0 0, 1 1 1) in ISO WKB
+	b := &ewkbBuilder{}
+	b.header(1002, 0)
+	binary.Write(b, binary.LittleEndian, uint32(2))
+	for _, c := range []float64{0, 0, 0, 1, 1, 1} {
+		binary.Write(b, binary.LittleEndian, math.Float64bits(c))
+	}
+	n, err := wkbNumPoints(b.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Error("unexpected number of points", n)
+	}
+}
+
+func TestWkbNumPointsInvalid(t *testing.T) {
+	wkb := ewkbLineString(3857, 0, 0, 1, 1, 2, 2).Bytes()
+	if _, err := wkbNumPoints(wkb[:len(wkb)-4]); err != errWkbTruncated {
+		t.Error("expected truncated error", err)
+	}
+	if _, err := wkbNumPoints([]byte{5, 1, 0, 0, 0}); err == nil {
+		t.Error("expected error for invalid byte order")
+	}
+	if _, err := wkbNumPoints([]byte{1, 42, 0, 0, 0}); err == nil {
+		t.Error("expected error for invalid type")
+	}
+}
+
+func TestEwkbHexWithSrid(t *testing.T) {
+	withSrid := ewkbLineString(4326, 0, 0, 1, 1).hex()
+	withoutSrid := ewkbLineString(0, 0, 0, 1, 1)
+
+	for _, test := range []struct {
+		value    interface{}
+		expected interface{}
+	}{
+		{nil, nil},
+		// unchanged
+		{withSrid, withSrid},
+		// hex WKB
+		{withoutSrid.hex(), withSrid},
+		{[]byte(withoutSrid.hex()), withSrid},
+		// binary WKB
+		{withoutSrid.Bytes(), withSrid},
+		// binary EWKB keeps SRID
+		{ewkbLineString(3857, 0, 0, 1, 1).Bytes(), ewkbLineString(3857, 0, 0, 1, 1).hex()},
+	} {
+		v, err := ewkbHexWithSrid(test.value, 4326)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v != test.expected {
+			t.Errorf("%v: %v != %v", test.value, v, test.expected)
+		}
+	}
+}
+
+func TestEwkbHexWithSridBigEndianZ(t *testing.T) {
+	// POINT Z (1 2 3) as big endian ISO WKB
+	b := &bytes.Buffer{}
+	b.WriteByte(0)
+	binary.Write(b, binary.BigEndian, uint32(1001))
+	for _, c := range []float64{1, 2, 3} {
+		binary.Write(b, binary.BigEndian, math.Float64bits(c))
+	}
+	v, err := ewkbHexWithSrid(b.Bytes(), 3857)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wkb, err := hex.DecodeString(v.(string))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := decodeWkb(wkb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.geomType != wkbPoint || !g.hasZ || g.hasM || g.srid != 3857 {
+		t.Errorf("unexpected geometry %#v", g)
+	}
+	if len(g.rings[0]) != 3 || g.rings[0][2] != 3 {
+		t.Error("unexpected coords", g.rings[0])
+	}
+}
+
+func TestEwkbHexWithSridInvalid(t *testing.T) {
+	if _, err := ewkbHexWithSrid("zz", 4326); err == nil {
+		t.Error("expected error for invalid hex")
+	}
+	if _, err := ewkbHexWithSrid([]byte{1, 42, 0, 0, 0}, 4326); err == nil {
+		t.Error("expected error for invalid type")
+	}
+	if _, err := ewkbHexWithSrid(42, 4326); err == nil {
+		t.Error("expected error for invalid value")
+	}
+}
@@ -0,0 +1,136 @@
+package postgis
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// SelectBatch returns the rows of a table for re-processing or migration,
+// with the values of the columns of the mapping in the order of the rows
+// of Insert. Geometries are returned as WKB ([]byte, without SRID) and
+// other values as returned by the driver. where is an optional SQL
+// condition. limit and offset select a batch of the rows (limit of 0 for
+// all rows), the rows are ordered by the id, so that all rows are
+// returned once with subsequent batches.
+func (pg *PostGIS) SelectBatch(table, where string, limit, offset int) ([][]interface{}, error) {
+	spec, ok := pg.Tables[table]
+	if !ok {
+		return nil, errors.New("unknown table " + table)
+	}
+	sql := spec.SelectSQL(where, limit, offset)
+	rows, err := pg.Db.Query(sql)
+	if err != nil {
+		return nil, &SQLError{sql, err}
+	}
+	defer rows.Close()
+
+	var result [][]interface{}
+	for rows.Next() {
+		row := make([]interface{}, len(spec.Columns))
+		dest := make([]interface{}, len(row))
+		for i := range row {
+			dest[i] = &row[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, &SQLError{sql, err}
+		}
+		result = append(result, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, &SQLError{sql, err}
+	}
+	return result, nil
+}
+
+// SelectSQL returns the SELECT of the columns for SelectBatch. The rows
+// are ordered by the serial id or by the OSM id column.
+func (spec *TableSpec) SelectSQL(where string, limit, offset int) string {
+	var cols []string
+	for _, col := range spec.Columns {
+		if col.Type.Name() == "GEOMETRY" {
+			cols = append(cols, fmt.Sprintf(`ST_AsBinary("%s") AS "%s"`, col.Name, col.Name))
+		} else {
+			cols = append(cols, "\""+col.Name+"\"")
+		}
+	}
+	sql := fmt.Sprintf(`SELECT %s FROM %s`,
+		strings.Join(cols, ", "),
+		qualifiedTableName(spec.Schema, spec.FullName),
+	)
+	if where != "" {
+		sql += " WHERE " + where
+	}
+	sql += spec.orderByIdSQL()
+	if limit > 0 {
+		sql += fmt.Sprintf(" LIMIT %d", limit)
+	}
+	if offset > 0 {
+		sql += fmt.Sprintf(" OFFSET %d", offset)
+	}
+	return sql
+}
+
+// orderByIdSQL returns the ORDER BY of the serial id or of the OSM id
+// column, or an empty string for tables without id.
+func (spec *TableSpec) orderByIdSQL() string {
+	if spec.hasSerialId() {
+		return ` ORDER BY "id"`
+	} else if idx := spec.idColumnIndex(); idx >= 0 {
+		return fmt.Sprintf(` ORDER BY "%s"`, spec.Columns[idx].Name)
+	}
+	return ""
+}
+
+// ExportNDJSON writes all rows of the table (by name) to w as
+// newline-delimited JSON, one object per row with the columns of the
+// mapping. Geometries are GeoJSON objects (ST_AsGeoJSON) in the SRID of
+// the table. PostgreSQL renders the JSON of each row and the rows are
+// written while they are read, ordered by the id, without loading the
+// table into memory.
+func (pg *PostGIS) ExportNDJSON(table string, w io.Writer) error {
+	spec, ok := pg.Tables[table]
+	if !ok {
+		return errors.New("unknown table " + table)
+	}
+	sql := spec.ExportNDJSONSQL()
+	rows, err := pg.Db.Query(sql)
+	if err != nil {
+		return &SQLError{sql, err}
+	}
+	defer rows.Close()
+
+	var line []byte
+	for rows.Next() {
+		if err := rows.Scan(&line); err != nil {
+			return &SQLError{sql, err}
+		}
+		line = append(line, '\n')
+		if _, err := w.Write(line); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return &SQLError{sql, err}
+	}
+	return nil
+}
+
+// ExportNDJSONSQL returns the query for ExportNDJSON with the JSON text of
+// each row.
+func (spec *TableSpec) ExportNDJSONSQL() string {
+	var cols []string
+	for _, col := range spec.Columns {
+		if col.Type.Name() == "GEOMETRY" {
+			cols = append(cols, fmt.Sprintf(`ST_AsGeoJSON("%s")::json AS "%s"`, col.Name, col.Name))
+		} else {
+			cols = append(cols, "\""+col.Name+"\"")
+		}
+	}
+	return fmt.Sprintf(`SELECT row_to_json(r)::text FROM (SELECT %s FROM %s%s) AS r`,
+		strings.Join(cols, ", "),
+		qualifiedTableName(spec.Schema, spec.FullName),
+		spec.orderByIdSQL(),
+	)
+}
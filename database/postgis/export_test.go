@@ -0,0 +1,129 @@
+package postgis
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"testing"
+)
+
+func TestSelectSQL(t *testing.T) {
+	spec := testTableSpec(t, testPostGIS(), testTable())
+
+	expected := `SELECT "osm_id", ST_AsBinary("geometry") AS "geometry", "name", "tags" FROM "import"."osm_roads" ORDER BY "id"`
+	if sql := spec.SelectSQL("", 0, 0); sql != expected {
+		t.Errorf("unexpected SQL\n%s\n%s", sql, expected)
+	}
+	expected = `SELECT "osm_id", ST_AsBinary("geometry") AS "geometry", "name", "tags" FROM "import"."osm_roads" WHERE name = 'Main' ORDER BY "id" LIMIT 100 OFFSET 200`
+	if sql := spec.SelectSQL("name = 'Main'", 100, 200); sql != expected {
+		t.Errorf("unexpected SQL\n%s\n%s", sql, expected)
+	}
+
+	// ordered by the OSM id without serial id
+	table := testTable()
+	table.Fields[0].Name = "id"
+	spec = testTableSpec(t, testPostGIS(), table)
+	expected = `SELECT "id", ST_AsBinary("geometry") AS "geometry", "name", "tags" FROM "import"."osm_roads" ORDER BY "id" LIMIT 10`
+	if sql := spec.SelectSQL("", 10, 0); sql != expected {
+		t.Errorf("unexpected SQL\n%s\n%s", sql, expected)
+	}
+}
+
+func TestSelectBatch(t *testing.T) {
+	db, d := newFakeDb()
+	defer db.Close()
+	pg := testPostGIS()
+	pg.Db = db
+	pg.Tables = map[string]*TableSpec{"roads": testTableSpec(t, pg, testTable())}
+
+	wkb := ewkbLineString(0, 0, 0, 10, 20).Bytes()
+	d.results = map[string]fakeResult{
+		"SELECT \"osm_id\"": {
+			columns: []string{"osm_id", "geometry", "name", "tags"},
+			rows: [][]driver.Value{
+				{int64(1), wkb, "Main", nil},
+				{int64(2), nil, "", nil},
+			},
+		},
+	}
+	rows, err := pg.SelectBatch("roads", "", 2, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 || rows[0][0] != int64(1) || rows[0][2] != "Main" {
+		t.Fatal("unexpected rows", rows)
+	}
+	g, err := decodeWkb(rows[0][1].([]byte))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.numPoints() != 2 {
+		t.Error("unexpected points", g.numPoints())
+	}
+	if minx, miny, maxx, maxy := g.bounds(); minx != 0 || miny != 0 || maxx != 10 || maxy != 20 {
+		t.Error("unexpected bounds", minx, miny, maxx, maxy)
+	}
+	if rows[1][1] != nil {
+		t.Error("expected NULL geometry", rows[1][1])
+	}
+
+	if _, err := pg.SelectBatch("unknown", "", 0, 0); err == nil {
+		t.Error("expected error for unknown table")
+	}
+}
+
+func TestExportNDJSON(t *testing.T) {
+	spec := testTableSpec(t, testPostGIS(), testTable())
+	expected := `SELECT row_to_json(r)::text FROM (SELECT "osm_id", ST_AsGeoJSON("geometry")::json AS "geometry", "name", "tags" FROM "import"."osm_roads" ORDER BY "id") AS r`
+	if sql := spec.ExportNDJSONSQL(); sql != expected {
+		t.Errorf("unexpected SQL\n%s\n%s", sql, expected)
+	}
+
+	db, d := newFakeDb()
+	defer db.Close()
+	pg := testPostGIS()
+	pg.Db = db
+	pg.Tables = map[string]*TableSpec{"roads": spec}
+	d.results = map[string]fakeResult{
+		"SELECT row_to_json": {
+			columns: []string{"row_to_json"},
+			rows: [][]driver.Value{
+				{`{"osm_id":1,"geometry":{"type":"LineString","coordinates":[[0,0],[10,20]]},"name":"Main","tags":null}`},
+				{[]byte(`{"osm_id":2,"geometry":null,"name":"","tags":null}`)},
+			},
+		},
+	}
+	var buf bytes.Buffer
+	if err := pg.ExportNDJSON("roads", &buf); err != nil {
+		t.Fatal(err)
+	}
+	lines := bytes.Split(buf.Bytes(), []byte("\n"))
+	if len(lines) != 3 || len(lines[2]) != 0 {
+		t.Fatalf("expected two lines, got %q", buf.String())
+	}
+	var row struct {
+		OsmId    int64 `json:"osm_id"`
+		Geometry *struct {
+			Type        string      `json:"type"`
+			Coordinates [][]float64 `json:"coordinates"`
+		} `json:"geometry"`
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(lines[0], &row); err != nil {
+		t.Fatal(err)
+	}
+	if row.OsmId != 1 || row.Name != "Main" || row.Geometry == nil || row.Geometry.Type != "LineString" || len(row.Geometry.Coordinates) != 2 {
+		t.Error("unexpected row", string(lines[0]))
+	}
+	row.Geometry = nil
+	if err := json.Unmarshal(lines[1], &row); err != nil {
+		t.Fatal(err)
+	}
+	if row.OsmId != 2 || row.Geometry != nil {
+		t.Error("unexpected row", string(lines[1]))
+	}
+
+	if err := pg.ExportNDJSON("unknown", &buf); err == nil {
+		t.Error("expected error for unknown table")
+	}
+}
@@ -0,0 +1,213 @@
+package postgis
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// fakeDriver is a database/sql driver that records all statements,
+// for tests of the TableTx without PostgreSQL.
+type fakeDriver struct {
+	mu        sync.Mutex
+	execs     []string
+	args      [][]driver.Value
+	commits   int
+	rollbacks int
+	// queries of all closed statements
+	closed []string
+	// statements with this prefix fail
+	fail string
+	// error of failed statements, instead of an error of the fake driver
+	failErr error
+	// statements with this prefix fail already in Prepare
+	failPrepare string
+	// results of queries with the prefix
+	results map[string]fakeResult
+	// the commit with this number fails, counted from 1
+	failCommit int
+}
+
+// fakeResult are the rows of a query.
+type fakeResult struct {
+	columns []string
+	rows    [][]driver.Value
+	// returns the rows for the args of the query, if set
+	rowsFunc func(args []driver.Value) [][]driver.Value
+}
+
+var fakeDrivers = struct {
+	sync.Mutex
+	n int
+}{}
+
+// newFakeDb returns a new *sql.DB with its own fakeDriver.
+func newFakeDb() (*sql.DB, *fakeDriver) {
+	fakeDrivers.Lock()
+	fakeDrivers.n += 1
+	name := fmt.Sprintf("imposm3-fake-%d", fakeDrivers.n)
+	fakeDrivers.Unlock()
+
+	d := &fakeDriver{}
+	sql.Register(name, d)
+	db, err := sql.Open(name, "")
+	if err != nil {
+		panic(err)
+	}
+	return db, d
+}
+
+func (d *fakeDriver) Open(name string) (driver.Conn, error) {
+	return &fakeConn{d}, nil
+}
+
+// Connect and Driver implement driver.Connector, to test connectors that
+// wrap the lib/pq connector.
+func (d *fakeDriver) Connect(ctx context.Context) (driver.Conn, error) {
+	return d.Open("")
+}
+
+func (d *fakeDriver) Driver() driver.Driver {
+	return d
+}
+
+func (d *fakeDriver) failError() error {
+	if d.failErr != nil {
+		return d.failErr
+	}
+	return errors.New("failed by fake driver")
+}
+
+// count returns the number of executions of statements with prefix.
+func (d *fakeDriver) count(prefix string) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	n := 0
+	for _, e := range d.execs {
+		if strings.HasPrefix(e, prefix) {
+			n += 1
+		}
+	}
+	return n
+}
+
+// values returns the arg at idx of all executions of statements with
+// prefix.
+func (d *fakeDriver) values(prefix string, idx int) []driver.Value {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var values []driver.Value
+	for i, e := range d.execs {
+		if strings.HasPrefix(e, prefix) && idx < len(d.args[i]) {
+			values = append(values, d.args[i][idx])
+		}
+	}
+	return values
+}
+
+type fakeConn struct {
+	d *fakeDriver
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	c.d.mu.Lock()
+	defer c.d.mu.Unlock()
+	if c.d.failPrepare != "" && strings.HasPrefix(query, c.d.failPrepare) {
+		return nil, c.d.failError()
+	}
+	return &fakeStmt{c.d, query}, nil
+}
+
+func (c *fakeConn) Close() error { return nil }
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return &fakeTx{c.d}, nil
+}
+
+type fakeTx struct {
+	d *fakeDriver
+}
+
+func (tx *fakeTx) Commit() error {
+	tx.d.mu.Lock()
+	defer tx.d.mu.Unlock()
+	tx.d.commits += 1
+	if tx.d.commits == tx.d.failCommit {
+		return tx.d.failError()
+	}
+	return nil
+}
+
+func (tx *fakeTx) Rollback() error {
+	tx.d.mu.Lock()
+	tx.d.rollbacks += 1
+	tx.d.mu.Unlock()
+	return nil
+}
+
+type fakeStmt struct {
+	d     *fakeDriver
+	query string
+}
+
+func (s *fakeStmt) Close() error {
+	s.d.mu.Lock()
+	s.d.closed = append(s.d.closed, s.query)
+	s.d.mu.Unlock()
+	return nil
+}
+
+func (s *fakeStmt) NumInput() int { return -1 }
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	s.d.execs = append(s.d.execs, s.query)
+	s.d.args = append(s.d.args, args)
+	if s.d.fail != "" && strings.HasPrefix(s.query, s.d.fail) {
+		return nil, s.d.failError()
+	}
+	return driver.RowsAffected(1), nil
+}
+
+// Query returns the result for the first matching prefix of results.
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	s.d.execs = append(s.d.execs, s.query)
+	s.d.args = append(s.d.args, args)
+	if s.d.fail != "" && strings.HasPrefix(s.query, s.d.fail) {
+		return nil, s.d.failError()
+	}
+	for prefix, result := range s.d.results {
+		if strings.HasPrefix(s.query, prefix) {
+			if result.rowsFunc != nil {
+				result.rows = result.rowsFunc(args)
+			}
+			return &fakeRows{result: result}, nil
+		}
+	}
+	return nil, errors.New("no result for query in fake driver")
+}
+
+type fakeRows struct {
+	result fakeResult
+	pos    int
+}
+
+func (r *fakeRows) Columns() []string { return r.result.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.pos >= len(r.result.rows) {
+		return io.EOF
+	}
+	copy(dest, r.result.rows[r.pos])
+	r.pos += 1
+	return nil
+}
@@ -2,6 +2,7 @@ package postgis
 
 import (
 	"fmt"
+	"strings"
 )
 
 type ColumnType interface {
@@ -20,7 +21,7 @@ func (t *simpleColumnType) Name() string {
 }
 
 func (t *simpleColumnType) PrepareInsertSql(i int, spec *TableSpec) string {
-	return fmt.Sprintf("$%d", i)
+	return spec.dialect().Placeholder(i)
 }
 
 func (t *simpleColumnType) GeneralizeSql(colSpec *ColumnSpec, spec *GeneralizedTableSpec) string {
@@ -32,7 +33,26 @@ type hstoreColumnType struct {
 }
 
 func (t *hstoreColumnType) PrepareInsertSql(i int, spec *TableSpec) string {
-	return fmt.Sprintf("$%d::hstore", i)
+	d := spec.dialect()
+	return d.Cast(d.Placeholder(i), "hstore")
+}
+
+type uuidColumnType struct {
+	simpleColumnType
+}
+
+func (t *uuidColumnType) PrepareInsertSql(i int, spec *TableSpec) string {
+	d := spec.dialect()
+	return d.Cast(d.Placeholder(i), "uuid")
+}
+
+type tstzrangeColumnType struct {
+	simpleColumnType
+}
+
+func (t *tstzrangeColumnType) PrepareInsertSql(i int, spec *TableSpec) string {
+	d := spec.dialect()
+	return d.Cast(d.Placeholder(i), "tstzrange")
 }
 
 type geometryType struct {
@@ -44,9 +64,30 @@ func (t *geometryType) Name() string {
 }
 
 func (t *geometryType) PrepareInsertSql(i int, spec *TableSpec) string {
-	return fmt.Sprintf("$%d::Geometry",
-		i,
-	)
+	d := spec.dialect()
+	geom := d.Cast(d.Placeholder(i), "Geometry")
+	switch spec.GeometryEncoding {
+	case GeometryEncodingEwkb:
+		geom = d.GeomFromWKB(d.Placeholder(i), 0)
+	case GeometryEncodingWkt:
+		geom = d.GeomFromText(d.Placeholder(i), spec.inputSrid())
+	}
+	return spec.insertGeometrySQL(geom)
+}
+
+// insertGeometrySQL returns the geometry transformed to the SRID of the
+// table and reduced to the GridSize.
+func (spec *TableSpec) insertGeometrySQL(geom string) string {
+	if spec.transformGeometry() && spec.TransformPipeline != "" {
+		geom = fmt.Sprintf("ST_TransformPipeline(%s, '%s', %d)",
+			geom, strings.Replace(spec.TransformPipeline, "'", "''", -1), spec.Srid,
+		)
+	} else if spec.transformGeometry() {
+		geom = fmt.Sprintf("ST_Transform(%s, %d)",
+			geom, spec.Srid,
+		)
+	}
+	return spec.subtypeGeometrySQL(spec.gridGeometrySQL(geom))
 }
 
 func (t *geometryType) GeneralizeSql(colSpec *ColumnSpec, spec *GeneralizedTableSpec) string {
@@ -80,6 +121,9 @@ func init() {
 		"int64":              &simpleColumnType{"BIGINT"},
 		"float32":            &simpleColumnType{"REAL"},
 		"hstore_string":      &simpleColumnType{"HSTORE"},
+		"jsonb_string":       &simpleColumnType{"JSONB"},
+		"uuid":               &uuidColumnType{simpleColumnType{"UUID"}},
+		"tstzrange":          &tstzrangeColumnType{simpleColumnType{"TSTZRANGE"}},
 		"geometry":           &geometryType{"GEOMETRY"},
 		"validated_geometry": &validatedGeometryType{geometryType{"GEOMETRY"}},
 	}
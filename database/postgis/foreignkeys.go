@@ -0,0 +1,242 @@
+package postgis
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	pq "github.com/lib/pq"
+)
+
+// Tables with foreign_key reference the id column of a parent table, e.g.
+// relation member tables the osm_id of the relation table, so that diff
+// imports only need to delete the relation: the members are deleted with
+// ON DELETE CASCADE. The parent table is created with a UNIQUE id column,
+// it needs to have a single row for each id. Finish adds the foreign keys
+// with NOT VALID after the load and validates them like the CHECK
+// constraints of DeferConstraintValidation. The foreign keys are
+// DEFERRABLE INITIALLY DEFERRED, so that diff imports can insert the rows
+// of the tables in any order within the transaction.
+//
+// PostgreSQL keeps the foreign keys when Deploy moves the tables to
+// another schema, as long as both tables are moved. Parent and child need
+// the same schemas for this, and tables with foreign keys are dropped
+// and rotated before their parent tables. Foreign keys are not supported
+// for tables with inherits.
+
+// ForeignKey is the foreign key from Column to the id column of Parent.
+type ForeignKey struct {
+	Column string
+	Parent *TableSpec
+}
+
+// prepareForeignKeys sets ForeignKey of all tables with foreign_key and
+// returns all problems.
+func (pg *PostGIS) prepareForeignKeys() error {
+	var errs TableSpecErrors
+	for name, spec := range pg.Tables {
+		if spec.foreignKey == nil {
+			continue
+		}
+		parent, ok := pg.Tables[spec.foreignKey.Table]
+		if !ok {
+			errs = append(errs, &TableSpecError{name, []string{
+				fmt.Sprintf("unknown foreign_key table %s", spec.foreignKey.Table)}})
+			continue
+		}
+		if problems := checkForeignKey(pg, spec, spec.foreignKey.Column, parent); len(problems) > 0 {
+			errs = append(errs, &TableSpecError{name, problems})
+			continue
+		}
+		spec.ForeignKey = &ForeignKey{spec.foreignKey.Column, parent}
+	}
+	if len(errs) > 0 {
+		sort.Sort(errs)
+		return errs
+	}
+	for _, spec := range pg.Tables {
+		if spec.ForeignKey != nil {
+			parent := spec.ForeignKey.Parent
+			parent.referencedBy = append(parent.referencedBy, spec)
+		}
+	}
+	for _, spec := range pg.Tables {
+		sort.Sort(tablesByName(spec.referencedBy))
+	}
+	return nil
+}
+
+// checkForeignKey returns all problems of the foreign key from column of
+// spec to parent.
+func checkForeignKey(pg *PostGIS, spec *TableSpec, column string, parent *TableSpec) []string {
+	var problems []string
+	colIdx := -1
+	for i, col := range spec.Columns {
+		if col.Name == column {
+			colIdx = i
+		}
+	}
+	parentIdx := parent.idColumnIndex()
+	switch {
+	case colIdx < 0:
+		problems = append(problems, fmt.Sprintf("unknown foreign_key column %s", column))
+	case parent == spec:
+		problems = append(problems, "foreign_key references the table itself")
+	case parentIdx < 0:
+		problems = append(problems, fmt.Sprintf("foreign_key table %s has no id column", parent.Name))
+	case spec.Columns[colIdx].Type.Name() != parent.Columns[parentIdx].Type.Name():
+		problems = append(problems, fmt.Sprintf("foreign_key column %s is %s, but id column of %s is %s",
+			column, spec.Columns[colIdx].Type.Name(), parent.Name, parent.Columns[parentIdx].Type.Name()))
+	}
+	if parent.foreignKey != nil {
+		problems = append(problems, fmt.Sprintf("foreign_key table %s has a foreign_key itself", parent.Name))
+	}
+	if spec.inherits != "" || spec.hasChildren || parent.inherits != "" || parent.hasChildren {
+		problems = append(problems, "foreign_key is not supported for tables with inherits")
+	}
+	if spec.schemas != parent.schemas {
+		problems = append(problems, fmt.Sprintf("foreign_key table %s needs the same schema", parent.Name))
+	}
+	if parent.Subdivide > 0 {
+		// the id of the parent needs to be unique
+		problems = append(problems, fmt.Sprintf("foreign_key table %s can not use subdivide", parent.Name))
+	}
+	if pg.Config.SoftDelete {
+		problems = append(problems, "foreign_key requires deletes, not SoftDelete")
+	}
+	return problems
+}
+
+type tablesByName []*TableSpec
+
+func (t tablesByName) Len() int           { return len(t) }
+func (t tablesByName) Less(i, j int) bool { return t[i].Name < t[j].Name }
+func (t tablesByName) Swap(i, j int)      { t[i], t[j] = t[j], t[i] }
+
+// dependencyDepth returns the inheritanceDepth, or 1 for tables with a
+// foreign key, as these need to be dropped before their parent tables.
+func (spec *TableSpec) dependencyDepth() int {
+	if spec.ForeignKey != nil {
+		return 1
+	}
+	return spec.inheritanceDepth()
+}
+
+func (fk *ForeignKey) name(spec *TableSpec) string {
+	return truncateIdentifier(fmt.Sprintf("%s_%s_fkey", spec.FullName, fk.Column))
+}
+
+// addSQL returns the statement that adds the foreign key to the table of
+// spec without checking the existing rows.
+func (fk *ForeignKey) addSQL(spec *TableSpec) string {
+	parent := fk.Parent
+	return fmt.Sprintf(`ALTER TABLE "%s"."%s" ADD CONSTRAINT "%s" FOREIGN KEY ("%s") `+
+		`REFERENCES "%s"."%s" ("%s") ON DELETE CASCADE DEFERRABLE INITIALLY DEFERRED NOT VALID`,
+		spec.Schema, spec.FullName, fk.name(spec), fk.Column,
+		parent.Schema, parent.FullName, parent.Columns[parent.idColumnIndex()].Name)
+}
+
+func (fk *ForeignKey) validateSQL(spec *TableSpec) string {
+	return fmt.Sprintf(`ALTER TABLE "%s"."%s" VALIDATE CONSTRAINT "%s"`,
+		spec.Schema, spec.FullName, fk.name(spec))
+}
+
+// isForeignKeyViolation returns whether err is a foreign_key_violation.
+func isForeignKeyViolation(err error) bool {
+	pqErr, ok := err.(*pq.Error)
+	return ok && pqErr.Code == "23503"
+}
+
+// addForeignKeys adds and validates the foreign keys of all tables, one
+// after another as they lock the parent tables. Rows without parent row
+// are reported as ConstraintViolationError (or logged with
+// WarnConstraintViolations, the foreign key stays NOT VALID).
+func (pg *PostGIS) addForeignKeys() error {
+	var names []string
+	for name, spec := range pg.Tables {
+		if spec.ForeignKey != nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := pg.addForeignKey(pg.Tables[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (pg *PostGIS) addForeignKey(spec *TableSpec) error {
+	fk := spec.ForeignKey
+	sql := fk.addSQL(spec)
+	if _, err := pg.Db.Exec(sql); err != nil {
+		return &SQLError{sql, err}
+	}
+	start := time.Now()
+	sql = fk.validateSQL(spec)
+	_, err := pg.Db.Exec(sql)
+	pg.addConstraintDuration(spec.FullName+"."+fk.name(spec), time.Since(start))
+	if err == nil {
+		log.Printf("validated foreign key %s of %s in %s", fk.name(spec), spec.FullName, time.Since(start))
+		return nil
+	}
+	if !isForeignKeyViolation(err) {
+		return &SQLError{sql, err}
+	}
+	parent := fk.Parent
+	orphans, sample, err := pg.CheckReferences(spec.Name, fk.Column, parent.Name, parent.Columns[parent.idColumnIndex()].Name)
+	if err != nil {
+		return err
+	}
+	violations := &ConstraintViolationError{spec.FullName, fk.name(spec), orphans, sample}
+	if pg.Config.WarnConstraintViolations {
+		log.Warnf("%s, foreign key stays NOT VALID", violations)
+		return nil
+	}
+	return violations
+}
+
+// cascadedRowsSQL returns the query for the number of rows of the table
+// with the foreign key that reference the id $1.
+func (fk *ForeignKey) cascadedRowsSQL(spec *TableSpec) string {
+	return fmt.Sprintf(`SELECT count(*) FROM "%s"."%s" WHERE "%s" = $1`,
+		spec.Schema, spec.FullName, fk.Column)
+}
+
+// cascadedRows returns the number of rows of all tables with a foreign
+// key to the table of tt that reference the id, i.e. the rows that a
+// delete of the id removes with ON DELETE CASCADE.
+func (tt *syncTableTx) cascadedRows(id int64) (int64, error) {
+	var rows int64
+	for _, child := range tt.tableSpec.referencedBy {
+		sql := child.ForeignKey.cascadedRowsSQL(child)
+		var n int64
+		if err := tt.Tx.QueryRow(sql, id).Scan(&n); err != nil {
+			return 0, &SQLError{sql, err}
+		}
+		rows += n
+	}
+	return rows, nil
+}
+
+// ReferencedTableError is returned for drops of a table that is still
+// referenced by a foreign key of another table, e.g. a relation member
+// table that is not part of the mapping anymore.
+type ReferencedTableError struct {
+	Schema string
+	Table  string
+	err    error
+}
+
+func (e *ReferencedTableError) Error() string {
+	return fmt.Sprintf("unable to drop %s.%s, other tables reference it with a foreign key "+
+		"and need to be dropped first: %s", e.Schema, e.Table, e.err)
+}
+
+// isDependentObjectsStillExist returns whether err is a
+// dependent_objects_still_exist error.
+func isDependentObjectsStillExist(err error) bool {
+	pqErr, ok := err.(*pq.Error)
+	return ok && pqErr.Code == "2BP01"
+}
@@ -0,0 +1,181 @@
+package postgis
+
+import (
+	"database/sql/driver"
+	"reflect"
+	"strings"
+	"testing"
+
+	pq "github.com/lib/pq"
+
+	"github.com/omniscale/imposm3/mapping"
+)
+
+func testForeignKeyTables(t *testing.T, pg *PostGIS) {
+	members := RelationMemberTable("road_members")
+	members.ForeignKey = &mapping.ForeignKey{Column: "relation_id", Table: "roads"}
+	pg.Tables = map[string]*TableSpec{
+		"roads":        testTableSpec(t, pg, testTable()),
+		"road_members": testTableSpec(t, pg, members),
+	}
+	if err := pg.prepareForeignKeys(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestForeignKeySQL(t *testing.T) {
+	pg := testPostGIS()
+	testForeignKeyTables(t, pg)
+	members := pg.Tables["road_members"]
+
+	if sql := pg.Tables["roads"].CreateTableSQL(); !strings.Contains(sql, `UNIQUE ("osm_id")`) {
+		t.Error("missing UNIQUE id of referenced table", sql)
+	}
+	if sql := members.CreateTableSQL(); strings.Contains(sql, "UNIQUE") || strings.Contains(sql, "FOREIGN KEY") {
+		t.Error("unexpected sql", sql)
+	}
+	expected := `ALTER TABLE "import"."osm_road_members" ADD CONSTRAINT "osm_road_members_relation_id_fkey" FOREIGN KEY ("relation_id") REFERENCES "import"."osm_roads" ("osm_id") ON DELETE CASCADE DEFERRABLE INITIALLY DEFERRED NOT VALID`
+	if sql := members.ForeignKey.addSQL(members); sql != expected {
+		t.Errorf("unexpected SQL\n%s\n%s", sql, expected)
+	}
+	expected = `ALTER TABLE "import"."osm_road_members" VALIDATE CONSTRAINT "osm_road_members_relation_id_fkey"`
+	if sql := members.ForeignKey.validateSQL(members); sql != expected {
+		t.Errorf("unexpected SQL\n%s\n%s", sql, expected)
+	}
+
+	// rotated and dropped before the referenced table
+	var names []string
+	for _, table := range pg.deployTables() {
+		names = append(names, table.name)
+	}
+	if s := strings.Join(names, ","); s != "osm_road_members,osm_roads" {
+		t.Error("unexpected deploy order", s)
+	}
+}
+
+func TestForeignKeyProblems(t *testing.T) {
+	for _, tc := range []struct {
+		fk      mapping.ForeignKey
+		prepare func(pg *PostGIS, roads *mapping.Table)
+		problem string
+	}{
+		{mapping.ForeignKey{Column: "relation_id", Table: "unknown"}, nil, "unknown foreign_key table unknown"},
+		{mapping.ForeignKey{Column: "way_id", Table: "roads"}, nil, "unknown foreign_key column way_id"},
+		{mapping.ForeignKey{Column: "role", Table: "roads"}, nil, "foreign_key column role is VARCHAR, but id column of roads is BIGINT"},
+		{mapping.ForeignKey{Column: "relation_id", Table: "roads"},
+			func(pg *PostGIS, roads *mapping.Table) { roads.Subdivide = 100 },
+			"foreign_key table roads can not use subdivide"},
+		{mapping.ForeignKey{Column: "relation_id", Table: "roads"},
+			func(pg *PostGIS, roads *mapping.Table) { pg.Config.SoftDelete = true },
+			"foreign_key requires deletes, not SoftDelete"},
+	} {
+		pg := testPostGIS()
+		roads := testTable()
+		if tc.prepare != nil {
+			tc.prepare(pg, roads)
+		}
+		members := RelationMemberTable("road_members")
+		fk := tc.fk
+		members.ForeignKey = &fk
+		pg.Tables = map[string]*TableSpec{
+			"roads":        testTableSpec(t, pg, roads),
+			"road_members": testTableSpec(t, pg, members),
+		}
+		if err := pg.prepareForeignKeys(); err == nil || !strings.Contains(err.Error(), tc.problem) {
+			t.Errorf("expected %q, got %v", tc.problem, err)
+		}
+	}
+}
+
+func TestAddForeignKeys(t *testing.T) {
+	db, d := newFakeDb()
+	defer db.Close()
+	pg := testPostGIS()
+	pg.Db = db
+	testForeignKeyTables(t, pg)
+
+	if err := pg.addForeignKeys(); err != nil {
+		t.Fatal(err)
+	}
+	if d.count("ALTER TABLE") != 2 || !strings.Contains(d.execs[0], "ON DELETE CASCADE") || !strings.Contains(d.execs[1], "VALIDATE CONSTRAINT") {
+		t.Error("unexpected statements", d.execs)
+	}
+	if _, ok := pg.ConstraintDurations()["osm_road_members.osm_road_members_relation_id_fkey"]; !ok {
+		t.Error("missing duration", pg.ConstraintDurations())
+	}
+
+	d.fail = `ALTER TABLE "import"."osm_road_members" VALIDATE`
+	d.failErr = &pq.Error{Code: "23503"}
+	d.results = map[string]fakeResult{
+		`SELECT c."relation_id"::text`: {
+			columns: []string{"relation_id", "count"},
+			rows:    [][]driver.Value{{"-1001", int64(2)}, {"-1002", int64(2)}},
+		},
+	}
+	err := pg.addForeignKeys()
+	violations, ok := err.(*ConstraintViolationError)
+	if !ok {
+		t.Fatal("expected ConstraintViolationError", err)
+	}
+	if violations.Violations != 2 || !reflect.DeepEqual(violations.Sample, []string{"-1001", "-1002"}) {
+		t.Error("unexpected violations", violations)
+	}
+
+	pg.Config.WarnConstraintViolations = true
+	if err := pg.addForeignKeys(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestReplaceBatchCascaded(t *testing.T) {
+	db, d := newFakeDb()
+	defer db.Close()
+	pg := testPostGIS()
+	pg.Db = db
+	testForeignKeyTables(t, pg)
+	d.results = map[string]fakeResult{
+		"SELECT count(*) FROM": {columns: []string{"count"}, rows: [][]driver.Value{{int64(3)}}},
+	}
+
+	elems := []ElementRows{{Id: 1}}
+	if err := pg.ReplaceBatch("roads", elems); err != nil {
+		t.Fatal(err)
+	}
+	if d.count(`SELECT count(*) FROM "import"."osm_road_members" WHERE "relation_id" = $1`) != 1 {
+		t.Error("missing count of cascaded rows", d.execs)
+	}
+	// the fake driver returns 1 for all RowsAffected
+	if elems[0].Deleted != 1 || elems[0].Cascaded != 3 {
+		t.Error("unexpected counts", elems[0])
+	}
+
+	// no cascades from the table with the foreign key
+	d.execs = nil
+	elems = []ElementRows{{Id: 1}}
+	if err := pg.ReplaceBatch("road_members", elems); err != nil {
+		t.Fatal(err)
+	}
+	if d.count("SELECT count(*)") != 0 || elems[0].Cascaded != 0 {
+		t.Error("unexpected cascaded rows", d.execs, elems[0])
+	}
+}
+
+func TestReferencedTableError(t *testing.T) {
+	db, d := newFakeDb()
+	defer db.Close()
+	d.results = map[string]fakeResult{
+		"SELECT EXISTS": {columns: []string{"exists"}, rows: [][]driver.Value{{true}}},
+	}
+	d.fail = "SELECT DropGeometryTable"
+	d.failErr = &pq.Error{Code: "2BP01"}
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	err = dropTableIfExists(tx, "import", "osm_roads")
+	if _, ok := err.(*ReferencedTableError); !ok || !strings.Contains(err.Error(), "unable to drop import.osm_roads, other tables reference it") {
+		t.Error("unexpected error", err)
+	}
+}
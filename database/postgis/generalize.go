@@ -0,0 +1,119 @@
+package postgis
+
+import (
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// Generalize builds the generalized tables in levels: the first level
+// contains all tables of non-generalized sources, each following level
+// the tables whose source is in the level before. Tables of a level are
+// built in parallel by Config.GeneralizeWorkers workers, each in its own
+// transaction and thus on its own connection. All tables of a level are
+// built, even if some of them fail. Tables with a failed source are
+// skipped and all errors are returned together.
+
+// GeneralizeTableError is the error of a single generalized table.
+type GeneralizeTableError struct {
+	Table string
+	Err   error
+}
+
+func (e *GeneralizeTableError) Error() string {
+	return fmt.Sprintf("generalized table %s: %s", e.Table, e.Err)
+}
+
+// GeneralizeTableErrors contains the errors of all generalized tables
+// that failed or that were skipped.
+type GeneralizeTableErrors []*GeneralizeTableError
+
+func (e GeneralizeTableErrors) Len() int           { return len(e) }
+func (e GeneralizeTableErrors) Less(i, j int) bool { return e[i].Table < e[j].Table }
+func (e GeneralizeTableErrors) Swap(i, j int)      { e[i], e[j] = e[j], e[i] }
+
+func (e GeneralizeTableErrors) Error() string {
+	var lines []string
+	for _, err := range e {
+		lines = append(lines, err.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// generalizeWorkers returns the number of parallel builds of generalized
+// tables, GOMAXPROCS by default.
+func (pg *PostGIS) generalizeWorkers() int {
+	worker := pg.Config.GeneralizeWorkers
+	if worker < 1 {
+		worker = int(runtime.GOMAXPROCS(0))
+	}
+	if worker < 1 {
+		worker = 1
+	}
+	return worker
+}
+
+// generalizedTableLevels returns the generalized tables ordered by their
+// sources (see Generalize), sorted by name within each level. Tables with
+// circular sources are not returned.
+func (pg *PostGIS) generalizedTableLevels() [][]*GeneralizedTableSpec {
+	var levels [][]*GeneralizedTableSpec
+	for _, table := range pg.GeneralizedTables {
+		depth := 0
+		for src := table.SourceGeneralized; src != nil && depth <= len(pg.GeneralizedTables); src = src.SourceGeneralized {
+			depth += 1
+		}
+		if depth > len(pg.GeneralizedTables) {
+			log.Warnf("skipping generalized table %s with circular source", table.Name)
+			continue
+		}
+		for len(levels) <= depth {
+			levels = append(levels, nil)
+		}
+		levels[depth] = append(levels[depth], table)
+	}
+	for _, level := range levels {
+		sort.Sort(generalizedTablesByName(level))
+	}
+	return levels
+}
+
+type generalizedTablesByName []*GeneralizedTableSpec
+
+func (t generalizedTablesByName) Len() int           { return len(t) }
+func (t generalizedTablesByName) Less(i, j int) bool { return t[i].Name < t[j].Name }
+func (t generalizedTablesByName) Swap(i, j int)      { t[i], t[j] = t[j], t[i] }
+
+// generalizeLevels calls generalize for all tables of the levels, with
+// worker parallel calls within each level. Tables are marked as created
+// if generalize succeeds.
+func generalizeLevels(levels [][]*GeneralizedTableSpec, worker int, generalize func(*GeneralizedTableSpec) error) error {
+	var errs GeneralizeTableErrors
+	for _, level := range levels {
+		p := newWorkerPool(worker, len(level))
+		for _, table := range level {
+			if src := table.SourceGeneralized; src != nil && !src.created {
+				errs = append(errs, &GeneralizeTableError{table.Name,
+					fmt.Errorf("skipped, source %s was not created", src.Name)})
+				continue
+			}
+			tbl := table // for following closure
+			p.in <- func() error {
+				if err := generalize(tbl); err != nil {
+					return &GeneralizeTableError{tbl.Name, err}
+				}
+				tbl.created = true
+				return nil
+			}
+		}
+		for _, err := range p.waitAll() {
+			errs = append(errs, err.(*GeneralizeTableError))
+		}
+	}
+	if len(errs) > 0 {
+		sort.Sort(errs)
+		return errs
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package postgis
+
+import (
+	"errors"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testGeneralizedTables returns roads_gen0 and landuse_gen0 of a
+// non-generalized source, roads_gen1 of roads_gen0 and roads_gen2 of
+// roads_gen1.
+func testGeneralizedTables(pg *PostGIS) {
+	roads := &TableSpec{Name: "roads"}
+	gen0 := &GeneralizedTableSpec{Name: "roads_gen0", Source: roads}
+	gen1 := &GeneralizedTableSpec{Name: "roads_gen1", Source: roads, SourceGeneralized: gen0}
+	gen2 := &GeneralizedTableSpec{Name: "roads_gen2", Source: roads, SourceGeneralized: gen1}
+	landuse := &GeneralizedTableSpec{Name: "landuse_gen0", Source: &TableSpec{Name: "landuse"}}
+	pg.GeneralizedTables = map[string]*GeneralizedTableSpec{
+		gen0.Name: gen0, gen1.Name: gen1, gen2.Name: gen2, landuse.Name: landuse,
+	}
+}
+
+func levelNames(levels [][]*GeneralizedTableSpec) string {
+	var names []string
+	for _, level := range levels {
+		var tables []string
+		for _, table := range level {
+			tables = append(tables, table.Name)
+		}
+		names = append(names, strings.Join(tables, ","))
+	}
+	return strings.Join(names, " ")
+}
+
+func TestGeneralizedTableLevels(t *testing.T) {
+	pg := testPostGIS()
+	testGeneralizedTables(pg)
+	if names := levelNames(pg.generalizedTableLevels()); names != "landuse_gen0,roads_gen0 roads_gen1 roads_gen2" {
+		t.Error("unexpected levels", names)
+	}
+
+	// circular sources are skipped
+	gen0 := pg.GeneralizedTables["roads_gen0"]
+	gen0.SourceGeneralized = pg.GeneralizedTables["roads_gen2"]
+	if names := levelNames(pg.generalizedTableLevels()); names != "landuse_gen0" {
+		t.Error("unexpected levels", names)
+	}
+}
+
+func TestGeneralizeWorkers(t *testing.T) {
+	pg := testPostGIS()
+	if n := pg.generalizeWorkers(); n != runtime.GOMAXPROCS(0) {
+		t.Error("unexpected workers", n)
+	}
+	pg.Config.GeneralizeWorkers = 3
+	if n := pg.generalizeWorkers(); n != 3 {
+		t.Error("unexpected workers", n)
+	}
+}
+
+func TestGeneralizeLevelsParallel(t *testing.T) {
+	pg := testPostGIS()
+	testGeneralizedTables(pg)
+
+	var mu sync.Mutex
+	var order []string
+	started := 0
+	firstLevel := make(chan struct{})
+	err := generalizeLevels(pg.generalizedTableLevels(), 2, func(table *GeneralizedTableSpec) error {
+		if table.SourceGeneralized == nil {
+			// both tables of the first level need to run at the same time
+			mu.Lock()
+			started += 1
+			if started == 2 {
+				close(firstLevel)
+			}
+			mu.Unlock()
+			select {
+			case <-firstLevel:
+			case <-time.After(5 * time.Second):
+				return errors.New("not generalized in parallel")
+			}
+		} else if !table.SourceGeneralized.created {
+			return errors.New("source not created")
+		}
+		mu.Lock()
+		order = append(order, table.Name)
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(order) != 4 || order[2] != "roads_gen1" || order[3] != "roads_gen2" {
+		t.Error("unexpected order", order)
+	}
+	for name, table := range pg.GeneralizedTables {
+		if !table.created {
+			t.Error("not created", name)
+		}
+	}
+}
+
+func TestGeneralizeLevelsErrors(t *testing.T) {
+	pg := testPostGIS()
+	testGeneralizedTables(pg)
+
+	var mu sync.Mutex
+	var generalized []string
+	err := generalizeLevels(pg.generalizedTableLevels(), 4, func(table *GeneralizedTableSpec) error {
+		mu.Lock()
+		generalized = append(generalized, table.Name)
+		mu.Unlock()
+		if table.Name == "roads_gen0" {
+			return errors.New("failed")
+		}
+		return nil
+	})
+	errs, ok := err.(GeneralizeTableErrors)
+	if !ok || len(errs) != 3 {
+		t.Fatal("expected three errors", err)
+	}
+	expected := "generalized table roads_gen0: failed\n" +
+		"generalized table roads_gen1: skipped, source roads_gen0 was not created\n" +
+		"generalized table roads_gen2: skipped, source roads_gen1 was not created"
+	if err.Error() != expected {
+		t.Errorf("unexpected error\n%s\n%s", err, expected)
+	}
+	// tables of other sources are still created
+	if len(generalized) != 2 || !pg.GeneralizedTables["landuse_gen0"].created {
+		t.Error("unexpected tables", generalized)
+	}
+}
@@ -0,0 +1,107 @@
+package postgis
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/omniscale/imposm3/mapping"
+)
+
+// Columns with generated are computed by PostgreSQL (GENERATED ALWAYS AS
+// ... STORED, requires PostgreSQL 12), e.g. the area of the geometry.
+// They are added after AddGeometryColumn like the tile_index, as the
+// expression can reference the geometry column. PostgreSQL does not allow
+// values for these columns, so they are not part of Columns and their
+// values are removed from the rows of the mapping (see dropGeometry).
+
+// minGeneratedColumnsVersion is the server_version_num of PostgreSQL 12.
+const minGeneratedColumnsVersion = 120000
+
+// GeneratedColumnsSQL returns the statements that add the generated
+// columns.
+func (spec *TableSpec) GeneratedColumnsSQL() []string {
+	var stmts []string
+	for _, col := range spec.GeneratedColumns {
+		stmts = append(stmts, fmt.Sprintf(`ALTER TABLE "%s"."%s" ADD COLUMN %s`,
+			spec.Schema, spec.FullName, col.constraintSQL(spec.dialect())))
+	}
+	return stmts
+}
+
+// checkGeneratedColumns returns all problems of the fields with generated.
+func checkGeneratedColumns(spec *TableSpec, t *mapping.Table) []string {
+	var problems []string
+	for _, field := range t.Fields {
+		if field.Generated == "" {
+			continue
+		}
+		fieldType := field.FieldType()
+		if fieldType == nil {
+			continue
+		}
+		if strings.TrimSpace(field.Generated) == "" {
+			problems = append(problems, fmt.Sprintf("generated column %s requires an expression", field.Name))
+		}
+		var invalid []string
+		if isGeometryField(fieldType) {
+			invalid = append(invalid, "geometry type")
+		}
+		if fieldType.Name == "id" {
+			invalid = append(invalid, "id type")
+		}
+		if field.Name == "id" && spec.hasSerialId() {
+			invalid = append(invalid, "the name of the serial id column")
+		}
+		if field.Default != "" {
+			invalid = append(invalid, "default")
+		}
+		if field.OnUpdate != "" {
+			invalid = append(invalid, "on_update")
+		}
+		if len(field.NullValues) > 0 {
+			invalid = append(invalid, "null_values")
+		}
+		if field.Enum != "" {
+			invalid = append(invalid, "enum")
+		}
+		for _, what := range invalid {
+			problems = append(problems, fmt.Sprintf("generated column %s can not have %s", field.Name, what))
+		}
+	}
+	return problems
+}
+
+// checkGeneratedColumnsVersion returns an error if tables have generated
+// columns and the server does not support them.
+func (pg *PostGIS) checkGeneratedColumnsVersion() error {
+	var tables []string
+	for name, spec := range pg.Tables {
+		if len(spec.GeneratedColumns) > 0 {
+			tables = append(tables, name)
+		}
+	}
+	if len(tables) == 0 {
+		return nil
+	}
+	sort.Strings(tables)
+	var version int
+	sql := "SHOW server_version_num"
+	if err := pg.Db.QueryRow(sql).Scan(&version); err != nil {
+		return &SQLError{sql, err}
+	}
+	if version < minGeneratedColumnsVersion {
+		return fmt.Errorf("generated columns of %s require PostgreSQL 12, server version is %s",
+			strings.Join(tables, ", "), formatServerVersion(version))
+	}
+	return nil
+}
+
+// formatServerVersion returns the version of a server_version_num, e.g.
+// 11.5 for 110005 and 9.6.15 for 90615.
+func formatServerVersion(num int) string {
+	if num >= 100000 {
+		return fmt.Sprintf("%d.%d", num/10000, num%10000)
+	}
+	return fmt.Sprintf("%d.%d.%d", num/10000, num/100%100, num%100)
+}
@@ -0,0 +1,139 @@
+package postgis
+
+import (
+	"database/sql/driver"
+	"strings"
+	"testing"
+
+	"github.com/omniscale/imposm3/mapping"
+)
+
+func testGeneratedTable() *mapping.Table {
+	return &mapping.Table{
+		Name: "buildings",
+		Type: mapping.PolygonTable,
+		Fields: []*mapping.Field{
+			{Name: "osm_id", Type: "id"},
+			{Name: "geometry", Type: "geometry"},
+			{Name: "area", Type: "pseudoarea", Generated: "ST_Area(geometry)"},
+			{Name: "name", Key: "name", Type: "string"},
+		},
+	}
+}
+
+func TestGeneratedColumnsSQL(t *testing.T) {
+	spec := testTableSpec(t, testPostGIS(), testGeneratedTable())
+
+	if len(spec.Columns) != 3 || len(spec.GeneratedColumns) != 1 {
+		t.Fatal("unexpected columns", spec.Columns, spec.GeneratedColumns)
+	}
+	expected := []string{
+		`ALTER TABLE "import"."osm_buildings" ADD COLUMN "area" REAL GENERATED ALWAYS AS (ST_Area(geometry)) STORED`,
+	}
+	if stmts := spec.GeneratedColumnsSQL(); len(stmts) != 1 || stmts[0] != expected[0] {
+		t.Errorf("unexpected SQL\n%v\n%v", stmts, expected)
+	}
+	for _, sql := range []string{spec.CreateTableSQL(), spec.InsertSQL(), spec.CopySQL()} {
+		if strings.Contains(sql, `"area"`) {
+			t.Error("generated column in", sql)
+		}
+	}
+}
+
+func TestGeneratedColumnInsert(t *testing.T) {
+	db, d := newFakeDb()
+	defer db.Close()
+	pg := testPostGIS()
+	pg.Db = db
+	spec := testTableSpec(t, pg, testGeneratedTable())
+	pg.Tables = map[string]*TableSpec{"buildings": spec}
+	if err := pg.BeginBulk(); err != nil {
+		t.Fatal(err)
+	}
+	polygon := ewkbPolygon(3857, []float64{0, 0, 10, 0, 10, 10, 0, 0}).hex()
+	// row of the mapping with the value of the area field
+	row := spec.dropGeometry([]interface{}{int64(1), polygon, float32(50), "name"})
+	if err := pg.txRouter.Insert("buildings", row); err != nil {
+		t.Fatal(err)
+	}
+	if err := pg.txRouter.End(); err != nil {
+		t.Fatal(err)
+	}
+	// no value for the generated column
+	for i, expected := range []driver.Value{int64(1), polygon, "name"} {
+		if v := d.values("COPY", i); len(v) != 1 || v[0] != expected {
+			t.Errorf("unexpected value %d: %v", i, v)
+		}
+	}
+	if v := d.values("COPY", 3); len(v) != 0 {
+		t.Error("unexpected values", v)
+	}
+}
+
+func TestCheckGeneratedColumns(t *testing.T) {
+	for _, test := range []struct {
+		field   mapping.Field
+		problem string
+	}{
+		{mapping.Field{Name: "len", Type: "integer", Generated: "ST_Length(geometry)"}, ""},
+		{mapping.Field{Name: "len", Type: "integer", Generated: " "}, "generated column len requires an expression"},
+		{mapping.Field{Name: "geom", Type: "geometry", Generated: "ST_Centroid(geometry)"}, "generated column geom can not have geometry type"},
+		{mapping.Field{Name: "len", Type: "integer", Generated: "1", Default: "0"}, "generated column len can not have default"},
+		{mapping.Field{Name: "len", Type: "integer", Generated: "1", NullValues: []string{"0"}}, "generated column len can not have null_values"},
+		{mapping.Field{Name: "id", Type: "integer", Generated: "1"}, "generated column id can not have the name of the serial id column"},
+		{mapping.Field{Name: "name", Type: "string", Generated: "'x'"}, "column name defined by"},
+	} {
+		table := testTable()
+		field := test.field
+		table.Fields = append(table.Fields, &field)
+		_, err := NewTableSpec(testPostGIS(), table)
+		if test.problem == "" {
+			if err != nil {
+				t.Error("unexpected error", err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), test.problem) {
+			t.Errorf("expected %s, got %v", test.problem, err)
+		}
+	}
+}
+
+func TestGeneratedColumnsVersion(t *testing.T) {
+	db, d := newFakeDb()
+	defer db.Close()
+	pg := testPostGIS()
+	pg.Db = db
+	pg.Tables = map[string]*TableSpec{"roads": testTableSpec(t, pg, testTable())}
+	// no query without generated columns
+	if err := pg.checkGeneratedColumnsVersion(); err != nil {
+		t.Fatal(err)
+	}
+
+	pg.Tables["buildings"] = testTableSpec(t, pg, testGeneratedTable())
+	version := func(v int64) {
+		d.results = map[string]fakeResult{
+			"SHOW server_version_num": {
+				columns: []string{"server_version_num"},
+				rows:    [][]driver.Value{{v}},
+			},
+		}
+	}
+	version(110005)
+	err := pg.checkGeneratedColumnsVersion()
+	if err == nil || err.Error() != "generated columns of buildings require PostgreSQL 12, server version is 11.5" {
+		t.Error("unexpected error", err)
+	}
+	version(120002)
+	if err := pg.checkGeneratedColumnsVersion(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestFormatServerVersion(t *testing.T) {
+	for num, expected := range map[int]string{90615: "9.6.15", 100010: "10.10", 160004: "16.4"} {
+		if v := formatServerVersion(num); v != expected {
+			t.Errorf("%d: unexpected version %s, expected %s", num, v, expected)
+		}
+	}
+}
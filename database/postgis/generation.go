@@ -0,0 +1,112 @@
+package postgis
+
+import (
+	"fmt"
+)
+
+// Tables with generation_column support mark and sweep for full
+// re-imports into existing tables:
+//
+//   1. SetGeneration marks all existing rows with the previous generation.
+//   2. UpsertGeneration sets the generation of all rows that are inserted
+//      (or updated with upsert) afterwards, i.e. the rows of the new data.
+//   3. SweepOldGenerations deletes all rows that were not inserted or
+//      updated by the new data.
+//
+// The generation of inserted rows is the DEFAULT of the column, so that
+// it also works for COPY and the statements of the TableTx don't change.
+
+func (pg *PostGIS) generationTable(table string) (*TableSpec, error) {
+	spec, ok := pg.Tables[table]
+	if !ok {
+		return nil, fmt.Errorf("unknown table %s", table)
+	}
+	if spec.GenerationColumn == "" {
+		return nil, fmt.Errorf("table %s has no generation_column", table)
+	}
+	return spec, nil
+}
+
+// only returns ONLY for tables with child tables, the rows of the child
+// tables are handled with the child tables.
+func (spec *TableSpec) only() string {
+	if spec.hasChildren {
+		return "ONLY "
+	}
+	return ""
+}
+
+// SetGenerationSQL returns the UPDATE statement that sets the generation
+// of all rows.
+func (spec *TableSpec) SetGenerationSQL() string {
+	return fmt.Sprintf(`UPDATE %s"%s"."%s" SET "%s" = $1`,
+		spec.only(), spec.Schema, spec.FullName, spec.GenerationColumn)
+}
+
+// GenerationDefaultSQL returns the ALTER TABLE statement that sets the
+// generation of inserted rows.
+func (spec *TableSpec) GenerationDefaultSQL(gen int64) string {
+	return fmt.Sprintf(`ALTER TABLE "%s"."%s" ALTER COLUMN "%s" SET DEFAULT %d`,
+		spec.Schema, spec.FullName, spec.GenerationColumn, gen)
+}
+
+// SweepSQL returns the statement that deletes all rows of previous
+// generations. Tables with SoftDelete mark the rows as deleted.
+func (spec *TableSpec) SweepSQL() string {
+	old := fmt.Sprintf(`("%s" IS NULL OR "%s" < $1)`, spec.GenerationColumn, spec.GenerationColumn)
+	if spec.SoftDelete {
+		return fmt.Sprintf(`UPDATE %s"%s"."%s" SET "%s" = true WHERE %s AND %s`,
+			spec.only(), spec.Schema, spec.FullName, deletedColumn, old, notDeletedSQL)
+	}
+	return fmt.Sprintf(`DELETE FROM %s"%s"."%s" WHERE %s`,
+		spec.only(), spec.Schema, spec.FullName, old)
+}
+
+// SetGeneration sets the generation of all existing rows of the table.
+func (pg *PostGIS) SetGeneration(table string, gen int64) error {
+	spec, err := pg.generationTable(table)
+	if err != nil {
+		return err
+	}
+	sql := spec.SetGenerationSQL()
+	return pg.withArgs(func(db queryer) error {
+		if _, err := db.Exec(sql, gen); err != nil {
+			return &SQLError{sql, err}
+		}
+		return nil
+	})
+}
+
+// UpsertGeneration sets the generation of all rows that are inserted or
+// updated (with upsert) into the table afterwards.
+func (pg *PostGIS) UpsertGeneration(table string, gen int64) error {
+	spec, err := pg.generationTable(table)
+	if err != nil {
+		return err
+	}
+	sql := spec.GenerationDefaultSQL(gen)
+	if _, err := pg.Db.Exec(sql); err != nil {
+		return &SQLError{sql, err}
+	}
+	return nil
+}
+
+// SweepOldGenerations deletes all rows of the table with a generation
+// before currentGen and returns the number of deleted rows.
+func (pg *PostGIS) SweepOldGenerations(table string, currentGen int64) (int64, error) {
+	spec, err := pg.generationTable(table)
+	if err != nil {
+		return 0, err
+	}
+	sql := spec.SweepSQL()
+	var n int64
+	err = pg.withArgs(func(db queryer) error {
+		result, err := db.Exec(sql, currentGen)
+		if err != nil {
+			return &SQLError{sql, err}
+		}
+		n, err = result.RowsAffected()
+		return err
+	})
+	return n, err
+}
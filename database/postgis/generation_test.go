@@ -0,0 +1,106 @@
+package postgis
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/omniscale/imposm3/mapping"
+)
+
+func testGenerationPostGIS(t *testing.T) *PostGIS {
+	pg := testPostGIS()
+	table := testTable()
+	table.GenerationColumn = "generation"
+	table.Upsert = true
+	pg.Tables = map[string]*TableSpec{"roads": testTableSpec(t, pg, table)}
+	return pg
+}
+
+func TestGenerationSQL(t *testing.T) {
+	pg := testGenerationPostGIS(t)
+	spec := pg.Tables["roads"]
+
+	if sql := spec.CreateTableSQL(); !strings.Contains(sql, `"generation" BIGINT`) {
+		t.Error("missing generation column", sql)
+	}
+	if sql := spec.UpsertSQL(); !strings.HasSuffix(sql, `, "generation" = DEFAULT`) {
+		t.Error("upsert does not update generation", sql)
+	}
+	for _, test := range []struct {
+		sql      string
+		expected string
+	}{
+		{spec.SetGenerationSQL(), `UPDATE "import"."osm_roads" SET "generation" = $1`},
+		{spec.GenerationDefaultSQL(8), `ALTER TABLE "import"."osm_roads" ALTER COLUMN "generation" SET DEFAULT 8`},
+		{spec.SweepSQL(), `DELETE FROM "import"."osm_roads" WHERE ("generation" IS NULL OR "generation" < $1)`},
+	} {
+		if test.sql != test.expected {
+			t.Errorf("unexpected SQL\n%s\n%s", test.sql, test.expected)
+		}
+	}
+
+	spec.SoftDelete = true
+	spec.hasChildren = true
+	expected := `UPDATE ONLY "import"."osm_roads" SET "deleted" = true WHERE ("generation" IS NULL OR "generation" < $1) AND NOT "deleted"`
+	if sql := spec.SweepSQL(); sql != expected {
+		t.Errorf("unexpected SQL\n%s\n%s", sql, expected)
+	}
+}
+
+func TestMarkAndSweep(t *testing.T) {
+	db, d := newFakeDb()
+	defer db.Close()
+	pg := testGenerationPostGIS(t)
+	pg.Db = db
+
+	if err := pg.SetGeneration("roads", 7); err != nil {
+		t.Fatal(err)
+	}
+	if err := pg.UpsertGeneration("roads", 8); err != nil {
+		t.Fatal(err)
+	}
+	deleted, err := pg.SweepOldGenerations("roads", 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 1 {
+		t.Error("unexpected deleted rows", deleted)
+	}
+	expected := []string{"UPDATE", "ALTER TABLE", "DELETE FROM"}
+	if len(d.execs) != len(expected) {
+		t.Fatal("unexpected execs", d.execs)
+	}
+	for i, prefix := range expected {
+		if !strings.HasPrefix(d.execs[i], prefix) {
+			t.Error("unexpected exec", i, d.execs[i])
+		}
+	}
+	if gen := d.values("UPDATE", 0); len(gen) != 1 || gen[0] != int64(7) {
+		t.Error("unexpected generation", gen)
+	}
+	if gen := d.values("DELETE FROM", 0); len(gen) != 1 || gen[0] != int64(8) {
+		t.Error("unexpected current generation", gen)
+	}
+}
+
+func TestGenerationErrors(t *testing.T) {
+	pg := testGenerationPostGIS(t)
+	pg.Tables["plain"] = testTableSpec(t, pg, testTable())
+	if err := pg.SetGeneration("unknown", 1); err == nil || err.Error() != "unknown table unknown" {
+		t.Error("expected unknown table error", err)
+	}
+	if _, err := pg.SweepOldGenerations("plain", 1); err == nil || err.Error() != "table plain has no generation_column" {
+		t.Error("expected generation_column error", err)
+	}
+
+	table := testTable()
+	table.GenerationColumn = "name"
+	if _, err := NewTableSpec(testPostGIS(), table); err == nil || !strings.Contains(err.Error(), "column name defined by field name (type string, key name) and generation_column") {
+		t.Error("expected column conflict", err)
+	}
+	table.GenerationColumn = "generation"
+	table.Indexes = []*mapping.Index{{Column: "generation"}}
+	if _, err := NewTableSpec(testPostGIS(), table); err != nil {
+		t.Error("unexpected error for index on generation column", err)
+	}
+}
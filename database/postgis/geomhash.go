@@ -0,0 +1,205 @@
+package postgis
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strings"
+
+	"github.com/omniscale/imposm3/mapping"
+)
+
+// Columns of type geometry_hash contain a 64-bit FNV-1a hash of the
+// geometry of the row, so that changed geometries can be detected without
+// comparing the geometries. The hash is calculated from the canonical
+// EWKB of the inserted geometry, for INSERT and COPY:
+//
+//  - little endian byte order, regardless of the byte order of the input
+//  - with the SRID of the input geometry, or the input SRID of the table
+//    if the geometry has none
+//  - coordinates with full float64 precision, without rounding; -0 is
+//    hashed as 0 and all NaN as the same value
+//
+// WKT geometries are hashed as the EWKB of the parsed geometry. The hash
+// is calculated before the geometry is transformed, reduced to the
+// GridSize or subdivided by PostGIS.
+//
+// The hash is stored as BIGINT, or as 8 big endian bytes in a BYTEA column
+// with representation: bytea. Upserts of rows that are unchanged are
+// skipped (see UpsertSQL).
+
+const (
+	geometryHashBigint = "bigint"
+	geometryHashBytea  = "bytea"
+)
+
+type geometryHashColumnType struct {
+	simpleColumnType
+	representation string
+}
+
+// newGeometryHashColumnType returns the column type for the
+// representation arg of the field.
+func newGeometryHashColumnType(field *mapping.Field) (*geometryHashColumnType, error) {
+	representation := geometryHashBigint
+	if v, ok := field.Args["representation"]; ok {
+		representation, _ = v.(string)
+	}
+	switch representation {
+	case geometryHashBigint:
+		return &geometryHashColumnType{simpleColumnType{"BIGINT"}, representation}, nil
+	case geometryHashBytea:
+		return &geometryHashColumnType{simpleColumnType{"BYTEA"}, representation}, nil
+	}
+	return nil, fmt.Errorf("unknown representation '%v' of geometry_hash column %s", field.Args["representation"], field.Name)
+}
+
+// encode returns the hash in the representation of the column.
+func (t *geometryHashColumnType) encode(hash uint64) interface{} {
+	if t.representation == geometryHashBytea {
+		b := make([]byte, 8)
+		binary.BigEndian.PutUint64(b, hash)
+		return b
+	}
+	return int64(hash)
+}
+
+// geometryHash returns the hash of the canonical EWKB of the geometry.
+func geometryHash(g *wkbGeometry) uint64 {
+	h := fnv.New64a()
+	h.Write(g.canonical().appendEwkb(nil))
+	return h.Sum64()
+}
+
+// canonical returns a copy of the geometry with -0 and NaN coordinates
+// replaced by 0 and a single NaN value.
+func (g *wkbGeometry) canonical() *wkbGeometry {
+	c := *g
+	c.rings = make([][]float64, len(g.rings))
+	for i, ring := range g.rings {
+		c.rings[i] = make([]float64, len(ring))
+		for j, v := range ring {
+			switch {
+			case v == 0:
+				v = 0
+			case math.IsNaN(v):
+				v = math.NaN()
+			}
+			c.rings[i][j] = v
+		}
+	}
+	c.parts = make([]*wkbGeometry, len(g.parts))
+	for i, part := range g.parts {
+		c.parts[i] = part.canonical()
+	}
+	return &c
+}
+
+// geometryHashColumns returns the indices of all geometry_hash columns.
+func (spec *TableSpec) geometryHashColumns() []int {
+	var cols []int
+	for i, col := range spec.Columns {
+		if _, ok := col.Type.(*geometryHashColumnType); ok {
+			cols = append(cols, i)
+		}
+	}
+	return cols
+}
+
+// rowGeometry decodes the geometry of the row, or returns nil if the row
+// has no geometry.
+func (spec *TableSpec) rowGeometry(row []interface{}) (*wkbGeometry, error) {
+	idx := spec.geometryColumnIndex()
+	if idx < 0 || idx >= len(row) || row[idx] == nil {
+		return nil, nil
+	}
+	var g *wkbGeometry
+	if spec.GeometryEncoding == GeometryEncodingWkt {
+		wkt, ok := row[idx].(string)
+		if !ok {
+			return nil, fmt.Errorf("WKT geometry is %T, not a string", row[idx])
+		}
+		var err error
+		if g, err = parseWkt(wkt); err != nil {
+			return nil, err
+		}
+	} else {
+		wkb, err := hexWkb(row[idx])
+		if err != nil {
+			return nil, err
+		}
+		if g, err = decodeWkb(wkb); err != nil {
+			return nil, err
+		}
+	}
+	if g.srid < 0 {
+		g.srid = spec.inputSrid()
+	}
+	return g, nil
+}
+
+// encodeGeometryHashes returns the row with the hash of the geometry in
+// all geometry_hash columns. Values of the row are replaced, also for rows
+// of InsertMultiBatch, etc.
+func (spec *TableSpec) encodeGeometryHashes(row []interface{}) ([]interface{}, error) {
+	cols := spec.geometryHashColumns()
+	if len(cols) == 0 {
+		return row, nil
+	}
+	g, err := spec.rowGeometry(row)
+	if err != nil {
+		return nil, fmt.Errorf("geometry hash of %s: %s", spec.Name, err)
+	}
+	encoded := make([]interface{}, len(row))
+	copy(encoded, row)
+	for _, i := range cols {
+		if i >= len(row) {
+			continue
+		}
+		if g == nil {
+			encoded[i] = nil
+			continue
+		}
+		encoded[i] = spec.Columns[i].Type.(*geometryHashColumnType).encode(geometryHash(g))
+	}
+	return encoded, nil
+}
+
+// upsertChangedSQL returns the WHERE condition of UpsertSQL that skips
+// updates of unchanged rows, or an empty string for tables without
+// geometry_hash column.
+func (spec *TableSpec) upsertChangedSQL() string {
+	if len(spec.geometryHashColumns()) == 0 {
+		return ""
+	}
+	return " WHERE " + spec.changedSQL()
+}
+
+// changedSQL returns the condition for upserted rows that differ from the
+// existing row. The geometry is compared by its hash, or by its EWKB for
+// tables without geometry_hash column.
+func (spec *TableSpec) changedSQL() string {
+	hashed := len(spec.geometryHashColumns()) > 0
+	idIdx := spec.idColumnIndex()
+	geomIdx := spec.geometryColumnIndex()
+	var existing, excluded []string
+	for i, col := range spec.Columns {
+		if i == idIdx || (i == geomIdx && hashed) {
+			continue
+		}
+		if i == geomIdx {
+			existing = append(existing, fmt.Sprintf(`ST_AsEWKB("%s"."%s")`, spec.FullName, col.Name))
+			excluded = append(excluded, fmt.Sprintf(`ST_AsEWKB(EXCLUDED."%s")`, col.Name))
+			continue
+		}
+		existing = append(existing, fmt.Sprintf(`"%s"."%s"`, spec.FullName, col.Name))
+		excluded = append(excluded, fmt.Sprintf(`EXCLUDED."%s"`, col.Name))
+	}
+	if spec.SoftDelete {
+		existing = append(existing, fmt.Sprintf(`"%s"."%s"`, spec.FullName, deletedColumn))
+		excluded = append(excluded, "false")
+	}
+	return fmt.Sprintf("(%s) IS DISTINCT FROM (%s)",
+		strings.Join(existing, ", "), strings.Join(excluded, ", "))
+}
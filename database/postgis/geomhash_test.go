@@ -0,0 +1,144 @@
+package postgis
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/omniscale/imposm3/mapping"
+)
+
+func geometryHashTable(representation string) *mapping.Table {
+	table := testTable()
+	field := &mapping.Field{Name: "geometry_hash", Type: "geometry_hash"}
+	if representation != "" {
+		field.Args = map[string]interface{}{"representation": representation}
+	}
+	table.Fields = append(table.Fields, field)
+	return table
+}
+
+// bigEndianLineString returns the line as big endian WKB without SRID.
+func bigEndianLineString(coords ...float64) []byte {
+	b := &bytes.Buffer{}
+	b.WriteByte(0)
+	binary.Write(b, binary.BigEndian, uint32(wkbLineString))
+	binary.Write(b, binary.BigEndian, uint32(len(coords)/2))
+	for _, c := range coords {
+		binary.Write(b, binary.BigEndian, math.Float64bits(c))
+	}
+	return b.Bytes()
+}
+
+func TestGeometryHashSQL(t *testing.T) {
+	spec := testTableSpec(t, testPostGIS(), geometryHashTable(""))
+	if sql := spec.CreateTableSQL(); !strings.Contains(sql, `"geometry_hash" BIGINT`) {
+		t.Error("unexpected sql", sql)
+	}
+	spec = testTableSpec(t, testPostGIS(), geometryHashTable("bytea"))
+	if sql := spec.CreateTableSQL(); !strings.Contains(sql, `"geometry_hash" BYTEA`) {
+		t.Error("unexpected sql", sql)
+	}
+	_, err := NewTableSpec(testPostGIS(), geometryHashTable("md5"))
+	if err == nil || !strings.Contains(err.Error(), "unknown representation 'md5' of geometry_hash column geometry_hash") {
+		t.Error("unexpected error", err)
+	}
+
+	table := geometryHashTable("")
+	table.Upsert = true
+	spec = testTableSpec(t, testPostGIS(), table)
+	expected := `"geometry_hash" = EXCLUDED."geometry_hash" WHERE ("osm_roads"."name", "osm_roads"."tags", "osm_roads"."geometry_hash") IS DISTINCT FROM (EXCLUDED."name", EXCLUDED."tags", EXCLUDED."geometry_hash")`
+	if sql := spec.UpsertSQL(); !strings.HasSuffix(sql, expected) {
+		t.Errorf("unexpected SQL\n%s\n%s", sql, expected)
+	}
+	if sql := spec.InsertReturningIdSQL(); !strings.HasSuffix(sql, `IS DISTINCT FROM (EXCLUDED."name", EXCLUDED."tags", EXCLUDED."geometry_hash") RETURNING "id"`) {
+		t.Error("unexpected sql", sql)
+	}
+}
+
+func TestGeometryHashCanonical(t *testing.T) {
+	spec := testTableSpec(t, testPostGIS(), geometryHashTable(""))
+	hash := func(geom interface{}) interface{} {
+		row, err := spec.encodeGeometryHashes([]interface{}{int64(1), geom, "", "", nil})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return row[4]
+	}
+
+	line := ewkbLineString(3857, 0, 0, 10, 10)
+	expected := hash(line.hex())
+	if expected == nil {
+		t.Fatal("missing hash")
+	}
+	// reproducible across runs and versions
+	if expected != int64(5613623851339636500) {
+		t.Errorf("unexpected hash %d", expected)
+	}
+	for _, geom := range []interface{}{
+		line.Bytes(),
+		// byte order and missing SRID of the table
+		bigEndianLineString(0, 0, 10, 10),
+		ewkbLineString(0, 0, 0, 10, 10).hex(),
+		// -0 coordinates
+		ewkbLineString(3857, math.Copysign(0, -1), 0, 10, 10).hex(),
+	} {
+		if h := hash(geom); h != expected {
+			t.Errorf("unexpected hash %v for %v", h, geom)
+		}
+	}
+	for _, geom := range []interface{}{
+		ewkbLineString(3857, 0, 0, 10, 10.000000001).hex(),
+		ewkbLineString(3857, 10, 10, 0, 0).hex(),
+		ewkbLineString(4326, 0, 0, 10, 10).hex(),
+	} {
+		if h := hash(geom); h == expected {
+			t.Errorf("same hash for %v", geom)
+		}
+	}
+	if h := hash(nil); h != nil {
+		t.Error("unexpected hash for NULL geometry", h)
+	}
+	if _, err := spec.encodeGeometryHashes([]interface{}{int64(1), "zz", "", "", nil}); err == nil {
+		t.Error("expected error for invalid geometry")
+	}
+
+	wktSpec := testTableSpec(t, testPostGIS(), geometryHashTable(""))
+	wktSpec.GeometryEncoding = GeometryEncodingWkt
+	row, err := wktSpec.encodeGeometryHashes([]interface{}{int64(1), "LINESTRING(0 0, 10 10)", "", "", nil})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if row[4] != expected {
+		t.Error("unexpected hash of WKT geometry", row[4])
+	}
+}
+
+func TestGeometryHashBytea(t *testing.T) {
+	spec := testTableSpec(t, testPostGIS(), geometryHashTable("bytea"))
+	line := ewkbLineString(3857, 0, 0, 10, 10).hex()
+	// the same hash for INSERT and COPY
+	row, err := spec.prepareRow([]interface{}{int64(1), line, "", "", "ignored"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	copyRow, err := spec.copyRow(row)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(row[4], copyRow[4]) {
+		t.Error("different hash for COPY", row[4], copyRow[4])
+	}
+	if h, ok := row[4].([]byte); !ok || hex.EncodeToString(h) != "4de7992689c25f14" {
+		t.Errorf("unexpected hash %#v", row[4])
+	}
+
+	args := rejectedRow{row: row}.invalidArgs(spec)
+	if args[4] != nil {
+		t.Error("unexpected hash of rejected row", args[4])
+	}
+}
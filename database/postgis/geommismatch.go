@@ -0,0 +1,82 @@
+package postgis
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Tables are dropped before they are created, but a table can still exist
+// when createTable adds the geometry column, e.g. if the table is not
+// visible in information_schema.tables for the user of the import.
+// AddGeometryColumn fails for existing columns, so the existing column is
+// checked against the mapping first. Columns with the same type and SRID
+// are kept, the GeometryMismatchPolicy decides about all others.
+
+const (
+	// GeometryMismatchPolicyError fails with a GeometryColumnMismatchError.
+	GeometryMismatchPolicyError = "error"
+	// GeometryMismatchPolicyRecreate drops the existing column and adds
+	// it again. All values of the column are lost.
+	GeometryMismatchPolicyRecreate = "recreate"
+	// GeometryMismatchPolicyIgnore keeps the existing column. Inserts
+	// fail for geometries that do not match the existing column.
+	GeometryMismatchPolicyIgnore = "ignore"
+)
+
+func checkGeometryMismatchPolicy(policy string) error {
+	switch policy {
+	case "", GeometryMismatchPolicyError, GeometryMismatchPolicyRecreate, GeometryMismatchPolicyIgnore:
+		return nil
+	}
+	return fmt.Errorf("unknown geometry mismatch policy '%s'", policy)
+}
+
+// GeometryColumnMismatchError is returned by Init if the geometry column
+// of a table already exists with another type or SRID and the
+// GeometryMismatchPolicy is error.
+type GeometryColumnMismatchError struct {
+	Schema     string
+	Table      string
+	Column     string
+	Mismatches []string
+}
+
+func (e *GeometryColumnMismatchError) Error() string {
+	return fmt.Sprintf("geometry column %s of %s.%s already exists and does not match the mapping "+
+		"(drop the table or set the geometry mismatch policy to recreate or ignore):\n\t%s",
+		e.Column, e.Schema, e.Table, strings.Join(e.Mismatches, "\n\t"))
+}
+
+// checkExistingGeometryColumn applies the GeometryMismatchPolicy to an
+// existing geometry column of the table. It returns whether the column
+// needs to be added with AddGeometryColumn.
+func checkExistingGeometryColumn(tx queryer, spec *TableSpec, column string) (bool, error) {
+	srid, geomType, found, err := existingGeometryColumn(tx, spec)
+	if err != nil {
+		return false, err
+	}
+	if !found {
+		return true, nil
+	}
+	mismatches := geometryColumnMismatches(spec, srid, geomType)
+	if len(mismatches) == 0 {
+		log.Printf("keeping existing geometry column %s of %s.%s", column, spec.Schema, spec.FullName)
+		return false, nil
+	}
+	switch spec.GeometryMismatchPolicy {
+	case GeometryMismatchPolicyRecreate:
+		sql := fmt.Sprintf("SELECT DropGeometryColumn('%s', '%s', '%s');",
+			spec.Schema, spec.FullName, column)
+		var void interface{}
+		if err := tx.QueryRow(sql).Scan(&void); err != nil {
+			return false, &SQLError{sql, err}
+		}
+		log.Warnf("recreating geometry column %s: %s", column, strings.Join(mismatches, ", "))
+		return true, nil
+	case GeometryMismatchPolicyIgnore:
+		log.Warnf("keeping geometry column %s: %s", column, strings.Join(mismatches, ", "))
+		return false, nil
+	default:
+		return false, &GeometryColumnMismatchError{spec.Schema, spec.FullName, column, mismatches}
+	}
+}
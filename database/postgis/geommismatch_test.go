@@ -0,0 +1,78 @@
+package postgis
+
+import (
+	"database/sql/driver"
+	"strings"
+	"testing"
+)
+
+func TestGeometryMismatchPolicy(t *testing.T) {
+	for _, tc := range []struct {
+		policy   string
+		existing []driver.Value
+		err      string
+		drops    int
+		adds     int
+	}{
+		{"", nil, "", 0, 1},
+		{"", []driver.Value{int64(3857), "LINESTRING"}, "", 0, 0},
+		{"", []driver.Value{int64(4326), "POINT"}, "geometry column geometry of import.osm_roads already exists", 0, 0},
+		{GeometryMismatchPolicyError, []driver.Value{int64(3857), "POINT"}, "geometry type POINT does not match LINESTRING", 0, 0},
+		{GeometryMismatchPolicyRecreate, []driver.Value{int64(3857), "POINT"}, "", 1, 1},
+		{GeometryMismatchPolicyIgnore, []driver.Value{int64(4326), "POINT"}, "", 0, 0},
+	} {
+		pg := testPostGIS()
+		pg.Config.GeometryMismatchPolicy = tc.policy
+		spec := testTableSpec(t, pg, testTable())
+
+		db, d := newFakeDb()
+		var existing [][]driver.Value
+		if tc.existing != nil {
+			existing = append(existing, tc.existing)
+		}
+		d.results = map[string]fakeResult{
+			"SELECT EXISTS(SELECT * FROM information_schema.tables": {
+				columns: []string{"exists"},
+				rows:    [][]driver.Value{{false}},
+			},
+			"SELECT srid, type FROM geometry_columns": {
+				columns: []string{"srid", "type"},
+				rows:    existing,
+			},
+			"SELECT DropGeometryColumn": {
+				columns: []string{"dropgeometrycolumn"},
+				rows:    [][]driver.Value{{"ok"}},
+			},
+			"SELECT AddGeometryColumn": {
+				columns: []string{"addgeometrycolumn"},
+				rows:    [][]driver.Value{{"ok"}},
+			},
+		}
+		tx, err := db.Begin()
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = createTable(tx, *spec)
+		tx.Rollback()
+		db.Close()
+
+		if tc.err == "" && err != nil {
+			t.Errorf("%q %v: unexpected error %s", tc.policy, tc.existing, err)
+		}
+		if tc.err != "" {
+			if _, ok := err.(*GeometryColumnMismatchError); !ok || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("%q %v: expected %q, got %v", tc.policy, tc.existing, tc.err, err)
+			}
+		}
+		if n := d.count(`SELECT DropGeometryColumn('import', 'osm_roads', 'geometry');`); n != tc.drops {
+			t.Errorf("%q %v: expected %d DropGeometryColumn, got %d", tc.policy, tc.existing, tc.drops, n)
+		}
+		if n := d.count("SELECT AddGeometryColumn"); n != tc.adds {
+			t.Errorf("%q %v: expected %d AddGeometryColumn, got %d", tc.policy, tc.existing, tc.adds, n)
+		}
+	}
+
+	if err := checkGeometryMismatchPolicy("drop"); err == nil {
+		t.Error("expected error for unknown policy")
+	}
+}
@@ -0,0 +1,67 @@
+package postgis
+
+import (
+	"fmt"
+
+	"github.com/omniscale/imposm3/mapping"
+)
+
+// The geometry column of point, linestring and polygon tables only
+// accepts the single geometry type (polygon tables have a GEOMETRY column
+// for multipolygons). Tables with geometry_subtype accept single and
+// multi geometries, and PostGIS normalizes them to the subtype of the
+// column before the insert:
+//
+//  - multi: MULTIPOINT, MULTILINESTRING or MULTIPOLYGON column, single
+//    geometries are converted with ST_Multi
+//  - single: LINESTRING column of linestring tables, multilinestrings are
+//    merged with ST_LineMerge. Inserts of multilinestrings that can not
+//    be merged into a single linestring fail.
+//
+// Tables with geometry_subtype are loaded with INSERT, not COPY.
+
+const (
+	geometrySubtypeMulti  = "multi"
+	geometrySubtypeSingle = "single"
+)
+
+// checkGeometrySubtype returns the problems of the geometry_subtype of
+// the table.
+func checkGeometrySubtype(spec *TableSpec, t *mapping.Table) []string {
+	var problems []string
+	switch t.GeometrySubtype {
+	case "":
+		return nil
+	case geometrySubtypeMulti:
+		switch spec.GeometryType {
+		case string(mapping.PointTable), string(mapping.LineStringTable), string(mapping.PolygonTable):
+		default:
+			problems = append(problems, "geometry_subtype multi requires point, linestring or polygon table")
+		}
+	case geometrySubtypeSingle:
+		if spec.GeometryType != string(mapping.LineStringTable) {
+			problems = append(problems, "geometry_subtype single requires linestring table")
+		}
+	default:
+		return []string{fmt.Sprintf("unknown geometry_subtype '%s'", t.GeometrySubtype)}
+	}
+	if spec.geometryColumnIndex() < 0 {
+		problems = append(problems, "geometry_subtype requires geometry column")
+	}
+	if t.Subdivide > 0 || t.MaxVertices > 0 {
+		problems = append(problems, "geometry_subtype not supported with subdivide and max_vertices")
+	}
+	return problems
+}
+
+// subtypeGeometrySQL returns the SQL expression that normalizes geom to
+// the GeometrySubtype.
+func (spec *TableSpec) subtypeGeometrySQL(geom string) string {
+	switch spec.GeometrySubtype {
+	case geometrySubtypeMulti:
+		return fmt.Sprintf("ST_Multi(%s)", geom)
+	case geometrySubtypeSingle:
+		return fmt.Sprintf("ST_LineMerge(%s)", geom)
+	}
+	return geom
+}
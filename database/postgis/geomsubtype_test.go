@@ -0,0 +1,91 @@
+package postgis
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/omniscale/imposm3/mapping"
+)
+
+func TestGeometrySubtypeSQL(t *testing.T) {
+	for _, tc := range []struct {
+		tableType mapping.TableType
+		subtype   string
+		geomType  string
+		geometry  string
+	}{
+		{mapping.LineStringTable, "", "LINESTRING", `$2::Geometry`},
+		{mapping.LineStringTable, geometrySubtypeMulti, "MULTILINESTRING", `ST_Multi($2::Geometry)`},
+		{mapping.LineStringTable, geometrySubtypeSingle, "LINESTRING", `ST_LineMerge($2::Geometry)`},
+		{mapping.PointTable, geometrySubtypeMulti, "MULTIPOINT", `ST_Multi($2::Geometry)`},
+		{mapping.PolygonTable, "", "GEOMETRY", `$2::Geometry`},
+		{mapping.PolygonTable, geometrySubtypeMulti, "MULTIPOLYGON", `ST_Multi($2::Geometry)`},
+	} {
+		table := testTable()
+		table.Type = tc.tableType
+		table.GeometrySubtype = tc.subtype
+		spec := testTableSpec(t, testPostGIS(), table)
+
+		if typ := spec.geometryColumnType(); typ != tc.geomType {
+			t.Errorf("%s %q: unexpected column type %s", tc.tableType, tc.subtype, typ)
+		}
+		expected := `INSERT INTO "import"."osm_roads" ("osm_id", "geometry", "name", "tags") VALUES ($1, ` + tc.geometry + `, $3, $4)`
+		if sql := spec.InsertSQL(); sql != expected {
+			t.Errorf("unexpected SQL\n%s\n%s", sql, expected)
+		}
+		if spec.canCopy() != (tc.subtype == "") {
+			t.Errorf("%s %q: unexpected canCopy", tc.tableType, tc.subtype)
+		}
+	}
+
+	// normalized after the transformation and the grid
+	pg := testPostGIS()
+	pg.Config.GridSize = 0.01
+	table := testTable()
+	table.GeometrySubtype = geometrySubtypeMulti
+	spec := testTableSpec(t, pg, table)
+	if sql := spec.InsertSQL(); !strings.Contains(sql, `ST_Multi(ST_ReducePrecision($2::Geometry, 0.01))`) {
+		t.Error("unexpected SQL", sql)
+	}
+}
+
+func TestGeometrySubtypeProblems(t *testing.T) {
+	for _, tc := range []struct {
+		prepare func(table *mapping.Table)
+		problem string
+	}{
+		{func(table *mapping.Table) { table.GeometrySubtype = "mixed" }, "unknown geometry_subtype 'mixed'"},
+		{func(table *mapping.Table) {
+			table.GeometrySubtype = geometrySubtypeSingle
+			table.Type = mapping.PolygonTable
+		}, "geometry_subtype single requires linestring table"},
+		{func(table *mapping.Table) {
+			table.GeometrySubtype = geometrySubtypeMulti
+			table.Type = mapping.GeometryTable
+		}, "geometry_subtype multi requires point, linestring or polygon table"},
+		{func(table *mapping.Table) {
+			table.GeometrySubtype = geometrySubtypeMulti
+			table.Subdivide = 100
+		}, "geometry_subtype not supported with subdivide and max_vertices"},
+	} {
+		table := testTable()
+		tc.prepare(table)
+		if _, err := NewTableSpec(testPostGIS(), table); err == nil || !strings.Contains(err.Error(), tc.problem) {
+			t.Errorf("expected %q, got %v", tc.problem, err)
+		}
+	}
+}
+
+func TestGeometrySubtypeWkt(t *testing.T) {
+	if wktTypeAllowed("linestring", "", wkbMultiLineString) {
+		t.Error("multilinestring allowed without geometry_subtype")
+	}
+	for _, subtype := range []string{geometrySubtypeMulti, geometrySubtypeSingle} {
+		if !wktTypeAllowed("linestring", subtype, wkbMultiLineString) || !wktTypeAllowed("linestring", subtype, wkbLineString) {
+			t.Error("linestrings not allowed with geometry_subtype", subtype)
+		}
+	}
+	if !wktTypeAllowed("point", geometrySubtypeMulti, wkbMultiPoint) || wktTypeAllowed("point", geometrySubtypeMulti, wkbLineString) {
+		t.Error("unexpected types of point table")
+	}
+}
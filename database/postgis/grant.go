@@ -0,0 +1,66 @@
+package postgis
+
+import (
+	"fmt"
+	"sort"
+)
+
+// grantSchemas returns the import and production schemas of all tables,
+// sorted by name.
+func (pg *PostGIS) grantSchemas() []string {
+	seen := make(map[string]bool)
+	var schemas []string
+	for _, t := range pg.deployTables() {
+		for _, schema := range []string{t.schemas.Import, t.schemas.Production} {
+			if !seen[schema] {
+				seen[schema] = true
+				schemas = append(schemas, schema)
+			}
+		}
+	}
+	sort.Strings(schemas)
+	return schemas
+}
+
+// grantSelectSQL returns the statements that grant USAGE on the schemas
+// and SELECT on all existing tables in the schemas to role. With
+// defaultPrivileges, tables that are created later in the schemas are
+// also readable by role.
+func grantSelectSQL(schemas []string, role string, defaultPrivileges bool) []string {
+	var stmts []string
+	for _, schema := range schemas {
+		stmts = append(stmts,
+			fmt.Sprintf(`GRANT USAGE ON SCHEMA "%s" TO "%s"`, schema, role),
+			fmt.Sprintf(`GRANT SELECT ON ALL TABLES IN SCHEMA "%s" TO "%s"`, schema, role),
+		)
+		if defaultPrivileges {
+			stmts = append(stmts,
+				fmt.Sprintf(`ALTER DEFAULT PRIVILEGES IN SCHEMA "%s" GRANT SELECT ON TABLES TO "%s"`, schema, role))
+		}
+	}
+	return stmts
+}
+
+// GrantSelect grants read access on all tables in the import and
+// production schemas to role. Tables keep their privileges when they are
+// moved by Deploy. With Config.GrantDefaultPrivileges, tables of later
+// imports are also readable by role, as the default privileges are
+// applied when the tables are created in the import schema.
+func (pg *PostGIS) GrantSelect(role string) error {
+	tx, err := pg.Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer rollbackIfTx(&tx)
+
+	for _, sql := range grantSelectSQL(pg.grantSchemas(), role, pg.Config.GrantDefaultPrivileges) {
+		if _, err := tx.Exec(sql); err != nil {
+			return &SQLError{sql, err}
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	tx = nil
+	return nil
+}
@@ -0,0 +1,61 @@
+package postgis
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/omniscale/imposm3/database"
+)
+
+func TestGrantSelectSQL(t *testing.T) {
+	expected := []string{
+		`GRANT USAGE ON SCHEMA "import" TO "render"`,
+		`GRANT SELECT ON ALL TABLES IN SCHEMA "import" TO "render"`,
+		`GRANT USAGE ON SCHEMA "public" TO "render"`,
+		`GRANT SELECT ON ALL TABLES IN SCHEMA "public" TO "render"`,
+	}
+	if stmts := grantSelectSQL([]string{"import", "public"}, "render", false); !reflect.DeepEqual(stmts, expected) {
+		t.Errorf("unexpected statements\n%q\n%q", stmts, expected)
+	}
+
+	stmts := grantSelectSQL([]string{"import"}, "render", true)
+	if len(stmts) != 3 || stmts[2] != `ALTER DEFAULT PRIVILEGES IN SCHEMA "import" GRANT SELECT ON TABLES TO "render"` {
+		t.Errorf("unexpected statements %q", stmts)
+	}
+}
+
+func TestGrantSelect(t *testing.T) {
+	db, d := newFakeDb()
+	defer db.Close()
+	pg := testPostGIS()
+	pg.Db = db
+	pg.Config.ProductionSchema = "public"
+	pg.Config.TableSchemas = map[string]database.Schemas{
+		"buildings": {Import: "buildings_import", Production: "buildings", Backup: "buildings_backup"},
+	}
+	roads := testTableSpec(t, pg, testTable())
+	table := testTable()
+	table.Name = "buildings"
+	table.Schema = "buildings"
+	buildings := testTableSpec(t, pg, table)
+	pg.Tables = map[string]*TableSpec{"roads": roads, "buildings": buildings}
+
+	expected := []string{"buildings", "buildings_import", "import", "public"}
+	if schemas := pg.grantSchemas(); !reflect.DeepEqual(schemas, expected) {
+		t.Error("unexpected schemas", schemas)
+	}
+
+	pg.Config.GrantDefaultPrivileges = true
+	if err := pg.GrantSelect("render"); err != nil {
+		t.Fatal(err)
+	}
+	if n := d.count("GRANT USAGE"); n != 4 {
+		t.Error("unexpected GRANT USAGE execs", n)
+	}
+	if n := d.count("ALTER DEFAULT PRIVILEGES"); n != 4 {
+		t.Error("unexpected ALTER DEFAULT PRIVILEGES execs", n)
+	}
+	if d.commits != 1 {
+		t.Error("unexpected commits", d.commits)
+	}
+}
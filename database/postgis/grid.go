@@ -0,0 +1,80 @@
+package postgis
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// Geometries are reduced to Config.GridSize after the transformation.
+// ST_ReducePrecision (PostGIS 3.1) keeps polygons valid, while
+// ST_SnapToGrid can collapse or self-intersect them. The function is
+// selected with postgis_version() when the database is opened.
+
+var postgisVersionRe = regexp.MustCompile(`^(\d+)\.(\d+)`)
+
+// parsePostGISVersion returns the major and minor version from the
+// output of postgis_version() (e.g. "3.1 USE_GEOS=1 USE_PROJ=1 USE_STATS=1").
+func parsePostGISVersion(version string) (major, minor int, err error) {
+	m := postgisVersionRe.FindStringSubmatch(version)
+	if m == nil {
+		return 0, 0, fmt.Errorf("unable to parse PostGIS version '%s'", version)
+	}
+	major, _ = strconv.Atoi(m[1])
+	minor, _ = strconv.Atoi(m[2])
+	return major, minor, nil
+}
+
+// hasReducePrecision returns whether the PostGIS version supports
+// ST_ReducePrecision.
+func hasReducePrecision(major, minor int) bool {
+	return major > 3 || major == 3 && minor >= 1
+}
+
+// detectGridFunction selects ST_SnapToGrid for all tables if GridSize is
+// set and PostGIS is older than 3.1.
+func (pg *PostGIS) detectGridFunction() error {
+	if pg.Config.GridSize <= 0 {
+		return nil
+	}
+	var version string
+	sql := "SELECT postgis_version()"
+	if err := pg.Db.QueryRow(sql).Scan(&version); err != nil {
+		return &SQLError{sql, err}
+	}
+	major, minor, err := parsePostGISVersion(version)
+	if err != nil {
+		return err
+	}
+	if hasReducePrecision(major, minor) {
+		return nil
+	}
+	log.Printf("PostGIS %d.%d without ST_ReducePrecision, reducing geometries with ST_SnapToGrid", major, minor)
+	for _, spec := range pg.Tables {
+		spec.snapToGrid = true
+	}
+	return nil
+}
+
+// reduceGeometry returns whether the geometries are reduced to the grid
+// size.
+func (spec *TableSpec) reduceGeometry() bool {
+	return spec.GridSize > 0 && spec.hasGeometry()
+}
+
+// gridGeometrySQL returns the SQL expression that reduces geom to the
+// grid size.
+func (spec *TableSpec) gridGeometrySQL(geom string) string {
+	if !spec.reduceGeometry() {
+		return geom
+	}
+	if spec.snapToGrid || spec.PreserveM {
+		// ST_ReducePrecision drops M values
+		return fmt.Sprintf("ST_SnapToGrid(%s, %s)", geom, formatGridSize(spec.GridSize))
+	}
+	return fmt.Sprintf("ST_ReducePrecision(%s, %s)", geom, formatGridSize(spec.GridSize))
+}
+
+func formatGridSize(size float64) string {
+	return strconv.FormatFloat(size, 'g', -1, 64)
+}
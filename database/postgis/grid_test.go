@@ -0,0 +1,112 @@
+package postgis
+
+import (
+	"database/sql/driver"
+	"strings"
+	"testing"
+)
+
+func TestGridGeometrySQL(t *testing.T) {
+	pg := testPostGIS()
+	pg.Config.GridSize = 0.01
+	spec := testTableSpec(t, pg, testTable())
+	if sql := spec.InsertSQL(); !strings.Contains(sql, "ST_ReducePrecision($2::Geometry, 0.01)") {
+		t.Error("unexpected sql", sql)
+	}
+	if spec.canCopy() {
+		t.Error("COPY with grid size")
+	}
+
+	spec.snapToGrid = true
+	if sql := spec.InsertSQL(); !strings.Contains(sql, "ST_SnapToGrid($2::Geometry, 0.01)") {
+		t.Error("unexpected sql", sql)
+	}
+
+	// reduced after the transformation
+	table := testTable()
+	table.Srid = 25832
+	pg.Config.GeometryEncoding = GeometryEncodingEwkb
+	pg.Config.GridSize = 1
+	spec = testTableSpec(t, pg, table)
+	if sql := spec.InsertSQL(); !strings.Contains(sql, "ST_ReducePrecision(ST_Transform(ST_GeomFromEWKB(decode($2, 'hex')), 25832), 1)") {
+		t.Error("unexpected sql", sql)
+	}
+	spec.Subdivide = 100
+	if sql := spec.SubdivideInsertSQL(); !strings.Contains(sql, "ST_Subdivide(ST_ReducePrecision(ST_Transform(") {
+		t.Error("unexpected sql", sql)
+	}
+
+	pg.Config.GridSize = 0
+	spec = testTableSpec(t, pg, testTable())
+	if sql := spec.InsertSQL(); strings.Contains(sql, "ST_ReducePrecision") || !spec.canCopy() {
+		t.Error("unexpected sql", sql)
+	}
+}
+
+func TestParsePostGISVersion(t *testing.T) {
+	for _, test := range []struct {
+		version      string
+		major, minor int
+		reduce       bool
+	}{
+		{"3.1 USE_GEOS=1 USE_PROJ=1 USE_STATS=1", 3, 1, true},
+		{"3.0 USE_GEOS=1 USE_PROJ=1 USE_STATS=1", 3, 0, false},
+		{"2.5 USE_GEOS=1 USE_PROJ=1 USE_STATS=1", 2, 5, false},
+		{"10.0 USE_GEOS=1", 10, 0, true},
+	} {
+		major, minor, err := parsePostGISVersion(test.version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if major != test.major || minor != test.minor {
+			t.Errorf("unexpected version %d.%d for %s", major, minor, test.version)
+		}
+		if hasReducePrecision(major, minor) != test.reduce {
+			t.Errorf("unexpected ST_ReducePrecision support for %s", test.version)
+		}
+	}
+	if _, _, err := parsePostGISVersion("unknown"); err == nil {
+		t.Error("expected error")
+	}
+}
+
+func TestDetectGridFunction(t *testing.T) {
+	db, d := newFakeDb()
+	defer db.Close()
+	pg := testPostGIS()
+	pg.Db = db
+	pg.Tables = map[string]*TableSpec{"roads": testTableSpec(t, pg, testTable())}
+	// no query without grid size
+	if err := pg.detectGridFunction(); err != nil {
+		t.Fatal(err)
+	}
+	if n := d.count("SELECT postgis_version()"); n != 0 {
+		t.Error("unexpected queries", n)
+	}
+
+	pg.Config.GridSize = 0.5
+	pg.Tables["roads"] = testTableSpec(t, pg, testTable())
+	version := func(v string) {
+		d.results = map[string]fakeResult{
+			"SELECT postgis_version()": {
+				columns: []string{"postgis_version"},
+				rows:    [][]driver.Value{{v}},
+			},
+		}
+	}
+	version("3.4 USE_GEOS=1 USE_PROJ=1 USE_STATS=1")
+	if err := pg.detectGridFunction(); err != nil {
+		t.Fatal(err)
+	}
+	if sql := pg.Tables["roads"].InsertSQL(); !strings.Contains(sql, "ST_ReducePrecision($2::Geometry, 0.5)") {
+		t.Error("unexpected sql", sql)
+	}
+
+	version("2.5 USE_GEOS=1 USE_PROJ=1 USE_STATS=1")
+	if err := pg.detectGridFunction(); err != nil {
+		t.Fatal(err)
+	}
+	if sql := pg.Tables["roads"].InsertSQL(); !strings.Contains(sql, "ST_SnapToGrid($2::Geometry, 0.5)") {
+		t.Error("unexpected sql", sql)
+	}
+}
@@ -0,0 +1,120 @@
+package postgis
+
+import (
+	"crypto/rand"
+	"fmt"
+	"sort"
+
+	"github.com/omniscale/imposm3/mapping"
+)
+
+// With Config.ImportIDColumn, all tables have an additional UUID column
+// with the ImportID of the run that inserted (or last upserted) the row,
+// e.g. to merge the data of multiple sources into the same tables and to
+// roll back a single run with DeleteByImportID. The ImportID is part of
+// the INSERT statements and appended to the rows of COPY (see
+// timestampRow), so that it does not depend on the DEFAULT of the column
+// like the generation_column. Generalized tables have no ImportIDColumn.
+
+// prepareImportID generates the ImportID if it is empty, or checks the
+// configured ImportID.
+func (pg *PostGIS) prepareImportID() error {
+	if pg.Config.ImportIDColumn == "" {
+		return nil
+	}
+	if pg.Config.ImportID == "" {
+		id, err := newImportID()
+		if err != nil {
+			return err
+		}
+		pg.Config.ImportID = id
+		log.Printf("import id %s", id)
+		return nil
+	}
+	id, err := mapping.CanonicalUUID(pg.Config.ImportID)
+	if err != nil {
+		return fmt.Errorf("invalid ImportID: %s", err)
+	}
+	pg.Config.ImportID = id
+	return nil
+}
+
+// newImportID returns a random (version 4) UUID.
+func newImportID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
+
+// importIDSQL returns the ImportID as UUID literal for INSERT statements.
+func (spec *TableSpec) importIDSQL() string {
+	return spec.dialect().Cast("'"+spec.ImportID+"'", "uuid")
+}
+
+// DeleteByImportIDSQL returns the statement that deletes all rows of the
+// import id $1. Tables with SoftDelete mark the rows as deleted.
+func (spec *TableSpec) DeleteByImportIDSQL() string {
+	if spec.SoftDelete {
+		return fmt.Sprintf(`UPDATE %s"%s"."%s" SET "%s" = true WHERE "%s" = $1 AND %s`,
+			spec.only(), spec.Schema, spec.FullName, deletedColumn, spec.ImportIDColumn, notDeletedSQL)
+	}
+	return fmt.Sprintf(`DELETE FROM %s"%s"."%s" WHERE "%s" = $1`,
+		spec.only(), spec.Schema, spec.FullName, spec.ImportIDColumn)
+}
+
+// DeleteByImportID deletes all rows of the import run from all tables in
+// a single transaction, e.g. to roll back the import of one source, and
+// returns the number of deleted rows. Tables with a foreign key are
+// handled before their parent tables. Generalized tables are not
+// updated. The ImportIDColumn is not indexed, each table is scanned.
+func (pg *PostGIS) DeleteByImportID(id string) (int64, error) {
+	if pg.Config.ImportIDColumn == "" {
+		return 0, fmt.Errorf("ImportIDColumn is not configured")
+	}
+	id, err := mapping.CanonicalUUID(id)
+	if err != nil {
+		return 0, err
+	}
+	if txr := pg.txRouter; txr != nil && !txr.ended {
+		return 0, fmt.Errorf("unable to delete import %s during an import", id)
+	}
+
+	tables := make([]*TableSpec, 0, len(pg.Tables))
+	for _, spec := range pg.Tables {
+		tables = append(tables, spec)
+	}
+	sort.Sort(tablesByDepth(tables))
+
+	tx, err := pg.Db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer rollbackIfTx(&tx)
+	var deleted int64
+	// child tables first
+	for i := len(tables) - 1; i >= 0; i-- {
+		spec := tables[i]
+		sql := spec.DeleteByImportIDSQL()
+		res, err := tx.Exec(sql, id)
+		if err != nil {
+			return 0, &SQLError{sql, err}
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		if n > 0 {
+			log.Printf("deleted %d rows of import %s from %s", n, id, spec.FullName)
+		}
+		deleted += n
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	tx = nil // set nil to prevent rollback
+	return deleted, nil
+}
@@ -0,0 +1,129 @@
+package postgis
+
+import (
+	"database/sql/driver"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testImportID = "6f1c4b38-8e0e-4c55-9a6c-3b1f0d9c2a11"
+
+func importIDPostGIS() *PostGIS {
+	pg := testPostGIS()
+	pg.Config.ImportIDColumn = "import_id"
+	pg.Config.ImportID = testImportID
+	return pg
+}
+
+func TestImportIDColumn(t *testing.T) {
+	table := testTable()
+	table.Upsert = true
+	spec := testTableSpec(t, importIDPostGIS(), table)
+
+	if sql := spec.CreateTableSQL(); !strings.Contains(sql, `"import_id" UUID`) {
+		t.Error("missing column", sql)
+	}
+	expected := `INSERT INTO "import"."osm_roads" ("osm_id", "geometry", "name", "tags", "import_id") VALUES ($1, $2::Geometry, $3, $4, '6f1c4b38-8e0e-4c55-9a6c-3b1f0d9c2a11'::uuid)`
+	if sql := spec.InsertSQL(); sql != expected {
+		t.Errorf("unexpected SQL\n%s\n%s", sql, expected)
+	}
+	if sql := spec.UpsertSQL(); !strings.Contains(sql, `"import_id" = EXCLUDED."import_id"`) {
+		t.Error("unexpected SQL", sql)
+	}
+	if sql := spec.CopySQL(); !strings.HasSuffix(sql, `"tags", "import_id") FROM STDIN`) {
+		t.Error("unexpected SQL", sql)
+	}
+	row := spec.timestampRow([]interface{}{int64(1), nil, "", ""}, time.Now())
+	if !reflect.DeepEqual(row, []interface{}{int64(1), nil, "", "", testImportID}) {
+		t.Error("unexpected COPY row", row)
+	}
+
+	table = testTable()
+	table.Subdivide = 100
+	spec = testTableSpec(t, importIDPostGIS(), table)
+	if sql := spec.SubdivideInsertSQL(); !strings.Contains(sql, `"tags", "import_id") SELECT`) || !strings.Contains(sql, `'6f1c4b38-8e0e-4c55-9a6c-3b1f0d9c2a11'::uuid FROM ST_Subdivide`) {
+		t.Error("unexpected SQL", sql)
+	}
+
+	table = testTable()
+	table.TimestampColumn = "import_id"
+	if _, err := NewTableSpec(importIDPostGIS(), table); err == nil || !strings.Contains(err.Error(), "column import_id defined by ImportIDColumn") {
+		t.Error("unexpected error", err)
+	}
+}
+
+func TestPrepareImportID(t *testing.T) {
+	pg := importIDPostGIS()
+	pg.Config.ImportID = ""
+	if err := pg.prepareImportID(); err != nil {
+		t.Fatal(err)
+	}
+	uuidV4 := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	if !uuidV4.MatchString(pg.Config.ImportID) {
+		t.Error("unexpected generated import id", pg.Config.ImportID)
+	}
+	generated := pg.Config.ImportID
+	if err := pg.prepareImportID(); err != nil || pg.Config.ImportID != generated {
+		t.Error("import id changed", pg.Config.ImportID, err)
+	}
+
+	pg.Config.ImportID = strings.ToUpper(testImportID)
+	if err := pg.prepareImportID(); err != nil || pg.Config.ImportID != testImportID {
+		t.Error("unexpected import id", pg.Config.ImportID, err)
+	}
+	pg.Config.ImportID = "run-1"
+	if err := pg.prepareImportID(); err == nil {
+		t.Error("expected error for invalid import id")
+	}
+
+	pg = testPostGIS()
+	if err := pg.prepareImportID(); err != nil || pg.Config.ImportID != "" {
+		t.Error("unexpected import id without column", pg.Config.ImportID, err)
+	}
+}
+
+func TestDeleteByImportID(t *testing.T) {
+	db, d := newFakeDb()
+	defer db.Close()
+	pg := importIDPostGIS()
+	pg.Db = db
+	testForeignKeyTables(t, pg)
+
+	if sql := pg.Tables["roads"].DeleteByImportIDSQL(); sql != `DELETE FROM "import"."osm_roads" WHERE "import_id" = $1` {
+		t.Error("unexpected SQL", sql)
+	}
+	n, err := pg.DeleteByImportID(strings.ToUpper(testImportID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the fake driver returns 1 for all RowsAffected
+	if n != 2 {
+		t.Error("unexpected deleted rows", n)
+	}
+	expected := []string{
+		`DELETE FROM "import"."osm_road_members" WHERE "import_id" = $1`,
+		`DELETE FROM "import"."osm_roads" WHERE "import_id" = $1`,
+	}
+	if !reflect.DeepEqual(d.execs, expected) {
+		t.Error("unexpected statements", d.execs)
+	}
+	if ids := d.values("DELETE", 0); !reflect.DeepEqual(ids, []driver.Value{testImportID, testImportID}) {
+		t.Error("unexpected import ids", ids)
+	}
+	if d.commits != 1 {
+		t.Error("unexpected commits", d.commits)
+	}
+
+	pg.Config.SoftDelete = true
+	spec := testTableSpec(t, pg, testTable())
+	if sql := spec.DeleteByImportIDSQL(); sql != `UPDATE "import"."osm_roads" SET "deleted" = true WHERE "import_id" = $1 AND NOT "deleted"` {
+		t.Error("unexpected SQL", sql)
+	}
+
+	if _, err := testFlushPostGIS(t, db).DeleteByImportID(testImportID); err == nil {
+		t.Error("expected error without ImportIDColumn")
+	}
+}
@@ -0,0 +1,268 @@
+package postgis
+
+import (
+	"database/sql"
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"time"
+)
+
+// Each import is recorded in the imposm_imports table of the import
+// schema. The record is created in Init, before any table is created,
+// and it is marked as finished in Finish. An unfinished record means that a
+// previous import is still running, or that it crashed.
+//
+// Running imports hold an advisory lock for the import schema. The lock
+// is bound to the transaction (and thus to the backend) of the import
+// and PostgreSQL releases it as soon as the backend is gone, even if the
+// import process was killed. An unfinished record without a held lock is
+// therefore a crashed import.
+
+const importsTable = "imposm_imports"
+
+const (
+	// CrashedImportAbort aborts Init if a previous import did not finish.
+	CrashedImportAbort = "abort"
+	// CrashedImportCleanup removes all tables of a crashed import in Init.
+	CrashedImportCleanup = "cleanup"
+)
+
+// ImportRunningError is returned by Init if another import into the same
+// schema is currently running.
+type ImportRunningError struct {
+	Schema string
+	Pid    int
+}
+
+func (e *ImportRunningError) Error() string {
+	if e.Pid == 0 {
+		return fmt.Sprintf("another import into %s is currently running", e.Schema)
+	}
+	return fmt.Sprintf("another import into %s is currently running (backend pid %d)", e.Schema, e.Pid)
+}
+
+// CrashedImportError is returned by Init if a previous import did not
+// finish and the CrashedImportPolicy is abort.
+type CrashedImportError struct {
+	Schema  string
+	Started time.Time
+	Pid     int
+	Tables  []string
+}
+
+func (e *CrashedImportError) Error() string {
+	return fmt.Sprintf("previous import into %s did not finish (started %s by backend pid %d, tables: %s)",
+		e.Schema, e.Started.Format(time.RFC3339), e.Pid, strings.Join(e.Tables, ", "))
+}
+
+type importRecord struct {
+	id      int64
+	started time.Time
+	pid     int
+	tables  []string
+}
+
+// importLockKey returns the advisory lock key for imports into schema.
+func importLockKey(schema string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte("imposm3 import " + schema))
+	return int64(h.Sum64())
+}
+
+func createImportsTableSQL(schema string) string {
+	return fmt.Sprintf(`CREATE TABLE IF NOT EXISTS "%s"."%s" (
+            id SERIAL PRIMARY KEY,
+            started TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+            finished TIMESTAMP WITH TIME ZONE,
+            backend_pid INTEGER NOT NULL,
+            tables TEXT NOT NULL
+        )`, schema, importsTable)
+}
+
+func unfinishedImportSQL(schema string) string {
+	return fmt.Sprintf(`SELECT id, started, backend_pid, tables FROM "%s"."%s" WHERE finished IS NULL ORDER BY id DESC LIMIT 1`,
+		schema, importsTable)
+}
+
+// checkPreviousImport decides what to do with an unfinished import
+// record. locked is whether this import acquired the import lock.
+// It returns true if the record needs to be cleaned up.
+func checkPreviousImport(schema string, record *importRecord, locked bool, lockPid int, policy string) (bool, error) {
+	if !locked {
+		return false, &ImportRunningError{schema, lockPid}
+	}
+	if record == nil {
+		return false, nil
+	}
+	switch policy {
+	case "", CrashedImportAbort:
+		return false, &CrashedImportError{schema, record.started, record.pid, record.tables}
+	case CrashedImportCleanup:
+		return true, nil
+	default:
+		return false, fmt.Errorf("unknown crashed import policy '%s'", policy)
+	}
+}
+
+// beginImport acquires the import lock, checks for unfinished imports and
+// records this import with all tables. Tables are recorded as schema.table.
+func (pg *PostGIS) beginImport(tables []string) error {
+	schema := pg.Config.ImportSchema
+
+	sql := createImportsTableSQL(schema)
+	if _, err := pg.Db.Exec(sql); err != nil {
+		return &SQLError{sql, err}
+	}
+
+	lockTx, err := pg.Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer rollbackIfTx(&lockTx)
+
+	locked, err := tryImportLock(lockTx, schema)
+	if err != nil {
+		return err
+	}
+	var record *importRecord
+	var lockPid int
+	if locked {
+		record, err = pg.unfinishedImport()
+	} else {
+		lockPid, err = importLockHolder(lockTx, schema)
+	}
+	if err != nil {
+		return err
+	}
+
+	cleanup, err := checkPreviousImport(schema, record, locked, lockPid, pg.Config.CrashedImportPolicy)
+	if err != nil {
+		return err
+	}
+	if cleanup {
+		if err := pg.cleanupImport(record); err != nil {
+			return err
+		}
+	}
+
+	var pid int
+	sql = "SELECT pg_backend_pid()"
+	if err := lockTx.QueryRow(sql).Scan(&pid); err != nil {
+		return &SQLError{sql, err}
+	}
+	sql = fmt.Sprintf(`INSERT INTO "%s"."%s" (backend_pid, tables) VALUES ($1, $2) RETURNING id`,
+		schema, importsTable)
+	err = pg.withArgs(func(db queryer) error {
+		return db.QueryRow(sql, pid, strings.Join(tables, ",")).Scan(&pg.importId)
+	})
+	if err != nil {
+		return &SQLError{sql, err}
+	}
+	// keep transaction open till finishImport
+	pg.importLockTx = lockTx
+	lockTx = nil
+	return nil
+}
+
+func tryImportLock(tx *sql.Tx, schema string) (bool, error) {
+	var locked bool
+	sql := "SELECT pg_try_advisory_xact_lock($1)"
+	if err := tx.QueryRow(sql, importLockKey(schema)).Scan(&locked); err != nil {
+		return false, &SQLError{sql, err}
+	}
+	return locked, nil
+}
+
+// importLockHolder returns the backend pid that holds the import lock.
+func importLockHolder(tx *sql.Tx, schema string) (int, error) {
+	key := uint64(importLockKey(schema))
+	// bigint advisory locks are split into classid (high) and objid (low)
+	query := fmt.Sprintf(`SELECT pid FROM pg_locks WHERE locktype = 'advisory' AND granted AND classid = %d AND objid = %d AND objsubid = 1`,
+		key>>32, key&0xffffffff)
+	var pid sql.NullInt64
+	err := tx.QueryRow(query).Scan(&pid)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, &SQLError{query, err}
+	}
+	return int(pid.Int64), nil
+}
+
+func (pg *PostGIS) unfinishedImport() (*importRecord, error) {
+	query := unfinishedImportSQL(pg.Config.ImportSchema)
+	r := importRecord{}
+	var tables string
+	err := pg.Db.QueryRow(query).Scan(&r.id, &r.started, &r.pid, &tables)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, &SQLError{query, err}
+	}
+	if tables != "" {
+		r.tables = strings.Split(tables, ",")
+	}
+	return &r, nil
+}
+
+// cleanupImport drops all tables of a crashed import and removes
+// its record.
+func (pg *PostGIS) cleanupImport(record *importRecord) error {
+	log.Warnf("previous import into %s did not finish (started %s), removing tables: %s",
+		pg.Config.ImportSchema, record.started.Format(time.RFC3339), strings.Join(record.tables, ", "))
+
+	tx, err := pg.Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer rollbackIfTx(&tx)
+	for _, table := range record.tables {
+		schema := pg.Config.ImportSchema
+		if parts := strings.SplitN(table, ".", 2); len(parts) == 2 {
+			schema, table = parts[0], parts[1]
+		}
+		if err := dropTableIfExists(tx, schema, table); err != nil {
+			return err
+		}
+	}
+	sql := fmt.Sprintf(`DELETE FROM "%s"."%s" WHERE id = $1`, pg.Config.ImportSchema, importsTable)
+	if _, err := tx.Exec(sql, record.id); err != nil {
+		return &SQLError{sql, err}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	tx = nil
+	return nil
+}
+
+// finishImport marks the import as finished and releases the lock.
+func (pg *PostGIS) finishImport() error {
+	if pg.importLockTx == nil {
+		return nil
+	}
+	sql := fmt.Sprintf(`UPDATE "%s"."%s" SET finished = now() WHERE id = $1`,
+		pg.Config.ImportSchema, importsTable)
+	err := pg.withArgs(func(db queryer) error {
+		_, err := db.Exec(sql, pg.importId)
+		return err
+	})
+	if err != nil {
+		return &SQLError{sql, err}
+	}
+	return pg.releaseImportLock()
+}
+
+// releaseImportLock releases the lock without finishing the import, so
+// that the next import can detect the unfinished import.
+func (pg *PostGIS) releaseImportLock() error {
+	if pg.importLockTx == nil {
+		return nil
+	}
+	err := pg.importLockTx.Rollback()
+	pg.importLockTx = nil
+	return err
+}
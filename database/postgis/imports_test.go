@@ -0,0 +1,61 @@
+package postgis
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckPreviousImport(t *testing.T) {
+	crashed := &importRecord{
+		id:      3,
+		started: time.Date(2015, 3, 1, 12, 0, 0, 0, time.UTC),
+		pid:     4242,
+		tables:  []string{"osm_roads", "osm_buildings"},
+	}
+
+	// no previous import
+	cleanup, err := checkPreviousImport("import", nil, true, 0, "")
+	if err != nil || cleanup {
+		t.Error("unexpected result", cleanup, err)
+	}
+
+	// running import
+	_, err = checkPreviousImport("import", nil, false, 1234, CrashedImportCleanup)
+	if err, ok := err.(*ImportRunningError); !ok || err.Pid != 1234 {
+		t.Error("expected ImportRunningError", err)
+	}
+
+	// crashed import
+	_, err = checkPreviousImport("import", crashed, true, 0, "")
+	if err, ok := err.(*CrashedImportError); !ok || err.Pid != 4242 {
+		t.Error("expected CrashedImportError", err)
+	}
+	if !strings.Contains(err.Error(), "previous import into import did not finish") ||
+		!strings.Contains(err.Error(), "osm_roads, osm_buildings") {
+		t.Error("unexpected error message", err)
+	}
+	cleanup, err = checkPreviousImport("import", crashed, true, 0, CrashedImportCleanup)
+	if err != nil || !cleanup {
+		t.Error("expected cleanup", cleanup, err)
+	}
+	if _, err = checkPreviousImport("import", crashed, true, 0, "ignore"); err == nil {
+		t.Error("expected error for unknown policy")
+	}
+}
+
+func TestImportLockKey(t *testing.T) {
+	if importLockKey("import") != importLockKey("import") {
+		t.Error("lock key not stable")
+	}
+	if importLockKey("import") == importLockKey("staging") {
+		t.Error("same lock key for different schemas")
+	}
+}
+
+func TestUnfinishedImportSQL(t *testing.T) {
+	expected := `SELECT id, started, backend_pid, tables FROM "import"."imposm_imports" WHERE finished IS NULL ORDER BY id DESC LIMIT 1`
+	if sql := unfinishedImportSQL("import"); sql != expected {
+		t.Errorf("unexpected SQL\n%s\n%s", sql, expected)
+	}
+}
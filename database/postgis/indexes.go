@@ -0,0 +1,399 @@
+package postgis
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strings"
+
+	"github.com/omniscale/imposm3/mapping"
+)
+
+// Additional indexes of the mapping are created in Finish, after the
+// geometry and OSM id indexes. Expressions are only checked for balanced
+// parentheses, all other errors are reported by PostgreSQL. GIN indexes
+// on columns require a container column (hstore or jsonb), e.g. for
+// tags ? 'wikidata' queries. Names of indexes are truncated to the
+// maximum identifier length of PostgreSQL, with a hash of the full name.
+
+const defaultIndexMethod = "btree"
+
+// maxIdentifierLength is the maximum length of identifiers in PostgreSQL
+// (NAMEDATALEN - 1), longer names are truncated by PostgreSQL.
+const maxIdentifierLength = 63
+
+// includeMethods are the index methods that support INCLUDE columns.
+var includeMethods = map[string]bool{
+	"btree":  true,
+	"gist":   true,
+	"spgist": true,
+}
+
+var indexMethods = map[string]bool{
+	"btree":  true,
+	"hash":   true,
+	"gist":   true,
+	"spgist": true,
+	"gin":    true,
+	"brin":   true,
+}
+
+// ginOpclasses are the operator classes for GIN indexes on columns of
+// each type. The first is the default of PostgreSQL.
+var ginOpclasses = map[string][]string{
+	"HSTORE": {"gin_hstore_ops"},
+	"JSONB":  {"jsonb_ops", "jsonb_path_ops"},
+}
+
+type IndexSpec struct {
+	Column string
+	// Columns of composite indexes
+	Columns    []IndexColumn
+	Expression string
+	Method     string
+	// Opclass of the column, only for GIN indexes
+	Opclass string
+	// Include are the non-key columns of covering indexes
+	Include []string
+	// position of the index in the mapping, for error messages
+	position int
+}
+
+// IndexColumn is a column of a composite index, with the optional sort
+// order (e.g. DESC NULLS LAST).
+type IndexColumn struct {
+	Name  string
+	Order string
+}
+
+// parseIndexColumn parses "name [ASC|DESC] [NULLS FIRST|NULLS LAST]".
+func parseIndexColumn(s string) (IndexColumn, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return IndexColumn{}, fmt.Errorf("empty column")
+	}
+	col := IndexColumn{Name: fields[0]}
+	order := fields[1:]
+	for i := range order {
+		order[i] = strings.ToUpper(order[i])
+	}
+	rest := order
+	if len(rest) > 0 && (rest[0] == "ASC" || rest[0] == "DESC") {
+		rest = rest[1:]
+	}
+	if len(rest) == 2 && rest[0] == "NULLS" && (rest[1] == "FIRST" || rest[1] == "LAST") {
+		rest = nil
+	}
+	if len(rest) > 0 {
+		return IndexColumn{}, fmt.Errorf("invalid sort order '%s' of column %s", strings.Join(fields[1:], " "), col.Name)
+	}
+	col.Order = strings.Join(order, " ")
+	return col, nil
+}
+
+// IndexError is returned if PostgreSQL fails to create an index of the
+// mapping.
+type IndexError struct {
+	Table string
+	Index IndexSpec
+	err   error
+}
+
+func (e *IndexError) Error() string {
+	return fmt.Sprintf("unable to create index %d (%s) of table %s: %s",
+		e.Index.position+1, e.Index.description(), e.Table, e.err)
+}
+
+func (idx *IndexSpec) description() string {
+	var desc string
+	switch {
+	case idx.Expression != "":
+		desc = fmt.Sprintf("expression '%s'", idx.Expression)
+	case len(idx.Columns) > 0:
+		desc = "columns " + strings.Join(idx.columnNames(), ", ")
+	default:
+		desc = "column " + idx.Column
+	}
+	if len(idx.Include) > 0 {
+		desc += " include " + strings.Join(idx.Include, ", ")
+	}
+	return desc
+}
+
+// columnNames returns the names of all columns of the index.
+func (idx *IndexSpec) columnNames() []string {
+	if idx.Column != "" {
+		return []string{idx.Column}
+	}
+	var names []string
+	for _, col := range idx.Columns {
+		names = append(names, col.Name)
+	}
+	return names
+}
+
+// indexName returns the name of the index. Expressions are hashed, as
+// they can't be used in the name. Composite indexes join the names of
+// the columns, covering indexes append the INCLUDE columns.
+func (idx *IndexSpec) indexName(names indexNameTemplate, tableName string) string {
+	column := strings.Join(idx.columnNames(), "_")
+	if idx.Expression != "" {
+		h := fnv.New32a()
+		h.Write([]byte(idx.Method + " " + idx.Expression))
+		column = fmt.Sprintf("expr_%08x", h.Sum32())
+	}
+	if len(idx.Include) > 0 {
+		// distinct from the index without INCLUDE
+		column += "_incl_" + strings.Join(idx.Include, "_")
+	}
+	return names.name(tableName, column, truncateIdentifier(fmt.Sprintf("%s_%s_idx", tableName, column)))
+}
+
+// truncateIdentifier returns names that exceed maxIdentifierLength
+// truncated, with a hash of the full name, so that long names remain
+// unique.
+func truncateIdentifier(name string) string {
+	if len(name) <= maxIdentifierLength {
+		return name
+	}
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	suffix := fmt.Sprintf("_%08x", h.Sum32())
+	return name[:maxIdentifierLength-len(suffix)] + suffix
+}
+
+// IndexSQL returns the CREATE INDEX statement for the index. INCLUDE is
+// only supported by PostgreSQL (11 or newer).
+func (idx *IndexSpec) IndexSQL(d Dialect, names indexNameTemplate, schema, tableName string) string {
+	target := idx.Expression
+	if target == "" && len(idx.Columns) > 0 {
+		var cols []string
+		for _, col := range idx.Columns {
+			c := d.QuoteIdent(col.Name)
+			if col.Order != "" {
+				c += " " + col.Order
+			}
+			cols = append(cols, c)
+		}
+		target = strings.Join(cols, ", ")
+	} else if target == "" {
+		target = d.QuoteIdent(idx.Column)
+		if idx.Opclass != "" {
+			target += " " + idx.Opclass
+		}
+	}
+	sql := d.IndexSQL(idx.indexName(names, tableName), schema, tableName, idx.Method, target)
+	if len(idx.Include) > 0 {
+		var cols []string
+		for _, name := range idx.Include {
+			cols = append(cols, d.QuoteIdent(name))
+		}
+		sql += fmt.Sprintf(" INCLUDE (%s)", strings.Join(cols, ", "))
+	}
+	return sql
+}
+
+// createMappingIndexes creates all additional indexes of the table.
+func createMappingIndexes(pg *PostGIS, spec *TableSpec) error {
+	for _, idx := range spec.Indexes {
+		sql := idx.IndexSQL(spec.dialect(), spec.indexNames(), spec.Schema, spec.FullName)
+		step := log.StartStep(fmt.Sprintf("Creating index on %s (%s)", spec.FullName, idx.description()))
+		_, err := pg.Db.Exec(sql)
+		log.StopStep(step)
+		if err != nil {
+			return &IndexError{spec.Name, idx, &SQLError{sql, err}}
+		}
+	}
+	return nil
+}
+
+// newIndexSpecs returns the specs for the indexes of the mapping and all
+// problems.
+func newIndexSpecs(spec *TableSpec, indexes []*mapping.Index) ([]IndexSpec, []string) {
+	var specs []IndexSpec
+	var problems []string
+	for i, index := range indexes {
+		idx := IndexSpec{
+			Column:     index.Column,
+			Expression: strings.TrimSpace(index.Expression),
+			Method:     strings.ToLower(index.Method),
+			Opclass:    strings.ToLower(index.Opclass),
+			Include:    index.Include,
+			position:   i,
+		}
+		if idx.Method == "" {
+			idx.Method = defaultIndexMethod
+		}
+		problem := ""
+		switch {
+		case len(index.Columns) > 0 && (idx.Column != "" || idx.Expression != ""):
+			problem = "columns can not be combined with column or expression"
+		case len(index.Columns) > 0:
+			idx.Columns, problem = newIndexColumns(spec, index.Columns)
+		case idx.Column != "" && idx.Expression != "":
+			problem = "requires either column or expression"
+		case idx.Column != "":
+			if !spec.hasColumn(idx.Column) {
+				problem = fmt.Sprintf("unknown column %s", idx.Column)
+			}
+		case idx.Expression != "":
+			if !balancedParens(idx.Expression) {
+				problem = fmt.Sprintf("unbalanced parentheses in expression '%s'", idx.Expression)
+			}
+		default:
+			problem = "requires column or expression"
+		}
+		if problem == "" && !indexMethods[idx.Method] {
+			problem = fmt.Sprintf("unknown method '%s'", index.Method)
+		}
+		if problem == "" {
+			problem = checkGinIndex(spec, idx)
+		}
+		if problem == "" && idx.Method != "btree" && idx.hasOrder() {
+			problem = fmt.Sprintf("sort order requires method btree, not %s", idx.Method)
+		}
+		if problem == "" && len(idx.Include) > 0 {
+			problem = checkIncludeColumns(spec, idx)
+		}
+		if problem != "" {
+			problems = append(problems, fmt.Sprintf("index %d %s", i+1, problem))
+			continue
+		}
+		specs = append(specs, idx)
+	}
+	return specs, problems
+}
+
+// checkIncludeColumns returns the problem of the first INCLUDE column
+// that does not exist or that is a key column of the index.
+func checkIncludeColumns(spec *TableSpec, idx IndexSpec) string {
+	if !includeMethods[idx.Method] {
+		return fmt.Sprintf("include requires method btree, gist or spgist, not %s", idx.Method)
+	}
+	keys := make(map[string]bool)
+	for _, name := range idx.columnNames() {
+		keys[name] = true
+	}
+	for _, name := range idx.Include {
+		if !spec.hasColumn(name) {
+			return fmt.Sprintf("unknown include column %s", name)
+		}
+		if keys[name] {
+			return fmt.Sprintf("include column %s is a key column of the index", name)
+		}
+	}
+	return ""
+}
+
+// newIndexColumns returns the columns of a composite index, or the
+// problem of the first invalid column.
+func newIndexColumns(spec *TableSpec, columns []string) ([]IndexColumn, string) {
+	var cols []IndexColumn
+	for _, c := range columns {
+		col, err := parseIndexColumn(c)
+		if err != nil {
+			return nil, err.Error()
+		}
+		if !spec.hasColumn(col.Name) {
+			return nil, fmt.Sprintf("unknown column %s", col.Name)
+		}
+		cols = append(cols, col)
+	}
+	return cols, ""
+}
+
+func (idx *IndexSpec) hasOrder() bool {
+	for _, col := range idx.Columns {
+		if col.Order != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// checkGinIndex returns the problem of a GIN index on a column that is
+// not a container, or of an opclass that does not match the column.
+func checkGinIndex(spec *TableSpec, idx IndexSpec) string {
+	if idx.Opclass != "" && (idx.Method != "gin" || idx.Column == "") {
+		return fmt.Sprintf("opclass %s requires method gin and a column", idx.Opclass)
+	}
+	if idx.Method != "gin" || idx.Expression != "" {
+		return ""
+	}
+	var typ string
+	for _, name := range idx.columnNames() {
+		typ = "INTEGER" // serial id
+		for _, col := range spec.Columns {
+			if col.Name == name {
+				typ = col.Type.Name()
+			}
+		}
+		if _, ok := ginOpclasses[typ]; !ok {
+			return fmt.Sprintf("method gin requires hstore or jsonb column, %s is %s", name, typ)
+		}
+	}
+	opclasses := ginOpclasses[typ]
+	if idx.Opclass == "" {
+		return ""
+	}
+	for _, opclass := range opclasses {
+		if opclass == idx.Opclass {
+			return ""
+		}
+	}
+	return fmt.Sprintf("opclass %s not supported for %s column %s", idx.Opclass, typ, idx.Column)
+}
+
+// hasColumn returns whether the table has a column with this name,
+// including columns that are added by imposm.
+func (spec *TableSpec) hasColumn(name string) bool {
+	for _, col := range spec.Columns {
+		if col.Name == name {
+			return true
+		}
+	}
+	if spec.hasSerialId() && name == "id" {
+		return true
+	}
+	if spec.TileIndex != nil && spec.TileIndex.Name == name {
+		return true
+	}
+	if spec.GenerationColumn != "" && name == spec.GenerationColumn {
+		return true
+	}
+	if spec.LastModifiedColumn != "" && name == spec.LastModifiedColumn {
+		return true
+	}
+	if spec.ImportIDColumn != "" && name == spec.ImportIDColumn {
+		return true
+	}
+	return name == spec.TimestampColumn || (spec.SoftDelete && name == deletedColumn)
+}
+
+// balancedParens returns whether all parentheses of the SQL expression
+// outside of string literals and quoted identifiers are balanced.
+func balancedParens(expr string) bool {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(expr); i++ {
+		c := expr[i]
+		if quote != 0 {
+			if c == quote {
+				// doubled quotes end and restart the quote
+				quote = 0
+			}
+			continue
+		}
+		switch c {
+		case '\'', '"':
+			quote = c
+		case '(':
+			depth += 1
+		case ')':
+			depth -= 1
+			if depth < 0 {
+				return false
+			}
+		}
+	}
+	return depth == 0 && quote == 0
+}
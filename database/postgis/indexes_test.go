@@ -0,0 +1,173 @@
+package postgis
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/omniscale/imposm3/mapping"
+)
+
+func TestIndexSQL(t *testing.T) {
+	table := testTable()
+	table.Indexes = []*mapping.Index{
+		{Column: "name"},
+		{Expression: "lower(name)"},
+		{Expression: "(tags->'ref')", Method: "HASH"},
+		{Column: "tags", Method: "gin", Opclass: "gin_hstore_ops"},
+	}
+	spec := testTableSpec(t, testPostGIS(), table)
+	if len(spec.Indexes) != 4 {
+		t.Fatal("unexpected indexes", spec.Indexes)
+	}
+
+	if sql := spec.Indexes[0].IndexSQL(PostgreSQL, "", spec.Schema, spec.FullName); sql != `CREATE INDEX "osm_roads_name_idx" ON "import"."osm_roads" USING btree ("name")` {
+		t.Error("unexpected SQL", sql)
+	}
+	sql := spec.Indexes[1].IndexSQL(PostgreSQL, "", spec.Schema, spec.FullName)
+	if !strings.HasPrefix(sql, `CREATE INDEX "osm_roads_expr_`) || !strings.HasSuffix(sql, `_idx" ON "import"."osm_roads" USING btree (lower(name))`) {
+		t.Error("unexpected SQL", sql)
+	}
+	if sql := spec.Indexes[2].IndexSQL(PostgreSQL, "", spec.Schema, spec.FullName); !strings.HasSuffix(sql, `USING hash ((tags->'ref'))`) {
+		t.Error("unexpected SQL", sql)
+	}
+
+	if sql := spec.Indexes[3].IndexSQL(PostgreSQL, "", spec.Schema, spec.FullName); sql != `CREATE INDEX "osm_roads_tags_idx" ON "import"."osm_roads" USING gin ("tags" gin_hstore_ops)` {
+		t.Error("unexpected SQL", sql)
+	}
+
+	name := spec.Indexes[1].indexName("", spec.FullName)
+	if name != spec.Indexes[1].indexName("", spec.FullName) || name == spec.Indexes[2].indexName("", spec.FullName) {
+		t.Error("unexpected index names", name, spec.Indexes[2].indexName("", spec.FullName))
+	}
+}
+
+func TestCompositeIndexSQL(t *testing.T) {
+	table := testTable()
+	table.Indexes = []*mapping.Index{
+		{Columns: []string{"name", "osm_id desc nulls last"}},
+	}
+	spec := testTableSpec(t, testPostGIS(), table)
+	expected := `CREATE INDEX "osm_roads_name_osm_id_idx" ON "import"."osm_roads" USING btree ("name", "osm_id" DESC NULLS LAST)`
+	if sql := spec.Indexes[0].IndexSQL(PostgreSQL, "", spec.Schema, spec.FullName); sql != expected {
+		t.Errorf("unexpected SQL\n%s\n%s", sql, expected)
+	}
+	if d := spec.Indexes[0].description(); d != "columns name, osm_id" {
+		t.Error("unexpected description", d)
+	}
+}
+
+func TestCoveringIndexSQL(t *testing.T) {
+	table := testTable()
+	table.Indexes = []*mapping.Index{
+		{Column: "name", Include: []string{"osm_id", "tags"}},
+		{Columns: []string{"name DESC"}, Include: []string{"osm_id"}},
+		{Column: "geometry", Method: "gist", Include: []string{"name"}},
+	}
+	spec := testTableSpec(t, testPostGIS(), table)
+	expected := `CREATE INDEX "osm_roads_name_incl_osm_id_tags_idx" ON "import"."osm_roads" USING btree ("name") INCLUDE ("osm_id", "tags")`
+	if sql := spec.Indexes[0].IndexSQL(PostgreSQL, "", spec.Schema, spec.FullName); sql != expected {
+		t.Errorf("unexpected SQL\n%s\n%s", sql, expected)
+	}
+	expected = `CREATE INDEX "osm_roads_name_incl_osm_id_idx" ON "import"."osm_roads" USING btree ("name" DESC) INCLUDE ("osm_id")`
+	if sql := spec.Indexes[1].IndexSQL(PostgreSQL, "", spec.Schema, spec.FullName); sql != expected {
+		t.Errorf("unexpected SQL\n%s\n%s", sql, expected)
+	}
+	if sql := spec.Indexes[2].IndexSQL(PostgreSQL, "", spec.Schema, spec.FullName); !strings.HasSuffix(sql, `USING gist ("geometry") INCLUDE ("name")`) {
+		t.Error("unexpected SQL", sql)
+	}
+	if d := spec.Indexes[0].description(); d != "column name include osm_id, tags" {
+		t.Error("unexpected description", d)
+	}
+}
+
+func TestIndexNameTruncated(t *testing.T) {
+	idx := IndexSpec{Columns: []IndexColumn{
+		{Name: "highway_classification"}, {Name: "z_order"}, {Name: "bridge_or_tunnel_layer"},
+	}}
+	name := idx.indexName("", "osm_transport_lines")
+	if len(name) != maxIdentifierLength || !strings.HasPrefix(name, "osm_transport_lines_highway_classification_z_order_") {
+		t.Error("unexpected name", name)
+	}
+	idx.Columns[2].Name = "bridge_or_tunnel_layers"
+	if other := idx.indexName("", "osm_transport_lines"); other == name || len(other) != maxIdentifierLength {
+		t.Error("truncated names not unique", name, other)
+	}
+	if name := (&IndexSpec{Column: "name"}).indexName("", "osm_roads"); name != "osm_roads_name_idx" {
+		t.Error("unexpected name", name)
+	}
+}
+
+func TestIndexProblems(t *testing.T) {
+	for _, test := range []struct {
+		index   mapping.Index
+		problem string
+	}{
+		{mapping.Index{Column: "id"}, ""},
+		{mapping.Index{Expression: `lower(name) || ')'`}, ""},
+		{mapping.Index{Expression: `("tags"->'a(')`}, ""},
+		{mapping.Index{}, "index 1 requires column or expression"},
+		{mapping.Index{Expression: "  "}, "index 1 requires column or expression"},
+		{mapping.Index{Column: "name", Expression: "lower(name)"}, "index 1 requires either column or expression"},
+		{mapping.Index{Column: "ref"}, "index 1 unknown column ref"},
+		{mapping.Index{Expression: "lower(name"}, "index 1 unbalanced parentheses in expression 'lower(name'"},
+		{mapping.Index{Expression: "lower(name))("}, "unbalanced parentheses"},
+		{mapping.Index{Expression: "lower(name) || 'foo"}, "unbalanced parentheses"},
+		{mapping.Index{Column: "name", Method: "rtree"}, "index 1 unknown method 'rtree'"},
+		{mapping.Index{Column: "tags", Method: "gin"}, ""},
+		{mapping.Index{Column: "tags", Method: "GIN", Opclass: "gin_hstore_ops"}, ""},
+		{mapping.Index{Expression: "to_tsvector('simple', name)", Method: "gin"}, ""},
+		{mapping.Index{Column: "name", Method: "gin"}, "index 1 method gin requires hstore or jsonb column, name is VARCHAR"},
+		{mapping.Index{Column: "id", Method: "gin"}, "index 1 method gin requires hstore or jsonb column, id is INTEGER"},
+		{mapping.Index{Column: "tags", Method: "gin", Opclass: "jsonb_path_ops"}, "index 1 opclass jsonb_path_ops not supported for HSTORE column tags"},
+		{mapping.Index{Column: "tags", Opclass: "gin_hstore_ops"}, "index 1 opclass gin_hstore_ops requires method gin and a column"},
+		{mapping.Index{Columns: []string{"name", "id DESC", "osm_id nulls last"}}, ""},
+		{mapping.Index{Columns: []string{"name", "ref"}}, "index 1 unknown column ref"},
+		{mapping.Index{Columns: []string{"name", "osm_id DESCENDING"}}, "index 1 invalid sort order 'DESCENDING' of column osm_id"},
+		{mapping.Index{Columns: []string{"name ASC NULLS"}}, "index 1 invalid sort order 'ASC NULLS' of column name"},
+		{mapping.Index{Columns: []string{"name", " "}}, "index 1 empty column"},
+		{mapping.Index{Columns: []string{"name"}, Column: "name"}, "index 1 columns can not be combined with column or expression"},
+		{mapping.Index{Columns: []string{"name DESC"}, Method: "hash"}, "index 1 sort order requires method btree, not hash"},
+		{mapping.Index{Columns: []string{"tags", "name"}, Method: "gin"}, "index 1 method gin requires hstore or jsonb column, name is VARCHAR"},
+		{mapping.Index{Column: "name", Include: []string{"osm_id"}}, ""},
+		{mapping.Index{Expression: "lower(name)", Include: []string{"name"}}, ""},
+		{mapping.Index{Column: "name", Include: []string{"osm_id", "ref"}}, "index 1 unknown include column ref"},
+		{mapping.Index{Columns: []string{"name", "osm_id"}, Include: []string{"osm_id"}}, "index 1 include column osm_id is a key column of the index"},
+		{mapping.Index{Column: "tags", Method: "gin", Include: []string{"name"}}, "index 1 include requires method btree, gist or spgist, not gin"},
+	} {
+		table := testTable()
+		index := test.index
+		table.Indexes = []*mapping.Index{&index}
+		_, err := NewTableSpec(testPostGIS(), table)
+		if test.problem == "" {
+			if err != nil {
+				t.Error("unexpected error", err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), test.problem) {
+			t.Errorf("expected %q for %+v, got %v", test.problem, test.index, err)
+		}
+	}
+}
+
+func TestCreateMappingIndexesError(t *testing.T) {
+	db, d := newFakeDb()
+	defer db.Close()
+	pg := testPostGIS()
+	pg.Db = db
+	table := testTable()
+	table.Indexes = []*mapping.Index{{Column: "name"}, {Expression: "lower(nam)"}}
+	spec := testTableSpec(t, pg, table)
+
+	d.fail = `CREATE INDEX "osm_roads_expr_`
+	err := createMappingIndexes(pg, spec)
+	if _, ok := err.(*IndexError); !ok {
+		t.Fatal("expected IndexError", err)
+	}
+	if !strings.HasPrefix(err.Error(), "unable to create index 2 (expression 'lower(nam)') of table roads: SQL Error: failed by fake driver") {
+		t.Error("unexpected error message", err)
+	}
+	if n := d.count("CREATE INDEX"); n != 2 {
+		t.Error("unexpected CREATE INDEX execs", n)
+	}
+}
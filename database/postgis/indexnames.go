@@ -0,0 +1,68 @@
+package postgis
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Indexes are named with the IndexNameTemplate of the configuration, e.g.
+// ix_{table}_{column}. {table} is the name of the table and {column} the
+// name of the indexed column. Geohash indexes use {column}_geohash,
+// indexes of the mapping with multiple columns join the names with _ and
+// expression indexes use expr_ with a hash of the expression. Names that
+// exceed maxIdentifierLength are truncated with truncateIdentifier.
+// Without template, indexes are named {table}_geom, {table}_geom_geohash,
+// {table}_osm_id_idx and {table}_{column}_idx.
+
+type indexNameTemplate string
+
+var indexNamePlaceholder = regexp.MustCompile(`\{[^{}]*\}`)
+
+// checkIndexNameTemplate returns an error for unknown placeholders and
+// templates without {table} or {column}, as the names of all indexes in
+// a schema need to be unique.
+func checkIndexNameTemplate(template string) error {
+	if template == "" {
+		return nil
+	}
+	for _, p := range indexNamePlaceholder.FindAllString(template, -1) {
+		if p != "{table}" && p != "{column}" {
+			return fmt.Errorf("unknown placeholder %s in IndexNameTemplate '%s'", p, template)
+		}
+	}
+	if !strings.Contains(template, "{table}") || !strings.Contains(template, "{column}") {
+		return fmt.Errorf("IndexNameTemplate '%s' requires {table} and {column}", template)
+	}
+	rest := strings.NewReplacer("{table}", "", "{column}", "").Replace(template)
+	if strings.ContainsAny(rest, `{}"`) {
+		return fmt.Errorf("invalid IndexNameTemplate '%s'", template)
+	}
+	return nil
+}
+
+// name returns the name of the index of the column, or defaultName
+// without template.
+func (t indexNameTemplate) name(tableName, column, defaultName string) string {
+	if t == "" {
+		return defaultName
+	}
+	return truncateIdentifier(strings.NewReplacer("{table}", tableName, "{column}", column).Replace(string(t)))
+}
+
+func (t indexNameTemplate) geometryIndexName(tableName, column string) string {
+	return t.name(tableName, column, tableName+"_geom")
+}
+
+func (t indexNameTemplate) geohashIndexName(tableName, column string) string {
+	return t.name(tableName, column+"_geohash", tableName+"_geom_geohash")
+}
+
+func (t indexNameTemplate) idIndexName(tableName, column string) string {
+	return t.name(tableName, column, tableName+"_osm_id_idx")
+}
+
+// indexNames returns the IndexNameTemplate of the spec.
+func (spec *TableSpec) indexNames() indexNameTemplate {
+	return indexNameTemplate(spec.IndexNameTemplate)
+}
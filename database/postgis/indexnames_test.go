@@ -0,0 +1,85 @@
+package postgis
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/omniscale/imposm3/mapping"
+)
+
+func TestIndexNameTemplate(t *testing.T) {
+	table := testTable()
+	table.Indexes = []*mapping.Index{{Column: "name"}, {Expression: "lower(name)"}}
+	pg := testPostGIS()
+	pg.Config.IndexNameTemplate = "ix_{table}_{column}"
+	spec := testTableSpec(t, pg, table)
+
+	var names []string
+	for _, idx := range managedIndexes(spec.dialect(), spec.indexNames(), spec.Schema, spec.FullName, spec.Srid, spec.Columns, spec.IdIndex, spec.Indexes) {
+		names = append(names, idx.name)
+		if !strings.HasPrefix(idx.sql, `CREATE INDEX "`+idx.name+`"`) {
+			t.Errorf("unexpected SQL for %s: %s", idx.name, idx.sql)
+		}
+	}
+	expected := []string{"ix_osm_roads_osm_id", "ix_osm_roads_geometry", "ix_osm_roads_geometry_geohash", "ix_osm_roads_name", "ix_osm_roads_expr_"}
+	if len(names) != len(expected) {
+		t.Fatal("unexpected indexes", names)
+	}
+	for i, name := range names {
+		if !strings.HasPrefix(name, expected[i]) {
+			t.Error("unexpected index name", name, expected[i])
+		}
+	}
+
+	long := indexNameTemplate("{table}_{column}_" + strings.Repeat("x", 60))
+	if name := long.geometryIndexName("osm_roads", "geometry"); len(name) != maxIdentifierLength {
+		t.Error("name not truncated", name)
+	}
+	if name := indexNameTemplate("").geohashIndexName("osm_roads", "geometry"); name != "osm_roads_geom_geohash" {
+		t.Error("unexpected default name", name)
+	}
+}
+
+func TestCheckIndexNameTemplate(t *testing.T) {
+	for _, template := range []string{"", "ix_{table}_{column}", "{column}_on_{table}"} {
+		if err := checkIndexNameTemplate(template); err != nil {
+			t.Errorf("unexpected error for %q: %s", template, err)
+		}
+	}
+	for template, msg := range map[string]string{
+		"ix_{table}_{col}":     "unknown placeholder {col}",
+		"ix_{table}":           "requires {table} and {column}",
+		"ix_{column}":          "requires {table} and {column}",
+		"ix_{table}_{column}}": "invalid IndexNameTemplate",
+		`ix_"{table}_{column}`: "invalid IndexNameTemplate",
+	} {
+		if err := checkIndexNameTemplate(template); err == nil || !strings.Contains(err.Error(), msg) {
+			t.Errorf("unexpected error for %q: %v", template, err)
+		}
+	}
+}
+
+func TestOptimizeIndexNameTemplate(t *testing.T) {
+	db, d := newFakeDb()
+	defer db.Close()
+	pg := testPostGIS()
+	pg.Db = db
+	pg.Config.IndexNameTemplate = "ix_{table}_{column}"
+	pg.Tables = map[string]*TableSpec{"roads": testTableSpec(t, pg, testTable())}
+	if err := pg.Finish(); err != nil {
+		t.Fatal(err)
+	}
+	if err := clusterTable(pg, PostgreSQL, pg.Tables["roads"].indexNames(), "import", "osm_roads", 3857, pg.Tables["roads"].Columns); err != nil {
+		t.Fatal(err)
+	}
+	for _, sql := range []string{
+		`CREATE INDEX "ix_osm_roads_geometry" ON "import"."osm_roads" USING GIST ("geometry")`,
+		`CREATE INDEX "ix_osm_roads_osm_id" ON "import"."osm_roads" USING BTREE ("osm_id")`,
+		`CREATE INDEX "ix_osm_roads_geometry_geohash" ON "import"."osm_roads"`,
+		`CLUSTER "ix_osm_roads_geometry_geohash" ON "import"."osm_roads"`,
+	} {
+		if n := d.count(sql); n != 1 {
+			t.Errorf("missing %s in %v", sql, d.execs)
+		}
+	}
+}
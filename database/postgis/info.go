@@ -0,0 +1,132 @@
+package postgis
+
+import (
+	"database/sql"
+	"regexp"
+	"strings"
+)
+
+// ServerInfo contains version information about the PostgreSQL server,
+// the PostGIS extension and all available extensions.
+type ServerInfo struct {
+	// Version is the full output of version().
+	Version string
+	// PostgreSQLVersion is the version number of the server (e.g. 9.3.5).
+	PostgreSQLVersion string
+	// PostGISFullVersion is the full output of postgis_full_version().
+	PostGISFullVersion string
+	// PostGISVersion is the version number of PostGIS (e.g. 2.1.3).
+	PostGISVersion string
+	// PostGISComponents contains all components of postgis_full_version()
+	// (POSTGIS, GEOS, PROJ, LIBXML, etc.).
+	PostGISComponents map[string]string
+	Extensions        []Extension
+}
+
+// Extension is an entry of pg_available_extensions.
+type Extension struct {
+	Name             string
+	DefaultVersion   string
+	InstalledVersion string // empty if extension is not installed
+}
+
+// ServerInfo queries the version of the PostgreSQL server, PostGIS and
+// the available extensions.
+func (pg *PostGIS) ServerInfo() (ServerInfo, error) {
+	info := ServerInfo{}
+
+	sql := "SELECT version()"
+	if err := pg.Db.QueryRow(sql).Scan(&info.Version); err != nil {
+		return info, &SQLError{sql, err}
+	}
+	info.PostgreSQLVersion = parsePostgreSQLVersion(info.Version)
+
+	sql = "SELECT postgis_full_version()"
+	if err := pg.Db.QueryRow(sql).Scan(&info.PostGISFullVersion); err != nil {
+		return info, &SQLError{sql, err}
+	}
+	info.PostGISComponents = parsePostGISFullVersion(info.PostGISFullVersion)
+	info.PostGISVersion = postGISVersion(info.PostGISComponents)
+
+	extensions, err := availableExtensions(pg.Db)
+	if err != nil {
+		return info, err
+	}
+	info.Extensions = extensions
+	return info, nil
+}
+
+func availableExtensions(db *sql.DB) ([]Extension, error) {
+	sql := "SELECT name, default_version, installed_version FROM pg_available_extensions ORDER BY name"
+	rows, err := db.Query(sql)
+	if err != nil {
+		return nil, &SQLError{sql, err}
+	}
+	defer rows.Close()
+
+	var extensions []Extension
+	for rows.Next() {
+		var name, defaultVersion, installedVersion nullString
+		if err := rows.Scan(&name, &defaultVersion, &installedVersion); err != nil {
+			return nil, &SQLError{sql, err}
+		}
+		extensions = append(extensions, Extension{
+			Name:             string(name),
+			DefaultVersion:   string(defaultVersion),
+			InstalledVersion: string(installedVersion),
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, &SQLError{sql, err}
+	}
+	return extensions, nil
+}
+
+// nullString scans NULL as an empty string.
+type nullString string
+
+func (s *nullString) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*s = ""
+	case []byte:
+		*s = nullString(v)
+	case string:
+		*s = nullString(v)
+	}
+	return nil
+}
+
+var postgresqlVersionRe = regexp.MustCompile(`^PostgreSQL (\d+(?:\.\d+)*\w*)`)
+
+// parsePostgreSQLVersion returns the version number from the
+// output of version() (e.g. "PostgreSQL 9.3.5 on x86_64-unknown-linux-gnu, ...").
+func parsePostgreSQLVersion(version string) string {
+	m := postgresqlVersionRe.FindStringSubmatch(version)
+	if m == nil {
+		return ""
+	}
+	return m[1]
+}
+
+var postgisComponentRe = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// parsePostGISFullVersion returns all KEY="value" components from the
+// output of postgis_full_version().
+func parsePostGISFullVersion(version string) map[string]string {
+	components := make(map[string]string)
+	for _, m := range postgisComponentRe.FindAllStringSubmatch(version, -1) {
+		components[m[1]] = m[2]
+	}
+	return components
+}
+
+// postGISVersion returns the version number of the POSTGIS component
+// without revision (e.g. "2.1.3" for POSTGIS="2.1.3 r12547").
+func postGISVersion(components map[string]string) string {
+	fields := strings.Fields(components["POSTGIS"])
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[0]
+}
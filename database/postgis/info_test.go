@@ -0,0 +1,56 @@
+package postgis
+
+import (
+	"testing"
+)
+
+func TestParsePostgreSQLVersion(t *testing.T) {
+	for _, test := range []struct {
+		version  string
+		expected string
+	}{
+		{"PostgreSQL 9.3.5 on x86_64-unknown-linux-gnu, compiled by gcc (Ubuntu 4.8.2-19ubuntu1) 4.8.2, 64-bit", "9.3.5"},
+		{"PostgreSQL 9.4beta2 on x86_64-apple-darwin13.3.0, compiled by Apple LLVM version 5.1, 64-bit", "9.4beta2"},
+		{"PostgreSQL 10.1, compiled by Visual C++ build 1800, 64-bit", "10.1"},
+		{"EnterpriseDB 9.3", ""},
+		{"", ""},
+	} {
+		if v := parsePostgreSQLVersion(test.version); v != test.expected {
+			t.Errorf("%q: %q != %q", test.version, v, test.expected)
+		}
+	}
+}
+
+func TestParsePostGISFullVersion(t *testing.T) {
+	components := parsePostGISFullVersion(`POSTGIS="2.1.3 r12547" GEOS="3.4.2-CAPI-1.8.2 r3921" PROJ="Rel. 4.8.0, 6 March 2012" GDAL="GDAL 1.10.1, released 2013/08/26" LIBXML="2.9.1" LIBJSON="UNKNOWN" RASTER`)
+
+	for k, v := range map[string]string{
+		"POSTGIS": "2.1.3 r12547",
+		"GEOS":    "3.4.2-CAPI-1.8.2 r3921",
+		"PROJ":    "Rel. 4.8.0, 6 March 2012",
+		"GDAL":    "GDAL 1.10.1, released 2013/08/26",
+		"LIBXML":  "2.9.1",
+		"LIBJSON": "UNKNOWN",
+	} {
+		if components[k] != v {
+			t.Errorf("%s: %q != %q", k, components[k], v)
+		}
+	}
+	if len(components) != 6 {
+		t.Error("unexpected components", components)
+	}
+	if v := postGISVersion(components); v != "2.1.3" {
+		t.Error("unexpected PostGIS version", v)
+	}
+}
+
+func TestParsePostGISFullVersionLegacy(t *testing.T) {
+	components := parsePostGISFullVersion(`POSTGIS="1.5.3" GEOS="3.2.2-CAPI-1.6.2" PROJ="Rel. 4.7.1, 23 September 2009" LIBXML="2.7.8" USE_STATS`)
+	if v := postGISVersion(components); v != "1.5.3" {
+		t.Error("unexpected PostGIS version", v)
+	}
+
+	if v := postGISVersion(parsePostGISFullVersion("")); v != "" {
+		t.Error("unexpected PostGIS version", v)
+	}
+}
@@ -0,0 +1,144 @@
+package postgis
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Tables with inherits are created with INHERITS (parent) after their
+// parent table, for osm2pgsql-style partitioning. Rows are inserted into
+// the table of the mapping and queries on the parent include the rows of
+// all child tables. The child inherits the geometry column of the parent,
+// so both need the same geometry column. Child tables are dropped before
+// their parents, as DropGeometryTable does not cascade.
+
+// prepareInheritance sets Inherits of all tables with a parent table and
+// returns all problems.
+func (pg *PostGIS) prepareInheritance() error {
+	var errs TableSpecErrors
+	for name, spec := range pg.Tables {
+		if spec.inherits == "" {
+			continue
+		}
+		parent, ok := pg.Tables[spec.inherits]
+		if !ok {
+			errs = append(errs, &TableSpecError{name, []string{
+				fmt.Sprintf("unknown parent table %s", spec.inherits)}})
+			continue
+		}
+		if problems := checkInheritance(spec, parent); len(problems) > 0 {
+			errs = append(errs, &TableSpecError{name, problems})
+			continue
+		}
+		spec.Inherits = parent
+		parent.hasChildren = true
+	}
+	if len(errs) == 0 {
+		for name, spec := range pg.Tables {
+			if spec.inheritanceDepth() < 0 {
+				errs = append(errs, &TableSpecError{name, []string{"inherits from itself or a child table"}})
+			}
+		}
+	}
+	if len(errs) > 0 {
+		sort.Sort(errs)
+		return errs
+	}
+	return nil
+}
+
+// checkInheritance returns all problems of the child table that would
+// fail with the columns of the parent table.
+func checkInheritance(spec, parent *TableSpec) []string {
+	var problems []string
+	parentTypes := make(map[string]string)
+	for _, col := range parent.Columns {
+		parentTypes[col.Name] = col.Type.Name()
+	}
+	for _, col := range spec.Columns {
+		if typ, ok := parentTypes[col.Name]; ok && typ != col.Type.Name() {
+			problems = append(problems, fmt.Sprintf("column %s is %s in parent table %s",
+				col.Name, typ, parent.Name))
+		}
+	}
+	parentGenerated := make(map[string]bool)
+	for _, col := range parent.GeneratedColumns {
+		parentGenerated[col.Name] = true
+	}
+	for _, col := range spec.Columns {
+		if parentGenerated[col.Name] {
+			problems = append(problems, fmt.Sprintf("column %s is generated in parent table %s",
+				col.Name, parent.Name))
+		}
+	}
+	for _, col := range spec.GeneratedColumns {
+		if _, ok := parentTypes[col.Name]; ok || parentGenerated[col.Name] {
+			problems = append(problems, fmt.Sprintf("generated column %s is defined in parent table %s",
+				col.Name, parent.Name))
+		}
+	}
+	if parent.hasGeometry() {
+		parentGeom := parent.Columns[parent.geometryColumnIndex()].Name
+		idx := spec.geometryColumnIndex()
+		switch {
+		case idx < 0 || spec.Columns[idx].Name != parentGeom:
+			problems = append(problems, fmt.Sprintf("requires geometry column %s of parent table %s",
+				parentGeom, parent.Name))
+		case spec.GeometryType != parent.GeometryType || spec.Srid != parent.Srid:
+			problems = append(problems, fmt.Sprintf("geometry (%s, %d) differs from parent table %s (%s, %d)",
+				spec.GeometryType, spec.Srid, parent.Name, parent.GeometryType, parent.Srid))
+		case spec.GeometrySubtype != parent.GeometrySubtype:
+			problems = append(problems, fmt.Sprintf("geometry_subtype '%s' differs from parent table %s ('%s')",
+				spec.GeometrySubtype, parent.Name, parent.GeometrySubtype))
+		}
+		if spec.TileIndex != nil && parent.TileIndex != nil {
+			problems = append(problems, fmt.Sprintf("tile_index is inherited from parent table %s", parent.Name))
+		}
+	}
+	return problems
+}
+
+// inheritanceDepth returns the number of parents of the table, or -1 if
+// the parents contain a cycle.
+func (spec *TableSpec) inheritanceDepth() int {
+	seen := map[*TableSpec]bool{spec: true}
+	depth := 0
+	for p := spec.Inherits; p != nil; p = p.Inherits {
+		if seen[p] {
+			return -1
+		}
+		seen[p] = true
+		depth += 1
+	}
+	return depth
+}
+
+// inheritsGeometry returns whether the geometry column is inherited from
+// the parent table.
+func (spec *TableSpec) inheritsGeometry() bool {
+	return spec.Inherits != nil && spec.Inherits.hasGeometry()
+}
+
+// tablesParentsFirst returns all tables, parents before their child
+// tables (and tables with a foreign key to them) and sorted by name
+// otherwise.
+func (pg *PostGIS) tablesParentsFirst() []*TableSpec {
+	tables := make([]*TableSpec, 0, len(pg.Tables))
+	for _, spec := range pg.Tables {
+		tables = append(tables, spec)
+	}
+	sort.Sort(tablesByDepth(tables))
+	return tables
+}
+
+type tablesByDepth []*TableSpec
+
+func (t tablesByDepth) Len() int { return len(t) }
+func (t tablesByDepth) Less(i, j int) bool {
+	di, dj := t[i].dependencyDepth(), t[j].dependencyDepth()
+	if di != dj {
+		return di < dj
+	}
+	return t[i].Name < t[j].Name
+}
+func (t tablesByDepth) Swap(i, j int) { t[i], t[j] = t[j], t[i] }
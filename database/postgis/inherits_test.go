@@ -0,0 +1,106 @@
+package postgis
+
+import (
+	"strings"
+	"testing"
+)
+
+func testInheritedTables(t *testing.T, pg *PostGIS) {
+	roads := testTable()
+	motorways := testTable()
+	motorways.Name = "motorways"
+	motorways.Inherits = "roads"
+	links := testTable()
+	links.Name = "motorway_links"
+	links.Inherits = "motorways"
+	pg.Tables = map[string]*TableSpec{
+		"motorway_links": testTableSpec(t, pg, links),
+		"motorways":      testTableSpec(t, pg, motorways),
+		"roads":          testTableSpec(t, pg, roads),
+	}
+	if err := pg.prepareInheritance(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInheritsSQL(t *testing.T) {
+	pg := testPostGIS()
+	testInheritedTables(t, pg)
+
+	sql := pg.Tables["motorways"].CreateTableSQL()
+	if !strings.HasSuffix(sql, `) INHERITS ("import"."osm_roads");`) {
+		t.Error("unexpected sql", sql)
+	}
+	if sql := pg.Tables["roads"].CreateTableSQL(); strings.Contains(sql, "INHERITS") {
+		t.Error("unexpected sql", sql)
+	}
+	if !pg.Tables["motorways"].inheritsGeometry() || pg.Tables["roads"].inheritsGeometry() {
+		t.Error("unexpected inherited geometry")
+	}
+
+	if sql := pg.Tables["roads"].DeleteSQL(); sql != `DELETE FROM ONLY "import"."osm_roads" WHERE "osm_id" = $1` {
+		t.Error("unexpected sql", sql)
+	}
+	if sql := pg.Tables["motorway_links"].DeleteSQL(); sql != `DELETE FROM "import"."osm_motorway_links" WHERE "osm_id" = $1` {
+		t.Error("unexpected sql", sql)
+	}
+}
+
+func TestInheritsOrder(t *testing.T) {
+	pg := testPostGIS()
+	testInheritedTables(t, pg)
+
+	var names []string
+	for _, spec := range pg.tablesParentsFirst() {
+		names = append(names, spec.Name)
+	}
+	if s := strings.Join(names, ","); s != "roads,motorways,motorway_links" {
+		t.Error("unexpected create order", s)
+	}
+
+	names = nil
+	for _, table := range pg.deployTables() {
+		names = append(names, table.name)
+	}
+	if s := strings.Join(names, ","); s != "osm_motorway_links,osm_motorways,osm_roads" {
+		t.Error("unexpected deploy order", s)
+	}
+}
+
+func TestInheritsProblems(t *testing.T) {
+	pg := testPostGIS()
+	roads := testTable()
+	points := testTable()
+	points.Name = "points"
+	points.Type = "point"
+	points.Inherits = "roads"
+	unknown := testTable()
+	unknown.Name = "unknown"
+	unknown.Inherits = "streets"
+	pg.Tables = map[string]*TableSpec{
+		"roads":   testTableSpec(t, pg, roads),
+		"points":  testTableSpec(t, pg, points),
+		"unknown": testTableSpec(t, pg, unknown),
+	}
+	err := pg.prepareInheritance()
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	errs := err.(TableSpecErrors)
+	if len(errs) != 2 || errs[0].Table != "points" || errs[1].Table != "unknown" {
+		t.Fatal("unexpected errors", err)
+	}
+	if !strings.Contains(errs[0].Error(), "geometry (point, 3857) differs from parent table roads (linestring, 3857)") {
+		t.Error("unexpected error", errs[0])
+	}
+	if !strings.Contains(errs[1].Error(), "unknown parent table streets") {
+		t.Error("unexpected error", errs[1])
+	}
+
+	pg = testPostGIS()
+	roads.Inherits = "roads"
+	pg.Tables = map[string]*TableSpec{"roads": testTableSpec(t, pg, roads)}
+	if err := pg.prepareInheritance(); err == nil || !strings.Contains(err.Error(), "inherits from itself") {
+		t.Error("unexpected error", err)
+	}
+}
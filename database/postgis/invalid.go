@@ -0,0 +1,203 @@
+package postgis
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/omniscale/imposm3/mapping"
+)
+
+// Rows that are rejected by the importer (e.g. geometries exceeding
+// max_vertices) are inserted into a <table>_invalid table, if enabled
+// with Config.InvalidTables. These tables have the same columns as the
+// original table, but with a GEOMETRY column without type constraints and
+// with additional error_reason and error_detail columns.
+
+const invalidTableSuffix = "_invalid"
+
+type rejectedRow struct {
+	row    []interface{}
+	reason string
+	detail string
+}
+
+// rejectReason returns a short reason for the error that is stored in
+// the error_reason column.
+func rejectReason(err error) string {
+	switch err.(type) {
+	case *VertexLimitError:
+		return "vertex_limit"
+	case *DuplicateRowError:
+		return "duplicate"
+	case *EnumValueError:
+		return "enum"
+	default:
+		return "error"
+	}
+}
+
+// InvalidTableName returns the name of the table for rejected rows.
+func (spec *TableSpec) InvalidTableName() string {
+	return spec.FullName + invalidTableSuffix
+}
+
+func (spec *TableSpec) CreateInvalidTableSQL() string {
+	cols := []string{}
+	if spec.hasSerialId() {
+		cols = append(cols, "id SERIAL PRIMARY KEY")
+	}
+	for _, col := range spec.Columns {
+		if col.enum != nil {
+			// invalid values would fail the insert into the invalid table
+			cols = append(cols, fmt.Sprintf(`"%s" VARCHAR`, col.Name))
+			continue
+		}
+		// GEOMETRY column without AddGeometryColumn constraints
+		cols = append(cols, col.AsSQL())
+	}
+	cols = append(cols, `"error_reason" VARCHAR`, `"error_detail" VARCHAR`)
+	columnSQL := strings.Join(cols, ",\n")
+	return fmt.Sprintf(`
+        CREATE TABLE IF NOT EXISTS "%s"."%s" (
+            %s
+        );`,
+		spec.Schema,
+		spec.InvalidTableName(),
+		columnSQL,
+	)
+}
+
+func (spec *TableSpec) InsertInvalidSQL() string {
+	var cols []string
+	var vars []string
+	for i, col := range spec.Columns {
+		cols = append(cols, "\""+col.Name+"\"")
+		if col.enum != nil {
+			vars = append(vars, fmt.Sprintf("$%d", i+1))
+			continue
+		}
+		vars = append(vars, col.Type.PrepareInsertSql(i+1, spec))
+	}
+	n := len(spec.Columns)
+	cols = append(cols, `"error_reason"`, `"error_detail"`)
+	vars = append(vars, fmt.Sprintf("$%d", n+1), fmt.Sprintf("$%d", n+2))
+
+	return fmt.Sprintf(`INSERT INTO "%s"."%s" (%s) VALUES (%s)`,
+		spec.Schema,
+		spec.InvalidTableName(),
+		strings.Join(cols, ", "),
+		strings.Join(vars, ", "),
+	)
+}
+
+// invalidArgs returns the arguments for InsertInvalidSQL.
+func (r rejectedRow) invalidArgs(spec *TableSpec) []interface{} {
+	args := make([]interface{}, len(spec.Columns), len(spec.Columns)+2)
+	copy(args, r.row)
+	if idx := spec.geometryColumnIndex(); spec.GeometryEncoding == GeometryEncodingWkt && idx >= 0 && args[idx] != nil {
+		// invalid WKT would fail the insert into the invalid table
+		if wkt, ok := args[idx].(string); !ok {
+			args[idx] = nil
+		} else if _, err := parseWkt(wkt); err != nil {
+			args[idx] = nil
+		}
+	}
+	for i, col := range spec.Columns {
+		if _, ok := col.Type.(*uuidColumnType); ok && args[i] != nil {
+			// invalid UUIDs would fail the insert into the invalid table
+			v, err := mapping.CanonicalUUID(args[i])
+			if err != nil {
+				args[i] = nil
+			} else {
+				args[i] = v
+			}
+		}
+		if _, ok := col.Type.(*tstzrangeColumnType); ok && args[i] != nil {
+			// invalid ranges would fail the insert into the invalid table
+			v, err := mapping.TstzRangeLiteral(args[i])
+			if err != nil {
+				args[i] = nil
+			} else {
+				args[i] = v
+			}
+		}
+		if t, ok := col.Type.(*decimalColumnType); ok && args[i] != nil {
+			// rejected overflows would fail the insert into the invalid table
+			v, overflow, err := t.encode(args[i])
+			if err != nil || overflow {
+				args[i] = nil
+			} else {
+				args[i] = v
+			}
+		}
+		if _, ok := col.Type.(*geometryHashColumnType); ok {
+			// hashes are only calculated for inserted rows
+			args[i] = nil
+		}
+		if t, ok := col.Type.(*osmTypeColumnType); ok && args[i] != nil {
+			v, err := t.value(args[i])
+			if err != nil {
+				args[i] = nil
+			} else {
+				args[i] = v
+			}
+		}
+	}
+	return append(args, r.reason, r.detail)
+}
+
+func createInvalidTable(tx *sql.Tx, spec TableSpec) error {
+	if err := dropTableIfExists(tx, spec.Schema, spec.InvalidTableName()); err != nil {
+		return err
+	}
+	sql := spec.CreateInvalidTableSQL()
+	if _, err := tx.Exec(sql); err != nil {
+		return &SQLError{sql, err}
+	}
+	return nil
+}
+
+// insertRejected inserts all rows into the invalid table of spec.
+func insertRejected(tx *sql.Tx, spec *TableSpec, rows []rejectedRow) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	sql := spec.InsertInvalidSQL()
+	stmt, err := tx.Prepare(sql)
+	if err != nil {
+		return &SQLError{sql, err}
+	}
+	defer stmt.Close()
+	for _, r := range rows {
+		args := r.invalidArgs(spec)
+		if _, err := stmt.Exec(args...); err != nil {
+			return &SQLInsertError{SQLError{sql, err}, args}
+		}
+	}
+	return nil
+}
+
+// dropEmptyInvalidTables removes all invalid tables without rows.
+func (pg *PostGIS) dropEmptyInvalidTables() error {
+	for _, spec := range pg.Tables {
+		if !spec.InvalidTable {
+			continue
+		}
+		sql := fmt.Sprintf(`SELECT EXISTS(SELECT 1 FROM "%s"."%s")`,
+			spec.Schema, spec.InvalidTableName())
+		var notEmpty bool
+		if err := pg.Db.QueryRow(sql).Scan(&notEmpty); err != nil {
+			return &SQLError{sql, err}
+		}
+		if notEmpty {
+			log.Warnf("rejected rows in %s", spec.InvalidTableName())
+			continue
+		}
+		sql = fmt.Sprintf(`DROP TABLE "%s"."%s"`, spec.Schema, spec.InvalidTableName())
+		if _, err := pg.Db.Exec(sql); err != nil {
+			return &SQLError{sql, err}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package postgis
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCreateInvalidTableSQL(t *testing.T) {
+	pg := testPostGIS()
+	pg.Config.InvalidTables = true
+	spec := testTableSpec(t, pg, testTable())
+
+	if name := spec.InvalidTableName(); name != "osm_roads_invalid" {
+		t.Error("unexpected name", name)
+	}
+	sql := spec.CreateInvalidTableSQL()
+	for _, part := range []string{
+		`CREATE TABLE IF NOT EXISTS "import"."osm_roads_invalid"`,
+		`id SERIAL PRIMARY KEY`,
+		`"osm_id" BIGINT`,
+		`"geometry" GEOMETRY`,
+		`"name" VARCHAR`,
+		`"error_reason" VARCHAR`,
+		`"error_detail" VARCHAR`,
+	} {
+		if !strings.Contains(sql, part) {
+			t.Errorf("%s not in %s", part, sql)
+		}
+	}
+}
+
+func TestInsertInvalidSQL(t *testing.T) {
+	spec := testTableSpec(t, testPostGIS(), testTable())
+
+	expected := `INSERT INTO "import"."osm_roads_invalid" ("osm_id", "geometry", "name", "tags", "error_reason", "error_detail") VALUES ($1, $2::Geometry, $3, $4, $5, $6)`
+	if sql := spec.InsertInvalidSQL(); sql != expected {
+		t.Errorf("unexpected SQL\n%s\n%s", sql, expected)
+	}
+
+	err := &VertexLimitError{"osm_roads", int64(42), 100, 10}
+	args := rejectedRow{[]interface{}{int64(42), "0101", "foo", ""}, rejectReason(err), err.Error()}.invalidArgs(spec)
+	if len(args) != 6 || args[0] != int64(42) || args[4] != "vertex_limit" || args[5] != err.Error() {
+		t.Error("unexpected args", args)
+	}
+
+	if reason := rejectReason(errors.New("foo")); reason != "error" {
+		t.Error("unexpected reason", reason)
+	}
+}
+
+func TestTableNamesWithInvalidTables(t *testing.T) {
+	pg := testPostGIS()
+	pg.Config.InvalidTables = true
+	pg.Tables = map[string]*TableSpec{"roads": testTableSpec(t, pg, testTable())}
+
+	tables := pg.deployTables()
+	if len(tables) != 2 || tables[0].name != "osm_roads" || tables[1].name != "osm_roads_invalid" {
+		t.Error("unexpected table names", tables)
+	}
+}
@@ -0,0 +1,19 @@
+package postgis
+
+import (
+	"fmt"
+)
+
+// Tables with LastModifiedColumn have a column with the time of the last
+// change of each row, e.g. to query the changes since a given time. New
+// rows get the DEFAULT now() of the column (also rows of ReplaceBatch)
+// and COPY sets the start time of the import transaction, like for the
+// TimestampColumn. Upserts only set now() if the row changed (see
+// changedSQL), upserts of unchanged rows keep the time.
+
+// lastModifiedUpdateSQL returns the SET of the LastModifiedColumn for
+// UpsertSQL.
+func (spec *TableSpec) lastModifiedUpdateSQL() string {
+	return fmt.Sprintf(`"%s" = CASE WHEN %s THEN now() ELSE "%s"."%s" END`,
+		spec.LastModifiedColumn, spec.changedSQL(), spec.FullName, spec.LastModifiedColumn)
+}
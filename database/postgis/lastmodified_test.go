@@ -0,0 +1,75 @@
+package postgis
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/omniscale/imposm3/mapping"
+)
+
+func lastModifiedTable() *mapping.Table {
+	table := testTable()
+	table.Upsert = true
+	table.LastModifiedColumn = "last_modified"
+	return table
+}
+
+func TestLastModifiedSQL(t *testing.T) {
+	table := lastModifiedTable()
+	table.Indexes = []*mapping.Index{{Column: "last_modified"}}
+	spec := testTableSpec(t, testPostGIS(), table)
+
+	if sql := spec.CreateTableSQL(); !strings.Contains(sql, `"last_modified" TIMESTAMP WITH TIME ZONE DEFAULT now()`) {
+		t.Error("missing last_modified column", sql)
+	}
+	if sql := spec.InsertSQL(); strings.Contains(sql, "last_modified") {
+		t.Error("last_modified column in INSERT", sql)
+	}
+	// unchanged rows keep the time, changes of the geometry or of other
+	// columns set now()
+	expected := `"last_modified" = CASE WHEN (ST_AsEWKB("osm_roads"."geometry"), "osm_roads"."name", "osm_roads"."tags") ` +
+		`IS DISTINCT FROM (ST_AsEWKB(EXCLUDED."geometry"), EXCLUDED."name", EXCLUDED."tags") THEN now() ELSE "osm_roads"."last_modified" END`
+	if sql := spec.UpsertSQL(); !strings.HasSuffix(sql, expected) {
+		t.Errorf("unexpected SQL\n%s\n%s", sql, expected)
+	}
+	if sql := spec.Indexes[0].IndexSQL(PostgreSQL, "", spec.Schema, spec.FullName); sql != `CREATE INDEX "osm_roads_last_modified_idx" ON "import"."osm_roads" USING btree ("last_modified")` {
+		t.Error("unexpected index", sql)
+	}
+}
+
+func TestLastModifiedGeometryHash(t *testing.T) {
+	table := lastModifiedTable()
+	table.Fields = append(table.Fields, &mapping.Field{Name: "geometry_hash", Type: "geometry_hash"})
+	spec := testTableSpec(t, testPostGIS(), table)
+
+	changed := `("osm_roads"."name", "osm_roads"."tags", "osm_roads"."geometry_hash") IS DISTINCT FROM (EXCLUDED."name", EXCLUDED."tags", EXCLUDED."geometry_hash")`
+	expected := `"last_modified" = CASE WHEN ` + changed + ` THEN now() ELSE "osm_roads"."last_modified" END WHERE ` + changed
+	if sql := spec.UpsertSQL(); !strings.HasSuffix(sql, expected) {
+		t.Errorf("unexpected SQL\n%s\n%s", sql, expected)
+	}
+}
+
+func TestLastModifiedCopy(t *testing.T) {
+	table := lastModifiedTable()
+	table.Upsert = false
+	table.TimestampColumn = "imported_at"
+	spec := testTableSpec(t, testPostGIS(), table)
+
+	if sql := spec.CopySQL(); sql != `COPY "import"."osm_roads" ("osm_id", "geometry", "name", "tags", "imported_at", "last_modified") FROM STDIN` {
+		t.Error("unexpected COPY", sql)
+	}
+	now := time.Now()
+	if row := spec.timestampRow(make([]interface{}, 4), now); len(row) != 6 || row[4] != now || row[5] != now {
+		t.Error("unexpected row", row)
+	}
+
+	table.LastModifiedColumn = "imported_at"
+	if _, err := NewTableSpec(testPostGIS(), table); err == nil || !strings.Contains(err.Error(), "defined by timestamp_column and last_modified_column") {
+		t.Error("expected error", err)
+	}
+	table.LastModifiedColumn = "name"
+	if _, err := NewTableSpec(testPostGIS(), table); err == nil || !strings.Contains(err.Error(), "and last_modified_column") {
+		t.Error("expected error", err)
+	}
+}
@@ -0,0 +1,98 @@
+package postgis
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Bulk imports insert rows with COPY, unless the table requires INSERT
+// (upsert, transformed geometries, grid size, geometry_subtype or defaults for NULL values). The load
+// method of the table or of Config.LoadMethod selects INSERT, COPY or
+// staging for all other tables. Tables with auto start with INSERT and switch to COPY
+// after CopyThresholdRows rows, so that small tables are not copied.
+//
+// Tables with staging copy the rows into a temporary staging table (see
+// stagingSpec) and insert them with INSERT ... SELECT when the COPY is
+// finished. The staging table is created in the transaction of the
+// import, on the same connection as the COPY and the INSERT, and it is
+// dropped with each commit.
+
+const (
+	LoadMethodAuto    = "auto"
+	LoadMethodCopy    = "copy"
+	LoadMethodInsert  = "insert"
+	LoadMethodStaging = "staging"
+)
+
+func checkLoadMethodName(method string) error {
+	switch method {
+	case "", LoadMethodAuto, LoadMethodCopy, LoadMethodInsert, LoadMethodStaging:
+		return nil
+	}
+	return fmt.Errorf("unknown load method '%s'", method)
+}
+
+// checkLoadMethod returns the problems of the load_method of the mapping.
+func checkLoadMethod(spec *TableSpec, method string) []string {
+	if err := checkLoadMethodName(method); err != nil {
+		return []string{err.Error()}
+	}
+	if (method == LoadMethodCopy || method == LoadMethodStaging) && !spec.canCopy() {
+		return []string{fmt.Sprintf("load_method %s not possible with upsert, transformed geometries, grid size, geometry_subtype or defaults for NULL values", method)}
+	}
+	return nil
+}
+
+// canCopy returns whether the rows can be inserted with COPY.
+func (spec *TableSpec) canCopy() bool {
+	// COPY is not able to update existing rows, to transform or reduce
+	// the precision of geometries or to replace NULL values with defaults
+	return spec.dialect().SupportsCopy() && !spec.Upsert && !spec.transformGeometry() && !spec.reduceGeometry() &&
+		spec.GeometrySubtype == "" && !spec.coalesceDefaults()
+}
+
+// useCopy returns whether the bulk import of the table starts with COPY.
+func (spec *TableSpec) useCopy() bool {
+	if !spec.canCopy() {
+		return false
+	}
+	switch spec.LoadMethod {
+	case LoadMethodCopy, LoadMethodStaging:
+		return true
+	case LoadMethodInsert:
+		return false
+	}
+	return spec.CopyThresholdRows <= 0
+}
+
+// switchToCopy returns whether the bulk import of the table switches
+// from INSERT to COPY after rows inserted rows.
+func (spec *TableSpec) switchToCopy(rows int64) bool {
+	if spec.LoadMethod != "" && spec.LoadMethod != LoadMethodAuto {
+		return false
+	}
+	return spec.CopyThresholdRows > 0 && rows >= int64(spec.CopyThresholdRows) && spec.canCopy()
+}
+
+// stagingSpec returns the temporary staging table of the table for
+// LoadMethodStaging. It has the columns of the table, but no parent table
+// or constraints for other tables.
+func (spec *TableSpec) stagingSpec() *TableSpec {
+	staging := *spec
+	staging.Schema = "pg_temp"
+	staging.FullName = spec.FullName + "_staging"
+	staging.OnCommitDrop = true
+	staging.Inherits = nil
+	staging.referencedBy = nil
+	return &staging
+}
+
+// insertFromSQL returns the INSERT of all copied columns of the source
+// table, e.g. of the staging table.
+func (spec *TableSpec) insertFromSQL(source *TableSpec) string {
+	d := spec.dialect()
+	columns := strings.Join(spec.copyColumns(d), ", ")
+	return fmt.Sprintf(`INSERT INTO %s (%s) SELECT %s FROM %s`,
+		d.QualifiedName(spec.Schema, spec.FullName), columns, columns,
+		d.QualifiedName(source.Schema, source.FullName))
+}
@@ -0,0 +1,167 @@
+package postgis
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoadMethodSelection(t *testing.T) {
+	pg := testPostGIS()
+	pg.Config.LoadMethod = LoadMethodInsert
+	lookup := testTable()
+	copied := testTable()
+	copied.LoadMethod = LoadMethodCopy
+	upsert := testTable()
+	upsert.Upsert = true
+
+	if spec := testTableSpec(t, pg, lookup); spec.useCopy() || spec.switchToCopy(1000) {
+		t.Error("global insert uses copy")
+	}
+	if spec := testTableSpec(t, pg, copied); !spec.useCopy() {
+		t.Error("table copy does not use copy")
+	}
+
+	pg.Config.LoadMethod = LoadMethodCopy
+	if spec := testTableSpec(t, pg, upsert); spec.useCopy() {
+		t.Error("upsert uses copy")
+	}
+	upsert.LoadMethod = LoadMethodCopy
+	if _, err := NewTableSpec(pg, upsert); err == nil || !strings.Contains(err.Error(), "load_method copy not possible") {
+		t.Error("unexpected error", err)
+	}
+
+	pg.Config.LoadMethod = ""
+	pg.Config.CopyThresholdRows = 100
+	spec := testTableSpec(t, pg, lookup)
+	if spec.useCopy() || spec.switchToCopy(99) || !spec.switchToCopy(100) {
+		t.Error("unexpected auto load method")
+	}
+	lookup.LoadMethod = "bulk"
+	if _, err := NewTableSpec(pg, lookup); err == nil || !strings.Contains(err.Error(), "unknown load method 'bulk'") {
+		t.Error("unexpected error", err)
+	}
+}
+
+func TestLoadMethodAutoSwitchesToCopy(t *testing.T) {
+	db, d := newFakeDb()
+	pg := testPostGIS()
+	pg.Db = db
+	pg.Config.CopyThresholdRows = 2
+	spec := testTableSpec(t, pg, testTable())
+	pg.Tables = map[string]*TableSpec{"roads": spec}
+
+	tt := NewBulkTableTx(pg, spec)
+	if err := tt.Begin(nil); err != nil {
+		t.Fatal(err)
+	}
+	line := ewkbLineString(3857, 0, 0, 1, 1).hex()
+	for i := 1; i <= 5; i++ {
+		tt.Insert([]interface{}{int64(i), line, "", ""})
+	}
+	if err := tt.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	if n := d.count("INSERT"); n != 2 {
+		t.Error("unexpected inserts", n)
+	}
+	if n := len(d.values("COPY", 0)); n != 3 {
+		t.Error("unexpected copied rows", n)
+	}
+	if n := pg.RowCounts()["roads"].Inserted; n != 5 {
+		t.Error("unexpected inserted rows", n)
+	}
+}
+
+func TestLoadMethodAutoSwitchError(t *testing.T) {
+	db, d := newFakeDb()
+	defer db.Close()
+	pg := testPostGIS()
+	pg.Db = db
+	pg.Config.CopyThresholdRows = 2
+	spec := testTableSpec(t, pg, testTable())
+	d.failPrepare = "COPY"
+
+	tt := NewBulkTableTx(pg, spec)
+	if err := tt.Begin(nil); err != nil {
+		t.Fatal(err)
+	}
+	line := ewkbLineString(3857, 0, 0, 1, 1).hex()
+	for i := 1; i <= 5; i++ {
+		tt.Insert([]interface{}{int64(i), line, "", ""})
+	}
+	if err := tt.Commit(); err == nil || !strings.Contains(err.Error(), "COPY") {
+		t.Fatal("expected error of COPY", err)
+	}
+	// following rows are ignored
+	if n := d.count("INSERT"); n != 2 {
+		t.Error("unexpected inserts", n)
+	}
+	if d.commits != 0 || d.rollbacks != 1 {
+		t.Error("unexpected commits/rollbacks", d.commits, d.rollbacks)
+	}
+}
+
+func TestLoadMethodStaging(t *testing.T) {
+	db, d := newFakeDb()
+	defer db.Close()
+	pg := testPostGIS()
+	pg.Db = db
+	table := testTable()
+	table.LoadMethod = LoadMethodStaging
+	spec := testTableSpec(t, pg, table)
+
+	tt := NewBulkTableTx(pg, spec).(*bulkTableTx)
+	if err := tt.Begin(nil); err != nil {
+		t.Fatal(err)
+	}
+	line := ewkbLineString(3857, 0, 0, 1, 1).hex()
+	for i := 1; i <= 3; i++ {
+		tt.Insert([]interface{}{int64(i), line, "", ""})
+	}
+	if err := tt.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	tt.Insert([]interface{}{int64(4), line, "", ""})
+	if err := tt.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	// staging table in each transaction, dropped with the commit
+	create := `
+        CREATE TEMP TABLE IF NOT EXISTS "osm_roads_staging" (`
+	if n := d.count(create); n != 2 || !strings.HasSuffix(d.execs[1], ") ON COMMIT DROP;") {
+		t.Error("unexpected staging table", n, d.execs[1])
+	}
+	if n := len(d.values(`COPY "osm_roads_staging" ("osm_id", "geometry", "name", "tags") FROM STDIN`, 0)); n != 4 {
+		t.Error("unexpected copied rows", n)
+	}
+	insert := `INSERT INTO "import"."osm_roads" ("osm_id", "geometry", "name", "tags") ` +
+		`SELECT "osm_id", "geometry", "name", "tags" FROM "osm_roads_staging"`
+	if n := d.count(insert); n != 2 {
+		t.Error("unexpected inserts of staged rows", n)
+	}
+	// the staged rows are inserted after the COPY and before the next COPY
+	first := func(prefix string) int {
+		for i, e := range d.execs {
+			if strings.HasPrefix(e, prefix) {
+				return i
+			}
+		}
+		return -1
+	}
+	if !(first(create) < first("COPY") && first("COPY") < first(insert) &&
+		first(insert) < first(`TRUNCATE TABLE "osm_roads_staging"`)) {
+		t.Error("unexpected order", d.execs)
+	}
+	if d.commits != 2 || d.rollbacks != 0 {
+		t.Error("unexpected commits/rollbacks", d.commits, d.rollbacks)
+	}
+
+	table.Fields[2].Type = "string"
+	pg.Config.GridSize = 0.1
+	if _, err := NewTableSpec(pg, table); err == nil || !strings.Contains(err.Error(), "load_method staging not possible") {
+		t.Error("expected error for grid size", err)
+	}
+}
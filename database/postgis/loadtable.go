@@ -0,0 +1,98 @@
+package postgis
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// LoadFromTable loads the rows of an existing table that was not created
+// by imposm (e.g. from a previous import tool) into the table (by name),
+// with a single INSERT INTO ... SELECT. columns maps the columns of the
+// table to the columns of srcTable. srcTable can be qualified with the
+// schema (schema.table). Columns without source column are NULL (or their
+// default). Values are cast to the types of the columns and geometries
+// are transformed and reduced like the geometries of imports. It returns
+// the number of loaded rows.
+func (pg *PostGIS) LoadFromTable(table, srcTable string, columns map[string]string) (int64, error) {
+	spec, ok := pg.Tables[table]
+	if !ok {
+		return 0, fmt.Errorf("unknown table %s", table)
+	}
+	sql, err := spec.loadFromTableSQL(srcTable, columns)
+	if err != nil {
+		return 0, err
+	}
+	defer log.StopStep(log.StartStep(fmt.Sprintf("Loading %s from %s", spec.FullName, srcTable)))
+	res, err := pg.Db.Exec(sql)
+	if err != nil {
+		return 0, &SQLError{sql, err}
+	}
+	return res.RowsAffected()
+}
+
+// loadFromTableSQL returns the INSERT INTO ... SELECT statement of
+// LoadFromTable. The columns are in the order of the table.
+func (spec *TableSpec) loadFromTableSQL(srcTable string, columns map[string]string) (string, error) {
+	if len(columns) == 0 {
+		return "", errors.New("no columns to load")
+	}
+	var unknown []string
+	for col := range columns {
+		if !spec.hasColumn(col) || col == spec.ImportIDColumn {
+			unknown = append(unknown, col)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return "", fmt.Errorf("unknown columns %s of table %s", strings.Join(unknown, ", "), spec.Name)
+	}
+
+	d := spec.dialect()
+	geomIdx := spec.geometryColumnIndex()
+	var cols, values []string
+	for i, col := range spec.Columns {
+		src, ok := columns[col.Name]
+		if !ok {
+			continue
+		}
+		if src == "" {
+			return "", fmt.Errorf("missing source column for %s", col.Name)
+		}
+		value := d.QuoteIdent(src)
+		if i == geomIdx {
+			value = spec.loadGeometrySQL(value)
+		} else {
+			value = d.Cast(value, col.Type.Name())
+		}
+		cols = append(cols, d.QuoteIdent(col.Name))
+		values = append(values, col.insertSQL(value))
+	}
+	if len(cols) == 0 {
+		return "", fmt.Errorf("no columns of table %s to load", spec.Name)
+	}
+	if spec.ImportIDColumn != "" {
+		cols = append(cols, d.QuoteIdent(spec.ImportIDColumn))
+		values = append(values, spec.importIDSQL())
+	}
+
+	src := d.QuoteIdent(srcTable)
+	if parts := strings.SplitN(srcTable, ".", 2); len(parts) == 2 {
+		src = d.QualifiedName(parts[0], parts[1])
+	}
+	return fmt.Sprintf(`INSERT INTO %s (%s) SELECT %s FROM %s`,
+		d.QualifiedName(spec.Schema, spec.FullName),
+		strings.Join(cols, ", "), strings.Join(values, ", "), src), nil
+}
+
+// loadGeometrySQL returns the geometry of the source column for
+// loadFromTableSQL. Geometries are transformed from their SRID, also if
+// the InputSrid is the SRID of the table.
+func (spec *TableSpec) loadGeometrySQL(geom string) string {
+	if !spec.transformGeometry() && spec.Srid != 0 {
+		// ST_Transform does not change geometries in the SRID of the table
+		return spec.subtypeGeometrySQL(spec.gridGeometrySQL(fmt.Sprintf("ST_Transform(%s, %d)", geom, spec.Srid)))
+	}
+	return spec.insertGeometrySQL(geom)
+}
@@ -0,0 +1,56 @@
+package postgis
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoadFromTableSQL(t *testing.T) {
+	spec := testTableSpec(t, testPostGIS(), testTable())
+	columns := map[string]string{"osm_id": "gid", "geometry": "geom", "name": "name"}
+	sql, err := spec.loadFromTableSQL("legacy.roads", columns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `INSERT INTO "import"."osm_roads" ("osm_id", "geometry", "name") SELECT "gid"::BIGINT, ST_Transform("geom", 3857), "name"::VARCHAR FROM "legacy"."roads"`
+	if sql != expected {
+		t.Errorf("unexpected SQL\n%s\n%s", sql, expected)
+	}
+
+	table := testTable()
+	table.Srid = 25832
+	pg := testPostGIS()
+	pg.Config.GridSize = 0.01
+	spec = testTableSpec(t, pg, table)
+	sql, err = spec.loadFromTableSQL("roads", map[string]string{"geometry": "the_geom"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(sql, `SELECT ST_ReducePrecision(ST_Transform("the_geom", 25832), 0.01) FROM "roads"`) {
+		t.Error("unexpected SQL", sql)
+	}
+
+	if _, err := spec.loadFromTableSQL("roads", map[string]string{"ref": "ref", "way": "way"}); err == nil || err.Error() != "unknown columns ref, way of table roads" {
+		t.Error("unexpected error", err)
+	}
+	if _, err := spec.loadFromTableSQL("roads", nil); err == nil {
+		t.Error("expected error without columns")
+	}
+}
+
+func TestLoadFromTable(t *testing.T) {
+	db, d := newFakeDb()
+	defer db.Close()
+	pg := testFlushPostGIS(t, db)
+	n, err := pg.LoadFromTable("roads", "legacy.roads", map[string]string{"osm_id": "gid"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the fake driver returns 1 for all RowsAffected
+	if n != 1 || d.count(`INSERT INTO "import"."osm_roads" ("osm_id") SELECT "gid"::BIGINT FROM "legacy"."roads"`) != 1 {
+		t.Error("unexpected load", n, d.execs)
+	}
+	if _, err := pg.LoadFromTable("unknown", "legacy.roads", map[string]string{"osm_id": "gid"}); err == nil {
+		t.Error("expected error for unknown table")
+	}
+}
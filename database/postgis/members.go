@@ -0,0 +1,65 @@
+package postgis
+
+import (
+	"errors"
+
+	"github.com/omniscale/imposm3/element"
+	"github.com/omniscale/imposm3/mapping"
+)
+
+// Relation member tables contain a row for each member of a relation,
+// with the columns of RelationMemberTable. The relation_id is the id
+// column of the table, so deletes of diff imports remove all members of
+// the relation. The sequence_id is the position of the member in the
+// relation, starting with 0.
+
+// RelationMemberTable returns the mapping of a relation member table
+// without geometry.
+func RelationMemberTable(name string) *mapping.Table {
+	return &mapping.Table{
+		Name: name,
+		Type: mapping.NoneTable,
+		Fields: []*mapping.Field{
+			{Name: "relation_id", Type: "id"},
+			{Name: "member_id", Type: "id"},
+			{Name: "member_type", Type: "string"},
+			{Name: "role", Type: "string"},
+			{Name: "sequence_id", Type: "integer"},
+		},
+	}
+}
+
+// NewRelationMemberTableSpec returns the spec of a relation member table.
+func NewRelationMemberTableSpec(pg *PostGIS, name string) (*TableSpec, error) {
+	return NewTableSpec(pg, RelationMemberTable(name))
+}
+
+var memberTypeNames = map[element.MemberType]string{
+	element.NODE:     "node",
+	element.WAY:      "way",
+	element.RELATION: "relation",
+}
+
+// RelationMemberRows returns the rows of all members of the relation, in
+// the order of the members.
+func RelationMemberRows(rel *element.Relation) [][]interface{} {
+	rows := make([][]interface{}, 0, len(rel.Members))
+	for i, m := range rel.Members {
+		rows = append(rows, []interface{}{rel.Id, m.Id, memberTypeNames[m.Type], m.Role, int64(i)})
+	}
+	return rows
+}
+
+// InsertRelationMembers inserts the members of the relation into the
+// relation member table (by name).
+func (pg *PostGIS) InsertRelationMembers(table string, rel *element.Relation) error {
+	if _, ok := pg.Tables[table]; !ok {
+		return errors.New("unknown table " + table)
+	}
+	for _, row := range RelationMemberRows(rel) {
+		if err := pg.txRouter.Insert(table, row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
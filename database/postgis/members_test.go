@@ -0,0 +1,96 @@
+package postgis
+
+import (
+	"database/sql/driver"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/omniscale/imposm3/element"
+)
+
+func TestRelationMemberTableSQL(t *testing.T) {
+	spec, err := NewRelationMemberTableSpec(testPostGIS(), "route_members")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spec.hasGeometry() {
+		t.Error("unexpected geometry")
+	}
+	sql := spec.CreateTableSQL()
+	for _, col := range []string{
+		`"relation_id" BIGINT`,
+		`"member_id" BIGINT`,
+		`"member_type" VARCHAR`,
+		`"role" VARCHAR`,
+		`"sequence_id" INT`,
+	} {
+		if !strings.Contains(sql, col) {
+			t.Errorf("missing %s in\n%s", col, sql)
+		}
+	}
+	if !strings.Contains(sql, `CREATE TABLE IF NOT EXISTS "import"."osm_route_members"`) {
+		t.Error("unexpected table", sql)
+	}
+	expected := `DELETE FROM "import"."osm_route_members" WHERE "relation_id" = $1`
+	if sql := spec.DeleteSQL(); sql != expected {
+		t.Errorf("unexpected SQL\n%s\n%s", sql, expected)
+	}
+}
+
+func TestRelationMemberRows(t *testing.T) {
+	rel := &element.Relation{
+		OSMElem: element.OSMElem{Id: -100},
+		Members: []element.Member{
+			{Id: 1, Type: element.WAY, Role: "outer"},
+			{Id: 2, Type: element.NODE, Role: ""},
+			{Id: 3, Type: element.RELATION, Role: "subarea"},
+		},
+	}
+	rows := RelationMemberRows(rel)
+	expected := [][]interface{}{
+		{int64(-100), int64(1), "way", "outer", int64(0)},
+		{int64(-100), int64(2), "node", "", int64(1)},
+		{int64(-100), int64(3), "relation", "subarea", int64(2)},
+	}
+	if !reflect.DeepEqual(rows, expected) {
+		t.Error("unexpected rows", rows)
+	}
+	if rows := RelationMemberRows(&element.Relation{}); len(rows) != 0 {
+		t.Error("unexpected rows", rows)
+	}
+}
+
+func TestInsertRelationMembers(t *testing.T) {
+	db, d := newFakeDb()
+	defer db.Close()
+	pg := testPostGIS()
+	pg.Db = db
+	spec, err := NewRelationMemberTableSpec(pg, "route_members")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pg.Tables = map[string]*TableSpec{"route_members": spec}
+	if err := pg.BeginBulk(); err != nil {
+		t.Fatal(err)
+	}
+	rel := &element.Relation{
+		OSMElem: element.OSMElem{Id: 5},
+		Members: []element.Member{{Id: 1, Type: element.WAY, Role: "forward"}, {Id: 2, Type: element.WAY}},
+	}
+	if err := pg.InsertRelationMembers("route_members", rel); err != nil {
+		t.Fatal(err)
+	}
+	if err := pg.InsertRelationMembers("routes", rel); err == nil {
+		t.Error("expected error for unknown table")
+	}
+	if err := pg.txRouter.End(); err != nil {
+		t.Fatal(err)
+	}
+	if roles := d.values("COPY", 3); !reflect.DeepEqual(roles, []driver.Value{"forward", ""}) {
+		t.Error("unexpected roles", roles)
+	}
+	if n := spec.rows.load().Inserted; n != 2 {
+		t.Error("unexpected inserted rows", n)
+	}
+}
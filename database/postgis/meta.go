@@ -0,0 +1,55 @@
+package postgis
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// With Config.MappingVersion, Init records which mapping produced each
+// table in the import_meta table (with the table prefix, e.g.
+// osm_import_meta) of the import schema. The table keeps one row for
+// each table, later imports update the row.
+
+const importMetaTable = "import_meta"
+
+func (pg *PostGIS) importMetaTableName() string {
+	return pg.Prefix + importMetaTable
+}
+
+func createImportMetaTableSQL(schema, table string) string {
+	return fmt.Sprintf(`CREATE TABLE IF NOT EXISTS "%s"."%s" (
+            table_name VARCHAR PRIMARY KEY,
+            mapping_name VARCHAR,
+            mapping_version VARCHAR NOT NULL,
+            imported TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+        )`, schema, table)
+}
+
+func importMetaSQL(schema, table string) string {
+	return fmt.Sprintf(`INSERT INTO "%s"."%s" (table_name, mapping_name, mapping_version) VALUES ($1, $2, $3) `+
+		`ON CONFLICT (table_name) DO UPDATE SET mapping_name = EXCLUDED.mapping_name, `+
+		`mapping_version = EXCLUDED.mapping_version, imported = now()`,
+		schema, table)
+}
+
+// recordImportMeta records the mapping of all tables. Tables are recorded
+// as schema.table.
+func (pg *PostGIS) recordImportMeta(tx *sql.Tx, specs []*TableSpec) error {
+	if pg.Config.MappingVersion == "" {
+		return nil
+	}
+	schema := pg.Config.ImportSchema
+	table := pg.importMetaTableName()
+	sql := createImportMetaTableSQL(schema, table)
+	if _, err := tx.Exec(sql); err != nil {
+		return &SQLError{sql, err}
+	}
+	sql = importMetaSQL(schema, table)
+	for _, spec := range specs {
+		name := spec.Schema + "." + spec.FullName
+		if _, err := tx.Exec(sql, name, pg.Config.MappingName, pg.Config.MappingVersion); err != nil {
+			return &SQLInsertError{SQLError{sql, err}, name}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package postgis
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRecordImportMeta(t *testing.T) {
+	db, d := newFakeDb()
+	defer db.Close()
+	pg := testPostGIS()
+	pg.Db = db
+	roads := testTableSpec(t, pg, testTable())
+	table := testTable()
+	table.Name = "buildings"
+	buildings := testTableSpec(t, pg, table)
+	pg.Tables = map[string]*TableSpec{"roads": roads, "buildings": buildings}
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// disabled without MappingVersion
+	if err := pg.recordImportMeta(tx, pg.tablesParentsFirst()); err != nil {
+		t.Fatal(err)
+	}
+	if len(d.execs) != 0 {
+		t.Error("unexpected execs", d.execs)
+	}
+
+	pg.Config.MappingName = "mapping.yml"
+	pg.Config.MappingVersion = "2.1"
+	if err := pg.recordImportMeta(tx, pg.tablesParentsFirst()); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if n := d.count(`CREATE TABLE IF NOT EXISTS "import"."osm_import_meta"`); n != 1 {
+		t.Error("unexpected create table execs", n)
+	}
+	insert := `INSERT INTO "import"."osm_import_meta" (table_name, mapping_name, mapping_version)`
+	names := d.values(insert, 0)
+	if len(names) != 2 || names[0] != "import.osm_buildings" || names[1] != "import.osm_roads" {
+		t.Error("unexpected tables", names)
+	}
+	for idx, expected := range map[int]string{1: "mapping.yml", 2: "2.1"} {
+		for _, v := range d.values(insert, idx) {
+			if v != expected {
+				t.Error("unexpected value", idx, v)
+			}
+		}
+	}
+	if sql := importMetaSQL("import", "osm_import_meta"); !strings.HasSuffix(sql, "imported = now()") {
+		t.Error("expected update of import time", sql)
+	}
+}
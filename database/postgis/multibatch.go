@@ -0,0 +1,100 @@
+package postgis
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+)
+
+// InsertMultiBatch inserts the rows of multiple tables in a single
+// transaction, e.g. a relation and the rows of its members. The tables
+// are inserted in the order of their names. Any error of the database
+// rolls back the rows of all tables. Rows are prepared like all other
+// rows, so skipped rows (null geometry, dedup, Config.OnRowError) do not
+// roll back the batch.
+//
+// The transaction is independent of Begin/End and rows are inserted
+// with INSERT (or the upsert of the table), LoadMethod is ignored.
+func (pg *PostGIS) InsertMultiBatch(batches map[string][][]interface{}) error {
+	tables := make([]string, 0, len(batches))
+	for table := range batches {
+		if _, ok := pg.Tables[table]; !ok {
+			return fmt.Errorf("unknown table %s", table)
+		}
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+
+	tx, err := pg.Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer rollbackIfTx(&tx)
+
+	inserted := make([]int, len(tables))
+	for i, table := range tables {
+		n, err := insertBatch(tx, pg.Tables[table], batches[table])
+		if err != nil {
+			return err
+		}
+		inserted[i] = n
+	}
+	if err := tx.Commit(); err != nil {
+		return geometryCheckError("COMMIT", err)
+	}
+	tx = nil
+
+	// count after the commit, rolled back rows are not inserted
+	for i, table := range tables {
+		for j := 0; j < inserted[i]; j++ {
+			pg.Tables[table].rows.inserted()
+		}
+	}
+	return nil
+}
+
+// insertBatch inserts the rows into the table of spec and returns the
+// number of inserted rows.
+func insertBatch(tx *sql.Tx, spec *TableSpec, rows [][]interface{}) (int, error) {
+	insertSql := spec.InsertSQL()
+	if spec.Upsert {
+		insertSql = spec.UpsertSQL()
+	}
+	stmt, err := tx.Prepare(insertSql)
+	if err != nil {
+		return 0, &SQLError{insertSql, err}
+	}
+	defer stmt.Close()
+
+	// only prepared for rows that need ST_Subdivide
+	subdivideSql := spec.SubdivideInsertSQL()
+	var subdivideStmt *sql.Stmt
+	n := 0
+	for _, row := range rows {
+		prepared, err := spec.prepareRowOrReject(row, insertSql)
+		if err != nil {
+			return 0, err
+		}
+		if prepared == nil {
+			continue
+		}
+		if spec.exceedsSubdivide(prepared) {
+			if subdivideStmt == nil {
+				subdivideStmt, err = tx.Prepare(subdivideSql)
+				if err != nil {
+					return 0, &SQLError{subdivideSql, err}
+				}
+				defer subdivideStmt.Close()
+			}
+			if _, err := subdivideStmt.Exec(prepared...); err != nil {
+				spec.rows.failed()
+				return 0, &SQLInsertError{SQLError{subdivideSql, err}, prepared}
+			}
+		} else if _, err := stmt.Exec(prepared...); err != nil {
+			spec.rows.failed()
+			return 0, &SQLInsertError{SQLError{insertSql, err}, prepared}
+		}
+		n += 1
+	}
+	return n, nil
+}
@@ -0,0 +1,93 @@
+package postgis
+
+import (
+	"testing"
+)
+
+func testMultiBatchPostGIS(t *testing.T) *PostGIS {
+	pg := testPostGIS()
+	buildings := testTable()
+	buildings.Name = "buildings"
+	pg.Tables = map[string]*TableSpec{
+		"roads":     testTableSpec(t, pg, testTable()),
+		"buildings": testTableSpec(t, pg, buildings),
+	}
+	return pg
+}
+
+func testMultiBatch() map[string][][]interface{} {
+	line := ewkbLineString(3857, 0, 0, 1, 1).hex()
+	return map[string][][]interface{}{
+		"roads": {
+			{int64(1), line, "A1", nil},
+			{int64(2), line, "A2", nil},
+		},
+		"buildings": {
+			{int64(3), line, "B1", nil},
+		},
+	}
+}
+
+func TestInsertMultiBatch(t *testing.T) {
+	db, d := newFakeDb()
+	defer db.Close()
+	pg := testMultiBatchPostGIS(t)
+	pg.Db = db
+
+	if err := pg.InsertMultiBatch(testMultiBatch()); err != nil {
+		t.Fatal(err)
+	}
+	if d.commits != 1 || d.rollbacks != 0 {
+		t.Error("unexpected commits/rollbacks", d.commits, d.rollbacks)
+	}
+	if ids := d.values(`INSERT INTO "import"."osm_roads"`, 0); len(ids) != 2 {
+		t.Error("unexpected roads", ids)
+	}
+	if ids := d.values(`INSERT INTO "import"."osm_buildings"`, 0); len(ids) != 1 {
+		t.Error("unexpected buildings", ids)
+	}
+	if n := pg.Tables["roads"].rows.load().Inserted; n != 2 {
+		t.Error("unexpected inserted rows", n)
+	}
+}
+
+func TestInsertMultiBatchRollback(t *testing.T) {
+	db, d := newFakeDb()
+	defer db.Close()
+	pg := testMultiBatchPostGIS(t)
+	pg.Db = db
+
+	// buildings are inserted before roads, roads fail after the buildings
+	d.fail = `INSERT INTO "import"."osm_roads"`
+	err := pg.InsertMultiBatch(testMultiBatch())
+	if _, ok := err.(*SQLInsertError); !ok {
+		t.Fatal("expected SQLInsertError", err)
+	}
+	if ids := d.values(`INSERT INTO "import"."osm_buildings"`, 0); len(ids) != 1 {
+		t.Error("unexpected buildings", ids)
+	}
+	if d.commits != 0 || d.rollbacks != 1 {
+		t.Error("unexpected commits/rollbacks", d.commits, d.rollbacks)
+	}
+	for name, spec := range pg.Tables {
+		if n := spec.rows.load().Inserted; n != 0 {
+			t.Error("rolled back rows counted as inserted", name, n)
+		}
+	}
+}
+
+func TestInsertMultiBatchUnknownTable(t *testing.T) {
+	db, d := newFakeDb()
+	defer db.Close()
+	pg := testMultiBatchPostGIS(t)
+	pg.Db = db
+
+	batches := testMultiBatch()
+	batches["unknown"] = [][]interface{}{{int64(1)}}
+	if err := pg.InsertMultiBatch(batches); err == nil || err.Error() != "unknown table unknown" {
+		t.Error("unexpected error", err)
+	}
+	if len(d.execs) != 0 || d.commits != 0 {
+		t.Error("unexpected statements", d.execs)
+	}
+}
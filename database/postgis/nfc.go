@@ -0,0 +1,145 @@
+package postgis
+
+// Unicode normalization form C (NFC), based on the tables from
+// nfc_tables.go. See http://unicode.org/reports/tr15/
+
+const (
+	hangulSBase  = 0xAC00
+	hangulLBase  = 0x1100
+	hangulVBase  = 0x1161
+	hangulTBase  = 0x11A7
+	hangulLCount = 19
+	hangulVCount = 21
+	hangulTCount = 28
+	hangulNCount = hangulVCount * hangulTCount
+	hangulSCount = hangulLCount * hangulNCount
+)
+
+var (
+	// primary composites for each pair of runes
+	nfcCompositions = make(map[[2]rune]rune)
+	// runes that are changed, or that might change other runes, by NFC
+	nfcUnstable = make(map[rune]bool)
+)
+
+func init() {
+	excluded := make(map[rune]bool)
+	for _, r := range nfcCompositionExclusions {
+		excluded[r] = true
+	}
+	for r, d := range nfcDecompositions {
+		if len(d) == 2 && nfcCombiningClasses[d[0]] == 0 && !excluded[r] {
+			nfcCompositions[[2]rune{d[0], d[1]}] = r
+			nfcUnstable[d[1]] = true
+		} else {
+			nfcUnstable[r] = true
+		}
+	}
+	for r := range nfcCombiningClasses {
+		nfcUnstable[r] = true
+	}
+	for i := rune(0); i < hangulVCount; i++ {
+		nfcUnstable[hangulVBase+i] = true
+	}
+	for i := rune(1); i < hangulTCount; i++ {
+		nfcUnstable[hangulTBase+i] = true
+	}
+}
+
+// nfc returns s in Unicode normalization form C.
+func nfc(s string) string {
+	if isNfc(s) {
+		return s
+	}
+	var runes []rune
+	for _, r := range s {
+		runes = appendDecomposed(runes, r)
+	}
+	reorderCanonical(runes)
+	return string(composeCanonical(runes))
+}
+
+// isNfc returns true for strings that are known to be in NFC.
+func isNfc(s string) bool {
+	for _, r := range s {
+		if r >= 0x80 && nfcUnstable[r] {
+			return false
+		}
+	}
+	return true
+}
+
+func appendDecomposed(runes []rune, r rune) []rune {
+	if s := r - hangulSBase; s >= 0 && s < hangulSCount {
+		runes = append(runes, hangulLBase+s/hangulNCount, hangulVBase+(s%hangulNCount)/hangulTCount)
+		if t := s % hangulTCount; t != 0 {
+			runes = append(runes, hangulTBase+t)
+		}
+		return runes
+	}
+	if d, ok := nfcDecompositions[r]; ok {
+		for _, dr := range d {
+			runes = appendDecomposed(runes, dr)
+		}
+		return runes
+	}
+	return append(runes, r)
+}
+
+// reorderCanonical sorts all sequences of non-starters by their
+// combining class. The sort is stable.
+func reorderCanonical(runes []rune) {
+	for i := 1; i < len(runes); i++ {
+		c := nfcCombiningClasses[runes[i]]
+		if c == 0 {
+			continue
+		}
+		for j := i; j > 0; j-- {
+			prev := nfcCombiningClasses[runes[j-1]]
+			if prev == 0 || prev <= c {
+				break
+			}
+			runes[j-1], runes[j] = runes[j], runes[j-1]
+		}
+	}
+}
+
+// composeCanonical composes all unblocked pairs of a starter and the
+// following runes. Modifies runes in place.
+func composeCanonical(runes []rune) []rune {
+	if len(runes) == 0 {
+		return runes
+	}
+	out := runes[:1]
+	starter := 0
+	if nfcCombiningClasses[runes[0]] != 0 {
+		starter = -1
+	}
+	var lastClass uint8
+	for _, r := range runes[1:] {
+		c := nfcCombiningClasses[r]
+		if starter >= 0 && (len(out)-1 == starter || (lastClass != 0 && lastClass < c)) {
+			if composed, ok := composePair(out[starter], r); ok {
+				out[starter] = composed
+				continue
+			}
+		}
+		if c == 0 {
+			starter = len(out)
+		}
+		lastClass = c
+		out = append(out, r)
+	}
+	return out
+}
+
+func composePair(a, b rune) (rune, bool) {
+	if l, v := a-hangulLBase, b-hangulVBase; l >= 0 && l < hangulLCount && v >= 0 && v < hangulVCount {
+		return hangulSBase + (l*hangulVCount+v)*hangulTCount, true
+	}
+	if s, t := a-hangulSBase, b-hangulTBase; s >= 0 && s < hangulSCount && s%hangulTCount == 0 && t > 0 && t < hangulTCount {
+		return a + t, true
+	}
+	r, ok := nfcCompositions[[2]rune{a, b}]
+	return r, ok
+}
@@ -0,0 +1,95 @@
+package postgis
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/omniscale/imposm3/mapping"
+)
+
+// isControl returns true for zero-width and bidi control characters.
+func isControl(r rune) bool {
+	switch {
+	case r == 0x061C, // arabic letter mark
+		r >= 0x200B && r <= 0x200F, // zero-width space/non-joiner/joiner, LRM, RLM
+		r >= 0x202A && r <= 0x202E, // bidi embeddings and overrides
+		r == 0x2060,                // word joiner
+		r >= 0x2066 && r <= 0x2069, // bidi isolates
+		r == 0xFEFF:                // zero-width no-break space
+		return true
+	}
+	return false
+}
+
+// normalizeString returns s in Unicode NFC, optionally without control
+// characters and with collapsed whitespace.
+func normalizeString(s string, opts *mapping.Normalize) string {
+	s = nfc(s)
+	if opts.StripControls && strings.IndexFunc(s, isControl) >= 0 {
+		s = strings.Map(func(r rune) rune {
+			if isControl(r) {
+				return -1
+			}
+			return r
+		}, s)
+	}
+	if opts.CollapseWhitespace {
+		s = collapseWhitespace(s)
+	}
+	return s
+}
+
+// collapseWhitespace replaces each run of whitespace with a single space.
+func collapseWhitespace(s string) string {
+	buf := make([]rune, 0, len(s))
+	space := false
+	changed := false
+	for _, r := range s {
+		if unicode.IsSpace(r) {
+			if space || r != ' ' {
+				changed = true
+			}
+			if space {
+				continue
+			}
+			space = true
+			buf = append(buf, ' ')
+			continue
+		}
+		space = false
+		buf = append(buf, r)
+	}
+	if !changed {
+		return s
+	}
+	return string(buf)
+}
+
+// normalizeRow normalizes all string values of columns with the
+// normalize option. It returns a copy of the row if any value changed.
+func (spec *TableSpec) normalizeRow(row []interface{}) []interface{} {
+	var normalized []interface{}
+	for i, col := range spec.Columns {
+		if col.normalize == nil || i >= len(row) {
+			continue
+		}
+		s, ok := row[i].(string)
+		if !ok {
+			continue
+		}
+		n := normalizeString(s, col.normalize)
+		if n == s {
+			continue
+		}
+		if normalized == nil {
+			normalized = make([]interface{}, len(row))
+			copy(normalized, row)
+		}
+		normalized[i] = n
+		spec.rows.normalized()
+	}
+	if normalized == nil {
+		return row
+	}
+	return normalized
+}
@@ -0,0 +1,98 @@
+package postgis
+
+import (
+	"testing"
+
+	"github.com/omniscale/imposm3/mapping"
+)
+
+func TestNfc(t *testing.T) {
+	for _, test := range []struct {
+		input    string
+		expected string
+	}{
+		{"Stra\u00dfe", "Stra\u00dfe"},
+		{"Mu\u0308nchen", "M\u00fcnchen"},
+		{"M\u00fcnchen", "M\u00fcnchen"},
+		{"A\u0308pfelweg O\u0308de", "\u00c4pfelweg \u00d6de"},
+		// canonical ordering
+		{"a\u0323\u0302", "\u1ead"},
+		{"a\u0302\u0323", "\u1ead"},
+		// blocked by mark with same combining class
+		{"a\u0301\u0301", "\u00e1\u0301"},
+		// hangul
+		{"\u1100\u1161\u11a8", "\uac01"},
+		{"\uac00\u11a8", "\uac01"},
+		// singleton
+		{"\u2126", "\u03a9"},
+		// composition exclusion
+		{"\u0958", "\u0915\u093c"},
+		// mark without starter
+		{"\u0308a", "\u0308a"},
+		{"", ""},
+	} {
+		if result := nfc(test.input); result != test.expected {
+			t.Errorf("nfc(%+q) = %+q, expected %+q", test.input, result, test.expected)
+		}
+	}
+}
+
+func TestNormalizeString(t *testing.T) {
+	opts := &mapping.Normalize{}
+	if s := normalizeString("Hauptstra\u00dfe\u200e", opts); s != "Hauptstra\u00dfe\u200e" {
+		t.Errorf("unexpected %+q", s)
+	}
+	opts.StripControls = true
+	if s := normalizeString("Hauptstra\u00dfe\u200e", opts); s != "Hauptstra\u00dfe" {
+		t.Errorf("unexpected %+q", s)
+	}
+	if s := normalizeString("\u202bfoo\u202c", opts); s != "foo" {
+		t.Errorf("unexpected %+q", s)
+	}
+	opts.CollapseWhitespace = true
+	if s := normalizeString("  Am \t Markt ", opts); s != " Am Markt " {
+		t.Errorf("unexpected %+q", s)
+	}
+	if s := normalizeString("Am Markt", opts); s != "Am Markt" {
+		t.Errorf("unexpected %+q", s)
+	}
+}
+
+func TestNormalizeRow(t *testing.T) {
+	pg := testPostGIS()
+	pg.Config.NormalizeStrings = &mapping.Normalize{StripControls: true}
+	spec := testTableSpec(t, pg, testTable())
+	pg.Tables = map[string]*TableSpec{"roads": spec}
+
+	if spec.Columns[0].normalize != nil || spec.Columns[3].normalize != nil {
+		t.Error("normalize for non-string columns")
+	}
+
+	row := []interface{}{int64(1), "", "Mu\u0308hlenweg\u200e", ""}
+	normalized := spec.normalizeRow(row)
+	if normalized[2] != "M\u00fchlenweg" {
+		t.Errorf("unexpected %+q", normalized[2])
+	}
+	if row[2] != "Mu\u0308hlenweg\u200e" {
+		t.Error("original row modified")
+	}
+
+	row = []interface{}{int64(2), "", "M\u00fchlenweg", ""}
+	if normalized := spec.normalizeRow(row); &normalized[0] != &row[0] {
+		t.Error("unchanged row copied")
+	}
+
+	if n := pg.RowCounts()["roads"].NormalizedValues; n != 1 {
+		t.Error("unexpected normalized values", n)
+	}
+}
+
+func TestNormalizeFieldOption(t *testing.T) {
+	table := testTable()
+	table.Fields[2].Normalize = &mapping.Normalize{CollapseWhitespace: true}
+	spec := testTableSpec(t, testPostGIS(), table)
+	row := spec.normalizeRow([]interface{}{int64(1), "", "Am  Markt", ""})
+	if row[2] != "Am Markt" {
+		t.Errorf("unexpected %+q", row[2])
+	}
+}
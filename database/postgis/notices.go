@@ -0,0 +1,104 @@
+package postgis
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+
+	pq "github.com/lib/pq"
+	"github.com/omniscale/imposm3/logging"
+)
+
+// PostgreSQL sends notices for some statements, e.g. newer PostGIS
+// versions for deprecated functions like AddGeometryColumn. Open creates
+// the connections of each PostGIS with a connector that logs the notices
+// at debug level (see newConnector), unless they are suppressed by the
+// noticeFilter of Config.SuppressNoticeCodes and SuppressNoticeMessages.
+// Deprecation notices are suppressed by default, as imposm still uses
+// these functions for older PostGIS versions.
+
+// connector opens lib/pq connections with the notice handler of the
+// PostGIS and runs the session statements (see initSession) for each new
+// connection.
+type connector struct {
+	driver.Connector
+	session []string
+}
+
+// newConnector returns the connector for the params with the notice
+// filter and the session statements.
+func newConnector(params string, f noticeFilter, session []string) (*connector, error) {
+	c, err := pq.NewConnector(params)
+	if err != nil {
+		return nil, err
+	}
+	return &connector{pq.ConnectorWithNoticeHandler(c, f.handle), session}, nil
+}
+
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := initSession(conn, c.session); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// deprecatedFeature is the SQLSTATE of deprecation notices.
+const deprecatedFeature = "01P01"
+
+// noticeFilter contains the SQLSTATE codes and the lower case messages
+// of suppressed notices.
+type noticeFilter struct {
+	codes    []string
+	messages []string
+}
+
+// newNoticeFilter returns the filter for the codes, deprecatedFeature if
+// nil, and the messages.
+func newNoticeFilter(codes, messages []string) noticeFilter {
+	f := noticeFilter{codes: codes}
+	if codes == nil {
+		f.codes = []string{deprecatedFeature}
+	}
+	for _, msg := range messages {
+		f.messages = append(f.messages, strings.ToLower(msg))
+	}
+	return f
+}
+
+// handle logs the notice, unless it is suppressed.
+func (f noticeFilter) handle(notice *pq.Error) {
+	if f.suppress(notice) {
+		return
+	}
+	logNotice("%s: %s", notice.Severity, notice.Message)
+}
+
+// logNotice is replaced in tests
+var logNotice = func(format string, args ...interface{}) {
+	log.Printfl(logging.DEBUG, format, args...)
+}
+
+// suppress returns whether the notice has one of the codes or contains
+// one of the messages.
+func (f noticeFilter) suppress(notice *pq.Error) bool {
+	for _, code := range f.codes {
+		if string(notice.Code) == code {
+			return true
+		}
+	}
+	if len(f.messages) == 0 {
+		return false
+	}
+	msg := strings.ToLower(notice.Message)
+	for _, m := range f.messages {
+		if strings.Contains(msg, m) {
+			return true
+		}
+	}
+	return false
+}
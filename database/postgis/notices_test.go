@@ -0,0 +1,79 @@
+package postgis
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	pq "github.com/lib/pq"
+)
+
+func TestNoticeConnector(t *testing.T) {
+	var logged []string
+	orig := logNotice
+	defer func() { logNotice = orig }()
+	logNotice = func(format string, args ...interface{}) {
+		logged = append(logged, fmt.Sprintf(format, args...))
+	}
+
+	// connectors with the same params keep their own filter
+	deprecated, err := newConnector("host=localhost", newNoticeFilter(nil, nil), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	all, err := newConnector("host=localhost", newNoticeFilter([]string{}, nil), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	notice := &pq.Error{Severity: "WARNING", Code: "01P01", Message: "AddGeometryColumn is obsolete"}
+	pq.ConnectorNoticeHandler(deprecated.Connector)(notice)
+	pq.ConnectorNoticeHandler(all.Connector)(notice)
+	if !reflect.DeepEqual(logged, []string{"WARNING: AddGeometryColumn is obsolete"}) {
+		t.Error("unexpected notices", logged)
+	}
+
+	if _, err := newConnector("host='localhost", newNoticeFilter(nil, nil), nil); err == nil {
+		t.Error("expected error for invalid params")
+	}
+}
+
+func TestHandleNotice(t *testing.T) {
+	var logged []string
+	orig := logNotice
+	defer func() { logNotice = orig }()
+	logNotice = func(format string, args ...interface{}) {
+		logged = append(logged, fmt.Sprintf(format, args...))
+	}
+
+	notices := []*pq.Error{
+		{Severity: "NOTICE", Code: "00000", Message: `table "osm_roads" does not exist, skipping`},
+		{Severity: "WARNING", Code: "01P01", Message: "AddGeometryColumn is obsolete"},
+		{Severity: "NOTICE", Code: "00000", Message: "function is deprecated, use ALTER TABLE instead"},
+	}
+	for _, tc := range []struct {
+		codes    []string
+		messages []string
+		expected []string
+	}{
+		// only deprecated features by default
+		{nil, nil, []string{
+			`NOTICE: table "osm_roads" does not exist, skipping`,
+			"NOTICE: function is deprecated, use ALTER TABLE instead",
+		}},
+		{[]string{}, nil, []string{
+			`NOTICE: table "osm_roads" does not exist, skipping`,
+			"WARNING: AddGeometryColumn is obsolete",
+			"NOTICE: function is deprecated, use ALTER TABLE instead",
+		}},
+		{[]string{"00000"}, []string{"OBSOLETE"}, nil},
+	} {
+		logged = nil
+		handle := newNoticeFilter(tc.codes, tc.messages).handle
+		for _, notice := range notices {
+			handle(notice)
+		}
+		if !reflect.DeepEqual(logged, tc.expected) {
+			t.Errorf("unexpected notices for %v %v: %v", tc.codes, tc.messages, logged)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package postgis
+
+import (
+	"fmt"
+
+	"github.com/omniscale/imposm3/mapping"
+)
+
+// OSM tags have no NULL values, missing tags are empty strings. The null
+// policy of a string column decides how empty strings and NULL values are
+// inserted.
+const (
+	nullPolicyEmptyAsNull = "empty_as_null"
+	nullPolicyNullAsEmpty = "null_as_empty"
+	nullPolicyVerbatim    = "verbatim"
+)
+
+// checkNullPolicies returns all problems with the null_policy, not_null
+// and default options of the columns.
+func checkNullPolicies(spec *TableSpec, fields []*mapping.Field) []string {
+	var problems []string
+	for i, col := range spec.Columns {
+		if fields[i].NullPolicy != "" && col.FieldType.GoType != "string" {
+			problems = append(problems, fmt.Sprintf("null_policy of column %s requires string type", col.Name))
+			continue
+		}
+		switch col.nullPolicy {
+		case "", nullPolicyVerbatim, nullPolicyNullAsEmpty:
+		case nullPolicyEmptyAsNull:
+			if col.NotNull && col.Default == "" {
+				problems = append(problems, fmt.Sprintf("column %s is not_null without default, but null_policy is %s",
+					col.Name, nullPolicyEmptyAsNull))
+			}
+		default:
+			problems = append(problems, fmt.Sprintf("unknown null_policy '%s' of column %s", col.nullPolicy, col.Name))
+		}
+	}
+	return problems
+}
+
+// missingRequired returns true if SkipRowsMissingRequired is enabled and
+// the row has a NULL value in a not_null column without default. These
+// rows would fail the insert of the whole batch.
+func (spec *TableSpec) missingRequired(row []interface{}) bool {
+	if !spec.SkipRowsMissingRequired {
+		return false
+	}
+	for i, col := range spec.Columns {
+		if i < len(row) && row[i] == nil && col.NotNull && col.Default == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// coalesceDefaults returns true if any column needs to replace NULL
+// values with the default.
+func (spec *TableSpec) coalesceDefaults() bool {
+	for _, col := range spec.Columns {
+		if col.coalesceDefault() {
+			return true
+		}
+	}
+	return false
+}
+
+// applyNullPolicies converts empty strings and NULL values of all string
+// columns with a null policy. It returns a copy of the row if any value
+// changed.
+func (spec *TableSpec) applyNullPolicies(row []interface{}) []interface{} {
+	var converted []interface{}
+	for i, col := range spec.Columns {
+		if i >= len(row) {
+			break
+		}
+		var value interface{}
+		switch col.nullPolicy {
+		case nullPolicyEmptyAsNull:
+			if s, ok := row[i].(string); !ok || s != "" {
+				continue
+			}
+			value = nil
+		case nullPolicyNullAsEmpty:
+			if row[i] != nil {
+				continue
+			}
+			value = ""
+		default:
+			continue
+		}
+		if converted == nil {
+			converted = make([]interface{}, len(row))
+			copy(converted, row)
+		}
+		converted[i] = value
+	}
+	if converted == nil {
+		return row
+	}
+	return converted
+}
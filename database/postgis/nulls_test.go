@@ -0,0 +1,160 @@
+package postgis
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/omniscale/imposm3/element"
+	"github.com/omniscale/imposm3/geom"
+	"github.com/omniscale/imposm3/mapping"
+)
+
+func TestNullPolicyMissingKey(t *testing.T) {
+	pg := testPostGIS()
+	pg.Config.NullPolicy = nullPolicyEmptyAsNull
+	table := testTable()
+	spec := testTableSpec(t, pg, table)
+
+	// name key is absent
+	elem := element.OSMElem{Id: 1, Tags: element.Tags{"highway": "residential"}}
+	g := geom.Geometry{Wkb: []byte(ewkbLineString(3857, 0, 0, 1, 1).hex())}
+	row := table.TableFields().MakeRow(&elem, &g, mapping.Match{})
+	if row[2] != "" {
+		t.Fatal("unexpected value for missing key", row[2])
+	}
+
+	converted := spec.applyNullPolicies(row)
+	if converted[2] != nil {
+		t.Error("empty string not converted to NULL", converted[2])
+	}
+	if row[2] != "" {
+		t.Error("original row modified")
+	}
+	// hstore tags are not string columns
+	if converted[3] == nil {
+		t.Error("tags converted to NULL")
+	}
+
+	elem.Tags["name"] = "Hauptstra\u00dfe"
+	row = table.TableFields().MakeRow(&elem, &g, mapping.Match{})
+	if converted := spec.applyNullPolicies(row); converted[2] != "Hauptstra\u00dfe" {
+		t.Error("unexpected name", converted[2])
+	}
+}
+
+func TestNullPolicies(t *testing.T) {
+	table := testTable()
+	table.Fields[2].NullPolicy = nullPolicyNullAsEmpty
+	spec := testTableSpec(t, testPostGIS(), table)
+
+	row := spec.applyNullPolicies([]interface{}{int64(1), "", nil, ""})
+	if row[2] != "" {
+		t.Error("NULL not converted to empty string", row[2])
+	}
+
+	table.Fields[2].NullPolicy = nullPolicyVerbatim
+	spec = testTableSpec(t, testPostGIS(), table)
+	row = []interface{}{int64(1), "", nil, ""}
+	if converted := spec.applyNullPolicies(row); &converted[0] != &row[0] || converted[2] != nil {
+		t.Error("verbatim row converted", converted)
+	}
+}
+
+func TestNullPolicyNotNull(t *testing.T) {
+	pg := testPostGIS()
+	pg.Config.NullPolicy = nullPolicyEmptyAsNull
+	table := testTable()
+	table.Fields[2].NotNull = true
+
+	_, err := NewTableSpec(pg, table)
+	if err == nil || !strings.Contains(err.Error(), "not_null without default") {
+		t.Fatal("expected error for not_null without default", err)
+	}
+
+	table.Fields[2].Default = "'unnamed'"
+	spec := testTableSpec(t, pg, table)
+	if sql := spec.CreateTableSQL(); !strings.Contains(sql, `"name" VARCHAR NOT NULL DEFAULT 'unnamed'`) {
+		t.Error("missing constraint", sql)
+	}
+	if sql := spec.InsertSQL(); !strings.Contains(sql, "COALESCE($3, 'unnamed')") {
+		t.Error("missing COALESCE", sql)
+	}
+	if !spec.coalesceDefaults() {
+		t.Error("COPY not disabled")
+	}
+
+	// NOT NULL with verbatim empty strings is valid
+	pg.Config.NullPolicy = ""
+	table.Fields[2].Default = ""
+	testTableSpec(t, pg, table)
+}
+
+func TestNullPolicyInvalid(t *testing.T) {
+	table := testTable()
+	table.Fields[2].NullPolicy = "foo"
+	if _, err := NewTableSpec(testPostGIS(), table); err == nil {
+		t.Error("expected error for unknown null_policy")
+	}
+
+	table = testTable()
+	table.Fields[0].NullPolicy = nullPolicyEmptyAsNull
+	if _, err := NewTableSpec(testPostGIS(), table); err == nil {
+		t.Error("expected error for null_policy of id column")
+	}
+}
+
+func TestSkipRowsMissingRequired(t *testing.T) {
+	pg := testPostGIS()
+	pg.Config.SkipRowsMissingRequired = true
+	table := testTable()
+	table.Fields[2].NotNull = true
+	table.Fields = append(table.Fields, &mapping.Field{Name: "ref", Key: "ref", Type: "string", NotNull: true, Default: "''"})
+	spec := testTableSpec(t, pg, table)
+	line := ewkbLineString(3857, 0, 0, 1, 1).hex()
+
+	// NULL in not_null column without default
+	row, err := spec.prepareRow([]interface{}{int64(1), line, nil, "", "A1"})
+	if err != nil || row != nil {
+		t.Error("row not skipped", row, err)
+	}
+	// NULL in not_null column with default
+	row, err = spec.prepareRow([]interface{}{int64(2), line, "Main", "", nil})
+	if err != nil || row == nil {
+		t.Error("row skipped", row, err)
+	}
+	// empty string is not missing
+	row, err = spec.prepareRow([]interface{}{int64(3), line, "", "", "A1"})
+	if err != nil || row == nil {
+		t.Error("row skipped", row, err)
+	}
+	if c := spec.rows.load(); c.SkippedMissingRequired != 1 || c.Failed != 0 {
+		t.Error("unexpected counts", c)
+	}
+
+	// disabled
+	pg.Config.SkipRowsMissingRequired = false
+	spec = testTableSpec(t, pg, table)
+	row, err = spec.prepareRow([]interface{}{int64(1), line, nil, "", "A1"})
+	if err != nil || row == nil {
+		t.Error("row skipped", row, err)
+	}
+	if c := spec.rows.load(); c.SkippedMissingRequired != 0 {
+		t.Error("unexpected counts", c)
+	}
+}
+
+func TestSkipRowsMissingRequiredNullPolicy(t *testing.T) {
+	pg := testPostGIS()
+	pg.Config.SkipRowsMissingRequired = true
+	table := testTable()
+	table.Fields[2].NotNull = true
+	table.Fields[2].NullPolicy = nullPolicyNullAsEmpty
+	spec := testTableSpec(t, pg, table)
+	line := ewkbLineString(3857, 0, 0, 1, 1).hex()
+
+	// NULL is converted to an empty string before the check
+	row, err := spec.prepareRow([]interface{}{int64(1), line, nil, ""})
+	if err != nil || row == nil || row[2] != "" {
+		t.Error("unexpected row", row, err)
+	}
+}
@@ -0,0 +1,179 @@
+package postgis
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/omniscale/imposm3/mapping"
+)
+
+// Columns of type osm_type store the type of the element of each row, so
+// that rows of nodes, ways and relations with the same id can be
+// distinguished without id offsets. Rows of the mapping get the type of
+// the match. Rows of InsertMultiBatch, ReplaceBatch, etc. need a
+// mapping.OsmType value (mapping.OsmTypeNode, OsmTypeWay or
+// OsmTypeRelation). The types are stored as N, W and R in a CHAR(1)
+// column, or as 0, 1 and 2 in a SMALLINT column with representation:
+// smallint.
+//
+// Deletes of elements only remove the rows of the type of the element.
+// Generalized tables are updated by id: all rows of the id are removed
+// and inserted again from the source table.
+
+const (
+	osmTypeChar     = "char"
+	osmTypeSmallint = "smallint"
+)
+
+var osmTypeChars = map[mapping.OsmType]string{
+	mapping.OsmTypeNode:     "N",
+	mapping.OsmTypeWay:      "W",
+	mapping.OsmTypeRelation: "R",
+}
+
+type osmTypeColumnType struct {
+	simpleColumnType
+	representation string
+}
+
+// newOsmTypeColumnType returns the column type for the representation
+// arg of the field.
+func newOsmTypeColumnType(field *mapping.Field) (*osmTypeColumnType, error) {
+	representation := osmTypeChar
+	if v, ok := field.Args["representation"]; ok {
+		representation, _ = v.(string)
+	}
+	switch representation {
+	case osmTypeChar:
+		return &osmTypeColumnType{simpleColumnType{"CHAR(1)"}, representation}, nil
+	case osmTypeSmallint:
+		return &osmTypeColumnType{simpleColumnType{"SMALLINT"}, representation}, nil
+	}
+	return nil, fmt.Errorf("unknown representation '%v' of osm_type column %s", field.Args["representation"], field.Name)
+}
+
+// encode returns the value of the type in the representation of the
+// column.
+func (t *osmTypeColumnType) encode(osmType mapping.OsmType) interface{} {
+	if t.representation == osmTypeSmallint {
+		return int64(osmType - mapping.OsmTypeNode)
+	}
+	return osmTypeChars[osmType]
+}
+
+// value returns the value of val in the representation of the column.
+// val is a mapping.OsmType or already in the representation of the
+// column.
+func (t *osmTypeColumnType) value(val interface{}) (interface{}, error) {
+	switch v := val.(type) {
+	case mapping.OsmType:
+		if _, ok := osmTypeChars[v]; ok {
+			return t.encode(v), nil
+		}
+	case string:
+		if t.representation == osmTypeChar {
+			for _, c := range osmTypeChars {
+				if v == c {
+					return v, nil
+				}
+			}
+		}
+	case int64:
+		if t.representation == osmTypeSmallint && v >= 0 && v <= 2 {
+			return v, nil
+		}
+	case int:
+		if t.representation == osmTypeSmallint && v >= 0 && v <= 2 {
+			return int64(v), nil
+		}
+	}
+	return nil, fmt.Errorf("invalid osm_type %#v", val)
+}
+
+// osmTypeColumn returns the index of the first osm_type column, or -1.
+func (spec *TableSpec) osmTypeColumn() int {
+	for i, col := range spec.Columns {
+		if _, ok := col.Type.(*osmTypeColumnType); ok {
+			return i
+		}
+	}
+	return -1
+}
+
+// osmTypeValue returns the value of the type for the osm_type column.
+func (spec *TableSpec) osmTypeValue(osmType mapping.OsmType) interface{} {
+	idx := spec.osmTypeColumn()
+	if idx < 0 {
+		return nil
+	}
+	return spec.Columns[idx].Type.(*osmTypeColumnType).encode(osmType)
+}
+
+// DeleteTypeSQL returns the DeleteSQL for rows of a single element type,
+// or an empty string for tables without osm_type column.
+func (spec *TableSpec) DeleteTypeSQL() string {
+	idx := spec.osmTypeColumn()
+	if idx < 0 {
+		return ""
+	}
+	return fmt.Sprintf(`%s AND "%s" = $2`, spec.DeleteSQL(), spec.Columns[idx].Name)
+}
+
+// encodeOsmTypes returns the row with the values of all osm_type columns
+// in the representation of the column.
+func (spec *TableSpec) encodeOsmTypes(row []interface{}) ([]interface{}, error) {
+	encoded := row
+	copied := false
+	for i, col := range spec.Columns {
+		if i >= len(row) || row[i] == nil {
+			continue
+		}
+		t, ok := col.Type.(*osmTypeColumnType)
+		if !ok {
+			continue
+		}
+		v, err := t.value(row[i])
+		if err != nil {
+			return nil, fmt.Errorf("column %s of %s: %s", col.Name, spec.Name, err)
+		}
+		if v == row[i] {
+			continue
+		}
+		if !copied {
+			encoded = make([]interface{}, len(row))
+			copy(encoded, row)
+			copied = true
+		}
+		encoded[i] = v
+	}
+	return encoded, nil
+}
+
+// suggestOsmTypeColumns logs all tables that need an osm_type column
+// (see tablesWithoutOsmType).
+func (pg *PostGIS) suggestOsmTypeColumns(m *mapping.Mapping) {
+	tables := pg.tablesWithoutOsmType(m)
+	if len(tables) == 0 {
+		return
+	}
+	log.Printf("tables %s match more than one element type, "+
+		"add a column of type osm_type to distinguish rows of elements with the same id",
+		strings.Join(tables, ", "))
+}
+
+// tablesWithoutOsmType returns the sorted names of all tables that are
+// filled from more than one element type and that have no osm_type
+// column.
+func (pg *PostGIS) tablesWithoutOsmType(m *mapping.Mapping) []string {
+	var tables []string
+	for name, spec := range pg.Tables {
+		table, ok := m.Tables[name]
+		if !ok || len(table.OsmTypes()) < 2 || spec.osmTypeColumn() >= 0 {
+			continue
+		}
+		tables = append(tables, name)
+	}
+	sort.Strings(tables)
+	return tables
+}
@@ -0,0 +1,196 @@
+package postgis
+
+import (
+	"database/sql/driver"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/omniscale/imposm3/mapping"
+)
+
+func osmTypeTable(representation string) *mapping.Table {
+	table := testTable()
+	field := &mapping.Field{Name: "osm_type", Type: "osm_type"}
+	if representation != "" {
+		field.Args = map[string]interface{}{"representation": representation}
+	}
+	table.Fields = append(table.Fields, field)
+	return table
+}
+
+func TestOsmTypeSQL(t *testing.T) {
+	spec := testTableSpec(t, testPostGIS(), osmTypeTable(""))
+	if sql := spec.CreateTableSQL(); !strings.Contains(sql, `"osm_type" CHAR(1)`) {
+		t.Error("unexpected sql", sql)
+	}
+	expected := `DELETE FROM "import"."osm_roads" WHERE "osm_id" = $1 AND "osm_type" = $2`
+	if sql := spec.DeleteTypeSQL(); sql != expected {
+		t.Errorf("unexpected SQL\n%s\n%s", sql, expected)
+	}
+
+	spec = testTableSpec(t, testPostGIS(), osmTypeTable("smallint"))
+	if sql := spec.CreateTableSQL(); !strings.Contains(sql, `"osm_type" SMALLINT`) {
+		t.Error("unexpected sql", sql)
+	}
+
+	spec = testTableSpec(t, testPostGIS(), testTable())
+	if sql := spec.DeleteTypeSQL(); sql != "" {
+		t.Error("unexpected sql", sql)
+	}
+
+	_, err := NewTableSpec(testPostGIS(), osmTypeTable("enum"))
+	if err == nil || !strings.Contains(err.Error(), "unknown representation 'enum' of osm_type column osm_type") {
+		t.Error("unexpected error", err)
+	}
+}
+
+func TestEncodeOsmTypes(t *testing.T) {
+	char := testTableSpec(t, testPostGIS(), osmTypeTable(""))
+	smallint := testTableSpec(t, testPostGIS(), osmTypeTable("smallint"))
+	for _, test := range []struct {
+		spec     *TableSpec
+		value    interface{}
+		expected interface{}
+	}{
+		{char, mapping.OsmTypeNode, "N"},
+		{char, mapping.OsmTypeWay, "W"},
+		{char, mapping.OsmTypeRelation, "R"},
+		{char, "R", "R"},
+		{char, nil, nil},
+		{smallint, mapping.OsmTypeNode, int64(0)},
+		{smallint, mapping.OsmTypeWay, int64(1)},
+		{smallint, mapping.OsmTypeRelation, int64(2)},
+		{smallint, 2, int64(2)},
+	} {
+		row, err := test.spec.encodeOsmTypes([]interface{}{int64(1), "", "", "", test.value})
+		if err != nil {
+			t.Errorf("unexpected error for %#v: %s", test.value, err)
+			continue
+		}
+		if row[4] != test.expected {
+			t.Errorf("unexpected value for %#v: %#v", test.value, row[4])
+		}
+	}
+	for _, test := range []struct {
+		spec  *TableSpec
+		value interface{}
+	}{
+		{char, mapping.OsmTypeUnknown},
+		{char, "X"},
+		{char, 1},
+		{smallint, "N"},
+		{smallint, int64(3)},
+	} {
+		if _, err := test.spec.encodeOsmTypes([]interface{}{int64(1), "", "", "", test.value}); err == nil {
+			t.Errorf("expected error for %#v", test.value)
+		}
+	}
+}
+
+func TestDeleteOsmType(t *testing.T) {
+	db, d := newFakeDb()
+	defer db.Close()
+	pg := testPostGIS()
+	pg.Db = db
+	pg.Tables = map[string]*TableSpec{
+		"roads":  testTableSpec(t, pg, osmTypeTable("")),
+		"places": testTableSpec(t, pg, &mapping.Table{Name: "places", Type: mapping.PointTable, Fields: testTable().Fields}),
+	}
+	if err := pg.Begin(); err != nil {
+		t.Fatal(err)
+	}
+	// only the way rows of the id
+	pg.Delete(5, []mapping.Match{{Table: mapping.DestTable{Name: "roads"}, OsmType: mapping.OsmTypeWay}})
+	// all rows of tables without osm_type and of unknown types
+	pg.Delete(6, []mapping.Match{{Table: mapping.DestTable{Name: "places"}, OsmType: mapping.OsmTypeNode}})
+	pg.Delete(7, []mapping.Match{{Table: mapping.DestTable{Name: "roads"}}})
+	if err := pg.End(); err != nil {
+		t.Fatal(err)
+	}
+
+	var deletes []string
+	for _, sql := range d.execs {
+		if strings.HasPrefix(sql, "DELETE") {
+			deletes = append(deletes, sql)
+		}
+	}
+	expected := []string{
+		`DELETE FROM "import"."osm_roads" WHERE "osm_id" = $1 AND "osm_type" = $2`,
+		`DELETE FROM "import"."osm_places" WHERE "osm_id" = $1`,
+		`DELETE FROM "import"."osm_roads" WHERE "osm_id" = $1`,
+	}
+	if !reflect.DeepEqual(deletes, expected) {
+		t.Errorf("unexpected deletes\n%v\n%v", deletes, expected)
+	}
+	if args := d.values(expected[0], 1); !reflect.DeepEqual(args, []driver.Value{"W"}) {
+		t.Error("unexpected osm_type", args)
+	}
+}
+
+func TestReplaceBatchOsmType(t *testing.T) {
+	db, d := newFakeDb()
+	defer db.Close()
+	pg := testPostGIS()
+	pg.Db = db
+	pg.Tables = map[string]*TableSpec{"roads": testTableSpec(t, pg, osmTypeTable("smallint"))}
+
+	line := ewkbLineString(3857, 0, 0, 1, 1).hex()
+	elems := []ElementRows{
+		{Id: 1, OsmType: mapping.OsmTypeRelation, Rows: [][]interface{}{{int64(1), line, "a", "", mapping.OsmTypeRelation}}},
+	}
+	if err := pg.ReplaceBatch("roads", elems); err != nil {
+		t.Fatal(err)
+	}
+	if args := d.values(`DELETE FROM "import"."osm_roads" WHERE "osm_id" = $1 AND "osm_type" = $2`, 1); !reflect.DeepEqual(args, []driver.Value{int64(2)}) {
+		t.Error("unexpected deleted osm_type", args)
+	}
+	if args := d.values("INSERT", 4); !reflect.DeepEqual(args, []driver.Value{int64(2)}) {
+		t.Error("unexpected inserted osm_type", args)
+	}
+}
+
+func TestTablesWithoutOsmType(t *testing.T) {
+	pg := testPostGIS()
+	m := &mapping.Mapping{Tables: mapping.Tables{
+		"roads":  osmTypeTable(""),
+		"areas":  &mapping.Table{Name: "areas", Type: mapping.PolygonTable, Fields: testTable().Fields},
+		"all":    &mapping.Table{Name: "all", Type: mapping.GeometryTable, Fields: testTable().Fields},
+		"places": &mapping.Table{Name: "places", Type: mapping.PointTable, Fields: testTable().Fields},
+	}}
+	pg.Tables = make(map[string]*TableSpec)
+	for name, table := range m.Tables {
+		pg.Tables[name] = testTableSpec(t, pg, table)
+	}
+	if tables := pg.tablesWithoutOsmType(m); !reflect.DeepEqual(tables, []string{"all", "areas"}) {
+		t.Error("unexpected tables", tables)
+	}
+}
+
+func TestDeleteGeneralizedOsmType(t *testing.T) {
+	pg := testPostGIS()
+	roads := testTableSpec(t, pg, osmTypeTable(""))
+	pg.Tables = map[string]*TableSpec{"roads": roads}
+	gen := &GeneralizedTableSpec{Name: "roads_gen0", Source: roads}
+	roads.Generalizations = []*GeneralizedTableSpec{gen}
+	pg.GeneralizedTables = map[string]*GeneralizedTableSpec{gen.Name: gen}
+
+	pg.txRouter = &TxRouter{Tables: map[string]TableTx{
+		"roads":  &countingTableTx{},
+		gen.Name: &countingTableTx{},
+	}}
+	pg.EnableGeneralizeUpdates()
+
+	way := []mapping.Match{{Table: mapping.DestTable{Name: "roads"}, OsmType: mapping.OsmTypeWay}}
+	// deleted way, the rows of other types are inserted again
+	pg.Delete(5, way)
+	// modified way, inserted once
+	pg.Delete(6, way)
+	pg.addUpdatedIds(6, way)
+	if err := pg.GeneralizeUpdates(); err != nil {
+		t.Fatal(err)
+	}
+	if n := pg.txRouter.Tables[gen.Name].(*countingTableTx).inserted; n != 2 {
+		t.Error("unexpected number of generalized updates", n)
+	}
+}
@@ -0,0 +1,94 @@
+package postgis
+
+import (
+	"database/sql"
+	"strings"
+)
+
+// With Config.PgBouncer, imposm works with pgbouncer in transaction
+// pooling mode, where each transaction (and each statement outside of a
+// transaction) can run on a different server connection:
+//
+// - Statements with parameters are executed in a transaction. lib/pq
+//   parses them as unnamed statement and executes them in a second round
+//   trip, which pgbouncer might send to another server.
+// - Prepared statements are closed before the commit of their
+//   transaction, instead of after the commit, when the server connection
+//   is already used by another client.
+//
+// Imposm does not use session settings (SET; DefaultTablespace and
+// TempTablespaces are not supported in this mode) or session-level advisory
+// locks (the import lock is bound to its transaction), so these work
+// the same in both modes. COPY is not affected. The transaction with the
+// import lock keeps a server connection for the whole import. Warmup only
+// opens connections to pgbouncer, not to the servers. pgbouncer needs
+// ignore_startup_parameters = extra_float_digits for lib/pq.
+
+// queryer is implemented by *sql.DB and *sql.Tx.
+type queryer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// withArgs calls f for statements with parameters. f is called with
+// pg.Db, or with a new transaction that is committed after f in PgBouncer
+// mode.
+func (pg *PostGIS) withArgs(f func(db queryer) error) error {
+	if !pg.Config.PgBouncer {
+		return f(pg.Db)
+	}
+	tx, err := pg.Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer rollbackIfTx(&tx)
+	if err := f(tx); err != nil {
+		return err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+	tx = nil // set nil to prevent rollback
+	return nil
+}
+
+// closeBeforeCommit closes the prepared statements of a transaction
+// before the commit in PgBouncer mode. database/sql closes them after the
+// commit otherwise.
+func (pg *PostGIS) closeBeforeCommit(stmts ...*sql.Stmt) {
+	if !pg.Config.PgBouncer {
+		return
+	}
+	for _, stmt := range stmts {
+		if stmt != nil {
+			// COPY statements are already closed after the flush
+			stmt.Close()
+		}
+	}
+}
+
+// stripPgBouncerFromConnectionParams removes the pgbouncer parameter from
+// the connection params and returns whether it is enabled.
+func stripPgBouncerFromConnectionParams(params string) (string, bool) {
+	parts := strings.Fields(params)
+	for i, p := range parts {
+		if strings.HasPrefix(p, "pgbouncer=") {
+			value := strings.Replace(p, "pgbouncer=", "", 1)
+			parts = append(parts[:i], parts[i+1:]...)
+			return strings.Join(parts, " "), value == "true" || value == "1"
+		}
+	}
+	return params, false
+}
+
+func (pg *PostGIS) logPgBouncerMode() {
+	if !pg.Config.PgBouncer {
+		return
+	}
+	log.Print("pgbouncer mode: statements with parameters run in transactions, " +
+		"prepared statements are closed before each commit")
+	log.Print("pgbouncer mode: the import lock keeps one server connection during the import, " +
+		"Warmup only opens connections to pgbouncer")
+}
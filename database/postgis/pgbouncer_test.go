@@ -0,0 +1,82 @@
+package postgis
+
+import (
+	"testing"
+)
+
+func TestStripPgBouncerFromConnectionParams(t *testing.T) {
+	for _, test := range []struct {
+		params    string
+		expected  string
+		pgBouncer bool
+	}{
+		{"host=localhost dbname=osm", "host=localhost dbname=osm", false},
+		{"host=localhost pgbouncer=true dbname=osm", "host=localhost dbname=osm", true},
+		{"host=localhost pgbouncer=false", "host=localhost", false},
+	} {
+		params, pgBouncer := stripPgBouncerFromConnectionParams(test.params)
+		if params != test.expected || pgBouncer != test.pgBouncer {
+			t.Errorf("unexpected result for %s: %s %v", test.params, params, pgBouncer)
+		}
+	}
+}
+
+func TestPgBouncerWithArgs(t *testing.T) {
+	db, d := newFakeDb()
+	defer db.Close()
+	pg := testPostGIS()
+	pg.Db = db
+	spec := testTableSpec(t, pg, testTable())
+	spec.GenerationColumn = "generation"
+	pg.Tables = map[string]*TableSpec{"roads": spec}
+
+	if err := pg.SetGeneration("roads", 2); err != nil {
+		t.Fatal(err)
+	}
+	if d.commits != 0 {
+		t.Error("unexpected transaction", d.commits)
+	}
+
+	pg.Config.PgBouncer = true
+	if err := pg.SetGeneration("roads", 3); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pg.SweepOldGenerations("roads", 3); err != nil {
+		t.Fatal(err)
+	}
+	if d.commits != 2 {
+		t.Error("expected transactions", d.commits)
+	}
+	if n := d.count(spec.SetGenerationSQL()); n != 2 {
+		t.Error("unexpected execs", n)
+	}
+}
+
+func TestPgBouncerCloseBeforeCommit(t *testing.T) {
+	for _, pgBouncer := range []bool{false, true} {
+		db, d := newFakeDb()
+		pg := testFlushPostGIS(t, db)
+		pg.Config.PgBouncer = pgBouncer
+		if err := pg.Begin(); err != nil {
+			t.Fatal(err)
+		}
+		line := ewkbLineString(3857, 0, 0, 1, 1).hex()
+		pg.txRouter.Insert("roads", []interface{}{int64(1), line, "", ""})
+		for _, tt := range pg.txRouter.Tables {
+			tt.End()
+		}
+		d.mu.Lock()
+		closed := len(d.closed)
+		d.mu.Unlock()
+		if pgBouncer && closed != 4 { // insert and delete of both tables
+			t.Error("expected closed statements before commit", closed)
+		}
+		if !pgBouncer && closed != 0 {
+			t.Error("unexpected closed statements", closed)
+		}
+		if err := pg.txRouter.End(); err != nil {
+			t.Fatal(err)
+		}
+		db.Close()
+	}
+}
@@ -2,10 +2,13 @@ package postgis
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"runtime"
+	"sort"
 	"strings"
-	"sync/atomic"
+	"sync"
+	"time"
 
 	pq "github.com/lib/pq"
 	"github.com/omniscale/imposm3/database"
@@ -50,14 +53,94 @@ func createTable(tx *sql.Tx, spec TableSpec) error {
 		return &SQLError{sql, err}
 	}
 
-	err = addGeometryColumn(tx, spec.FullName, spec)
-	if err != nil {
-		return err
+	if spec.hasGeometry() && !spec.inheritsGeometry() {
+		err = addGeometryColumn(tx, spec)
+		if err != nil {
+			return err
+		}
+	}
+	if spec.TileIndex != nil {
+		sql = spec.TileIndexSQL()
+		if _, err := tx.Exec(sql); err != nil {
+			return &SQLError{sql, err}
+		}
+	}
+	for _, sql := range spec.GeneratedColumnsSQL() {
+		if _, err := tx.Exec(sql); err != nil {
+			return &SQLError{sql, err}
+		}
+	}
+	if spec.GeometryCheck != "" {
+		for _, sql := range spec.GeometryCheckSQL() {
+			if _, err := tx.Exec(sql); err != nil {
+				return &SQLError{sql, err}
+			}
+		}
 	}
 	return nil
 }
 
-func addGeometryColumn(tx *sql.Tx, tableName string, spec TableSpec) error {
+// ddlRetryBackoff is the initial wait time before retrying a DDL statement.
+var ddlRetryBackoff = 500 * time.Millisecond
+
+// sleep is replaced in tests
+var sleep = time.Sleep
+
+// createTableWithRetry calls createTable within a savepoint and retries
+// up to retries times if it fails with a lock timeout.
+func createTableWithRetry(tx *sql.Tx, spec TableSpec, retries int) error {
+	if retries <= 0 {
+		return createTable(tx, spec)
+	}
+	return retryOnLockTimeout(retries, ddlRetryBackoff, func() error {
+		if _, err := tx.Exec("SAVEPOINT create_table"); err != nil {
+			return &SQLError{"SAVEPOINT create_table", err}
+		}
+		if err := createTable(tx, spec); err != nil {
+			if _, rbErr := tx.Exec("ROLLBACK TO SAVEPOINT create_table"); rbErr != nil {
+				return &SQLError{"ROLLBACK TO SAVEPOINT create_table", rbErr}
+			}
+			return err
+		}
+		if _, err := tx.Exec("RELEASE SAVEPOINT create_table"); err != nil {
+			return &SQLError{"RELEASE SAVEPOINT create_table", err}
+		}
+		return nil
+	})
+}
+
+// retryOnLockTimeout calls f until it succeeds, until it fails with
+// any other error than a lock timeout, or until all retries are used.
+// The wait time between each try starts with backoff and doubles
+// with each retry.
+func retryOnLockTimeout(retries int, backoff time.Duration, f func() error) error {
+	for i := 0; ; i++ {
+		err := f()
+		if err == nil || i >= retries || !isLockNotAvailable(err) {
+			return err
+		}
+		log.Warnf("lock timeout, retrying in %s (%d/%d): %s", backoff, i+1, retries, err)
+		sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// isLockNotAvailable returns whether err is a lock_not_available error
+// (e.g. caused by lock_timeout).
+func isLockNotAvailable(err error) bool {
+	switch e := err.(type) {
+	case *SQLError:
+		err = e.originalError
+	case *SQLInsertError:
+		err = e.originalError
+	}
+	if pqErr, ok := err.(*pq.Error); ok {
+		return pqErr.Code == "55P03"
+	}
+	return false
+}
+
+func addGeometryColumn(tx *sql.Tx, spec TableSpec) error {
 	colName := "geometry"
 	for _, col := range spec.Columns {
 		if col.Type.Name() == "GEOMETRY" {
@@ -66,15 +149,15 @@ func addGeometryColumn(tx *sql.Tx, tableName string, spec TableSpec) error {
 		}
 	}
 
-	geomType := strings.ToUpper(spec.GeometryType)
-	if geomType == "POLYGON" {
-		geomType = "GEOMETRY" // for multipolygon support
+	add, err := checkExistingGeometryColumn(tx, &spec, colName)
+	if err != nil || !add {
+		return err
 	}
-	sql := fmt.Sprintf("SELECT AddGeometryColumn('%s', '%s', '%s', '%d', '%s', 2);",
-		spec.Schema, tableName, colName, spec.Srid, geomType)
+
+	sql := spec.dialect().GeometryColumnDDL(&spec, colName)
 	row := tx.QueryRow(sql)
 	var void interface{}
-	err := row.Scan(&void)
+	err = row.Scan(&void)
 	if err != nil {
 		return &SQLError{sql, err}
 	}
@@ -134,19 +217,59 @@ func (pg *PostGIS) createSchema(schema string) error {
 
 // Init creates schema and tables, drops existing data.
 func (pg *PostGIS) Init() error {
+	if err := pg.checkGeneratedColumnsVersion(); err != nil {
+		return err
+	}
+	if err := pg.registerCustomSrids(); err != nil {
+		return err
+	}
 	if err := pg.createSchema(pg.Config.ImportSchema); err != nil {
 		return err
 	}
+	if err := pg.createEnums(); err != nil {
+		return err
+	}
+	for _, spec := range pg.Tables {
+		if err := pg.createSchema(spec.Schema); err != nil {
+			return err
+		}
+	}
+
+	var tables []string
+	for _, table := range pg.deployTables() {
+		tables = append(tables, table.schemas.Import+"."+table.name)
+	}
+	if err := pg.beginImport(tables); err != nil {
+		return err
+	}
 
 	tx, err := pg.Db.Begin()
 	if err != nil {
 		return err
 	}
 	defer rollbackIfTx(&tx)
-	for _, spec := range pg.Tables {
-		if err := createTable(tx, *spec); err != nil {
+	specs := pg.tablesParentsFirst()
+	// parents can only be dropped after their child tables and tables
+	// with foreign keys to them
+	for i := len(specs) - 1; i >= 0; i-- {
+		if spec := specs[i]; spec.Inherits != nil || spec.ForeignKey != nil {
+			if err := dropTableIfExists(tx, spec.Schema, spec.FullName); err != nil {
+				return err
+			}
+		}
+	}
+	for _, spec := range specs {
+		if err := createTableWithRetry(tx, *spec, pg.Config.DDLRetries); err != nil {
 			return err
 		}
+		if spec.InvalidTable {
+			if err := createInvalidTable(tx, *spec); err != nil {
+				return err
+			}
+		}
+	}
+	if err := pg.recordImportMeta(tx, specs); err != nil {
+		return err
 	}
 	err = tx.Commit()
 	if err != nil {
@@ -170,7 +293,10 @@ func (pg *PostGIS) Finish() error {
 		tableName := tbl.FullName
 		table := tbl
 		p.in <- func() error {
-			return createIndex(pg, tableName, table.Columns)
+			if err := createIndex(pg, table.dialect(), table.indexNames(), table.Schema, tableName, table.Columns, table.IdIndex); err != nil {
+				return err
+			}
+			return createMappingIndexes(pg, table)
 		}
 	}
 
@@ -178,7 +304,7 @@ func (pg *PostGIS) Finish() error {
 		tableName := tbl.FullName
 		table := tbl
 		p.in <- func() error {
-			return createIndex(pg, tableName, table.Source.Columns)
+			return createIndex(pg, table.Source.dialect(), table.Source.indexNames(), table.Schema, tableName, table.Source.Columns, table.Source.IdIndex)
 		}
 	}
 
@@ -187,14 +313,53 @@ func (pg *PostGIS) Finish() error {
 		return err
 	}
 
-	return nil
+	if err := pg.validateConstraints(); err != nil {
+		return err
+	}
+
+	if err := pg.addForeignKeys(); err != nil {
+		return err
+	}
+
+	if err := pg.resetSequences(); err != nil {
+		return err
+	}
+
+	if pg.Config.DropEmptyInvalidTables {
+		if err := pg.dropEmptyInvalidTables(); err != nil {
+			return err
+		}
+	}
+	return pg.finishImport()
+}
+
+func geometryIndexSQL(d Dialect, names indexNameTemplate, schema, tableName, column string) string {
+	return d.IndexSQL(names.geometryIndexName(tableName, column), schema, tableName, "GIST", d.QuoteIdent(column))
+}
+
+const (
+	idIndexBtree = "btree"
+	// idIndexBrin is much smaller than a B-tree, but only efficient for
+	// rows that are inserted in the order of the OSM id
+	idIndexBrin = "brin"
+)
+
+func idIndexSQL(d Dialect, names indexNameTemplate, schema, tableName, column, method string) string {
+	if method == "" {
+		method = idIndexBtree
+	}
+	return d.IndexSQL(names.idIndexName(tableName, column), schema, tableName, strings.ToUpper(method), d.QuoteIdent(column))
+}
+
+func geohashIndexSQL(d Dialect, names indexNameTemplate, schema, tableName, column string, srid int) string {
+	return d.IndexSQL(names.geohashIndexName(tableName, column), schema, tableName, "",
+		fmt.Sprintf("ST_GeoHash(ST_Transform(ST_SetSRID(Box2D(%s), %d), 4326))", column, srid))
 }
 
-func createIndex(pg *PostGIS, tableName string, columns []ColumnSpec) error {
+func createIndex(pg *PostGIS, d Dialect, names indexNameTemplate, schema, tableName string, columns []ColumnSpec, idIndex string) error {
 	for _, col := range columns {
 		if col.Type.Name() == "GEOMETRY" {
-			sql := fmt.Sprintf(`CREATE INDEX "%s_geom" ON "%s"."%s" USING GIST ("%s")`,
-				tableName, pg.Config.ImportSchema, tableName, col.Name)
+			sql := geometryIndexSQL(d, names, schema, tableName, col.Name)
 			step := log.StartStep(fmt.Sprintf("Creating geometry index on %s", tableName))
 			_, err := pg.Db.Exec(sql)
 			log.StopStep(step)
@@ -203,8 +368,7 @@ func createIndex(pg *PostGIS, tableName string, columns []ColumnSpec) error {
 			}
 		}
 		if col.FieldType.Name == "id" {
-			sql := fmt.Sprintf(`CREATE INDEX "%s_osm_id_idx" ON "%s"."%s" USING BTREE ("%s")`,
-				tableName, pg.Config.ImportSchema, tableName, col.Name)
+			sql := idIndexSQL(d, names, schema, tableName, col.Name, idIndex)
 			step := log.StartStep(fmt.Sprintf("Creating OSM id index on %s", tableName))
 			_, err := pg.Db.Exec(sql)
 			log.StopStep(step)
@@ -218,9 +382,19 @@ func createIndex(pg *PostGIS, tableName string, columns []ColumnSpec) error {
 
 func (pg *PostGIS) GeneralizeUpdates() error {
 	defer log.StopStep(log.StartStep(fmt.Sprintf("Updating generalized tables")))
+	pg.updatedIdsMu.Lock()
+	defer pg.updatedIdsMu.Unlock()
 	for _, table := range pg.sortedGeneralizedTables() {
+		inserted := make(map[int64]bool)
 		if ids, ok := pg.updatedIds[table]; ok {
 			for _, id := range ids {
+				inserted[id] = true
+				pg.txRouter.Insert(table, []interface{}{id})
+			}
+		}
+		for _, id := range pg.reinsertedIds[table] {
+			if !inserted[id] {
+				inserted[id] = true
 				pg.txRouter.Insert(table, []interface{}{id})
 			}
 		}
@@ -228,59 +402,11 @@ func (pg *PostGIS) GeneralizeUpdates() error {
 	return nil
 }
 
+// Generalize creates all generalized tables in the order of their sources.
 func (pg *PostGIS) Generalize() error {
 	defer log.StopStep(log.StartStep(fmt.Sprintf("Creating generalized tables")))
 
-	worker := int(runtime.GOMAXPROCS(0))
-	if worker < 1 {
-		worker = 1
-	}
-	// generalized tables can depend on other generalized tables
-	// create tables with non-generalized sources first
-	p := newWorkerPool(worker, len(pg.GeneralizedTables))
-	for _, table := range pg.GeneralizedTables {
-		if table.SourceGeneralized == nil {
-			tbl := table // for following closure
-			p.in <- func() error {
-				if err := pg.generalizeTable(tbl); err != nil {
-					return err
-				}
-				tbl.created = true
-				return nil
-			}
-		}
-	}
-	err := p.wait()
-	if err != nil {
-		return err
-	}
-
-	// next create tables with created generalized sources until
-	// no new source is created
-	created := int32(1)
-	for created == 1 {
-		created = 0
-
-		p := newWorkerPool(worker, len(pg.GeneralizedTables))
-		for _, table := range pg.GeneralizedTables {
-			if !table.created && table.SourceGeneralized.created {
-				tbl := table // for following closure
-				p.in <- func() error {
-					if err := pg.generalizeTable(tbl); err != nil {
-						return err
-					}
-					tbl.created = true
-					atomic.StoreInt32(&created, 1)
-					return nil
-				}
-			}
-		}
-		err := p.wait()
-		if err != nil {
-			return err
-		}
-	}
-	return nil
+	return generalizeLevels(pg.generalizedTableLevels(), pg.generalizeWorkers(), pg.generalizeTable)
 }
 
 func (pg *PostGIS) generalizeTable(table *GeneralizedTableSpec) error {
@@ -293,9 +419,19 @@ func (pg *PostGIS) generalizeTable(table *GeneralizedTableSpec) error {
 	}
 	defer rollbackIfTx(&tx)
 
-	var where string
+	var conditions []string
 	if table.Where != "" {
-		where = " WHERE " + table.Where
+		conditions = append(conditions, "("+table.Where+")")
+	}
+	if table.Source.SoftDelete {
+		conditions = append(conditions, notDeletedSQL)
+	}
+	if table.Source.AllowNullGeometry {
+		conditions = append(conditions, table.Source.notNullGeometrySQL())
+	}
+	var where string
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
 	}
 	var cols []string
 
@@ -303,7 +439,7 @@ func (pg *PostGIS) generalizeTable(table *GeneralizedTableSpec) error {
 		cols = append(cols, col.Type.GeneralizeSql(&col, table))
 	}
 
-	if err := dropTableIfExists(tx, pg.Config.ImportSchema, table.FullName); err != nil {
+	if err := dropTableIfExists(tx, table.Schema, table.FullName); err != nil {
 		return err
 	}
 
@@ -316,7 +452,7 @@ func (pg *PostGIS) generalizeTable(table *GeneralizedTableSpec) error {
 		sourceTable = table.Source.FullName
 	}
 	sql := fmt.Sprintf(`CREATE TABLE "%s"."%s" AS (SELECT %s FROM "%s"."%s"%s)`,
-		pg.Config.ImportSchema, table.FullName, columnSQL, pg.Config.ImportSchema,
+		table.Schema, table.FullName, columnSQL, table.Schema,
 		sourceTable, where)
 
 	_, err = tx.Exec(sql)
@@ -343,7 +479,9 @@ func (pg *PostGIS) generalizeTable(table *GeneralizedTableSpec) error {
 	return nil
 }
 
-// Optimize clusters tables on new GeoHash index.
+// Optimize clusters tables on new GeoHash index. Tables with reindex
+// (and their generalized tables) are reindexed instead (see reindexTable).
+// Logs the SpatialStats of all tables afterwards, if enabled.
 func (pg *PostGIS) Optimize() error {
 	defer log.StopStep(log.StartStep(fmt.Sprintf("Clustering on geometry")))
 
@@ -352,37 +490,58 @@ func (pg *PostGIS) Optimize() error {
 		worker = 1
 	}
 
+	mode, err := pg.reindexMode()
+	if err != nil {
+		return err
+	}
+
 	p := newWorkerPool(worker, len(pg.Tables)+len(pg.GeneralizedTables))
 
 	for _, tbl := range pg.Tables {
 		tableName := tbl.FullName
 		table := tbl
+		if table.Reindex {
+			p.in <- func() error {
+				indexes := managedIndexes(table.dialect(), table.indexNames(), table.Schema, tableName, table.Srid, table.Columns, table.IdIndex, table.Indexes)
+				return pg.reindexTable(table.Schema, tableName, indexes, mode)
+			}
+			continue
+		}
 		p.in <- func() error {
-			return clusterTable(pg, tableName, table.Srid, table.Columns)
+			return clusterTable(pg, table.dialect(), table.indexNames(), table.Schema, tableName, table.Srid, table.Columns)
 		}
 	}
 	for _, tbl := range pg.GeneralizedTables {
 		tableName := tbl.FullName
 		table := tbl
+		if table.Source.Reindex {
+			p.in <- func() error {
+				indexes := managedIndexes(table.Source.dialect(), table.Source.indexNames(), table.Schema, tableName, table.Source.Srid, table.Source.Columns, table.Source.IdIndex, nil)
+				return pg.reindexTable(table.Schema, tableName, indexes, mode)
+			}
+			continue
+		}
 		p.in <- func() error {
-			return clusterTable(pg, tableName, table.Source.Srid, table.Source.Columns)
+			return clusterTable(pg, table.Source.dialect(), table.Source.indexNames(), table.Schema, tableName, table.Source.Srid, table.Source.Columns)
 		}
 	}
 
-	err := p.wait()
+	err = p.wait()
 	if err != nil {
 		return err
 	}
 
+	if pg.Config.SpatialStats {
+		return pg.logSpatialStats()
+	}
 	return nil
 }
 
-func clusterTable(pg *PostGIS, tableName string, srid int, columns []ColumnSpec) error {
+func clusterTable(pg *PostGIS, d Dialect, names indexNameTemplate, schema, tableName string, srid int, columns []ColumnSpec) error {
 	for _, col := range columns {
 		if col.Type.Name() == "GEOMETRY" {
 			step := log.StartStep(fmt.Sprintf("Indexing %s on geohash", tableName))
-			sql := fmt.Sprintf(`CREATE INDEX "%s_geom_geohash" ON "%s"."%s" (ST_GeoHash(ST_Transform(ST_SetSRID(Box2D(%s), %d), 4326)))`,
-				tableName, pg.Config.ImportSchema, tableName, col.Name, srid)
+			sql := geohashIndexSQL(d, names, schema, tableName, col.Name, srid)
 			_, err := pg.Db.Exec(sql)
 			log.StopStep(step)
 			if err != nil {
@@ -390,8 +549,8 @@ func clusterTable(pg *PostGIS, tableName string, srid int, columns []ColumnSpec)
 			}
 
 			step = log.StartStep(fmt.Sprintf("Clustering %s on geohash", tableName))
-			sql = fmt.Sprintf(`CLUSTER "%s_geom_geohash" ON "%s"."%s"`,
-				tableName, pg.Config.ImportSchema, tableName)
+			sql = fmt.Sprintf(`CLUSTER "%s" ON "%s"."%s"`,
+				names.geohashIndexName(tableName, col.Name), schema, tableName)
 			_, err = pg.Db.Exec(sql)
 			log.StopStep(step)
 			if err != nil {
@@ -403,7 +562,7 @@ func clusterTable(pg *PostGIS, tableName string, srid int, columns []ColumnSpec)
 
 	step := log.StartStep(fmt.Sprintf("Analysing %s", tableName))
 	sql := fmt.Sprintf(`ANALYSE "%s"."%s"`,
-		pg.Config.ImportSchema, tableName)
+		schema, tableName)
 	_, err := pg.Db.Exec(sql)
 	log.StopStep(step)
 	if err != nil {
@@ -413,6 +572,22 @@ func clusterTable(pg *PostGIS, tableName string, srid int, columns []ColumnSpec)
 	return nil
 }
 
+// PostGIS implements database.DB. Tables and GeneralizedTables are
+// created by New and the maps and the configuration of the specs are not
+// modified afterwards, so that they can be read without locking. The
+// exceptions are:
+//
+// The TableSpecs and mapping.Fields hold the counters and logs of the
+// import (rows, dedup, vertexLimits, analyzer, autoSrid and the nulled
+// counters of the fields). They are shared by all TableTx and protected
+// by their own mutex or updated atomically.
+//
+// TableSpec.Srid of tables with a detected SRID (Config.Srid 0) is set
+// by End of bulk imports (see updateDetectedSrid), after all TableTx and
+// their goroutines finished.
+//
+// All other shared state is protected by a mutex or owned by a single
+// TableTx.
 type PostGIS struct {
 	Db                      *sql.DB
 	Params                  string
@@ -422,16 +597,46 @@ type PostGIS struct {
 	Prefix                  string
 	txRouter                *TxRouter
 	updateGeneralizedTables bool
+	updatedIdsMu            sync.Mutex
 	updatedIds              map[string][]int64
+	// reinsertedIds are ids of deleted rows of generalized tables with
+	// osm_type (see deleteGeneralized)
+	reinsertedIds map[string][]int64
+	// tables with disabled triggers, see disableTriggersDuringLoad
+	disabledTriggers []string
+	// transaction that holds the import lock, see beginImport
+	importLockTx *sql.Tx
+	importId     int64
+	// tables that are not selected with OnlyTables/SkipTables
+	skipped map[string]bool
+	// set if the SRID is detected from the data (Config.Srid 0)
+	autoSrid *sridDetector
+	// durations of Optimize for tables with reindex
+	reindexMu        sync.Mutex
+	reindexDurations map[string]time.Duration
+	// durations of the constraint validation of Finish
+	constraintMu        sync.Mutex
+	constraintDurations map[string]time.Duration
+	// max. idle connections of pg.Db, set by Warmup
+	warmConns int
+	// enums of the mapping
+	enums map[string]*enumSpec
+	// limits the write rate of all tables
+	throttle throttle
 }
 
 func (pg *PostGIS) Open() error {
 	var err error
 
-	pg.Db, err = sql.Open("postgres", pg.Params)
+	stmts := pg.sessionSQL()
+	if len(stmts) > 0 && pg.Config.PgBouncer {
+		return errors.New("DefaultTablespace and TempTablespaces are not supported with pgbouncer")
+	}
+	c, err := newConnector(pg.Params, newNoticeFilter(pg.Config.SuppressNoticeCodes, pg.Config.SuppressNoticeMessages), stmts)
 	if err != nil {
 		return err
 	}
+	pg.Db = sql.OpenDB(c)
 	// check that the connection actually works
 	err = pg.Db.Ping()
 	if err != nil {
@@ -441,53 +646,101 @@ func (pg *PostGIS) Open() error {
 }
 
 func (pg *PostGIS) InsertPoint(elem element.OSMElem, geom geom.Geometry, matches []mapping.Match) error {
+	matches, err := pg.selectedMatches(matches)
+	if err != nil {
+		return err
+	}
 	for _, match := range matches {
-		row := match.Row(&elem, &geom)
+		row := pg.matchRow(match, &elem, &geom)
 		if err := pg.txRouter.Insert(match.Table.Name, row); err != nil {
 			return err
 		}
 	}
+	if err := pg.insertTags(&elem, matches); err != nil {
+		return err
+	}
 	return nil
 }
 
 func (pg *PostGIS) InsertLineString(elem element.OSMElem, geom geom.Geometry, matches []mapping.Match) error {
+	matches, err := pg.selectedMatches(matches)
+	if err != nil {
+		return err
+	}
 	for _, match := range matches {
-		row := match.Row(&elem, &geom)
+		row := pg.matchRow(match, &elem, &geom)
 		if err := pg.txRouter.Insert(match.Table.Name, row); err != nil {
 			return err
 		}
 	}
+	if err := pg.insertTags(&elem, matches); err != nil {
+		return err
+	}
 	if pg.updateGeneralizedTables {
-		for _, generalizedTable := range pg.generalizedFromMatches(matches) {
-			pg.updatedIds[generalizedTable.Name] = append(pg.updatedIds[generalizedTable.Name], elem.Id)
-		}
+		pg.addUpdatedIds(elem.Id, matches)
 	}
 	return nil
 }
 
 func (pg *PostGIS) InsertPolygon(elem element.OSMElem, geom geom.Geometry, matches []mapping.Match) error {
+	matches, err := pg.selectedMatches(matches)
+	if err != nil {
+		return err
+	}
 	for _, match := range matches {
-		row := match.Row(&elem, &geom)
+		row := pg.matchRow(match, &elem, &geom)
 		if err := pg.txRouter.Insert(match.Table.Name, row); err != nil {
 			return err
 		}
 	}
+	if err := pg.insertTags(&elem, matches); err != nil {
+		return err
+	}
 	if pg.updateGeneralizedTables {
-		for _, generalizedTable := range pg.generalizedFromMatches(matches) {
-			pg.updatedIds[generalizedTable.Name] = append(pg.updatedIds[generalizedTable.Name], elem.Id)
-		}
+		pg.addUpdatedIds(elem.Id, matches)
 	}
 	return nil
 }
 
+// addUpdatedIds records the id for all generalized tables of the matches.
+func (pg *PostGIS) addUpdatedIds(id int64, matches []mapping.Match) {
+	generalizedTables := pg.generalizedFromMatches(matches)
+	pg.updatedIdsMu.Lock()
+	for _, generalizedTable := range generalizedTables {
+		pg.updatedIds[generalizedTable.Name] = append(pg.updatedIds[generalizedTable.Name], id)
+	}
+	pg.updatedIdsMu.Unlock()
+}
+
+// deleteGeneralized deletes all rows of the id from the generalized
+// table. Rows of other element types with the same id (see osm_type) are
+// inserted again with GeneralizeUpdates, unless the id is also updated.
+func (pg *PostGIS) deleteGeneralized(table *GeneralizedTableSpec, id int64) {
+	pg.txRouter.Delete(table.Name, id)
+	if table.Source != nil && table.Source.osmTypeColumn() >= 0 {
+		pg.updatedIdsMu.Lock()
+		pg.reinsertedIds[table.Name] = append(pg.reinsertedIds[table.Name], id)
+		pg.updatedIdsMu.Unlock()
+	}
+}
+
 func (pg *PostGIS) Delete(id int64, matches interface{}) error {
 	if matches, ok := matches.([]mapping.Match); ok {
+		var selected []mapping.Match
 		for _, match := range matches {
-			pg.txRouter.Delete(match.Table.Name, id)
+			if pg.skipped[match.Table.Name] {
+				continue
+			}
+			pg.txRouter.DeleteType(match.Table.Name, id, match.OsmType)
+			selected = append(selected, match)
+		}
+		matches = selected
+		if len(matches) > 0 {
+			pg.deleteTags(id)
 		}
 		if pg.updateGeneralizedTables {
 			for _, generalizedTable := range pg.generalizedFromMatches(matches) {
-				pg.txRouter.Delete(generalizedTable.Name, id)
+				pg.deleteGeneralized(generalizedTable, id)
 			}
 		}
 	}
@@ -500,14 +753,15 @@ func (pg *PostGIS) DeleteElem(elem element.OSMElem) error {
 	// main-member. those tags are not avail. during delete. just try to
 	// delete from each polygon table.
 	if v, ok := elem.Tags["type"]; ok && (v == "multipolygon" || v == "boundary") {
+		pg.deleteTags(elem.Id)
 		for _, tableSpec := range pg.Tables {
 			if tableSpec.GeometryType != "polygon" {
 				continue
 			}
-			pg.txRouter.Delete(tableSpec.Name, elem.Id)
+			pg.txRouter.DeleteType(tableSpec.Name, elem.Id, mapping.OsmTypeRelation)
 			if pg.updateGeneralizedTables {
 				for _, genTable := range tableSpec.Generalizations {
-					pg.txRouter.Delete(genTable.Name, elem.Id)
+					pg.deleteGeneralized(genTable, elem.Id)
 				}
 			}
 		}
@@ -544,30 +798,76 @@ func (pg *PostGIS) sortedGeneralizedTables() []string {
 func (pg *PostGIS) EnableGeneralizeUpdates() {
 	pg.updateGeneralizedTables = true
 	pg.updatedIds = make(map[string][]int64)
+	pg.reinsertedIds = make(map[string][]int64)
 }
 
 func (pg *PostGIS) Begin() error {
+	if err := pg.disableTriggersDuringLoad(false); err != nil {
+		return err
+	}
+	if pg.Config.MaxTransactionAge > 0 {
+		log.Warnf("ignoring MaxTransactionAge of %s, all changes are committed in a single transaction", pg.Config.MaxTransactionAge)
+	}
 	var err error
 	pg.txRouter, err = newTxRouter(pg, false)
 	return err
 }
 
 func (pg *PostGIS) BeginBulk() error {
+	if err := pg.disableTriggersDuringLoad(true); err != nil {
+		return err
+	}
 	var err error
 	pg.txRouter, err = newTxRouter(pg, true)
 	return err
 }
 
 func (pg *PostGIS) Abort() error {
-	return pg.txRouter.Abort()
+	err := pg.txRouter.Abort()
+	if triggerErr := pg.enableTriggersAfterLoad(); err == nil {
+		err = triggerErr
+	}
+	// keep the import unfinished
+	if lockErr := pg.releaseImportLock(); err == nil {
+		err = lockErr
+	}
+	return err
 }
 
 func (pg *PostGIS) End() error {
-	return pg.txRouter.End()
+	err := pg.txRouter.End()
+	if err == nil && pg.txRouter.tx == nil {
+		// bulk import, before the generalized tables are created
+		err = pg.updateDetectedSrid()
+	}
+	pg.waitAnalyzes()
+	// log after End, bulk imports insert rows till all tables are committed
+	pg.logVertexLimitReports()
+	pg.logDedupCounts()
+	pg.logRowCounts()
+	if triggerErr := pg.enableTriggersAfterLoad(); err == nil {
+		err = triggerErr
+	}
+	return err
+}
+
+// Flush commits all rows that were inserted before the call, e.g. before
+// a replication sequence is recorded. Rows that are inserted during the
+// Flush are committed with the next Flush or End. Close calls Flush for
+// imports without End or Abort.
+func (pg *PostGIS) Flush() error {
+	if pg.txRouter == nil || pg.txRouter.ended {
+		return nil
+	}
+	return pg.txRouter.Flush()
 }
 
 func (pg *PostGIS) Close() error {
-	return pg.Db.Close()
+	err := pg.Flush()
+	if closeErr := pg.Db.Close(); err == nil {
+		err = closeErr
+	}
+	return err
 }
 
 func New(conf database.Config, m *mapping.Mapping) (database.DB, error) {
@@ -591,24 +891,108 @@ func New(conf database.Config, m *mapping.Mapping) (database.DB, error) {
 	}
 	params = disableDefaultSsl(params)
 	params, db.Prefix = stripPrefixFromConnectionParams(params)
-
-	for name, table := range m.Tables {
-		db.Tables[name] = NewTableSpec(db, table)
+	var pgBouncer bool
+	params, pgBouncer = stripPgBouncerFromConnectionParams(params)
+	if pgBouncer {
+		db.Config.PgBouncer = true
 	}
-	for name, table := range m.GeneralizedTables {
-		db.GeneralizedTables[name] = NewGeneralizedTableSpec(db, table)
+
+	if err := db.prepareTables(m); err != nil {
+		return nil, err
 	}
-	db.prepareGeneralizedTableSources()
-	db.prepareGeneralizations()
+	db.throttle.setLimit(conf.MaxRowsPerSecond, conf.MaxBytesPerSecond)
 
 	db.Params = params
 	err = db.Open()
 	if err != nil {
 		return nil, err
 	}
+	db.logPgBouncerMode()
+	if err := db.detectGridFunction(); err != nil {
+		return nil, err
+	}
 	return db, nil
 }
 
+// prepareTables creates the specs for all selected tables of the mapping.
+// It returns TableSpecErrors with the problems of all invalid tables.
+func (pg *PostGIS) prepareTables(m *mapping.Mapping) error {
+	skipped, err := pg.skippedTables(m)
+	if err != nil {
+		return err
+	}
+	pg.skipped = skipped
+	if err := checkGeometryEncoding(pg.Config.GeometryEncoding, pg.Config.Srid); err != nil {
+		return err
+	}
+	if err := checkLoadMethodName(pg.Config.LoadMethod); err != nil {
+		return err
+	}
+	if err := checkGeometryMismatchPolicy(pg.Config.GeometryMismatchPolicy); err != nil {
+		return err
+	}
+	if err := checkIndexNameTemplate(pg.Config.IndexNameTemplate); err != nil {
+		return err
+	}
+	if err := pg.prepareImportID(); err != nil {
+		return err
+	}
+	if err := pg.prepareEnums(m.Enums); err != nil {
+		return err
+	}
+	var errs TableSpecErrors
+	for name, table := range m.Tables {
+		if skipped[name] {
+			continue
+		}
+		spec, err := NewTableSpec(pg, table)
+		if err != nil {
+			errs = append(errs, err.(*TableSpecError))
+			continue
+		}
+		pg.Tables[name] = spec
+	}
+	if len(errs) > 0 {
+		sort.Sort(errs)
+		return errs
+	}
+	if err := pg.prepareTagsTable(m); err != nil {
+		return err
+	}
+	if err := pg.prepareInheritance(); err != nil {
+		return err
+	}
+	if err := pg.prepareForeignKeys(); err != nil {
+		return err
+	}
+	pg.suggestOsmTypeColumns(m)
+	if err := checkCustomSrids(pg.Config, pg.Tables); err != nil {
+		return err
+	}
+	for name, table := range m.GeneralizedTables {
+		if skipped[name] {
+			continue
+		}
+		if pg.Config.SkipGeometry {
+			log.Printf("skipping generalized table %s without geometries", name)
+			continue
+		}
+		pg.GeneralizedTables[name] = NewGeneralizedTableSpec(pg, table)
+	}
+	pg.prepareGeneralizedTableSources()
+	if err := pg.checkGeneralizedTableSources(); err != nil {
+		return err
+	}
+	pg.prepareGeneralizations()
+	// generalized tables are in the schema of the source table
+	for _, table := range pg.GeneralizedTables {
+		if table.Source != nil {
+			table.Schema = table.Source.Schema
+		}
+	}
+	return nil
+}
+
 // prepareGeneralizedTableSources checks if all generalized table have an
 // existing source and sets .Source to the original source (works even
 // when source is allready generalized).
@@ -638,6 +1022,23 @@ func (pg *PostGIS) prepareGeneralizedTableSources() {
 	}
 }
 
+// checkGeneralizedTableSources returns TableSpecErrors for all
+// generalized tables of sources without geometry.
+func (pg *PostGIS) checkGeneralizedTableSources() error {
+	var errs TableSpecErrors
+	for name, table := range pg.GeneralizedTables {
+		if table.Source != nil && !table.Source.hasGeometry() {
+			errs = append(errs, &TableSpecError{name, []string{
+				fmt.Sprintf("source table %s has no geometry", table.Source.Name)}})
+		}
+	}
+	if len(errs) > 0 {
+		sort.Sort(errs)
+		return errs
+	}
+	return nil
+}
+
 func (pg *PostGIS) prepareGeneralizations() {
 	for _, table := range pg.GeneralizedTables {
 		table.Source.Generalizations = append(table.Source.Generalizations, table)
@@ -0,0 +1,302 @@
+package postgis
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	pq "github.com/lib/pq"
+	"github.com/omniscale/imposm3/element"
+	"github.com/omniscale/imposm3/geom"
+	"github.com/omniscale/imposm3/mapping"
+)
+
+func TestRetryOnLockTimeout(t *testing.T) {
+	var slept []time.Duration
+	sleep = func(d time.Duration) { slept = append(slept, d) }
+	defer func() { sleep = time.Sleep }()
+
+	calls := 0
+	err := retryOnLockTimeout(3, time.Second, func() error {
+		calls += 1
+		if calls < 3 {
+			return &SQLError{"DROP TABLE foo", &pq.Error{Code: "55P03", Message: "canceling statement due to lock timeout"}}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 3 {
+		t.Error("unexpected number of calls", calls)
+	}
+	if len(slept) != 2 || slept[0] != time.Second || slept[1] != 2*time.Second {
+		t.Error("unexpected backoff", slept)
+	}
+}
+
+func TestRetryOnLockTimeoutExhausted(t *testing.T) {
+	sleep = func(d time.Duration) {}
+	defer func() { sleep = time.Sleep }()
+
+	calls := 0
+	err := retryOnLockTimeout(2, time.Second, func() error {
+		calls += 1
+		return &SQLError{"DROP TABLE foo", &pq.Error{Code: "55P03"}}
+	})
+	if !isLockNotAvailable(err) {
+		t.Error("expected lock error", err)
+	}
+	if calls != 3 {
+		t.Error("unexpected number of calls", calls)
+	}
+}
+
+func TestRetryOnLockTimeoutFailFast(t *testing.T) {
+	sleep = func(d time.Duration) { t.Error("unexpected retry") }
+	defer func() { sleep = time.Sleep }()
+
+	for _, expected := range []error{
+		&SQLError{"CREATE TABLE foo", &pq.Error{Code: "42501", Message: "permission denied for schema import"}},
+		&SQLError{"CREATE TABLE foo", &pq.Error{Code: "42601", Message: "syntax error at or near"}},
+		errors.New("connection refused"),
+	} {
+		calls := 0
+		err := retryOnLockTimeout(5, time.Second, func() error {
+			calls += 1
+			return expected
+		})
+		if err != expected {
+			t.Error("unexpected error", err)
+		}
+		if calls != 1 {
+			t.Error("unexpected number of calls", calls)
+		}
+	}
+}
+
+// countingTableTx applies the row filters of the table spec and
+// counts the inserted rows.
+type countingTableTx struct {
+	spec     *TableSpec
+	inserted int64
+}
+
+func (tt *countingTableTx) Begin(*sql.Tx) error { return nil }
+func (tt *countingTableTx) Insert(row []interface{}) error {
+	if tt.spec != nil {
+		if tt.spec.dedup != nil && tt.spec.dedup.duplicate(row) {
+			return nil
+		}
+		if _, err := tt.spec.limitVertices(row); err != nil {
+			return nil
+		}
+	}
+	atomic.AddInt64(&tt.inserted, 1)
+	return nil
+}
+func (tt *countingTableTx) Delete(id int64) error { return nil }
+func (tt *countingTableTx) End()                  {}
+func (tt *countingTableTx) Commit() error         { return nil }
+func (tt *countingTableTx) Rollback()             {}
+
+// TestConcurrentInserts inserts from multiple goroutines into the
+// TableTx of bulk and of diff imports, run with -race.
+func TestConcurrentInserts(t *testing.T) {
+	for _, bulkImport := range []bool{true, false} {
+		testConcurrentInserts(t, bulkImport)
+	}
+}
+
+func testConcurrentInserts(t *testing.T, bulkImport bool) {
+	m, err := mapping.NewMapping("test_mapping.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pg := testPostGIS()
+	if bulkImport {
+		// detect the SRID, End updates spec.Srid
+		pg.Config.Srid = 0
+	}
+	pg.Tables = make(map[string]*TableSpec)
+	pg.GeneralizedTables = make(map[string]*GeneralizedTableSpec)
+	if err := pg.prepareTables(m); err != nil {
+		t.Fatal(err)
+	}
+
+	gen := &GeneralizedTableSpec{Name: "landusages_gen0", FullName: "osm_landusages_gen0",
+		Schema: pg.Config.ImportSchema, Source: pg.Tables["landusages"]}
+	pg.GeneralizedTables[gen.Name] = gen
+	pg.Tables["landusages"].Generalizations = append(pg.Tables["landusages"].Generalizations, gen)
+
+	for _, spec := range pg.Tables {
+		spec.MaxVertices = 5
+		spec.vertexLimits = &vertexLimitLog{}
+		spec.dedup, err = newDeduplicator(&mapping.Dedup{Scope: "lru", Size: 1000}, spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// count the rows for the throttle without waiting
+	pg.SetThrottle(1e9, 0)
+
+	db, d := newFakeDb()
+	defer db.Close()
+	d.results = map[string]fakeResult{
+		"SELECT UpdateGeometrySRID": {columns: []string{"updategeometrysrid"}, rows: [][]driver.Value{{"ok"}}},
+	}
+	pg.Db = db
+	pg.txRouter, err = newTxRouter(pg, bulkImport)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bulkImport {
+		pg.EnableGeneralizeUpdates()
+	}
+
+	matcher := m.PolygonMatcher()
+	// six vertices, simplified to five
+	square := ewkbPolygon(3857, []float64{0, 0, 5, 0, 10, 0, 10, 10, 0, 10, 0, 0}).hex()
+
+	done := make(chan struct{})
+	go func() {
+		// read metadata while inserting
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				pg.VertexLimitReports()
+				pg.DedupCounts()
+			}
+		}
+	}()
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				way := element.Way{
+					OSMElem: element.OSMElem{Id: int64(i*1000 + j%100), Tags: element.Tags{"amenity": "parking", "name": "foo"}},
+					Refs:    []int64{1, 2, 3, 4, 1},
+				}
+				matches := matcher.MatchWay(&way)
+				if len(matches) == 0 {
+					t.Error("no matches")
+					return
+				}
+				if err := pg.InsertPolygon(way.OSMElem, geom.Geometry{Wkb: []byte(square)}, matches); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if !bulkImport {
+		if err := pg.GeneralizeUpdates(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// End waits for the goroutines of the bulk TableTx before it sets the
+	// detected SRID
+	if err := pg.End(); err != nil {
+		t.Fatal(err)
+	}
+	close(done)
+
+	if bulkImport {
+		if srid := pg.Tables["landusages"].Srid; srid != 3857 {
+			t.Error("SRID not detected", srid)
+		}
+		if n := d.count("SELECT UpdateGeometrySRID"); n != len(pg.Tables) {
+			t.Error("unexpected number of SRID updates", n)
+		}
+	}
+
+	rows := len(d.values(`COPY "import"."osm_landusages"`, 0)) +
+		len(d.values(`INSERT INTO "import"."osm_landusages" `, 0))
+	if rows != 8*100 {
+		t.Error("unexpected number of inserts", bulkImport, rows)
+	}
+	if !bulkImport {
+		if n := len(d.values(`INSERT INTO "import"."osm_landusages_gen0"`, 0)); n != 8*200 {
+			t.Error("unexpected number of generalized updates", n)
+		}
+	}
+	if n := pg.DedupCounts()["landusages"]; n != 8*100 {
+		t.Error("unexpected number of duplicates", bulkImport, n)
+	}
+	if r := pg.VertexLimitReports()["landusages"]; len(r.Simplified) != 800 || len(r.Rejected) != 0 {
+		t.Error("unexpected vertex limit report", bulkImport, len(r.Simplified), len(r.Rejected))
+	}
+}
+
+func TestPrepareTablesDefaultMapping(t *testing.T) {
+	pg := testPostGIS()
+	pg.Tables = make(map[string]*TableSpec)
+	pg.GeneralizedTables = make(map[string]*GeneralizedTableSpec)
+	m := mapping.Default()
+	if err := pg.prepareTables(m); err != nil {
+		t.Fatal(err)
+	}
+	if len(pg.Tables) != len(m.Tables) || len(pg.GeneralizedTables) != len(m.GeneralizedTables) {
+		t.Error("unexpected tables", len(pg.Tables), len(pg.GeneralizedTables))
+	}
+}
+
+func TestIdIndexSQL(t *testing.T) {
+	expected := `CREATE INDEX "osm_roads_osm_id_idx" ON "import"."osm_roads" USING BTREE ("osm_id")`
+	if sql := idIndexSQL(PostgreSQL, "", "import", "osm_roads", "osm_id", ""); sql != expected {
+		t.Errorf("unexpected SQL\n%s\n%s", sql, expected)
+	}
+	expected = `CREATE INDEX "osm_roads_osm_id_idx" ON "import"."osm_roads" USING BRIN ("osm_id")`
+	if sql := idIndexSQL(PostgreSQL, "", "import", "osm_roads", "osm_id", idIndexBrin); sql != expected {
+		t.Errorf("unexpected SQL\n%s\n%s", sql, expected)
+	}
+}
+
+func TestFinishBrinIdIndex(t *testing.T) {
+	db, d := newFakeDb()
+	defer db.Close()
+	pg := testPostGIS()
+	pg.Db = db
+	table := testTable()
+	table.IdIndex = "brin"
+	spec := testTableSpec(t, pg, table)
+	pg.Tables = map[string]*TableSpec{"roads": spec}
+	pg.GeneralizedTables = map[string]*GeneralizedTableSpec{
+		"roads_gen0": {Name: "roads_gen0", FullName: "osm_roads_gen0", Schema: "import", Source: spec},
+	}
+	if err := pg.Finish(); err != nil {
+		t.Fatal(err)
+	}
+	for _, sql := range []string{
+		`CREATE INDEX "osm_roads_osm_id_idx" ON "import"."osm_roads" USING BRIN ("osm_id")`,
+		`CREATE INDEX "osm_roads_gen0_osm_id_idx" ON "import"."osm_roads_gen0" USING BRIN ("osm_id")`,
+	} {
+		if n := d.count(sql); n != 1 {
+			t.Errorf("missing %s in %v", sql, d.execs)
+		}
+	}
+
+	// reindexed with the same method
+	for _, idx := range managedIndexes(PostgreSQL, "", "import", "osm_roads", 3857, spec.Columns, spec.IdIndex, nil) {
+		if idx.name == "osm_roads_osm_id_idx" && !strings.Contains(idx.sql, "USING BRIN") {
+			t.Error("unexpected index", idx.sql)
+		}
+	}
+
+	table.IdIndex = "hash"
+	if _, err := NewTableSpec(pg, table); err == nil || !strings.Contains(err.Error(), "unknown id_index 'hash'") {
+		t.Error("expected error for unknown id_index", err)
+	}
+}
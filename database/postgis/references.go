@@ -0,0 +1,68 @@
+package postgis
+
+import (
+	"fmt"
+)
+
+// referenceSampleSize is the max number of orphaned values that
+// CheckReferences returns.
+const referenceSampleSize = 10
+
+// CheckReferences returns the number of rows of childTable with a value in
+// childCol that is missing in parentCol of parentTable and the values of up
+// to 10 of these orphaned rows, e.g. relation members without the member
+// element. The tables are the names of the mapping and they need to be in
+// the import schema (i.e. before Deploy). Rows with a NULL value are not
+// checked.
+func (pg *PostGIS) CheckReferences(childTable, childCol, parentTable, parentCol string) (int64, []string, error) {
+	child, err := pg.referenceTable(childTable, childCol)
+	if err != nil {
+		return 0, nil, err
+	}
+	parent, err := pg.referenceTable(parentTable, parentCol)
+	if err != nil {
+		return 0, nil, err
+	}
+	sql := checkReferencesSQL(child, childCol, parent, parentCol, referenceSampleSize)
+	rows, err := pg.Db.Query(sql)
+	if err != nil {
+		return 0, nil, &SQLError{sql, err}
+	}
+	defer rows.Close()
+
+	var orphans int64
+	var sample []string
+	for rows.Next() {
+		var value nullString
+		if err := rows.Scan(&value, &orphans); err != nil {
+			return 0, nil, &SQLError{sql, err}
+		}
+		sample = append(sample, string(value))
+	}
+	if err := rows.Err(); err != nil {
+		return 0, nil, &SQLError{sql, err}
+	}
+	return orphans, sample, nil
+}
+
+func (pg *PostGIS) referenceTable(table, column string) (*TableSpec, error) {
+	spec, ok := pg.Tables[table]
+	if !ok {
+		return nil, fmt.Errorf("unknown table %s", table)
+	}
+	if !spec.hasColumn(column) {
+		return nil, fmt.Errorf("unknown column %s of table %s", column, table)
+	}
+	return spec, nil
+}
+
+// checkReferencesSQL returns the query for the orphaned rows. The window
+// function counts all orphaned rows, before the LIMIT of the sample.
+func checkReferencesSQL(child *TableSpec, childCol string, parent *TableSpec, parentCol string, limit int) string {
+	return fmt.Sprintf(`SELECT c."%s"::text, count(*) OVER () FROM "%s"."%s" c `+
+		`LEFT JOIN "%s"."%s" p ON c."%s" = p."%s" `+
+		`WHERE c."%s" IS NOT NULL AND p."%s" IS NULL LIMIT %d`,
+		childCol, child.Schema, child.FullName,
+		parent.Schema, parent.FullName, childCol, parentCol,
+		childCol, parentCol, limit)
+}
@@ -0,0 +1,81 @@
+package postgis
+
+import (
+	"database/sql/driver"
+	"reflect"
+	"testing"
+
+	"github.com/omniscale/imposm3/mapping"
+)
+
+func testReferencesPostGIS(t *testing.T) *PostGIS {
+	pg := testPostGIS()
+	roads := testTableSpec(t, pg, testTable())
+	members := &mapping.Table{
+		Name: "route_members",
+		Type: mapping.NoneTable,
+		Fields: []*mapping.Field{
+			{Name: "osm_id", Type: "id"},
+			{Name: "member", Type: "integer"},
+		},
+	}
+	pg.Tables = map[string]*TableSpec{
+		"roads":         roads,
+		"route_members": testTableSpec(t, pg, members),
+	}
+	return pg
+}
+
+func TestCheckReferencesSQL(t *testing.T) {
+	pg := testReferencesPostGIS(t)
+	sql := checkReferencesSQL(pg.Tables["route_members"], "member", pg.Tables["roads"], "osm_id", 10)
+	expected := `SELECT c."member"::text, count(*) OVER () FROM "import"."osm_route_members" c ` +
+		`LEFT JOIN "import"."osm_roads" p ON c."member" = p."osm_id" ` +
+		`WHERE c."member" IS NOT NULL AND p."osm_id" IS NULL LIMIT 10`
+	if sql != expected {
+		t.Errorf("unexpected SQL\n%s\n%s", sql, expected)
+	}
+}
+
+func TestCheckReferences(t *testing.T) {
+	db, d := newFakeDb()
+	defer db.Close()
+	pg := testReferencesPostGIS(t)
+	pg.Db = db
+
+	d.results = map[string]fakeResult{
+		`SELECT c."member"::text`: {
+			columns: []string{"member", "count"},
+			rows: [][]driver.Value{
+				{[]byte("1001"), int64(42)},
+				{[]byte("1002"), int64(42)},
+			},
+		},
+	}
+	orphans, sample, err := pg.CheckReferences("route_members", "member", "roads", "osm_id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if orphans != 42 {
+		t.Error("unexpected orphans", orphans)
+	}
+	if !reflect.DeepEqual(sample, []string{"1001", "1002"}) {
+		t.Error("unexpected sample", sample)
+	}
+
+	d.results[`SELECT c."member"::text`] = fakeResult{columns: []string{"member", "count"}}
+	orphans, sample, err = pg.CheckReferences("route_members", "member", "roads", "osm_id")
+	if err != nil || orphans != 0 || sample != nil {
+		t.Error("unexpected result without orphans", orphans, sample, err)
+	}
+}
+
+func TestCheckReferencesUnknown(t *testing.T) {
+	pg := testReferencesPostGIS(t)
+	if _, _, err := pg.CheckReferences("unknown", "member", "roads", "osm_id"); err == nil || err.Error() != "unknown table unknown" {
+		t.Error("expected unknown table error", err)
+	}
+	if _, _, err := pg.CheckReferences("route_members", "member", "roads", "way_id"); err == nil || err.Error() != "unknown column way_id of table roads" {
+		t.Error("expected unknown column error", err)
+	}
+}
@@ -0,0 +1,165 @@
+package postgis
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Tables with reindex are reindexed in Optimize instead of being
+// clustered, e.g. to remove the bloat of the GiST indexes after large diff
+// imports. Only indexes that imposm created are reindexed: the geometry,
+// OSM id and GeoHash indexes and the indexes of the mapping. Indexes that
+// don't exist (e.g. the GeoHash index of tables that were never
+// clustered) are skipped.
+//
+// With ReindexOnline, indexes are rebuilt with REINDEX CONCURRENTLY
+// (PostgreSQL 12). Older servers create a new index with CREATE INDEX
+// CONCURRENTLY that replaces the old index.
+
+type reindexMode int
+
+const (
+	reindexLocked reindexMode = iota
+	reindexConcurrently
+	reindexReplace
+)
+
+// minReindexConcurrentlyVersion is the server_version_num of PostgreSQL 12.
+const minReindexConcurrentlyVersion = 120000
+
+// managedIndex is an index that imposm creates.
+type managedIndex struct {
+	name string
+	// sql is the CREATE INDEX statement
+	sql string
+}
+
+// managedIndexes returns all indexes that imposm creates for the table.
+func managedIndexes(d Dialect, names indexNameTemplate, schema, tableName string, srid int, columns []ColumnSpec, idIndex string, indexes []IndexSpec) []managedIndex {
+	var result []managedIndex
+	for _, col := range columns {
+		if col.Type.Name() == "GEOMETRY" {
+			result = append(result,
+				managedIndex{names.geometryIndexName(tableName, col.Name), geometryIndexSQL(d, names, schema, tableName, col.Name)},
+				managedIndex{names.geohashIndexName(tableName, col.Name), geohashIndexSQL(d, names, schema, tableName, col.Name, srid)},
+			)
+		}
+		if col.FieldType.Name == "id" {
+			result = append(result, managedIndex{names.idIndexName(tableName, col.Name), idIndexSQL(d, names, schema, tableName, col.Name, idIndex)})
+		}
+	}
+	for _, idx := range indexes {
+		result = append(result, managedIndex{idx.indexName(names, tableName), idx.IndexSQL(d, names, schema, tableName)})
+	}
+	return result
+}
+
+// reindexSQL returns the statements that rebuild the index.
+func reindexSQL(schema string, idx managedIndex, mode reindexMode) []string {
+	switch mode {
+	case reindexConcurrently:
+		return []string{fmt.Sprintf(`REINDEX INDEX CONCURRENTLY "%s"."%s"`, schema, idx.name)}
+	case reindexReplace:
+		tmpName := truncateIdentifier(idx.name + "_reindex")
+		create := strings.Replace(idx.sql,
+			fmt.Sprintf(`CREATE INDEX "%s"`, idx.name),
+			fmt.Sprintf(`CREATE INDEX CONCURRENTLY "%s"`, tmpName), 1)
+		return []string{
+			// left by a previous run that failed
+			fmt.Sprintf(`DROP INDEX IF EXISTS "%s"."%s"`, schema, tmpName),
+			create,
+			fmt.Sprintf(`DROP INDEX CONCURRENTLY "%s"."%s"`, schema, idx.name),
+			fmt.Sprintf(`ALTER INDEX "%s"."%s" RENAME TO "%s"`, schema, tmpName, idx.name),
+		}
+	default:
+		return []string{fmt.Sprintf(`REINDEX INDEX "%s"."%s"`, schema, idx.name)}
+	}
+}
+
+// reindexMode returns how indexes are rebuilt for Config.ReindexOnline.
+func (pg *PostGIS) reindexMode() (reindexMode, error) {
+	if !pg.Config.ReindexOnline {
+		return reindexLocked, nil
+	}
+	var version int
+	sql := "SHOW server_version_num"
+	if err := pg.Db.QueryRow(sql).Scan(&version); err != nil {
+		return 0, &SQLError{sql, err}
+	}
+	if version >= minReindexConcurrentlyVersion {
+		return reindexConcurrently, nil
+	}
+	return reindexReplace, nil
+}
+
+// existingIndexes returns the names of all indexes of the table.
+func (pg *PostGIS) existingIndexes(schema, tableName string) (map[string]bool, error) {
+	sql := "SELECT indexname FROM pg_indexes WHERE schemaname = $1 AND tablename = $2"
+	names := make(map[string]bool)
+	err := pg.withArgs(func(db queryer) error {
+		rows, err := db.Query(sql, schema, tableName)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var name string
+			if err := rows.Scan(&name); err != nil {
+				return err
+			}
+			names[name] = true
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, &SQLError{sql, err}
+	}
+	return names, nil
+}
+
+// reindexTable rebuilds all existing indexes of the table and records the
+// duration of each index.
+func (pg *PostGIS) reindexTable(schema, tableName string, indexes []managedIndex, mode reindexMode) error {
+	existing, err := pg.existingIndexes(schema, tableName)
+	if err != nil {
+		return err
+	}
+	for _, idx := range indexes {
+		if !existing[idx.name] {
+			continue
+		}
+		start := time.Now()
+		step := log.StartStep(fmt.Sprintf("Reindexing %s", idx.name))
+		for _, sql := range reindexSQL(schema, idx, mode) {
+			if _, err := pg.Db.Exec(sql); err != nil {
+				log.StopStep(step)
+				return &SQLError{sql, err}
+			}
+		}
+		log.StopStep(step)
+		pg.addReindexDuration(schema+"."+idx.name, time.Since(start))
+	}
+	return nil
+}
+
+func (pg *PostGIS) addReindexDuration(index string, d time.Duration) {
+	pg.reindexMu.Lock()
+	defer pg.reindexMu.Unlock()
+	if pg.reindexDurations == nil {
+		pg.reindexDurations = make(map[string]time.Duration)
+	}
+	pg.reindexDurations[index] = d
+}
+
+// ReindexDurations returns the duration of each reindexed index (as
+// schema.index) of Optimize.
+func (pg *PostGIS) ReindexDurations() map[string]time.Duration {
+	pg.reindexMu.Lock()
+	defer pg.reindexMu.Unlock()
+	durations := make(map[string]time.Duration, len(pg.reindexDurations))
+	for index, d := range pg.reindexDurations {
+		durations[index] = d
+	}
+	return durations
+}
@@ -0,0 +1,106 @@
+package postgis
+
+import (
+	"database/sql/driver"
+	"reflect"
+	"testing"
+)
+
+func TestReindexSQL(t *testing.T) {
+	idx := managedIndex{"osm_roads_geom", geometryIndexSQL(PostgreSQL, "", "import", "osm_roads", "geometry")}
+
+	for _, tc := range []struct {
+		mode     reindexMode
+		expected []string
+	}{
+		{reindexLocked, []string{`REINDEX INDEX "import"."osm_roads_geom"`}},
+		{reindexConcurrently, []string{`REINDEX INDEX CONCURRENTLY "import"."osm_roads_geom"`}},
+		{reindexReplace, []string{
+			`DROP INDEX IF EXISTS "import"."osm_roads_geom_reindex"`,
+			`CREATE INDEX CONCURRENTLY "osm_roads_geom_reindex" ON "import"."osm_roads" USING GIST ("geometry")`,
+			`DROP INDEX CONCURRENTLY "import"."osm_roads_geom"`,
+			`ALTER INDEX "import"."osm_roads_geom_reindex" RENAME TO "osm_roads_geom"`,
+		}},
+	} {
+		if sql := reindexSQL("import", idx, tc.mode); !reflect.DeepEqual(sql, tc.expected) {
+			t.Errorf("unexpected SQL for mode %d\n%q\n%q", tc.mode, sql, tc.expected)
+		}
+	}
+}
+
+func TestReindexMode(t *testing.T) {
+	for _, tc := range []struct {
+		online   bool
+		version  string
+		expected reindexMode
+	}{
+		{false, "", reindexLocked},
+		{true, "110005", reindexReplace},
+		{true, "120001", reindexConcurrently},
+	} {
+		db, d := newFakeDb()
+		pg := testPostGIS()
+		pg.Db = db
+		pg.Config.ReindexOnline = tc.online
+		d.results = map[string]fakeResult{
+			"SHOW server_version_num": {
+				columns: []string{"server_version_num"},
+				rows:    [][]driver.Value{{[]byte(tc.version)}},
+			},
+		}
+		mode, err := pg.reindexMode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if mode != tc.expected {
+			t.Errorf("unexpected mode for %s: %d", tc.version, mode)
+		}
+		db.Close()
+	}
+}
+
+func TestOptimizeReindex(t *testing.T) {
+	db, d := newFakeDb()
+	defer db.Close()
+	pg := testPostGIS()
+	pg.Db = db
+
+	table := testTable()
+	table.Reindex = true
+	pg.Tables = map[string]*TableSpec{"roads": testTableSpec(t, pg, table)}
+
+	// the GeoHash index does not exist and other_idx is not created by imposm
+	d.results = map[string]fakeResult{
+		"SELECT indexname FROM pg_indexes": {
+			columns: []string{"indexname"},
+			rows: [][]driver.Value{
+				{[]byte("osm_roads_geom")},
+				{[]byte("osm_roads_osm_id_idx")},
+				{[]byte("other_idx")},
+			},
+		},
+	}
+	if err := pg.Optimize(); err != nil {
+		t.Fatal(err)
+	}
+	if n := d.count("CLUSTER"); n != 0 {
+		t.Error("reindexed table was clustered", n)
+	}
+	if n := d.count("REINDEX"); n != 2 {
+		t.Error("unexpected number of REINDEX", n)
+	}
+	if n := d.count(`REINDEX INDEX "import"."other_idx"`); n != 0 {
+		t.Error("reindexed index not created by imposm")
+	}
+	if tables := d.values("SELECT indexname", 1); !reflect.DeepEqual(tables, []driver.Value{"osm_roads"}) {
+		t.Error("unexpected tables", tables)
+	}
+
+	durations := pg.ReindexDurations()
+	if len(durations) != 2 {
+		t.Fatal("unexpected durations", durations)
+	}
+	if _, ok := durations["import.osm_roads_osm_id_idx"]; !ok {
+		t.Error("missing duration", durations)
+	}
+}
@@ -0,0 +1,108 @@
+package postgis
+
+import (
+	"errors"
+
+	"github.com/omniscale/imposm3/mapping"
+)
+
+// ElementRows are the new rows of an element for ReplaceBatch. Elements
+// without rows are only deleted.
+type ElementRows struct {
+	Id int64
+	// OsmType limits the delete to rows of this element type, for tables
+	// with an osm_type column. All rows of the id are deleted for
+	// mapping.OsmTypeUnknown.
+	OsmType mapping.OsmType
+	Rows    [][]interface{}
+	// Deleted and Inserted are the number of rows, set by ReplaceBatch
+	// (e.g. for the expiry of tiles and updates of generalized tables).
+	Deleted  int64
+	Inserted int64
+	// Cascaded is the number of rows of tables with a foreign key to the
+	// table that were deleted with the rows of the element (ON DELETE
+	// CASCADE), set by ReplaceBatch. These rows are not part of the
+	// Deleted rows of the other tables, if they are replaced afterwards.
+	Cascaded int64
+}
+
+// ReplaceBatch deletes all rows of each element from the table (by
+// name) and inserts the new rows of the element, for elements with
+// multiple rows (e.g. split ways or subdivided polygons) where Upsert is
+// not possible. The rows are replaced in the transaction of the current
+// diff import, or in a new transaction. Rows are inserted without
+// dedup and aggregation.
+func (pg *PostGIS) ReplaceBatch(table string, elems []ElementRows) error {
+	spec, ok := pg.Tables[table]
+	if !ok {
+		return errors.New("unknown table " + table)
+	}
+	if txr := pg.txRouter; txr != nil && !txr.ended {
+		if txr.tx == nil {
+			return errors.New("unable to replace rows in bulkImport mode")
+		}
+		txr.mu.RLock()
+		defer txr.mu.RUnlock()
+		tt, ok := txr.Tables[table].(*syncTableTx)
+		if !ok {
+			return errors.New("unknown table " + table)
+		}
+		return tt.replace(elems)
+	}
+
+	tx, err := pg.Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer rollbackIfTx(&tx)
+	tt := NewSynchronousTableTx(pg, spec.FullName, spec).(*syncTableTx)
+	if err := tt.Begin(tx); err != nil {
+		return err
+	}
+	err = tt.replace(elems)
+	tt.End()
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return geometryCheckError("COMMIT", err)
+	}
+	tx = nil // set nil to prevent rollback
+	return nil
+}
+
+// replace deletes and inserts the rows of all elements with the prepared
+// statements of the table.
+func (tt *syncTableTx) replace(elems []ElementRows) error {
+	for i := range elems {
+		elem := &elems[i]
+		elem.Deleted, elem.Inserted, elem.Cascaded = 0, 0, 0
+		var cascaded int64
+		if len(tt.tableSpec.referencedBy) > 0 {
+			var err error
+			if cascaded, err = tt.cascadedRows(elem.Id); err != nil {
+				return err
+			}
+		}
+		n, err := tt.deleteType(elem.Id, elem.OsmType)
+		if err != nil {
+			return err
+		}
+		elem.Deleted = n
+		if n > 0 {
+			// the id is unique, the rows were only cascaded if the row
+			// was deleted (and not only rows of another osm_type)
+			elem.Cascaded = cascaded
+		}
+		for _, row := range elem.Rows {
+			inserted, err := tt.insertRow(row)
+			if err != nil {
+				return err
+			}
+			if inserted {
+				elem.Inserted += 1
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package postgis
+
+import (
+	"database/sql/driver"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func testReplacePostGIS(t *testing.T) (*PostGIS, *fakeDriver) {
+	db, d := newFakeDb()
+	pg := testPostGIS()
+	pg.Db = db
+	pg.Tables = map[string]*TableSpec{"roads": testTableSpec(t, pg, testTable())}
+	return pg, d
+}
+
+func TestReplaceBatch(t *testing.T) {
+	pg, d := testReplacePostGIS(t)
+	defer pg.Db.Close()
+
+	line := ewkbLineString(3857, 0, 0, 1, 1).hex()
+	elems := []ElementRows{
+		{Id: 1, Rows: [][]interface{}{{int64(1), line, "a", ""}, {int64(1), line, "b", ""}}},
+		{Id: 2}, // only deleted
+	}
+	if err := pg.ReplaceBatch("roads", elems); err != nil {
+		t.Fatal(err)
+	}
+	var stmts []string
+	for _, sql := range d.execs {
+		stmts = append(stmts, strings.Fields(sql)[0])
+	}
+	if !reflect.DeepEqual(stmts, []string{"DELETE", "INSERT", "INSERT", "DELETE"}) {
+		t.Error("unexpected statements", d.execs)
+	}
+	if ids := d.values("DELETE", 0); !reflect.DeepEqual(ids, []driver.Value{int64(1), int64(2)}) {
+		t.Error("unexpected deleted ids", ids)
+	}
+	// the fake driver returns 1 for all RowsAffected
+	if elems[0].Deleted != 1 || elems[0].Inserted != 2 {
+		t.Error("unexpected counts", elems[0])
+	}
+	if elems[1].Deleted != 1 || elems[1].Inserted != 0 {
+		t.Error("unexpected counts", elems[1])
+	}
+	if d.commits != 1 || d.rollbacks != 0 {
+		t.Error("unexpected commits/rollbacks", d.commits, d.rollbacks)
+	}
+
+	if err := pg.ReplaceBatch("unknown", elems); err == nil {
+		t.Error("expected error for unknown table")
+	}
+}
+
+func TestReplaceBatchError(t *testing.T) {
+	pg, d := testReplacePostGIS(t)
+	defer pg.Db.Close()
+	d.fail = "INSERT"
+
+	line := ewkbLineString(3857, 0, 0, 1, 1).hex()
+	elems := []ElementRows{{Id: 1, Rows: [][]interface{}{{int64(1), line, "a", ""}}}}
+	if err := pg.ReplaceBatch("roads", elems); err == nil {
+		t.Fatal("expected error")
+	}
+	// the delete is rolled back
+	if d.commits != 0 || d.rollbacks != 1 {
+		t.Error("unexpected commits/rollbacks", d.commits, d.rollbacks)
+	}
+}
+
+func TestReplaceBatchDiff(t *testing.T) {
+	pg, d := testReplacePostGIS(t)
+	defer pg.Db.Close()
+	if err := pg.Begin(); err != nil {
+		t.Fatal(err)
+	}
+	line := ewkbLineString(3857, 0, 0, 1, 1).hex()
+	for i := 0; i < 2; i++ {
+		elems := []ElementRows{{Id: 1, Rows: [][]interface{}{{int64(1), line, "a", ""}}}}
+		if err := pg.ReplaceBatch("roads", elems); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// in the transaction of the diff import
+	if d.commits != 0 {
+		t.Error("unexpected commits", d.commits)
+	}
+	if err := pg.txRouter.End(); err != nil {
+		t.Fatal(err)
+	}
+	if d.commits != 1 || d.count("DELETE") != 2 || d.count("INSERT") != 2 {
+		t.Error("unexpected statements", d.commits, d.execs)
+	}
+}
+
+func TestReplaceBatchBulk(t *testing.T) {
+	pg, _ := testReplacePostGIS(t)
+	defer pg.Db.Close()
+	if err := pg.BeginBulk(); err != nil {
+		t.Fatal(err)
+	}
+	defer pg.txRouter.End()
+	if err := pg.ReplaceBatch("roads", []ElementRows{{Id: 1}}); err == nil {
+		t.Error("expected error in bulk import")
+	}
+}
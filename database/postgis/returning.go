@@ -0,0 +1,115 @@
+package postgis
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/omniscale/imposm3/database"
+)
+
+// InsertBatchReturningIds inserts the rows into the table and returns the
+// generated id of each row, e.g. to reference the rows from another table.
+// ids[i] is the id of rows[i], or 0 if the row was skipped (null geometry,
+// dedup), rejected (see Config.OnRowError) or an unchanged upsert (see
+// upsertChangedSQL). The table needs the implicit serial id column and
+// it can not be subdivided, as the parts would receive multiple ids.
+//
+// Each row is inserted with INSERT ... RETURNING, in a transaction that is
+// independent of Begin/End. This is considerably slower than the COPY of
+// bulk imports and LoadMethod is ignored. Errors of the database roll back
+// the whole batch.
+func (pg *PostGIS) InsertBatchReturningIds(table string, rows [][]interface{}) ([]int64, error) {
+	spec, ok := pg.Tables[table]
+	if !ok {
+		return nil, fmt.Errorf("unknown table %s", table)
+	}
+	if !spec.hasSerialId() {
+		return nil, fmt.Errorf("table %s has no generated id column", table)
+	}
+	if !spec.dialect().SupportsReturning() {
+		return nil, fmt.Errorf("table %s does not support RETURNING", table)
+	}
+	if spec.Subdivide > 0 {
+		return nil, fmt.Errorf("table %s is subdivided and returns multiple ids for each row", table)
+	}
+
+	tx, err := pg.Db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer rollbackIfTx(&tx)
+
+	query := spec.InsertReturningIdSQL()
+	stmt, err := tx.Prepare(query)
+	if err != nil {
+		return nil, &SQLError{query, err}
+	}
+	defer stmt.Close()
+
+	ids := make([]int64, len(rows))
+	for i, row := range rows {
+		prepared, err := spec.prepareRowOrReject(row, query)
+		if err != nil {
+			return nil, err
+		}
+		if prepared == nil {
+			continue
+		}
+		err = stmt.QueryRow(prepared...).Scan(&ids[i])
+		if err == sql.ErrNoRows && spec.Upsert {
+			// unchanged row, the update was skipped (see upsertChangedSQL)
+			continue
+		}
+		if err != nil {
+			spec.rows.failed()
+			return nil, &SQLInsertError{SQLError{query, err}, prepared}
+		}
+		spec.rows.inserted()
+	}
+	pg.closeBeforeCommit(stmt)
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	tx = nil
+	return ids, nil
+}
+
+// InsertReturningIdSQL returns the INSERT (or upsert) statement that
+// returns the generated id.
+func (spec *TableSpec) InsertReturningIdSQL() string {
+	sql := spec.InsertSQL()
+	if spec.Upsert {
+		sql = spec.UpsertSQL()
+	}
+	return sql + ` RETURNING "id"`
+}
+
+// prepareRowOrReject returns the prepared row for the insert with sql,
+// or nil for skipped and rejected rows. Rejected rows are only logged,
+// they are not inserted into the invalid table.
+func (spec *TableSpec) prepareRowOrReject(row []interface{}, sql string) ([]interface{}, error) {
+	prepare := spec.prepareRow
+	for retried := false; ; retried = true {
+		prepared, err := prepare(row)
+		if err == nil {
+			return prepared, nil
+		}
+		err = sridInsertError(err, sql, row)
+		if isSridMismatch(err) {
+			spec.rows.failed()
+			return nil, err
+		}
+		switch spec.rowErrorAction(row, err, retried) {
+		case database.RetryRow:
+			prepare = spec.convertRow
+			continue
+		case database.AbortImport:
+			spec.rows.failed()
+			return nil, &RowAbortedError{spec.Name, err}
+		default:
+			log.Warn(err)
+			spec.rows.failed()
+			return nil, nil
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package postgis
+
+import (
+	"database/sql/driver"
+	"reflect"
+	"testing"
+)
+
+func TestInsertReturningIdSQL(t *testing.T) {
+	spec := testTableSpec(t, testPostGIS(), testTable())
+	expected := `INSERT INTO "import"."osm_roads" ("osm_id", "geometry", "name", "tags") VALUES ($1, $2::Geometry, $3, $4) RETURNING "id"`
+	if sql := spec.InsertReturningIdSQL(); sql != expected {
+		t.Errorf("unexpected SQL\n%s\n%s", sql, expected)
+	}
+}
+
+func TestInsertBatchReturningIds(t *testing.T) {
+	line := ewkbLineString(3857, 0, 0, 1, 1).hex()
+	// polygon collapses before it reaches max_vertices and is rejected
+	polygon := ewkbPolygon(3857, circle(10, 10)).hex()
+
+	db, d := newFakeDb()
+	defer db.Close()
+	pg := testPostGIS()
+	pg.Db = db
+	table := testTable()
+	table.MaxVertices = 2
+	spec := testTableSpec(t, pg, table)
+	pg.Tables = map[string]*TableSpec{"roads": spec}
+
+	// ids are the osm_id * 10, to check the alignment with the rows
+	d.results = map[string]fakeResult{
+		"INSERT": {
+			columns: []string{"id"},
+			rowsFunc: func(args []driver.Value) [][]driver.Value {
+				return [][]driver.Value{{args[0].(int64) * 10}}
+			},
+		},
+	}
+	rows := [][]interface{}{
+		{int64(1), line, "Main", ""},
+		{int64(2), nil, "Null", ""},
+		{int64(3), polygon, "Rejected", ""},
+		{int64(4), line, "Side", ""},
+	}
+	ids, err := pg.InsertBatchReturningIds("roads", rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []int64{10, 0, 0, 40}; !reflect.DeepEqual(ids, expected) {
+		t.Error("unexpected ids", ids, expected)
+	}
+	if n := d.count("INSERT"); n != 2 {
+		t.Error("unexpected inserts", n)
+	}
+	if d.commits != 1 {
+		t.Error("unexpected commits", d.commits)
+	}
+	counts := pg.RowCounts()["roads"]
+	if counts.Inserted != 2 || counts.Failed != 1 || counts.SkippedNullGeometry != 1 {
+		t.Errorf("unexpected counts %+v", counts)
+	}
+
+	d.fail = "INSERT"
+	if ids, err := pg.InsertBatchReturningIds("roads", rows); err == nil || ids != nil {
+		t.Error("expected error", ids, err)
+	}
+	if d.commits != 1 || d.rollbacks != 1 {
+		t.Error("expected rollback", d.commits, d.rollbacks)
+	}
+}
+
+func TestInsertBatchReturningIdsTable(t *testing.T) {
+	pg := testPostGIS()
+	table := testTable()
+	// id field replaces the serial id
+	table.Fields[0].Name = "id"
+	subdivided := testTable()
+	subdivided.Name = "subdivided"
+	subdivided.Subdivide = 64
+	pg.Tables = map[string]*TableSpec{
+		"roads":      testTableSpec(t, pg, table),
+		"subdivided": testTableSpec(t, pg, subdivided),
+	}
+	for table, expected := range map[string]string{
+		"unknown":    "unknown table unknown",
+		"roads":      "table roads has no generated id column",
+		"subdivided": "table subdivided is subdivided and returns multiple ids for each row",
+	} {
+		if _, err := pg.InsertBatchReturningIds(table, nil); err == nil || err.Error() != expected {
+			t.Error("unexpected error", table, err)
+		}
+	}
+}
@@ -2,17 +2,120 @@ package postgis
 
 import (
 	"fmt"
+	"sort"
+	"time"
+
+	"github.com/omniscale/imposm3/database"
 )
 
-func (pg *PostGIS) rotate(source, dest, backup string) error {
-	defer log.StopStep(log.StartStep(fmt.Sprintf("Rotating tables")))
+// deployTable is a table (with prefix) and the schemas for Deploy.
+type deployTable struct {
+	name    string
+	schemas database.Schemas
+	// depth of the table in the inheritance (see inherits.go) or 1 for
+	// tables with a foreign key
+	depth int
+}
 
-	if err := pg.createSchema(dest); err != nil {
-		return err
+// rotateTable is a table that is moved from source to dest. An existing
+// table in dest is moved to backup.
+type rotateTable struct {
+	name   string
+	source string
+	dest   string
+	backup string
+}
+
+func (pg *PostGIS) defaultSchemas() database.Schemas {
+	return database.Schemas{
+		Import:     pg.Config.ImportSchema,
+		Production: pg.Config.ProductionSchema,
+		Backup:     pg.Config.BackupSchema,
 	}
+}
 
-	if err := pg.createSchema(backup); err != nil {
-		return err
+// deployTables returns all tables with their schemas, sorted by name with
+// child tables before their parent tables.
+func (pg *PostGIS) deployTables() []deployTable {
+	var tables []deployTable
+	for _, spec := range pg.Tables {
+		tables = append(tables, deployTable{spec.FullName, spec.schemas, spec.dependencyDepth()})
+		if spec.InvalidTable {
+			tables = append(tables, deployTable{spec.InvalidTableName(), spec.schemas, 0})
+		}
+		if spec.centroids {
+			tables = append(tables, deployTable{spec.CentroidTableName(), spec.schemas, 0})
+		}
+	}
+	for _, spec := range pg.GeneralizedTables {
+		schemas := pg.defaultSchemas()
+		if spec.Source != nil {
+			schemas = spec.Source.schemas
+		}
+		tables = append(tables, deployTable{spec.FullName, schemas, 0})
+	}
+	sort.Sort(deployTablesByName(tables))
+	return tables
+}
+
+type deployTablesByName []deployTable
+
+func (t deployTablesByName) Len() int { return len(t) }
+func (t deployTablesByName) Less(i, j int) bool {
+	if t[i].depth != t[j].depth {
+		// child tables first, parents can only be dropped after them
+		return t[i].depth > t[j].depth
+	}
+	return t[i].name < t[j].name
+}
+func (t deployTablesByName) Swap(i, j int) { t[i], t[j] = t[j], t[i] }
+
+// rotateTables returns all tables for Deploy, or for RevertDeploy if
+// revert is true.
+func (pg *PostGIS) rotateTables(revert bool) []rotateTable {
+	var tables []rotateTable
+	for _, t := range pg.deployTables() {
+		if revert {
+			tables = append(tables, rotateTable{t.name, t.schemas.Backup, t.schemas.Production, t.schemas.Import})
+		} else {
+			tables = append(tables, rotateTable{t.name, t.schemas.Import, t.schemas.Production, t.schemas.Backup})
+		}
+	}
+	return tables
+}
+
+// rotateSQL returns the statements to rotate the table, depending on
+// the tables that exist. It returns nil if the source table does not exist.
+func rotateSQL(t rotateTable, sourceExists, destExists, backupExists bool) []string {
+	if !sourceExists {
+		return nil
+	}
+	var stmts []string
+	if destExists {
+		if backupExists {
+			stmts = append(stmts, fmt.Sprintf(`SELECT DropGeometryTable('%s', '%s')`, t.backup, t.name))
+		}
+		stmts = append(stmts, fmt.Sprintf(`ALTER TABLE "%s"."%s" SET SCHEMA "%s"`, t.dest, t.name, t.backup))
+	}
+	stmts = append(stmts, fmt.Sprintf(`ALTER TABLE "%s"."%s" SET SCHEMA "%s"`, t.source, t.name, t.dest))
+	return stmts
+}
+
+// rotate moves all tables in a single transaction.
+func (pg *PostGIS) rotate(tables []rotateTable) error {
+	defer log.StopStep(log.StartStep("Rotating tables"))
+
+	created := make(map[string]bool)
+	for _, t := range tables {
+		for _, schema := range []string{t.dest, t.backup} {
+			if created[schema] {
+				continue
+			}
+			if err := pg.createSchema(schema); err != nil {
+				return err
+			}
+			created[schema] = true
+		}
 	}
 
 	tx, err := pg.Db.Begin()
@@ -21,49 +124,49 @@ func (pg *PostGIS) rotate(source, dest, backup string) error {
 	}
 	defer rollbackIfTx(&tx)
 
-	for _, tableName := range pg.tableNames() {
-		tableName = pg.Prefix + tableName
+	if pg.Config.DeployLockTimeout > 0 {
+		// SET LOCAL only applies to this transaction
+		sql := fmt.Sprintf("SET LOCAL lock_timeout = '%dms'", pg.Config.DeployLockTimeout/time.Millisecond)
+		if _, err := tx.Exec(sql); err != nil {
+			return &SQLError{sql, err}
+		}
+	}
 
-		log.Printf("Rotating %s from %s -> %s -> %s", tableName, source, dest, backup)
+	for _, t := range tables {
+		log.Printf("Rotating %s from %s -> %s -> %s", t.name, t.source, t.dest, t.backup)
 
-		backupExists, err := tableExists(tx, backup, tableName)
+		backupExists, err := tableExists(tx, t.backup, t.name)
 		if err != nil {
 			return err
 		}
-		sourceExists, err := tableExists(tx, source, tableName)
+		sourceExists, err := tableExists(tx, t.source, t.name)
 		if err != nil {
 			return err
 		}
-		destExists, err := tableExists(tx, dest, tableName)
+		destExists, err := tableExists(tx, t.dest, t.name)
 		if err != nil {
 			return err
 		}
 
 		if !sourceExists {
-			log.Warnf("skipping rotate of %s, table does not exists in %s", tableName, source)
+			log.Warnf("skipping rotate of %s, table does not exists in %s", t.name, t.source)
 			continue
 		}
-
 		if destExists {
-			log.Printf("backup of %s, to %s", tableName, backup)
-			if backupExists {
-				err = dropTableIfExists(tx, backup, tableName)
-				if err != nil {
-					return err
+			log.Printf("backup of %s, to %s", t.name, t.backup)
+		}
+		for _, sql := range rotateSQL(t, sourceExists, destExists, backupExists) {
+			if _, err := tx.Exec(sql); err != nil {
+				if isDependentObjectsStillExist(err) {
+					return &ReferencedTableError{t.backup, t.name, &SQLError{sql, err}}
+				}
+				err = &SQLError{sql, err}
+				if isLockNotAvailable(err) {
+					log.Warnf("rotating %s exceeded the lock timeout, all tables are unchanged and the rotate can be retried", t.name)
 				}
-			}
-			sql := fmt.Sprintf(`ALTER TABLE "%s"."%s" SET SCHEMA "%s"`, dest, tableName, backup)
-			_, err = tx.Exec(sql)
-			if err != nil {
 				return err
 			}
 		}
-
-		sql := fmt.Sprintf(`ALTER TABLE "%s"."%s" SET SCHEMA "%s"`, source, tableName, dest)
-		_, err = tx.Exec(sql)
-		if err != nil {
-			return err
-		}
 	}
 
 	err = tx.Commit()
@@ -75,11 +178,11 @@ func (pg *PostGIS) rotate(source, dest, backup string) error {
 }
 
 func (pg *PostGIS) Deploy() error {
-	return pg.rotate(pg.Config.ImportSchema, pg.Config.ProductionSchema, pg.Config.BackupSchema)
+	return pg.rotate(pg.rotateTables(false))
 }
 
 func (pg *PostGIS) RevertDeploy() error {
-	return pg.rotate(pg.Config.BackupSchema, pg.Config.ProductionSchema, pg.Config.ImportSchema)
+	return pg.rotate(pg.rotateTables(true))
 }
 
 func (pg *PostGIS) RemoveBackup() error {
@@ -89,18 +192,16 @@ func (pg *PostGIS) RemoveBackup() error {
 	}
 	defer rollbackIfTx(&tx)
 
-	backup := pg.Config.BackupSchema
-
-	for _, tableName := range pg.tableNames() {
-		tableName = pg.Prefix + tableName
+	for _, t := range pg.deployTables() {
+		backup := t.schemas.Backup
 
-		backupExists, err := tableExists(tx, backup, tableName)
+		backupExists, err := tableExists(tx, backup, t.name)
 		if err != nil {
 			return err
 		}
 		if backupExists {
-			log.Printf("removing backup of %s from %s", tableName, backup)
-			err = dropTableIfExists(tx, backup, tableName)
+			log.Printf("removing backup of %s from %s", t.name, backup)
+			err = dropTableIfExists(tx, backup, t.name)
 			if err != nil {
 				return err
 			}
@@ -115,15 +216,3 @@ func (pg *PostGIS) RemoveBackup() error {
 	tx = nil // set nil to prevent rollback
 	return nil
 }
-
-// tableNames returns a list of all tables (without prefix).
-func (pg *PostGIS) tableNames() []string {
-	var names []string
-	for name, _ := range pg.Tables {
-		names = append(names, name)
-	}
-	for name, _ := range pg.GeneralizedTables {
-		names = append(names, name)
-	}
-	return names
-}
@@ -0,0 +1,182 @@
+package postgis
+
+import (
+	"database/sql/driver"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/omniscale/imposm3/database"
+)
+
+func testMultiSchemaPostGIS(t *testing.T) *PostGIS {
+	pg := testPostGIS()
+	pg.Config.ProductionSchema = "public"
+	pg.Config.BackupSchema = "backup"
+	pg.Config.TableSchemas = map[string]database.Schemas{
+		"buildings": {Import: "import_buildings", Production: "buildings", Backup: "backup_buildings"},
+	}
+	roads := testTable()
+	buildings := testTable()
+	buildings.Name = "buildings"
+	buildings.Type = "polygon"
+	buildings.Schema = "buildings"
+	pg.Tables = map[string]*TableSpec{
+		"roads":     testTableSpec(t, pg, roads),
+		"buildings": testTableSpec(t, pg, buildings),
+	}
+	return pg
+}
+
+func TestTableSchema(t *testing.T) {
+	pg := testMultiSchemaPostGIS(t)
+	if schema := pg.Tables["buildings"].Schema; schema != "import_buildings" {
+		t.Error("unexpected schema", schema)
+	}
+	if schema := pg.Tables["roads"].Schema; schema != "import" {
+		t.Error("unexpected schema", schema)
+	}
+
+	table := testTable()
+	table.Schema = "unknown"
+	if _, err := NewTableSpec(pg, table); err == nil {
+		t.Error("expected error for unknown schema")
+	}
+}
+
+func TestRotateTables(t *testing.T) {
+	pg := testMultiSchemaPostGIS(t)
+
+	deploy := pg.rotateTables(false)
+	expected := []rotateTable{
+		{"osm_buildings", "import_buildings", "buildings", "backup_buildings"},
+		{"osm_roads", "import", "public", "backup"},
+	}
+	if !reflect.DeepEqual(deploy, expected) {
+		t.Error("unexpected deploy tables", deploy)
+	}
+
+	revert := pg.rotateTables(true)
+	expected = []rotateTable{
+		{"osm_buildings", "backup_buildings", "buildings", "import_buildings"},
+		{"osm_roads", "backup", "public", "import"},
+	}
+	if !reflect.DeepEqual(revert, expected) {
+		t.Error("unexpected revert tables", revert)
+	}
+}
+
+func TestRotateSQL(t *testing.T) {
+	table := rotateTable{"osm_buildings", "import_buildings", "buildings", "backup_buildings"}
+
+	if stmts := rotateSQL(table, false, true, true); stmts != nil {
+		t.Error("expected no statements for missing source", stmts)
+	}
+
+	stmts := rotateSQL(table, true, false, false)
+	expected := []string{
+		`ALTER TABLE "import_buildings"."osm_buildings" SET SCHEMA "buildings"`,
+	}
+	if !reflect.DeepEqual(stmts, expected) {
+		t.Error("unexpected statements", stmts)
+	}
+
+	stmts = rotateSQL(table, true, true, false)
+	expected = []string{
+		`ALTER TABLE "buildings"."osm_buildings" SET SCHEMA "backup_buildings"`,
+		`ALTER TABLE "import_buildings"."osm_buildings" SET SCHEMA "buildings"`,
+	}
+	if !reflect.DeepEqual(stmts, expected) {
+		t.Error("unexpected statements", stmts)
+	}
+
+	stmts = rotateSQL(table, true, true, true)
+	expected = []string{
+		`SELECT DropGeometryTable('backup_buildings', 'osm_buildings')`,
+		`ALTER TABLE "buildings"."osm_buildings" SET SCHEMA "backup_buildings"`,
+		`ALTER TABLE "import_buildings"."osm_buildings" SET SCHEMA "buildings"`,
+	}
+	if !reflect.DeepEqual(stmts, expected) {
+		t.Error("unexpected statements", stmts)
+	}
+}
+
+func TestRotateMultiSchemaSequence(t *testing.T) {
+	pg := testMultiSchemaPostGIS(t)
+	// production tables exist in both schemas, only the default schema
+	// has a backup
+	existing := map[string]bool{
+		"import_buildings.osm_buildings": true,
+		"buildings.osm_buildings":        true,
+		"import.osm_roads":               true,
+		"public.osm_roads":               true,
+		"backup.osm_roads":               true,
+	}
+	var stmts []string
+	for _, table := range pg.rotateTables(false) {
+		stmts = append(stmts, rotateSQL(table,
+			existing[table.source+"."+table.name],
+			existing[table.dest+"."+table.name],
+			existing[table.backup+"."+table.name],
+		)...)
+	}
+	expected := []string{
+		`ALTER TABLE "buildings"."osm_buildings" SET SCHEMA "backup_buildings"`,
+		`ALTER TABLE "import_buildings"."osm_buildings" SET SCHEMA "buildings"`,
+		`SELECT DropGeometryTable('backup', 'osm_roads')`,
+		`ALTER TABLE "public"."osm_roads" SET SCHEMA "backup"`,
+		`ALTER TABLE "import"."osm_roads" SET SCHEMA "public"`,
+	}
+	if !reflect.DeepEqual(stmts, expected) {
+		t.Errorf("unexpected statements\n%v\n%v", stmts, expected)
+	}
+}
+
+func TestDeployLockTimeout(t *testing.T) {
+	db, d := newFakeDb()
+	defer db.Close()
+	pg := testPostGIS()
+	pg.Db = db
+	pg.Config.ProductionSchema = "public"
+	pg.Config.BackupSchema = "backup"
+	pg.Config.DeployLockTimeout = 2 * time.Second
+	pg.Tables = map[string]*TableSpec{"roads": testTableSpec(t, pg, testTable())}
+	exists := fakeResult{columns: []string{"exists"}, rows: [][]driver.Value{{true}}}
+	d.results = map[string]fakeResult{
+		"SELECT EXISTS(SELECT schema_name":                      exists,
+		"SELECT EXISTS(SELECT * FROM information_schema.tables": exists,
+	}
+
+	if err := pg.Deploy(); err != nil {
+		t.Fatal(err)
+	}
+	// the lock_timeout is set before the first table is rotated
+	var stmts []string
+	for _, sql := range d.execs {
+		if !strings.HasPrefix(sql, "SELECT EXISTS") {
+			stmts = append(stmts, sql)
+		}
+	}
+	expected := []string{
+		`SET LOCAL lock_timeout = '2000ms'`,
+		`SELECT DropGeometryTable('backup', 'osm_roads')`,
+		`ALTER TABLE "public"."osm_roads" SET SCHEMA "backup"`,
+		`ALTER TABLE "import"."osm_roads" SET SCHEMA "public"`,
+	}
+	if !reflect.DeepEqual(stmts, expected) {
+		t.Errorf("unexpected statements\n%v\n%v", stmts, expected)
+	}
+	if d.commits != 1 {
+		t.Error("unexpected commits", d.commits)
+	}
+
+	d.execs = nil
+	pg.Config.DeployLockTimeout = 0
+	if err := pg.Deploy(); err != nil {
+		t.Fatal(err)
+	}
+	if n := d.count("SET"); n != 0 {
+		t.Error("unexpected SET lock_timeout", d.execs)
+	}
+}
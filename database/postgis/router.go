@@ -2,22 +2,50 @@ package postgis
 
 import (
 	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/omniscale/imposm3/database"
+	"github.com/omniscale/imposm3/mapping"
 )
 
 // TxRouter routes inserts/deletes to TableTx
 type TxRouter struct {
 	Tables map[string]TableTx
 	tx     *sql.Tx
+	pg     *PostGIS
+	// Flush of diff imports replaces tx and blocks all inserts/deletes
+	mu sync.RWMutex
+	// set by End and Abort
+	ended bool
+}
+
+// FlushError is returned by Flush with all tables that failed.
+type FlushError struct {
+	Tables []string
+	Err    error
+}
+
+func (e *FlushError) Error() string {
+	return fmt.Sprintf("flush failed for %s: %s", strings.Join(e.Tables, ", "), e.Err)
 }
 
 func newTxRouter(pg *PostGIS, bulkImport bool) (*TxRouter, error) {
 	txr := TxRouter{
 		Tables: make(map[string]TableTx),
+		pg:     pg,
 	}
 
 	if bulkImport {
 		for tableName, table := range pg.Tables {
-			tt := NewBulkTableTx(pg, table)
+			var tt TableTx
+			if table.Shards > 1 {
+				tt = newShardedTableTx(pg, table)
+			} else {
+				tt = NewBulkTableTx(pg, table)
+			}
 			err := tt.Begin(nil)
 			if err != nil {
 				return nil, err
@@ -52,15 +80,23 @@ func newTxRouter(pg *PostGIS, bulkImport bool) (*TxRouter, error) {
 }
 
 func (txr *TxRouter) End() error {
+	txr.ended = true
 	if txr.tx != nil {
 		for _, tt := range txr.Tables {
 			tt.End()
 		}
-		return txr.tx.Commit()
+		if err := txr.tx.Commit(); err != nil {
+			return geometryCheckError("COMMIT", err)
+		}
+		return nil
 	}
 
 	for _, tt := range txr.Tables {
 		if err := tt.Commit(); err != nil {
+			// rollback all tables that are not committed
+			for _, tt := range txr.Tables {
+				tt.Rollback()
+			}
 			return err
 		}
 	}
@@ -68,6 +104,7 @@ func (txr *TxRouter) End() error {
 }
 
 func (txr *TxRouter) Abort() error {
+	txr.ended = true
 	if txr.tx != nil {
 		for _, tt := range txr.Tables {
 			tt.End()
@@ -80,7 +117,72 @@ func (txr *TxRouter) Abort() error {
 	return nil
 }
 
+// Flush commits all rows that were inserted before the call. Bulk
+// imports flush all tables in parallel, each table commits its own
+// transaction. Diff imports commit the shared transaction and block
+// inserts and deletes till the new transaction is started.
+func (txr *TxRouter) Flush() error {
+	if txr.tx != nil {
+		return txr.flushTx()
+	}
+	type result struct {
+		table string
+		err   error
+	}
+	results := make(chan result, len(txr.Tables))
+	for name, tt := range txr.Tables {
+		go func(name string, tt database.Flusher) {
+			results <- result{name, tt.Flush()}
+		}(name, tt.(database.Flusher))
+	}
+	var flushErr *FlushError
+	for range txr.Tables {
+		r := <-results
+		if r.err == nil {
+			continue
+		}
+		if flushErr == nil {
+			flushErr = &FlushError{Err: r.err}
+		}
+		flushErr.Tables = append(flushErr.Tables, r.table)
+	}
+	if flushErr != nil {
+		sort.Strings(flushErr.Tables)
+		return flushErr
+	}
+	return nil
+}
+
+// flushTx commits the shared transaction of diff imports and begins a
+// new one for all tables.
+func (txr *TxRouter) flushTx() error {
+	txr.mu.Lock()
+	defer txr.mu.Unlock()
+	var tables []string
+	for name, tt := range txr.Tables {
+		tt.End()
+		tables = append(tables, name)
+	}
+	sort.Strings(tables)
+	if err := txr.tx.Commit(); err != nil {
+		return &FlushError{tables, geometryCheckError("COMMIT", err)}
+	}
+	tx, err := txr.pg.Db.Begin()
+	if err != nil {
+		return &FlushError{tables, err}
+	}
+	txr.tx = tx
+	for _, name := range tables {
+		if err := txr.Tables[name].Begin(tx); err != nil {
+			return &FlushError{[]string{name}, err}
+		}
+	}
+	return nil
+}
+
 func (txr *TxRouter) Insert(table string, row []interface{}) error {
+	txr.mu.RLock()
+	defer txr.mu.RUnlock()
 	tt, ok := txr.Tables[table]
 	if !ok {
 		panic("unknown table " + table)
@@ -89,9 +191,32 @@ func (txr *TxRouter) Insert(table string, row []interface{}) error {
 }
 
 func (txr *TxRouter) Delete(table string, id int64) error {
+	txr.mu.RLock()
+	defer txr.mu.RUnlock()
+	tt, ok := txr.Tables[table]
+	if !ok {
+		panic("unknown table " + table)
+	}
+	return tt.Delete(id)
+}
+
+// typeDeleter is implemented by TableTx that can delete the rows of a
+// single element type (see osm_type columns).
+type typeDeleter interface {
+	DeleteType(id int64, osmType mapping.OsmType) error
+}
+
+// DeleteType deletes the rows of the id and element type from the table.
+// It deletes all rows of the id if the table has no osm_type column.
+func (txr *TxRouter) DeleteType(table string, id int64, osmType mapping.OsmType) error {
+	txr.mu.RLock()
+	defer txr.mu.RUnlock()
 	tt, ok := txr.Tables[table]
 	if !ok {
 		panic("unknown table " + table)
 	}
+	if td, ok := tt.(typeDeleter); ok {
+		return td.DeleteType(id, osmType)
+	}
 	return tt.Delete(id)
 }
@@ -0,0 +1,28 @@
+package postgis
+
+import (
+	"fmt"
+
+	"github.com/omniscale/imposm3/database"
+)
+
+// RowAbortedError is returned if Config.OnRowError aborts the import.
+type RowAbortedError struct {
+	Table string
+	Err   error
+}
+
+func (e *RowAbortedError) Error() string {
+	return fmt.Sprintf("import aborted by rejected row of %s: %s", e.Table, e.Err)
+}
+
+// rowErrorAction returns the action of Config.OnRowError for the rejected
+// row. Rows are only retried once, RetryRow for a retried row skips the
+// row.
+func (spec *TableSpec) rowErrorAction(row []interface{}, err error, retried bool) database.ErrorAction {
+	action := spec.onRowError(spec.Name, row, err)
+	if action == database.RetryRow && retried {
+		return database.SkipRow
+	}
+	return action
+}
@@ -0,0 +1,96 @@
+package postgis
+
+import (
+	"testing"
+
+	"github.com/omniscale/imposm3/database"
+	"github.com/omniscale/imposm3/mapping"
+)
+
+func TestOnRowError(t *testing.T) {
+	line := ewkbLineString(3857, 0, 0, 1, 1).hex()
+	// polygon collapses before it reaches max_vertices
+	polygon := ewkbPolygon(3857, circle(10, 10)).hex()
+	for _, test := range []struct {
+		action   database.ErrorAction
+		calls    int
+		expected []string
+		failed   bool
+	}{
+		{database.SkipRow, 1, []string{"Main"}, false},
+		{database.RetryRow, 1, []string{"Main", "Side"}, false},
+		{database.AbortImport, 1, nil, true},
+	} {
+		db, d := newFakeDb()
+		pg := testPostGIS()
+		pg.Db = db
+		calls := 0
+		pg.Config.OnRowError = func(table string, row []interface{}, err error) database.ErrorAction {
+			calls += 1
+			if _, ok := err.(*VertexLimitError); !ok || table != "roads" {
+				t.Error("unexpected row error", table, err)
+			}
+			row[1] = line
+			return test.action
+		}
+		table := testTable()
+		table.MaxVertices = 2
+		// retried rows are not duplicates of themselves
+		table.Dedup = &mapping.Dedup{}
+		spec := testTableSpec(t, pg, table)
+		pg.Tables = map[string]*TableSpec{"roads": spec}
+
+		tt := NewBulkTableTx(pg, spec)
+		if err := tt.Begin(nil); err != nil {
+			t.Fatal(err)
+		}
+		tt.Insert([]interface{}{int64(1), line, "Main", ""})
+		tt.Insert([]interface{}{int64(2), polygon, "Side", ""})
+		err := tt.Commit()
+		if _, ok := err.(*RowAbortedError); ok != test.failed {
+			t.Error("unexpected commit error", test.action, err)
+		}
+		db.Close()
+
+		if calls != test.calls {
+			t.Error("unexpected calls", test.action, calls)
+		}
+		if test.failed {
+			continue
+		}
+		names := d.values("COPY", 2)
+		if len(names) != len(test.expected) {
+			t.Fatal("unexpected rows", test.action, names)
+		}
+		for i, name := range test.expected {
+			if names[i] != name {
+				t.Error("unexpected row", test.action, names)
+			}
+		}
+	}
+}
+
+func TestOnRowErrorRetriesOnce(t *testing.T) {
+	table := testTable()
+	table.MaxVertices = 2
+	pg := testPostGIS()
+	calls := 0
+	pg.Config.OnRowError = func(table string, row []interface{}, err error) database.ErrorAction {
+		calls += 1
+		return database.RetryRow
+	}
+	spec := testTableSpec(t, pg, table)
+	pg.Tables = map[string]*TableSpec{"roads": spec}
+	tt := &bulkTableTx{Pg: pg, Spec: spec}
+
+	tt.insert([]interface{}{int64(1), ewkbPolygon(3857, circle(10, 10)).hex(), "", ""})
+	if calls != 2 {
+		t.Error("unexpected calls", calls)
+	}
+	if tt.err != nil {
+		t.Error(tt.err)
+	}
+	if n := pg.RowCounts()["roads"].Failed; n != 1 {
+		t.Error("unexpected failed rows", n)
+	}
+}
@@ -0,0 +1,113 @@
+package postgis
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/omniscale/imposm3/mapping"
+)
+
+// Config.OnlyTables and Config.SkipTables limit an import to a subset of
+// the tables of the mapping. Skipped tables are removed from pg.Tables
+// and pg.GeneralizedTables, so they are not created, indexed or
+// deployed. Generalized tables are skipped with their source table.
+
+const (
+	// SkippedTableIgnore ignores rows for skipped tables.
+	SkippedTableIgnore = "ignore"
+	// SkippedTableError returns a *TableSkippedError for rows of skipped
+	// tables.
+	SkippedTableError = "error"
+)
+
+// TableSkippedError is returned for inserts into tables that are not
+// selected with OnlyTables/SkipTables, if SkippedTableInserts is error.
+type TableSkippedError struct {
+	Table string
+}
+
+func (e *TableSkippedError) Error() string {
+	return fmt.Sprintf("table %s is skipped in this import", e.Table)
+}
+
+// skippedTables returns the names of all tables and generalized tables of
+// the mapping that are not selected. It returns an error for table names
+// in OnlyTables and SkipTables that are not in the mapping.
+func (pg *PostGIS) skippedTables(m *mapping.Mapping) (map[string]bool, error) {
+	skipped := make(map[string]bool)
+	if len(pg.Config.OnlyTables) == 0 && len(pg.Config.SkipTables) == 0 {
+		return skipped, nil
+	}
+	switch pg.Config.SkippedTableInserts {
+	case "", SkippedTableIgnore, SkippedTableError:
+	default:
+		return nil, fmt.Errorf("unknown skipped table inserts policy '%s'", pg.Config.SkippedTableInserts)
+	}
+
+	var unknown []string
+	for _, names := range [][]string{pg.Config.OnlyTables, pg.Config.SkipTables} {
+		for _, name := range names {
+			if _, ok := m.Tables[name]; !ok {
+				unknown = append(unknown, name)
+			}
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("unknown tables in table selection: %s", strings.Join(unknown, ", "))
+	}
+
+	if len(pg.Config.OnlyTables) > 0 {
+		for name := range m.Tables {
+			skipped[name] = true
+		}
+		for _, name := range pg.Config.OnlyTables {
+			delete(skipped, name)
+		}
+	}
+	for _, name := range pg.Config.SkipTables {
+		skipped[name] = true
+	}
+
+	for name := range m.GeneralizedTables {
+		if source := generalizedTableSource(m, name); skipped[source] {
+			skipped[name] = true
+		}
+	}
+	return skipped, nil
+}
+
+// generalizedTableSource returns the name of the table that the
+// generalized table is derived from, following other generalized tables.
+func generalizedTableSource(m *mapping.Mapping, name string) string {
+	seen := make(map[string]bool)
+	for {
+		gen, ok := m.GeneralizedTables[name]
+		if !ok || seen[name] {
+			return name
+		}
+		seen[name] = true
+		name = gen.SourceTableName
+	}
+}
+
+// selectedMatches returns the matches of all tables that are not skipped.
+// It returns a *TableSkippedError for skipped tables, if
+// SkippedTableInserts is error.
+func (pg *PostGIS) selectedMatches(matches []mapping.Match) ([]mapping.Match, error) {
+	if len(pg.skipped) == 0 {
+		return matches, nil
+	}
+	selected := make([]mapping.Match, 0, len(matches))
+	for _, match := range matches {
+		if !pg.skipped[match.Table.Name] {
+			selected = append(selected, match)
+			continue
+		}
+		if pg.Config.SkippedTableInserts == SkippedTableError {
+			return nil, &TableSkippedError{match.Table.Name}
+		}
+	}
+	return selected, nil
+}
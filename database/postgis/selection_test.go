@@ -0,0 +1,96 @@
+package postgis
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/omniscale/imposm3/mapping"
+)
+
+func selectionMapping() *mapping.Mapping {
+	m := &mapping.Mapping{Tables: mapping.Tables{}, GeneralizedTables: mapping.GeneralizedTables{}}
+	for _, name := range []string{"roads", "buildings", "landuse"} {
+		table := testTable()
+		table.Name = name
+		m.Tables[name] = table
+	}
+	m.GeneralizedTables["roads_gen1"] = &mapping.GeneralizedTable{Name: "roads_gen1", SourceTableName: "roads"}
+	m.GeneralizedTables["roads_gen0"] = &mapping.GeneralizedTable{Name: "roads_gen0", SourceTableName: "roads_gen1"}
+	m.GeneralizedTables["landuse_gen0"] = &mapping.GeneralizedTable{Name: "landuse_gen0", SourceTableName: "landuse"}
+	return m
+}
+
+func preparedSelection(t *testing.T, only, skip []string) *PostGIS {
+	pg := testPostGIS()
+	pg.Config.OnlyTables = only
+	pg.Config.SkipTables = skip
+	pg.Tables = make(map[string]*TableSpec)
+	pg.GeneralizedTables = make(map[string]*GeneralizedTableSpec)
+	if err := pg.prepareTables(selectionMapping()); err != nil {
+		t.Fatal(err)
+	}
+	return pg
+}
+
+func TestOnlyTables(t *testing.T) {
+	pg := preparedSelection(t, []string{"roads"}, nil)
+	if len(pg.Tables) != 1 || pg.Tables["roads"] == nil {
+		t.Error("unexpected tables", pg.Tables)
+	}
+	if len(pg.GeneralizedTables) != 2 || pg.GeneralizedTables["roads_gen0"] == nil || pg.GeneralizedTables["roads_gen1"] == nil {
+		t.Error("unexpected generalized tables", pg.GeneralizedTables)
+	}
+	if tables := pg.deployTables(); len(tables) != 3 {
+		t.Error("unexpected deploy tables", tables)
+	}
+}
+
+func TestSkipTables(t *testing.T) {
+	pg := preparedSelection(t, nil, []string{"roads"})
+	if len(pg.Tables) != 2 || pg.Tables["roads"] != nil {
+		t.Error("unexpected tables", pg.Tables)
+	}
+	if len(pg.GeneralizedTables) != 1 || pg.GeneralizedTables["landuse_gen0"] == nil {
+		t.Error("unexpected generalized tables", pg.GeneralizedTables)
+	}
+
+	pg = preparedSelection(t, nil, nil)
+	if len(pg.Tables) != 3 || len(pg.GeneralizedTables) != 3 {
+		t.Error("unexpected tables", pg.Tables, pg.GeneralizedTables)
+	}
+}
+
+func TestTableSelectionErrors(t *testing.T) {
+	pg := testPostGIS()
+	pg.Config.OnlyTables = []string{"roads", "road"}
+	pg.Config.SkipTables = []string{"roads_gen0"}
+	_, err := pg.skippedTables(selectionMapping())
+	if err == nil || err.Error() != "unknown tables in table selection: road, roads_gen0" {
+		t.Error("unexpected error", err)
+	}
+
+	pg = testPostGIS()
+	pg.Config.OnlyTables = []string{"roads"}
+	pg.Config.SkippedTableInserts = "warn"
+	if _, err := pg.skippedTables(selectionMapping()); err == nil {
+		t.Error("expected error for unknown policy")
+	}
+}
+
+func TestSelectedMatches(t *testing.T) {
+	pg := preparedSelection(t, []string{"roads"}, nil)
+	matches := []mapping.Match{
+		{Table: mapping.DestTable{Name: "roads"}},
+		{Table: mapping.DestTable{Name: "landuse"}},
+	}
+	selected, err := pg.selectedMatches(matches)
+	if err != nil || len(selected) != 1 || selected[0].Table.Name != "roads" {
+		t.Error("unexpected matches", selected, err)
+	}
+
+	pg.Config.SkippedTableInserts = SkippedTableError
+	_, err = pg.selectedMatches(matches)
+	if _, ok := err.(*TableSkippedError); !ok || !strings.Contains(err.Error(), "landuse") {
+		t.Error("expected TableSkippedError", err)
+	}
+}
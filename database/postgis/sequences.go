@@ -0,0 +1,30 @@
+package postgis
+
+import (
+	"fmt"
+)
+
+// resetSequenceSQL sets the sequence of the serial id column to the next
+// value after the highest id in the table (1 for empty tables). Tables
+// with explicit ids (e.g. from a restore) would otherwise fail with
+// duplicate keys on the next insert. The sequence of a partitioned table
+// is the sequence of the parent table.
+func resetSequenceSQL(schema, table string) string {
+	return fmt.Sprintf(`SELECT setval(pg_get_serial_sequence('"%[1]s"."%[2]s"', 'id'), COALESCE(MAX("id"), 0) + 1, false) FROM "%[1]s"."%[2]s"`,
+		schema, table)
+}
+
+// resetSequences resets the sequences of all tables with a serial id
+// column. Tables with an id column from the mapping have no sequence.
+func (pg *PostGIS) resetSequences() error {
+	for _, spec := range pg.Tables {
+		if !spec.hasSerialId() {
+			continue
+		}
+		sql := resetSequenceSQL(spec.Schema, spec.FullName)
+		if _, err := pg.Db.Exec(sql); err != nil {
+			return &SQLError{sql, err}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package postgis
+
+import (
+	"testing"
+
+	"github.com/omniscale/imposm3/mapping"
+)
+
+func TestResetSequenceSQL(t *testing.T) {
+	sql := resetSequenceSQL("import", "osm_roads")
+	expected := `SELECT setval(pg_get_serial_sequence('"import"."osm_roads"', 'id'), COALESCE(MAX("id"), 0) + 1, false) FROM "import"."osm_roads"`
+	if sql != expected {
+		t.Errorf("unexpected SQL\n%s\n%s", sql, expected)
+	}
+}
+
+func TestResetSequences(t *testing.T) {
+	db, d := newFakeDb()
+	defer db.Close()
+	pg := testPostGIS()
+	pg.Db = db
+
+	// id column from the mapping, without sequence
+	buildings := testTable()
+	buildings.Name = "buildings"
+	buildings.Fields[0] = &mapping.Field{Name: "id", Type: "id"}
+	pg.Tables = map[string]*TableSpec{
+		"roads":     testTableSpec(t, pg, testTable()),
+		"buildings": testTableSpec(t, pg, buildings),
+	}
+
+	if err := pg.resetSequences(); err != nil {
+		t.Fatal(err)
+	}
+	if len(d.execs) != 1 || d.execs[0] != resetSequenceSQL("import", "osm_roads") {
+		t.Error("unexpected statements", d.execs)
+	}
+}
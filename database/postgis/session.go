@@ -0,0 +1,55 @@
+package postgis
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strings"
+)
+
+// Session settings (Config.DefaultTablespace and TempTablespaces) need to
+// be set for each connection of the pool, as database/sql opens new
+// connections when they are needed. The connector of the PostGIS runs
+// the SET statements for each new connection (see connector).
+
+// initSession runs the SET statements on the new connection.
+func initSession(conn driver.Conn, stmts []string) error {
+	for _, sql := range stmts {
+		if err := execConn(conn, sql); err != nil {
+			return &SQLError{sql, err}
+		}
+	}
+	return nil
+}
+
+// sessionSQL returns the SET statements for each connection.
+func (pg *PostGIS) sessionSQL() []string {
+	var stmts []string
+	if pg.Config.DefaultTablespace != "" {
+		stmts = append(stmts, fmt.Sprintf("SET default_tablespace = %s",
+			quoteLiteral(pg.Config.DefaultTablespace)))
+	}
+	if len(pg.Config.TempTablespaces) > 0 {
+		values := make([]string, len(pg.Config.TempTablespaces))
+		for i, ts := range pg.Config.TempTablespaces {
+			values[i] = quoteLiteral(ts)
+		}
+		stmts = append(stmts, fmt.Sprintf("SET temp_tablespaces = %s", strings.Join(values, ", ")))
+	}
+	return stmts
+}
+
+// execConn executes the statement without arguments on the driver
+// connection.
+func execConn(conn driver.Conn, sql string) error {
+	if execer, ok := conn.(driver.Execer); ok {
+		_, err := execer.Exec(sql, nil)
+		return err
+	}
+	stmt, err := conn.Prepare(sql)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	_, err = stmt.Exec(nil)
+	return err
+}
@@ -0,0 +1,57 @@
+package postgis
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestSessionSQL(t *testing.T) {
+	pg := testPostGIS()
+	if stmts := pg.sessionSQL(); len(stmts) != 0 {
+		t.Error("unexpected statements", stmts)
+	}
+
+	pg.Config.DefaultTablespace = "fast"
+	pg.Config.TempTablespaces = []string{"temp1", "temp'2"}
+	expected := []string{
+		`SET default_tablespace = 'fast'`,
+		`SET temp_tablespaces = 'temp1', 'temp''2'`,
+	}
+	if stmts := pg.sessionSQL(); !reflect.DeepEqual(stmts, expected) {
+		t.Errorf("unexpected statements\n%v\n%v", stmts, expected)
+	}
+}
+
+func TestConnectorSession(t *testing.T) {
+	_, d := newFakeDb()
+	stmts := []string{`SET default_tablespace = 'fast'`, `SET temp_tablespaces = 'temp1'`}
+
+	// statements run for each new connection of the connector, but not for
+	// connections of other connectors
+	for _, c := range []*connector{{d, stmts}, {d, nil}, {d, stmts}} {
+		if _, err := c.Connect(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expected := append(append([]string{}, stmts...), stmts...)
+	if !reflect.DeepEqual(d.execs, expected) {
+		t.Errorf("unexpected statements\n%v\n%v", d.execs, expected)
+	}
+
+	d.execs = nil
+	d.fail = "SET temp_tablespaces"
+	c := &connector{d, stmts}
+	if _, err := c.Connect(context.Background()); err == nil {
+		t.Error("expected error for failed SET")
+	}
+}
+
+func TestSessionSettingsPgBouncer(t *testing.T) {
+	pg := testPostGIS()
+	pg.Config.PgBouncer = true
+	pg.Config.DefaultTablespace = "fast"
+	if err := pg.Open(); err == nil {
+		t.Error("expected error with pgbouncer")
+	}
+}
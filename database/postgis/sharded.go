@@ -0,0 +1,244 @@
+package postgis
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/omniscale/imposm3/mapping"
+)
+
+// Tables with shards are bulk imported by multiple bulkTableTx, each with
+// its own connection, transaction and COPY. The rows are distributed
+// round-robin or by the hash of the OSM id, so the rows are not inserted
+// in the order of the import. This is safe for tables with the serial id
+// column, but tables that depend on the order of the inserts (upsert and
+// dedup, which also keep their state across all rows) can't be sharded.
+//
+// Each shard imports into its own unlogged staging table (see
+// stagingTableName). The table itself is only changed by Commit, which
+// truncates it and inserts the rows of all staging tables in a single
+// transaction. A failure of any shard or of this transaction leaves the
+// previous rows of the table intact, as for tables without shards.
+// Rejected rows are collected by the shards and inserted into the
+// invalid table in the same transaction. The staging tables are dropped
+// after Commit or Rollback.
+
+const (
+	ShardByRoundRobin = "round_robin"
+	ShardById         = "id"
+)
+
+// checkShards returns the problems of the shards options of the mapping.
+func checkShards(spec *TableSpec, t *mapping.Table) []string {
+	var problems []string
+	if t.Shards < 0 {
+		problems = append(problems, fmt.Sprintf("invalid shards %d", t.Shards))
+	}
+	switch t.ShardBy {
+	case "", ShardByRoundRobin:
+	case ShardById:
+		if spec.idColumnIndex() < 0 {
+			problems = append(problems, "shard_by id requires id column")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("unknown shard_by '%s'", t.ShardBy))
+	}
+	if t.Shards > 1 {
+		if spec.Upsert {
+			problems = append(problems, "shards not allowed with upsert")
+		}
+		if t.Dedup != nil {
+			problems = append(problems, "shards not allowed with dedup")
+		}
+	}
+	return problems
+}
+
+type shardedTableTx struct {
+	Pg     *PostGIS
+	Spec   *TableSpec
+	shards []*bulkTableTx
+	// number of inserted rows of all shards, for CopyProgress
+	inserted int64
+	// counter for round-robin
+	next uint64
+}
+
+// stagingTableName returns the name of the staging table of the shard.
+func stagingTableName(spec *TableSpec, shard int) string {
+	return fmt.Sprintf("%s_shard%d", spec.FullName, shard)
+}
+
+func newShardedTableTx(pg *PostGIS, spec *TableSpec) TableTx {
+	tt := &shardedTableTx{Pg: pg, Spec: spec}
+	for i := 0; i < spec.Shards; i++ {
+		// the staging spec shares the row counters and the other state
+		// of the spec, the staging tables are not analyzed
+		staging := *spec
+		staging.FullName = stagingTableName(spec, i)
+		staging.analyzer = nil
+		shard := NewBulkTableTx(pg, &staging).(*bulkTableTx)
+		shard.shard = true
+		shard.total = &tt.inserted
+		tt.shards = append(tt.shards, shard)
+	}
+	return tt
+}
+
+// Begin creates the staging tables and begins the transactions of all
+// shards. Shards always use their own transactions, tx needs to be nil.
+func (tt *shardedTableTx) Begin(tx *sql.Tx) error {
+	if tx != nil {
+		panic("unable to shard table in a shared transaction")
+	}
+	d := tt.Spec.dialect()
+	table := d.QualifiedName(tt.Spec.Schema, tt.Spec.FullName)
+	for _, shard := range tt.shards {
+		staging := d.QualifiedName(shard.Spec.Schema, shard.Spec.FullName)
+		for _, sql := range []string{
+			fmt.Sprintf(`DROP TABLE IF EXISTS %s`, staging),
+			fmt.Sprintf(`CREATE UNLOGGED TABLE %s (LIKE %s INCLUDING DEFAULTS)`, staging, table),
+		} {
+			if _, err := tt.Pg.Db.Exec(sql); err != nil {
+				tt.Rollback()
+				return &SQLError{sql, err}
+			}
+		}
+	}
+	for _, shard := range tt.shards {
+		if err := shard.Begin(nil); err != nil {
+			tt.Rollback()
+			return err
+		}
+	}
+	return nil
+}
+
+// dropStagingTables drops the staging tables of all shards.
+func (tt *shardedTableTx) dropStagingTables() {
+	d := tt.Spec.dialect()
+	for _, shard := range tt.shards {
+		sql := fmt.Sprintf(`DROP TABLE IF EXISTS %s`, d.QualifiedName(shard.Spec.Schema, shard.Spec.FullName))
+		if _, err := tt.Pg.Db.Exec(sql); err != nil {
+			log.Warn(&SQLError{sql, err})
+		}
+	}
+}
+
+// shard returns the index of the shard for the row.
+func (tt *shardedTableTx) shard(row []interface{}) int {
+	n := uint64(len(tt.shards))
+	if tt.Spec.ShardBy == ShardById {
+		if idx := tt.Spec.idColumnIndex(); idx >= 0 && idx < len(row) {
+			if id, ok := row[idx].(int64); ok {
+				return int(uint64(id) % n)
+			}
+		}
+	}
+	return int(atomic.AddUint64(&tt.next, 1) % n)
+}
+
+func (tt *shardedTableTx) Insert(row []interface{}) error {
+	return tt.shards[tt.shard(row)].Insert(row)
+}
+
+func (tt *shardedTableTx) Delete(id int64) error {
+	panic("unable to delete in bulkImport mode")
+}
+
+func (tt *shardedTableTx) End() {
+	for _, shard := range tt.shards {
+		shard.End()
+	}
+}
+
+// each calls f for all shards in parallel and returns the first error.
+func (tt *shardedTableTx) each(f func(*bulkTableTx) error) error {
+	errs := make([]error, len(tt.shards))
+	var wg sync.WaitGroup
+	for i, shard := range tt.shards {
+		wg.Add(1)
+		go func(i int, shard *bulkTableTx) {
+			errs[i] = f(shard)
+			wg.Done()
+		}(i, shard)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Commit commits the staging tables of all shards and replaces the rows
+// of the table with their rows in a single transaction.
+func (tt *shardedTableTx) Commit() error {
+	err := tt.each(func(shard *bulkTableTx) error {
+		return shard.Commit()
+	})
+	if err != nil {
+		tt.Rollback()
+		return err
+	}
+	if err := tt.insertStaged(); err != nil {
+		tt.Rollback()
+		return err
+	}
+	tt.dropStagingTables()
+	return nil
+}
+
+// insertStaged truncates the table and inserts the rows of all staging
+// tables and all rejected rows.
+func (tt *shardedTableTx) insertStaged() error {
+	tx, err := tt.Pg.Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer rollbackIfTx(&tx)
+
+	stmts := []string{fmt.Sprintf(`TRUNCATE TABLE %s RESTART IDENTITY`,
+		tt.Spec.dialect().QualifiedName(tt.Spec.Schema, tt.Spec.FullName))}
+	for _, shard := range tt.shards {
+		stmts = append(stmts, tt.Spec.insertFromSQL(shard.Spec))
+	}
+	for _, sql := range stmts {
+		if _, err := tx.Exec(sql); err != nil {
+			return geometryCheckError(sql, err)
+		}
+	}
+	var rejected []rejectedRow
+	for _, shard := range tt.shards {
+		rejected = append(rejected, shard.rejectedRows...)
+	}
+	if err := insertRejected(tx, tt.Spec, rejected); err != nil {
+		return err
+	}
+	err = tx.Commit()
+	tx = nil
+	if err != nil {
+		return geometryCheckError("COMMIT", err)
+	}
+	return nil
+}
+
+// Rollback rolls back all shards and drops the staging tables. The rows
+// of the table are not changed.
+func (tt *shardedTableTx) Rollback() {
+	for _, shard := range tt.shards {
+		shard.Rollback()
+	}
+	tt.dropStagingTables()
+}
+
+// Flush commits the rows of all shards into their staging tables. They
+// are inserted into the table with Commit.
+func (tt *shardedTableTx) Flush() error {
+	return tt.each(func(shard *bulkTableTx) error {
+		return shard.Flush()
+	})
+}
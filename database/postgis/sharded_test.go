@@ -0,0 +1,276 @@
+package postgis
+
+import (
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/omniscale/imposm3/database"
+	"github.com/omniscale/imposm3/mapping"
+)
+
+func TestCheckShards(t *testing.T) {
+	pg := testPostGIS()
+	for _, test := range []struct {
+		shards  int
+		shardBy string
+		upsert  bool
+		dedup   bool
+		problem string
+	}{
+		{4, "", false, false, ""},
+		{4, "id", false, false, ""},
+		{4, "hash", false, false, "unknown shard_by 'hash'"},
+		{-1, "", false, false, "invalid shards -1"},
+		{4, "", true, false, "shards not allowed with upsert"},
+		{4, "", false, true, "shards not allowed with dedup"},
+		{1, "", true, true, ""},
+	} {
+		table := testTable()
+		table.Shards = test.shards
+		table.ShardBy = test.shardBy
+		table.Upsert = test.upsert
+		if test.dedup {
+			table.Dedup = &mapping.Dedup{}
+		}
+		_, err := NewTableSpec(pg, table)
+		if test.problem == "" {
+			if err != nil {
+				t.Error("unexpected error", err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), test.problem) {
+			t.Errorf("expected %s, got %v", test.problem, err)
+		}
+	}
+}
+
+func TestShardById(t *testing.T) {
+	pg := testPostGIS()
+	table := testTable()
+	table.Shards = 3
+	table.ShardBy = ShardById
+	tt := newShardedTableTx(pg, testTableSpec(t, pg, table)).(*shardedTableTx)
+	defer tt.End()
+
+	for id, expected := range map[int64]int{0: 0, 1: 1, 5: 2, 9: 0} {
+		for i := 0; i < 2; i++ {
+			if shard := tt.shard([]interface{}{id, nil, "", ""}); shard != expected {
+				t.Errorf("unexpected shard %d for %d, expected %d", shard, id, expected)
+			}
+		}
+	}
+	tt.Spec.ShardBy = ShardByRoundRobin
+	if a, b := tt.shard([]interface{}{int64(1), nil, "", ""}), tt.shard([]interface{}{int64(1), nil, "", ""}); a == b {
+		t.Error("expected different shards for round-robin", a, b)
+	}
+}
+
+func testShardedPostGIS(t *testing.T) (*PostGIS, *fakeDriver) {
+	db, d := newFakeDb()
+	pg := testPostGIS()
+	pg.Db = db
+	table := testTable()
+	table.Shards = 3
+	pg.Tables = map[string]*TableSpec{"roads": testTableSpec(t, pg, table)}
+	return pg, d
+}
+
+func TestShardedBulkImport(t *testing.T) {
+	pg, d := testShardedPostGIS(t)
+	defer pg.Db.Close()
+	pg.Config.CopyProgressRows = 10
+	var mu sync.Mutex
+	var progress []int64
+	// called by all shards
+	pg.Config.CopyProgress = func(table string, rows int64) {
+		mu.Lock()
+		progress = append(progress, rows)
+		mu.Unlock()
+	}
+	if err := pg.BeginBulk(); err != nil {
+		t.Fatal(err)
+	}
+	line := ewkbLineString(3857, 0, 0, 1, 1).hex()
+	for i := 0; i < 30; i++ {
+		pg.txRouter.Insert("roads", []interface{}{int64(i), line, "", ""})
+	}
+	if err := pg.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if d.commits != 3 {
+		t.Error("unexpected commits", d.commits)
+	}
+	if err := pg.txRouter.End(); err != nil {
+		t.Fatal(err)
+	}
+	// 3 shards and the transaction of insertStaged
+	if d.commits != 7 || d.rollbacks != 0 {
+		t.Error("unexpected commits/rollbacks", d.commits, d.rollbacks)
+	}
+	if n := d.count(`CREATE UNLOGGED TABLE "import"."osm_roads_shard2" (LIKE "import"."osm_roads" INCLUDING DEFAULTS)`); n != 1 {
+		t.Error("unexpected CREATE of staging table", n)
+	}
+	if n := d.count(`COPY "import"."osm_roads_shard`); n != 36 { // 30 rows and end of COPY for each shard and flush
+		t.Error("unexpected COPY execs", n)
+	}
+	// truncated once, in the transaction that inserts the staged rows
+	if n := d.count(`TRUNCATE TABLE "import"."osm_roads" RESTART IDENTITY`); n != 1 {
+		t.Error("unexpected TRUNCATE", n)
+	}
+	if n := d.count(`INSERT INTO "import"."osm_roads" ("osm_id", "geometry", "name", "tags") SELECT "osm_id", "geometry", "name", "tags" FROM "import"."osm_roads_shard1"`); n != 1 {
+		t.Error("unexpected INSERT of staged rows", n)
+	}
+	// dropped before Begin and after Commit
+	if n := d.count(`DROP TABLE IF EXISTS "import"."osm_roads_shard0"`); n != 2 {
+		t.Error("unexpected DROP of staging table", n)
+	}
+	if n := pg.Tables["roads"].rows.load().Inserted; n != 30 {
+		t.Error("unexpected inserted rows", n)
+	}
+	sort.Sort(int64Slice(progress))
+	if !reflect.DeepEqual(progress, []int64{10, 20, 30}) {
+		t.Error("unexpected progress", progress)
+	}
+}
+
+// assertTableUnchanged checks that the rows of the table were not
+// changed and that the staging tables were dropped.
+func assertTableUnchanged(t *testing.T, d *fakeDriver, name string) {
+	if n := d.count(`TRUNCATE TABLE "import"."osm_roads"`); n != 0 {
+		t.Error(name, "unexpected TRUNCATE", n)
+	}
+	if n := d.count(`INSERT INTO "import"."osm_roads" `); n != 0 {
+		t.Error(name, "unexpected INSERT of staged rows", n)
+	}
+	assertStagingDropped(t, d, name)
+}
+
+func assertStagingDropped(t *testing.T, d *fakeDriver, name string) {
+	for _, last := range d.execs[len(d.execs)-3:] {
+		if !strings.HasPrefix(last, `DROP TABLE IF EXISTS "import"."osm_roads_shard`) {
+			t.Error(name, "expected DROP of staging tables", last)
+		}
+	}
+}
+
+func TestShardedBulkImportError(t *testing.T) {
+	pg, d := testShardedPostGIS(t)
+	defer pg.Db.Close()
+	cancel := make(chan struct{})
+	pg.Config.Cancel = cancel
+	if err := pg.BeginBulk(); err != nil {
+		t.Fatal(err)
+	}
+	close(cancel)
+	line := ewkbLineString(3857, 0, 0, 1, 1).hex()
+	// only the first shard fails
+	pg.txRouter.Insert("roads", []interface{}{int64(1), line, "", ""})
+
+	if err := pg.txRouter.End(); err != database.ErrCanceled {
+		t.Fatal("expected ErrCanceled", err)
+	}
+	assertTableUnchanged(t, d, "canceled")
+}
+
+func TestShardedCommitFailure(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		failCommit int
+		fail       string
+	}{
+		{"commit of a shard", 2, ""},
+		{"insert of staged rows", 0, `INSERT INTO "import"."osm_roads" (`},
+		{"commit of staged rows", 4, ""},
+	} {
+		pg, d := testShardedPostGIS(t)
+		d.failCommit = tc.failCommit
+		d.fail = tc.fail
+		if err := pg.BeginBulk(); err != nil {
+			t.Fatal(err)
+		}
+		line := ewkbLineString(3857, 0, 0, 1, 1).hex()
+		for i := 0; i < 6; i++ {
+			pg.txRouter.Insert("roads", []interface{}{int64(i), line, "", ""})
+		}
+		if err := pg.txRouter.End(); err == nil {
+			t.Error(tc.name, "expected error")
+		}
+		if tc.failCommit == 2 {
+			assertTableUnchanged(t, d, tc.name)
+		} else {
+			// the TRUNCATE is part of the failed transaction
+			assertStagingDropped(t, d, tc.name)
+		}
+		if tc.fail != "" && d.rollbacks != 1 {
+			t.Error(tc.name, "expected rollback of staged rows", d.rollbacks)
+		}
+		pg.Db.Close()
+	}
+}
+
+func TestShardedFlushFailure(t *testing.T) {
+	pg, d := testShardedPostGIS(t)
+	defer pg.Db.Close()
+	if err := pg.BeginBulk(); err != nil {
+		t.Fatal(err)
+	}
+	d.mu.Lock()
+	d.failCommit = 2
+	d.mu.Unlock()
+	if err := pg.Flush(); err == nil {
+		t.Fatal("expected error")
+	}
+	if err := pg.txRouter.Abort(); err != nil {
+		t.Fatal(err)
+	}
+	assertTableUnchanged(t, d, "flush")
+}
+
+// benchmarkShardedCopy imports rows into a table with the number of
+// shards. Requires a PostGIS database, e.g.:
+// IMPOSM3_BENCH_CONNECTION=postgis://localhost/imposm go test -bench Sharded
+func benchmarkShardedCopy(b *testing.B, shards int) {
+	conn := os.Getenv("IMPOSM3_BENCH_CONNECTION")
+	if conn == "" {
+		b.Skip("IMPOSM3_BENCH_CONNECTION not set")
+	}
+	table := testTable()
+	table.Name = "bench_sharded"
+	table.Shards = shards
+	db, err := New(database.Config{
+		ConnectionParams: conn,
+		Srid:             3857,
+		ImportSchema:     "import",
+		ProductionSchema: "public",
+		BackupSchema:     "backup",
+	}, &mapping.Mapping{Tables: mapping.Tables{table.Name: table}})
+	if err != nil {
+		b.Fatal(err)
+	}
+	pg := db.(*PostGIS)
+	defer pg.Close()
+	if err := pg.Init(); err != nil {
+		b.Fatal(err)
+	}
+
+	line := ewkbLineString(3857, 0, 0, 1, 1).hex()
+	b.ResetTimer()
+	if err := pg.BeginBulk(); err != nil {
+		b.Fatal(err)
+	}
+	for i := 0; i < b.N; i++ {
+		pg.txRouter.Insert(table.Name, []interface{}{int64(i), line, "name", ""})
+	}
+	if err := pg.End(); err != nil {
+		b.Fatal(err)
+	}
+}
+
+func BenchmarkShardedCopy1(b *testing.B) { benchmarkShardedCopy(b, 1) }
+func BenchmarkShardedCopy2(b *testing.B) { benchmarkShardedCopy(b, 2) }
+func BenchmarkShardedCopy4(b *testing.B) { benchmarkShardedCopy(b, 4) }
@@ -0,0 +1,290 @@
+package postgis
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+)
+
+var errSimplifyCollapsed = errors.New("geometry collapsed before reaching vertex limit")
+
+// simplifyToLimit simplifies g with Douglas-Peucker until it has no more
+// than maxVertices vertices. The tolerance starts small and is doubled
+// with each try. It returns errSimplifyCollapsed if the geometry collapsed
+// (i.e. polygon rings with less then four points) before it reached the
+// limit.
+func simplifyToLimit(g *wkbGeometry, maxVertices int) (*wkbGeometry, error) {
+	if g.numPoints() <= maxVertices {
+		return g, nil
+	}
+	minx, miny, maxx, maxy := g.bounds()
+	diag := math.Hypot(maxx-minx, maxy-miny)
+	if diag == 0 || math.IsInf(diag, 0) || math.IsNaN(diag) {
+		return nil, errSimplifyCollapsed
+	}
+	for tolerance := diag / 1e6; tolerance <= diag; tolerance *= 2 {
+		simplified := g.simplify(tolerance)
+		if simplified == nil {
+			break
+		}
+		if simplified.numPoints() <= maxVertices {
+			return simplified, nil
+		}
+	}
+	return nil, errSimplifyCollapsed
+}
+
+// simplify returns a simplified copy of the geometry. Holes and parts of
+// multi geometries that collapse are removed. It returns nil if the whole
+// geometry collapsed.
+func (g *wkbGeometry) simplify(tolerance float64) *wkbGeometry {
+	result := &wkbGeometry{geomType: g.geomType, hasZ: g.hasZ, hasM: g.hasM, srid: g.srid}
+	dims := g.dims()
+	switch g.geomType {
+	case wkbPoint:
+		result.rings = g.rings
+	case wkbLineString:
+		result.rings = [][]float64{simplifyLine(g.rings[0], dims, tolerance)}
+	case wkbPolygon:
+		for i, ring := range g.rings {
+			simplified := simplifyRing(ring, dims, tolerance)
+			if simplified == nil {
+				if i == 0 {
+					return nil // shell collapsed
+				}
+				continue // drop hole
+			}
+			result.rings = append(result.rings, simplified)
+		}
+	default:
+		for _, part := range g.parts {
+			if simplified := part.simplify(tolerance); simplified != nil {
+				result.parts = append(result.parts, simplified)
+			}
+		}
+		if len(result.parts) == 0 {
+			return nil
+		}
+	}
+	return result
+}
+
+// simplifyRing simplifies a closed ring. The ring is split at the vertex
+// farthest from the first vertex and both halves are simplified, so that
+// the ring stays closed. Returns nil if the result has less than four
+// vertices.
+func simplifyRing(coords []float64, dims int, tolerance float64) []float64 {
+	n := len(coords) / dims
+	if n < 4 {
+		return nil
+	}
+	split := 0
+	maxDist := -1.0
+	for i := 1; i < n-1; i++ {
+		d := math.Hypot(coords[i*dims]-coords[0], coords[i*dims+1]-coords[1])
+		if d > maxDist {
+			split, maxDist = i, d
+		}
+	}
+	first := simplifyLine(coords[:(split+1)*dims], dims, tolerance)
+	second := simplifyLine(coords[split*dims:], dims, tolerance)
+	result := make([]float64, 0, len(first)+len(second))
+	result = append(result, first...)
+	result = append(result, second[dims:]...)
+	if len(result)/dims < 4 {
+		return nil
+	}
+	return result
+}
+
+// simplifyLine simplifies the coordinates with the Douglas-Peucker algorithm.
+// The first and last vertex are always kept.
+func simplifyLine(coords []float64, dims int, tolerance float64) []float64 {
+	n := len(coords) / dims
+	if n <= 2 {
+		return coords
+	}
+	keep := make([]bool, n)
+	keep[0], keep[n-1] = true, true
+
+	// iterative to support lines with a huge number of vertices
+	stack := [][2]int{{0, n - 1}}
+	for len(stack) > 0 {
+		start, end := stack[len(stack)-1][0], stack[len(stack)-1][1]
+		stack = stack[:len(stack)-1]
+
+		idx := -1
+		maxDist := tolerance
+		for i := start + 1; i < end; i++ {
+			d := segmentDistance(
+				coords[i*dims], coords[i*dims+1],
+				coords[start*dims], coords[start*dims+1],
+				coords[end*dims], coords[end*dims+1],
+			)
+			if d > maxDist {
+				idx, maxDist = i, d
+			}
+		}
+		if idx >= 0 {
+			keep[idx] = true
+			stack = append(stack, [2]int{start, idx}, [2]int{idx, end})
+		}
+	}
+
+	result := make([]float64, 0, len(coords))
+	for i := 0; i < n; i++ {
+		if keep[i] {
+			result = append(result, coords[i*dims:(i+1)*dims]...)
+		}
+	}
+	return result
+}
+
+// segmentDistance returns the distance of point p to the segment a-b.
+func segmentDistance(px, py, ax, ay, bx, by float64) float64 {
+	dx, dy := bx-ax, by-ay
+	if dx == 0 && dy == 0 {
+		return math.Hypot(px-ax, py-ay)
+	}
+	t := ((px-ax)*dx + (py-ay)*dy) / (dx*dx + dy*dy)
+	if t < 0 {
+		t = 0
+	} else if t > 1 {
+		t = 1
+	}
+	return math.Hypot(px-(ax+t*dx), py-(ay+t*dy))
+}
+
+// VertexLimitError is returned for geometries that exceed the
+// max_vertices of a table and that could not be simplified.
+type VertexLimitError struct {
+	Table       string
+	Id          interface{}
+	Vertices    int
+	MaxVertices int
+}
+
+func (e *VertexLimitError) Error() string {
+	return fmt.Sprintf("geometry of %v in %s has %d vertices and could not be simplified to %d vertices",
+		e.Id, e.Table, e.Vertices, e.MaxVertices)
+}
+
+// VertexLimitReport contains the OSM IDs of all elements that exceeded
+// the max_vertices of a table.
+type VertexLimitReport struct {
+	// Simplified geometries were inserted with less vertices.
+	Simplified []int64
+	// Rejected geometries were not inserted.
+	Rejected []int64
+}
+
+type vertexLimitLog struct {
+	mu     sync.Mutex
+	report VertexLimitReport
+}
+
+func (l *vertexLimitLog) add(id interface{}, rejected bool) {
+	osmId, ok := id.(int64)
+	if !ok {
+		return
+	}
+	l.mu.Lock()
+	if rejected {
+		l.report.Rejected = append(l.report.Rejected, osmId)
+	} else {
+		l.report.Simplified = append(l.report.Simplified, osmId)
+	}
+	l.mu.Unlock()
+}
+
+// limitVertices simplifies the geometry of the row if it exceeds
+// spec.MaxVertices. It returns the row unchanged if no simplification is
+// required and a copy of the row with the simplified geometry otherwise.
+// Returns a *VertexLimitError if the geometry could not be simplified.
+func (spec *TableSpec) limitVertices(row []interface{}) ([]interface{}, error) {
+	if spec.MaxVertices <= 0 {
+		return row, nil
+	}
+	idx := spec.geometryColumnIndex()
+	if idx < 0 || idx >= len(row) {
+		return row, nil
+	}
+	wkb, err := hexWkb(row[idx])
+	if err != nil || wkb == nil {
+		return row, nil // let PostGIS report invalid geometries
+	}
+	n, err := wkbNumPoints(wkb)
+	if err != nil || n <= spec.MaxVertices {
+		return row, nil
+	}
+
+	var id interface{}
+	if idIdx := spec.idColumnIndex(); idIdx >= 0 && idIdx < len(row) {
+		id = row[idIdx]
+	}
+
+	g, err := decodeWkb(wkb)
+	if err == nil {
+		g, err = simplifyToLimit(g, spec.MaxVertices)
+	}
+	if err != nil {
+		if spec.vertexLimits != nil {
+			spec.vertexLimits.add(id, true)
+		}
+		return nil, &VertexLimitError{spec.FullName, id, n, spec.MaxVertices}
+	}
+	if spec.vertexLimits != nil {
+		spec.vertexLimits.add(id, false)
+	}
+	log.Warnf("simplified geometry of %v in %s from %d to %d vertices",
+		id, spec.FullName, n, g.numPoints())
+
+	simplified := make([]interface{}, len(row))
+	copy(simplified, row)
+	simplified[idx] = g.hexEwkb()
+	return simplified, nil
+}
+
+// VertexLimitReports returns the elements that exceeded the max_vertices
+// limit for each table with a limit.
+func (pg *PostGIS) VertexLimitReports() map[string]VertexLimitReport {
+	reports := make(map[string]VertexLimitReport)
+	for name, spec := range pg.Tables {
+		if spec.vertexLimits == nil {
+			continue
+		}
+		spec.vertexLimits.mu.Lock()
+		report := VertexLimitReport{
+			Simplified: append([]int64(nil), spec.vertexLimits.report.Simplified...),
+			Rejected:   append([]int64(nil), spec.vertexLimits.report.Rejected...),
+		}
+		spec.vertexLimits.mu.Unlock()
+		sort.Sort(int64Slice(report.Simplified))
+		sort.Sort(int64Slice(report.Rejected))
+		reports[name] = report
+	}
+	return reports
+}
+
+// logVertexLimitReports logs a summary of all elements that exceeded the
+// max_vertices limit.
+func (pg *PostGIS) logVertexLimitReports() {
+	for name, report := range pg.VertexLimitReports() {
+		if len(report.Simplified) > 0 {
+			log.Warnf("simplified %d geometries in %s to max_vertices: %v",
+				len(report.Simplified), name, report.Simplified)
+		}
+		if len(report.Rejected) > 0 {
+			log.Warnf("rejected %d geometries in %s exceeding max_vertices: %v",
+				len(report.Rejected), name, report.Rejected)
+		}
+	}
+}
+
+type int64Slice []int64
+
+func (s int64Slice) Len() int           { return len(s) }
+func (s int64Slice) Less(i, j int) bool { return s[i] < s[j] }
+func (s int64Slice) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
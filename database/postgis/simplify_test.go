@@ -0,0 +1,184 @@
+package postgis
+
+import (
+	"math"
+	"testing"
+)
+
+// circle returns a closed ring with n+1 coordinates.
+func circle(n int, r float64) []float64 {
+	coords := make([]float64, 0, (n+1)*2)
+	for i := 0; i < n; i++ {
+		a := 2 * math.Pi * float64(i) / float64(n)
+		coords = append(coords, r*math.Cos(a), r*math.Sin(a))
+	}
+	return append(coords, coords[0], coords[1])
+}
+
+func zigzag(n int) []float64 {
+	coords := make([]float64, 0, n*2)
+	for i := 0; i < n; i++ {
+		coords = append(coords, float64(i), float64(i%2)*0.001)
+	}
+	return coords
+}
+
+func TestSimplifyLine(t *testing.T) {
+	line := []float64{0, 0, 1, 0.1, 2, -0.1, 3, 5, 4, 6, 5, 7, 6, 8.1, 7, 9}
+	simplified := simplifyLine(line, 2, 0.5)
+	expected := []float64{0, 0, 2, -0.1, 3, 5, 7, 9}
+	if len(simplified) != len(expected) {
+		t.Fatal("unexpected result", simplified)
+	}
+	for i := range expected {
+		if simplified[i] != expected[i] {
+			t.Fatal("unexpected result", simplified)
+		}
+	}
+}
+
+func TestSimplifyLineKeepsEndpoints(t *testing.T) {
+	line := zigzag(1000)
+	simplified := simplifyLine(line, 2, 1)
+	if len(simplified) != 4 {
+		t.Fatal("unexpected result", simplified)
+	}
+	if simplified[0] != 0 || simplified[2] != 999 {
+		t.Error("endpoints not preserved", simplified)
+	}
+}
+
+func TestSimplifyRingPreservesClosure(t *testing.T) {
+	ring := circle(1000, 100)
+	for _, tolerance := range []float64{0.01, 1, 10, 30} {
+		simplified := simplifyRing(ring, 2, tolerance)
+		if simplified == nil {
+			t.Fatal("ring collapsed with tolerance", tolerance)
+		}
+		n := len(simplified)
+		if n/2 < 4 {
+			t.Fatal("ring with less then four points", simplified)
+		}
+		if n/2 >= 1001 {
+			t.Error("ring not simplified", tolerance, n/2)
+		}
+		if simplified[0] != simplified[n-2] || simplified[1] != simplified[n-1] {
+			t.Error("ring not closed", simplified)
+		}
+	}
+	// input must not be modified
+	if len(ring) != 2002 || ring[0] != 100 || ring[2000] != 100 {
+		t.Error("input ring modified")
+	}
+}
+
+func TestSimplifyRingCollapse(t *testing.T) {
+	if simplifyRing(circle(100, 1), 2, 10) != nil {
+		t.Error("ring did not collapse")
+	}
+	if simplifyRing([]float64{0, 0, 1, 1, 0, 0}, 2, 0) != nil {
+		t.Error("invalid ring not collapsed")
+	}
+}
+
+func TestSimplifyToLimitLineString(t *testing.T) {
+	g, err := decodeWkb(ewkbLineString(3857, zigzag(5000)...).Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	simplified, err := simplifyToLimit(g, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := simplified.numPoints(); n > 100 || n < 2 {
+		t.Error("unexpected number of points", n)
+	}
+	if simplified.srid != 3857 {
+		t.Error("srid not preserved", simplified.srid)
+	}
+}
+
+func TestSimplifyToLimitPolygon(t *testing.T) {
+	g, err := decodeWkb(ewkbPolygon(3857, circle(5000, 1000), circle(500, 10)).Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	simplified, err := simplifyToLimit(g, 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := simplified.numPoints(); n > 50 {
+		t.Error("unexpected number of points", n)
+	}
+	for _, ring := range simplified.rings {
+		n := len(ring)
+		if ring[0] != ring[n-2] || ring[1] != ring[n-1] {
+			t.Error("ring not closed")
+		}
+	}
+
+	// encode and decode again
+	wkb, err := hexWkb(simplified.hexEwkb())
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := decodeWkb(wkb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.numPoints() != simplified.numPoints() || decoded.srid != 3857 {
+		t.Error("unexpected decoded geometry", decoded)
+	}
+}
+
+func TestSimplifyToLimitCollapse(t *testing.T) {
+	g, err := decodeWkb(ewkbPolygon(3857, circle(5000, 1000)).Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := simplifyToLimit(g, 3); err != errSimplifyCollapsed {
+		t.Error("expected collapse", err)
+	}
+}
+
+func TestLimitVertices(t *testing.T) {
+	table := testTable()
+	table.MaxVertices = 100
+	spec := testTableSpec(t, testPostGIS(), table)
+
+	small := []interface{}{int64(1), ewkbLineString(3857, 0, 0, 1, 1).hex(), "", ""}
+	row, err := spec.limitVertices(small)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if row[1] != small[1] {
+		t.Error("small geometry modified")
+	}
+
+	large := []interface{}{int64(2), ewkbLineString(3857, zigzag(1000)...).hex(), "", ""}
+	row, err = spec.limitVertices(large)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if row[1] == large[1] {
+		t.Error("large geometry not simplified")
+	}
+	wkb, _ := hexWkb(row[1])
+	if n, _ := wkbNumPoints(wkb); n > 100 {
+		t.Error("too many points", n)
+	}
+
+	spec.MaxVertices = 1
+	_, err = spec.limitVertices([]interface{}{int64(3), ewkbLineString(3857, zigzag(10)...).hex(), "", ""})
+	if _, ok := err.(*VertexLimitError); !ok {
+		t.Error("expected VertexLimitError", err)
+	}
+
+	reports := spec.vertexLimits.report
+	if len(reports.Simplified) != 1 || reports.Simplified[0] != 2 {
+		t.Error("unexpected simplified ids", reports.Simplified)
+	}
+	if len(reports.Rejected) != 1 || reports.Rejected[0] != 3 {
+		t.Error("unexpected rejected ids", reports.Rejected)
+	}
+}
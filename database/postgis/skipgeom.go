@@ -0,0 +1,46 @@
+package postgis
+
+import (
+	"github.com/omniscale/imposm3/element"
+	"github.com/omniscale/imposm3/geom"
+	"github.com/omniscale/imposm3/mapping"
+)
+
+// With Config.SkipGeometry, all tables are created without geometry
+// column, for imports that only need the tags. Geometry columns of the
+// mapping are ignored and the tables are created like tables of type
+// none. The geometry values are removed from the rows of the mapping
+// before they are inserted (see dropGeometry). Generalized tables require
+// geometries and are skipped.
+
+// isGeometryField returns whether the field type is a geometry column.
+func isGeometryField(fieldType *mapping.FieldType) bool {
+	return fieldType.GoType == "geometry" || fieldType.GoType == "validated_geometry"
+}
+
+// dropGeometry removes the values of the skipped geometry columns from
+// a row of the mapping.
+func (spec *TableSpec) dropGeometry(row []interface{}) []interface{} {
+	if len(spec.skippedColumns) == 0 {
+		return row
+	}
+	result := make([]interface{}, 0, len(row))
+	skip := 0
+	for i, v := range row {
+		if skip < len(spec.skippedColumns) && spec.skippedColumns[skip] == i {
+			skip += 1
+			continue
+		}
+		result = append(result, v)
+	}
+	return result
+}
+
+// matchRow returns the row of the match for the table.
+func (pg *PostGIS) matchRow(match mapping.Match, elem *element.OSMElem, g *geom.Geometry) []interface{} {
+	row := match.Row(elem, g)
+	if spec, ok := pg.Tables[match.Table.Name]; ok {
+		return spec.dropGeometry(row)
+	}
+	return row
+}
@@ -0,0 +1,91 @@
+package postgis
+
+import (
+	"database/sql/driver"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/omniscale/imposm3/element"
+	"github.com/omniscale/imposm3/geom"
+	"github.com/omniscale/imposm3/mapping"
+)
+
+func TestSkipGeometrySQL(t *testing.T) {
+	pg := testPostGIS()
+	pg.Config.SkipGeometry = true
+	spec := testTableSpec(t, pg, testTable())
+
+	if spec.hasGeometry() || spec.geometryColumnIndex() != -1 {
+		t.Error("unexpected geometry column")
+	}
+	for _, sql := range []string{spec.CreateTableSQL(), spec.InsertSQL(), spec.CopySQL()} {
+		if strings.Contains(sql, "geometry") || strings.Contains(sql, "Geometry") || strings.Contains(sql, "ST_GeomFrom") {
+			t.Error("unexpected geometry in SQL", sql)
+		}
+	}
+	expected := `INSERT INTO "import"."osm_roads" ("osm_id", "name", "tags") VALUES ($1, $2, $3)`
+	if sql := spec.InsertSQL(); sql != expected {
+		t.Errorf("unexpected SQL\n%s\n%s", sql, expected)
+	}
+
+	row := spec.dropGeometry([]interface{}{int64(1), "0102000020110F0000", "A1", nil})
+	if !reflect.DeepEqual(row, []interface{}{int64(1), "A1", nil}) {
+		t.Error("unexpected row", row)
+	}
+}
+
+func TestSkipGeometryCreateTable(t *testing.T) {
+	db, d := newFakeDb()
+	defer db.Close()
+	pg := testPostGIS()
+	pg.Config.SkipGeometry = true
+	spec := testTableSpec(t, pg, testTable())
+	d.results = map[string]fakeResult{
+		"SELECT EXISTS(SELECT * FROM information_schema.tables": {
+			columns: []string{"exists"},
+			rows:    [][]driver.Value{{false}},
+		},
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := createTable(tx, *spec); err != nil {
+		t.Fatal(err)
+	}
+	if n := d.count("SELECT AddGeometryColumn"); n != 0 {
+		t.Error("unexpected AddGeometryColumn")
+	}
+	if n := d.count(spec.CreateTableSQL()); n != 1 {
+		t.Error("table not created")
+	}
+	tx.Rollback()
+}
+
+func TestSkipGeometryDefaultMapping(t *testing.T) {
+	pg := testPostGIS()
+	pg.Config.SkipGeometry = true
+	pg.Tables = make(map[string]*TableSpec)
+	pg.GeneralizedTables = make(map[string]*GeneralizedTableSpec)
+	m := mapping.Default()
+	if err := pg.prepareTables(m); err != nil {
+		t.Fatal(err)
+	}
+	if len(pg.GeneralizedTables) != 0 {
+		t.Error("generalized tables not skipped", pg.GeneralizedTables)
+	}
+
+	road := element.Way{OSMElem: element.OSMElem{Id: 1, Tags: element.Tags{"highway": "primary", "name": "Main Street"}}}
+	matches := m.LineStringMatcher().MatchWay(&road)
+	if len(matches) == 0 {
+		t.Fatal("no match for road")
+	}
+	for _, match := range matches {
+		row := pg.matchRow(match, &road.OSMElem, &geom.Geometry{})
+		if cols := pg.Tables[match.Table.Name].Columns; len(row) != len(cols) {
+			t.Error("row does not match columns", row, cols)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+package postgis
+
+import (
+	"fmt"
+)
+
+// Tables with SoftDelete keep deleted rows and mark them in the deleted
+// column. Generalized tables only contain rows that are not deleted and
+// their rows are still removed.
+
+const deletedColumn = "deleted"
+
+var softDeleteColumnSQL = fmt.Sprintf(`"%s" BOOLEAN NOT NULL DEFAULT false`, deletedColumn)
+
+// notDeletedSQL is the condition for all rows that are not deleted.
+var notDeletedSQL = fmt.Sprintf(`NOT "%s"`, deletedColumn)
+
+func softDeleteSQL(only, schema, table, idColumn string) string {
+	return fmt.Sprintf(`UPDATE %s"%s"."%s" SET "%s" = true WHERE "%s" = $1 AND %s`,
+		only, schema, table, deletedColumn, idColumn, notDeletedSQL)
+}
@@ -0,0 +1,54 @@
+package postgis
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/omniscale/imposm3/mapping"
+)
+
+func TestSoftDeleteSQL(t *testing.T) {
+	pg := testPostGIS()
+	pg.Config.SoftDelete = true
+	spec := testTableSpec(t, pg, testTable())
+
+	if sql := spec.DeleteSQL(); sql != `UPDATE "import"."osm_roads" SET "deleted" = true WHERE "osm_id" = $1 AND NOT "deleted"` {
+		t.Error("unexpected SQL", sql)
+	}
+	if sql := spec.CreateTableSQL(); !strings.Contains(sql, `"deleted" BOOLEAN NOT NULL DEFAULT false`) {
+		t.Error("missing deleted column", sql)
+	}
+
+	spec.Upsert = true
+	if sql := spec.UpsertSQL(); !strings.HasSuffix(sql, `, "deleted" = false`) {
+		t.Error("upsert does not reset deleted", sql)
+	}
+
+	gen := &GeneralizedTableSpec{Name: "roads_gen0", FullName: "osm_roads_gen0", Schema: "import", Source: spec, Where: "type = 'motorway'"}
+	if sql := gen.InsertSQL(); !strings.Contains(sql, `WHERE "osm_id" = $1 AND (type = 'motorway') AND NOT "deleted"`) {
+		t.Error("generalized table inserts deleted rows", sql)
+	}
+	if sql := gen.DeleteSQL(); !strings.HasPrefix(sql, "DELETE") {
+		t.Error("unexpected SQL for generalized table", sql)
+	}
+}
+
+func TestSoftDeleteDisabled(t *testing.T) {
+	spec := testTableSpec(t, testPostGIS(), testTable())
+	if sql := spec.DeleteSQL(); sql != `DELETE FROM "import"."osm_roads" WHERE "osm_id" = $1` {
+		t.Error("unexpected SQL", sql)
+	}
+	if sql := spec.CreateTableSQL(); strings.Contains(sql, "deleted") {
+		t.Error("unexpected deleted column", sql)
+	}
+}
+
+func TestSoftDeleteColumnConflict(t *testing.T) {
+	pg := testPostGIS()
+	pg.Config.SoftDelete = true
+	table := testTable()
+	table.Fields = append(table.Fields, &mapping.Field{Name: "deleted", Key: "deleted", Type: "bool"})
+	if _, err := NewTableSpec(pg, table); err == nil {
+		t.Error("expected error for deleted column")
+	}
+}
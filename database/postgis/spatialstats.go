@@ -0,0 +1,115 @@
+package postgis
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+)
+
+// SpatialStats are the extent and the number of NULL and empty geometries
+// of a table, to check the result of an import (e.g. that the table covers
+// the imported region and not just (0, 0)). Optimize logs the stats of all
+// tables with Config.SpatialStats. With Config.SpatialStatsSample, the
+// stats are calculated from a sample of about 1% of the table
+// (TABLESAMPLE SYSTEM, PostgreSQL 9.5) and the counts are estimates.
+type SpatialStats struct {
+	// MinX, MinY, MaxX, MaxY is the extent of all geometries. Empty is
+	// set for tables without geometries.
+	MinX, MinY, MaxX, MaxY float64
+	Empty                  bool
+	NullGeometries         int64
+	EmptyGeometries        int64
+	Sampled                bool
+}
+
+// sridBounds are the valid extents of common SRIDs. Extents of other SRIDs
+// are not checked.
+var sridBounds = map[int][4]float64{
+	4326: {-180, -90, 180, 90},
+	3857: {-20037508.342789244, -20048966.1040146, 20037508.342789244, 20048966.1040146},
+}
+
+// outOfBounds returns whether the extent exceeds the bounds of the SRID.
+func (s SpatialStats) outOfBounds(srid int) bool {
+	b, ok := sridBounds[srid]
+	if !ok || s.Empty {
+		return false
+	}
+	return s.MinX < b[0] || s.MinY < b[1] || s.MaxX > b[2] || s.MaxY > b[3]
+}
+
+func (spec *TableSpec) SpatialStatsSQL(sample bool) string {
+	geom := spec.Columns[spec.geometryColumnIndex()].Name
+	var tablesample string
+	if sample {
+		tablesample = " TABLESAMPLE SYSTEM (1)"
+	}
+	return fmt.Sprintf(`SELECT ST_XMin(ext), ST_YMin(ext), ST_XMax(ext), ST_YMax(ext), nulls, empty FROM (`+
+		`SELECT ST_Extent("%s") AS ext, count(*) - count("%s") AS nulls, count(CASE WHEN ST_IsEmpty("%s") THEN 1 END) AS empty `+
+		`FROM "%s"."%s"%s) AS stats`,
+		geom, geom, geom, spec.Schema, spec.FullName, tablesample)
+}
+
+// SpatialStats queries the stats of all tables with a geometry column (by
+// name).
+func (pg *PostGIS) SpatialStats() (map[string]SpatialStats, error) {
+	stats := make(map[string]SpatialStats)
+	for name, spec := range pg.Tables {
+		if !spec.hasGeometry() {
+			continue
+		}
+		s, err := pg.tableSpatialStats(spec)
+		if err != nil {
+			return nil, err
+		}
+		stats[name] = s
+	}
+	return stats, nil
+}
+
+func (pg *PostGIS) tableSpatialStats(spec *TableSpec) (SpatialStats, error) {
+	s := SpatialStats{Sampled: pg.Config.SpatialStatsSample}
+	// NULL for tables without geometries
+	var minx, miny, maxx, maxy sql.NullFloat64
+	sql := spec.SpatialStatsSQL(s.Sampled)
+	if err := pg.Db.QueryRow(sql).Scan(&minx, &miny, &maxx, &maxy,
+		&s.NullGeometries, &s.EmptyGeometries); err != nil {
+		return s, &SQLError{sql, err}
+	}
+	if !minx.Valid {
+		s.Empty = true
+		return s, nil
+	}
+	s.MinX, s.MinY, s.MaxX, s.MaxY = minx.Float64, miny.Float64, maxx.Float64, maxy.Float64
+	return s, nil
+}
+
+// logSpatialStats logs the stats of all tables and warns about extents
+// outside of the bounds of the SRID.
+func (pg *PostGIS) logSpatialStats() error {
+	defer log.StopStep(log.StartStep("Calculating spatial stats"))
+	stats, err := pg.SpatialStats()
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(stats))
+	for name := range stats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		s := stats[name]
+		spec := pg.Tables[name]
+		if s.Empty {
+			log.Printf("%s has no geometries, %d NULL", spec.FullName, s.NullGeometries)
+			continue
+		}
+		log.Printf("%s extent (%f %f, %f %f), %d NULL and %d empty geometries",
+			spec.FullName, s.MinX, s.MinY, s.MaxX, s.MaxY, s.NullGeometries, s.EmptyGeometries)
+		if s.outOfBounds(spec.Srid) {
+			log.Warnf("!!! extent of %s (%f %f, %f %f) is outside of the bounds of EPSG:%d !!!",
+				spec.FullName, s.MinX, s.MinY, s.MaxX, s.MaxY, spec.Srid)
+		}
+	}
+	return nil
+}
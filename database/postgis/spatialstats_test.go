@@ -0,0 +1,76 @@
+package postgis
+
+import (
+	"database/sql/driver"
+	"testing"
+)
+
+func TestSpatialStatsSQL(t *testing.T) {
+	spec := testTableSpec(t, testPostGIS(), testTable())
+
+	expected := `SELECT ST_XMin(ext), ST_YMin(ext), ST_XMax(ext), ST_YMax(ext), nulls, empty FROM (SELECT ST_Extent("geometry") AS ext, count(*) - count("geometry") AS nulls, count(CASE WHEN ST_IsEmpty("geometry") THEN 1 END) AS empty FROM "import"."osm_roads") AS stats`
+	if sql := spec.SpatialStatsSQL(false); sql != expected {
+		t.Errorf("unexpected SQL\n%s\n%s", sql, expected)
+	}
+	expected = `SELECT ST_XMin(ext), ST_YMin(ext), ST_XMax(ext), ST_YMax(ext), nulls, empty FROM (SELECT ST_Extent("geometry") AS ext, count(*) - count("geometry") AS nulls, count(CASE WHEN ST_IsEmpty("geometry") THEN 1 END) AS empty FROM "import"."osm_roads" TABLESAMPLE SYSTEM (1)) AS stats`
+	if sql := spec.SpatialStatsSQL(true); sql != expected {
+		t.Errorf("unexpected SQL\n%s\n%s", sql, expected)
+	}
+}
+
+func TestSpatialStats(t *testing.T) {
+	db, d := newFakeDb()
+	defer db.Close()
+	pg := testPostGIS()
+	pg.Db = db
+	pg.Tables = map[string]*TableSpec{"roads": testTableSpec(t, pg, testTable())}
+
+	d.results = map[string]fakeResult{
+		"SELECT ST_XMin": {
+			columns: []string{"st_xmin", "st_ymin", "st_xmax", "st_ymax", "nulls", "empty"},
+			rows:    [][]driver.Value{{float64(1000), float64(2000), float64(3000), float64(4000), int64(2), int64(1)}},
+		},
+	}
+	stats, err := pg.SpatialStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := stats["roads"]
+	if s != (SpatialStats{MinX: 1000, MinY: 2000, MaxX: 3000, MaxY: 4000, NullGeometries: 2, EmptyGeometries: 1}) {
+		t.Error("unexpected stats", s)
+	}
+	if s.outOfBounds(3857) {
+		t.Error("unexpected out of bounds")
+	}
+
+	d.results["SELECT ST_XMin"] = fakeResult{
+		columns: []string{"st_xmin", "st_ymin", "st_xmax", "st_ymax", "nulls", "empty"},
+		rows:    [][]driver.Value{{nil, nil, nil, nil, int64(3), int64(0)}},
+	}
+	stats, err = pg.SpatialStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := stats["roads"]; !s.Empty || s.NullGeometries != 3 {
+		t.Error("unexpected stats", s)
+	}
+}
+
+func TestSpatialStatsOutOfBounds(t *testing.T) {
+	for _, test := range []struct {
+		stats    SpatialStats
+		srid     int
+		expected bool
+	}{
+		{SpatialStats{MinX: 8, MinY: 53, MaxX: 9, MaxY: 54}, 4326, false},
+		{SpatialStats{MinX: 8, MinY: 53, MaxX: 900000, MaxY: 54}, 4326, true},
+		{SpatialStats{MinX: -2e7, MinY: -2e7, MaxX: 2e7, MaxY: 2e7}, 3857, false},
+		{SpatialStats{MinX: -3e7, MinY: 0, MaxX: 0, MaxY: 0}, 3857, true},
+		{SpatialStats{MinX: -3e7, MinY: 0, MaxX: 0, MaxY: 0}, 25832, false},
+		{SpatialStats{Empty: true}, 4326, false},
+	} {
+		if actual := test.stats.outOfBounds(test.srid); actual != test.expected {
+			t.Errorf("%v in %d: expected %v", test.stats, test.srid, test.expected)
+		}
+	}
+}
@@ -3,7 +3,9 @@ package postgis
 import (
 	"fmt"
 	"strings"
+	"time"
 
+	"github.com/omniscale/imposm3/database"
 	"github.com/omniscale/imposm3/mapping"
 )
 
@@ -11,6 +13,21 @@ type ColumnSpec struct {
 	Name      string
 	FieldType mapping.FieldType
 	Type      ColumnType
+	// OnUpdate is the expression for the column in the DO UPDATE SET
+	// list of UpsertSQL (EXCLUDED value if empty).
+	OnUpdate string
+	// normalize is only set for string columns
+	normalize *mapping.Normalize
+	// enum is only set for columns with enum
+	enum *enumSpec
+	// NotNull and Default are constraints of the column.
+	NotNull bool
+	Default string
+	// nullPolicy is only set for string columns
+	nullPolicy string
+	// Generated is the expression of a generated column (see
+	// generated.go).
+	Generated string
 }
 type TableSpec struct {
 	Name            string
@@ -20,6 +37,134 @@ type TableSpec struct {
 	GeometryType    string
 	Srid            int
 	Generalizations []*GeneralizedTableSpec
+	// InputSrid is the SRID of the inserted geometries. Geometries are
+	// transformed if it differs from Srid.
+	InputSrid int
+	// GeometryEncoding is empty, ewkb (see encodeEwkb) or wkt (see
+	// checkWkt).
+	GeometryEncoding string
+	// TransformPipeline is used for geometries that are transformed
+	// (see transformGeometry).
+	TransformPipeline string
+	// GridSize of the inserted geometries (see gridGeometrySQL, 0 to
+	// disable).
+	GridSize   float64
+	snapToGrid bool
+	// Subdivide is the max number of vertices of a geometry before it is
+	// split with ST_Subdivide (0 to disable).
+	Subdivide int
+	// MaxVertices is the max number of vertices of a geometry before it is
+	// simplified (0 to disable).
+	MaxVertices  int
+	vertexLimits *vertexLimitLog
+	// InsertDefaultId includes the serial id column as DEFAULT in InsertSQL.
+	InsertDefaultId bool
+	// InvalidTable enables the <table>_invalid table for rejected rows.
+	InvalidTable bool
+	dedup        *deduplicator
+	// SkipRowsMissingRequired skips rows with NULL in not_null columns
+	// without default (see missingRequired).
+	SkipRowsMissingRequired bool
+	// PreserveM adds the M dimension to the geometry column.
+	PreserveM bool
+	// GeometrySubtype of the geometry column (see subtypeGeometrySQL).
+	GeometrySubtype string
+	// GeometryMismatchPolicy for existing geometry columns, see
+	// addGeometryColumn.
+	GeometryMismatchPolicy string
+	// GeometryCheck adds a constraint that rejects invalid geometries
+	// (geometryCheckImmediate or geometryCheckDeferred).
+	GeometryCheck string
+	// AllowNullGeometry inserts rows without geometry with NULL,
+	// instead of skipping them.
+	AllowNullGeometry bool
+	// CheckSrid checks the embedded SRID of EWKB geometries of each row
+	// before the insert (see checkSrid).
+	CheckSrid bool
+	// DeferConstraintValidation adds the CHECK constraints in Finish.
+	DeferConstraintValidation bool
+	// IndexNameTemplate names the indexes of the table, see indexnames.go.
+	IndexNameTemplate string
+	// Upsert updates rows with the same OSM id instead of inserting
+	// another row (see UpsertSQL).
+	Upsert bool
+	// SoftDelete marks deleted rows in the deletedColumn (see DeleteSQL).
+	SoftDelete bool
+	// CopyBufferRows and CopyBufferBytes are the limits after which the
+	// COPY of a bulk import is flushed.
+	CopyBufferRows  int
+	CopyBufferBytes int
+	// AnalyzeEveryRows is the number of committed rows after which the
+	// table is analyzed during bulk imports (see analyzer, 0 to
+	// disable).
+	AnalyzeEveryRows int
+	analyzer         *analyzer
+	// LoadMethod and CopyThresholdRows select INSERT or COPY for bulk
+	// imports (see useCopy).
+	LoadMethod        string
+	CopyThresholdRows int
+	// TimestampColumn is the name of an additional column with the time
+	// of the insert. It is not part of Columns and rows.
+	TimestampColumn string
+	// LastModifiedColumn is the name of an additional column with the
+	// time of the last change of the row (see lastmodified.go).
+	LastModifiedColumn string
+	// ImportIDColumn is the name of an additional column with the
+	// ImportID of the run that inserted the row (see importid.go).
+	ImportIDColumn string
+	ImportID       string
+	// GenerationColumn is the name of an additional column with the
+	// generation of the row, for mark and sweep (see SetGeneration).
+	GenerationColumn string
+	// Reindex rebuilds the indexes in Optimize instead of clustering the
+	// table.
+	Reindex bool
+	// Shards is the number of connections for the bulk import of the
+	// table (see shardedTableTx). ShardBy is ShardByRoundRobin or
+	// ShardById.
+	Shards  int
+	ShardBy string
+	// indexes of the values in the rows of the mapping that are not
+	// inserted, for Config.SkipGeometry and generated columns
+	skippedColumns []int
+	// GeneratedColumns are computed by PostgreSQL (see generated.go).
+	// They are not part of Columns and rows.
+	GeneratedColumns []ColumnSpec
+	// TileIndex adds a generated column (see TileIndexSQL). It is not
+	// part of Columns and rows.
+	TileIndex *mapping.TileIndex
+	// Indexes are the additional indexes of the mapping.
+	Indexes []IndexSpec
+	// IdIndex is the method of the OSM id index (idIndexBtree or
+	// idIndexBrin).
+	IdIndex string
+	// Inherits is the parent table (see prepareInheritance).
+	Inherits *TableSpec
+	// name of the parent table in the mapping
+	inherits string
+	// hasChildren is set if other tables inherit from this table
+	hasChildren bool
+	// ForeignKey references the parent table (see prepareForeignKeys).
+	ForeignKey *ForeignKey
+	// foreign key of the mapping
+	foreignKey *mapping.ForeignKey
+	// referencedBy are the tables with a ForeignKey to this table
+	referencedBy []*TableSpec
+	// centroids is set after CreateCentroidTable, to deploy the table
+	centroids bool
+	// schemas for Deploy, Schema is schemas.Import
+	schemas database.Schemas
+	rows    *rowCounter
+	// autoSrid is set if the SRID is detected from the data
+	autoSrid *sridDetector
+	// nullValueFields are the fields with null_values, for NulledValues
+	nullValueFields []*mapping.Field
+	onRowError      database.RowErrorFunc
+	// Dialect of the statements of the table (PostgreSQL if nil).
+	Dialect Dialect
+	// OnCommitDrop drops temporary tables at the end of the transaction
+	// instead of the end of the session (see isTemp).
+	OnCommitDrop bool
 }
 
 type GeneralizedTableSpec struct {
@@ -36,74 +181,417 @@ type GeneralizedTableSpec struct {
 }
 
 func (col *ColumnSpec) AsSQL() string {
-	return fmt.Sprintf("\"%s\" %s", col.Name, col.Type.Name())
+	return col.columnSQL(PostgreSQL)
 }
 
-func (spec *TableSpec) CreateTableSQL() string {
-	foundIdCol := false
+func (col *ColumnSpec) columnSQL(d Dialect) string {
+	if col.Generated != "" {
+		return fmt.Sprintf("%s %s GENERATED ALWAYS AS (%s) STORED", d.QuoteIdent(col.Name), col.Type.Name(), col.Generated)
+	}
+	return fmt.Sprintf("%s %s", d.QuoteIdent(col.Name), col.Type.Name())
+}
+
+// constraintSQL returns the column with NOT NULL and DEFAULT.
+func (col *ColumnSpec) constraintSQL(d Dialect) string {
+	sql := col.columnSQL(d)
+	if col.NotNull {
+		sql += " NOT NULL"
+	}
+	if col.Default != "" {
+		sql += " DEFAULT " + col.Default
+	}
+	return sql
+}
+
+// coalesceDefault returns true if NULL values need to be replaced by
+// the default of the column. NULL values don't use the default.
+func (col *ColumnSpec) coalesceDefault() bool {
+	return col.NotNull && col.Default != ""
+}
+
+// insertSQL wraps the placeholder of the column with COALESCE, if
+// required.
+func (col *ColumnSpec) insertSQL(placeholder string) string {
+	if col.coalesceDefault() {
+		return fmt.Sprintf("COALESCE(%s, %s)", placeholder, col.Default)
+	}
+	return placeholder
+}
+
+// hasSerialId returns whether the table has an implicit serial id column.
+func (spec *TableSpec) hasSerialId() bool {
 	for _, cs := range spec.Columns {
 		if cs.Name == "id" {
-			foundIdCol = true
+			return false
 		}
 	}
+	return true
+}
 
+func (spec *TableSpec) CreateTableSQL() string {
+	d := spec.dialect()
 	cols := []string{}
-	if !foundIdCol {
+	if spec.hasSerialId() {
 		// only add id column if there is no id configured
 		// TODO allow to disable id column?
-		cols = append(cols, "id SERIAL PRIMARY KEY")
+		cols = append(cols, d.SerialPrimaryKey("id"))
 	}
 
 	for _, col := range spec.Columns {
 		if col.Type.Name() == "GEOMETRY" {
+			if spec.isTemp() {
+				cols = append(cols, fmt.Sprintf("%s Geometry(%s, %d)",
+					d.QuoteIdent(col.Name), spec.geometryColumnType(), spec.Srid))
+			}
 			continue
 		}
-		cols = append(cols, col.AsSQL())
+		cols = append(cols, col.constraintSQL(d))
+	}
+	if spec.TimestampColumn != "" {
+		cols = append(cols, d.QuoteIdent(spec.TimestampColumn)+" TIMESTAMP WITH TIME ZONE DEFAULT now()")
+	}
+	if spec.LastModifiedColumn != "" {
+		cols = append(cols, d.QuoteIdent(spec.LastModifiedColumn)+" TIMESTAMP WITH TIME ZONE DEFAULT now()")
+	}
+	if spec.GenerationColumn != "" {
+		cols = append(cols, d.QuoteIdent(spec.GenerationColumn)+" BIGINT")
+	}
+	if spec.ImportIDColumn != "" {
+		cols = append(cols, d.QuoteIdent(spec.ImportIDColumn)+" UUID")
+	}
+	if spec.SoftDelete {
+		cols = append(cols, softDeleteColumnSQL)
+	}
+	if spec.Upsert || len(spec.referencedBy) > 0 {
+		// required for ON CONFLICT and foreign keys
+		cols = append(cols, fmt.Sprintf(`UNIQUE (%s)`, d.QuoteIdent(spec.Columns[spec.idColumnIndex()].Name)))
 	}
 	columnSQL := strings.Join(cols, ",\n")
+	inherits := ""
+	if spec.Inherits != nil {
+		inherits = fmt.Sprintf(` INHERITS (%s.%s)`, d.QuoteIdent(spec.Inherits.Schema), d.QuoteIdent(spec.Inherits.FullName))
+	}
+	if spec.isTemp() {
+		onCommit := ""
+		if spec.OnCommitDrop {
+			onCommit = " ON COMMIT DROP"
+		}
+		return fmt.Sprintf(`
+        CREATE TEMP TABLE IF NOT EXISTS %s (
+            %s
+        )%s%s;`,
+			d.QualifiedName(spec.Schema, spec.FullName),
+			columnSQL,
+			inherits,
+			onCommit,
+		)
+	}
 	return fmt.Sprintf(`
-        CREATE TABLE IF NOT EXISTS "%s"."%s" (
+        CREATE TABLE IF NOT EXISTS %s.%s (
             %s
-        );`,
-		spec.Schema,
-		spec.FullName,
+        )%s;`,
+		d.QuoteIdent(spec.Schema),
+		d.QuoteIdent(spec.FullName),
 		columnSQL,
+		inherits,
 	)
 }
 
+// isTemp returns whether the table is a temporary table in the pg_temp
+// schema, i.e. the staging table of LoadMethodStaging (see stagingSpec).
+// Tables of the mapping can't be temporary, as the pooled connections of
+// the import do not see the temporary tables of each other. Temporary
+// tables are created with CREATE
+// TEMP TABLE and their geometry column is part of the CREATE statement,
+// as AddGeometryColumn requires the real schema of the table. PostgreSQL
+// drops them at the end of the session, or at the end of the transaction
+// with OnCommitDrop.
+func (spec *TableSpec) isTemp() bool {
+	return isTempSchema(spec.Schema)
+}
+
+func isTempSchema(schema string) bool {
+	return strings.HasPrefix(schema, "pg_temp")
+}
+
 func (spec *TableSpec) InsertSQL() string {
+	d := spec.dialect()
 	var cols []string
 	var vars []string
-	for _, col := range spec.Columns {
-		cols = append(cols, "\""+col.Name+"\"")
+	if spec.InsertDefaultId && spec.hasSerialId() {
+		cols = append(cols, d.QuoteIdent("id"))
+		vars = append(vars, "DEFAULT")
+	}
+	for i, col := range spec.Columns {
+		cols = append(cols, d.QuoteIdent(col.Name))
 		vars = append(vars,
-			col.Type.PrepareInsertSql(len(vars)+1, spec))
+			col.insertSQL(col.Type.PrepareInsertSql(i+1, spec)))
+	}
+	if spec.ImportIDColumn != "" {
+		cols = append(cols, d.QuoteIdent(spec.ImportIDColumn))
+		vars = append(vars, spec.importIDSQL())
 	}
 	columns := strings.Join(cols, ", ")
 	placeholders := strings.Join(vars, ", ")
 
-	return fmt.Sprintf(`INSERT INTO "%s"."%s" (%s) VALUES (%s)`,
-		spec.Schema,
-		spec.FullName,
+	return fmt.Sprintf(`INSERT INTO %s (%s) VALUES (%s)`,
+		d.QualifiedName(spec.Schema, spec.FullName),
 		columns,
 		placeholders,
 	)
 }
 
-func (spec *TableSpec) CopySQL() string {
+// qualifiedTableName returns the quoted table name with the schema.
+// Temporary tables (pg_temp schema) and tables without schema are
+// returned unqualified, temporary tables are found by the search_path.
+func qualifiedTableName(schema, table string) string {
+	if schema == "" || isTempSchema(schema) {
+		return fmt.Sprintf(`"%s"`, table)
+	}
+	return fmt.Sprintf(`"%s"."%s"`, schema, table)
+}
+
+// UpsertSQL returns an INSERT statement that updates the existing row
+// with the same OSM id. Columns are set to the inserted value (EXCLUDED),
+// or to their OnUpdate expression. The expression can refer to the
+// existing row with the table name, e.g.
+// GREATEST(osm_roads.version, EXCLUDED.version). Rows of tables with a
+// geometry_hash column are only updated if they changed (see
+// upsertChangedSQL).
+func (spec *TableSpec) UpsertSQL() string {
+	idIdx := spec.idColumnIndex()
+	var sets []string
+	for i, col := range spec.Columns {
+		if i == idIdx {
+			continue
+		}
+		sets = append(sets, col.updateSQL())
+	}
+	if spec.TimestampColumn != "" {
+		sets = append(sets, fmt.Sprintf(`"%s" = DEFAULT`, spec.TimestampColumn))
+	}
+	if spec.LastModifiedColumn != "" {
+		sets = append(sets, spec.lastModifiedUpdateSQL())
+	}
+	if spec.GenerationColumn != "" {
+		sets = append(sets, fmt.Sprintf(`"%s" = DEFAULT`, spec.GenerationColumn))
+	}
+	if spec.ImportIDColumn != "" {
+		sets = append(sets, fmt.Sprintf(`"%s" = EXCLUDED."%s"`, spec.ImportIDColumn, spec.ImportIDColumn))
+	}
+	if spec.SoftDelete {
+		sets = append(sets, fmt.Sprintf(`"%s" = false`, deletedColumn))
+	}
+	return fmt.Sprintf(`%s ON CONFLICT ("%s") DO UPDATE SET %s%s`,
+		spec.InsertSQL(),
+		spec.Columns[idIdx].Name,
+		strings.Join(sets, ", "),
+		spec.upsertChangedSQL(),
+	)
+}
+
+func (col *ColumnSpec) updateSQL() string {
+	if col.OnUpdate != "" {
+		return fmt.Sprintf(`"%s" = %s`, col.Name, col.OnUpdate)
+	}
+	return fmt.Sprintf(`"%s" = EXCLUDED."%s"`, col.Name, col.Name)
+}
+
+// SubdivideInsertSQL returns an INSERT statement that splits the geometry
+// with ST_Subdivide into parts with at most spec.Subdivide vertices. Each
+// part is inserted as a separate row with the same attribute values.
+func (spec *TableSpec) SubdivideInsertSQL() string {
+	geomIdx := spec.geometryColumnIndex()
 	var cols []string
-	for _, col := range spec.Columns {
+	var vars []string
+	var geomVar string
+	for i, col := range spec.Columns {
 		cols = append(cols, "\""+col.Name+"\"")
+		if i == geomIdx {
+			geomVar = col.Type.PrepareInsertSql(i+1, spec)
+			vars = append(vars, "subdivided")
+		} else {
+			// explicit cast, types of parameters in the SELECT list
+			// are not derived from the target columns
+			vars = append(vars, col.insertSQL(fmt.Sprintf("$%d::%s", i+1, col.Type.Name())))
+		}
+	}
+	if spec.ImportIDColumn != "" {
+		cols = append(cols, "\""+spec.ImportIDColumn+"\"")
+		vars = append(vars, spec.importIDSQL())
 	}
 	columns := strings.Join(cols, ", ")
+	placeholders := strings.Join(vars, ", ")
 
-	return fmt.Sprintf(`COPY "%s"."%s" (%s) FROM STDIN`,
+	return fmt.Sprintf(`INSERT INTO "%s"."%s" (%s) SELECT %s FROM ST_Subdivide(%s, %d) AS subdivided`,
 		spec.Schema,
 		spec.FullName,
 		columns,
+		placeholders,
+		geomVar,
+		spec.Subdivide,
 	)
 }
 
+// hasGeometry returns false for attribute-only tables (type none).
+func (spec *TableSpec) hasGeometry() bool {
+	return spec.GeometryType != string(mapping.NoneTable)
+}
+
+// geometryColumnIndex returns the index of the first geometry column or -1.
+func (spec *TableSpec) geometryColumnIndex() int {
+	for i, col := range spec.Columns {
+		if col.Type.Name() == "GEOMETRY" {
+			return i
+		}
+	}
+	return -1
+}
+
+// idColumnIndex returns the index of the OSM id column or -1.
+func (spec *TableSpec) idColumnIndex() int {
+	for i, col := range spec.Columns {
+		if col.FieldType.Name == "id" {
+			return i
+		}
+	}
+	return -1
+}
+
+// notNullGeometrySQL returns the condition for rows with geometry, for
+// generalized tables of tables with AllowNullGeometry.
+func (spec *TableSpec) notNullGeometrySQL() string {
+	return fmt.Sprintf(`"%s" IS NOT NULL`, spec.Columns[spec.geometryColumnIndex()].Name)
+}
+
+// exceedsSubdivide returns whether the geometry of the row has more
+// vertices than allowed by spec.Subdivide.
+func (spec *TableSpec) exceedsSubdivide(row []interface{}) bool {
+	if spec.Subdivide <= 0 {
+		return false
+	}
+	idx := spec.geometryColumnIndex()
+	if idx < 0 || idx >= len(row) {
+		return false
+	}
+	wkb, err := hexWkb(row[idx])
+	if err != nil || wkb == nil {
+		return false
+	}
+	n, err := wkbNumPoints(wkb)
+	if err != nil {
+		log.Warnf("unable to count vertices for %s: %s", spec.FullName, err)
+		return false
+	}
+	return n > spec.Subdivide
+}
+
+// geometryColumnType returns the type of the geometry column as used
+// by AddGeometryColumn.
+func (spec *TableSpec) geometryColumnType() string {
+	geomType := strings.ToUpper(spec.GeometryType)
+	if spec.GeometrySubtype == geometrySubtypeMulti {
+		geomType = "MULTI" + geomType
+	} else if geomType == "POLYGON" {
+		geomType = "GEOMETRY" // for multipolygon support
+	}
+	if spec.PreserveM {
+		geomType += "M"
+	}
+	return geomType
+}
+
+// geometryDims returns the number of dimensions of the geometry column.
+func (spec *TableSpec) geometryDims() int {
+	if spec.PreserveM {
+		return 3
+	}
+	return 2
+}
+
+// transformGeometry returns whether inserted geometries need to be
+// transformed into the SRID of the table.
+func (spec *TableSpec) transformGeometry() bool {
+	if spec.autoSrid != nil {
+		// ST_Transform uses the SRID of the geometry
+		return spec.Srid != 0
+	}
+	return spec.InputSrid != 0 && spec.Srid != spec.InputSrid
+}
+
+// CopySQL returns the COPY statement for text mode COPY. Geometries need
+// to be passed as hex encoded EWKB (see copyRow). COPY requires the values
+// of the TimestampColumn, the LastModifiedColumn and the ImportIDColumn
+// (see timestampRow).
+func (spec *TableSpec) CopySQL() string {
+	d := spec.dialect()
+	return fmt.Sprintf(`COPY %s (%s) FROM STDIN`,
+		d.QualifiedName(spec.Schema, spec.FullName),
+		strings.Join(spec.copyColumns(d), ", "),
+	)
+}
+
+// copyColumns returns the quoted columns of CopySQL.
+func (spec *TableSpec) copyColumns(d Dialect) []string {
+	var cols []string
+	for _, col := range spec.Columns {
+		cols = append(cols, d.QuoteIdent(col.Name))
+	}
+	if spec.TimestampColumn != "" {
+		cols = append(cols, d.QuoteIdent(spec.TimestampColumn))
+	}
+	if spec.LastModifiedColumn != "" {
+		cols = append(cols, d.QuoteIdent(spec.LastModifiedColumn))
+	}
+	if spec.ImportIDColumn != "" {
+		cols = append(cols, d.QuoteIdent(spec.ImportIDColumn))
+	}
+	return cols
+}
+
+// copyRow returns the row with the geometry as hex encoded EWKB with the
+// SRID of the table, also for WKT geometries. The row is returned
+// unchanged if the geometry already is in this format.
+func (spec *TableSpec) copyRow(row []interface{}) ([]interface{}, error) {
+	idx := spec.geometryColumnIndex()
+	if idx < 0 || idx >= len(row) {
+		return row, nil
+	}
+	var geom interface{}
+	var err error
+	if spec.GeometryEncoding == GeometryEncodingWkt {
+		geom, err = wktHexEwkb(row[idx], spec.Srid)
+	} else {
+		geom, err = ewkbHexWithSrid(row[idx], spec.Srid)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if s, ok := row[idx].(string); ok && s == geom {
+		return row, nil
+	}
+	copied := make([]interface{}, len(row))
+	copy(copied, row)
+	copied[idx] = geom
+	return copied, nil
+}
+
+// timestampRow returns a copy of the row with the values for the
+// TimestampColumn, the LastModifiedColumn and the ImportIDColumn appended.
+// The row is returned unchanged if the table has none of them.
+func (spec *TableSpec) timestampRow(row []interface{}, t time.Time) []interface{} {
+	if spec.TimestampColumn != "" {
+		row = append(row[:len(row):len(row)], t)
+	}
+	if spec.LastModifiedColumn != "" {
+		row = append(row[:len(row):len(row)], t)
+	}
+	if spec.ImportIDColumn != "" {
+		row = append(row[:len(row):len(row)], spec.ImportID)
+	}
+	return row
+}
+
 func (spec *TableSpec) DeleteSQL() string {
 	var idColumnName string
 	for _, col := range spec.Columns {
@@ -117,35 +605,456 @@ func (spec *TableSpec) DeleteSQL() string {
 		panic("missing id column")
 	}
 
-	return fmt.Sprintf(`DELETE FROM "%s"."%s" WHERE "%s" = $1`,
+	// only delete from this table, not from the child tables
+	only := spec.only()
+	if spec.SoftDelete {
+		return softDeleteSQL(only, spec.Schema, spec.FullName, idColumnName)
+	}
+	return fmt.Sprintf(`DELETE FROM %s"%s"."%s" WHERE "%s" = $1`,
+		only,
 		spec.Schema,
 		spec.FullName,
 		idColumnName,
 	)
 }
 
-func NewTableSpec(pg *PostGIS, t *mapping.Table) *TableSpec {
+// TableSpecError contains all problems of a table of the mapping.
+type TableSpecError struct {
+	Table    string
+	Problems []string
+}
+
+func (e *TableSpecError) Error() string {
+	return fmt.Sprintf("invalid table %s: %s", e.Table, strings.Join(e.Problems, "; "))
+}
+
+// TableSpecErrors contains the errors of all invalid tables.
+type TableSpecErrors []*TableSpecError
+
+func (e TableSpecErrors) Len() int           { return len(e) }
+func (e TableSpecErrors) Less(i, j int) bool { return e[i].Table < e[j].Table }
+func (e TableSpecErrors) Swap(i, j int)      { e[i], e[j] = e[j], e[i] }
+
+func (e TableSpecErrors) Error() string {
+	var lines []string
+	for _, err := range e {
+		lines = append(lines, err.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// reservedColumnSuffix is appended to reserved column names with
+// Config.RenameReservedColumns.
+const reservedColumnSuffix = "_tag"
+
+var geometryFieldTypes = []string{"geometry", "validated_geometry"}
+
+// reservedColumns returns the names of the columns that imposm adds or
+// uses itself, with the field types that are allowed to use that name.
+// The names of the id and geometry columns are taken from the first id
+// and geometry field of the table.
+func reservedColumns(fields []*mapping.Field) map[string][]string {
+	idCol, geomCol := "", ""
+	for _, field := range fields {
+		if idCol == "" && field.Type == "id" {
+			idCol = field.Name
+		}
+		if geomCol == "" && (field.Type == "geometry" || field.Type == "validated_geometry") {
+			geomCol = field.Name
+		}
+	}
+	if idCol == "" {
+		idCol = "osm_id"
+	}
+	if geomCol == "" {
+		geomCol = "geometry"
+	}
+	return map[string][]string{
+		"id":    {"id"}, // replaces the serial id column
+		idCol:   {"id"},
+		geomCol: geometryFieldTypes,
+	}
+}
+
+// fieldDescription describes a field of the mapping for error messages.
+func fieldDescription(field *mapping.Field) string {
+	if field.Key != "" {
+		return fmt.Sprintf("field %s (type %s, key %s)", field.Name, field.Type, field.Key)
+	}
+	return fmt.Sprintf("field %s (type %s)", field.Name, field.Type)
+}
+
+// checkReservedColumns returns a problem for each field that uses a
+// reserved column name (see reservedColumns). With rename, these columns
+// are renamed with reservedColumnSuffix instead.
+func checkReservedColumns(spec *TableSpec, fields []*mapping.Field, rename bool) []string {
+	var problems []string
+	reserved := reservedColumns(fields)
+	for i := range spec.Columns {
+		col := &spec.Columns[i]
+		types, ok := reserved[col.Name]
+		if !ok {
+			continue
+		}
+		allowed := false
+		for _, typ := range types {
+			if fields[i].Type == typ {
+				allowed = true
+			}
+		}
+		if allowed {
+			continue
+		}
+		if rename {
+			log.Warnf("renamed column %s of %s in table %s to %s",
+				col.Name, fieldDescription(fields[i]), spec.Name, col.Name+reservedColumnSuffix)
+			col.Name += reservedColumnSuffix
+			continue
+		}
+		problems = append(problems, fmt.Sprintf("column %s defined by %s is reserved for %s fields",
+			col.Name, fieldDescription(fields[i]), strings.Join(types, "/")))
+	}
+	return problems
+}
+
+// checkColumns returns a problem for each column name that is used more
+// than once, including columns that are added by imposm.
+func checkColumns(spec *TableSpec, fields []*mapping.Field) []string {
+	var problems []string
+	origins := make(map[string]string)
+	for i, col := range spec.Columns {
+		desc := fieldDescription(fields[i])
+		if origin, ok := origins[col.Name]; ok {
+			problems = append(problems, fmt.Sprintf("column %s defined by %s and %s", col.Name, origin, desc))
+			continue
+		}
+		origins[col.Name] = desc
+	}
+	if origin, ok := origins[deletedColumn]; ok && spec.SoftDelete {
+		problems = append(problems, fmt.Sprintf("column %s defined by %s and by soft delete", deletedColumn, origin))
+	}
+	if origin, ok := origins[spec.TimestampColumn]; ok {
+		problems = append(problems, fmt.Sprintf("column %s defined by %s and timestamp_column", spec.TimestampColumn, origin))
+	}
+	if origin, ok := origins[spec.LastModifiedColumn]; ok {
+		problems = append(problems, fmt.Sprintf("column %s defined by %s and last_modified_column", spec.LastModifiedColumn, origin))
+	}
+	if spec.LastModifiedColumn != "" && spec.LastModifiedColumn == spec.TimestampColumn {
+		problems = append(problems, fmt.Sprintf("column %s defined by timestamp_column and last_modified_column", spec.LastModifiedColumn))
+	}
+	if origin, ok := origins[spec.ImportIDColumn]; ok {
+		problems = append(problems, fmt.Sprintf("column %s defined by %s and ImportIDColumn", spec.ImportIDColumn, origin))
+	}
+	for _, name := range []string{spec.TimestampColumn, spec.LastModifiedColumn, spec.GenerationColumn} {
+		if name != "" && name == spec.ImportIDColumn {
+			problems = append(problems, fmt.Sprintf("column %s defined by ImportIDColumn and another column option", name))
+		}
+	}
+	if origin, ok := origins[spec.GenerationColumn]; ok {
+		problems = append(problems, fmt.Sprintf("column %s defined by %s and generation_column", spec.GenerationColumn, origin))
+	}
+	for _, col := range spec.GeneratedColumns {
+		if origin, ok := origins[col.Name]; ok {
+			problems = append(problems, fmt.Sprintf("column %s defined by %s and generated column", col.Name, origin))
+		}
+		origins[col.Name] = "generated column"
+	}
+	if spec.TileIndex != nil {
+		if origin, ok := origins[spec.TileIndex.Name]; ok {
+			problems = append(problems, fmt.Sprintf("column %s defined by %s and tile_index", spec.TileIndex.Name, origin))
+		}
+	}
+	if spec.InvalidTable {
+		for _, name := range []string{"error_reason", "error_detail"} {
+			if origin, ok := origins[name]; ok {
+				problems = append(problems, fmt.Sprintf("column %s defined by %s and by the invalid table", name, origin))
+			}
+		}
+	}
+	return problems
+}
+
+// checkGeometryOptions returns all problems of tables without geometry
+// column.
+func checkGeometryOptions(spec *TableSpec, t *mapping.Table) []string {
+	var problems []string
+	if idx := spec.geometryColumnIndex(); idx >= 0 && !spec.hasGeometry() {
+		problems = append(problems, fmt.Sprintf("table type none can not have geometry column %s", spec.Columns[idx].Name))
+	}
+	if spec.geometryColumnIndex() >= 0 {
+		if spec.GeometryEncoding == GeometryEncodingWkt && (t.Subdivide > 0 || t.MaxVertices > 0) {
+			// vertices are counted in the WKB of the geometries
+			problems = append(problems, "subdivide and max_vertices not supported with geometry encoding wkt")
+		}
+		return problems
+	}
+	if t.Subdivide > 0 {
+		problems = append(problems, "subdivide requires geometry column")
+	}
+	if t.MaxVertices > 0 {
+		problems = append(problems, "max_vertices requires geometry column")
+	}
+	if t.Srid != 0 {
+		problems = append(problems, "srid requires geometry column")
+	}
+	if t.Dedup != nil && t.Dedup.Geometry {
+		problems = append(problems, "dedup geometry requires geometry column")
+	}
+	return problems
+}
+
+// checkUpsert returns all problems of the upsert and on_update options.
+func checkUpsert(spec *TableSpec) []string {
+	var problems []string
+	if !spec.Upsert {
+		for _, col := range spec.Columns {
+			if col.OnUpdate != "" {
+				problems = append(problems, fmt.Sprintf("on_update of column %s requires upsert", col.Name))
+			}
+		}
+		return problems
+	}
+	if idx := spec.idColumnIndex(); idx < 0 {
+		problems = append(problems, "upsert requires id column")
+	} else if spec.Columns[idx].OnUpdate != "" {
+		problems = append(problems, fmt.Sprintf("on_update not allowed for id column %s", spec.Columns[idx].Name))
+	}
+	if spec.Subdivide > 0 {
+		// ON CONFLICT can't update the same row with multiple parts
+		problems = append(problems, "upsert not allowed with subdivide")
+	}
+	return problems
+}
+
+// NewTableSpec returns the spec of the mapping table. It returns a
+// *TableSpecError with all problems of the table.
+func NewTableSpec(pg *PostGIS, t *mapping.Table) (*TableSpec, error) {
 	spec := TableSpec{
 		Name:         t.Name,
 		FullName:     pg.Prefix + t.Name,
 		Schema:       pg.Config.ImportSchema,
 		GeometryType: string(t.Type),
 		Srid:         pg.Config.Srid,
+		InputSrid:    pg.Config.Srid,
+		Subdivide:    t.Subdivide,
+		MaxVertices:  t.MaxVertices,
+
+		InsertDefaultId:    pg.Config.InsertDefaultId,
+		InvalidTable:       pg.Config.InvalidTables,
+		TimestampColumn:    t.TimestampColumn,
+		LastModifiedColumn: t.LastModifiedColumn,
+		TileIndex:          t.TileIndex,
+		inherits:           t.Inherits,
+		foreignKey:         t.ForeignKey,
+		Upsert:             t.Upsert,
+		SoftDelete:         pg.Config.SoftDelete,
+		CopyBufferRows:     pg.Config.CopyBufferRows,
+		CopyBufferBytes:    pg.Config.CopyBufferBytes,
+
+		DeferConstraintValidation: pg.Config.DeferConstraintValidation,
+		IndexNameTemplate:         pg.Config.IndexNameTemplate,
+
+		GenerationColumn: t.GenerationColumn,
+		ImportIDColumn:   pg.Config.ImportIDColumn,
+		ImportID:         pg.Config.ImportID,
+		Reindex:          pg.Config.Reindex || t.Reindex,
+		Shards:           t.Shards,
+		ShardBy:          t.ShardBy,
+
+		GeometryEncoding:       pg.Config.GeometryEncoding,
+		PreserveM:              pg.Config.PreserveM,
+		GeometryMismatchPolicy: pg.Config.GeometryMismatchPolicy,
+		TransformPipeline:      pg.Config.TransformPipeline,
+		GridSize:               pg.Config.GridSize,
+
+		LoadMethod:        pg.Config.LoadMethod,
+		CopyThresholdRows: pg.Config.CopyThresholdRows,
+
+		SkipRowsMissingRequired: pg.Config.SkipRowsMissingRequired,
+
+		rows:       &rowCounter{},
+		onRowError: pg.Config.OnRowError,
+	}
+	if spec.onRowError == nil {
+		spec.onRowError = database.SkipRowErrors
+	}
+	if spec.MaxVertices > 0 {
+		spec.vertexLimits = &vertexLimitLog{}
+	}
+	spec.AnalyzeEveryRows = pg.Config.AnalyzeEveryRows
+	if t.AnalyzeEveryRows != 0 {
+		spec.AnalyzeEveryRows = t.AnalyzeEveryRows
+	}
+	if spec.AnalyzeEveryRows > 0 {
+		spec.analyzer = &analyzer{}
+	}
+	if pg.Config.Srid == 0 {
+		if pg.autoSrid == nil {
+			pg.autoSrid = &sridDetector{}
+		}
+		spec.autoSrid = pg.autoSrid
+	}
+	if t.LoadMethod != "" {
+		spec.LoadMethod = t.LoadMethod
+	}
+	if t.CopyBuffer != nil {
+		if t.CopyBuffer.Rows > 0 {
+			spec.CopyBufferRows = t.CopyBuffer.Rows
+		}
+		if t.CopyBuffer.Bytes > 0 {
+			spec.CopyBufferBytes = t.CopyBuffer.Bytes
+		}
 	}
-	for _, field := range t.Fields {
+	if pg.Config.SkipGeometry {
+		spec.GeometryType = string(mapping.NoneTable)
+	}
+	var fields []*mapping.Field
+	var typeProblems []string
+	for i, field := range t.Fields {
 		fieldType := field.FieldType()
 		if fieldType == nil {
 			continue
 		}
+		if pg.Config.SkipGeometry && isGeometryField(fieldType) {
+			spec.skippedColumns = append(spec.skippedColumns, i)
+			continue
+		}
 		pgType, ok := pgTypes[fieldType.GoType]
+		switch fieldType.GoType {
+		case "decimal":
+			decimalType, err := newDecimalColumnType(field)
+			if err != nil {
+				typeProblems = append(typeProblems, err.Error())
+				continue
+			}
+			pgType, ok = decimalType, true
+		case "osm_type":
+			osmType, err := newOsmTypeColumnType(field)
+			if err != nil {
+				typeProblems = append(typeProblems, err.Error())
+				continue
+			}
+			pgType, ok = osmType, true
+		case "geometry_hash":
+			hashType, err := newGeometryHashColumnType(field)
+			if err != nil {
+				typeProblems = append(typeProblems, err.Error())
+				continue
+			}
+			pgType, ok = hashType, true
+		}
 		if !ok {
 			log.Errorf("unhandled field type %v, using string type", fieldType)
 			pgType = pgTypes["string"]
 		}
-		col := ColumnSpec{field.Name, *fieldType, pgType}
+		col := ColumnSpec{Name: field.Name, FieldType: *fieldType, Type: pgType, OnUpdate: field.OnUpdate}
+		col.NotNull = field.NotNull
+		col.Default = field.Default
+		if field.Generated != "" {
+			col.Generated = field.Generated
+			spec.skippedColumns = append(spec.skippedColumns, i)
+			spec.GeneratedColumns = append(spec.GeneratedColumns, col)
+			continue
+		}
+		if fieldType.GoType == "string" {
+			col.normalize = field.Normalize
+			if col.normalize == nil {
+				col.normalize = pg.Config.NormalizeStrings
+			}
+			col.nullPolicy = field.NullPolicy
+			if col.nullPolicy == "" {
+				col.nullPolicy = pg.Config.NullPolicy
+			}
+		}
 		spec.Columns = append(spec.Columns, col)
+		fields = append(fields, field)
+		if len(field.NullValues) > 0 {
+			spec.nullValueFields = append(spec.nullValueFields, field)
+		}
 	}
-	return &spec
+	problems := typeProblems
+	problems = append(problems, checkReservedColumns(&spec, fields, pg.Config.RenameReservedColumns)...)
+	problems = append(problems, checkColumns(&spec, fields)...)
+	problems = append(problems, pg.prepareEnumColumns(&spec, fields)...)
+
+	if t.Srid != 0 {
+		spec.Srid = t.Srid
+	}
+	spec.schemas = pg.defaultSchemas()
+	if t.Schema != "" {
+		if schemas, ok := pg.Config.TableSchemas[t.Schema]; ok {
+			spec.schemas = schemas
+			spec.Schema = schemas.Import
+		} else {
+			problems = append(problems, fmt.Sprintf("unknown schema '%s'", t.Schema))
+		}
+	}
+	if isTempSchema(spec.Schema) {
+		problems = append(problems, fmt.Sprintf("temporary schema '%s' not supported, use load_method staging", spec.Schema))
+	}
+	switch t.IdIndex {
+	case "", idIndexBtree:
+	case idIndexBrin:
+		spec.IdIndex = idIndexBrin
+	default:
+		problems = append(problems, fmt.Sprintf("unknown id_index '%s'", t.IdIndex))
+	}
+	if t.AllowNullGeometry {
+		if spec.geometryColumnIndex() < 0 {
+			problems = append(problems, "allow_null_geometry requires geometry column")
+		} else {
+			spec.AllowNullGeometry = true
+		}
+	}
+	if t.CheckSrid {
+		if spec.geometryColumnIndex() < 0 {
+			problems = append(problems, "check_srid requires geometry column")
+		} else if spec.GeometryEncoding == GeometryEncodingWkt {
+			problems = append(problems, "check_srid requires EWKB geometries, not geometry encoding wkt")
+		} else {
+			spec.CheckSrid = true
+		}
+	}
+	if subtypeProblems := checkGeometrySubtype(&spec, t); len(subtypeProblems) > 0 {
+		problems = append(problems, subtypeProblems...)
+	} else {
+		spec.GeometrySubtype = t.GeometrySubtype
+	}
+	switch t.GeometryCheck {
+	case "":
+	case geometryCheckImmediate, geometryCheckDeferred:
+		if spec.geometryColumnIndex() < 0 {
+			problems = append(problems, "geometry_check requires geometry column")
+		} else {
+			spec.GeometryCheck = t.GeometryCheck
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("unknown geometry_check '%s'", t.GeometryCheck))
+	}
+	if spec.TileIndex != nil {
+		problems = append(problems, checkTileIndex(&spec, spec.TileIndex)...)
+	}
+	problems = append(problems, checkGeneratedColumns(&spec, t)...)
+	problems = append(problems, checkGeometryOptions(&spec, t)...)
+	indexes, indexProblems := newIndexSpecs(&spec, t.Indexes)
+	spec.Indexes = indexes
+	problems = append(problems, indexProblems...)
+	problems = append(problems, checkUpsert(&spec)...)
+	problems = append(problems, checkNullPolicies(&spec, fields)...)
+	problems = append(problems, checkLoadMethod(&spec, t.LoadMethod)...)
+	problems = append(problems, checkShards(&spec, t)...)
+	if t.Dedup != nil {
+		dedup, err := newDeduplicator(t.Dedup, &spec)
+		if err != nil {
+			problems = append(problems, err.Error())
+		} else {
+			spec.dedup = dedup
+		}
+	}
+	if len(problems) > 0 {
+		return nil, &TableSpecError{t.Name, problems}
+	}
+	return &spec, nil
 }
 
 func NewGeneralizedTableSpec(pg *PostGIS, t *mapping.GeneralizedTable) *GeneralizedTableSpec {
@@ -202,6 +1111,12 @@ func (spec *GeneralizedTableSpec) InsertSQL() string {
 	if spec.Where != "" {
 		where += " AND (" + spec.Where + ")"
 	}
+	if spec.Source.SoftDelete {
+		where += " AND " + notDeletedSQL
+	}
+	if spec.Source.AllowNullGeometry {
+		where += " AND " + spec.Source.notNullGeometrySQL()
+	}
 
 	columnSQL := strings.Join(cols, ",\n")
 	sql := fmt.Sprintf(`INSERT INTO "%s"."%s" (SELECT %s FROM "%s"."%s"%s)`,
@@ -0,0 +1,581 @@
+package postgis
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/omniscale/imposm3/database"
+	"github.com/omniscale/imposm3/mapping"
+)
+
+func testPostGIS() *PostGIS {
+	return &PostGIS{
+		Config: database.Config{
+			ImportSchema: "import",
+			Srid:         3857,
+		},
+		Prefix: "osm_",
+	}
+}
+
+func testTable() *mapping.Table {
+	return &mapping.Table{
+		Name: "roads",
+		Type: mapping.LineStringTable,
+		Fields: []*mapping.Field{
+			{Name: "osm_id", Type: "id"},
+			{Name: "geometry", Type: "geometry"},
+			{Name: "name", Key: "name", Type: "string"},
+			{Name: "tags", Type: "hstore_tags"},
+		},
+	}
+}
+
+func testTableSpec(t *testing.T, pg *PostGIS, table *mapping.Table) *TableSpec {
+	spec, err := NewTableSpec(pg, table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return spec
+}
+
+func TestSubdivideInsertSQL(t *testing.T) {
+	table := testTable()
+	table.Subdivide = 64
+	spec := testTableSpec(t, testPostGIS(), table)
+
+	expected := `INSERT INTO "import"."osm_roads" ("osm_id", "geometry", "name", "tags") SELECT $1::BIGINT, subdivided, $3::VARCHAR, $4::HSTORE FROM ST_Subdivide($2::Geometry, 64) AS subdivided`
+	if sql := spec.SubdivideInsertSQL(); sql != expected {
+		t.Errorf("unexpected SQL\n%s\n%s", sql, expected)
+	}
+}
+
+func TestExceedsSubdivide(t *testing.T) {
+	table := testTable()
+	spec := testTableSpec(t, testPostGIS(), table)
+
+	small := ewkbLineString(3857, 0, 0, 1, 1, 2, 2).hex()
+	large := ewkbLineString(3857, 0, 0, 1, 1, 2, 2, 3, 3, 4, 4).hex()
+
+	if spec.exceedsSubdivide([]interface{}{1, large, "", ""}) {
+		t.Error("subdivide not enabled")
+	}
+
+	spec.Subdivide = 4
+	if spec.exceedsSubdivide([]interface{}{1, small, "", ""}) {
+		t.Error("small geometry exceeds limit")
+	}
+	if !spec.exceedsSubdivide([]interface{}{1, large, "", ""}) {
+		t.Error("large geometry does not exceed limit")
+	}
+	if spec.exceedsSubdivide([]interface{}{1, nil, "", ""}) {
+		t.Error("nil geometry exceeds limit")
+	}
+}
+
+func TestInsertSQL(t *testing.T) {
+	spec := testTableSpec(t, testPostGIS(), testTable())
+
+	expected := `INSERT INTO "import"."osm_roads" ("osm_id", "geometry", "name", "tags") VALUES ($1, $2::Geometry, $3, $4)`
+	if sql := spec.InsertSQL(); sql != expected {
+		t.Errorf("unexpected SQL\n%s\n%s", sql, expected)
+	}
+}
+
+func TestInsertSQLTempTable(t *testing.T) {
+	for _, schema := range []string{"pg_temp", "pg_temp_3", ""} {
+		spec := testTableSpec(t, testPostGIS(), testTable())
+		spec.Schema = schema
+		expected := `INSERT INTO "osm_roads" ("osm_id", "geometry", "name", "tags") VALUES ($1, $2::Geometry, $3, $4)`
+		if sql := spec.InsertSQL(); sql != expected {
+			t.Errorf("unexpected SQL for schema '%s'\n%s\n%s", schema, sql, expected)
+		}
+	}
+}
+
+func TestCreateTempTableSQL(t *testing.T) {
+	spec := testTableSpec(t, testPostGIS(), testTable())
+	spec.Schema = "pg_temp"
+	sql := spec.CreateTableSQL()
+	if !strings.Contains(sql, `CREATE TEMP TABLE IF NOT EXISTS "osm_roads" (`) || strings.Contains(sql, "pg_temp") {
+		t.Error("unexpected sql", sql)
+	}
+	if !strings.Contains(sql, `"geometry" Geometry(LINESTRING, 3857)`) || strings.Contains(sql, "ON COMMIT") {
+		t.Error("unexpected sql", sql)
+	}
+	if sql := spec.CopySQL(); sql != `COPY "osm_roads" ("osm_id", "geometry", "name", "tags") FROM STDIN` {
+		t.Error("unexpected sql", sql)
+	}
+
+	spec.OnCommitDrop = true
+	if sql := spec.CreateTableSQL(); !strings.HasSuffix(sql, ") ON COMMIT DROP;") {
+		t.Error("unexpected sql", sql)
+	}
+
+}
+
+func TestTempSchemaNotSupported(t *testing.T) {
+	pg := testPostGIS()
+	pg.Config.ImportSchema = "pg_temp"
+	if _, err := NewTableSpec(pg, testTable()); err == nil || !strings.Contains(err.Error(), "use load_method staging") {
+		t.Error("expected error for temporary schema", err)
+	}
+}
+
+func TestInsertSQLDefaultId(t *testing.T) {
+	pg := testPostGIS()
+	pg.Config.InsertDefaultId = true
+	spec := testTableSpec(t, pg, testTable())
+
+	expected := `INSERT INTO "import"."osm_roads" ("id", "osm_id", "geometry", "name", "tags") VALUES (DEFAULT, $1, $2::Geometry, $3, $4)`
+	if sql := spec.InsertSQL(); sql != expected {
+		t.Errorf("unexpected SQL\n%s\n%s", sql, expected)
+	}
+
+	// no DEFAULT for tables with an explicit id column
+	table := testTable()
+	table.Fields[0].Name = "id"
+	spec = testTableSpec(t, pg, table)
+	expected = `INSERT INTO "import"."osm_roads" ("id", "geometry", "name", "tags") VALUES ($1, $2::Geometry, $3, $4)`
+	if sql := spec.InsertSQL(); sql != expected {
+		t.Errorf("unexpected SQL\n%s\n%s", sql, expected)
+	}
+}
+
+func TestTableSrid(t *testing.T) {
+	spec := testTableSpec(t, testPostGIS(), testTable())
+	if spec.Srid != 3857 || spec.transformGeometry() {
+		t.Error("unexpected SRID", spec.Srid)
+	}
+
+	table := testTable()
+	table.Srid = 4326
+	spec = testTableSpec(t, testPostGIS(), table)
+	if spec.Srid != 4326 || spec.InputSrid != 3857 {
+		t.Error("unexpected SRID", spec.Srid, spec.InputSrid)
+	}
+	if !spec.transformGeometry() {
+		t.Error("geometries not transformed")
+	}
+
+	expected := `INSERT INTO "import"."osm_roads" ("osm_id", "geometry", "name", "tags") VALUES ($1, ST_Transform($2::Geometry, 4326), $3, $4)`
+	if sql := spec.InsertSQL(); sql != expected {
+		t.Errorf("unexpected SQL\n%s\n%s", sql, expected)
+	}
+}
+
+func TestTableSridSameAsImport(t *testing.T) {
+	table := testTable()
+	table.Srid = 3857
+	spec := testTableSpec(t, testPostGIS(), table)
+	if spec.transformGeometry() {
+		t.Error("geometries transformed")
+	}
+	expected := `INSERT INTO "import"."osm_roads" ("osm_id", "geometry", "name", "tags") VALUES ($1, $2::Geometry, $3, $4)`
+	if sql := spec.InsertSQL(); sql != expected {
+		t.Errorf("unexpected SQL\n%s\n%s", sql, expected)
+	}
+}
+
+func TestTransformPipeline(t *testing.T) {
+	pg := testPostGIS()
+	pg.Config.TransformPipeline = "urn:ogc:def:coordinateOperation:EPSG::1671"
+	table := testTable()
+	table.Srid = 4326
+	table.Subdivide = 64
+	spec := testTableSpec(t, pg, table)
+
+	expected := `INSERT INTO "import"."osm_roads" ("osm_id", "geometry", "name", "tags") VALUES ($1, ST_TransformPipeline($2::Geometry, 'urn:ogc:def:coordinateOperation:EPSG::1671', 4326), $3, $4)`
+	if sql := spec.InsertSQL(); sql != expected {
+		t.Errorf("unexpected SQL\n%s\n%s", sql, expected)
+	}
+	if sql := spec.SubdivideInsertSQL(); !strings.Contains(sql, "ST_Subdivide(ST_TransformPipeline($2::Geometry, 'urn:ogc:def:coordinateOperation:EPSG::1671', 4326), 64)") {
+		t.Error("unexpected SQL", sql)
+	}
+
+	pg.Config.TransformPipeline = "+proj=pipeline +step +init='x'"
+	spec = testTableSpec(t, pg, table)
+	if sql := spec.InsertSQL(); !strings.Contains(sql, "ST_TransformPipeline($2::Geometry, '+proj=pipeline +step +init=''x''', 4326)") {
+		t.Error("pipeline not quoted", sql)
+	}
+
+	// only for transformed geometries
+	table.Srid = 3857
+	spec = testTableSpec(t, pg, table)
+	if sql := spec.InsertSQL(); strings.Contains(sql, "ST_TransformPipeline") {
+		t.Error("unexpected transform", sql)
+	}
+}
+
+func TestCopyRow(t *testing.T) {
+	spec := testTableSpec(t, testPostGIS(), testTable())
+
+	ewkb := ewkbLineString(3857, 0, 0, 1, 1).hex()
+	row := []interface{}{int64(1), ewkb, "name", ""}
+	copied, err := spec.copyRow(row)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if &copied[0] != &row[0] {
+		t.Error("row with EWKB copied")
+	}
+
+	row = []interface{}{int64(1), ewkbLineString(0, 0, 0, 1, 1).Bytes(), "name", ""}
+	copied, err = spec.copyRow(row)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if copied[1] != ewkb {
+		t.Error("unexpected geometry", copied[1])
+	}
+	if _, ok := row[1].([]byte); !ok {
+		t.Error("original row modified")
+	}
+}
+
+func TestTimestampColumn(t *testing.T) {
+	table := testTable()
+	table.TimestampColumn = "imported_at"
+	spec := testTableSpec(t, testPostGIS(), table)
+
+	if sql := spec.CreateTableSQL(); !strings.Contains(sql, `"imported_at" TIMESTAMP WITH TIME ZONE DEFAULT now()`) {
+		t.Error("missing timestamp column", sql)
+	}
+	if sql := spec.InsertSQL(); strings.Contains(sql, "imported_at") {
+		t.Error("timestamp column in INSERT", sql)
+	}
+	if sql := spec.CopySQL(); sql != `COPY "import"."osm_roads" ("osm_id", "geometry", "name", "tags", "imported_at") FROM STDIN` {
+		t.Error("unexpected COPY", sql)
+	}
+	if sql := spec.CreateInvalidTableSQL(); strings.Contains(sql, "imported_at") {
+		t.Error("timestamp column in invalid table", sql)
+	}
+
+	now := time.Now()
+	row := make([]interface{}, 4, 8)
+	stamped := spec.timestampRow(row, now)
+	if len(stamped) != 5 || stamped[4] != now {
+		t.Error("unexpected row", stamped)
+	}
+	if row[:5][4] != nil {
+		t.Error("original row modified")
+	}
+
+	spec.TimestampColumn = ""
+	if stamped := spec.timestampRow(row, now); len(stamped) != 4 {
+		t.Error("unexpected row", stamped)
+	}
+}
+
+func TestTimestampColumnConflict(t *testing.T) {
+	table := testTable()
+	table.TimestampColumn = "name"
+	if _, err := NewTableSpec(testPostGIS(), table); err == nil {
+		t.Error("expected error for timestamp_column with name of column")
+	}
+}
+
+func TestUpsertSQL(t *testing.T) {
+	table := testTable()
+	table.Upsert = true
+	spec := testTableSpec(t, testPostGIS(), table)
+
+	expected := `INSERT INTO "import"."osm_roads" ("osm_id", "geometry", "name", "tags") VALUES ($1, $2::Geometry, $3, $4) ON CONFLICT ("osm_id") DO UPDATE SET "geometry" = EXCLUDED."geometry", "name" = EXCLUDED."name", "tags" = EXCLUDED."tags"`
+	if sql := spec.UpsertSQL(); sql != expected {
+		t.Errorf("unexpected SQL\n%s\n%s", sql, expected)
+	}
+	if sql := spec.CreateTableSQL(); !strings.Contains(sql, `UNIQUE ("osm_id")`) {
+		t.Error("missing unique constraint", sql)
+	}
+}
+
+func TestUpsertSQLOnUpdate(t *testing.T) {
+	table := testTable()
+	table.Upsert = true
+	table.TimestampColumn = "imported_at"
+	table.Fields = append(table.Fields, &mapping.Field{
+		Name: "version", Type: "integer", Key: "version",
+		OnUpdate: "GREATEST(osm_roads.version, EXCLUDED.version)",
+	})
+	spec := testTableSpec(t, testPostGIS(), table)
+
+	expected := `ON CONFLICT ("osm_id") DO UPDATE SET "geometry" = EXCLUDED."geometry", "name" = EXCLUDED."name", "tags" = EXCLUDED."tags", "version" = GREATEST(osm_roads.version, EXCLUDED.version), "imported_at" = DEFAULT`
+	if sql := spec.UpsertSQL(); !strings.HasSuffix(sql, expected) {
+		t.Errorf("unexpected SQL\n%s\n%s", sql, expected)
+	}
+}
+
+func TestUpsertProblems(t *testing.T) {
+	table := testTable()
+	table.Fields[2].OnUpdate = "EXCLUDED.name"
+	if _, err := NewTableSpec(testPostGIS(), table); err == nil {
+		t.Error("expected error for on_update without upsert")
+	}
+
+	table = testTable()
+	table.Upsert = true
+	table.Fields = table.Fields[1:]
+	if _, err := NewTableSpec(testPostGIS(), table); err == nil {
+		t.Error("expected error for upsert without id column")
+	}
+
+	table = testTable()
+	table.Upsert = true
+	table.Subdivide = 100
+	if _, err := NewTableSpec(testPostGIS(), table); err == nil {
+		t.Error("expected error for upsert with subdivide")
+	}
+}
+
+func TestNewTableSpecDuplicateColumn(t *testing.T) {
+	table := testTable()
+	table.Fields = append(table.Fields, &mapping.Field{Name: "name", Key: "name:en", Type: "string"})
+	_, err := NewTableSpec(testPostGIS(), table)
+	specErr, ok := err.(*TableSpecError)
+	if !ok {
+		t.Fatal("expected TableSpecError", err)
+	}
+	if specErr.Table != "roads" || len(specErr.Problems) != 1 {
+		t.Fatal("unexpected error", err)
+	}
+	expected := "column name defined by field name (type string, key name) and field name (type string, key name:en)"
+	if specErr.Problems[0] != expected {
+		t.Errorf("unexpected problem\n%s\n%s", specErr.Problems[0], expected)
+	}
+}
+
+func TestNewTableSpecReservedColumns(t *testing.T) {
+	for _, name := range []string{"id", "osm_id", "geometry"} {
+		table := testTable()
+		table.Fields = append(table.Fields, &mapping.Field{Name: name, Key: "ref", Type: "string"})
+		_, err := NewTableSpec(testPostGIS(), table)
+		specErr, ok := err.(*TableSpecError)
+		if !ok {
+			t.Fatal("expected TableSpecError", name, err)
+		}
+		if !strings.HasPrefix(specErr.Problems[0], "column "+name+" defined by field "+name+" (type string, key ref) is reserved for ") {
+			t.Error("unexpected problem", specErr.Problems)
+		}
+	}
+
+	// id fields can use id, replaces serial id
+	table := testTable()
+	table.Fields[0].Name = "id"
+	spec := testTableSpec(t, testPostGIS(), table)
+	if spec.hasSerialId() {
+		t.Error("unexpected serial id")
+	}
+}
+
+func TestNewTableSpecReservedColumnsFromFields(t *testing.T) {
+	// custom names of id and geometry column are reserved,
+	// default names are free
+	table := testTable()
+	table.Fields[0].Name = "osm_ident"
+	table.Fields[1].Name = "geom"
+	table.Fields = append(table.Fields,
+		&mapping.Field{Name: "osm_id", Key: "ref", Type: "string"},
+		&mapping.Field{Name: "geometry", Key: "geometry", Type: "string"},
+	)
+	testTableSpec(t, testPostGIS(), table)
+
+	table.Fields = append(table.Fields, &mapping.Field{Name: "geom", Key: "geom", Type: "string"})
+	_, err := NewTableSpec(testPostGIS(), table)
+	specErr, ok := err.(*TableSpecError)
+	if !ok {
+		t.Fatal("expected TableSpecError", err)
+	}
+	if specErr.Problems[0] != "column geom defined by field geom (type string, key geom) is reserved for geometry/validated_geometry fields" {
+		t.Error("unexpected problem", specErr.Problems)
+	}
+}
+
+func TestNewTableSpecRenameReservedColumns(t *testing.T) {
+	table := testTable()
+	table.Fields = append(table.Fields,
+		&mapping.Field{Name: "id", Key: "ref", Type: "string"},
+		&mapping.Field{Name: "geometry", Key: "shape", Type: "string"},
+	)
+	pg := testPostGIS()
+	pg.Config.RenameReservedColumns = true
+	spec := testTableSpec(t, pg, table)
+
+	var names []string
+	for _, col := range spec.Columns {
+		names = append(names, col.Name)
+	}
+	if strings.Join(names, ",") != "osm_id,geometry,name,tags,id_tag,geometry_tag" {
+		t.Error("unexpected columns", names)
+	}
+	if !spec.hasSerialId() {
+		t.Error("missing serial id")
+	}
+
+	// renamed column can still collide
+	table.Fields = append(table.Fields, &mapping.Field{Name: "id_tag", Key: "id", Type: "string"})
+	if _, err := NewTableSpec(pg, table); err == nil {
+		t.Error("expected error for duplicate renamed column")
+	}
+}
+
+func TestNewTableSpecInvalidTableColumns(t *testing.T) {
+	table := testTable()
+	table.Fields = append(table.Fields, &mapping.Field{Name: "error_reason", Key: "fixme", Type: "string"})
+	if _, err := NewTableSpec(testPostGIS(), table); err != nil {
+		t.Fatal(err)
+	}
+	pg := testPostGIS()
+	pg.Config.InvalidTables = true
+	if _, err := NewTableSpec(pg, table); err == nil {
+		t.Error("expected error for column of invalid table")
+	}
+}
+
+func TestPrepareTablesErrors(t *testing.T) {
+	m := &mapping.Mapping{Tables: mapping.Tables{}}
+	for _, name := range []string{"roads", "buildings", "landuse"} {
+		table := testTable()
+		table.Name = name
+		if name != "buildings" {
+			table.Fields = append(table.Fields, &mapping.Field{Name: "osm_id", Type: "id"})
+		}
+		m.Tables[name] = table
+	}
+	pg := testPostGIS()
+	pg.Tables = make(map[string]*TableSpec)
+	pg.GeneralizedTables = make(map[string]*GeneralizedTableSpec)
+	err := pg.prepareTables(m)
+	errs, ok := err.(TableSpecErrors)
+	if !ok {
+		t.Fatal("expected TableSpecErrors", err)
+	}
+	if len(errs) != 2 || errs[0].Table != "landuse" || errs[1].Table != "roads" {
+		t.Error("unexpected errors", err)
+	}
+	if !strings.Contains(err.Error(), "invalid table roads: column osm_id defined by field osm_id (type id) and field osm_id (type id)") {
+		t.Error("unexpected error message", err)
+	}
+}
+
+func attributeTable() *mapping.Table {
+	return &mapping.Table{
+		Name: "route_refs",
+		Type: mapping.NoneTable,
+		Fields: []*mapping.Field{
+			{Name: "osm_id", Type: "id"},
+			{Name: "ref", Key: "ref", Type: "string"},
+		},
+	}
+}
+
+func TestAttributeTable(t *testing.T) {
+	db, d := newFakeDb()
+	defer db.Close()
+	pg := testPostGIS()
+	pg.Db = db
+	spec := testTableSpec(t, pg, attributeTable())
+	pg.Tables = map[string]*TableSpec{"route_refs": spec}
+
+	if spec.hasGeometry() {
+		t.Error("attribute table with geometry")
+	}
+	expected := `CREATE TABLE IF NOT EXISTS "import"."osm_route_refs" (
+            id SERIAL PRIMARY KEY,
+"osm_id" BIGINT,
+"ref" VARCHAR
+        );`
+	if sql := strings.TrimSpace(spec.CreateTableSQL()); sql != expected {
+		t.Errorf("unexpected SQL\n%s\n%s", sql, expected)
+	}
+	if sql := spec.InsertSQL(); sql != `INSERT INTO "import"."osm_route_refs" ("osm_id", "ref") VALUES ($1, $2)` {
+		t.Error("unexpected SQL", sql)
+	}
+
+	tt := NewBulkTableTx(pg, spec)
+	if err := tt.Begin(nil); err != nil {
+		t.Fatal(err)
+	}
+	tt.Insert([]interface{}{int64(1), "A 1"})
+	tt.Insert([]interface{}{int64(2), "B 2"})
+	if err := tt.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if n := d.count(`COPY "import"."osm_route_refs" ("osm_id", "ref") FROM STDIN`); n != 3 {
+		t.Error("unexpected COPY execs", n)
+	}
+	if counts := pg.RowCounts()["route_refs"]; counts.Inserted != 2 || counts.SkippedNullGeometry != 0 {
+		t.Error("unexpected row counts", counts)
+	}
+}
+
+func TestAttributeTableProblems(t *testing.T) {
+	table := attributeTable()
+	table.Fields = append(table.Fields, &mapping.Field{Name: "geometry", Type: "geometry"})
+	if _, err := NewTableSpec(testPostGIS(), table); err == nil || !strings.Contains(err.Error(), "table type none can not have geometry column geometry") {
+		t.Error("expected error for geometry column", err)
+	}
+
+	table = attributeTable()
+	table.Subdivide = 100
+	table.Srid = 4326
+	table.GeometryCheck = "immediate"
+	_, err := NewTableSpec(testPostGIS(), table)
+	if err == nil {
+		t.Fatal("expected error for geometry options")
+	}
+	for _, problem := range []string{"subdivide requires geometry column", "srid requires geometry column", "geometry_check requires geometry column"} {
+		if !strings.Contains(err.Error(), problem) {
+			t.Error("missing problem", problem, err)
+		}
+	}
+
+	m := &mapping.Mapping{
+		Tables:            mapping.Tables{"route_refs": attributeTable()},
+		GeneralizedTables: mapping.GeneralizedTables{"route_refs_gen0": {Name: "route_refs_gen0", SourceTableName: "route_refs"}},
+	}
+	pg := testPostGIS()
+	pg.Tables = make(map[string]*TableSpec)
+	pg.GeneralizedTables = make(map[string]*GeneralizedTableSpec)
+	err = pg.prepareTables(m)
+	if err == nil || !strings.Contains(err.Error(), "invalid table route_refs_gen0: source table route_refs has no geometry") {
+		t.Error("expected error for generalized table", err)
+	}
+}
+
+func TestPreserveM(t *testing.T) {
+	pg := testPostGIS()
+	pg.Config.PreserveM = true
+	pg.Config.GridSize = 0.01
+	spec := testTableSpec(t, pg, testTable())
+
+	expected := `SELECT AddGeometryColumn('import', 'osm_roads', 'geometry', '3857', 'LINESTRINGM', 3);`
+	if sql := PostgreSQL.GeometryColumnDDL(spec, "geometry"); sql != expected {
+		t.Errorf("unexpected SQL\n%s\n%s", sql, expected)
+	}
+	spec.Schema = "pg_temp"
+	if sql := spec.CreateTableSQL(); !strings.Contains(sql, `"geometry" Geometry(LINESTRINGM, 3857)`) {
+		t.Error("unexpected SQL", sql)
+	}
+	// ST_ReducePrecision drops M values
+	if sql := spec.InsertSQL(); !strings.Contains(sql, "ST_SnapToGrid($2::Geometry, 0.01)") {
+		t.Error("unexpected SQL", sql)
+	}
+	if mismatches := geometryColumnMismatches(spec, 3857, "LINESTRINGM"); len(mismatches) != 0 {
+		t.Error("unexpected mismatches", mismatches)
+	}
+
+	polygons := testTable()
+	polygons.Type = "polygon"
+	spec = testTableSpec(t, pg, polygons)
+	if typ := spec.geometryColumnType(); typ != "GEOMETRYM" {
+		t.Error("unexpected geometry type", typ)
+	}
+
+	pg.Config.PreserveM = false
+	spec = testTableSpec(t, pg, testTable())
+	expected = `SELECT AddGeometryColumn('import', 'osm_roads', 'geometry', '3857', 'LINESTRING', 2);`
+	if sql := PostgreSQL.GeometryColumnDDL(spec, "geometry"); sql != expected {
+		t.Errorf("unexpected SQL\n%s\n%s", sql, expected)
+	}
+}
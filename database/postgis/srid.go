@@ -0,0 +1,195 @@
+package postgis
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// With Config.Srid 0, the SRID is detected from the EWKB header of the
+// first inserted geometry. Tables are created with SRID 0, which accepts
+// geometries of any SRID, and the geometry columns are updated to the
+// detected SRID at the end of the bulk import. All geometries need to
+// have the same SRID.
+
+// SridMismatchError is returned if a geometry has another SRID than the
+// detected SRID.
+type SridMismatchError struct {
+	Table    string
+	Detected int
+	Srid     int
+}
+
+func (e *SridMismatchError) Error() string {
+	if e.Srid < 0 {
+		return fmt.Sprintf("unable to detect SRID: geometry for %s has no SRID", e.Table)
+	}
+	return fmt.Sprintf("geometry for %s has SRID %d, but SRID %d was detected from previous geometries",
+		e.Table, e.Srid, e.Detected)
+}
+
+type sridDetector struct {
+	mu   sync.Mutex
+	srid int
+}
+
+// check sets the detected SRID if it is the first geometry and returns
+// an error if it differs from the detected SRID.
+func (d *sridDetector) check(table string, srid int) error {
+	if srid < 0 {
+		return &SridMismatchError{Table: table, Srid: srid}
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.srid == 0 {
+		log.Printf("detected SRID %d from geometry for %s", srid, table)
+		d.srid = srid
+		return nil
+	}
+	if d.srid != srid {
+		return &SridMismatchError{table, d.srid, srid}
+	}
+	return nil
+}
+
+func (d *sridDetector) detected() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.srid
+}
+
+// geometrySrid returns the SRID of the EWKB geometry value, or -1 if it
+// has no SRID. value can be hex encoded or binary (E)WKB.
+func geometrySrid(value interface{}) (int, error) {
+	if v, ok := value.(string); ok && len(v) >= 18 {
+		// only decode the header
+		value = v[:18]
+	}
+	wkb, err := hexWkb(value)
+	if err != nil {
+		return 0, err
+	}
+	r := wkbReader{buf: wkb}
+	_, _, _, srid, err := r.header()
+	return srid, err
+}
+
+// detectSrid checks the SRID of the geometry of the row, if the SRID is
+// detected from the data.
+func (spec *TableSpec) detectSrid(row []interface{}) error {
+	if spec.autoSrid == nil {
+		return nil
+	}
+	idx := spec.geometryColumnIndex()
+	if idx < 0 || idx >= len(row) || row[idx] == nil {
+		return nil
+	}
+	srid, err := geometrySrid(row[idx])
+	if err != nil {
+		return err
+	}
+	return spec.autoSrid.check(spec.Name, srid)
+}
+
+// updateDetectedSrid sets the detected SRID for all geometry columns
+// that were created with SRID 0. It updates spec.Srid and needs to be
+// called after all TableTx ended, as they read spec.Srid for each row.
+func (pg *PostGIS) updateDetectedSrid() error {
+	if pg.autoSrid == nil {
+		return nil
+	}
+	srid := pg.autoSrid.detected()
+	if srid == 0 {
+		log.Warn("unable to detect SRID, no geometries were inserted")
+		return nil
+	}
+	for _, spec := range pg.Tables {
+		idx := spec.geometryColumnIndex()
+		if spec.Srid != 0 || idx < 0 {
+			continue
+		}
+		sql := updateGeometrySridSQL(spec.Schema, spec.FullName, spec.Columns[idx].Name, srid)
+		var void interface{}
+		if err := pg.Db.QueryRow(sql).Scan(&void); err != nil {
+			return &SQLError{sql, err}
+		}
+		spec.Srid = srid
+	}
+	return nil
+}
+
+func updateGeometrySridSQL(schema, table, column string, srid int) string {
+	return fmt.Sprintf("SELECT UpdateGeometrySRID('%s', '%s', '%s', %d)", schema, table, column, srid)
+}
+
+// isSridMismatch returns whether the row needs to fail the import.
+func isSridMismatch(err error) bool {
+	_, ok := err.(*SridMismatchError)
+	return ok
+}
+
+// MixedSridError is returned by CheckSrid if the geometries of a table
+// have more than one SRID.
+type MixedSridError struct {
+	Table string
+	Srids []int
+}
+
+func (e *MixedSridError) Error() string {
+	srids := make([]string, len(e.Srids))
+	for i, srid := range e.Srids {
+		srids[i] = strconv.Itoa(srid)
+	}
+	return fmt.Sprintf("geometries of %s have %d different SRIDs: %s",
+		e.Table, len(e.Srids), strings.Join(srids, ", "))
+}
+
+func distinctSridsSQL(schema, table, column string) string {
+	return fmt.Sprintf(`SELECT DISTINCT ST_SRID("%s") FROM "%s"."%s" WHERE "%s" IS NOT NULL ORDER BY 1`,
+		column, schema, table, column)
+}
+
+// CheckSrid returns the distinct SRIDs of all geometries of the table (by
+// name, also generalized tables) in ascending order, e.g. to verify an
+// import. It returns the SRIDs together with a *MixedSridError if there
+// is more than one SRID. Geometry columns with a SRID (typmod) only accept
+// geometries of this SRID, mixed SRIDs are only possible in columns with
+// SRID 0. The query reads the whole table.
+func (pg *PostGIS) CheckSrid(table string) ([]int, error) {
+	var spec *TableSpec
+	var schema, name string
+	if s, ok := pg.Tables[table]; ok {
+		spec, schema, name = s, s.Schema, s.FullName
+	} else if s, ok := pg.GeneralizedTables[table]; ok {
+		spec, schema, name = s.Source, s.Schema, s.FullName
+	} else {
+		return nil, fmt.Errorf("unknown table %s", table)
+	}
+	idx := spec.geometryColumnIndex()
+	if idx < 0 {
+		return nil, fmt.Errorf("table %s has no geometry column", table)
+	}
+
+	sql := distinctSridsSQL(schema, name, spec.Columns[idx].Name)
+	rows, err := pg.Db.Query(sql)
+	if err != nil {
+		return nil, &SQLError{sql, err}
+	}
+	defer rows.Close()
+	var srids []int
+	for rows.Next() {
+		var srid int
+		if err := rows.Scan(&srid); err != nil {
+			return nil, &SQLError{sql, err}
+		}
+		srids = append(srids, srid)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, &SQLError{sql, err}
+	}
+	if len(srids) > 1 {
+		return srids, &MixedSridError{name, srids}
+	}
+	return srids, nil
+}
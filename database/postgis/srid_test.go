@@ -0,0 +1,144 @@
+package postgis
+
+import (
+	"database/sql/driver"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDetectSrid(t *testing.T) {
+	pg := testPostGIS()
+	pg.Config.Srid = 0
+	spec := testTableSpec(t, pg, testTable())
+	pg.Tables = map[string]*TableSpec{"roads": spec}
+
+	if _, err := spec.prepareRow([]interface{}{int64(1), nil, "", ""}); err != nil {
+		t.Error("unexpected error for row without geometry", err)
+	}
+	if pg.autoSrid.detected() != 0 {
+		t.Error("SRID detected without geometry")
+	}
+	if _, err := spec.prepareRow([]interface{}{int64(1), ewkbLineString(4326, 0, 0, 1, 1).hex(), "", ""}); err != nil {
+		t.Fatal(err)
+	}
+	if srid := pg.autoSrid.detected(); srid != 4326 {
+		t.Error("unexpected SRID", srid)
+	}
+	if _, err := spec.prepareRow([]interface{}{int64(2), ewkbLineString(4326, 0, 0, 1, 1).Bytes(), "", ""}); err != nil {
+		t.Error("unexpected error for binary EWKB", err)
+	}
+
+	_, err := spec.prepareRow([]interface{}{int64(3), ewkbLineString(3857, 0, 0, 1, 1).hex(), "", ""})
+	if !isSridMismatch(err) || err.Error() != "geometry for roads has SRID 3857, but SRID 4326 was detected from previous geometries" {
+		t.Error("expected SridMismatchError", err)
+	}
+	_, err = spec.prepareRow([]interface{}{int64(4), ewkbLineString(0, 0, 0, 1, 1).hex(), "", ""})
+	if !isSridMismatch(err) || !strings.Contains(err.Error(), "has no SRID") {
+		t.Error("expected SridMismatchError for geometry without SRID", err)
+	}
+}
+
+func TestDetectSridDisabled(t *testing.T) {
+	spec := testTableSpec(t, testPostGIS(), testTable())
+	if spec.autoSrid != nil {
+		t.Fatal("SRID detection enabled")
+	}
+	if _, err := spec.prepareRow([]interface{}{int64(1), ewkbLineString(4326, 0, 0, 1, 1).hex(), "", ""}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDetectSridTransform(t *testing.T) {
+	pg := testPostGIS()
+	pg.Config.Srid = 0
+	spec := testTableSpec(t, pg, testTable())
+	if spec.transformGeometry() {
+		t.Error("transform without table SRID")
+	}
+	table := testTable()
+	table.Srid = 25832
+	spec = testTableSpec(t, pg, table)
+	if !spec.transformGeometry() || !strings.Contains(spec.InsertSQL(), "ST_Transform($2::Geometry, 25832)") {
+		t.Error("missing transform", spec.InsertSQL())
+	}
+}
+
+func TestDetectSridBulkMismatch(t *testing.T) {
+	db, d := newFakeDb()
+	defer db.Close()
+	pg := testPostGIS()
+	pg.Db = db
+	pg.Config.Srid = 0
+	spec := testTableSpec(t, pg, testTable())
+	pg.Tables = map[string]*TableSpec{"roads": spec}
+
+	tt := NewBulkTableTx(pg, spec)
+	if err := tt.Begin(nil); err != nil {
+		t.Fatal(err)
+	}
+	tt.Insert([]interface{}{int64(1), ewkbLineString(4326, 0, 0, 1, 1).hex(), "", ""})
+	tt.Insert([]interface{}{int64(2), ewkbLineString(3857, 0, 0, 1, 1).hex(), "", ""})
+	tt.Insert([]interface{}{int64(3), ewkbLineString(4326, 0, 0, 1, 1).hex(), "", ""})
+	if err := tt.Commit(); !isSridMismatch(err) {
+		t.Fatal("expected SridMismatchError", err)
+	}
+	if d.commits != 0 || d.rollbacks != 1 {
+		t.Error("unexpected commits/rollbacks", d.commits, d.rollbacks)
+	}
+	if n := d.count("COPY"); n != 1 {
+		t.Error("unexpected COPY execs", n)
+	}
+}
+
+func TestUpdateGeometrySridSQL(t *testing.T) {
+	if sql := updateGeometrySridSQL("import", "osm_roads", "geometry", 4326); sql != "SELECT UpdateGeometrySRID('import', 'osm_roads', 'geometry', 4326)" {
+		t.Error("unexpected SQL", sql)
+	}
+}
+
+func TestCheckSridTable(t *testing.T) {
+	db, d := newFakeDb()
+	defer db.Close()
+	pg := testPostGIS()
+	pg.Db = db
+	spec := testTableSpec(t, pg, testTable())
+	pg.Tables = map[string]*TableSpec{"roads": spec}
+	pg.GeneralizedTables = map[string]*GeneralizedTableSpec{
+		"roads_gen0": {Name: "roads_gen0", FullName: "osm_roads_gen0", Schema: "import", Source: spec},
+	}
+
+	result := fakeResult{columns: []string{"st_srid"}, rows: [][]driver.Value{{int64(3857)}}}
+	d.results = map[string]fakeResult{"SELECT DISTINCT ST_SRID": result}
+	srids, err := pg.CheckSrid("roads")
+	if err != nil || !reflect.DeepEqual(srids, []int{3857}) {
+		t.Error("unexpected result", srids, err)
+	}
+	expected := `SELECT DISTINCT ST_SRID("geometry") FROM "import"."osm_roads" WHERE "geometry" IS NOT NULL ORDER BY 1`
+	if d.execs[0] != expected {
+		t.Errorf("unexpected SQL\n%s\n%s", d.execs[0], expected)
+	}
+
+	result.rows = [][]driver.Value{{int64(0)}, {int64(3857)}, {int64(4326)}}
+	d.results = map[string]fakeResult{"SELECT DISTINCT ST_SRID": result}
+	srids, err = pg.CheckSrid("roads_gen0")
+	if _, ok := err.(*MixedSridError); !ok || err.Error() != "geometries of osm_roads_gen0 have 3 different SRIDs: 0, 3857, 4326" {
+		t.Error("expected MixedSridError", err)
+	}
+	if !reflect.DeepEqual(srids, []int{0, 3857, 4326}) {
+		t.Error("unexpected SRIDs", srids)
+	}
+	if !strings.Contains(d.execs[1], `FROM "import"."osm_roads_gen0"`) {
+		t.Error("unexpected SQL", d.execs[1])
+	}
+
+	// empty tables have no SRIDs
+	d.results = map[string]fakeResult{"SELECT DISTINCT ST_SRID": {columns: []string{"st_srid"}}}
+	if srids, err := pg.CheckSrid("roads"); err != nil || len(srids) != 0 {
+		t.Error("unexpected result", srids, err)
+	}
+
+	if _, err := pg.CheckSrid("unknown"); err == nil {
+		t.Error("expected error for unknown table")
+	}
+}
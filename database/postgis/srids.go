@@ -0,0 +1,98 @@
+package postgis
+
+import (
+	"fmt"
+	"sort"
+
+	pq "github.com/lib/pq"
+	"github.com/omniscale/imposm3/database"
+)
+
+// Custom SRIDs are inserted into spatial_ref_sys before AddGeometryColumn
+// requires them. The insert only checks for an existing SRID and works
+// without ON CONFLICT on older servers.
+
+// minUserSrid is the first SRID of the range for user-defined spatial
+// reference systems. All SRIDs below are EPSG (or ESRI) codes that are
+// shipped with PostGIS.
+const minUserSrid = 900000
+
+// maxSrid is the max SRID that PostGIS accepts.
+const maxSrid = 999999
+
+// CustomSridError is returned if a custom SRID can't be inserted into
+// spatial_ref_sys.
+type CustomSridError struct {
+	Srid int
+	err  error
+}
+
+func (e *CustomSridError) Error() string {
+	if isInsufficientPrivilege(e.err) {
+		return fmt.Sprintf("insufficient privileges to insert SRID %d into spatial_ref_sys, "+
+			"insert the SRID as database owner or superuser before the import: %s", e.Srid, e.err)
+	}
+	return fmt.Sprintf("unable to insert SRID %d into spatial_ref_sys: %s", e.Srid, e.err)
+}
+
+func isInsufficientPrivilege(err error) bool {
+	if sqlErr, ok := err.(*SQLError); ok {
+		err = sqlErr.originalError
+	}
+	pqErr, ok := err.(*pq.Error)
+	return ok && pqErr.Code == "42501"
+}
+
+const insertCustomSridSQL = `INSERT INTO spatial_ref_sys (srid, auth_name, auth_srid, srtext, proj4text) ` +
+	`SELECT $1, $2, $3, $4, $5 WHERE NOT EXISTS (SELECT 1 FROM spatial_ref_sys WHERE srid = $1)`
+
+// registerCustomSrids inserts all CustomSrids that are missing in
+// spatial_ref_sys.
+func (pg *PostGIS) registerCustomSrids() error {
+	for _, def := range pg.Config.CustomSrids {
+		authSrid := def.AuthSrid
+		if authSrid == 0 {
+			authSrid = def.Srid
+		}
+		err := pg.withArgs(func(db queryer) error {
+			_, err := db.Exec(insertCustomSridSQL, def.Srid, def.AuthName, authSrid, def.Wkt, def.Proj4)
+			return err
+		})
+		if err != nil {
+			return &CustomSridError{def.Srid, &SQLError{insertCustomSridSQL, err}}
+		}
+	}
+	return nil
+}
+
+// checkCustomSrids returns an error for invalid CustomSrids and for
+// user-defined SRIDs of the config or of the tables that are not
+// included in CustomSrids.
+func checkCustomSrids(conf database.Config, tables map[string]*TableSpec) error {
+	custom := make(map[int]bool)
+	for _, def := range conf.CustomSrids {
+		switch {
+		case def.Srid <= 0 || def.Srid > maxSrid:
+			return fmt.Errorf("custom SRID %d not within 1 and %d", def.Srid, maxSrid)
+		case custom[def.Srid]:
+			return fmt.Errorf("custom SRID %d defined twice", def.Srid)
+		case def.Proj4 == "" && def.Wkt == "":
+			return fmt.Errorf("custom SRID %d requires proj4 or wkt", def.Srid)
+		}
+		custom[def.Srid] = true
+	}
+	if conf.Srid >= minUserSrid && !custom[conf.Srid] {
+		return fmt.Errorf("SRID %d is not a standard SRID and missing in CustomSrids", conf.Srid)
+	}
+	var names []string
+	for name := range tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if srid := tables[name].Srid; srid >= minUserSrid && !custom[srid] {
+			return fmt.Errorf("SRID %d of table %s is not a standard SRID and missing in CustomSrids", srid, name)
+		}
+	}
+	return nil
+}
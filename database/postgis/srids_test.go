@@ -0,0 +1,91 @@
+package postgis
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	pq "github.com/lib/pq"
+	"github.com/omniscale/imposm3/database"
+)
+
+const testProj4 = "+proj=laea +lat_0=52 +lon_0=10 +x_0=4321000 +y_0=3210000 +ellps=GRS80 +units=m +no_defs"
+
+func TestRegisterCustomSrids(t *testing.T) {
+	db, d := newFakeDb()
+	defer db.Close()
+	pg := testPostGIS()
+	pg.Db = db
+	pg.Config.CustomSrids = []database.SridDef{
+		{Srid: 910001, AuthName: "local", Proj4: testProj4},
+		{Srid: 910002, AuthName: "local", AuthSrid: 2, Wkt: `PROJCS["local"]`},
+	}
+	if err := pg.registerCustomSrids(); err != nil {
+		t.Fatal(err)
+	}
+	if n := d.count("INSERT INTO spatial_ref_sys"); n != 2 {
+		t.Fatal("unexpected inserts", n)
+	}
+	if !strings.Contains(d.execs[0], "WHERE NOT EXISTS (SELECT 1 FROM spatial_ref_sys WHERE srid = $1)") {
+		t.Error("insert does not check for existing SRID", d.execs[0])
+	}
+	if authSrids := d.values("INSERT", 2); authSrids[0] != int64(910001) || authSrids[1] != int64(2) {
+		t.Error("unexpected auth_srid", authSrids)
+	}
+	if texts := d.values("INSERT", 4); texts[0] != testProj4 || texts[1] != "" {
+		t.Error("unexpected proj4text", texts)
+	}
+
+	d.fail = "INSERT INTO spatial_ref_sys"
+	err := pg.registerCustomSrids()
+	if _, ok := err.(*CustomSridError); !ok || !strings.HasPrefix(err.Error(), "unable to insert SRID 910001") {
+		t.Error("expected CustomSridError", err)
+	}
+}
+
+func TestCustomSridErrorPrivileges(t *testing.T) {
+	err := &CustomSridError{910001, &SQLError{insertCustomSridSQL, &pq.Error{Code: "42501", Message: "permission denied for table spatial_ref_sys"}}}
+	if !strings.HasPrefix(err.Error(), "insufficient privileges to insert SRID 910001 into spatial_ref_sys, insert the SRID as database owner or superuser") {
+		t.Error("unexpected error", err)
+	}
+	err = &CustomSridError{910001, errors.New("connection refused")}
+	if err.Error() != "unable to insert SRID 910001 into spatial_ref_sys: connection refused" {
+		t.Error("unexpected error", err)
+	}
+}
+
+func TestCheckCustomSrids(t *testing.T) {
+	pg := testPostGIS()
+	table := testTable()
+	table.Srid = 910001
+	tables := map[string]*TableSpec{
+		"roads": testTableSpec(t, pg, testTable()),
+		"local": testTableSpec(t, pg, table),
+	}
+	def := database.SridDef{Srid: 910001, Proj4: testProj4}
+	for _, test := range []struct {
+		srid     int
+		defs     []database.SridDef
+		expected string
+	}{
+		{3857, []database.SridDef{def}, ""},
+		{910001, []database.SridDef{def}, ""},
+		{3857, nil, "SRID 910001 of table local is not a standard SRID and missing in CustomSrids"},
+		{910002, []database.SridDef{def}, "SRID 910002 is not a standard SRID and missing in CustomSrids"},
+		{3857, []database.SridDef{def, def}, "custom SRID 910001 defined twice"},
+		{3857, []database.SridDef{{Srid: 910001}}, "custom SRID 910001 requires proj4 or wkt"},
+		{3857, []database.SridDef{def, {Srid: 1000000, Proj4: testProj4}}, "custom SRID 1000000 not within 1 and 999999"},
+	} {
+		conf := database.Config{Srid: test.srid, CustomSrids: test.defs}
+		err := checkCustomSrids(conf, tables)
+		if test.expected == "" {
+			if err != nil {
+				t.Error("unexpected error", err)
+			}
+			continue
+		}
+		if err == nil || err.Error() != test.expected {
+			t.Errorf("expected %q, got %v", test.expected, err)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package postgis
+
+import (
+	"fmt"
+
+	"github.com/omniscale/imposm3/element"
+	"github.com/omniscale/imposm3/mapping"
+)
+
+// The tags table (Config.TagsTable) contains the osm_id and all tags of
+// each element that is inserted into any table of the mapping. Elements
+// are only inserted once, even if they match multiple tables or are
+// inserted in multiple parts (e.g. with -limitto). Only tags that are
+// cached are available, see load_all of the mapping.
+
+const (
+	tagsTableName   = "tags"
+	tagsTableHstore = "hstore"
+	tagsTableJsonb  = "jsonb"
+)
+
+// tagsTable returns the mapping of the tags table.
+func tagsTable(format string) *mapping.Table {
+	tagsType := "hstore_tags"
+	if format == tagsTableJsonb {
+		tagsType = "jsonb_tags"
+	}
+	return &mapping.Table{
+		Name: tagsTableName,
+		Type: mapping.NoneTable,
+		Fields: []*mapping.Field{
+			{Name: "osm_id", Type: "id"},
+			{Name: "tags", Type: tagsType},
+		},
+		// rows of the same element in one transaction
+		Dedup: &mapping.Dedup{},
+	}
+}
+
+// prepareTagsTable adds the spec of the tags table to the tables.
+func (pg *PostGIS) prepareTagsTable(m *mapping.Mapping) error {
+	switch pg.Config.TagsTable {
+	case "":
+		return nil
+	case tagsTableHstore, tagsTableJsonb:
+	default:
+		return fmt.Errorf("unknown tags table format '%s'", pg.Config.TagsTable)
+	}
+	if _, ok := m.Tables[tagsTableName]; ok {
+		return fmt.Errorf("tags table conflicts with table %s of the mapping", tagsTableName)
+	}
+	spec, err := NewTableSpec(pg, tagsTable(pg.Config.TagsTable))
+	if err != nil {
+		return err
+	}
+	pg.Tables[tagsTableName] = spec
+	return nil
+}
+
+// tagsRow returns the row of the element for the tags table.
+func (pg *PostGIS) tagsRow(elem *element.OSMElem) []interface{} {
+	var tags interface{}
+	if pg.Config.TagsTable == tagsTableJsonb {
+		tags = mapping.JsonbString("", elem, nil, mapping.Match{})
+	} else {
+		tags = mapping.HstoreString("", elem, nil, mapping.Match{})
+	}
+	return []interface{}{elem.Id, tags}
+}
+
+// insertTags inserts the tags of the element into the tags table, if
+// the element matched any table.
+func (pg *PostGIS) insertTags(elem *element.OSMElem, matches []mapping.Match) error {
+	if pg.Config.TagsTable == "" || len(matches) == 0 {
+		return nil
+	}
+	return pg.txRouter.Insert(tagsTableName, pg.tagsRow(elem))
+}
+
+// deleteTags deletes the tags of the element from the tags table.
+func (pg *PostGIS) deleteTags(id int64) {
+	if pg.Config.TagsTable == "" {
+		return
+	}
+	pg.txRouter.Delete(tagsTableName, id)
+}
@@ -0,0 +1,120 @@
+package postgis
+
+import (
+	"database/sql/driver"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/omniscale/imposm3/element"
+	"github.com/omniscale/imposm3/geom"
+	"github.com/omniscale/imposm3/mapping"
+)
+
+func TestTagsTableSQL(t *testing.T) {
+	pg := testPostGIS()
+	pg.Tables = make(map[string]*TableSpec)
+	pg.Config.TagsTable = "jsonb"
+	if err := pg.prepareTagsTable(selectionMapping()); err != nil {
+		t.Fatal(err)
+	}
+	spec := pg.Tables["tags"]
+	if sql := spec.CreateTableSQL(); !strings.Contains(sql, `"import"."osm_tags"`) || !strings.Contains(sql, `"tags" JSONB`) {
+		t.Error("unexpected sql", sql)
+	}
+
+	pg.Config.TagsTable = "hstore"
+	if err := pg.prepareTagsTable(selectionMapping()); err != nil {
+		t.Fatal(err)
+	}
+	if sql := pg.Tables["tags"].CreateTableSQL(); !strings.Contains(sql, `"tags" HSTORE`) {
+		t.Error("unexpected sql", sql)
+	}
+
+	pg.Config.TagsTable = "json"
+	if err := pg.prepareTagsTable(selectionMapping()); err == nil || err.Error() != "unknown tags table format 'json'" {
+		t.Error("unexpected error", err)
+	}
+	pg.Config.TagsTable = "jsonb"
+	m := selectionMapping()
+	m.Tables["tags"] = testTable()
+	if err := pg.prepareTagsTable(m); err == nil || err.Error() != "tags table conflicts with table tags of the mapping" {
+		t.Error("unexpected error", err)
+	}
+}
+
+func TestInsertTags(t *testing.T) {
+	m, err := mapping.NewMapping("test_mapping.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, d := newFakeDb()
+	pg := testPostGIS()
+	pg.Db = db
+	pg.Config.TagsTable = "jsonb"
+	pg.Tables = make(map[string]*TableSpec)
+	pg.GeneralizedTables = make(map[string]*GeneralizedTableSpec)
+	if err := pg.prepareTables(m); err != nil {
+		t.Fatal(err)
+	}
+	if err := pg.BeginBulk(); err != nil {
+		t.Fatal(err)
+	}
+
+	square := ewkbPolygon(3857, []float64{0, 0, 10, 0, 10, 10, 0, 10, 0, 0}).hex()
+	way := element.Way{
+		OSMElem: element.OSMElem{Id: 42, Tags: element.Tags{"amenity": "parking", "name": "foo"}},
+		Refs:    []int64{1, 2, 3, 4, 1},
+	}
+	matches := m.PolygonMatcher().MatchWay(&way)
+	if len(matches) == 0 {
+		t.Fatal("no matches")
+	}
+	// inserted in two parts
+	for i := 0; i < 2; i++ {
+		if err := pg.InsertPolygon(way.OSMElem, geom.Geometry{Wkb: []byte(square)}, matches); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// tags of elements without matches are not inserted
+	if err := pg.InsertPolygon(element.OSMElem{Id: 43}, geom.Geometry{Wkb: []byte(square)}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := pg.End(); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	copySQL := pg.Tables["tags"].CopySQL()
+	if ids := d.values(copySQL, 0); !reflect.DeepEqual(ids, []driver.Value{int64(42)}) {
+		t.Error("unexpected ids", ids)
+	}
+	if tags := d.values(copySQL, 1); !reflect.DeepEqual(tags, []driver.Value{`{"amenity":"parking","name":"foo"}`}) {
+		t.Error("unexpected tags", tags)
+	}
+}
+
+func TestDeleteTags(t *testing.T) {
+	db, d := newFakeDb()
+	defer db.Close()
+	pg := testPostGIS()
+	pg.Db = db
+	pg.Config.TagsTable = "hstore"
+	pg.Tables = map[string]*TableSpec{"roads": testTableSpec(t, pg, testTable())}
+	if err := pg.prepareTagsTable(selectionMapping()); err != nil {
+		t.Fatal(err)
+	}
+	if err := pg.Begin(); err != nil {
+		t.Fatal(err)
+	}
+	pg.Delete(5, []mapping.Match{{Table: mapping.DestTable{Name: "roads"}}})
+	pg.Delete(6, []mapping.Match{})
+	pg.DeleteElem(element.OSMElem{Id: -7, Tags: element.Tags{"type": "multipolygon"}})
+	if err := pg.End(); err != nil {
+		t.Fatal(err)
+	}
+	deleteSQL := pg.Tables["tags"].DeleteSQL()
+	if ids := d.values(deleteSQL, 0); !reflect.DeepEqual(ids, []driver.Value{int64(5), int64(-7)}) {
+		t.Error("unexpected deleted ids", ids)
+	}
+}
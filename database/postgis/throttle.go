@@ -0,0 +1,165 @@
+package postgis
+
+import (
+	"sync"
+	"time"
+)
+
+// With Config.MaxRowsPerSecond or Config.MaxBytesPerSecond, all inserts
+// of an import share a token bucket that limits the write rate, e.g. for
+// imports into databases with a production workload. Tables count the
+// rows and bytes of each batch and wait as soon as the batch reaches a
+// tenth of a second of the limit, and before the batch is finished
+// (before each COPY flush, commit or the end of a diff import). Bulk
+// imports with the default CopyBufferRows and CopyBufferBytes (a single
+// COPY per table) are limited while they are running, not only at the
+// commit. The bucket holds at most one second of
+// the limit, so that the rate is not exceeded after an idle period. It
+// starts empty and batches that exceed the available tokens take the
+// tokens of the following batches of all tables, so the average rate
+// stays at the limit for parallel writers.
+
+// now is replaced in tests
+var now = time.Now
+
+// throttle is the token bucket for the row and byte limits. A limit of 0
+// disables the limit. The zero value does not limit the rate.
+type throttle struct {
+	mu             sync.Mutex
+	rowsPerSecond  int
+	bytesPerSecond int
+	// available rows and bytes, negative if batches used the tokens of
+	// the following batches
+	rows  float64
+	bytes float64
+	last  time.Time
+}
+
+// setLimit changes the limits. Batches that already wait are not
+// affected.
+func (t *throttle) setLimit(rowsPerSecond, bytesPerSecond int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.refill()
+	t.rowsPerSecond = rowsPerSecond
+	t.bytesPerSecond = bytesPerSecond
+	t.rows = capTokens(t.rows, rowsPerSecond)
+	t.bytes = capTokens(t.bytes, bytesPerSecond)
+}
+
+// refill adds the tokens since the last refill.
+func (t *throttle) refill() {
+	ts := now()
+	if t.last.IsZero() {
+		t.last = ts
+		return
+	}
+	elapsed := ts.Sub(t.last).Seconds()
+	t.last = ts
+	t.rows = capTokens(t.rows+elapsed*float64(t.rowsPerSecond), t.rowsPerSecond)
+	t.bytes = capTokens(t.bytes+elapsed*float64(t.bytesPerSecond), t.bytesPerSecond)
+}
+
+// capTokens limits tokens to one second of the limit.
+func capTokens(tokens float64, limit int) float64 {
+	if limit > 0 && tokens > float64(limit) {
+		return float64(limit)
+	}
+	return tokens
+}
+
+// reserve takes the rows and bytes of a batch and returns how long the
+// batch needs to wait.
+func (t *throttle) reserve(rows, bytes int) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.rowsPerSecond <= 0 && t.bytesPerSecond <= 0 {
+		return 0
+	}
+	t.refill()
+	var wait float64
+	if t.rowsPerSecond > 0 {
+		t.rows -= float64(rows)
+		if w := -t.rows / float64(t.rowsPerSecond); w > wait {
+			wait = w
+		}
+	}
+	if t.bytesPerSecond > 0 {
+		t.bytes -= float64(bytes)
+		if w := -t.bytes / float64(t.bytesPerSecond); w > wait {
+			wait = w
+		}
+	}
+	return time.Duration(wait * float64(time.Second))
+}
+
+// throttleBatches is the number of batches per second of the limits, see
+// batchFull.
+const throttleBatches = 10
+
+// batchFull returns whether a batch with the rows and bytes reached a
+// tenth of a second of a limit and needs to wait.
+func (t *throttle) batchFull(rows, bytes int) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.rowsPerSecond > 0 && rows >= batchLimit(t.rowsPerSecond) {
+		return true
+	}
+	return t.bytesPerSecond > 0 && bytes >= batchLimit(t.bytesPerSecond)
+}
+
+func batchLimit(limit int) int {
+	if limit < throttleBatches {
+		return 1
+	}
+	return limit / throttleBatches
+}
+
+// wait waits until the limits allow the rows and bytes of a batch.
+func (t *throttle) wait(rows, bytes int) {
+	if d := t.reserve(rows, bytes); d > 0 {
+		sleep(d)
+	}
+}
+
+// batchCounter counts the rows and bytes of a batch for the throttle. It
+// is safe for concurrent use, e.g. by concurrent Inserts of a
+// syncTableTx.
+type batchCounter struct {
+	mu    sync.Mutex
+	rows  int
+	bytes int
+}
+
+// add counts the row and waits for the counted rows if the batch is
+// full.
+func (c *batchCounter) add(t *throttle, row []interface{}) {
+	c.mu.Lock()
+	c.rows += 1
+	c.bytes += rowSize(row)
+	full := t.batchFull(c.rows, c.bytes)
+	c.mu.Unlock()
+	if full {
+		c.wait(t)
+	}
+}
+
+// wait waits for the counted rows and resets the counter. The counter is
+// not locked while it waits.
+func (c *batchCounter) wait(t *throttle) {
+	c.mu.Lock()
+	rows, bytes := c.rows, c.bytes
+	c.rows = 0
+	c.bytes = 0
+	c.mu.Unlock()
+	if rows > 0 {
+		t.wait(rows, bytes)
+	}
+}
+
+// SetThrottle changes the max. rows and bytes per second of all inserts,
+// e.g. to reduce the load of an import during business hours. 0 disables
+// the limit.
+func (pg *PostGIS) SetThrottle(rowsPerSecond, bytesPerSecond int) {
+	pg.throttle.setLimit(rowsPerSecond, bytesPerSecond)
+}
@@ -0,0 +1,220 @@
+package postgis
+
+import (
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/omniscale/imposm3/mapping"
+)
+
+// fakeClock replaces now and sleep, sleep advances the clock.
+type fakeClock struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+func installFakeClock() (*fakeClock, func()) {
+	c := &fakeClock{t: time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)}
+	now = c.now
+	sleep = c.sleep
+	return c, func() {
+		now = time.Now
+		sleep = time.Sleep
+	}
+}
+
+func (c *fakeClock) now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t
+}
+
+func (c *fakeClock) sleep(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.t = c.t.Add(d)
+}
+
+func assertRate(t *testing.T, what string, n int, elapsed time.Duration, expected float64) {
+	rate := float64(n) / elapsed.Seconds()
+	if math.Abs(rate-expected)/expected > 0.02 {
+		t.Errorf("%s rate %.1f/s, expected %.1f/s", what, rate, expected)
+	}
+}
+
+func TestThrottleRows(t *testing.T) {
+	clock, restore := installFakeClock()
+	defer restore()
+
+	var th throttle
+	th.setLimit(1000, 0)
+	start := clock.now()
+	for i := 0; i < 200; i++ {
+		th.wait(250, 100000)
+	}
+	assertRate(t, "rows", 200*250, clock.now().Sub(start), 1000)
+}
+
+func TestThrottleBytes(t *testing.T) {
+	clock, restore := installFakeClock()
+	defer restore()
+
+	var th throttle
+	th.setLimit(1000000, 50000)
+	start := clock.now()
+	for i := 0; i < 100; i++ {
+		th.wait(10, 4096)
+	}
+	assertRate(t, "bytes", 100*4096, clock.now().Sub(start), 50000)
+}
+
+func TestThrottleDisabled(t *testing.T) {
+	_, restore := installFakeClock()
+	defer restore()
+	sleep = func(d time.Duration) { t.Error("unexpected sleep", d) }
+
+	var th throttle
+	th.wait(1000000, 1000000)
+	th.setLimit(0, 0)
+	th.wait(1000000, 1000000)
+}
+
+func TestThrottleSetLimit(t *testing.T) {
+	clock, restore := installFakeClock()
+	defer restore()
+
+	var th throttle
+	th.setLimit(1000, 0)
+	start := clock.now()
+	for i := 0; i < 100; i++ {
+		th.wait(100, 0)
+	}
+	assertRate(t, "rows", 100*100, clock.now().Sub(start), 1000)
+
+	th.setLimit(200, 0)
+	start = clock.now()
+	for i := 0; i < 100; i++ {
+		th.wait(100, 0)
+	}
+	assertRate(t, "rows", 100*100, clock.now().Sub(start), 200)
+}
+
+func TestThrottleIdle(t *testing.T) {
+	clock, restore := installFakeClock()
+	defer restore()
+
+	var th throttle
+	th.setLimit(1000, 0)
+	// tokens of an idle period are limited to one second
+	clock.sleep(time.Hour)
+	start := clock.now()
+	for i := 0; i < 100; i++ {
+		th.wait(100, 0)
+	}
+	assertRate(t, "rows", 100*100-1000, clock.now().Sub(start), 1000)
+}
+
+func TestThrottleParallel(t *testing.T) {
+	_, restore := installFakeClock()
+	defer restore()
+
+	// the clock is not advanced, each batch waits for all batches
+	// before it
+	var mu sync.Mutex
+	var maxWait time.Duration
+	sleep = func(d time.Duration) {
+		mu.Lock()
+		if d > maxWait {
+			maxWait = d
+		}
+		mu.Unlock()
+	}
+
+	var th throttle
+	th.setLimit(1000, 0)
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				th.wait(100, 0)
+			}
+		}()
+	}
+	wg.Wait()
+	assertRate(t, "rows", 8*50*100, maxWait, 1000)
+}
+
+func TestThrottleBulkImport(t *testing.T) {
+	clock, restore := installFakeClock()
+	defer restore()
+
+	db, d := newFakeDb()
+	defer db.Close()
+	pg := testPostGIS()
+	pg.Db = db
+	pg.SetThrottle(100, 0)
+
+	table := testTable()
+	table.CopyBuffer = &mapping.CopyBuffer{Rows: 50}
+	spec := testTableSpec(t, pg, table)
+	tt := NewBulkTableTx(pg, spec)
+	if err := tt.Begin(nil); err != nil {
+		t.Fatal(err)
+	}
+	start := clock.now()
+	geom := ewkbLineString(3857, 0, 0, 10, 10).hex()
+	for i := 0; i < 500; i++ {
+		tt.Insert([]interface{}{int64(i), geom, "name", nil})
+	}
+	if err := tt.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if d.commits != 1 {
+		t.Error("unexpected commits", d.commits)
+	}
+	assertRate(t, "rows", 500, clock.now().Sub(start), 100)
+}
+
+func TestThrottleBulkImportDefault(t *testing.T) {
+	clock, restore := installFakeClock()
+	defer restore()
+	var sleeps int
+	sleep = func(d time.Duration) {
+		sleeps += 1
+		clock.sleep(d)
+	}
+
+	db, d := newFakeDb()
+	defer db.Close()
+	pg := testPostGIS()
+	pg.Db = db
+	pg.SetThrottle(100, 0)
+
+	// single COPY without flushes
+	spec := testTableSpec(t, pg, testTable())
+	tt := NewBulkTableTx(pg, spec)
+	if err := tt.Begin(nil); err != nil {
+		t.Fatal(err)
+	}
+	start := clock.now()
+	geom := ewkbLineString(3857, 0, 0, 10, 10).hex()
+	for i := 0; i < 500; i++ {
+		tt.Insert([]interface{}{int64(i), geom, "name", nil})
+	}
+	tt.End()
+	// the import waited while the rows were inserted, every 10 rows
+	if sleeps < 49 {
+		t.Error("expected waits during the import, got", sleeps)
+	}
+	assertRate(t, "rows", 500, clock.now().Sub(start), 100)
+	if err := tt.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if d.commits != 1 {
+		t.Error("unexpected commits", d.commits)
+	}
+}
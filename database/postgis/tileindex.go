@@ -0,0 +1,102 @@
+package postgis
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/omniscale/imposm3/mapping"
+)
+
+// Tables with tile_index have an additional column that PostgreSQL
+// computes from the geometry (GENERATED ALWAYS AS ... STORED, requires
+// PostgreSQL 12). The column is added after AddGeometryColumn, as the
+// expression references the geometry column. It is not part of Columns
+// and it is never inserted by imposm.
+
+const defaultTileIndexType = "BIGINT"
+
+// TileIndexSQL returns the statement that adds the tile_index column.
+func (spec *TableSpec) TileIndexSQL() string {
+	typ := spec.TileIndex.Type
+	if typ == "" {
+		typ = defaultTileIndexType
+	}
+	return fmt.Sprintf(`ALTER TABLE "%s"."%s" ADD COLUMN "%s" %s GENERATED ALWAYS AS (%s) STORED`,
+		spec.Schema, spec.FullName, spec.TileIndex.Name, typ, spec.TileIndex.Expression)
+}
+
+// checkTileIndex returns all problems of the tile_index option.
+func checkTileIndex(spec *TableSpec, t *mapping.TileIndex) []string {
+	var problems []string
+	if t.Name == "" {
+		problems = append(problems, "tile_index requires name")
+	}
+	if strings.TrimSpace(t.Expression) == "" {
+		return append(problems, "tile_index requires expression")
+	}
+	idx := spec.geometryColumnIndex()
+	if idx < 0 {
+		return append(problems, "tile_index requires geometry column")
+	}
+	geomCol := spec.Columns[idx].Name
+	if !referencesColumn(t.Expression, geomCol) {
+		problems = append(problems, fmt.Sprintf("tile_index expression '%s' does not reference geometry column %s",
+			t.Expression, geomCol))
+	}
+	return problems
+}
+
+// referencesColumn returns whether the SQL expression contains the
+// column name as an identifier. Unquoted identifiers are case insensitive,
+// quoted identifiers need to match exactly. String literals are skipped.
+func referencesColumn(expr, column string) bool {
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		switch {
+		case c == '\'' || c == '"':
+			end := i + 1
+			for end < len(expr) {
+				if expr[end] == c {
+					if end+1 < len(expr) && expr[end+1] == c {
+						// escaped quote
+						end += 2
+						continue
+					}
+					break
+				}
+				end++
+			}
+			if c == '"' && end < len(expr) && strings.Replace(expr[i+1:end], `""`, `"`, -1) == column {
+				return true
+			}
+			i = end + 1
+		case isIdentStart(c):
+			end := i + 1
+			for end < len(expr) && isIdentPart(expr[end]) {
+				end++
+			}
+			if strings.ToLower(expr[i:end]) == column {
+				return true
+			}
+			i = end
+		case c >= '0' && c <= '9':
+			// skip numbers, e.g. 1e10
+			end := i + 1
+			for end < len(expr) && isIdentPart(expr[end]) {
+				end++
+			}
+			i = end
+		default:
+			i++
+		}
+	}
+	return false
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= 0x80
+}
+
+func isIdentPart(c byte) bool {
+	return isIdentStart(c) || (c >= '0' && c <= '9') || c == '$'
+}
@@ -0,0 +1,68 @@
+package postgis
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/omniscale/imposm3/mapping"
+)
+
+func TestTileIndexSQL(t *testing.T) {
+	table := testTable()
+	table.TileIndex = &mapping.TileIndex{Name: "quadkey", Expression: "tile_quadkey(geometry, 14)"}
+	spec := testTableSpec(t, testPostGIS(), table)
+
+	expected := `ALTER TABLE "import"."osm_roads" ADD COLUMN "quadkey" BIGINT GENERATED ALWAYS AS (tile_quadkey(geometry, 14)) STORED`
+	if sql := spec.TileIndexSQL(); sql != expected {
+		t.Errorf("unexpected SQL\n%s\n%s", sql, expected)
+	}
+	spec.TileIndex.Type = "VARCHAR"
+	if sql := spec.TileIndexSQL(); !strings.Contains(sql, `"quadkey" VARCHAR GENERATED ALWAYS`) {
+		t.Error("unexpected SQL", sql)
+	}
+
+	for _, sql := range []string{spec.CreateTableSQL(), spec.InsertSQL(), spec.CopySQL(), spec.CreateInvalidTableSQL()} {
+		if strings.Contains(sql, "quadkey") {
+			t.Error("tile_index column in", sql)
+		}
+	}
+}
+
+func TestTileIndexProblems(t *testing.T) {
+	for _, test := range []struct {
+		tileIndex mapping.TileIndex
+		problem   string
+	}{
+		{mapping.TileIndex{Name: "tile", Expression: `tile_key("geometry")`}, ""},
+		{mapping.TileIndex{Name: "tile", Expression: `tile_key(ST_Centroid(Geometry))`}, ""},
+		{mapping.TileIndex{Name: "tile", Expression: `tile_key(geom)`}, "does not reference geometry column geometry"},
+		{mapping.TileIndex{Name: "tile", Expression: `tile_key(geometry_4326)`}, "does not reference geometry column geometry"},
+		{mapping.TileIndex{Name: "tile", Expression: `tile_key("Geometry")`}, "does not reference geometry column geometry"},
+		{mapping.TileIndex{Name: "tile", Expression: `tile_key('geometry')`}, "does not reference geometry column geometry"},
+		{mapping.TileIndex{Name: "tile", Expression: " "}, "tile_index requires expression"},
+		{mapping.TileIndex{Expression: "tile_key(geometry)"}, "tile_index requires name"},
+		{mapping.TileIndex{Name: "name", Expression: "tile_key(geometry)"}, "column name defined by field name (type string, key name) and tile_index"},
+	} {
+		table := testTable()
+		table.TileIndex = &test.tileIndex
+		_, err := NewTableSpec(testPostGIS(), table)
+		if test.problem == "" {
+			if err != nil {
+				t.Error("unexpected error for", test.tileIndex.Expression, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), test.problem) {
+			t.Errorf("expected %q for %v, got %v", test.problem, test.tileIndex, err)
+		}
+	}
+}
+
+func TestTileIndexRequiresGeometry(t *testing.T) {
+	table := testTable()
+	table.Fields = append(table.Fields[:1], table.Fields[2:]...)
+	table.TileIndex = &mapping.TileIndex{Name: "tile", Expression: "tile_key(geometry)"}
+	if _, err := NewTableSpec(testPostGIS(), table); err == nil || !strings.Contains(err.Error(), "tile_index requires geometry column") {
+		t.Error("expected error for table without geometry", err)
+	}
+}
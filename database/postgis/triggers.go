@@ -0,0 +1,83 @@
+package postgis
+
+import (
+	"fmt"
+)
+
+func disableTriggersSQL(schema, table string) string {
+	return fmt.Sprintf(`ALTER TABLE "%s"."%s" DISABLE TRIGGER ALL`, schema, table)
+}
+
+func enableTriggersSQL(schema, table string) string {
+	return fmt.Sprintf(`ALTER TABLE "%s"."%s" ENABLE TRIGGER ALL`, schema, table)
+}
+
+// tableSchema returns the import schema of the table.
+func (pg *PostGIS) tableSchema(table string) string {
+	for _, spec := range pg.Tables {
+		if spec.FullName == table {
+			return spec.Schema
+		}
+	}
+	for _, spec := range pg.GeneralizedTables {
+		if spec.FullName == table {
+			return spec.Schema
+		}
+	}
+	return pg.Config.ImportSchema
+}
+
+// DisableTriggers disables all triggers of the table in its import schema.
+func (pg *PostGIS) DisableTriggers(table string) error {
+	sql := disableTriggersSQL(pg.tableSchema(table), table)
+	if _, err := pg.Db.Exec(sql); err != nil {
+		return &SQLError{sql, err}
+	}
+	return nil
+}
+
+// EnableTriggers enables all triggers of the table in its import schema.
+func (pg *PostGIS) EnableTriggers(table string) error {
+	sql := enableTriggersSQL(pg.tableSchema(table), table)
+	if _, err := pg.Db.Exec(sql); err != nil {
+		return &SQLError{sql, err}
+	}
+	return nil
+}
+
+// disableTriggersDuringLoad disables the triggers of all tables that are
+// written by the import, if enabled with Config.DisableTriggersDuringLoad.
+// Generalized tables are only updated by diff imports.
+func (pg *PostGIS) disableTriggersDuringLoad(bulkImport bool) error {
+	if !pg.Config.DisableTriggersDuringLoad {
+		return nil
+	}
+	var tables []string
+	for _, spec := range pg.Tables {
+		tables = append(tables, spec.FullName)
+	}
+	if !bulkImport {
+		for _, spec := range pg.GeneralizedTables {
+			tables = append(tables, spec.FullName)
+		}
+	}
+	for _, table := range tables {
+		if err := pg.DisableTriggers(table); err != nil {
+			return err
+		}
+		pg.disabledTriggers = append(pg.disabledTriggers, table)
+	}
+	return nil
+}
+
+// enableTriggersAfterLoad enables all triggers that were disabled by
+// disableTriggersDuringLoad.
+func (pg *PostGIS) enableTriggersAfterLoad() error {
+	for len(pg.disabledTriggers) > 0 {
+		if err := pg.EnableTriggers(pg.disabledTriggers[0]); err != nil {
+			return err
+		}
+		pg.disabledTriggers = pg.disabledTriggers[1:]
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package postgis
+
+import (
+	"testing"
+)
+
+func TestTriggersSQL(t *testing.T) {
+	if sql := disableTriggersSQL("import", "osm_roads"); sql != `ALTER TABLE "import"."osm_roads" DISABLE TRIGGER ALL` {
+		t.Error("unexpected SQL", sql)
+	}
+	if sql := enableTriggersSQL("import", "osm_roads"); sql != `ALTER TABLE "import"."osm_roads" ENABLE TRIGGER ALL` {
+		t.Error("unexpected SQL", sql)
+	}
+}
+
+func TestDisableTriggersDuringLoadNotEnabled(t *testing.T) {
+	pg := testPostGIS()
+	pg.Tables = map[string]*TableSpec{"roads": testTableSpec(t, pg, testTable())}
+	// no database required if not enabled
+	if err := pg.disableTriggersDuringLoad(true); err != nil {
+		t.Fatal(err)
+	}
+	if len(pg.disabledTriggers) != 0 {
+		t.Error("unexpected disabled triggers", pg.disabledTriggers)
+	}
+	if err := pg.enableTriggersAfterLoad(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,38 @@
+package postgis
+
+import (
+	"fmt"
+
+	"github.com/omniscale/imposm3/mapping"
+)
+
+// encodeTstzRanges returns the row with all values of tstzrange columns
+// as range literals, so that ranges can be passed as [2]time.Time or
+// string for INSERT and COPY. It returns an error for values that are
+// not a range, before PostgreSQL aborts the transaction.
+func (spec *TableSpec) encodeTstzRanges(row []interface{}) ([]interface{}, error) {
+	encoded := row
+	copied := false
+	for i, col := range spec.Columns {
+		if i >= len(row) || row[i] == nil {
+			continue
+		}
+		if _, ok := col.Type.(*tstzrangeColumnType); !ok {
+			continue
+		}
+		v, err := mapping.TstzRangeLiteral(row[i])
+		if err != nil {
+			return nil, fmt.Errorf("column %s of %s: %s", col.Name, spec.Name, err)
+		}
+		if s, ok := row[i].(string); ok && s == v {
+			continue
+		}
+		if !copied {
+			encoded = make([]interface{}, len(row))
+			copy(encoded, row)
+			copied = true
+		}
+		encoded[i] = v
+	}
+	return encoded, nil
+}
@@ -0,0 +1,93 @@
+package postgis
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/omniscale/imposm3/mapping"
+)
+
+func tstzrangeTable() *mapping.Table {
+	table := testTable()
+	table.Fields = append(table.Fields, &mapping.Field{Name: "valid", Key: "valid", Type: "tstzrange"})
+	return table
+}
+
+func TestTstzRangeSQL(t *testing.T) {
+	spec := testTableSpec(t, testPostGIS(), tstzrangeTable())
+	if sql := spec.CreateTableSQL(); !strings.Contains(sql, `"valid" TSTZRANGE`) {
+		t.Error("unexpected sql", sql)
+	}
+	if sql := spec.InsertSQL(); !strings.Contains(sql, "$5::tstzrange") {
+		t.Error("unexpected sql", sql)
+	}
+}
+
+func TestEncodeTstzRanges(t *testing.T) {
+	spec := testTableSpec(t, testPostGIS(), tstzrangeTable())
+	from := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2021, 6, 30, 12, 0, 0, 500, time.FixedZone("", 7200))
+
+	for _, tc := range []struct {
+		value    interface{}
+		expected string
+	}{
+		{[2]time.Time{from, to}, `["2020-01-01T00:00:00Z","2021-06-30T12:00:00.0000005+02:00")`},
+		{[2]time.Time{from, {}}, `["2020-01-01T00:00:00Z",)`},
+		{[2]time.Time{}, `(,)`},
+		{"[2020-01-01,2021-01-01)", "[2020-01-01,2021-01-01)"},
+		{` ("2020-01-01 10:00:00+01", infinity] `, `("2020-01-01 10:00:00+01", infinity]`},
+		{"empty", "empty"},
+	} {
+		row, err := spec.encodeTstzRanges([]interface{}{int64(1), "", "", "", tc.value})
+		if err != nil {
+			t.Errorf("unexpected error for %#v: %s", tc.value, err)
+			continue
+		}
+		if row[4] != tc.expected {
+			t.Errorf("unexpected value for %#v: %v", tc.value, row[4])
+		}
+	}
+
+	row := []interface{}{int64(1), "", "", "", "empty"}
+	if encoded, err := spec.encodeTstzRanges(row); err != nil || &encoded[0] != &row[0] {
+		t.Error("literal row copied", err)
+	}
+	for _, value := range []interface{}{
+		"2020-01-01", "[2020-01-01)", "[2020-01-01,2021-01-01,2022-01-01)", "[yesterday,today)",
+		[2]time.Time{to, from}, from, 42,
+	} {
+		if _, err := spec.encodeTstzRanges([]interface{}{int64(1), "", "", "", value}); err == nil {
+			t.Errorf("expected error for %#v", value)
+		}
+	}
+
+	args := rejectedRow{[]interface{}{int64(1), "", "", "", "[yesterday,today)"}, "error", ""}.invalidArgs(spec)
+	if args[4] != nil {
+		t.Error("invalid range for invalid table", args[4])
+	}
+}
+
+func TestInsertTstzRange(t *testing.T) {
+	db, d := newFakeDb()
+	defer db.Close()
+	pg := testPostGIS()
+	pg.Db = db
+	pg.Tables = map[string]*TableSpec{"roads": testTableSpec(t, pg, tstzrangeTable())}
+	if err := pg.Begin(); err != nil {
+		t.Fatal(err)
+	}
+	line := ewkbLineString(3857, 0, 0, 1, 1).hex()
+	valid := [2]time.Time{time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), {}}
+	if err := pg.txRouter.Insert("roads", []interface{}{int64(1), line, "", "", valid}); err != nil {
+		t.Fatal(err)
+	}
+	if err := pg.txRouter.End(); err != nil {
+		t.Fatal(err)
+	}
+	values := d.values(`INSERT INTO "import"."osm_roads"`, 4)
+	if len(values) != 1 || values[0] != `["2020-01-01T00:00:00Z",)` {
+		t.Error("unexpected values", values)
+	}
+}
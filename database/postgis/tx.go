@@ -4,8 +4,20 @@ import (
 	"database/sql"
 	"fmt"
 	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/omniscale/imposm3/database"
+	"github.com/omniscale/imposm3/mapping"
 )
 
+// TableTx inserts and deletes rows of a single table. Insert and Delete
+// can be called concurrently. Statements are prepared in Begin and owned
+// by the TableTx, there are no statements per writer goroutine: the bulk
+// TableTx passes all rows to a single goroutine that executes them, and
+// the statements of the synchronous TableTx belong to a single sql.Tx and
+// thus to a single connection. Statements per goroutine would still be
+// executed one after another on this connection.
 type TableTx interface {
 	Begin(*sql.Tx) error
 	Insert(row []interface{}) error
@@ -23,7 +35,35 @@ type bulkTableTx struct {
 	InsertStmt *sql.Stmt
 	InsertSql  string
 	wg         *sync.WaitGroup
-	rows       chan []interface{}
+	rows       chan bulkRow
+	// false if rows are inserted with INSERT instead of COPY
+	copy bool
+	// temporary table of the COPY for LoadMethodStaging
+	staging  *TableSpec
+	copyRows copyCounter
+	// rows that need ST_Subdivide, inserted after the COPY
+	subdivideRows [][]interface{}
+	// rows for the invalid table, inserted after the COPY
+	rejectedRows []rejectedRow
+	// value of the TimestampColumn for COPY
+	started time.Time
+	// begin of the current transaction, for MaxTransactionAge
+	txStarted time.Time
+	// number of inserted rows, for CopyProgress
+	inserted int64
+	// rows since the last commit, for AnalyzeEveryRows
+	uncommitted int64
+	// rows since the last flush, for the throttle
+	batch batchCounter
+	// set if the import was canceled or failed, remaining rows are
+	// ignored
+	err   error
+	ended bool
+	// set for the shards of a shardedTableTx, which creates the staging
+	// table before Begin, inserts the rejected rows and counts the
+	// inserted rows of all shards in total
+	shard bool
+	total *int64
 }
 
 func NewBulkTableTx(pg *PostGIS, spec *TableSpec) TableTx {
@@ -32,8 +72,10 @@ func NewBulkTableTx(pg *PostGIS, spec *TableSpec) TableTx {
 		Table: spec.FullName,
 		Spec:  spec,
 		wg:    &sync.WaitGroup{},
-		rows:  make(chan []interface{}, 64),
+		rows:  make(chan bulkRow, 64),
 	}
+	tt.copyRows.limit = spec.CopyBufferRows
+	tt.copyRows.byteLimit = spec.CopyBufferBytes
 	tt.wg.Add(1)
 	go tt.loop()
 	return tt
@@ -48,13 +90,32 @@ func (tt *bulkTableTx) Begin(tx *sql.Tx) error {
 		}
 	}
 	tt.Tx = tx
+	tt.started = time.Now()
+	tt.txStarted = now()
 
-	_, err = tx.Exec(fmt.Sprintf(`TRUNCATE TABLE "%s"."%s" RESTART IDENTITY`, tt.Pg.Config.ImportSchema, tt.Table))
-	if err != nil {
-		return err
+	if !tt.shard {
+		_, err = tx.Exec(fmt.Sprintf(`TRUNCATE TABLE "%s"."%s" RESTART IDENTITY`, tt.Spec.Schema, tt.Table))
+		if err != nil {
+			return err
+		}
 	}
 
-	tt.InsertSql = tt.Spec.CopySQL()
+	if tt.Spec.Upsert {
+		// COPY is not able to update existing rows
+		tt.InsertSql = tt.Spec.UpsertSQL()
+	} else if !tt.Spec.useCopy() {
+		tt.InsertSql = tt.Spec.InsertSQL()
+	} else if tt.Spec.LoadMethod == LoadMethodStaging {
+		tt.staging = tt.Spec.stagingSpec()
+		if err := tt.createStaging(); err != nil {
+			return err
+		}
+		tt.InsertSql = tt.staging.CopySQL()
+		tt.copy = true
+	} else {
+		tt.InsertSql = tt.Spec.CopySQL()
+		tt.copy = true
+	}
 
 	stmt, err := tt.Tx.Prepare(tt.InsertSql)
 	if err != nil {
@@ -65,49 +126,421 @@ func (tt *bulkTableTx) Begin(tx *sql.Tx) error {
 	return nil
 }
 
+// bulkRow is a row for the insert loop, or a Flush if flushed is set.
+type bulkRow struct {
+	row     []interface{}
+	flushed chan error
+}
+
 func (tt *bulkTableTx) Insert(row []interface{}) error {
-	tt.rows <- row
+	tt.rows <- bulkRow{row: row}
+	return nil
+}
+
+// Flush commits all rows that were inserted before the call and
+// continues with a new transaction. Flush is not allowed after End.
+func (tt *bulkTableTx) Flush() error {
+	if tt.ended {
+		return nil
+	}
+	flushed := make(chan error)
+	tt.rows <- bulkRow{flushed: flushed}
+	return <-flushed
+}
+
+// flush is Flush within the insert loop.
+func (tt *bulkTableTx) flush() error {
+	if tt.err != nil {
+		return tt.err
+	}
+	for _, row := range tt.Spec.flushRows() {
+		tt.insert(row)
+	}
+	if tt.err != nil {
+		return tt.err
+	}
+	if err := tt.endCopy(); err != nil {
+		tt.err = err
+		return err
+	}
+	tt.Pg.closeBeforeCommit(tt.InsertStmt)
+	if err := tt.Tx.Commit(); err != nil {
+		tt.Tx = nil
+		tt.err = geometryCheckError("COMMIT", err)
+		return tt.err
+	}
+	tt.Pg.analyzeCommitted(tt.Spec, tt.uncommitted)
+	tt.uncommitted = 0
+	tx, err := tt.Pg.Db.Begin()
+	if err != nil {
+		tt.Tx = nil
+		tt.err = err
+		return err
+	}
+	tt.Tx = tx
+	tt.txStarted = now()
+	if tt.staging != nil {
+		// dropped with the commit
+		if err := tt.createStaging(); err != nil {
+			tt.err = err
+			return err
+		}
+	}
+	// prepared statements are closed with the commit
+	stmt, err := tt.Tx.Prepare(tt.InsertSql)
+	if err != nil {
+		tt.err = &SQLError{tt.InsertSql, err}
+		return tt.err
+	}
+	tt.InsertStmt = stmt
 	return nil
 }
 
 func (tt *bulkTableTx) loop() {
-	for row := range tt.rows {
-		_, err := tt.InsertStmt.Exec(row...)
-		if err != nil {
-			// TODO
-			log.Fatal(&SQLInsertError{SQLError{tt.InsertSql, err}, row})
+	for item := range tt.rows {
+		if item.flushed != nil {
+			item.flushed <- tt.flush()
+			continue
+		}
+		row := item.row
+		if tt.err != nil {
+			continue
+		}
+		if tt.canceled() {
+			tt.err = database.ErrCanceled
+			continue
+		}
+		for _, row := range tt.Spec.collectRows(row) {
+			tt.insert(row)
+		}
+		if tt.expired() {
+			// sets tt.err on failure
+			tt.flush()
+		}
+	}
+	if tt.err == nil {
+		for _, row := range tt.Spec.flushRows() {
+			tt.insert(row)
 		}
 	}
 	tt.wg.Done()
 }
 
+// expired returns true if the current transaction is open for longer
+// than Config.MaxTransactionAge. The age is checked after each row, so
+// the transaction is committed with the first row after the age.
+func (tt *bulkTableTx) expired() bool {
+	age := tt.Pg.Config.MaxTransactionAge
+	return age > 0 && now().Sub(tt.txStarted) >= age
+}
+
+func (tt *bulkTableTx) insert(row []interface{}) {
+	if tt.err != nil {
+		return
+	}
+	prepare := tt.Spec.prepareRow
+	for retried := false; ; retried = true {
+		prepared, subdivide, err := tt.prepareRow(row, prepare)
+		err = sridInsertError(err, tt.InsertSql, row)
+		if isSridMismatch(err) {
+			tt.err = err
+			return
+		}
+		if err == nil {
+			if prepared != nil {
+				tt.insertPrepared(prepared, subdivide)
+			}
+			return
+		}
+		switch tt.Spec.rowErrorAction(row, err, retried) {
+		case database.RetryRow:
+			prepare = tt.Spec.convertRow
+			continue
+		case database.AbortImport:
+			tt.Spec.rows.failed()
+			tt.err = &RowAbortedError{tt.Spec.Name, err}
+		default:
+			tt.reject(row, err)
+		}
+		return
+	}
+}
+
+// prepareRow prepares the row with prepare and converts it for the COPY.
+// subdivide is true for rows that need to be inserted with ST_Subdivide.
+func (tt *bulkTableTx) prepareRow(row []interface{}, prepare func([]interface{}) ([]interface{}, error)) (prepared []interface{}, subdivide bool, err error) {
+	prepared, err = prepare(row)
+	if err != nil || prepared == nil {
+		return nil, false, err
+	}
+	if tt.Spec.exceedsSubdivide(prepared) {
+		return prepared, true, nil
+	}
+	if tt.copy {
+		prepared, err = tt.Spec.copyRow(prepared)
+		if err != nil {
+			return nil, false, err
+		}
+		prepared = tt.Spec.timestampRow(prepared, tt.started)
+	}
+	return prepared, false, nil
+}
+
+func (tt *bulkTableTx) insertPrepared(row []interface{}, subdivide bool) {
+	tt.batch.add(&tt.Pg.throttle, row)
+	tt.uncommitted += 1
+	if subdivide {
+		// COPY is not able to call ST_Subdivide
+		tt.subdivideRows = append(tt.subdivideRows, row)
+		return
+	}
+	_, err := tt.InsertStmt.Exec(row...)
+	if err != nil {
+		// TODO
+		log.Fatal(&SQLInsertError{SQLError{tt.InsertSql, err}, row})
+	}
+	tt.Spec.rows.inserted()
+	tt.progress()
+	// following rows are ignored after an error, Commit returns it
+	if tt.copy && tt.copyRows.add(rowSize(row)) {
+		if err := tt.flushCopy(); err != nil {
+			tt.err = err
+			return
+		}
+	}
+	if !tt.copy && tt.Spec.switchToCopy(tt.inserted) {
+		if err := tt.startCopy(); err != nil {
+			tt.err = err
+		}
+	}
+}
+
+// startCopy switches from INSERT to COPY for all following rows.
+func (tt *bulkTableTx) startCopy() error {
+	if err := tt.InsertStmt.Close(); err != nil {
+		return err
+	}
+	tt.InsertSql = tt.Spec.CopySQL()
+	stmt, err := tt.Tx.Prepare(tt.InsertSql)
+	if err != nil {
+		return &SQLError{tt.InsertSql, err}
+	}
+	tt.InsertStmt = stmt
+	tt.copy = true
+	return nil
+}
+
+// canceled returns true if Config.Cancel is closed.
+func (tt *bulkTableTx) canceled() bool {
+	select {
+	case <-tt.Pg.Config.Cancel:
+		return true
+	default:
+		return false
+	}
+}
+
+// progress counts the inserted row and calls Config.CopyProgress every
+// Config.CopyProgressRows rows.
+func (tt *bulkTableTx) progress() {
+	tt.inserted += 1
+	n := tt.inserted
+	if tt.total != nil {
+		n = atomic.AddInt64(tt.total, 1)
+	}
+	conf := &tt.Pg.Config
+	if conf.CopyProgress != nil && conf.CopyProgressRows > 0 && n%int64(conf.CopyProgressRows) == 0 {
+		conf.CopyProgress(tt.Spec.Name, n)
+	}
+}
+
 func (tt *bulkTableTx) Delete(id int64) error {
 	panic("unable to delete in bulkImport mode")
 }
 
 func (tt *bulkTableTx) End() {
+	if tt.ended {
+		return
+	}
+	tt.ended = true
 	close(tt.rows)
 	tt.wg.Wait()
+	if tt.Spec.dedup != nil {
+		tt.Spec.dedup.reset()
+	}
 }
 
 func (tt *bulkTableTx) Commit() error {
 	tt.End()
-	if tt.InsertStmt != nil {
+	if tt.err != nil {
+		tt.Rollback()
+		return tt.err
+	}
+	if err := tt.endCopy(); err != nil {
+		return err
+	}
+	tt.Pg.closeBeforeCommit(tt.InsertStmt)
+	err := tt.Tx.Commit()
+	// the transaction is finished, also if the commit failed
+	tt.Tx = nil
+	if err != nil {
+		return geometryCheckError("COMMIT", err)
+	}
+	return nil
+}
+
+// endCopy finishes the COPY and inserts all collected rows that
+// can't be inserted with COPY.
+func (tt *bulkTableTx) endCopy() error {
+	tt.batch.wait(&tt.Pg.throttle)
+	if tt.InsertStmt != nil && tt.copy {
+		// flush COPY
 		_, err := tt.InsertStmt.Exec()
 		if err != nil {
-			return err
+			return geometryCheckError(tt.InsertSql, err)
 		}
 	}
-	err := tt.Tx.Commit()
-	if err != nil {
+	if err := tt.insertStaged(); err != nil {
 		return err
 	}
-	tt.Tx = nil
+	if err := tt.insertSubdivided(); err != nil {
+		return err
+	}
+	if tt.shard {
+		// inserted by the shardedTableTx
+		return nil
+	}
+	if err := insertRejected(tt.Tx, tt.Spec, tt.rejectedRows); err != nil {
+		return err
+	}
+	tt.rejectedRows = nil
+	return nil
+}
+
+// createStaging creates the staging table in the current transaction.
+func (tt *bulkTableTx) createStaging() error {
+	sql := tt.staging.CreateTableSQL()
+	if _, err := tt.Tx.Exec(sql); err != nil {
+		return &SQLError{sql, err}
+	}
+	return nil
+}
+
+// insertStaged inserts the rows of the staging table and empties it for
+// the next COPY. Needs to be called after the COPY is finished.
+func (tt *bulkTableTx) insertStaged() error {
+	if tt.staging == nil {
+		return nil
+	}
+	for _, sql := range []string{
+		tt.Spec.insertFromSQL(tt.staging),
+		fmt.Sprintf(`TRUNCATE TABLE %s`, tt.staging.dialect().QualifiedName(tt.staging.Schema, tt.staging.FullName)),
+	} {
+		if _, err := tt.Tx.Exec(sql); err != nil {
+			return geometryCheckError(sql, err)
+		}
+	}
+	return nil
+}
+
+// flushCopy finishes the current COPY and starts a new one.
+func (tt *bulkTableTx) flushCopy() error {
+	if err := tt.endCopy(); err != nil {
+		return err
+	}
+	if err := tt.InsertStmt.Close(); err != nil {
+		return err
+	}
+	stmt, err := tt.Tx.Prepare(tt.InsertSql)
+	if err != nil {
+		return &SQLError{tt.InsertSql, err}
+	}
+	tt.InsertStmt = stmt
+	return nil
+}
+
+// copyCounter counts the rows and bytes of a COPY and reports when the
+// COPY needs to be flushed. A limit of 0 never flushes.
+type copyCounter struct {
+	limit     int
+	byteLimit int
+	rows      int
+	bytes     int
+}
+
+// add counts a row with size bytes and returns true if a limit is
+// reached.
+func (c *copyCounter) add(size int) bool {
+	if c.limit <= 0 && c.byteLimit <= 0 {
+		return false
+	}
+	c.rows += 1
+	c.bytes += size
+	if (c.limit <= 0 || c.rows < c.limit) && (c.byteLimit <= 0 || c.bytes < c.byteLimit) {
+		return false
+	}
+	c.rows = 0
+	c.bytes = 0
+	return true
+}
+
+// rowSize returns the approximate size of the row in a COPY.
+func rowSize(row []interface{}) int {
+	size := 0
+	for _, v := range row {
+		switch v := v.(type) {
+		case string:
+			size += len(v)
+		case []byte:
+			size += len(v)
+		default:
+			size += 8
+		}
+	}
+	return size
+}
+
+// reject logs rows that are skipped and collects them for the invalid
+// table. COPY does not allow other statements, so they are inserted
+// after the COPY is finished.
+func (tt *bulkTableTx) reject(row []interface{}, err error) {
+	log.Warn(err)
+	tt.Spec.rows.failed()
+	if tt.Spec.InvalidTable {
+		tt.rejectedRows = append(tt.rejectedRows, rejectedRow{row, rejectReason(err), err.Error()})
+	}
+}
+
+// insertSubdivided inserts all collected rows with ST_Subdivide.
+// Needs to be called after the COPY is finished.
+func (tt *bulkTableTx) insertSubdivided() error {
+	if len(tt.subdivideRows) == 0 {
+		return nil
+	}
+	sql := tt.Spec.SubdivideInsertSQL()
+	stmt, err := tt.Tx.Prepare(sql)
+	if err != nil {
+		return &SQLError{sql, err}
+	}
+	defer stmt.Close()
+	for _, row := range tt.subdivideRows {
+		if _, err := stmt.Exec(row...); err != nil {
+			return &SQLInsertError{SQLError{sql, err}, row}
+		}
+		tt.Spec.rows.inserted()
+	}
+	tt.subdivideRows = nil
 	return nil
 }
 
 func (tt *bulkTableTx) Rollback() {
+	tt.End()
+	if tt.Tx != nil && tt.InsertStmt != nil {
+		// finish COPY before the rollback
+		tt.InsertStmt.Close()
+	}
 	rollbackIfTx(&tt.Tx)
+	tt.Tx = nil
 }
 
 type syncTableTx struct {
@@ -119,6 +552,17 @@ type syncTableTx struct {
 	DeleteStmt *sql.Stmt
 	InsertSql  string
 	DeleteSql  string
+	// nil for generalized tables
+	tableSpec     *TableSpec
+	SubdivideStmt *sql.Stmt
+	SubdivideSql  string
+	InvalidStmt   *sql.Stmt
+	InvalidSql    string
+	// only prepared for tables with an osm_type column
+	DeleteTypeStmt *sql.Stmt
+	DeleteTypeSql  string
+	// rows since Begin, for the throttle
+	batch batchCounter
 }
 
 type tableSpec interface {
@@ -146,6 +590,9 @@ func (tt *syncTableTx) Begin(tx *sql.Tx) error {
 	tt.Tx = tx
 
 	tt.InsertSql = tt.Spec.InsertSQL()
+	if spec, ok := tt.Spec.(*TableSpec); ok && spec.Upsert {
+		tt.InsertSql = spec.UpsertSQL()
+	}
 
 	stmt, err := tt.Tx.Prepare(tt.InsertSql)
 	if err != nil {
@@ -160,26 +607,202 @@ func (tt *syncTableTx) Begin(tx *sql.Tx) error {
 	}
 	tt.DeleteStmt = stmt
 
+	if spec, ok := tt.Spec.(*TableSpec); ok {
+		tt.tableSpec = spec
+		if spec.Subdivide > 0 {
+			tt.SubdivideSql = spec.SubdivideInsertSQL()
+			stmt, err = tt.Tx.Prepare(tt.SubdivideSql)
+			if err != nil {
+				return &SQLError{tt.SubdivideSql, err}
+			}
+			tt.SubdivideStmt = stmt
+		}
+		if spec.InvalidTable {
+			tt.InvalidSql = spec.InsertInvalidSQL()
+			stmt, err = tt.Tx.Prepare(tt.InvalidSql)
+			if err != nil {
+				return &SQLError{tt.InvalidSql, err}
+			}
+			tt.InvalidStmt = stmt
+		}
+		if sql := spec.DeleteTypeSQL(); sql != "" {
+			tt.DeleteTypeSql = sql
+			stmt, err = tt.Tx.Prepare(tt.DeleteTypeSql)
+			if err != nil {
+				return &SQLError{tt.DeleteTypeSql, err}
+			}
+			tt.DeleteTypeStmt = stmt
+		}
+	}
+
 	return nil
 }
 
 func (tt *syncTableTx) Insert(row []interface{}) error {
+	if tt.tableSpec == nil {
+		return tt.insert(row)
+	}
+	for _, row := range tt.tableSpec.collectRows(row) {
+		if err := tt.insert(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (tt *syncTableTx) insert(row []interface{}) error {
+	_, err := tt.insertRow(row)
+	return err
+}
+
+// insertRow inserts the row and returns whether it was inserted, or
+// skipped or rejected.
+func (tt *syncTableTx) insertRow(row []interface{}) (bool, error) {
+	if tt.tableSpec == nil {
+		return true, tt.exec(row)
+	}
+	prepare := tt.tableSpec.prepareRow
+	for retried := false; ; retried = true {
+		prepared, err := prepare(row)
+		err = sridInsertError(err, tt.InsertSql, row)
+		if isSridMismatch(err) {
+			tt.countFailed()
+			return false, err
+		}
+		if err == nil {
+			if prepared == nil {
+				return false, nil
+			}
+			if err := tt.exec(prepared); err != nil {
+				return false, err
+			}
+			return true, nil
+		}
+		switch tt.tableSpec.rowErrorAction(row, err, retried) {
+		case database.RetryRow:
+			prepare = tt.tableSpec.convertRow
+			continue
+		case database.AbortImport:
+			tt.countFailed()
+			return false, &RowAbortedError{tt.tableSpec.Name, err}
+		default:
+			return false, tt.reject(row, err)
+		}
+	}
+}
+
+func (tt *syncTableTx) exec(row []interface{}) error {
+	tt.batch.add(&tt.Pg.throttle, row)
+	if tt.SubdivideStmt != nil && tt.tableSpec.exceedsSubdivide(row) {
+		_, err := tt.SubdivideStmt.Exec(row...)
+		if err != nil {
+			tt.countFailed()
+			return &SQLInsertError{SQLError{tt.SubdivideSql, err}, row}
+		}
+		tt.countInserted()
+		return nil
+	}
 	_, err := tt.InsertStmt.Exec(row...)
 	if err != nil {
+		tt.countFailed()
 		return &SQLInsertError{SQLError{tt.InsertSql, err}, row}
 	}
+	tt.countInserted()
+	return nil
+}
+
+// countInserted counts the row for TableSpecs, generalized tables are
+// not counted.
+func (tt *syncTableTx) countInserted() {
+	if tt.tableSpec != nil {
+		tt.tableSpec.rows.inserted()
+	}
+}
+
+func (tt *syncTableTx) countFailed() {
+	if tt.tableSpec != nil {
+		tt.tableSpec.rows.failed()
+	}
+}
+
+// reject logs rows that are skipped and inserts them into the invalid
+// table, if enabled.
+func (tt *syncTableTx) reject(row []interface{}, err error) error {
+	log.Warn(err)
+	tt.countFailed()
+	if tt.InvalidStmt == nil {
+		return nil
+	}
+	args := rejectedRow{row, rejectReason(err), err.Error()}.invalidArgs(tt.tableSpec)
+	if _, err := tt.InvalidStmt.Exec(args...); err != nil {
+		return &SQLInsertError{SQLError{tt.InvalidSql, err}, args}
+	}
 	return nil
 }
 
 func (tt *syncTableTx) Delete(id int64) error {
-	_, err := tt.DeleteStmt.Exec(id)
+	_, err := tt.delete(id)
+	return err
+}
+
+// DeleteType deletes the rows of the id with the osm_type of the
+// element. All rows of the id are deleted for tables without osm_type
+// column and for unknown types.
+func (tt *syncTableTx) DeleteType(id int64, osmType mapping.OsmType) error {
+	_, err := tt.deleteType(id, osmType)
+	return err
+}
+
+// delete deletes all rows of the id and returns the number of deleted
+// rows.
+func (tt *syncTableTx) delete(id int64) (int64, error) {
+	return tt.deleteType(id, mapping.OsmTypeUnknown)
+}
+
+// deleteType deletes the rows of the id and osmType (see DeleteType) and
+// returns the number of deleted rows.
+func (tt *syncTableTx) deleteType(id int64, osmType mapping.OsmType) (int64, error) {
+	if tt.tableSpec != nil && tt.tableSpec.dedup != nil && tt.tableSpec.dedup.keepLast {
+		// the pending rows would be inserted after this delete
+		tt.tableSpec.dedup.forget(id)
+	}
+	var res sql.Result
+	var err error
+	if tt.DeleteTypeStmt != nil && osmType != mapping.OsmTypeUnknown {
+		t := tt.tableSpec.osmTypeValue(osmType)
+		res, err = tt.DeleteTypeStmt.Exec(id, t)
+		if err != nil {
+			return 0, &SQLInsertError{SQLError{tt.DeleteTypeSql, err}, []interface{}{id, t}}
+		}
+	} else {
+		res, err = tt.DeleteStmt.Exec(id)
+		if err != nil {
+			return 0, &SQLInsertError{SQLError{tt.DeleteSql, err}, id}
+		}
+	}
+	n, err := res.RowsAffected()
 	if err != nil {
-		return &SQLInsertError{SQLError{tt.DeleteSql, err}, id}
+		return 0, err
 	}
-	return nil
+	return n, nil
 }
 
 func (tt *syncTableTx) End() {
+	defer tt.batch.wait(&tt.Pg.throttle)
+	if tt.tableSpec == nil {
+		return
+	}
+	for _, row := range tt.tableSpec.flushRows() {
+		if err := tt.insert(row); err != nil {
+			// the transaction is aborted and fails on commit
+			log.Warn(err)
+			break
+		}
+	}
+	if tt.tableSpec.dedup != nil {
+		tt.tableSpec.dedup.reset()
+	}
+	tt.Pg.closeBeforeCommit(tt.InsertStmt, tt.DeleteStmt, tt.SubdivideStmt, tt.InvalidStmt, tt.DeleteTypeStmt)
 }
 
 func (tt *syncTableTx) Commit() error {
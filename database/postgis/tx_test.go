@@ -0,0 +1,473 @@
+package postgis
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/omniscale/imposm3/database"
+	"github.com/omniscale/imposm3/mapping"
+)
+
+func TestCopyCounter(t *testing.T) {
+	c := copyCounter{limit: 3}
+	var flushed []int
+	for i := 1; i <= 10; i++ {
+		if c.add(0) {
+			flushed = append(flushed, i)
+		}
+	}
+	if len(flushed) != 3 || flushed[0] != 3 || flushed[1] != 6 || flushed[2] != 9 {
+		t.Error("unexpected flushes", flushed)
+	}
+}
+
+func TestCopyCounterDisabled(t *testing.T) {
+	c := copyCounter{}
+	for i := 0; i < 1000; i++ {
+		if c.add(0) {
+			t.Fatal("flushed without limit")
+		}
+	}
+}
+
+func TestCopyCounterBytes(t *testing.T) {
+	c := copyCounter{limit: 100, byteLimit: 250}
+	var flushed []int
+	for i := 1; i <= 10; i++ {
+		if c.add(100) {
+			flushed = append(flushed, i)
+		}
+	}
+	if len(flushed) != 3 || flushed[0] != 3 || flushed[1] != 6 || flushed[2] != 9 {
+		t.Error("unexpected flushes", flushed)
+	}
+}
+
+func TestCopyBufferPerTable(t *testing.T) {
+	pg := testPostGIS()
+	pg.Config.CopyBufferRows = 4
+	pg.Config.CopyBufferBytes = 1000
+	roads := testTable()
+	roads.CopyBuffer = &mapping.CopyBuffer{Rows: 2}
+	buildings := testTable()
+	buildings.Name = "buildings"
+	buildings.CopyBuffer = &mapping.CopyBuffer{Bytes: 90}
+	pois := testTable()
+	pois.Name = "pois"
+	pg.Tables = map[string]*TableSpec{
+		"roads":     testTableSpec(t, pg, roads),
+		"buildings": testTableSpec(t, pg, buildings),
+		"pois":      testTableSpec(t, pg, pois),
+	}
+
+	settings := pg.CopyBufferSettings()
+	if s := settings["roads"]; s.Rows != 2 || s.Bytes != 1000 {
+		t.Error("unexpected settings", s)
+	}
+	if s := settings["buildings"]; s.Rows != 4 || s.Bytes != 90 {
+		t.Error("unexpected settings", s)
+	}
+	if s := settings["pois"]; s.Rows != 4 || s.Bytes != 1000 {
+		t.Error("unexpected settings", s)
+	}
+
+	counters := make(map[string]*copyCounter)
+	for name, spec := range pg.Tables {
+		counters[name] = &copyCounter{limit: spec.CopyBufferRows, byteLimit: spec.CopyBufferBytes}
+	}
+	// single producer with rows of 30 bytes for all tables
+	row := []interface{}{int64(1), strings.Repeat("x", 22), "", ""}
+	flushes := make(map[string]int)
+	for i := 0; i < 12; i++ {
+		for name, c := range counters {
+			if c.add(rowSize(row)) {
+				flushes[name] += 1
+			}
+		}
+	}
+	if flushes["roads"] != 6 || flushes["buildings"] != 4 || flushes["pois"] != 3 {
+		t.Error("unexpected flushes", flushes)
+	}
+}
+
+func TestBulkCopyProgress(t *testing.T) {
+	db, d := newFakeDb()
+	defer db.Close()
+	pg := testPostGIS()
+	pg.Db = db
+	pg.Config.CopyProgressRows = 3
+	var progress []int64
+	pg.Config.CopyProgress = func(table string, rows int64) {
+		if table != "roads" {
+			t.Error("unexpected table", table)
+		}
+		progress = append(progress, rows)
+	}
+	spec := testTableSpec(t, pg, testTable())
+
+	tt := NewBulkTableTx(pg, spec)
+	if err := tt.Begin(nil); err != nil {
+		t.Fatal(err)
+	}
+	line := ewkbLineString(3857, 0, 0, 1, 1).hex()
+	for i := 0; i < 10; i++ {
+		tt.Insert([]interface{}{int64(i), line, "", ""})
+	}
+	if err := tt.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if len(progress) != 3 || progress[0] != 3 || progress[2] != 9 {
+		t.Error("unexpected progress", progress)
+	}
+	if n := d.count("COPY"); n != 11 { // 10 rows and end of COPY
+		t.Error("unexpected COPY execs", n)
+	}
+	if d.commits != 1 || d.rollbacks != 0 {
+		t.Error("unexpected commits/rollbacks", d.commits, d.rollbacks)
+	}
+}
+
+func TestBulkCopyCancel(t *testing.T) {
+	db, d := newFakeDb()
+	defer db.Close()
+	pg := testPostGIS()
+	pg.Db = db
+	cancel := make(chan struct{})
+	pg.Config.Cancel = cancel
+	pg.Config.CopyProgressRows = 1
+	pg.Config.CopyProgress = func(table string, rows int64) {
+		if rows == 5 {
+			close(cancel)
+		}
+	}
+	spec := testTableSpec(t, pg, testTable())
+
+	tt := NewBulkTableTx(pg, spec)
+	if err := tt.Begin(nil); err != nil {
+		t.Fatal(err)
+	}
+	line := ewkbLineString(3857, 0, 0, 1, 1).hex()
+	for i := 0; i < 100; i++ {
+		// does not block after cancel
+		tt.Insert([]interface{}{int64(i), line, "", ""})
+	}
+	if err := tt.Commit(); err != database.ErrCanceled {
+		t.Fatal("expected ErrCanceled", err)
+	}
+	if n := d.count("COPY"); n != 5 {
+		t.Error("unexpected COPY execs", n)
+	}
+	if d.commits != 0 || d.rollbacks != 1 {
+		t.Error("unexpected commits/rollbacks", d.commits, d.rollbacks)
+	}
+	// Abort after failed End
+	tt.Rollback()
+	if d.rollbacks != 1 {
+		t.Error("unexpected rollbacks", d.rollbacks)
+	}
+}
+
+func TestBulkCopyFlushError(t *testing.T) {
+	db, d := newFakeDb()
+	defer db.Close()
+	pg := testPostGIS()
+	pg.Db = db
+	spec := testTableSpec(t, pg, testTable())
+	spec.CopyBufferRows = 2
+	spec.Subdivide = 2
+	// inserted after the COPY is flushed
+	d.fail = "INSERT INTO"
+
+	tt := NewBulkTableTx(pg, spec)
+	if err := tt.Begin(nil); err != nil {
+		t.Fatal(err)
+	}
+	line := ewkbLineString(3857, 0, 0, 1, 1).hex()
+	tt.Insert([]interface{}{int64(1), ewkbLineString(3857, 0, 0, 1, 1, 2, 2).hex(), "", ""})
+	for i := 0; i < 10; i++ {
+		// ignored after the failed flush
+		tt.Insert([]interface{}{int64(i), line, "", ""})
+	}
+	err := tt.Commit()
+	if err == nil || !strings.Contains(err.Error(), "ST_Subdivide") {
+		t.Fatal("expected error of flush", err)
+	}
+	if n := d.count("COPY"); n != 3 { // two rows and the end of COPY
+		t.Error("unexpected COPY execs", n)
+	}
+	if d.commits != 0 || d.rollbacks != 1 {
+		t.Error("unexpected commits/rollbacks", d.commits, d.rollbacks)
+	}
+}
+
+func TestBulkMaxTransactionAge(t *testing.T) {
+	clock, restore := installFakeClock()
+	defer restore()
+	db, d := newFakeDb()
+	defer db.Close()
+	pg := testPostGIS()
+	pg.Db = db
+	pg.Config.MaxTransactionAge = time.Minute
+	// a row every 10 seconds, the clock advances in the insert loop
+	var committed []int64
+	commits := 0
+	pg.Config.CopyProgressRows = 1
+	pg.Config.CopyProgress = func(table string, rows int64) {
+		if d.commits != commits {
+			committed = append(committed, rows)
+			commits = d.commits
+		}
+		clock.sleep(10 * time.Second)
+	}
+	spec := testTableSpec(t, pg, testTable())
+
+	tt := NewBulkTableTx(pg, spec)
+	if err := tt.Begin(nil); err != nil {
+		t.Fatal(err)
+	}
+	line := ewkbLineString(3857, 0, 0, 1, 1).hex()
+	for i := 0; i < 30; i++ {
+		tt.Insert([]interface{}{int64(i), line, "", ""})
+	}
+	if err := tt.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	// committed after the 6th row of each transaction
+	if !reflect.DeepEqual(committed, []int64{7, 13, 19, 25}) {
+		t.Error("unexpected commits before rows", committed)
+	}
+	if d.commits != 6 || d.rollbacks != 0 {
+		t.Error("unexpected commits/rollbacks", d.commits, d.rollbacks)
+	}
+	// the COPY is prepared again after each commit
+	if n := d.count("COPY"); n != 36 { // 30 rows and end of COPY for each transaction
+		t.Error("unexpected COPY execs", n)
+	}
+}
+
+func TestMaxTransactionAgeIgnoredForDiff(t *testing.T) {
+	clock, restore := installFakeClock()
+	defer restore()
+	db, d := newFakeDb()
+	defer db.Close()
+	pg := testPostGIS()
+	pg.Db = db
+	pg.Config.MaxTransactionAge = time.Minute
+	pg.Tables = map[string]*TableSpec{"roads": testTableSpec(t, pg, testTable())}
+	if err := pg.Begin(); err != nil {
+		t.Fatal(err)
+	}
+	line := ewkbLineString(3857, 0, 0, 1, 1).hex()
+	for i := 0; i < 10; i++ {
+		pg.txRouter.Insert("roads", []interface{}{int64(i), line, "", ""})
+		clock.sleep(time.Minute)
+	}
+	if err := pg.txRouter.End(); err != nil {
+		t.Fatal(err)
+	}
+	if d.commits != 1 {
+		t.Error("unexpected commits", d.commits)
+	}
+}
+
+func testFlushPostGIS(t *testing.T, db *sql.DB) *PostGIS {
+	pg := testPostGIS()
+	pg.Db = db
+	buildings := testTable()
+	buildings.Name = "buildings"
+	pg.Tables = map[string]*TableSpec{
+		"roads":     testTableSpec(t, pg, testTable()),
+		"buildings": testTableSpec(t, pg, buildings),
+	}
+	return pg
+}
+
+func TestBulkFlush(t *testing.T) {
+	db, d := newFakeDb()
+	defer db.Close()
+	pg := testFlushPostGIS(t, db)
+	if err := pg.BeginBulk(); err != nil {
+		t.Fatal(err)
+	}
+	line := ewkbLineString(3857, 0, 0, 1, 1).hex()
+	for i := 0; i < 10; i++ {
+		pg.txRouter.Insert("roads", []interface{}{int64(i), line, "", ""})
+	}
+	if err := pg.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if d.commits != 2 {
+		t.Error("unexpected commits", d.commits)
+	}
+	if n := pg.Tables["roads"].rows.load().Inserted; n != 10 {
+		t.Error("unexpected inserted rows", n)
+	}
+	// repeated Flush without new rows
+	if err := pg.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		pg.txRouter.Insert("buildings", []interface{}{int64(i), line, "", ""})
+	}
+	if err := pg.txRouter.End(); err != nil {
+		t.Fatal(err)
+	}
+	if d.commits != 6 || d.rollbacks != 0 {
+		t.Error("unexpected commits/rollbacks", d.commits, d.rollbacks)
+	}
+	// tables are only truncated in Begin
+	if n := d.count("TRUNCATE"); n != 2 {
+		t.Error("unexpected TRUNCATE", n)
+	}
+	// Flush after End
+	if err := pg.Flush(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBulkFlushConcurrent(t *testing.T) {
+	db, _ := newFakeDb()
+	defer db.Close()
+	pg := testFlushPostGIS(t, db)
+	if err := pg.BeginBulk(); err != nil {
+		t.Fatal(err)
+	}
+	line := ewkbLineString(3857, 0, 0, 1, 1).hex()
+	var wg sync.WaitGroup
+	for _, table := range []string{"roads", "buildings"} {
+		wg.Add(1)
+		go func(table string) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				pg.txRouter.Insert(table, []interface{}{int64(i), line, "", ""})
+			}
+		}(table)
+	}
+	for i := 0; i < 10; i++ {
+		if err := pg.Flush(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wg.Wait()
+	if err := pg.txRouter.End(); err != nil {
+		t.Fatal(err)
+	}
+	for name, spec := range pg.Tables {
+		if n := spec.rows.load().Inserted; n != 1000 {
+			t.Error("unexpected inserted rows", name, n)
+		}
+	}
+}
+
+func TestBulkFlushError(t *testing.T) {
+	db, _ := newFakeDb()
+	defer db.Close()
+	pg := testFlushPostGIS(t, db)
+	cancel := make(chan struct{})
+	pg.Config.Cancel = cancel
+	if err := pg.BeginBulk(); err != nil {
+		t.Fatal(err)
+	}
+	close(cancel)
+	line := ewkbLineString(3857, 0, 0, 1, 1).hex()
+	pg.txRouter.Insert("roads", []interface{}{int64(1), line, "", ""})
+
+	err := pg.Flush()
+	flushErr, ok := err.(*FlushError)
+	if !ok {
+		t.Fatal("expected FlushError", err)
+	}
+	if flushErr.Err != database.ErrCanceled || !reflect.DeepEqual(flushErr.Tables, []string{"roads"}) {
+		t.Error("unexpected error", flushErr)
+	}
+	pg.txRouter.Abort()
+}
+
+func TestDiffFlush(t *testing.T) {
+	db, d := newFakeDb()
+	defer db.Close()
+	pg := testFlushPostGIS(t, db)
+	if err := pg.Begin(); err != nil {
+		t.Fatal(err)
+	}
+	line := ewkbLineString(3857, 0, 0, 1, 1).hex()
+	if err := pg.txRouter.Insert("roads", []interface{}{int64(1), line, "", ""}); err != nil {
+		t.Fatal(err)
+	}
+	if err := pg.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if d.commits != 1 {
+		t.Error("unexpected commits", d.commits)
+	}
+	// inserts use the new transaction
+	if err := pg.txRouter.Insert("roads", []interface{}{int64(2), line, "", ""}); err != nil {
+		t.Fatal(err)
+	}
+	if err := pg.txRouter.End(); err != nil {
+		t.Fatal(err)
+	}
+	if d.commits != 2 {
+		t.Error("unexpected commits", d.commits)
+	}
+	if args := d.values("INSERT", 0); !reflect.DeepEqual(args, []driver.Value{int64(1), int64(2)}) {
+		t.Error("unexpected inserts", args)
+	}
+}
+
+func TestCloseFlush(t *testing.T) {
+	db, d := newFakeDb()
+	pg := testFlushPostGIS(t, db)
+	if err := pg.BeginBulk(); err != nil {
+		t.Fatal(err)
+	}
+	line := ewkbLineString(3857, 0, 0, 1, 1).hex()
+	pg.txRouter.Insert("roads", []interface{}{int64(1), line, "", ""})
+	if err := pg.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if d.commits != 2 {
+		t.Error("unexpected commits", d.commits)
+	}
+}
+
+func TestSyncTableTxConcurrentInserts(t *testing.T) {
+	db, d := newFakeDb()
+	defer db.Close()
+	pg := testPostGIS()
+	pg.Db = db
+	pg.SetThrottle(1000000, 0)
+	spec := testTableSpec(t, pg, testTable())
+
+	tt := NewSynchronousTableTx(pg, spec.Name, spec)
+	if err := tt.Begin(nil); err != nil {
+		t.Fatal(err)
+	}
+	line := ewkbLineString(3857, 0, 0, 1, 1).hex()
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				if err := tt.Insert([]interface{}{int64(w*1000 + i), line, "", ""}); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	tt.End()
+	if err := tt.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if n := d.count(`INSERT INTO "import"."osm_roads"`); n != 400 {
+		t.Error("unexpected inserts", n)
+	}
+}
@@ -87,6 +87,9 @@ func dropTableIfExists(tx *sql.Tx, schema, table string) error {
 	var void interface{}
 	err = row.Scan(&void)
 	if err != nil {
+		if isDependentObjectsStillExist(err) {
+			return &ReferencedTableError{schema, table, &SQLError{sqlStmt, err}}
+		}
 		return &SQLError{sqlStmt, err}
 	}
 	return nil
@@ -130,6 +133,20 @@ func (p *workerPool) workerLoop() {
 	p.wg.Done()
 }
 
+// waitAll waits till all functions returned and returns all errors.
+func (p *workerPool) waitAll() []error {
+	close(p.in)
+	p.wg.Wait()
+	close(p.out)
+	var errs []error
+	for err := range p.out {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
 func (p *workerPool) wait() error {
 	close(p.in)
 	done := make(chan bool)
@@ -0,0 +1,69 @@
+package postgis
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/omniscale/imposm3/mapping"
+)
+
+type testUUID [16]byte
+
+func uuidTable() *mapping.Table {
+	table := testTable()
+	table.Fields = append(table.Fields, &mapping.Field{Name: "ref", Key: "ref", Type: "uuid"})
+	return table
+}
+
+func TestUuidSQL(t *testing.T) {
+	spec := testTableSpec(t, testPostGIS(), uuidTable())
+	if sql := spec.CreateTableSQL(); !strings.Contains(sql, `"ref" UUID`) {
+		t.Error("unexpected sql", sql)
+	}
+	if sql := spec.InsertSQL(); !strings.Contains(sql, "$5::uuid") {
+		t.Error("unexpected sql", sql)
+	}
+}
+
+func TestEncodeUuids(t *testing.T) {
+	spec := testTableSpec(t, testPostGIS(), uuidTable())
+	canonical := "0a1b2c3d-4e5f-6071-8293-a4b5c6d7e8f9"
+	b := [16]byte{0x0a, 0x1b, 0x2c, 0x3d, 0x4e, 0x5f, 0x60, 0x71, 0x82, 0x93, 0xa4, 0xb5, 0xc6, 0xd7, 0xe8, 0xf9}
+
+	for _, value := range []interface{}{
+		canonical,
+		"0A1B2C3D-4E5F-6071-8293-A4B5C6D7E8F9",
+		"{0a1b2c3d4e5f60718293a4b5c6d7e8f9}",
+		"urn:uuid:0a1b2c3d-4e5f-6071-8293-a4b5c6d7e8f9",
+		b,
+		b[:],
+		testUUID(b),
+	} {
+		row, err := spec.encodeUuids([]interface{}{int64(1), "", "", "", value})
+		if err != nil {
+			t.Errorf("unexpected error for %#v: %s", value, err)
+			continue
+		}
+		if row[4] != canonical {
+			t.Errorf("unexpected value for %#v: %v", value, row[4])
+		}
+	}
+
+	row := []interface{}{int64(1), "", "", "", canonical}
+	if encoded, err := spec.encodeUuids(row); err != nil || &encoded[0] != &row[0] {
+		t.Error("canonical row copied", err)
+	}
+	if encoded, err := spec.encodeUuids([]interface{}{int64(1), "", "", "", nil}); err != nil || encoded[4] != nil {
+		t.Error("unexpected NULL value", encoded, err)
+	}
+	for _, value := range []interface{}{"0a1b2c3d", "not-a-uuid", 42} {
+		if _, err := spec.encodeUuids([]interface{}{int64(1), "", "", "", value}); err == nil {
+			t.Errorf("expected error for %#v", value)
+		}
+	}
+
+	args := rejectedRow{[]interface{}{int64(1), "", "", "", "not-a-uuid"}, "error", ""}.invalidArgs(spec)
+	if args[4] != nil {
+		t.Error("invalid UUID for invalid table", args[4])
+	}
+}
@@ -0,0 +1,60 @@
+package postgis
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// VacuumFull rewrites tables to reclaim the space of updated and deleted
+// rows, e.g. after many diff imports with upserts. VACUUM FULL can't run
+// in a transaction, so it is executed directly on pg.Db. It takes an
+// exclusive lock on the table, queries of the table wait till it is
+// finished.
+
+var errVacuumDuringImport = errors.New("unable to VACUUM FULL during an import, the import holds locks on the tables")
+
+func vacuumFullSQL(schema, table string) string {
+	return fmt.Sprintf(`VACUUM FULL "%s"."%s"`, schema, table)
+}
+
+// VacuumFull runs VACUUM FULL on the table (by name, also generalized
+// tables) in its schema. It returns an error during an import, as the
+// exclusive lock would wait for the transaction of the import.
+func (pg *PostGIS) VacuumFull(table string) error {
+	if txr := pg.txRouter; txr != nil && !txr.ended {
+		return errVacuumDuringImport
+	}
+	var schema, name string
+	if spec, ok := pg.Tables[table]; ok {
+		schema, name = spec.Schema, spec.FullName
+	} else if spec, ok := pg.GeneralizedTables[table]; ok {
+		schema, name = spec.Schema, spec.FullName
+	} else {
+		return fmt.Errorf("unknown table %s", table)
+	}
+	defer log.StopStep(log.StartStep(fmt.Sprintf("Vacuuming %s", name)))
+	sql := vacuumFullSQL(schema, name)
+	if _, err := pg.Db.Exec(sql); err != nil {
+		return &SQLError{sql, err}
+	}
+	return nil
+}
+
+// VacuumAll runs VacuumFull on all tables, one after another.
+func (pg *PostGIS) VacuumAll() error {
+	var tables []string
+	for name := range pg.Tables {
+		tables = append(tables, name)
+	}
+	for name := range pg.GeneralizedTables {
+		tables = append(tables, name)
+	}
+	sort.Strings(tables)
+	for _, table := range tables {
+		if err := pg.VacuumFull(table); err != nil {
+			return err
+		}
+	}
+	return nil
+}
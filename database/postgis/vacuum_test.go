@@ -0,0 +1,60 @@
+package postgis
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestVacuumFullSQL(t *testing.T) {
+	if sql := vacuumFullSQL("production", "osm_roads"); sql != `VACUUM FULL "production"."osm_roads"` {
+		t.Error("unexpected SQL", sql)
+	}
+}
+
+func TestVacuumAll(t *testing.T) {
+	db, d := newFakeDb()
+	defer db.Close()
+	pg := testFlushPostGIS(t, db)
+	pg.GeneralizedTables = map[string]*GeneralizedTableSpec{
+		"roads_gen0": {Name: "roads_gen0", FullName: "osm_roads_gen0", Schema: "import", Source: pg.Tables["roads"]},
+	}
+	if err := pg.VacuumAll(); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		`VACUUM FULL "import"."osm_buildings"`,
+		`VACUUM FULL "import"."osm_roads"`,
+		`VACUUM FULL "import"."osm_roads_gen0"`,
+	}
+	if !reflect.DeepEqual(d.execs, expected) {
+		t.Errorf("unexpected statements %v", d.execs)
+	}
+	// outside of a transaction
+	if d.commits != 0 || d.rollbacks != 0 {
+		t.Error("unexpected transaction", d.commits, d.rollbacks)
+	}
+	if err := pg.VacuumFull("unknown"); err == nil {
+		t.Error("expected error for unknown table")
+	}
+}
+
+func TestVacuumFullDuringImport(t *testing.T) {
+	db, d := newFakeDb()
+	defer db.Close()
+	pg := testFlushPostGIS(t, db)
+	if err := pg.Begin(); err != nil {
+		t.Fatal(err)
+	}
+	if err := pg.VacuumFull("roads"); err != errVacuumDuringImport {
+		t.Error("unexpected error", err)
+	}
+	if err := pg.txRouter.End(); err != nil {
+		t.Fatal(err)
+	}
+	if err := pg.VacuumFull("roads"); err != nil {
+		t.Fatal(err)
+	}
+	if n := d.count("VACUUM FULL"); n != 1 {
+		t.Error("unexpected VACUUM", n)
+	}
+}
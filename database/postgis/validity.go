@@ -0,0 +1,96 @@
+package postgis
+
+import (
+	"fmt"
+	"strings"
+
+	pq "github.com/lib/pq"
+)
+
+// Tables with geometry_check reject invalid geometries in the database.
+// immediate adds a CHECK constraint, so that each invalid row fails on
+// insert. CHECK constraints are not deferrable, deferred uses a constraint
+// trigger instead, so that invalid rows fail on commit. Both raise a
+// check_violation error with the name of the constraint.
+
+const (
+	geometryCheckImmediate = "immediate"
+	geometryCheckDeferred  = "deferred"
+)
+
+const geometryCheckSuffix = "_geometry_valid"
+
+const geometryCheckFunction = "imposm_check_geometry"
+
+func (spec *TableSpec) geometryCheckName() string {
+	return spec.FullName + geometryCheckSuffix
+}
+
+// GeometryCheckSQL returns the statements that add the geometry_check
+// constraint of the table.
+func (spec *TableSpec) GeometryCheckSQL() []string {
+	if spec.GeometryCheck == geometryCheckImmediate {
+		if spec.DeferConstraintValidation {
+			// added by Finish
+			return nil
+		}
+		return []string{spec.checkConstraints()[0].addSQL(spec, false)}
+	}
+
+	geomCol := spec.Columns[spec.geometryColumnIndex()].Name
+
+	args := []string{"'" + geomCol + "'"}
+	if idx := spec.idColumnIndex(); idx >= 0 {
+		args = append(args, "'"+spec.Columns[idx].Name+"'")
+	}
+	return []string{
+		geometryCheckFunctionSQL(spec.Schema),
+		fmt.Sprintf(`CREATE CONSTRAINT TRIGGER "%s" AFTER INSERT OR UPDATE ON "%s"."%s" DEFERRABLE INITIALLY DEFERRED FOR EACH ROW EXECUTE PROCEDURE "%s"."%s"(%s)`,
+			spec.geometryCheckName(), spec.Schema, spec.FullName,
+			spec.Schema, geometryCheckFunction, strings.Join(args, ", ")),
+	}
+}
+
+// geometryCheckFunctionSQL returns the trigger function for deferred
+// geometry checks. The arguments are the name of the geometry column and
+// the optional name of the id column for the error message.
+func geometryCheckFunctionSQL(schema string) string {
+	return fmt.Sprintf(`CREATE OR REPLACE FUNCTION "%s"."%s"() RETURNS TRIGGER AS $$
+DECLARE
+    valid BOOLEAN;
+    id TEXT;
+BEGIN
+    EXECUTE format('SELECT ST_IsValid($1.%%I)', TG_ARGV[0]) INTO valid USING NEW;
+    IF NOT valid THEN
+        IF TG_NARGS > 1 THEN
+            EXECUTE format('SELECT $1.%%I::TEXT', TG_ARGV[1]) INTO id USING NEW;
+        END IF;
+        RAISE EXCEPTION 'invalid geometry of %% in %%', coalesce(id, 'row'), TG_TABLE_NAME
+            USING ERRCODE = 'check_violation', CONSTRAINT = TG_NAME;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql`, schema, geometryCheckFunction)
+}
+
+// isGeometryCheckViolation returns whether err was raised by a
+// geometry_check constraint.
+func isGeometryCheckViolation(err error) bool {
+	pqErr, ok := err.(*pq.Error)
+	if !ok {
+		return false
+	}
+	return pqErr.Code == "23514" && strings.HasSuffix(pqErr.Constraint, geometryCheckSuffix)
+}
+
+// geometryCheckError returns a *SQLInsertError for violations of the
+// geometry_check constraint, with the message of the violation (i.e.
+// the id and table of the invalid row) as data. Other errors are
+// returned unchanged.
+func geometryCheckError(query string, err error) error {
+	if !isGeometryCheckViolation(err) {
+		return err
+	}
+	pqErr := err.(*pq.Error)
+	return &SQLInsertError{SQLError{query, err}, pqErr.Message}
+}
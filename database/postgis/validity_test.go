@@ -0,0 +1,82 @@
+package postgis
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	pq "github.com/lib/pq"
+)
+
+func TestGeometryCheckSQLImmediate(t *testing.T) {
+	table := testTable()
+	table.GeometryCheck = "immediate"
+	spec := testTableSpec(t, testPostGIS(), table)
+
+	sql := spec.GeometryCheckSQL()
+	expected := `ALTER TABLE "import"."osm_roads" ADD CONSTRAINT "osm_roads_geometry_valid" CHECK (ST_IsValid("geometry"))`
+	if len(sql) != 1 || sql[0] != expected {
+		t.Errorf("unexpected SQL\n%v\n%s", sql, expected)
+	}
+}
+
+func TestGeometryCheckSQLDeferred(t *testing.T) {
+	table := testTable()
+	table.GeometryCheck = "deferred"
+	spec := testTableSpec(t, testPostGIS(), table)
+
+	sql := spec.GeometryCheckSQL()
+	if len(sql) != 2 {
+		t.Fatal("unexpected SQL", sql)
+	}
+	if !strings.HasPrefix(sql[0], `CREATE OR REPLACE FUNCTION "import"."imposm_check_geometry"() RETURNS TRIGGER`) {
+		t.Error("unexpected function SQL", sql[0])
+	}
+	if strings.Contains(sql[0], "%!") {
+		t.Error("invalid format in function SQL", sql[0])
+	}
+	if !strings.Contains(sql[0], `RAISE EXCEPTION 'invalid geometry of % in %'`) {
+		t.Error("unexpected function SQL", sql[0])
+	}
+	expected := `CREATE CONSTRAINT TRIGGER "osm_roads_geometry_valid" AFTER INSERT OR UPDATE ON "import"."osm_roads" DEFERRABLE INITIALLY DEFERRED FOR EACH ROW EXECUTE PROCEDURE "import"."imposm_check_geometry"('geometry', 'osm_id')`
+	if sql[1] != expected {
+		t.Errorf("unexpected SQL\n%s\n%s", sql[1], expected)
+	}
+}
+
+func TestGeometryCheckUnknown(t *testing.T) {
+	table := testTable()
+	table.GeometryCheck = "always"
+	if _, err := NewTableSpec(testPostGIS(), table); err == nil {
+		t.Error("expected error for unknown geometry_check")
+	}
+}
+
+func TestGeometryCheckError(t *testing.T) {
+	violation := &pq.Error{
+		Code:       "23514",
+		Message:    "invalid geometry of 42 in osm_roads",
+		Constraint: "osm_roads_geometry_valid",
+	}
+	err := geometryCheckError("COMMIT", violation)
+	insertErr, ok := err.(*SQLInsertError)
+	if !ok {
+		t.Fatal("expected SQLInsertError", err)
+	}
+	if insertErr.data != "invalid geometry of 42 in osm_roads" {
+		t.Error("unexpected data", insertErr.data)
+	}
+	if !strings.Contains(err.Error(), "COMMIT") {
+		t.Error("query missing in error", err)
+	}
+
+	// other check constraints and errors are not mapped
+	other := &pq.Error{Code: "23514", Constraint: "osm_roads_name_check"}
+	if err := geometryCheckError("COMMIT", other); err != error(other) {
+		t.Error("unexpected mapping", err)
+	}
+	plain := errors.New("connection lost")
+	if err := geometryCheckError("COMMIT", plain); err != plain {
+		t.Error("unexpected mapping", err)
+	}
+}
@@ -0,0 +1,58 @@
+package postgis
+
+import (
+	"fmt"
+	"strings"
+)
+
+// splitViewName returns the schema and the name of the view. Views
+// without schema are in the production schema.
+func (pg *PostGIS) splitViewName(view string) (string, string) {
+	if parts := strings.SplitN(view, ".", 2); len(parts) == 2 {
+		return parts[0], parts[1]
+	}
+	return pg.Config.ProductionSchema, view
+}
+
+// registerViewGeometrySQL returns the INSERT for the geometry_columns
+// table of PostGIS 1.x. The entry is only inserted if it does not exist.
+func registerViewGeometrySQL(schema, view, column, geomType string, srid int) string {
+	return fmt.Sprintf(`INSERT INTO geometry_columns (f_table_catalog, f_table_schema, f_table_name, f_geometry_column, coord_dimension, srid, type) `+
+		`SELECT '', '%[1]s', '%[2]s', '%[3]s', 2, %[4]d, '%[5]s' `+
+		`WHERE NOT EXISTS (SELECT 1 FROM geometry_columns WHERE f_table_schema = '%[1]s' AND f_table_name = '%[2]s' AND f_geometry_column = '%[3]s')`,
+		schema, view, column, srid, strings.ToUpper(geomType))
+}
+
+// geometryColumnsIsTable returns true if geometry_columns is a table
+// (PostGIS 1.x) and not a view (PostGIS 2).
+func (pg *PostGIS) geometryColumnsIsTable() (bool, error) {
+	var relkind string
+	sql := `SELECT relkind FROM pg_class WHERE relname = 'geometry_columns' AND pg_table_is_visible(oid)`
+	if err := pg.Db.QueryRow(sql).Scan(&relkind); err != nil {
+		return false, &SQLError{sql, err}
+	}
+	return relkind == "r", nil
+}
+
+// RegisterViewGeometry registers the geometry column of a view
+// (optionally with schema, e.g. public.roads_view) in geometry_columns.
+// This is only required for PostGIS 1.x, where geometry_columns is a
+// table. PostGIS 2 lists the columns of views automatically, with the
+// SRID and type if the column is casted in the view (e.g.
+// geometry::geometry(LineString, 3857)). RegisterViewGeometry does
+// nothing for PostGIS 2.
+func (pg *PostGIS) RegisterViewGeometry(view, column, geomType string, srid int) error {
+	isTable, err := pg.geometryColumnsIsTable()
+	if err != nil {
+		return err
+	}
+	if !isTable {
+		return nil
+	}
+	schema, name := pg.splitViewName(view)
+	sql := registerViewGeometrySQL(schema, name, column, geomType, srid)
+	if _, err := pg.Db.Exec(sql); err != nil {
+		return &SQLError{sql, err}
+	}
+	return nil
+}
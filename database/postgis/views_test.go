@@ -0,0 +1,26 @@
+package postgis
+
+import (
+	"testing"
+)
+
+func TestRegisterViewGeometrySQL(t *testing.T) {
+	sql := registerViewGeometrySQL("public", "osm_roads_view", "geometry", "linestring", 3857)
+	expected := `INSERT INTO geometry_columns (f_table_catalog, f_table_schema, f_table_name, f_geometry_column, coord_dimension, srid, type) ` +
+		`SELECT '', 'public', 'osm_roads_view', 'geometry', 2, 3857, 'LINESTRING' ` +
+		`WHERE NOT EXISTS (SELECT 1 FROM geometry_columns WHERE f_table_schema = 'public' AND f_table_name = 'osm_roads_view' AND f_geometry_column = 'geometry')`
+	if sql != expected {
+		t.Errorf("unexpected SQL\n%s\n%s", sql, expected)
+	}
+}
+
+func TestSplitViewName(t *testing.T) {
+	pg := testPostGIS()
+	pg.Config.ProductionSchema = "public"
+	if schema, view := pg.splitViewName("roads_view"); schema != "public" || view != "roads_view" {
+		t.Error("unexpected view", schema, view)
+	}
+	if schema, view := pg.splitViewName("maps.roads_view"); schema != "maps" || view != "roads_view" {
+		t.Error("unexpected view", schema, view)
+	}
+}
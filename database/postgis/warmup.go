@@ -0,0 +1,34 @@
+package postgis
+
+import "database/sql"
+
+// Warmup opens n connections to the database and keeps them in the idle
+// pool of pg.Db, so that the first inserts of the import don't need to
+// connect. All connections are opened at the same time, as database/sql
+// would reuse a single connection otherwise. Each connection is checked
+// with BEGIN, like Ping checks a single connection.
+func (pg *PostGIS) Warmup(n int) error {
+	if n < 1 {
+		return nil
+	}
+	// the default keeps only two idle connections
+	if n > pg.warmConns {
+		pg.Db.SetMaxIdleConns(n)
+		pg.warmConns = n
+	}
+
+	txs := make([]*sql.Tx, 0, n)
+	defer func() {
+		for _, tx := range txs {
+			tx.Rollback()
+		}
+	}()
+	for i := 0; i < n; i++ {
+		tx, err := pg.Db.Begin()
+		if err != nil {
+			return err
+		}
+		txs = append(txs, tx)
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package postgis
+
+import "testing"
+
+func TestWarmup(t *testing.T) {
+	db, d := newFakeDb()
+	defer db.Close()
+	pg := testPostGIS()
+	pg.Db = db
+
+	if err := pg.Warmup(5); err != nil {
+		t.Fatal(err)
+	}
+	stats := db.Stats()
+	if stats.OpenConnections != 5 {
+		t.Error("unexpected open connections", stats.OpenConnections)
+	}
+	if stats.Idle != 5 {
+		t.Error("unexpected idle connections", stats.Idle)
+	}
+	if d.rollbacks != 5 {
+		t.Error("unexpected rollbacks", d.rollbacks)
+	}
+
+	// connections are reused
+	if err := pg.Warmup(3); err != nil {
+		t.Fatal(err)
+	}
+	if stats := db.Stats(); stats.OpenConnections != 5 {
+		t.Error("unexpected open connections", stats.OpenConnections)
+	}
+}
@@ -0,0 +1,384 @@
+package postgis
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// With Config.GeometryEncoding wkt, geometries are passed as WKT strings.
+// INSERT converts them with ST_GeomFromText. COPY is not able to call
+// functions, so the WKT is converted to hex encoded EWKB before the COPY
+// (see copyRow). Only the geometry type of the WKT is checked before the
+// insert, all other errors are reported by the parser for COPY or by
+// PostGIS for INSERT.
+
+const GeometryEncodingWkt = "wkt"
+
+var wktTypes = map[string]uint32{
+	"POINT":              wkbPoint,
+	"LINESTRING":         wkbLineString,
+	"POLYGON":            wkbPolygon,
+	"MULTIPOINT":         wkbMultiPoint,
+	"MULTILINESTRING":    wkbMultiLineString,
+	"MULTIPOLYGON":       wkbMultiPolygon,
+	"GEOMETRYCOLLECTION": wkbGeometryCollection,
+}
+
+// WktTypeError is returned for WKT geometries that are not allowed in
+// the geometry column of the table.
+type WktTypeError struct {
+	Table     string
+	Type      string
+	TableType string
+}
+
+func (e *WktTypeError) Error() string {
+	return fmt.Sprintf("WKT geometry for %s is %s, but table type is %s", e.Table, e.Type, e.TableType)
+}
+
+// wktType returns the leading geometry type token of the WKT.
+func wktType(wkt string) string {
+	wkt = strings.TrimSpace(wkt)
+	end := 0
+	for end < len(wkt) && isIdentPart(wkt[end]) {
+		end++
+	}
+	return strings.ToUpper(wkt[:end])
+}
+
+// wktTypeAllowed returns whether geometries of the WKT type can be
+// inserted into tables of the geometry type (see geometryColumnType).
+// Tables with a geometry subtype also accept multi geometries.
+func wktTypeAllowed(tableType, subtype string, geomType uint32) bool {
+	switch tableType {
+	case "point":
+		return geomType == wkbPoint || (subtype != "" && geomType == wkbMultiPoint)
+	case "linestring":
+		return geomType == wkbLineString || (subtype != "" && geomType == wkbMultiLineString)
+	case "polygon":
+		return geomType == wkbPolygon || geomType == wkbMultiPolygon
+	}
+	return true
+}
+
+// checkWkt returns an error if the WKT geometry of the row does not match
+// the geometry type of the table.
+func (spec *TableSpec) checkWkt(row []interface{}) error {
+	if spec.GeometryEncoding != GeometryEncodingWkt {
+		return nil
+	}
+	idx := spec.geometryColumnIndex()
+	if idx < 0 || idx >= len(row) || row[idx] == nil {
+		return nil
+	}
+	wkt, ok := row[idx].(string)
+	if !ok {
+		return fmt.Errorf("WKT geometry for %s is %T, not a string", spec.Name, row[idx])
+	}
+	typ := wktType(wkt)
+	geomType, ok := wktTypes[typ]
+	if !ok {
+		return fmt.Errorf("WKT geometry for %s has unknown type '%s'", spec.Name, typ)
+	}
+	if !wktTypeAllowed(spec.GeometryType, spec.GeometrySubtype, geomType) {
+		return &WktTypeError{spec.Name, typ, spec.GeometryType}
+	}
+	return nil
+}
+
+// wktHexEwkb returns the WKT geometry as hex encoded EWKB with srid.
+func wktHexEwkb(value interface{}, srid int) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+	wkt, ok := value.(string)
+	if !ok {
+		return nil, fmt.Errorf("WKT geometry is %T, not a string", value)
+	}
+	g, err := parseWkt(wkt)
+	if err != nil {
+		return nil, err
+	}
+	g.srid = srid
+	return g.hexEwkb(), nil
+}
+
+// wktParser parses WKT into a wkbGeometry.
+type wktParser struct {
+	wkt string
+	pos int
+}
+
+// parseWkt parses the WKT geometry. Z and M coordinates are detected from
+// the dimension (Z, M or ZM) or from the number of coordinate values.
+func parseWkt(wkt string) (*wkbGeometry, error) {
+	p := &wktParser{wkt: wkt}
+	g, err := p.geometry()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.next(); tok != "" {
+		return nil, p.errorf("unexpected '%s' after geometry", tok)
+	}
+	return g, nil
+}
+
+func (p *wktParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("invalid WKT at position %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
+
+// next returns the next token: a word, a number, or one of ( ) ,.
+func (p *wktParser) next() string {
+	for p.pos < len(p.wkt) && (p.wkt[p.pos] == ' ' || p.wkt[p.pos] == '\t' || p.wkt[p.pos] == '\n' || p.wkt[p.pos] == '\r') {
+		p.pos++
+	}
+	if p.pos >= len(p.wkt) {
+		return ""
+	}
+	start := p.pos
+	switch c := p.wkt[p.pos]; {
+	case c == '(' || c == ')' || c == ',':
+		p.pos++
+	case isIdentStart(c):
+		for p.pos < len(p.wkt) && isIdentPart(p.wkt[p.pos]) {
+			p.pos++
+		}
+	default:
+		for p.pos < len(p.wkt) && strings.IndexByte("0123456789+-.eE", p.wkt[p.pos]) >= 0 {
+			p.pos++
+		}
+		if p.pos == start {
+			p.pos++
+		}
+	}
+	return p.wkt[start:p.pos]
+}
+
+func (p *wktParser) peek() string {
+	pos := p.pos
+	tok := p.next()
+	p.pos = pos
+	return tok
+}
+
+func (p *wktParser) expect(tok string) error {
+	if t := p.next(); t != tok {
+		return p.errorf("expected '%s', found '%s'", tok, t)
+	}
+	return nil
+}
+
+// dims parses the optional Z, M or ZM after the geometry type.
+func (p *wktParser) dims(g *wkbGeometry) {
+	switch strings.ToUpper(p.peek()) {
+	case "Z":
+		g.hasZ = true
+	case "M":
+		g.hasM = true
+	case "ZM":
+		g.hasZ, g.hasM = true, true
+	default:
+		return
+	}
+	p.next()
+}
+
+// geometry parses a tagged geometry, e.g. POINT (1 2).
+func (p *wktParser) geometry() (*wkbGeometry, error) {
+	tok := strings.ToUpper(p.next())
+	geomType, ok := wktTypes[tok]
+	if !ok {
+		return nil, p.errorf("unknown geometry type '%s'", tok)
+	}
+	g := &wkbGeometry{geomType: geomType, srid: -1}
+	p.dims(g)
+	d := &wktDims{g: g, fixed: g.hasZ || g.hasM}
+	if strings.ToUpper(p.peek()) == "EMPTY" {
+		p.next()
+		switch geomType {
+		case wkbPoint:
+			g.rings = [][]float64{nanCoords(g.dims())}
+		case wkbLineString:
+			g.rings = [][]float64{{}}
+		}
+		return g, nil
+	}
+	var err error
+	switch geomType {
+	case wkbPoint:
+		var coords []float64
+		coords, err = p.coordList(d)
+		if err == nil && len(coords) != g.dims() {
+			err = p.errorf("point requires one coordinate")
+		}
+		g.rings = [][]float64{coords}
+	case wkbLineString:
+		var coords []float64
+		coords, err = p.coordList(d)
+		g.rings = [][]float64{coords}
+	case wkbPolygon:
+		g.rings, err = p.rings(d)
+	case wkbGeometryCollection:
+		err = p.list(func() error {
+			part, err := p.geometry()
+			if err != nil {
+				return err
+			}
+			g.parts = append(g.parts, part)
+			return nil
+		}, func() {
+			if !d.fixed {
+				g.hasZ, g.hasM = g.parts[0].hasZ, g.parts[0].hasM
+			}
+		})
+	default:
+		err = p.multi(d)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// multi parses the parts of MULTIPOINT, MULTILINESTRING and MULTIPOLYGON.
+func (p *wktParser) multi(d *wktDims) error {
+	g := d.g
+	partType := g.geomType - 3
+	return p.list(func() error {
+		part := &wkbGeometry{geomType: partType, srid: -1}
+		if strings.ToUpper(p.peek()) == "EMPTY" {
+			p.next()
+			// dimensions are set after all parts are parsed
+			if partType == wkbPoint {
+				part.rings = [][]float64{nil}
+			} else if partType == wkbLineString {
+				part.rings = [][]float64{{}}
+			}
+			g.parts = append(g.parts, part)
+			return nil
+		}
+		var err error
+		switch partType {
+		case wkbPoint:
+			var coords []float64
+			if p.peek() == "(" {
+				coords, err = p.coordList(d)
+			} else {
+				// MULTIPOINT (1 2, 3 4) without parentheses
+				coords, err = p.coord(d)
+			}
+			if err == nil && len(coords) != g.dims() {
+				err = p.errorf("point requires one coordinate")
+			}
+			part.rings = [][]float64{coords}
+		case wkbLineString:
+			var coords []float64
+			coords, err = p.coordList(d)
+			part.rings = [][]float64{coords}
+		case wkbPolygon:
+			part.rings, err = p.rings(d)
+		}
+		g.parts = append(g.parts, part)
+		return err
+	}, func() {
+		for _, part := range g.parts {
+			part.hasZ, part.hasM = g.hasZ, g.hasM
+			if partType == wkbPoint && part.rings[0] == nil {
+				part.rings[0] = nanCoords(g.dims())
+			}
+		}
+	})
+}
+
+// list parses ( item, item, ... ) and calls done after the list.
+func (p *wktParser) list(item func() error, done ...func()) error {
+	if err := p.expect("("); err != nil {
+		return err
+	}
+	for {
+		if err := item(); err != nil {
+			return err
+		}
+		tok := p.next()
+		if tok == ")" {
+			break
+		}
+		if tok != "," {
+			return p.errorf("expected ',' or ')', found '%s'", tok)
+		}
+	}
+	for _, f := range done {
+		f()
+	}
+	return nil
+}
+
+// rings parses the rings of a polygon.
+func (p *wktParser) rings(d *wktDims) ([][]float64, error) {
+	var rings [][]float64
+	err := p.list(func() error {
+		coords, err := p.coordList(d)
+		rings = append(rings, coords)
+		return err
+	})
+	return rings, err
+}
+
+// coordList parses ( x y, x y, ... ).
+func (p *wktParser) coordList(d *wktDims) ([]float64, error) {
+	var coords []float64
+	err := p.list(func() error {
+		c, err := p.coord(d)
+		coords = append(coords, c...)
+		return err
+	})
+	return coords, err
+}
+
+// wktDims are the dimensions of a geometry and its parts. The first
+// coordinate sets the dimensions, if they are not fixed by Z, M or ZM.
+type wktDims struct {
+	g     *wkbGeometry
+	fixed bool
+}
+
+// coord parses a single coordinate.
+func (p *wktParser) coord(d *wktDims) ([]float64, error) {
+	g := d.g
+	var values []float64
+	for {
+		tok := p.peek()
+		if tok == "" || tok == "," || tok == ")" || tok == "(" {
+			break
+		}
+		p.next()
+		v, err := strconv.ParseFloat(tok, 64)
+		if err != nil {
+			return nil, p.errorf("invalid number '%s'", tok)
+		}
+		values = append(values, v)
+	}
+	if !d.fixed {
+		switch len(values) {
+		case 3:
+			g.hasZ = true
+		case 4:
+			g.hasZ, g.hasM = true, true
+		}
+		d.fixed = true
+	}
+	if len(values) != g.dims() {
+		return nil, p.errorf("expected %d coordinate values, found %d", g.dims(), len(values))
+	}
+	return values, nil
+}
+
+func nanCoords(dims int) []float64 {
+	coords := make([]float64, dims)
+	for i := range coords {
+		coords[i] = math.NaN()
+	}
+	return coords
+}
@@ -0,0 +1,164 @@
+package postgis
+
+import (
+	"encoding/hex"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestParseWkt(t *testing.T) {
+	for _, test := range []struct {
+		wkt      string
+		expected string
+	}{
+		{"POINT (1 2)", ewkbPoint(3857, 1, 2).hex()},
+		{"point(1 2)", ewkbPoint(3857, 1, 2).hex()},
+		{"LINESTRING (0 0, 1 1)", ewkbLineString(3857, 0, 0, 1, 1).hex()},
+		{"POLYGON ((0 0, 1 0, 1 1, 0 0))", ewkbPolygon(3857, []float64{0, 0, 1, 0, 1, 1, 0, 0}).hex()},
+		{"POLYGON((0 0,1e1 0,10 -1.5,0 0))", ewkbPolygon(3857, []float64{0, 0, 10, 0, 10, -1.5, 0, 0}).hex()},
+	} {
+		ewkb, err := wktHexEwkb(test.wkt, 3857)
+		if err != nil {
+			t.Errorf("unexpected error for %s: %s", test.wkt, err)
+			continue
+		}
+		if ewkb != test.expected {
+			t.Errorf("unexpected EWKB for %s: %s", test.wkt, ewkb)
+		}
+	}
+}
+
+func TestParseWktRoundTrip(t *testing.T) {
+	for _, test := range []struct {
+		wkt        string
+		geomType   uint32
+		parts      int
+		points     int
+		hasZ, hasM bool
+	}{
+		{"POINT Z (1 2 3)", wkbPoint, 0, 1, true, false},
+		{"POINT (1 2 3)", wkbPoint, 0, 1, true, false},
+		{"POINT ZM (1 2 3 4)", wkbPoint, 0, 1, true, true},
+		{"POINT M (1 2 4)", wkbPoint, 0, 1, false, true},
+		{"LINESTRING (0 0, 1 1, 2 0)", wkbLineString, 0, 3, false, false},
+		{"LINESTRING EMPTY", wkbLineString, 0, 0, false, false},
+		{"POLYGON ((0 0, 4 0, 4 4, 0 0), (1 1, 2 1, 2 2, 1 1))", wkbPolygon, 0, 8, false, false},
+		{"MULTIPOINT ((0 0), (1 1))", wkbMultiPoint, 2, 2, false, false},
+		{"MULTIPOINT (0 0, 1 1, 2 2)", wkbMultiPoint, 3, 3, false, false},
+		{"MULTILINESTRING ((0 0, 1 1), (2 2, 3 3, 4 4))", wkbMultiLineString, 2, 5, false, false},
+		{"MULTIPOLYGON (((0 0, 1 0, 1 1, 0 0)), ((5 5, 6 5, 6 6, 5 5)))", wkbMultiPolygon, 2, 8, false, false},
+		{"MULTIPOLYGON Z (((0 0 1, 1 0 1, 1 1 1, 0 0 1)))", wkbMultiPolygon, 1, 4, true, false},
+		{"GEOMETRYCOLLECTION (POINT (1 2), LINESTRING (0 0, 1 1), POLYGON ((0 0, 1 0, 1 1, 0 0)))", wkbGeometryCollection, 3, 7, false, false},
+		{"GEOMETRYCOLLECTION (GEOMETRYCOLLECTION (POINT (1 2)), MULTIPOINT ((3 4)))", wkbGeometryCollection, 2, 2, false, false},
+		{"GEOMETRYCOLLECTION EMPTY", wkbGeometryCollection, 0, 0, false, false},
+	} {
+		ewkb, err := wktHexEwkb(test.wkt, 4326)
+		if err != nil {
+			t.Errorf("unexpected error for %s: %s", test.wkt, err)
+			continue
+		}
+		wkb, _ := hex.DecodeString(ewkb.(string))
+		g, err := decodeWkb(wkb)
+		if err != nil {
+			t.Errorf("unable to decode EWKB for %s: %s", test.wkt, err)
+			continue
+		}
+		if g.geomType != test.geomType || len(g.parts) != test.parts || g.numPoints() != test.points ||
+			g.hasZ != test.hasZ || g.hasM != test.hasM || g.srid != 4326 {
+			t.Errorf("unexpected geometry for %s: %+v", test.wkt, g)
+		}
+		if g.hexEwkb() != ewkb {
+			t.Errorf("EWKB of %s changed after decoding", test.wkt)
+		}
+	}
+}
+
+func TestParseWktEmptyPoint(t *testing.T) {
+	g, err := parseWkt("POINT EMPTY")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(g.rings) != 1 || !math.IsNaN(g.rings[0][0]) || !math.IsNaN(g.rings[0][1]) {
+		t.Error("unexpected empty point", g.rings)
+	}
+}
+
+func TestParseWktInvalid(t *testing.T) {
+	for _, wkt := range []string{
+		"",
+		"CIRCLE (0 0)",
+		"POINT (1)",
+		"POINT (1 2, 3 4)",
+		"POINT (a b)",
+		"LINESTRING (0 0, 1 1",
+		"LINESTRING (0 0, 1 1 1)",
+		"LINESTRING (0 0, 1 1))",
+		"POLYGON (0 0, 1 0, 1 1, 0 0)",
+		"MULTIPOINT ((0 0) (1 1))",
+		"GEOMETRYCOLLECTION (0 0)",
+	} {
+		if _, err := parseWkt(wkt); err == nil {
+			t.Errorf("expected error for %q", wkt)
+		}
+	}
+}
+
+func TestWktEncoding(t *testing.T) {
+	pg := testPostGIS()
+	pg.Config.GeometryEncoding = GeometryEncodingWkt
+	spec := testTableSpec(t, pg, testTable())
+	if sql := spec.InsertSQL(); !strings.Contains(sql, "ST_GeomFromText($2, 3857)") {
+		t.Error("unexpected sql", sql)
+	}
+
+	row := []interface{}{int64(1), "LINESTRING (0 0, 1 1)", "", ""}
+	if err := spec.checkWkt(row); err != nil {
+		t.Error(err)
+	}
+	copied, err := spec.copyRow(row)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if copied[1] != ewkbLineString(3857, 0, 0, 1, 1).hex() || row[1] != "LINESTRING (0 0, 1 1)" {
+		t.Error("unexpected COPY geometry", copied[1])
+	}
+
+	err = spec.checkWkt([]interface{}{int64(1), " multilinestring ((0 0, 1 1))", "", ""})
+	if err == nil || err.Error() != "WKT geometry for roads is MULTILINESTRING, but table type is linestring" {
+		t.Error("unexpected error", err)
+	}
+	if err := spec.checkWkt([]interface{}{int64(1), "0102000020", "", ""}); err == nil {
+		t.Error("expected error for WKB")
+	}
+
+	args := rejectedRow{[]interface{}{int64(1), "LINESTRING (0 0", "", ""}, "error", ""}.invalidArgs(spec)
+	if args[1] != nil {
+		t.Error("invalid WKT for invalid table", args[1])
+	}
+
+	table := testTable()
+	table.Type = "polygon"
+	spec = testTableSpec(t, pg, table)
+	for _, wkt := range []string{"POLYGON ((0 0, 1 0, 1 1, 0 0))", "MULTIPOLYGON (((0 0, 1 0, 1 1, 0 0)))"} {
+		if err := spec.checkWkt([]interface{}{int64(1), wkt, "", ""}); err != nil {
+			t.Error(err)
+		}
+	}
+	table.Type = "geometry"
+	spec = testTableSpec(t, pg, table)
+	if err := spec.checkWkt([]interface{}{int64(1), "GEOMETRYCOLLECTION (POINT (1 2))", "", ""}); err != nil {
+		t.Error(err)
+	}
+
+	table = testTable()
+	table.MaxVertices = 100
+	if _, err := NewTableSpec(pg, table); err == nil || !strings.Contains(err.Error(), "not supported with geometry encoding wkt") {
+		t.Error("unexpected error", err)
+	}
+
+	pg.Config.Srid = 0
+	if err := pg.prepareTables(selectionMapping()); err == nil || !strings.Contains(err.Error(), "geometry encoding wkt requires Srid") {
+		t.Error("unexpected error", err)
+	}
+}
@@ -64,6 +64,12 @@ func Update(oscFile string, geometryLimiter *limit.Limiter, expireor expire.Expi
 	}
 	defer db.Close()
 
+	if checker, ok := db.(database.TableChecker); ok {
+		if err := checker.CheckTables(); err != nil {
+			return err
+		}
+	}
+
 	err = db.Begin()
 	if err != nil {
 		return err
@@ -16,6 +16,40 @@ type Field struct {
 	Keys []Key                  `yaml:"keys"`
 	Type string                 `yaml:"type"`
 	Args map[string]interface{} `yaml:"args"`
+	// OnUpdate is the SQL expression for the column if an upsert updates
+	// an existing row.
+	OnUpdate string `yaml:"on_update"`
+	// Normalize normalizes string values, overrides the global option.
+	Normalize *Normalize `yaml:"normalize"`
+	// NullPolicy converts empty strings to NULL (empty_as_null), NULL to
+	// empty strings (null_as_empty) or keeps all values (verbatim).
+	// Overrides the global option.
+	NullPolicy string `yaml:"null_policy"`
+	// NotNull adds a NOT NULL constraint to the column.
+	NotNull bool `yaml:"not_null"`
+	// Default is the SQL expression for the DEFAULT of the column.
+	Default string `yaml:"default"`
+	// NullValues are tag values that are inserted as NULL, e.g. unknown.
+	NullValues []string `yaml:"null_values"`
+	// NullValuesIgnoreCase compares NullValues case-insensitive.
+	NullValuesIgnoreCase bool `yaml:"null_values_ignore_case"`
+	// Enum is the name of an enum of the mapping, for string columns
+	// with the PostgreSQL enum type.
+	Enum string `yaml:"enum"`
+	// Generated is the SQL expression of a column that PostgreSQL
+	// computes (GENERATED ALWAYS AS ... STORED), e.g. from the geometry.
+	Generated string `yaml:"generated"`
+	// nulled counts the values that matched NullValues
+	nulled *int64
+}
+
+// Normalize configures the normalization of string values. Values are
+// always normalized to Unicode NFC.
+type Normalize struct {
+	// StripControls removes zero-width and bidi control characters.
+	StripControls bool `yaml:"strip_controls"`
+	// CollapseWhitespace replaces runs of whitespace with a single space.
+	CollapseWhitespace bool `yaml:"collapse_whitespace"`
 }
 
 type Table struct {
@@ -27,6 +61,123 @@ type Table struct {
 	Fields       []*Field              `yaml:"columns"` // TODO rename Fields internaly to Columns
 	OldFields    []*Field              `yaml:"fields"`
 	Filters      *Filters              `yaml:"filters"`
+	// Subdivide splits geometries with more vertices into multiple rows.
+	Subdivide int `yaml:"subdivide"`
+	// MaxVertices simplifies geometries with more vertices.
+	MaxVertices int `yaml:"max_vertices"`
+	// Dedup drops repeated rows of the same element.
+	Dedup *Dedup `yaml:"dedup"`
+	// Srid of the geometry column, defaults to the import SRID.
+	Srid int `yaml:"srid"`
+	// CheckSrid checks the SRID of EWKB geometries before the insert.
+	CheckSrid bool `yaml:"check_srid"`
+	// GeometrySubtype normalizes single and multi geometries to one
+	// subtype of the geometry column (multi or single).
+	GeometrySubtype string `yaml:"geometry_subtype"`
+	// GeometryCheck rejects invalid geometries in the database
+	// (immediate or deferred).
+	GeometryCheck string `yaml:"geometry_check"`
+	// AllowNullGeometry inserts rows without geometry with a NULL
+	// geometry, instead of skipping them.
+	AllowNullGeometry bool `yaml:"allow_null_geometry"`
+	// Schema is the name of the group of schemas for this table.
+	Schema string `yaml:"schema"`
+	// TimestampColumn adds a column with the time of the insert.
+	TimestampColumn string `yaml:"timestamp_column"`
+	// LastModifiedColumn adds a column with the time of the last change
+	// of the row.
+	LastModifiedColumn string `yaml:"last_modified_column"`
+	// GenerationColumn adds a column with the generation of the row, for
+	// mark and sweep re-imports.
+	GenerationColumn string `yaml:"generation_column"`
+	// Reindex rebuilds the indexes of the table in the optimize step,
+	// instead of clustering the table.
+	Reindex bool `yaml:"reindex"`
+	// Upsert updates existing rows with the same OSM ID.
+	Upsert bool `yaml:"upsert"`
+	// CopyBuffer overrides the global COPY buffer limits.
+	CopyBuffer *CopyBuffer `yaml:"copy_buffer"`
+	// AnalyzeEveryRows overrides the global number of committed rows
+	// after which the table is analyzed during the import (-1 disables).
+	AnalyzeEveryRows int `yaml:"analyze_every_rows"`
+	// LoadMethod overrides the global load method of bulk imports (auto,
+	// copy, insert or staging).
+	LoadMethod string `yaml:"load_method"`
+	// TileIndex adds a generated column with a tile key of the geometry.
+	TileIndex *TileIndex `yaml:"tile_index"`
+	// Indexes are additional indexes that are created after the import.
+	Indexes []*Index `yaml:"indexes"`
+	// IdIndex is the method of the OSM ID index, btree (default) or brin.
+	IdIndex string `yaml:"id_index"`
+	// Inherits is the name of the parent table of the mapping. The table
+	// is created with INHERITS (parent).
+	Inherits string `yaml:"inherits"`
+	// ForeignKey references the id column of another table, e.g. from the
+	// relation_id of a relation member table to the relation table. Rows
+	// are deleted with the referenced row (ON DELETE CASCADE).
+	ForeignKey *ForeignKey `yaml:"foreign_key"`
+	// Shards splits the bulk import of the table across multiple
+	// connections. ShardBy distributes the rows round_robin (default) or
+	// by id.
+	Shards  int    `yaml:"shards"`
+	ShardBy string `yaml:"shard_by"`
+}
+
+// Index is an additional index on a column or on an SQL expression.
+type Index struct {
+	Column string `yaml:"column"`
+	// Columns of a composite index, each with an optional sort order,
+	// e.g. "z_order DESC NULLS LAST".
+	Columns []string `yaml:"columns"`
+	// Expression is used verbatim in CREATE INDEX, e.g. lower(name).
+	Expression string `yaml:"expression"`
+	// Method of the index, defaults to btree.
+	Method string `yaml:"method"`
+	// Opclass of the column for GIN indexes, e.g. jsonb_path_ops.
+	Opclass string `yaml:"opclass"`
+	// Include are non-key columns of covering indexes (INCLUDE), for
+	// index-only scans.
+	Include []string `yaml:"include"`
+}
+
+// TileIndex configures a stored generated column that PostgreSQL computes
+// from the geometry, e.g. with a quadkey function.
+type TileIndex struct {
+	Name string `yaml:"name"`
+	// Type of the column, defaults to BIGINT.
+	Type string `yaml:"type"`
+	// Expression is the SQL expression for the column. It needs to
+	// reference the geometry column.
+	Expression string `yaml:"expression"`
+}
+
+// ForeignKey is a foreign key from Column to the id column of Table.
+type ForeignKey struct {
+	Column string `yaml:"column"`
+	Table  string `yaml:"table"`
+}
+
+// CopyBuffer configures after how many rows or bytes the COPY of a bulk
+// import is flushed. 0 uses the global value.
+type CopyBuffer struct {
+	Rows  int `yaml:"rows"`
+	Bytes int `yaml:"bytes"`
+}
+
+type Dedup struct {
+	// Scope is either "batch" (default) to detect duplicates within each
+	// transaction, or "lru" to detect duplicates within the last Size rows.
+	Scope string `yaml:"scope"`
+	// Size limits the number of remembered rows.
+	Size int `yaml:"size"`
+	// Geometry includes a hash of the geometry in the comparison, for
+	// tables where one element can result in multiple rows.
+	Geometry bool `yaml:"geometry"`
+	// Keep is either "first" (default) to insert the first row of
+	// duplicates, "last" to insert the last row, or "error" to reject
+	// all later rows. last and error are only supported for the batch
+	// scope.
+	Keep string `yaml:"keep"`
 }
 
 type GeneralizedTable struct {
@@ -51,6 +202,15 @@ type Mapping struct {
 	// SingleIdSpace mangles the overlapping node/way/relation IDs
 	// to be unique (nodes positive, ways negative, relations negative -1e17)
 	SingleIdSpace bool `yaml:"use_single_id_space"`
+	// Enums are PostgreSQL enum types for columns with enum.
+	Enums map[string]*Enum `yaml:"enums"`
+}
+
+// Enum is a PostgreSQL enum type.
+type Enum struct {
+	Values []string `yaml:"values"`
+	// Check rejects rows with other values before they are inserted.
+	Check bool `yaml:"check"`
 }
 
 type Tags struct {
@@ -133,6 +293,8 @@ func (tt *TableType) UnmarshalJSON(data []byte) error {
 		*tt = PolygonTable
 	case `"geometry"`:
 		*tt = GeometryTable
+	case `"none"`:
+		*tt = NoneTable
 	default:
 		return errors.New("unknown type " + string(data))
 	}
@@ -144,16 +306,28 @@ const (
 	LineStringTable TableType = "linestring"
 	PointTable      TableType = "point"
 	GeometryTable   TableType = "geometry"
+	// NoneTable is a table without geometry column. Elements are matched
+	// like for GeometryTable.
+	NoneTable TableType = "none"
 )
 
+// NewMapping reads the mapping file. It returns the Default mapping if
+// filename is empty.
 func NewMapping(filename string) (*Mapping, error) {
+	if filename == "" {
+		log.Print("no mapping file, using the default mapping")
+		return Default(), nil
+	}
 	f, err := ioutil.ReadFile(filename)
 	if err != nil {
 		return nil, err
 	}
+	return decodeMapping(f)
+}
 
+func decodeMapping(b []byte) (*Mapping, error) {
 	mapping := Mapping{}
-	err = yaml.Unmarshal(f, &mapping)
+	err := yaml.Unmarshal(b, &mapping)
 	if err != nil {
 		return nil, err
 	}
@@ -185,6 +359,13 @@ func (m *Mapping) prepare() error {
 			// todo deprecate 'fields'
 			t.Fields = t.OldFields
 		}
+		for _, f := range t.Fields {
+			f.nulled = new(int64)
+		}
+		if t.Type == "" {
+			// NoneTable matches all elements, require an explicit type
+			return fmt.Errorf("missing type for table %s, use type none for tables without geometry column", name)
+		}
 	}
 
 	for name, t := range m.GeneralizedTables {
@@ -208,9 +389,15 @@ func (tt TagTables) addFromMapping(mapping KeyValues, table DestTable) {
 	}
 }
 
+// matchesType returns whether elements of the tableType are matched for
+// this table.
+func (t *Table) matchesType(tableType TableType) bool {
+	return t.Type == tableType || t.Type == GeometryTable || t.Type == NoneTable
+}
+
 func (m *Mapping) mappings(tableType TableType, mappings TagTables) {
 	for name, t := range m.Tables {
-		if t.Type != GeometryTable && t.Type != tableType {
+		if !t.matchesType(tableType) {
 			continue
 		}
 		mappings.addFromMapping(t.Mapping, DestTable{Name: name})
@@ -233,7 +420,7 @@ func (m *Mapping) mappings(tableType TableType, mappings TagTables) {
 func (m *Mapping) tables(tableType TableType) map[string]*TableFields {
 	result := make(map[string]*TableFields)
 	for name, t := range m.Tables {
-		if t.Type == tableType || t.Type == "geometry" {
+		if t.matchesType(tableType) {
 			result[name] = t.TableFields()
 		}
 	}
@@ -242,7 +429,7 @@ func (m *Mapping) tables(tableType TableType) map[string]*TableFields {
 
 func (m *Mapping) extraTags(tableType TableType, tags map[Key]bool) {
 	for _, t := range m.Tables {
-		if t.Type != tableType {
+		if t.Type != tableType && t.Type != NoneTable {
 			continue
 		}
 		for key, _ := range t.ExtraTags() {
@@ -0,0 +1,139 @@
+package mapping
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/omniscale/imposm3/element"
+	"github.com/omniscale/imposm3/geom"
+)
+
+// Decimal is an exact decimal number, parsed without conversion to
+// float.
+type Decimal struct {
+	Negative bool
+	// Int are the digits before the decimal point, without leading
+	// zeros. Frac are the digits after the decimal point, without
+	// trailing zeros.
+	Int  string
+	Frac string
+}
+
+// ParseDecimal parses a decimal number with an optional sign and
+// decimal point, e.g. -12.50. Exponents are not supported.
+func ParseDecimal(s string) (Decimal, error) {
+	v := strings.TrimSpace(s)
+	var d Decimal
+	if strings.HasPrefix(v, "-") || strings.HasPrefix(v, "+") {
+		d.Negative = v[0] == '-'
+		v = v[1:]
+	}
+	intPart, fracPart := v, ""
+	if idx := strings.IndexByte(v, '.'); idx >= 0 {
+		intPart, fracPart = v[:idx], v[idx+1:]
+	}
+	if intPart == "" && fracPart == "" || !isDigits(intPart) || !isDigits(fracPart) {
+		return Decimal{}, fmt.Errorf("invalid decimal '%s'", s)
+	}
+	d.Int = strings.TrimLeft(intPart, "0")
+	d.Frac = strings.TrimRight(fracPart, "0")
+	if d.Int == "" && d.Frac == "" {
+		d.Negative = false
+	}
+	return d, nil
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// CanonicalDecimal returns val as Decimal. val can be a string, []byte or
+// an integer. Floats are converted with the shortest representation that
+// parses as the same float.
+func CanonicalDecimal(val interface{}) (Decimal, error) {
+	switch v := val.(type) {
+	case string:
+		return ParseDecimal(v)
+	case []byte:
+		return ParseDecimal(string(v))
+	case int:
+		return ParseDecimal(strconv.FormatInt(int64(v), 10))
+	case int32:
+		return ParseDecimal(strconv.FormatInt(int64(v), 10))
+	case int64:
+		return ParseDecimal(strconv.FormatInt(v, 10))
+	case float32:
+		return ParseDecimal(strconv.FormatFloat(float64(v), 'f', -1, 32))
+	case float64:
+		return ParseDecimal(strconv.FormatFloat(v, 'f', -1, 64))
+	}
+	return Decimal{}, fmt.Errorf("unsupported decimal value %T", val)
+}
+
+// Round returns the number rounded to scale digits after the decimal
+// point, with ties away from zero like PostgreSQL NUMERIC.
+func (d Decimal) Round(scale int) Decimal {
+	if len(d.Frac) <= scale {
+		return d
+	}
+	digits := []byte(d.Int + d.Frac[:scale])
+	if d.Frac[scale] >= '5' {
+		i := len(digits) - 1
+		for ; i >= 0 && digits[i] == '9'; i-- {
+			digits[i] = '0'
+		}
+		if i >= 0 {
+			digits[i] += 1
+		} else {
+			digits = append([]byte{'1'}, digits...)
+		}
+	}
+	n := len(digits) - scale
+	r := Decimal{
+		Negative: d.Negative,
+		Int:      strings.TrimLeft(string(digits[:n]), "0"),
+		Frac:     strings.TrimRight(string(digits[n:]), "0"),
+	}
+	if r.Int == "" && r.Frac == "" {
+		r.Negative = false
+	}
+	return r
+}
+
+// IntDigits returns the number of digits before the decimal point.
+func (d Decimal) IntDigits() int {
+	return len(d.Int)
+}
+
+func (d Decimal) String() string {
+	s := d.Int
+	if s == "" {
+		s = "0"
+	}
+	if d.Frac != "" {
+		s += "." + d.Frac
+	}
+	if d.Negative {
+		s = "-" + s
+	}
+	return s
+}
+
+// DecimalValue returns the tag value as exact decimal string, or nil for
+// invalid numbers. Precision and scale are checked by the database.
+func DecimalValue(val string, elem *element.OSMElem, geom *geom.Geometry, match Match) interface{} {
+	if val == "" {
+		return nil
+	}
+	d, err := ParseDecimal(val)
+	if err != nil {
+		return nil
+	}
+	return d.String()
+}
@@ -0,0 +1,58 @@
+package mapping
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDefaultMapping(t *testing.T) {
+	m := Default()
+	for _, name := range []string{"roads", "buildings", "landusages", "waterways", "places", "amenities"} {
+		if _, ok := m.Tables[name]; !ok {
+			t.Error("missing table", name)
+		}
+	}
+	if m.Tables["roads"].Name != "roads" {
+		t.Error("mapping not prepared")
+	}
+
+	example, err := ioutil.ReadFile("../example-mapping.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(example) != defaultMapping {
+		t.Error("default mapping differs from example-mapping.yml")
+	}
+}
+
+func TestNewMappingDefault(t *testing.T) {
+	m, err := NewMapping("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Tables) != len(Default().Tables) {
+		t.Error("expected default mapping", len(m.Tables))
+	}
+}
+
+func TestWriteDefault(t *testing.T) {
+	dir, err := ioutil.TempDir("", "imposm3-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "mapping.yml")
+	if err := WriteDefault(filename); err != nil {
+		t.Fatal(err)
+	}
+	m, err := NewMapping(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Tables) != len(Default().Tables) || len(m.GeneralizedTables) != len(Default().GeneralizedTables) {
+		t.Error("unexpected mapping", m)
+	}
+}
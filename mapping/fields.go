@@ -1,10 +1,12 @@
 package mapping
 
 import (
+	"encoding/json"
 	"errors"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync/atomic"
 
 	"github.com/omniscale/imposm3/element"
 	"github.com/omniscale/imposm3/geom"
@@ -23,11 +25,17 @@ func init() {
 		"string":               {"string", "string", String, nil},
 		"direction":            {"direction", "int8", Direction, nil},
 		"integer":              {"integer", "int32", Integer, nil},
+		"uuid":                 {"uuid", "uuid", UUID, nil},
+		"tstzrange":            {"tstzrange", "tstzrange", TstzRange, nil},
+		"decimal":              {"decimal", "decimal", DecimalValue, nil},
+		"osm_type":             {"osm_type", "osm_type", OsmTypeValue, nil},
 		"mapping_key":          {"mapping_key", "string", KeyName, nil},
 		"mapping_value":        {"mapping_value", "string", ValueName, nil},
 		"geometry":             {"geometry", "geometry", Geometry, nil},
 		"validated_geometry":   {"validated_geometry", "validated_geometry", Geometry, nil},
+		"geometry_hash":        {"geometry_hash", "geometry_hash", GeometryHash, nil},
 		"hstore_tags":          {"hstore_tags", "hstore_string", HstoreString, nil},
+		"jsonb_tags":           {"jsonb_tags", "jsonb_string", JsonbString, nil},
 		"wayzorder":            {"wayzorder", "int32", WayZOrder, nil},
 		"pseudoarea":           {"pseudoarea", "float32", PseudoArea, nil},
 		"zorder":               {"zorder", "int32", nil, MakeZOrder},
@@ -46,15 +54,52 @@ type Value string
 type FieldSpec struct {
 	Key  Key
 	Type FieldType
+	// nullValues are the NullValues of the field, lower case if
+	// ignoreCase is set
+	nullValues map[string]bool
+	ignoreCase bool
+	nulled     *int64
 }
 
 func (f *FieldSpec) Value(elem *element.OSMElem, geom *geom.Geometry, match Match) interface{} {
 	if f.Type.Func != nil {
-		return f.Type.Func(elem.Tags[string(f.Key)], elem, geom, match)
+		val := elem.Tags[string(f.Key)]
+		if f.isNull(val) {
+			return nil
+		}
+		return f.Type.Func(val, elem, geom, match)
 	}
 	return nil
 }
 
+// isNull returns true if the tag value is one of the NullValues of the
+// field. Checked before the value is converted, so that NullValues also
+// apply to non-string types.
+func (f *FieldSpec) isNull(val string) bool {
+	if len(f.nullValues) == 0 {
+		return false
+	}
+	if f.ignoreCase {
+		val = strings.ToLower(val)
+	}
+	if !f.nullValues[val] {
+		return false
+	}
+	if f.nulled != nil {
+		atomic.AddInt64(f.nulled, 1)
+	}
+	return true
+}
+
+// NulledValues returns the number of values that were inserted as NULL
+// because they matched NullValues.
+func (field *Field) NulledValues() int64 {
+	if field.nulled == nil {
+		return 0
+	}
+	return atomic.LoadInt64(field.nulled)
+}
+
 type TableFields struct {
 	fields []FieldSpec
 }
@@ -88,6 +133,17 @@ func (t *Table) TableFields() *TableFields {
 	for _, mappingField := range t.Fields {
 		field := FieldSpec{}
 		field.Key = mappingField.Key
+		if len(mappingField.NullValues) > 0 {
+			field.nullValues = make(map[string]bool)
+			field.ignoreCase = mappingField.NullValuesIgnoreCase
+			for _, v := range mappingField.NullValues {
+				if field.ignoreCase {
+					v = strings.ToLower(v)
+				}
+				field.nullValues[v] = true
+			}
+			field.nulled = mappingField.nulled
+		}
 
 		fieldType := mappingField.FieldType()
 		if fieldType != nil {
@@ -133,6 +189,29 @@ func Integer(val string, elem *element.OSMElem, geom *geom.Geometry, match Match
 	return v
 }
 
+// UUID returns the tag value as canonical UUID, or nil for invalid UUIDs.
+func UUID(val string, elem *element.OSMElem, geom *geom.Geometry, match Match) interface{} {
+	if val == "" {
+		return nil
+	}
+	v, err := CanonicalUUID(val)
+	if err != nil {
+		return nil
+	}
+	return v
+}
+
+func TstzRange(val string, elem *element.OSMElem, geom *geom.Geometry, match Match) interface{} {
+	if val == "" {
+		return nil
+	}
+	v, err := TstzRangeLiteral(val)
+	if err != nil {
+		return nil
+	}
+	return v
+}
+
 func Id(val string, elem *element.OSMElem, geom *geom.Geometry, match Match) interface{} {
 	return elem.Id
 }
@@ -159,6 +238,12 @@ func Geometry(val string, elem *element.OSMElem, geom *geom.Geometry, match Matc
 	return string(geom.Wkb)
 }
 
+// GeometryHash returns nil. The hash is calculated from the geometry
+// of the row by the database.
+func GeometryHash(val string, elem *element.OSMElem, geom *geom.Geometry, match Match) interface{} {
+	return nil
+}
+
 func PseudoArea(val string, elem *element.OSMElem, geom *geom.Geometry, match Match) interface{} {
 	area := geom.Geom.Area()
 	if area == 0.0 {
@@ -177,6 +262,15 @@ func HstoreString(val string, elem *element.OSMElem, geom *geom.Geometry, match
 	return strings.Join(tags, ", ")
 }
 
+// JsonbString returns all tags as JSON object.
+func JsonbString(val string, elem *element.OSMElem, geom *geom.Geometry, match Match) interface{} {
+	b, err := json.Marshal(map[string]string(elem.Tags))
+	if err != nil {
+		return nil
+	}
+	return string(b)
+}
+
 var wayRanks map[string]int
 
 func init() {
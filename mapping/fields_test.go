@@ -215,3 +215,141 @@ func TestHstoreString(t *testing.T) {
 	assertEq(t, HstoreString("", &element.OSMElem{Tags: element.Tags{`\`: `\\\\`}}, nil, match).(string), `"\\"=>"\\\\\\\\"`)
 	assertEq(t, HstoreString("", &element.OSMElem{Tags: element.Tags{"Ümlåütê=>": ""}}, nil, match).(string), `"Ümlåütê=>"=>""`)
 }
+
+func TestNullValues(t *testing.T) {
+	m := Mapping{Tables: Tables{"roads": &Table{
+		Type: LineStringTable,
+		Fields: []*Field{
+			{Name: "maxspeed", Key: "maxspeed", Type: "integer", NullValues: []string{"none", "5"}},
+			{Name: "name", Key: "name", Type: "string", NullValues: []string{"FIXME"}, NullValuesIgnoreCase: true},
+			{Name: "ref", Key: "ref", Type: "string", NullValues: []string{"FIXME"}},
+		},
+	}}}
+	if err := m.prepare(); err != nil {
+		t.Fatal(err)
+	}
+	fields := m.Tables["roads"].TableFields()
+
+	for _, test := range []struct {
+		tags     element.Tags
+		expected []interface{}
+	}{
+		{element.Tags{"maxspeed": "50", "name": "Main", "ref": "B1"}, []interface{}{int64(50), "Main", "B1"}},
+		{element.Tags{"maxspeed": "none", "name": "fixme", "ref": "fixme"}, []interface{}{nil, nil, "fixme"}},
+		{element.Tags{"maxspeed": "5", "name": "FixMe", "ref": "FIXME"}, []interface{}{nil, nil, nil}},
+		// null_values apply before the value is parsed
+		{element.Tags{"maxspeed": "05"}, []interface{}{int64(5), "", ""}},
+	} {
+		elem := element.OSMElem{Tags: test.tags}
+		row := fields.MakeRow(&elem, nil, Match{})
+		for i := range test.expected {
+			if row[i] != test.expected[i] {
+				t.Errorf("unexpected row for %v: %#v", test.tags, row)
+				break
+			}
+		}
+	}
+
+	table := m.Tables["roads"]
+	if n := table.Fields[0].NulledValues(); n != 2 {
+		t.Error("unexpected nulled maxspeed values", n)
+	}
+	if n := table.Fields[1].NulledValues(); n != 2 {
+		t.Error("unexpected nulled name values", n)
+	}
+	if n := table.Fields[2].NulledValues(); n != 1 {
+		t.Error("unexpected nulled ref values", n)
+	}
+}
+
+func TestUUID(t *testing.T) {
+	match := Match{}
+	if v := UUID("0A1B2C3D-4E5F-6071-8293-A4B5C6D7E8F9", nil, nil, match); v != "0a1b2c3d-4e5f-6071-8293-a4b5c6d7e8f9" {
+		t.Error("unexpected value", v)
+	}
+	if v := UUID("0a1b2c3d4e5f60718293a4b5c6d7e8f9", nil, nil, match); v != "0a1b2c3d-4e5f-6071-8293-a4b5c6d7e8f9" {
+		t.Error("unexpected value", v)
+	}
+	for _, val := range []string{"", "yes", "0a1b2c3d-4e5f-6071-8293-a4b5c6d7e8"} {
+		if v := UUID(val, nil, nil, match); v != nil {
+			t.Error("unexpected value", val, v)
+		}
+	}
+}
+
+func TestTstzRange(t *testing.T) {
+	match := Match{}
+	if v := TstzRange("[2020-01-01,2021-01-01)", nil, nil, match); v != "[2020-01-01,2021-01-01)" {
+		t.Error("unexpected value", v)
+	}
+	for _, val := range []string{"", "2020", "[2020-01-01,soon)"} {
+		if v := TstzRange(val, nil, nil, match); v != nil {
+			t.Error("unexpected value", val, v)
+		}
+	}
+}
+
+func TestDecimalValue(t *testing.T) {
+	match := Match{}
+	for _, test := range []struct {
+		val      string
+		expected interface{}
+	}{
+		// not exact as float64 (12345678901234568)
+		{"12345678901234567.89", "12345678901234567.89"},
+		{"+0012.500", "12.5"},
+		{"-.5", "-0.5"},
+		{"-0.00", "0"},
+		{"7.", "7"},
+		{"", nil},
+		{".", nil},
+		{"1e10", nil},
+		{"1.2.3", nil},
+		{"12 m", nil},
+	} {
+		if v := DecimalValue(test.val, nil, nil, match); v != test.expected {
+			t.Errorf("unexpected value for %q: %#v", test.val, v)
+		}
+	}
+}
+
+func TestDecimalRound(t *testing.T) {
+	for _, test := range []struct {
+		val      string
+		scale    int
+		expected string
+	}{
+		{"1.234", 2, "1.23"},
+		{"1.235", 2, "1.24"},
+		{"-1.235", 2, "-1.24"},
+		{"9.995", 2, "10"},
+		{"99.5", 1, "99.5"},
+		{"0.004", 2, "0"},
+		{"-0.004", 2, "0"},
+		{"12345678901234567.895", 2, "12345678901234567.9"},
+	} {
+		d, err := ParseDecimal(test.val)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r := d.Round(test.scale).String(); r != test.expected {
+			t.Errorf("unexpected value for %s: %s", test.val, r)
+		}
+	}
+}
+
+func TestOsmTypeValue(t *testing.T) {
+	if v := OsmTypeValue("", nil, nil, Match{OsmType: OsmTypeWay}); v != OsmTypeWay {
+		t.Error("unexpected value", v)
+	}
+	if v := OsmTypeValue("", nil, nil, Match{}); v != nil {
+		t.Error("unexpected value", v)
+	}
+}
+
+func TestJsonbString(t *testing.T) {
+	elem := element.OSMElem{Tags: element.Tags{"name": `"Main" St`, "highway": "primary"}}
+	if v := JsonbString("", &elem, nil, Match{}); v != `{"highway":"primary","name":"\"Main\" St"}` {
+		t.Error("unexpected value", v)
+	}
+}
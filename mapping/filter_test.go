@@ -52,7 +52,8 @@ func matchesEqual(t *testing.T, expected []Match, actual []Match) {
 		if actualMatch, ok := actualMatches[name]; ok {
 			if expectedMatch.Table != actualMatch.Table ||
 				expectedMatch.Key != actualMatch.Key ||
-				expectedMatch.Value != actualMatch.Value {
+				expectedMatch.Value != actualMatch.Value ||
+				expectedMatch.OsmType != actualMatch.OsmType {
 				t.Fatalf("match differ %v != %v", expectedMatch, actualMatch)
 			}
 		} else {
@@ -263,16 +264,16 @@ func TestPointMatcher(t *testing.T) {
 	matchesEqual(t, []Match{}, points.MatchNode(&elem))
 
 	elem.Tags = element.Tags{"place": "city"}
-	matchesEqual(t, []Match{{"place", "city", DestTable{Name: "places"}, nil}}, points.MatchNode(&elem))
+	matchesEqual(t, []Match{{"place", "city", DestTable{Name: "places"}, OsmTypeNode, nil}}, points.MatchNode(&elem))
 
 	elem.Tags = element.Tags{"place": "city", "highway": "unknown"}
-	matchesEqual(t, []Match{{"place", "city", DestTable{Name: "places"}, nil}}, points.MatchNode(&elem))
+	matchesEqual(t, []Match{{"place", "city", DestTable{Name: "places"}, OsmTypeNode, nil}}, points.MatchNode(&elem))
 
 	elem.Tags = element.Tags{"place": "city", "highway": "bus_stop"}
 	matchesEqual(t,
 		[]Match{
-			{"place", "city", DestTable{Name: "places"}, nil},
-			{"highway", "bus_stop", DestTable{Name: "transport_points"}, nil}},
+			{"place", "city", DestTable{Name: "places"}, OsmTypeNode, nil},
+			{"highway", "bus_stop", DestTable{Name: "transport_points"}, OsmTypeNode, nil}},
 		points.MatchNode(&elem))
 }
 
@@ -287,7 +288,7 @@ func TestLineStringMatcher(t *testing.T) {
 	matchesEqual(t, []Match{}, ls.MatchWay(&elem))
 
 	elem.Tags = element.Tags{"highway": "pedestrian"}
-	matchesEqual(t, []Match{{"highway", "pedestrian", DestTable{Name: "roads", SubMapping: "roads"}, nil}}, ls.MatchWay(&elem))
+	matchesEqual(t, []Match{{"highway", "pedestrian", DestTable{Name: "roads", SubMapping: "roads"}, OsmTypeWay, nil}}, ls.MatchWay(&elem))
 
 	// exclude_tags area=yes
 	elem.Tags = element.Tags{"highway": "pedestrian", "area": "yes"}
@@ -296,13 +297,13 @@ func TestLineStringMatcher(t *testing.T) {
 	elem.Tags = element.Tags{"highway": "secondary", "railway": "tram"}
 	matchesEqual(t,
 		[]Match{
-			{"highway", "secondary", DestTable{Name: "roads", SubMapping: "roads"}, nil},
-			{"railway", "tram", DestTable{Name: "roads", SubMapping: "railway"}, nil}},
+			{"highway", "secondary", DestTable{Name: "roads", SubMapping: "roads"}, OsmTypeWay, nil},
+			{"railway", "tram", DestTable{Name: "roads", SubMapping: "railway"}, OsmTypeWay, nil}},
 		ls.MatchWay(&elem))
 
 	elem.Tags = element.Tags{"highway": "footway", "landuse": "park"}
 	// landusages not a linestring table
-	matchesEqual(t, []Match{{"highway", "footway", DestTable{Name: "roads", SubMapping: "roads"}, nil}}, ls.MatchWay(&elem))
+	matchesEqual(t, []Match{{"highway", "footway", DestTable{Name: "roads", SubMapping: "roads"}, OsmTypeWay, nil}}, ls.MatchWay(&elem))
 }
 
 func TestPolygonMatcher(t *testing.T) {
@@ -316,34 +317,34 @@ func TestPolygonMatcher(t *testing.T) {
 	matchesEqual(t, []Match{}, polys.MatchRelation(&elem))
 
 	elem.Tags = element.Tags{"building": "yes"}
-	matchesEqual(t, []Match{{"building", "yes", DestTable{Name: "buildings"}, nil}}, polys.MatchRelation(&elem))
+	matchesEqual(t, []Match{{"building", "yes", DestTable{Name: "buildings"}, OsmTypeRelation, nil}}, polys.MatchRelation(&elem))
 	elem.Tags = element.Tags{"building": "residential"}
-	matchesEqual(t, []Match{{"building", "residential", DestTable{Name: "buildings"}, nil}}, polys.MatchRelation(&elem))
+	matchesEqual(t, []Match{{"building", "residential", DestTable{Name: "buildings"}, OsmTypeRelation, nil}}, polys.MatchRelation(&elem))
 
 	elem.Tags = element.Tags{"building": "shop"}
 	matchesEqual(t, []Match{
-		{"building", "shop", DestTable{Name: "buildings"}, nil},
-		{"building", "shop", DestTable{Name: "amenity_areas"}, nil}},
+		{"building", "shop", DestTable{Name: "buildings"}, OsmTypeRelation, nil},
+		{"building", "shop", DestTable{Name: "amenity_areas"}, OsmTypeRelation, nil}},
 		polys.MatchRelation(&elem))
 
 	elem.Tags = element.Tags{"landuse": "farm"}
-	matchesEqual(t, []Match{{"landuse", "farm", DestTable{Name: "landusages"}, nil}}, polys.MatchRelation(&elem))
+	matchesEqual(t, []Match{{"landuse", "farm", DestTable{Name: "landusages"}, OsmTypeRelation, nil}}, polys.MatchRelation(&elem))
 
 	elem.Tags = element.Tags{"landuse": "farm", "highway": "secondary"}
-	matchesEqual(t, []Match{{"landuse", "farm", DestTable{Name: "landusages"}, nil}}, polys.MatchRelation(&elem))
+	matchesEqual(t, []Match{{"landuse", "farm", DestTable{Name: "landusages"}, OsmTypeRelation, nil}}, polys.MatchRelation(&elem))
 
 	elem.Tags = element.Tags{"landuse": "farm", "aeroway": "apron"}
 	matchesEqual(t,
 		[]Match{
-			{"aeroway", "apron", DestTable{Name: "transport_areas"}, nil},
-			{"landuse", "farm", DestTable{Name: "landusages"}, nil}},
+			{"aeroway", "apron", DestTable{Name: "transport_areas"}, OsmTypeRelation, nil},
+			{"landuse", "farm", DestTable{Name: "landusages"}, OsmTypeRelation, nil}},
 		polys.MatchRelation(&elem))
 
 	elem.Tags = element.Tags{"highway": "footway"}
-	matchesEqual(t, []Match{{"highway", "footway", DestTable{Name: "landusages"}, nil}}, polys.MatchRelation(&elem))
+	matchesEqual(t, []Match{{"highway", "footway", DestTable{Name: "landusages"}, OsmTypeRelation, nil}}, polys.MatchRelation(&elem))
 
 	elem.Tags = element.Tags{"boundary": "administrative", "admin_level": "8"}
-	matchesEqual(t, []Match{{"boundary", "administrative", DestTable{Name: "admin"}, nil}}, polys.MatchRelation(&elem))
+	matchesEqual(t, []Match{{"boundary", "administrative", DestTable{Name: "admin"}, OsmTypeRelation, nil}}, polys.MatchRelation(&elem))
 }
 
 func TestMatcherMappingOrder(t *testing.T) {
@@ -365,13 +366,13 @@ func TestMatcherMappingOrder(t *testing.T) {
 	*/
 
 	elem.Tags = element.Tags{"landuse": "forest", "leisure": "park"}
-	matchesEqual(t, []Match{{"landuse", "forest", DestTable{Name: "landusages"}, nil}}, polys.MatchRelation(&elem))
+	matchesEqual(t, []Match{{"landuse", "forest", DestTable{Name: "landusages"}, OsmTypeRelation, nil}}, polys.MatchRelation(&elem))
 
 	elem.Tags = element.Tags{"landuse": "park", "leisure": "park"}
-	matchesEqual(t, []Match{{"leisure", "park", DestTable{Name: "landusages"}, nil}}, polys.MatchRelation(&elem))
+	matchesEqual(t, []Match{{"leisure", "park", DestTable{Name: "landusages"}, OsmTypeRelation, nil}}, polys.MatchRelation(&elem))
 
 	elem.Tags = element.Tags{"landuse": "park", "leisure": "park", "amenity": "university"}
-	matchesEqual(t, []Match{{"amenity", "university", DestTable{Name: "landusages"}, nil}}, polys.MatchRelation(&elem))
+	matchesEqual(t, []Match{{"amenity", "university", DestTable{Name: "landusages"}, OsmTypeRelation, nil}}, polys.MatchRelation(&elem))
 }
 
 func TestFilterNodes(t *testing.T) {
@@ -27,9 +27,11 @@ func (m *Mapping) PolygonMatcher() RelWayMatcher {
 }
 
 type Match struct {
-	Key         string
-	Value       string
-	Table       DestTable
+	Key   string
+	Value string
+	Table DestTable
+	// OsmType is the type of the matched element.
+	OsmType     OsmType
 	tableFields *TableFields
 }
 
@@ -62,7 +64,7 @@ func (m *Match) Row(elem *element.OSMElem, geom *geom.Geometry) []interface{} {
 }
 
 func (tm *tagMatcher) MatchNode(node *element.Node) []Match {
-	return tm.match(&node.Tags)
+	return tm.match(&node.Tags, OsmTypeNode)
 }
 
 func (tm *tagMatcher) MatchWay(way *element.Way) []Match {
@@ -71,7 +73,7 @@ func (tm *tagMatcher) MatchWay(way *element.Way) []Match {
 			if way.Tags["area"] == "no" {
 				return nil
 			}
-			return tm.match(&way.Tags)
+			return tm.match(&way.Tags, OsmTypeWay)
 		}
 	} else { // match way as linestring
 		if way.IsClosed() {
@@ -79,13 +81,13 @@ func (tm *tagMatcher) MatchWay(way *element.Way) []Match {
 				return nil
 			}
 		}
-		return tm.match(&way.Tags)
+		return tm.match(&way.Tags, OsmTypeWay)
 	}
 	return nil
 }
 
 func (tm *tagMatcher) MatchRelation(rel *element.Relation) []Match {
-	return tm.match(&rel.Tags)
+	return tm.match(&rel.Tags, OsmTypeRelation)
 }
 
 type orderedMatch struct {
@@ -93,13 +95,13 @@ type orderedMatch struct {
 	order int
 }
 
-func (tm *tagMatcher) match(tags *element.Tags) []Match {
+func (tm *tagMatcher) match(tags *element.Tags, osmType OsmType) []Match {
 	tables := make(map[DestTable]orderedMatch)
 
 	addTables := func(k, v string, tbls []OrderedDestTable) {
 		for _, t := range tbls {
 			this := orderedMatch{
-				Match: Match{k, v, t.DestTable, tm.tables[t.Name]},
+				Match: Match{k, v, t.DestTable, osmType, tm.tables[t.Name]},
 				order: t.order,
 			}
 			if other, ok := tables[t.DestTable]; ok {
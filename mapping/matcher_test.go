@@ -4,6 +4,9 @@ import (
 	"testing"
 
 	"github.com/omniscale/imposm3/element"
+	"github.com/omniscale/imposm3/geom"
+
+	"gopkg.in/yaml.v2"
 )
 
 func BenchmarkTagMatch(b *testing.B) {
@@ -157,3 +160,53 @@ func TestSelectRelationPolygonsMultipleTagsOnWay(t *testing.T) {
 		t.Fatal(filtered)
 	}
 }
+
+func TestNoneTableMatch(t *testing.T) {
+	m := Mapping{}
+	err := yaml.Unmarshal([]byte(`
+tables:
+  route_refs:
+    type: none
+    columns:
+    - name: osm_id
+      type: id
+    - name: ref
+      key: ref
+      type: string
+    mapping:
+      route: [bus]
+`), &m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.prepare(); err != nil {
+		t.Fatal(err)
+	}
+	if m.Tables["route_refs"].Type != NoneTable {
+		t.Fatal("unexpected type", m.Tables["route_refs"].Type)
+	}
+
+	m.Tables["route_refs"].Type = ""
+	if err := m.prepare(); err == nil {
+		t.Fatal("expected error for missing type")
+	}
+	m.Tables["route_refs"].Type = NoneTable
+
+	elem := element.Relation{}
+	elem.Id = 42
+	elem.Tags = element.Tags{"route": "bus", "ref": "12"}
+	matches := m.LineStringMatcher().MatchRelation(&elem)
+	if len(matches) != 1 || matches[0].Table.Name != "route_refs" {
+		t.Fatal("unexpected matches", matches)
+	}
+	row := matches[0].Row(&elem.OSMElem, &geom.Geometry{})
+	if len(row) != 2 || row[0] != int64(42) || row[1] != "12" {
+		t.Error("unexpected row", row)
+	}
+
+	tags := element.Tags{"route": "bus", "ref": "12", "note": "foo"}
+	m.WayTagFilter().Filter(&tags)
+	if _, ok := tags["ref"]; !ok {
+		t.Error("ref filtered", tags)
+	}
+}
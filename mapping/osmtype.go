@@ -0,0 +1,57 @@
+package mapping
+
+import (
+	"github.com/omniscale/imposm3/element"
+	"github.com/omniscale/imposm3/geom"
+)
+
+// OsmType is the type of the element of a row, for columns of type
+// osm_type. Rows of the mapping get the type of the matched element.
+// Callers that insert rows directly (e.g. InsertMultiBatch) pass one of
+// the OsmType constants as value of the column. The database stores them
+// as N/W/R or as 0/1/2, depending on the column.
+type OsmType int
+
+const (
+	// OsmTypeUnknown is the type of matches that are not from an element
+	// matcher. Deletes of unknown types remove the rows of all types.
+	OsmTypeUnknown OsmType = iota
+	OsmTypeNode
+	OsmTypeWay
+	OsmTypeRelation
+)
+
+var osmTypeNames = map[OsmType]string{
+	OsmTypeNode:     "node",
+	OsmTypeWay:      "way",
+	OsmTypeRelation: "relation",
+}
+
+func (t OsmType) String() string {
+	if name, ok := osmTypeNames[t]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// OsmTypeValue returns the type of the matched element, or nil for
+// unknown types.
+func OsmTypeValue(val string, elem *element.OSMElem, geom *geom.Geometry, match Match) interface{} {
+	if match.OsmType == OsmTypeUnknown {
+		return nil
+	}
+	return match.OsmType
+}
+
+// OsmTypes returns the types of all elements that are matched for the
+// table. Ways of route relations are inserted into linestring tables with
+// the tags of the relation.
+func (t *Table) OsmTypes() []OsmType {
+	switch t.Type {
+	case PointTable:
+		return []OsmType{OsmTypeNode}
+	case LineStringTable, PolygonTable:
+		return []OsmType{OsmTypeWay, OsmTypeRelation}
+	}
+	return []OsmType{OsmTypeNode, OsmTypeWay, OsmTypeRelation}
+}
@@ -0,0 +1,85 @@
+package mapping
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// tstzRangeLayouts are the accepted timestamps of range literals, a
+// subset of the input formats of PostgreSQL.
+var tstzRangeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999Z07",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02",
+}
+
+// TstzRangeLiteral returns the tstzrange literal of val. val can be a
+// [2]time.Time with the inclusive lower and the exclusive upper bound,
+// zero times are unbounded, or a range literal string like
+// [2020-01-01,2021-01-01) or empty. Strings are returned without
+// changes, but only with bounds that are timestamps or infinity.
+func TstzRangeLiteral(val interface{}) (string, error) {
+	switch v := val.(type) {
+	case [2]time.Time:
+		return formatTstzRange(v)
+	case string:
+		return parseTstzRange(v)
+	}
+	return "", fmt.Errorf("unsupported tstzrange value %T", val)
+}
+
+func formatTstzRange(r [2]time.Time) (string, error) {
+	if !r[0].IsZero() && !r[1].IsZero() && r[1].Before(r[0]) {
+		return "", fmt.Errorf("invalid tstzrange, upper bound %s before lower bound %s",
+			r[1].Format(time.RFC3339Nano), r[0].Format(time.RFC3339Nano))
+	}
+	lower, upper := "(", ")"
+	if !r[0].IsZero() {
+		lower = `["` + r[0].Format(time.RFC3339Nano) + `"`
+	}
+	if !r[1].IsZero() {
+		upper = `"` + r[1].Format(time.RFC3339Nano) + `")`
+	}
+	return lower + "," + upper, nil
+}
+
+func parseTstzRange(s string) (string, error) {
+	v := strings.TrimSpace(s)
+	if strings.ToLower(v) == "empty" {
+		return v, nil
+	}
+	if len(v) < 3 || !strings.ContainsAny(v[:1], "[(") || !strings.ContainsAny(v[len(v)-1:], "])") {
+		return "", fmt.Errorf("invalid tstzrange '%s'", s)
+	}
+	bounds := strings.Split(v[1:len(v)-1], ",")
+	if len(bounds) != 2 {
+		return "", fmt.Errorf("invalid tstzrange '%s'", s)
+	}
+	for _, bound := range bounds {
+		if !validTstzRangeBound(bound) {
+			return "", fmt.Errorf("invalid bound '%s' of tstzrange '%s'", bound, s)
+		}
+	}
+	return v, nil
+}
+
+func validTstzRangeBound(bound string) bool {
+	b := strings.TrimSpace(bound)
+	if len(b) >= 2 && b[0] == '"' && b[len(b)-1] == '"' {
+		b = b[1 : len(b)-1]
+	}
+	switch strings.ToLower(b) {
+	case "", "infinity", "-infinity":
+		return true
+	}
+	for _, layout := range tstzRangeLayouts {
+		if _, err := time.Parse(layout, b); err == nil {
+			return true
+		}
+	}
+	return false
+}
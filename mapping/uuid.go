@@ -0,0 +1,58 @@
+package mapping
+
+import (
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// CanonicalUUID returns the UUID in the canonical form
+// (xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx, lower case). val can be a string,
+// 16 bytes as [16]byte, []byte or any named [16]byte type, or a
+// fmt.Stringer. Strings can have braces, the urn:uuid: prefix and are
+// accepted without hyphens.
+func CanonicalUUID(val interface{}) (string, error) {
+	switch v := val.(type) {
+	case string:
+		return parseUUID(v)
+	case []byte:
+		if len(v) == 16 {
+			return formatUUID(v), nil
+		}
+		return parseUUID(string(v))
+	}
+	rv := reflect.ValueOf(val)
+	if rv.Kind() == reflect.Array && rv.Len() == 16 && rv.Type().Elem().Kind() == reflect.Uint8 {
+		b := make([]byte, 16)
+		for i := range b {
+			b[i] = byte(rv.Index(i).Uint())
+		}
+		return formatUUID(b), nil
+	}
+	if s, ok := val.(fmt.Stringer); ok {
+		return parseUUID(s.String())
+	}
+	return "", fmt.Errorf("unsupported UUID value %T", val)
+}
+
+func parseUUID(s string) (string, error) {
+	v := strings.TrimSpace(s)
+	if len(v) > 9 && strings.ToLower(v[:9]) == "urn:uuid:" {
+		v = v[9:]
+	}
+	if strings.HasPrefix(v, "{") && strings.HasSuffix(v, "}") {
+		v = v[1 : len(v)-1]
+	}
+	v = strings.Replace(v, "-", "", -1)
+	b, err := hex.DecodeString(v)
+	if err != nil || len(b) != 16 {
+		return "", fmt.Errorf("invalid UUID '%s'", s)
+	}
+	return formatUUID(b), nil
+}
+
+func formatUUID(b []byte) string {
+	h := hex.EncodeToString(b)
+	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:32]
+}
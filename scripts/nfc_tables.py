@@ -0,0 +1,61 @@
+#!/usr/bin/env python3
+"""
+Generates database/postgis/nfc_tables.go with the canonical decompositions,
+combining classes and composition exclusions from the Unicode database of
+Python. Called by go generate in database/postgis, the output file is the
+first argument.
+
+    python3 scripts/nfc_tables.py database/postgis/nfc_tables.go
+"""
+import os
+import subprocess
+import sys
+import unicodedata
+
+if len(sys.argv) != 2:
+    sys.exit('usage: %s nfc_tables.go' % sys.argv[0])
+output = sys.argv[1]
+script = os.path.relpath(os.path.abspath(__file__), os.path.dirname(os.path.abspath(output)))
+
+decompositions = []
+classes = []
+exclusions = []
+
+for cp in range(sys.maxunicode + 1):
+    ch = chr(cp)
+    ccc = unicodedata.combining(ch)
+    if ccc:
+        classes.append((cp, ccc))
+    d = unicodedata.decomposition(ch)
+    if not d or d.startswith('<'):
+        continue
+    parts = [int(p, 16) for p in d.split()]
+    decompositions.append((cp, parts))
+    if len(parts) == 2 and not unicodedata.combining(chr(parts[0])):
+        if unicodedata.normalize('NFC', ch) != ch:
+            exclusions.append(cp)
+
+out = []
+out.append('// generated by scripts/nfc_tables.py from Unicode %s, do not edit\n' % unicodedata.unidata_version)
+out.append('package postgis\n')
+out.append('//go:generate python3 %s nfc_tables.go\n' % script)
+out.append('// canonical decompositions (single level)')
+out.append('var nfcDecompositions = map[rune][]rune{')
+for cp, parts in decompositions:
+    out.append('\t0x%04X: {%s},' % (cp, ', '.join('0x%04X' % p for p in parts)))
+out.append('}\n')
+out.append('// canonical combining classes (all other runes are 0)')
+out.append('var nfcCombiningClasses = map[rune]uint8{')
+for i in range(0, len(classes), 6):
+    out.append('\t' + ' '.join('0x%04X: %d,' % c for c in classes[i:i+6]))
+out.append('}\n')
+out.append('// runes with a two rune decomposition that are excluded from composition')
+out.append('var nfcCompositionExclusions = []rune{')
+for i in range(0, len(exclusions), 8):
+    out.append('\t' + ' '.join('0x%04X,' % c for c in exclusions[i:i+8]))
+out.append('}')
+
+with open(output, 'w') as f:
+    f.write('\n'.join(out) + '\n')
+
+subprocess.check_call(['gofmt', '-w', output])
@@ -23,6 +23,43 @@ def test_import():
     t.imposm3_import(t.db_conf, './build/single_table.pbf', mapping_file)
     assert t.table_exists('osm_all', schema=t.TEST_SCHEMA_IMPORT)
 
+def test_insert_after_import():
+    """Rows with default id can be inserted after import."""
+    conn = psycopg2.connect(**t.db_conf)
+    try:
+        cur = conn.cursor()
+        cur.execute('SELECT max(id) FROM %s.osm_all' % t.TEST_SCHEMA_IMPORT)
+        max_id = cur.fetchone()[0]
+        cur.execute('INSERT INTO %s.osm_all (osm_id) VALUES (-1) RETURNING id' % t.TEST_SCHEMA_IMPORT)
+        assert cur.fetchone()[0] > max_id
+    finally:
+        conn.rollback()
+        conn.close()
+
+def test_gin_index():
+    """GIN index on tags column is created."""
+    conn = psycopg2.connect(**t.db_conf)
+    try:
+        cur = conn.cursor()
+        cur.execute("SELECT indexdef FROM pg_indexes WHERE schemaname = %s AND indexname = 'osm_all_tags_idx'",
+            (t.TEST_SCHEMA_IMPORT, ))
+        indexdef = cur.fetchone()[0]
+        assert 'USING gin (tags)' in indexdef, indexdef
+    finally:
+        conn.close()
+
+def test_composite_index():
+    """Composite index with sort order is created."""
+    conn = psycopg2.connect(**t.db_conf)
+    try:
+        cur = conn.cursor()
+        cur.execute("SELECT indexdef FROM pg_indexes WHERE schemaname = %s AND indexname = 'osm_all_osm_id_id_idx'",
+            (t.TEST_SCHEMA_IMPORT, ))
+        indexdef = cur.fetchone()[0]
+        assert 'USING btree (osm_id, id DESC NULLS LAST)' in indexdef, indexdef
+    finally:
+        conn.close()
+
 def test_deploy():
     """Deploy succeeds"""
     assert not t.table_exists('osm_all', schema=t.TEST_SCHEMA_PRODUCTION)